@@ -0,0 +1,186 @@
+use criterion::{black_box, criterion_group, criterion_main, BenchmarkId, Criterion};
+use lsif_core::compact_graph::CompactGraph;
+use lsif_core::graph_serde::SerializedCodeGraph;
+use lsif_core::{CodeGraph, EdgeKind, Position, Range, Symbol, SymbolKind};
+use tempfile::TempDir;
+
+/// ファイルごとにモジュールと関数を持ち、関数が次のファイルの関数を参照するグラフ
+fn generate_graph(symbol_count: usize) -> CodeGraph {
+    let mut graph = CodeGraph::new();
+    let mut nodes = Vec::with_capacity(symbol_count);
+    for i in 0..symbol_count {
+        let file = i / 20;
+        let symbol = Symbol {
+            id: format!("src/module_{}.rs#{}:function_{}", file, i % 20, i),
+            kind: if i % 20 == 0 {
+                SymbolKind::Module
+            } else {
+                SymbolKind::Function
+            },
+            name: format!("function_{}", i % 200),
+            file_path: format!("src/module_{}.rs", file),
+            range: Range {
+                start: Position {
+                    line: (i % 20) as u32 * 10,
+                    character: 0,
+                },
+                end: Position {
+                    line: (i % 20) as u32 * 10 + 8,
+                    character: 1,
+                },
+            },
+            documentation: (i % 3 == 0).then(|| format!("Documentation {}", i % 50)),
+            detail: Some(format!("fn function_{}()", i % 200)),
+            modifiers: Default::default(),
+            qualified_name: Some(format!("crate::module_{}::function_{}", file, i % 200)),
+        };
+        nodes.push(graph.add_symbol(symbol));
+    }
+    for i in 0..symbol_count {
+        if i % 20 != 0 {
+            graph.add_edge(nodes[i], nodes[i - i % 20], EdgeKind::Contains);
+        }
+        let target = (i + 20) % symbol_count;
+        graph.add_edge(nodes[i], nodes[target], EdgeKind::Reference);
+    }
+    graph
+}
+
+/// 保存したグラフを読み込む時間（bincodeで`CodeGraph`に戻す場合とメモリマップの場合）
+fn benchmark_load(c: &mut Criterion) {
+    let mut group = c.benchmark_group("graph_load");
+    group.sample_size(20);
+
+    for size in [1_000, 10_000, 100_000] {
+        let graph = generate_graph(size);
+        let serialized = SerializedCodeGraph::from(&graph);
+        let temp_dir = TempDir::new().unwrap();
+        let bincode_path = temp_dir.path().join("graph.bin");
+        let compact_path = temp_dir.path().join("graph.csr");
+        std::fs::write(&bincode_path, bincode::serialize(&graph).unwrap()).unwrap();
+        std::fs::write(&compact_path, CompactGraph::build(&serialized, 1).unwrap()).unwrap();
+
+        group.bench_with_input(
+            BenchmarkId::new("bincode_code_graph", size),
+            &size,
+            |b, _| {
+                b.iter(|| {
+                    let bytes = std::fs::read(&bincode_path).unwrap();
+                    black_box(bincode::deserialize::<CodeGraph>(&bytes).unwrap())
+                })
+            },
+        );
+
+        group.bench_with_input(
+            BenchmarkId::new("mmap_compact_graph", size),
+            &size,
+            |b, _| b.iter(|| black_box(CompactGraph::open(&compact_path).unwrap())),
+        );
+
+        group.bench_with_input(
+            BenchmarkId::new("build_compact_graph", size),
+            &size,
+            |b, _| b.iter(|| black_box(CompactGraph::build(&serialized, 1).unwrap())),
+        );
+    }
+
+    group.finish();
+}
+
+/// 読み込み済みのグラフでの検索
+fn benchmark_queries(c: &mut Criterion) {
+    let mut group = c.benchmark_group("graph_queries");
+
+    for size in [10_000, 100_000] {
+        let graph = generate_graph(size);
+        let compact = CompactGraph::from_bytes(
+            CompactGraph::build(&SerializedCodeGraph::from(&graph), 1).unwrap(),
+        )
+        .unwrap();
+        let ids: Vec<String> = (0..size)
+            .step_by(size / 100)
+            .map(|i| format!("src/module_{}.rs#{}:function_{}", i / 20, i % 20, i))
+            .collect();
+
+        group.bench_with_input(BenchmarkId::new("code_graph_find", size), &size, |b, _| {
+            b.iter(|| {
+                for id in &ids {
+                    black_box(graph.find_symbol(id));
+                }
+            })
+        });
+
+        group.bench_with_input(
+            BenchmarkId::new("compact_graph_find", size),
+            &size,
+            |b, _| {
+                b.iter(|| {
+                    for id in &ids {
+                        black_box(compact.find_symbol(id).map(|symbol| symbol.name()));
+                    }
+                })
+            },
+        );
+
+        group.bench_with_input(
+            BenchmarkId::new("code_graph_references", size),
+            &size,
+            |b, _| {
+                b.iter(|| {
+                    for id in &ids {
+                        black_box(graph.find_references(id).unwrap());
+                    }
+                })
+            },
+        );
+
+        group.bench_with_input(
+            BenchmarkId::new("compact_graph_references", size),
+            &size,
+            |b, _| {
+                b.iter(|| {
+                    for id in &ids {
+                        let symbol = compact.find_symbol(id).unwrap().symbol_id();
+                        let references: Vec<Symbol> = compact
+                            .incoming_edges(symbol)
+                            .filter(|(_, edge)| edge.kind == EdgeKind::Reference)
+                            .map(|(from, _)| compact.symbol(from).to_symbol())
+                            .collect();
+                        black_box(references);
+                    }
+                })
+            },
+        );
+
+        group.bench_with_input(BenchmarkId::new("code_graph_scan", size), &size, |b, _| {
+            b.iter(|| {
+                black_box(
+                    graph
+                        .get_all_symbols()
+                        .filter(|symbol| symbol.name == "function_42")
+                        .count(),
+                )
+            })
+        });
+
+        group.bench_with_input(
+            BenchmarkId::new("compact_graph_scan", size),
+            &size,
+            |b, _| {
+                b.iter(|| {
+                    black_box(
+                        compact
+                            .symbols()
+                            .filter(|symbol| symbol.name() == "function_42")
+                            .count(),
+                    )
+                })
+            },
+        );
+    }
+
+    group.finish();
+}
+
+criterion_group!(benches, benchmark_load, benchmark_queries);
+criterion_main!(benches);
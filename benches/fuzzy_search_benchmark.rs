@@ -70,6 +70,8 @@ fn create_test_symbols(count: usize) -> Vec<Symbol> {
                         None
                     },
                     detail: None,
+                    modifiers: Default::default(),
+                    qualified_name: None,
                 });
                 index += 1;
             }
@@ -96,6 +98,8 @@ fn create_test_symbols(count: usize) -> Vec<Symbol> {
             },
             documentation: None,
         detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         });
     }
 
@@ -255,6 +259,8 @@ fn benchmark_trigram_generation(c: &mut Criterion) {
                     },
                     documentation: None,
         detail: None,
+                    modifiers: Default::default(),
+                    qualified_name: None,
                 };
                 index.add_symbol(symbol);
                 black_box(index.stats())
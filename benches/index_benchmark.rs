@@ -27,6 +27,8 @@ fn create_small_graph() -> CodeGraph {
             },
             documentation: Some(format!("Documentation for function_{i}")),
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         };
         graph.add_symbol(symbol);
     }
@@ -75,6 +77,8 @@ fn create_medium_graph() -> CodeGraph {
             },
             documentation: Some(format!("Documentation for symbol_{i}")),
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         };
         graph.add_symbol(symbol);
     }
@@ -137,6 +141,8 @@ fn create_large_graph() -> CodeGraph {
                 None
             },
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         };
         let idx = graph.add_symbol(symbol);
         indices.insert(format!("symbol_{i}"), idx);
@@ -326,6 +332,8 @@ fn benchmark_edge_operations(c: &mut Criterion) {
                     },
                     documentation: None,
         detail: None,
+                    modifiers: Default::default(),
+                    qualified_name: None,
                 };
                 indices.push(graph.add_symbol(symbol));
             }
@@ -363,6 +371,8 @@ fn benchmark_edge_operations(c: &mut Criterion) {
                     },
                     documentation: None,
         detail: None,
+                    modifiers: Default::default(),
+                    qualified_name: None,
                 };
                 indices.push(graph.add_symbol(symbol));
             }
@@ -412,6 +422,8 @@ fn benchmark_incremental_updates(c: &mut Criterion) {
                 },
                 documentation: Some(format!("Doc for function_{file_idx}_{sym_idx}")),
                 detail: None,
+                modifiers: Default::default(),
+                qualified_name: None,
             };
             file_symbols.push(symbol.clone());
             all_symbols.push(symbol);
@@ -450,6 +462,8 @@ fn benchmark_incremental_updates(c: &mut Criterion) {
                     },
                     documentation: Some(format!("Updated doc {i}")),
                     detail: None,
+                    modifiers: Default::default(),
+                    qualified_name: None,
                 });
             }
 
@@ -487,6 +501,8 @@ fn benchmark_incremental_updates(c: &mut Criterion) {
                         },
                         documentation: Some(format!("Updated doc {file_idx}_{sym_idx}")),
                         detail: None,
+                        modifiers: Default::default(),
+                        qualified_name: None,
                     });
                 }
                 updates.push(FileUpdate::Modified {
@@ -535,6 +551,8 @@ fn benchmark_incremental_updates(c: &mut Criterion) {
                     },
                     documentation: None,
         detail: None,
+                    modifiers: Default::default(),
+                    qualified_name: None,
                 })
                 .unwrap();
         }
@@ -575,6 +593,8 @@ fn benchmark_storage_operations(c: &mut Criterion) {
             },
             documentation: Some(format!("Doc {i}")),
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         });
     }
 
@@ -74,6 +74,8 @@ fn extract_symbols_from_file(file_path: &Path) -> Vec<Symbol> {
                         },
                         documentation: Some(format!("Function {name}")),
                         detail: None,
+                        modifiers: Default::default(),
+                        qualified_name: None,
                     });
                 }
             }
@@ -98,6 +100,8 @@ fn extract_symbols_from_file(file_path: &Path) -> Vec<Symbol> {
                         },
                         documentation: Some(format!("Struct {name}")),
                         detail: None,
+                        modifiers: Default::default(),
+                        qualified_name: None,
                     });
                 }
             }
@@ -122,6 +126,8 @@ fn extract_symbols_from_file(file_path: &Path) -> Vec<Symbol> {
                         },
                         documentation: Some(format!("Constant {name}")),
                         detail: None,
+                        modifiers: Default::default(),
+                        qualified_name: None,
                     });
                 }
             }
@@ -32,6 +32,8 @@ fn create_test_symbol(id: usize) -> Symbol {
             None
         },
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     }
 }
 
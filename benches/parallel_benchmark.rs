@@ -4,8 +4,13 @@ use lsif_core::parallel::{
     ParallelIncrementalIndex,
 };
 // use lsif_core::parallel_optimized::{OptimizedDeadCodeDetector, OptimizedParallelGraph};
+use cli::type_search::{TypeFilter, TypeSearchEngine};
+use lsif_core::graph_query::{QueryEngine, QueryParser};
 use lsif_core::incremental::UpdateResult;
-use lsif_core::{CodeGraph, EdgeKind, IncrementalIndex, Position, Range, Symbol, SymbolKind};
+use lsif_core::{
+    CodeGraph, ComplexityAnalyzer, EdgeKind, IncrementalIndex, Position, PublicApiAnalyzer, Range,
+    Symbol, SymbolKind,
+};
 use std::collections::HashMap;
 use std::path::PathBuf;
 
@@ -38,6 +43,8 @@ fn create_symbols(count: usize) -> Vec<Symbol> {
                 None
             },
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         })
         .collect()
 }
@@ -174,6 +181,8 @@ fn benchmark_file_updates(c: &mut Criterion) {
                         },
                         documentation: None,
         detail: None,
+                        modifiers: Default::default(),
+                        qualified_name: None,
                     },
                     Symbol {
                         id: format!("file{i}_sym2"),
@@ -192,6 +201,8 @@ fn benchmark_file_updates(c: &mut Criterion) {
                         },
                         documentation: None,
         detail: None,
+                        modifiers: Default::default(),
+                        qualified_name: None,
                     },
                 ];
                 let hash = format!("hash_{i}");
@@ -265,6 +276,8 @@ fn benchmark_dead_code_detection(c: &mut Criterion) {
                 },
                 documentation: None,
         detail: None,
+                modifiers: Default::default(),
+                qualified_name: None,
             })
             .unwrap();
 
@@ -289,6 +302,8 @@ fn benchmark_dead_code_detection(c: &mut Criterion) {
                 },
                 documentation: None,
         detail: None,
+                modifiers: Default::default(),
+                qualified_name: None,
             };
             let idx = index.graph.add_symbol(symbol.clone());
             index.symbol_to_file.insert(
@@ -327,6 +342,8 @@ fn benchmark_dead_code_detection(c: &mut Criterion) {
                 },
                 documentation: None,
         detail: None,
+                modifiers: Default::default(),
+                qualified_name: None,
             };
             index.add_symbol(symbol).unwrap();
         }
@@ -468,6 +485,76 @@ fn benchmark_file_hash_calculation(c: &mut Criterion) {
     group.finish();
 }
 
+/// 読み取り専用の解析（クエリ・型検索・複雑度・公開API）をスレッド数を変えて比べる
+fn benchmark_read_paths(c: &mut Criterion) {
+    let mut group = c.benchmark_group("read_paths");
+    group.sample_size(20);
+
+    let size = 20_000;
+    let mut graph = CodeGraph::new();
+    for (i, mut symbol) in create_symbols(size).into_iter().enumerate() {
+        if matches!(symbol.kind, SymbolKind::Function | SymbolKind::Method) {
+            let returns = if i % 3 == 0 {
+                "Result<String>"
+            } else {
+                "usize"
+            };
+            symbol.detail = Some(format!("pub fn {}(input: &str) -> {returns}", symbol.name));
+        }
+        graph.add_symbol(symbol);
+    }
+    for i in 0..size {
+        for step in [1, 7, 31] {
+            if let (Some(from), Some(to)) = (
+                graph.get_node_index(&format!("symbol_{i}")),
+                graph.get_node_index(&format!("symbol_{}", (i + step) % size)),
+            ) {
+                graph.add_edge(from, to, EdgeKind::Reference);
+            }
+        }
+    }
+    let pattern = QueryParser::parse("(caller:Function)-[:Reference*1..3]->(cls:Class)").unwrap();
+    let filters = [TypeFilter::Returns("Result".to_string())];
+    let api_analyzer = PublicApiAnalyzer::new(graph.clone());
+
+    let mut thread_counts = vec![1, rayon::current_num_threads()];
+    thread_counts.dedup();
+    for threads in thread_counts {
+        let pool = rayon::ThreadPoolBuilder::new()
+            .num_threads(threads)
+            .build()
+            .unwrap();
+
+        group.bench_with_input(BenchmarkId::new("query", threads), &graph, |b, graph| {
+            b.iter(|| pool.install(|| QueryEngine::new(graph).execute(&pattern)))
+        });
+
+        group.bench_with_input(
+            BenchmarkId::new("type_search", threads),
+            &graph,
+            |b, graph| {
+                b.iter(|| pool.install(|| TypeSearchEngine::new(graph).search(&filters, size)))
+            },
+        );
+
+        group.bench_with_input(
+            BenchmarkId::new("complexity", threads),
+            &graph,
+            |b, graph| {
+                b.iter(|| pool.install(|| ComplexityAnalyzer::new(graph).analyze_all_functions()))
+            },
+        );
+
+        group.bench_with_input(
+            BenchmarkId::new("public_api", threads),
+            &api_analyzer,
+            |b, analyzer| b.iter(|| pool.install(|| analyzer.extract_public_apis("rust"))),
+        );
+    }
+
+    group.finish();
+}
+
 criterion_group!(
     parallel_benches,
     benchmark_symbol_addition,
@@ -475,6 +562,7 @@ criterion_group!(
     benchmark_file_updates,
     benchmark_dead_code_detection,
     benchmark_lsif_generation,
-    benchmark_file_hash_calculation
+    benchmark_file_hash_calculation,
+    benchmark_read_paths
 );
 criterion_main!(parallel_benches);
@@ -32,6 +32,8 @@ fn generate_test_symbols(count: usize) -> Vec<Symbol> {
                 None
             },
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         })
         .collect()
 }
@@ -1,8 +1,8 @@
 use criterion::{black_box, criterion_group, criterion_main, BenchmarkId, Criterion};
-// use lsif_core::interned_graph::InternedGraph;
+use lsif_core::interned_graph::InternedGraph;
 // use lsif_core::optimized_graph::OptimizedCodeGraph;
 // use lsif_core::string_interner::StringInterner;
-use lsif_core::{CodeGraph, Position, Range, Symbol, SymbolKind};
+use lsif_core::{CodeGraph, Edge, EdgeKind, GraphStore, Position, Range, Symbol, SymbolKind};
 
 /// テスト用のSymbolを生成（重複する文字列を含む）
 fn create_test_symbol_with_duplicates(id: usize) -> Symbol {
@@ -29,6 +29,8 @@ fn create_test_symbol_with_duplicates(id: usize) -> Symbol {
         },
         documentation: Some(format!("Documentation type {}", id % 20)), // 20個のユニークなドキュメント
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     }
 }
 
@@ -110,14 +112,12 @@ fn benchmark_graph_with_interning(c: &mut Criterion) {
             })
         });
 
-        // InternedGraph is behind experimental-optimizations feature
-        /*
         group.bench_with_input(
             BenchmarkId::new("interned_graph", size),
             size,
             |b, &size| {
                 b.iter(|| {
-                    let graph = InternedGraph::new();
+                    let mut graph = InternedGraph::new();
 
                     let symbols: Vec<Symbol> =
                         (0..size).map(create_test_symbol_with_duplicates).collect();
@@ -127,7 +127,6 @@ fn benchmark_graph_with_interning(c: &mut Criterion) {
                 })
             },
         );
-        */
     }
 
     group.finish();
@@ -159,11 +158,9 @@ fn benchmark_memory_usage(c: &mut Criterion) {
         })
     });
 
-    // InternedGraph is behind experimental-optimizations feature
-    /*
     group.bench_function("interned_memory", |b| {
         b.iter(|| {
-            let graph = InternedGraph::new();
+            let mut graph = InternedGraph::new();
 
             for i in 0..size {
                 graph.add_symbol(create_test_symbol_with_duplicates(i));
@@ -173,7 +170,6 @@ fn benchmark_memory_usage(c: &mut Criterion) {
             black_box(memory)
         })
     });
-    */
 
     group.finish();
 }
@@ -193,25 +189,22 @@ fn benchmark_search_performance(c: &mut Criterion) {
         graph
     };
 
-    // InternedGraph is behind experimental-optimizations feature
-    /*
     let interned_graph = {
-        let graph = InternedGraph::new();
+        let mut graph = InternedGraph::new();
         for i in 0..size {
             graph.add_symbol(create_test_symbol_with_duplicates(i));
         }
 
         // エッジを追加
         for i in 0..size / 10 {
-            graph.add_edge(
+            graph.add_edge_between(
                 &format!("symbol_{}", i),
                 &format!("symbol_{}", (i + 1) % size),
-                core::EdgeKind::Reference,
+                Edge::new(EdgeKind::Reference),
             );
         }
         graph
     };
-    */
 
     // Symbol検索のベンチマーク
     group.bench_function("standard_find_symbol", |b| {
@@ -222,8 +215,6 @@ fn benchmark_search_performance(c: &mut Criterion) {
         })
     });
 
-    // InternedGraph is behind experimental-optimizations feature
-    /*
     group.bench_function("interned_find_symbol", |b| {
         b.iter(|| {
             for i in (0..100).step_by(10) {
@@ -238,7 +229,6 @@ fn benchmark_search_performance(c: &mut Criterion) {
             black_box(interned_graph.find_references("symbol_250"));
         })
     });
-    */
 
     group.finish();
 }
@@ -248,7 +238,7 @@ fn benchmark_realistic_project(c: &mut Criterion) {
     let mut group = c.benchmark_group("realistic_project");
 
     // 大規模プロジェクト（1000ファイル、各50シンボル）
-    let _total_symbols = 50000;
+    let total_symbols = 50000;
 
     group.bench_function("large_project_standard", |b| {
         b.iter(|| {
@@ -265,11 +255,9 @@ fn benchmark_realistic_project(c: &mut Criterion) {
         })
     });
 
-    // InternedGraph is behind experimental-optimizations feature
-    /*
     group.bench_function("large_project_interned", |b| {
         b.iter(|| {
-            let graph = InternedGraph::new();
+            let mut graph = InternedGraph::new();
 
             let symbols: Vec<Symbol> = (0..total_symbols)
                 .map(create_test_symbol_with_duplicates)
@@ -281,7 +269,6 @@ fn benchmark_realistic_project(c: &mut Criterion) {
             black_box((graph.symbol_count(), stats.cache_hits))
         })
     });
-    */
 
     group.finish();
 }
@@ -1,5 +1,6 @@
 use anyhow::Result;
 use clap::Parser;
+use cli::cancellation::{is_interrupted, INTERRUPTED_EXIT_CODE};
 use cli::Cli;
 
 fn main() -> Result<()> {
@@ -20,7 +21,9 @@ fn main() -> Result<()> {
 
     // CLIパース＆実行
     let cli = Cli::parse();
-    cli.run()?;
-
-    Ok(())
+    match cli.run() {
+        // 中断はメッセージを表示済みなので、エラーとしては扱わず終了コードだけ返す
+        Err(e) if is_interrupted(&e) => std::process::exit(INTERRUPTED_EXIT_CODE),
+        result => result,
+    }
 }
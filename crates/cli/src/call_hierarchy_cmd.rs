@@ -1,21 +1,28 @@
 use crate::storage::IndexStorage;
 use anyhow::Result;
 use lsif_core::call_hierarchy::{format_hierarchy, CallHierarchyAnalyzer};
-use lsif_core::CodeGraph;
+use lsif_core::{GraphBackend, GraphStore};
+
+/// 保存されたグラフを`backend`の保存方式で読み込む
+fn load_graph(
+    index_path: &str,
+    backend: GraphBackend,
+) -> Result<Box<dyn GraphStore + Send + Sync>> {
+    IndexStorage::open(index_path)?
+        .load_graph_store(backend)?
+        .ok_or_else(|| anyhow::anyhow!("No graph found in index"))
+}
 
 pub fn show_call_hierarchy(
     index_path: &str,
     symbol_id: &str,
     direction: &str,
     max_depth: usize,
+    backend: GraphBackend,
 ) -> Result<()> {
-    // Load the index
-    let storage = IndexStorage::open(index_path)?;
-    let graph: CodeGraph = storage
-        .load_data("graph")?
-        .ok_or_else(|| anyhow::anyhow!("No graph found in index"))?;
+    let graph = load_graph(index_path, backend)?;
 
-    let analyzer = CallHierarchyAnalyzer::new(&graph);
+    let analyzer = CallHierarchyAnalyzer::new(&*graph);
 
     match direction {
         "incoming" | "callers" => {
@@ -58,14 +65,11 @@ pub fn find_paths(
     from_symbol: &str,
     to_symbol: &str,
     max_depth: usize,
+    backend: GraphBackend,
 ) -> Result<()> {
-    // Load the index
-    let storage = IndexStorage::open(index_path)?;
-    let graph: CodeGraph = storage
-        .load_data("graph")?
-        .ok_or_else(|| anyhow::anyhow!("No graph found in index"))?;
+    let graph = load_graph(index_path, backend)?;
 
-    let analyzer = CallHierarchyAnalyzer::new(&graph);
+    let analyzer = CallHierarchyAnalyzer::new(&*graph);
     let paths = analyzer.find_call_paths(from_symbol, to_symbol, max_depth);
 
     if paths.is_empty() {
@@ -84,7 +88,7 @@ pub fn find_paths(
 #[cfg(test)]
 mod tests {
     use super::*;
-    use lsif_core::{EdgeKind, Position, Range, Symbol, SymbolKind};
+    use lsif_core::{CodeGraph, EdgeKind, Position, Range, Symbol, SymbolKind};
     use tempfile::TempDir;
 
     fn create_test_graph() -> CodeGraph {
@@ -108,6 +112,8 @@ mod tests {
             },
             documentation: None,
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         };
 
         let helper_fn = Symbol {
@@ -127,6 +133,8 @@ mod tests {
             },
             documentation: None,
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         };
 
         let util_fn = Symbol {
@@ -146,6 +154,8 @@ mod tests {
             },
             documentation: None,
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         };
 
         // Add symbols to graph
@@ -172,7 +182,13 @@ mod tests {
         storage.save_data("graph", &graph).unwrap();
 
         // Test incoming calls
-        let result = show_call_hierarchy(index_path.to_str().unwrap(), "helper", "incoming", 3);
+        let result = show_call_hierarchy(
+            index_path.to_str().unwrap(),
+            "helper",
+            "incoming",
+            3,
+            GraphBackend::default(),
+        );
 
         assert!(result.is_ok());
     }
@@ -189,7 +205,13 @@ mod tests {
         storage.save_data("graph", &graph).unwrap();
 
         // Test outgoing calls
-        let result = show_call_hierarchy(index_path.to_str().unwrap(), "helper", "outgoing", 3);
+        let result = show_call_hierarchy(
+            index_path.to_str().unwrap(),
+            "helper",
+            "outgoing",
+            3,
+            GraphBackend::default(),
+        );
 
         assert!(result.is_ok());
     }
@@ -206,7 +228,13 @@ mod tests {
         storage.save_data("graph", &graph).unwrap();
 
         // Test full hierarchy
-        let result = show_call_hierarchy(index_path.to_str().unwrap(), "helper", "full", 3);
+        let result = show_call_hierarchy(
+            index_path.to_str().unwrap(),
+            "helper",
+            "full",
+            3,
+            GraphBackend::default(),
+        );
 
         assert!(result.is_ok());
     }
@@ -223,7 +251,13 @@ mod tests {
         storage.save_data("graph", &graph).unwrap();
 
         // Test invalid direction
-        let result = show_call_hierarchy(index_path.to_str().unwrap(), "helper", "invalid", 3);
+        let result = show_call_hierarchy(
+            index_path.to_str().unwrap(),
+            "helper",
+            "invalid",
+            3,
+            GraphBackend::default(),
+        );
 
         assert!(result.is_err());
         assert!(result
@@ -242,7 +276,13 @@ mod tests {
         let _storage = IndexStorage::open(&index_path).unwrap();
 
         // Test with no graph
-        let result = show_call_hierarchy(index_path.to_str().unwrap(), "helper", "incoming", 3);
+        let result = show_call_hierarchy(
+            index_path.to_str().unwrap(),
+            "helper",
+            "incoming",
+            3,
+            GraphBackend::default(),
+        );
 
         assert!(result.is_err());
         assert!(result.unwrap_err().to_string().contains("No graph found"));
@@ -260,7 +300,13 @@ mod tests {
         storage.save_data("graph", &graph).unwrap();
 
         // Test finding paths
-        let result = find_paths(index_path.to_str().unwrap(), "main", "util", 5);
+        let result = find_paths(
+            index_path.to_str().unwrap(),
+            "main",
+            "util",
+            5,
+            GraphBackend::default(),
+        );
 
         assert!(result.is_ok());
     }
@@ -277,7 +323,13 @@ mod tests {
         storage.save_data("graph", &graph).unwrap();
 
         // Test finding paths with no connection
-        let result = find_paths(index_path.to_str().unwrap(), "util", "main", 5);
+        let result = find_paths(
+            index_path.to_str().unwrap(),
+            "util",
+            "main",
+            5,
+            GraphBackend::default(),
+        );
 
         assert!(result.is_ok());
     }
@@ -0,0 +1,162 @@
+//! Ctrl-Cによる中断
+//!
+//! 1回目のCtrl-Cでは中断を要求するだけで、インデックス中の処理はファイルの切れ目で止まり、
+//! それまでの結果を保存してLSPサーバーを終了させる。2回目のCtrl-Cですぐに終了する。
+//! インデックス処理の外（クエリの実行中など）では1回目のCtrl-Cですぐに終了する。
+
+use std::sync::atomic::{AtomicBool, Ordering};
+use std::sync::{Arc, Mutex, Once};
+use tracing::warn;
+
+/// 中断された時の終了コード（128 + SIGINT）
+pub const INTERRUPTED_EXIT_CODE: i32 = 130;
+
+/// インデックス処理が中断されたことを表すエラー
+///
+/// 途中までの結果は保存済みなので、呼び出し元はエラーとして表示せずに
+/// `INTERRUPTED_EXIT_CODE`で終了する（終了コードへの変換はmainだけで行う）。
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub struct Interrupted;
+
+impl std::fmt::Display for Interrupted {
+    fn fmt(&self, f: &mut std::fmt::Formatter<'_>) -> std::fmt::Result {
+        write!(f, "Interrupted")
+    }
+}
+
+impl std::error::Error for Interrupted {}
+
+/// エラーが（文脈を付けられていても）中断によるものか
+pub fn is_interrupted(error: &anyhow::Error) -> bool {
+    error.downcast_ref::<Interrupted>().is_some()
+}
+
+/// 中断の要求（複製しても同じ要求を共有する）
+#[derive(Debug, Clone, Default)]
+pub struct CancellationToken(Arc<AtomicBool>);
+
+impl CancellationToken {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    pub fn cancel(&self) {
+        self.0.store(true, Ordering::SeqCst);
+    }
+
+    pub fn is_cancelled(&self) -> bool {
+        self.0.load(Ordering::SeqCst)
+    }
+}
+
+/// 実行中のインデックス処理のトークン（処理中でなければNone）
+static ACTIVE: Mutex<Option<CancellationToken>> = Mutex::new(None);
+
+/// Ctrl-Cで中断が要求される範囲（インデックス処理の間だけ保持する）
+///
+/// 範囲の外でのCtrl-Cは通常どおりすぐにプロセスを終了させる。
+pub struct CtrlCScope {
+    token: CancellationToken,
+}
+
+impl CtrlCScope {
+    /// この範囲でCtrl-Cが押されると中断が要求されるトークン
+    pub fn token(&self) -> CancellationToken {
+        self.token.clone()
+    }
+}
+
+impl Drop for CtrlCScope {
+    fn drop(&mut self) {
+        let mut active = ACTIVE.lock().unwrap_or_else(|e| e.into_inner());
+        *active = None;
+    }
+}
+
+/// Ctrl-Cで中断を要求する範囲を始める（ハンドラはプロセスで一度だけ登録する）
+pub fn ctrl_c_scope() -> CtrlCScope {
+    static INSTALL: Once = Once::new();
+    INSTALL.call_once(|| {
+        let spawned = std::thread::Builder::new()
+            .name("ctrl-c".to_string())
+            .spawn(wait_for_ctrl_c);
+        if let Err(e) = spawned {
+            warn!("Failed to install Ctrl-C handler: {}", e);
+        }
+    });
+
+    let token = CancellationToken::new();
+    *ACTIVE.lock().unwrap_or_else(|e| e.into_inner()) = Some(token.clone());
+    CtrlCScope { token }
+}
+
+fn active_token() -> Option<CancellationToken> {
+    ACTIVE.lock().unwrap_or_else(|e| e.into_inner()).clone()
+}
+
+fn wait_for_ctrl_c() {
+    let runtime = match tokio::runtime::Builder::new_current_thread()
+        .enable_all()
+        .build()
+    {
+        Ok(runtime) => runtime,
+        Err(e) => {
+            warn!("Failed to install Ctrl-C handler: {}", e);
+            return;
+        }
+    };
+    runtime.block_on(async {
+        while tokio::signal::ctrl_c().await.is_ok() {
+            match active_token() {
+                Some(token) if !token.is_cancelled() => {
+                    token.cancel();
+                    eprintln!(
+                        "\n⏸️  Interrupted: finishing the current file and saving progress (press Ctrl-C again to abort)"
+                    );
+                }
+                Some(_) => {
+                    eprintln!("\nAborted");
+                    std::process::exit(INTERRUPTED_EXIT_CODE);
+                }
+                // インデックス処理の外では通常のSIGINTと同じくすぐに終了する
+                None => std::process::exit(INTERRUPTED_EXIT_CODE),
+            }
+        }
+    });
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_cancellation_is_shared_between_clones() {
+        let token = CancellationToken::new();
+        let clone = token.clone();
+        assert!(!clone.is_cancelled());
+        token.cancel();
+        assert!(clone.is_cancelled());
+    }
+
+    #[test]
+    fn test_interrupted_is_detected_through_context() {
+        use anyhow::Context;
+
+        let error = Err::<(), _>(Interrupted)
+            .context("Failed to index")
+            .unwrap_err();
+        assert!(is_interrupted(&error));
+        assert!(!is_interrupted(&anyhow::anyhow!("Interrupted")));
+    }
+
+    #[test]
+    fn test_ctrl_c_scope_is_cleared_on_drop() {
+        let scope = ctrl_c_scope();
+        let token = scope.token();
+        assert!(active_token().is_some());
+
+        drop(scope);
+        assert!(active_token().is_none());
+        assert!(!token.is_cancelled());
+    }
+}
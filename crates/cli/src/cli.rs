@@ -4,14 +4,22 @@ mod commands;
 use anyhow::Result;
 use clap::{Parser, Subcommand};
 use std::path::Path;
-use std::time::Instant;
+use std::time::{Duration, Instant};
 
+use crate::cancellation::{ctrl_c_scope, Interrupted};
 use crate::differential_indexer::DifferentialIndexer;
-use crate::git_diff::GitDiffDetector;
+use crate::memory_budget::{parse_memory_size, MemoryBudget};
+use crate::staleness::parse_duration;
+use crate::storage::IndexStorage;
 use commands::{
-    crawl::handle_crawl, definition::handle_definition, index::handle_index,
-    references::handle_references, search::handle_search, utils::print_success,
+    crawl::handle_crawl,
+    definition::handle_definition,
+    index::handle_index,
+    references::handle_references,
+    search::handle_search,
+    utils::{print_success, print_warning},
 };
+use lsif_core::GraphBackend;
 
 const DEFAULT_INDEX_PATH: &str = ".lsif-index.db";
 
@@ -32,6 +40,33 @@ pub struct Cli {
     #[arg(short = 'n', long = "no-auto-index", global = true)]
     pub no_auto_index: bool,
 
+    /// Accept a changed project without reindexing while the index is younger than this
+    /// (e.g. 30s, 5m, 1h; default 0 = reindex on any change, env: LSIF_MAX_STALENESS)
+    #[arg(
+        long = "max-staleness",
+        global = true,
+        value_name = "DURATION",
+        value_parser = parse_duration
+    )]
+    pub max_staleness: Option<Duration>,
+
+    /// Bound the memory used for symbols while indexing the whole project by spilling
+    /// per-file results to disk (e.g. 512M, 2G; env: LSIF_MAX_MEMORY). Only full indexing
+    /// (first run, --force, --resume) is bounded; differential runs, --workspace-symbol and
+    /// watch load the whole graph
+    #[arg(
+        long = "max-memory",
+        global = true,
+        value_name = "SIZE",
+        value_parser = parse_memory_size
+    )]
+    pub max_memory: Option<MemoryBudget>,
+
+    /// Worker threads for parallel queries and analysis
+    /// (default 0 = one per CPU, env: LSIF_THREADS)
+    #[arg(long = "threads", global = true, value_name = "N")]
+    pub threads: Option<usize>,
+
     /// Verbose output
     #[arg(short = 'v', long = "verbose", global = true)]
     pub verbose: bool,
@@ -40,6 +75,15 @@ pub struct Cli {
     #[arg(long = "no-lsp", global = true)]
     pub no_lsp: bool,
 
+    /// Write every LSP request/response/notification to this file (JSONL)
+    #[arg(long = "lsp-trace", global = true, value_name = "FILE")]
+    pub lsp_trace: Option<String>,
+
+    /// In-memory graph for call hierarchy: standard or interned (less memory on
+    /// large graphs; env: LSIF_GRAPH_BACKEND)
+    #[arg(long = "graph-backend", global = true, value_name = "BACKEND")]
+    pub graph_backend: Option<GraphBackend>,
+
     /// Output format (human, quickfix, lsp, grep, json, tsv, null)
     #[arg(short = 'f', long = "format", global = true, default_value = "human")]
     pub format: String,
@@ -76,6 +120,14 @@ pub enum Commands {
         /// Group by file
         #[arg(short = 'g', long = "group")]
         group_by_file: bool,
+
+        /// Only show writes (assignments, x++, &x) to a variable or field
+        #[arg(short = 'w', long = "writes")]
+        writes: bool,
+
+        /// Hide references found with lower confidence (LSP 1.0, tree-sitter 0.8, regex 0.3)
+        #[arg(long = "min-confidence", default_value_t = 0.0)]
+        min_confidence: f32,
     },
 
     /// Show call hierarchy [aliases: calls, c]
@@ -132,6 +184,10 @@ pub enum Commands {
         #[arg(long = "has-field")]
         has_field: Option<String>,
 
+        /// Filter by modifiers, all must match (e.g. "public,async")
+        #[arg(long = "modifier")]
+        modifier: Option<String>,
+
         /// Maximum results (default: 50)
         #[arg(short = 'm', long = "max", default_value = "50")]
         max_results: usize,
@@ -155,6 +211,10 @@ pub enum Commands {
         /// Use workspace/symbol API for fast indexing (if supported)
         #[arg(long = "workspace-symbol")]
         workspace_symbol: bool,
+
+        /// Continue an interrupted full index from its last checkpoint
+        #[arg(long = "resume", conflicts_with = "force")]
+        resume: bool,
     },
 
     /// Smart crawl from current file using definitions
@@ -208,6 +268,55 @@ pub enum Commands {
         by_type: bool,
     },
 
+    /// Show learned LSP response time statistics
+    LspStats {
+        /// Filter by language (e.g. rust, typescript)
+        #[arg(short = 'l', long = "language")]
+        language: Option<String>,
+    },
+
+    /// Inspect LSP protocol traces recorded with --lsp-trace
+    LspTrace {
+        #[command(subcommand)]
+        action: LspTraceCommands,
+    },
+
+    /// List usages of deprecated APIs grouped by package
+    Deprecated {
+        /// Ignore usages recorded in this file (output of `lsif deprecated --format json`)
+        #[arg(short = 'b', long = "baseline", value_name = "FILE")]
+        baseline: Option<String>,
+
+        /// Exit with an error if any (new) usage is found
+        #[arg(short = 'c', long = "check")]
+        check: bool,
+    },
+
+    /// Check the stored index for corruption and the LSP servers for installation
+    Doctor {
+        /// Repair the graph and drop symbols of missing or changed files
+        #[arg(long = "fix")]
+        fix: bool,
+
+        /// Like --fix, then reindex the affected files right away
+        #[arg(long = "reindex")]
+        reindex: bool,
+    },
+
+    /// Watch the project and reindex edited files incrementally
+    Watch {
+        /// Wait this long (ms) after the last change before reindexing
+        #[arg(long = "debounce", default_value = "200", value_name = "MS")]
+        debounce: u64,
+    },
+
+    /// Show the tree-sitter query files in use and validate them
+    Queries {
+        /// Filter by language (rust, typescript, python, go)
+        #[arg(short = 'l', long = "language")]
+        language: Option<String>,
+    },
+
     /// Export index data [alias: e]
     #[command(visible_alias = "e")]
     Export {
@@ -221,6 +330,23 @@ pub enum Commands {
         /// Include references
         #[arg(short = 'r', long = "refs")]
         include_refs: bool,
+
+        /// Export only symbols with these modifiers (e.g. "exported")
+        #[arg(long = "modifier")]
+        modifier: Option<String>,
+    },
+}
+
+#[derive(Subcommand)]
+pub enum LspTraceCommands {
+    /// Report slow methods, errors and timeouts from a trace
+    Summarize {
+        /// Trace file written by --lsp-trace
+        file: String,
+
+        /// Number of slowest requests to show (default: 10)
+        #[arg(long = "top", default_value = "10")]
+        top: usize,
     },
 }
 
@@ -231,6 +357,28 @@ impl Cli {
             tracing_subscriber::fmt().with_env_filter("debug").init();
         }
 
+        // 並列の検索・解析はrayonの現在のスレッドプールで動く
+        let threads = match self.threads {
+            Some(threads) => threads,
+            None => match std::env::var("LSIF_THREADS") {
+                Ok(value) => value
+                    .trim()
+                    .parse()
+                    .map_err(|_| anyhow::anyhow!("invalid LSIF_THREADS: {}", value))?,
+                Err(_) => 0,
+            },
+        };
+        if threads > 0 {
+            // グローバルなプールは一度しか設定できないので、このコマンド用のプールで動かす
+            let pool = rayon::ThreadPoolBuilder::new()
+                .num_threads(threads)
+                .build()?;
+            return pool.install(|| self.execute());
+        }
+        self.execute()
+    }
+
+    fn execute(self) -> Result<()> {
         let db_path = self
             .database
             .unwrap_or_else(|| DEFAULT_INDEX_PATH.to_string());
@@ -241,11 +389,45 @@ impl Cli {
             std::env::set_var("LSIF_FALLBACK_ONLY", "1");
         }
 
+        // プロジェクトの.lsif/queriesでtree-sitterクエリを上書きできるようにする
+        lsp::query_loader::set_project_root(Path::new(&project_root));
+
+        // --lsp-traceが指定されていたら全LSPクライアントの通信を記録
+        if let Some(trace_path) = &self.lsp_trace {
+            std::env::set_var(lsp::lsp_trace::LSP_TRACE_ENV, trace_path);
+        }
+
         // Smart auto-indexing: only if DB doesn't exist or is stale
         // Skip auto-index for Index command (it handles indexing itself)
-        let is_index_command = matches!(self.command, Commands::Index { .. });
-        if !self.no_auto_index && !is_index_command && should_auto_index(&db_path, &project_root)? {
-            quick_index(&db_path, &project_root)?;
+        // and for LspStats/LspTrace/Queries (they don't use the index)
+        let is_index_command = matches!(
+            self.command,
+            Commands::Index { .. }
+                | Commands::LspStats { .. }
+                | Commands::LspTrace { .. }
+                | Commands::Queries { .. }
+                | Commands::Doctor { .. }
+        );
+        let max_staleness = match self.max_staleness {
+            Some(max_staleness) => max_staleness,
+            None => match std::env::var("LSIF_MAX_STALENESS") {
+                Ok(value) => parse_duration(&value)?,
+                Err(_) => Duration::ZERO,
+            },
+        };
+        let max_memory = match self.max_memory {
+            Some(max_memory) => Some(max_memory),
+            None => MemoryBudget::from_env()?,
+        };
+        let graph_backend = match self.graph_backend {
+            Some(graph_backend) => graph_backend,
+            None => GraphBackend::from_env()?,
+        };
+        if !self.no_auto_index
+            && !is_index_command
+            && should_auto_index(&db_path, &project_root, max_staleness)?
+        {
+            quick_index(&db_path, &project_root, max_memory)?;
         }
 
         let format = crate::output_format::OutputFormat::from_str(&self.format)?;
@@ -258,12 +440,17 @@ impl Cli {
                 location,
                 include_definitions,
                 group_by_file,
+                writes,
+                min_confidence,
             } => {
                 handle_references(
                     &db_path,
+                    &project_root,
                     &location,
                     include_definitions,
                     group_by_file,
+                    writes,
+                    min_confidence,
                     format,
                 )?;
             }
@@ -273,7 +460,14 @@ impl Cli {
                 outgoing,
                 max_depth,
             } => {
-                handle_call_hierarchy(&db_path, &symbol, incoming, outgoing, max_depth)?;
+                handle_call_hierarchy(
+                    &db_path,
+                    &symbol,
+                    incoming,
+                    outgoing,
+                    max_depth,
+                    graph_backend,
+                )?;
             }
             Commands::WorkspaceSymbols {
                 query,
@@ -285,6 +479,7 @@ impl Cli {
                 takes,
                 implements,
                 has_field,
+                modifier,
             } => {
                 handle_search(
                     &db_path,
@@ -298,6 +493,7 @@ impl Cli {
                     takes,
                     implements,
                     has_field,
+                    modifier,
                 )?;
             }
             Commands::Index {
@@ -305,6 +501,7 @@ impl Cli {
                 show_progress,
                 fallback_only,
                 workspace_symbol,
+                resume,
             } => {
                 handle_index(
                     &db_path,
@@ -313,6 +510,8 @@ impl Cli {
                     show_progress,
                     fallback_only,
                     workspace_symbol,
+                    resume,
+                    max_memory,
                 )?;
             }
             Commands::Crawl {
@@ -344,12 +543,43 @@ impl Cli {
             } => {
                 commands::stats::handle_stats(&db_path, detailed, by_file, by_type)?;
             }
+            Commands::LspStats { language } => {
+                commands::lsp_stats::handle_lsp_stats(&db_path, language, format)?;
+            }
+            Commands::LspTrace { action } => match action {
+                LspTraceCommands::Summarize { file, top } => {
+                    commands::lsp_trace::handle_lsp_trace_summarize(&file, top, format)?;
+                }
+            },
+            Commands::Queries { language } => {
+                commands::queries::handle_queries(language, format)?;
+            }
+            Commands::Doctor { fix, reindex } => {
+                commands::doctor::handle_doctor(&db_path, &project_root, fix, reindex, format)?;
+            }
+            Commands::Watch { debounce } => {
+                commands::watch::handle_watch(
+                    &db_path,
+                    &project_root,
+                    Duration::from_millis(debounce),
+                )?;
+            }
+            Commands::Deprecated { baseline, check } => {
+                commands::deprecated::handle_deprecated(
+                    &db_path,
+                    &project_root,
+                    baseline,
+                    check,
+                    format,
+                )?;
+            }
             Commands::Export {
                 output,
                 format,
                 include_refs,
+                modifier,
             } => {
-                handle_export(&db_path, &output, &format, include_refs)?;
+                handle_export(&db_path, &output, &format, include_refs, modifier)?;
             }
         }
 
@@ -360,23 +590,40 @@ impl Cli {
 // Helper functions
 
 /// Check if auto-indexing is needed
-fn should_auto_index(db_path: &str, project_root: &str) -> Result<bool> {
+///
+/// Compares git HEAD, the git index and sampled file mtimes/sizes with the manifest
+/// saved by the last index; a changed project younger than `max_staleness` is accepted.
+fn should_auto_index(db_path: &str, project_root: &str, max_staleness: Duration) -> Result<bool> {
     if !Path::new(db_path).exists() {
         return Ok(true);
     }
 
-    // Quick check using git HEAD
-    match GitDiffDetector::new(Path::new(project_root)) {
-        Ok(_detector) => {
-            // Simplified - always check for now
+    let manifest = IndexStorage::open_read_only(db_path)?.load_staleness_manifest()?;
+    let manifest = match manifest {
+        Some(manifest) => manifest,
+        // Indexed by an older version: check once to record a manifest
+        None => return Ok(true),
+    };
+
+    match manifest.stale_reason(Path::new(project_root)) {
+        Some(reason) if manifest.age() >= max_staleness => {
+            tracing::debug!("Index is stale: {}", reason);
             Ok(true)
         }
-        Err(_) => Ok(false),
+        Some(reason) => {
+            tracing::debug!(
+                "Index is stale ({}) but within --max-staleness ({:?} old)",
+                reason,
+                manifest.age()
+            );
+            Ok(false)
+        }
+        None => Ok(false),
     }
 }
 
 /// Quick incremental index
-fn quick_index(db_path: &str, project_root: &str) -> Result<()> {
+fn quick_index(db_path: &str, project_root: &str, max_memory: Option<MemoryBudget>) -> Result<()> {
     let start = Instant::now();
     println!("⚡ Quick indexing...");
 
@@ -388,8 +635,19 @@ fn quick_index(db_path: &str, project_root: &str) -> Result<()> {
     let use_lsp = std::env::var("LSIF_USE_LSP").unwrap_or_default() == "1" 
         && std::env::var("LSIF_FALLBACK_ONLY").is_err();
     indexer.set_fallback_only(!use_lsp);
+    // Ctrl-Cでの中断はインデックス処理の間だけ受け付ける
+    let interrupt = ctrl_c_scope();
+    indexer.set_cancellation(interrupt.token());
+    if let Some(max_memory) = max_memory {
+        indexer.set_memory_budget(max_memory);
+    }
 
     let result = indexer.index_differential()?;
+    drop(interrupt);
+    if result.interrupted {
+        print_warning("Interrupted: the files indexed so far were saved");
+        return Err(Interrupted.into());
+    }
 
     if result.files_added + result.files_modified + result.files_deleted > 0 {
         print_success(&format!(
@@ -411,9 +669,12 @@ fn handle_call_hierarchy(
     symbol: &str,
     incoming: bool,
     outgoing: bool,
-    _max_depth: usize,
+    max_depth: usize,
+    graph_backend: GraphBackend,
 ) -> Result<()> {
-    use commands::utils::{load_graph, print_error, print_info};
+    use commands::utils::{print_error, print_info};
+    use lsif_core::call_hierarchy::{format_hierarchy, CallHierarchyAnalyzer};
+    use lsif_core::GraphStore;
 
     let direction = if incoming {
         "incoming"
@@ -427,23 +688,38 @@ fn handle_call_hierarchy(
         "📞",
     );
 
-    let graph = load_graph(db_path)?;
+    let graph = IndexStorage::open(db_path)?
+        .load_graph_store(graph_backend)?
+        .unwrap_or_else(|| graph_backend.load(Default::default()));
 
     // Find the symbol
-    let target_symbol = graph.get_all_symbols().find(|s| s.name == symbol).cloned();
+    let target_symbol = graph
+        .symbols()
+        .find(|s| s.matches_name(symbol))
+        .map(|s| s.into_owned());
 
     if let Some(sym) = target_symbol {
         println!(
             "Symbol: {} at {}:{}:{}",
-            sym.name, sym.file_path, sym.range.start.line, sym.range.start.character
+            sym.display_name(),
+            sym.file_path,
+            sym.range.start.line,
+            sym.range.start.character
         );
 
+        let analyzer = CallHierarchyAnalyzer::new(&*graph);
         if incoming || !outgoing {
-            println!("\n⬇️  Incoming calls: (not yet implemented)");
+            println!("\n⬇️  Incoming calls:");
+            if let Some(hierarchy) = analyzer.get_incoming_calls(&sym.id, max_depth) {
+                println!("{}", format_hierarchy(&hierarchy, "", true));
+            }
         }
 
         if outgoing || !incoming {
-            println!("\n⬆️  Outgoing calls: (not yet implemented)");
+            println!("\n⬆️  Outgoing calls:");
+            if let Some(hierarchy) = analyzer.get_outgoing_calls(&sym.id, max_depth) {
+                println!("{}", format_hierarchy(&hierarchy, "", true));
+            }
         }
     } else {
         print_error(&format!("Symbol '{}' not found", symbol));
@@ -474,8 +750,15 @@ fn handle_unused(
     Ok(())
 }
 
-fn handle_export(db_path: &str, output: &str, format: &str, _include_refs: bool) -> Result<()> {
+fn handle_export(
+    db_path: &str,
+    output: &str,
+    format: &str,
+    _include_refs: bool,
+    modifier: Option<String>,
+) -> Result<()> {
     use commands::utils::{load_graph, print_error, print_info, print_success};
+    use lsif_core::SymbolModifiers;
     use std::fs::File;
     use std::io::Write;
 
@@ -484,11 +767,19 @@ fn handle_export(db_path: &str, output: &str, format: &str, _include_refs: bool)
         "📤",
     );
 
+    let modifiers = match modifier {
+        Some(list) => SymbolModifiers::parse_list(&list).map_err(anyhow::Error::msg)?,
+        None => SymbolModifiers::NONE,
+    };
     let graph = load_graph(db_path)?;
 
     match format {
         "json" => {
-            let symbols: Vec<_> = graph.get_all_symbols().cloned().collect();
+            let symbols: Vec<_> = graph
+                .get_all_symbols()
+                .filter(|s| s.modifiers.contains(modifiers))
+                .cloned()
+                .collect();
             let data = serde_json::json!({
                 "symbols": symbols,
                 "total": symbols.len(),
@@ -19,10 +19,10 @@ pub fn handle_definition(
         );
     }
 
-    let graph = load_graph(db_path)?;
+    let graph = open_graph(db_path)?;
 
-    if let Some(symbol) = find_symbol_at_location(&graph, &file, line, column) {
-        let output = formatter.format_symbol(symbol, None);
+    if let Some(symbol) = graph.find_symbol_at_location(&file, line, column) {
+        let output = formatter.format_symbol(&symbol, None);
         println!("{}", output);
     } else if format == OutputFormat::Human {
         print_error("No definition found at this location");
@@ -0,0 +1,85 @@
+use super::utils::*;
+use crate::deprecation_report::{
+    exclude_baseline, find_deprecated_usages, group_by_package, DeprecatedUsage,
+};
+use crate::output_format::OutputFormat;
+use anyhow::{bail, Context, Result};
+use std::path::Path;
+
+/// 非推奨APIの使用箇所をパッケージごとに表示
+///
+/// `check`なら（ベースラインにない）使用箇所が1つでもあればエラーにする。
+pub fn handle_deprecated(
+    db_path: &str,
+    project_root: &str,
+    baseline: Option<String>,
+    check: bool,
+    format: OutputFormat,
+) -> Result<()> {
+    let graph = load_graph(db_path)?;
+    let mut usages = find_deprecated_usages(&graph, Path::new(project_root))?;
+
+    if let Some(baseline) = baseline {
+        let content = std::fs::read_to_string(&baseline)
+            .with_context(|| format!("Failed to read baseline {}", baseline))?;
+        let known: Vec<DeprecatedUsage> = serde_json::from_str(&content)
+            .with_context(|| format!("Invalid baseline {}", baseline))?;
+        usages = exclude_baseline(usages, &known);
+    }
+
+    match format {
+        OutputFormat::Json => println!("{}", serde_json::to_string_pretty(&usages)?),
+        OutputFormat::Human => display_usages(&usages),
+        _ => {
+            for usage in &usages {
+                println!(
+                    "{}:{}:{}: {} is deprecated{}",
+                    usage.file,
+                    usage.line,
+                    usage.column,
+                    usage.symbol,
+                    replacement_hint(usage)
+                );
+            }
+        }
+    }
+
+    if check && !usages.is_empty() {
+        bail!("{} usage(s) of deprecated APIs", usages.len());
+    }
+    Ok(())
+}
+
+fn display_usages(usages: &[DeprecatedUsage]) {
+    if usages.is_empty() {
+        print_success("No usages of deprecated APIs");
+        return;
+    }
+
+    print_info(
+        &format!("{} usage(s) of deprecated APIs:", usages.len()),
+        "⚠️",
+    );
+    for (package, usages) in group_by_package(usages) {
+        println!("\n📦 {} ({})", package, usages.len());
+        for usage in usages {
+            println!(
+                "  📍 {}:{}:{} {} (defined at {}:{}){}",
+                usage.file,
+                usage.line,
+                usage.column,
+                usage.symbol,
+                usage.definition_file,
+                usage.definition_line,
+                replacement_hint(usage)
+            );
+        }
+    }
+}
+
+fn replacement_hint(usage: &DeprecatedUsage) -> String {
+    match &usage.replacement {
+        Some(replacement) => format!(" → use {}", replacement),
+        None => String::new(),
+    }
+}
@@ -0,0 +1,341 @@
+use super::utils::*;
+use crate::differential_indexer::{DifferentialIndexMetadata, DifferentialIndexer, METADATA_KEY};
+use crate::git_diff::GitDiffDetector;
+use crate::output_format::OutputFormat;
+use crate::reference_finder::referencing_files;
+use crate::storage::IndexStorage;
+use anyhow::Result;
+use lsif_core::graph_serde::SerializedCodeGraph;
+use lsif_core::integrity::{check_graph, check_ranges, check_serialized, line_count, repair_graph};
+use lsif_core::{CodeGraph, IntegrityIssue};
+use lsp::{LspServerRegistry, ServerInstallation};
+use serde_json::json;
+use std::collections::{BTreeSet, HashSet};
+use std::path::{Path, PathBuf};
+
+/// 保存されたインデックスを検査する
+///
+/// `fix`なら構造の問題を直し、消えたファイル・古くなったファイルのシンボルとハッシュを除く
+/// （次の`lsif index`で作り直される）。それらを参照していたファイルも次のインデックスで
+/// 処理し直して参照を張り直す。`reindex`ならその場でそれらを再インデックスする。
+pub fn handle_doctor(
+    db_path: &str,
+    project_root: &str,
+    fix: bool,
+    reindex: bool,
+    format: OutputFormat,
+) -> Result<()> {
+    let project_root = Path::new(project_root);
+    let fix = fix || reindex;
+    let mut report = Report::default();
+
+    let storage = IndexStorage::open(db_path)?;
+    let mut graph = match storage.load_serialized_graph() {
+        Ok(Some(serialized)) => {
+            report.graph_issues = check_serialized(&serialized);
+            let graph = serialized.into_graph();
+            for issue in check_graph(&graph) {
+                if !report.graph_issues.contains(&issue) {
+                    report.graph_issues.push(issue);
+                }
+            }
+            report
+                .graph_issues
+                .extend(check_ranges(&graph, |file_path| {
+                    std::fs::read_to_string(resolve(project_root, file_path))
+                        .ok()
+                        .map(|content| line_count(&content))
+                }));
+            Some(graph)
+        }
+        Ok(None) => None,
+        Err(e) => {
+            report.unreadable_graph = Some(e.to_string());
+            None
+        }
+    };
+
+    let mut metadata = storage.load_data::<DifferentialIndexMetadata>(METADATA_KEY)?;
+    if let Some(metadata) = &metadata {
+        let detector = GitDiffDetector::new(project_root)?;
+        let mut paths: Vec<&PathBuf> = metadata.file_content_hashes.keys().collect();
+        paths.sort();
+        for path in paths {
+            match detector.calculate_file_hash(path) {
+                Err(_) if !path.exists() => report.missing_files.push(path.clone()),
+                Ok(hash) if hash != metadata.file_content_hashes[path] => {
+                    report.stale_files.push(path.clone())
+                }
+                _ => {}
+            }
+        }
+    }
+
+    report.servers = check_servers(graph.as_ref());
+
+    if fix {
+        if let Some(graph) = graph.as_mut() {
+            report.repaired += repair_graph(graph);
+            // 保存形式の壊れたエッジは読み込んだ時点で消えている
+            report.repaired += report
+                .graph_issues
+                .iter()
+                .filter(|issue| matches!(issue, IntegrityIssue::DanglingEdge { .. }))
+                .count();
+        }
+
+        // 消えたファイル・古くなったファイルは次のインデックスで作り直す
+        let outdated: BTreeSet<PathBuf> = report
+            .missing_files
+            .iter()
+            .chain(&report.stale_files)
+            .cloned()
+            .chain(
+                report
+                    .graph_issues
+                    .iter()
+                    .filter_map(IntegrityIssue::file_path)
+                    .map(|file_path| resolve(project_root, file_path)),
+            )
+            .collect();
+        let (removed_symbols, referencing) = match graph.as_mut() {
+            Some(graph) => remove_files(graph, project_root, &outdated),
+            None => (0, BTreeSet::new()),
+        };
+        report.removed_symbols = removed_symbols;
+        // グラフとメタデータが食い違わないようにまとめて書き込む
+        let mut transaction = storage.transaction();
+        if let Some(metadata) = metadata.as_mut() {
+            if !outdated.is_empty() {
+                metadata
+                    .file_content_hashes
+                    .retain(|path, _| !outdated.contains(path));
+                // 除いたファイルを参照していたファイルは、変更されたものとして処理させて参照を張り直す
+                for path in &referencing {
+                    if let Some(hash) = metadata.file_content_hashes.get_mut(path) {
+                        hash.clear();
+                    }
+                }
+                // Gitの差分ではなくハッシュで変更を検出させる
+                metadata.last_commit = None;
+                metadata.hash_cache_path = None;
+                transaction.save_data(METADATA_KEY, metadata)?;
+                transaction.clear_staleness_manifest();
+            }
+        }
+        if let Some(graph) = &graph {
+            transaction.save_graph(&SerializedCodeGraph::from(graph))?;
+        }
+        transaction.commit()?;
+        report.outdated_files = outdated.len();
+    }
+    // sledは同じプロセスでも二重に開けない
+    drop(storage);
+
+    if reindex && report.outdated_files > 0 {
+        let mut indexer = DifferentialIndexer::new(db_path, project_root)?;
+        if std::env::var("LSIF_FALLBACK_ONLY").is_ok() {
+            indexer.set_fallback_only(true);
+        }
+        let result = indexer.index_differential()?;
+        report.reindexed_files = Some(result.files_added + result.files_modified);
+    }
+
+    match format {
+        OutputFormat::Json => println!("{}", serde_json::to_string_pretty(&report.to_json())?),
+        _ => display_report(&report, fix),
+    }
+    Ok(())
+}
+
+#[derive(Default)]
+struct Report {
+    unreadable_graph: Option<String>,
+    graph_issues: Vec<IntegrityIssue>,
+    missing_files: Vec<PathBuf>,
+    stale_files: Vec<PathBuf>,
+    servers: Vec<ServerInstallation>,
+    repaired: usize,
+    removed_symbols: usize,
+    outdated_files: usize,
+    reindexed_files: Option<usize>,
+}
+
+impl Report {
+    fn is_healthy(&self) -> bool {
+        self.unreadable_graph.is_none()
+            && self.graph_issues.is_empty()
+            && self.missing_files.is_empty()
+            && self.stale_files.is_empty()
+    }
+
+    fn to_json(&self) -> serde_json::Value {
+        json!({
+            "healthy": self.is_healthy(),
+            "unreadable_graph": self.unreadable_graph,
+            "graph_issues": self.graph_issues,
+            "missing_files": self.missing_files,
+            "stale_files": self.stale_files,
+            "lsp_servers": self.servers,
+            "repaired": self.repaired,
+            "removed_symbols": self.removed_symbols,
+            "reindexed_files": self.reindexed_files,
+        })
+    }
+}
+
+/// 相対パスはプロジェクトルートから
+fn resolve(project_root: &Path, file_path: &str) -> PathBuf {
+    let path = Path::new(file_path);
+    if path.is_absolute() || path.exists() {
+        path.to_path_buf()
+    } else {
+        project_root.join(path)
+    }
+}
+
+/// ファイルのシンボルを全て除き、除いた数と、除いたシンボルを参照していた他のファイルを返す
+fn remove_files(
+    graph: &mut CodeGraph,
+    project_root: &Path,
+    files: &BTreeSet<PathBuf>,
+) -> (usize, BTreeSet<PathBuf>) {
+    let is_removed = |file_path: &str| {
+        files.contains(Path::new(file_path)) || files.contains(&resolve(project_root, file_path))
+    };
+    let file_paths: BTreeSet<String> = graph
+        .get_all_symbols()
+        .map(|symbol| symbol.file_path.clone())
+        .filter(|file_path| is_removed(file_path))
+        .collect();
+
+    // 除くと入ってくる参照のエッジも消えるので、先に参照元を集めておく
+    let referencing: BTreeSet<PathBuf> = file_paths
+        .iter()
+        .flat_map(|file_path| referencing_files(graph, file_path))
+        .filter(|path| !is_removed(&path.to_string_lossy()))
+        .collect();
+
+    let removed = file_paths
+        .iter()
+        .map(|file_path| {
+            graph
+                .replace_symbols_in_rows(file_path, None, 0, Vec::new())
+                .removed
+        })
+        .sum();
+    (removed, referencing)
+}
+
+/// インデックスにある言語のLSPサーバー（空のインデックスなら設定済みの全サーバー）
+fn check_servers(graph: Option<&CodeGraph>) -> Vec<ServerInstallation> {
+    let registry = LspServerRegistry::default();
+    let languages: BTreeSet<String> = graph
+        .map(|graph| {
+            graph
+                .get_all_symbols()
+                .filter_map(|symbol| {
+                    LspServerRegistry::detect_language(Path::new(&symbol.file_path))
+                })
+                .collect()
+        })
+        .unwrap_or_default();
+
+    let mut configs = registry.configs();
+    if !languages.is_empty() {
+        configs.retain(|config| languages.contains(&config.language_id));
+    }
+    // typescriptとjavascriptは同じサーバー
+    let mut seen = HashSet::new();
+    configs.retain(|config| seen.insert(config.command.clone()));
+    configs.iter().map(|config| config.installation()).collect()
+}
+
+fn display_report(report: &Report, fix: bool) {
+    print_info("Index", "🩺");
+    if let Some(error) = &report.unreadable_graph {
+        print_error(&format!(
+            "The stored graph cannot be read ({}); run `lsif index --force`",
+            error
+        ));
+    }
+    for issue in &report.graph_issues {
+        print_warning(&issue.to_string());
+    }
+    for path in &report.missing_files {
+        print_warning(&format!(
+            "{} is indexed but missing on disk",
+            path.display()
+        ));
+    }
+    for path in &report.stale_files {
+        print_warning(&format!("{} changed since it was indexed", path.display()));
+    }
+    if report.is_healthy() {
+        print_success("No problems found");
+    } else if fix {
+        print_success(&format!(
+            "Repaired {} structural problem(s), removed {} symbol(s) from {} outdated file(s)",
+            report.repaired, report.removed_symbols, report.outdated_files
+        ));
+        match report.reindexed_files {
+            Some(count) => print_success(&format!("Reindexed {} file(s)", count)),
+            None if report.outdated_files > 0 => {
+                print_info("Run `lsif index` to index the outdated files again", "💡")
+            }
+            None => {}
+        }
+    } else {
+        print_info(
+            "Run `lsif doctor --fix` to repair, or `lsif doctor --reindex` to also reindex the affected files",
+            "💡",
+        );
+    }
+
+    println!();
+    print_info("LSP servers", "🔌");
+    for server in &report.servers {
+        if server.installed {
+            print_success(&format!(
+                "{}: {} ({})",
+                server.language_id,
+                server.command,
+                server.version.as_deref().unwrap_or("unknown version")
+            ));
+        } else {
+            print_warning(&format!(
+                "{}: {} is not installed (the tree-sitter fallback is used)",
+                server.language_id, server.command
+            ));
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use lsif_core::test_fixtures::SymbolBuilder;
+    use lsif_core::{EdgeKind, Symbol};
+
+    fn symbol(file_path: &str, name: &str) -> Symbol {
+        SymbolBuilder::new(&format!("{}#1:{}", file_path, name), name, file_path).build()
+    }
+
+    #[test]
+    fn test_remove_files_returns_referencing_files() {
+        let mut graph = CodeGraph::new();
+        let target = graph.add_symbol(symbol("/p/lib.rs", "helper"));
+        let caller = graph.add_symbol(symbol("/p/main.rs", "main"));
+        let inner = graph.add_symbol(symbol("/p/lib.rs", "inner"));
+        graph.add_edge(caller, target, EdgeKind::Reference);
+        graph.add_edge(inner, target, EdgeKind::Reference);
+
+        let outdated = BTreeSet::from([PathBuf::from("/p/lib.rs")]);
+        let (removed, referencing) = remove_files(&mut graph, Path::new("/p"), &outdated);
+
+        assert_eq!(removed, 2);
+        // 除いたファイル自身は含めない
+        assert_eq!(referencing, BTreeSet::from([PathBuf::from("/p/main.rs")]));
+        assert!(graph.find_symbol("/p/main.rs#1:main").is_some());
+    }
+}
@@ -1,7 +1,11 @@
 use super::utils::*;
-use crate::differential_indexer::DifferentialIndexer;
-use anyhow::Result;
-use std::path::Path;
+use crate::cancellation::{ctrl_c_scope, Interrupted};
+use crate::differential_indexer::{DifferentialIndexResult, DifferentialIndexer};
+use crate::index_checkpoint::{FileFailure, MAX_FILE_ATTEMPTS};
+use crate::memory_budget::MemoryBudget;
+use anyhow::{Context, Result};
+use lsif_core::graph_serde::SerializedCodeGraph;
+use std::path::{Path, PathBuf};
 use std::time::Instant;
 
 pub fn handle_index(
@@ -11,6 +15,8 @@ pub fn handle_index(
     _show_progress: bool,
     fallback_only: bool,
     workspace_symbol: bool,
+    resume: bool,
+    max_memory: Option<MemoryBudget>,
 ) -> Result<()> {
     let start = Instant::now();
 
@@ -21,14 +27,14 @@ pub fn handle_index(
         use crate::storage::IndexStorage;
         use crate::workspace_symbol_strategy::WorkspaceSymbolStrategy;
 
-        use std::path::PathBuf;
-
         let strategy = WorkspaceSymbolStrategy::new(PathBuf::from(project_root));
         let graph = strategy.index()?;
 
         // ストレージに保存
         let storage = IndexStorage::open(db_path)?;
-        storage.save_data("graph", &graph)?;
+        let mut transaction = storage.transaction();
+        transaction.save_graph(&SerializedCodeGraph::from(&graph))?;
+        transaction.commit()?;
 
         print_success(&format!(
             "Indexed {} symbols in {:.2}s using workspace/symbol",
@@ -40,31 +46,81 @@ pub fn handle_index(
     }
 
     // 通常のインデックスモード
-    if force {
+    if resume {
+        print_info("Resuming interrupted indexing...", "⏯️");
+    } else if force {
         print_info("Force reindexing project...", "🔄");
-        if Path::new(db_path).exists() {
-            std::fs::remove_dir_all(db_path).ok(); // DBディレクトリを削除
-        }
     } else {
         print_info("Indexing project...", "📇");
     }
 
-    let mut indexer = DifferentialIndexer::new(db_path, Path::new(project_root))?;
-
-    // フォールバックオンリーモードを設定
-    // --no-lspフラグまたは--fallback-onlyフラグが設定されている場合
-    let use_fallback = fallback_only || std::env::var("LSIF_FALLBACK_ONLY").is_ok();
-    if use_fallback {
-        indexer.set_fallback_only(true);
-    }
-
-    // full_reindexは内部でworkspace/symbolを試みる
-    let result = if force || !Path::new(db_path).exists() {
-        indexer.full_reindex()? // 内部でworkspace/symbolを優先的に使用
+    // 既存のインデックスを作り直す時は別のディレクトリに作り、成功してから入れ替える
+    // （中断や失敗で前回のインデックスを失わないように）
+    let staging = staging_path(db_path);
+    let staging = if force && !resume && Path::new(db_path).exists() {
+        if staging.exists() {
+            std::fs::remove_dir_all(&staging)?; // 前回の中断や失敗で残ったもの
+        }
+        Some(staging)
+    } else if resume && staging.exists() {
+        // 中断された作り直しをチェックポイントから続ける
+        Some(staging)
     } else {
-        indexer.index_differential()? // 差分時はdocument symbolを使用
+        None
+    };
+    let build_path = staging.as_deref().unwrap_or_else(|| Path::new(db_path));
+
+    let result = match build_index(
+        build_path,
+        project_root,
+        force,
+        resume,
+        fallback_only,
+        max_memory,
+    ) {
+        Ok(result) => result,
+        Err(e) => {
+            // 続きから再開する作り直しは、それまでの成果を残しておく
+            if let Some(staging) = staging.as_ref().filter(|_| !resume) {
+                std::fs::remove_dir_all(staging).ok();
+            }
+            return Err(e);
+        }
     };
 
+    print_failed_files(&result.failed_files);
+
+    if result.interrupted {
+        if staging.is_some() {
+            // 作り直しの途中経過（チェックポイント）は残し、--resumeで続けてから入れ替える
+            print_warning(&format!(
+                "Interrupted: kept the previous index and the {} files rebuilt so far; run `lsif index --resume` to continue",
+                result.files_added + result.resumed_files
+            ));
+            return Err(Interrupted.into());
+        }
+        print_warning(&format!(
+            "Interrupted: saved {} symbols from the files indexed so far (+{} ~{} -{} files); run `lsif index --resume` to continue",
+            result.symbols_added + result.symbols_updated,
+            result.files_added,
+            result.files_modified,
+            result.files_deleted
+        ));
+        return Err(Interrupted.into());
+    }
+    if let Some(staging) = &staging {
+        replace_index(staging, Path::new(db_path))?;
+    }
+    if result.resumed_files > 0 {
+        print_info(
+            &format!(
+                "Skipped {} files already indexed before the interruption",
+                result.resumed_files
+            ),
+            "⏯️",
+        );
+    }
+
     // 結果の表示を改善
     if result.full_reindex && result.files_added == 0 {
         // workspace/symbolを使った場合
@@ -87,3 +143,89 @@ pub fn handle_index(
 
     Ok(())
 }
+
+/// インデックスを作る（`db_path`がなければ全体を、あれば差分を）
+fn build_index(
+    db_path: &Path,
+    project_root: &str,
+    force: bool,
+    resume: bool,
+    fallback_only: bool,
+    max_memory: Option<MemoryBudget>,
+) -> Result<DifferentialIndexResult> {
+    let mut indexer = DifferentialIndexer::new(db_path, Path::new(project_root))?;
+
+    // フォールバックオンリーモードを設定
+    // --no-lspフラグまたは--fallback-onlyフラグが設定されている場合
+    let use_fallback = fallback_only || std::env::var("LSIF_FALLBACK_ONLY").is_ok();
+    if use_fallback {
+        indexer.set_fallback_only(true);
+    }
+    // Ctrl-Cで処理済みのファイルまでを保存して止める（この関数を抜けると通常の動作に戻る）
+    let interrupt = ctrl_c_scope();
+    indexer.set_cancellation(interrupt.token());
+    // 全ファイルを処理する時は、結果をディスクに書き出してメモリを上限内に収める
+    if let Some(max_memory) = max_memory {
+        indexer.set_memory_budget(max_memory);
+    }
+
+    // full_reindexは内部でworkspace/symbolを試みる
+    if resume {
+        indexer.resume()
+    } else if force || !db_path.exists() {
+        indexer.full_reindex() // 内部でworkspace/symbolを優先的に使用
+    } else {
+        indexer.index_differential() // 差分時はdocument symbolを使用
+    }
+}
+
+/// 作り直し中のインデックスの置き場所（同じディレクトリに置いてrenameで入れ替える）
+fn staging_path(db_path: &str) -> PathBuf {
+    PathBuf::from(format!("{}.reindex", db_path.trim_end_matches('/')))
+}
+
+/// 作り直したインデックスを既存のものと入れ替える
+fn replace_index(staging: &Path, db_path: &Path) -> Result<()> {
+    if !db_path.exists() {
+        return std::fs::rename(staging, db_path)
+            .with_context(|| format!("Failed to move {} into place", staging.display()));
+    }
+    let backup = staging.with_extension("old");
+    if backup.exists() {
+        std::fs::remove_dir_all(&backup)?;
+    }
+    std::fs::rename(db_path, &backup)
+        .with_context(|| format!("Failed to move aside {}", db_path.display()))?;
+    if let Err(e) = std::fs::rename(staging, db_path) {
+        // 入れ替えられなければ元のインデックスを戻す
+        std::fs::rename(&backup, db_path).ok();
+        return Err(e).with_context(|| format!("Failed to replace {}", db_path.display()));
+    }
+    std::fs::remove_dir_all(&backup).ok();
+    Ok(())
+}
+
+/// シンボルを抽出できなかったファイル
+fn print_failed_files(failed_files: &[(PathBuf, FileFailure)]) {
+    if failed_files.is_empty() {
+        return;
+    }
+    print_warning(&format!(
+        "{} file(s) could not be indexed:",
+        failed_files.len()
+    ));
+    for (path, failure) in failed_files {
+        let retry = if failure.attempts >= MAX_FILE_ATTEMPTS {
+            "gave up"
+        } else {
+            "will retry on the next index"
+        };
+        println!(
+            "  {} ({} attempt(s), {}): {}",
+            path.display(),
+            failure.attempts,
+            retry,
+            failure.last_error
+        );
+    }
+}
@@ -0,0 +1,116 @@
+use super::utils::*;
+use crate::output_format::OutputFormat;
+use crate::storage::IndexStorage;
+use anyhow::Result;
+use lsp::lsp_timing_stats::{LspTimingStats, TimingPercentiles};
+use serde_json::json;
+
+/// 学習済みのLSP応答時間統計を表示
+pub fn handle_lsp_stats(
+    db_path: &str,
+    language: Option<String>,
+    format: OutputFormat,
+) -> Result<()> {
+    let storage = IndexStorage::open(db_path)?;
+    let mut stats: Vec<LspTimingStats> = storage
+        .load_lsp_timing_stats()?
+        .into_values()
+        .filter(|s| language.as_deref().map_or(true, |lang| s.language == lang))
+        .collect();
+    stats.sort_by_key(|s| s.key());
+
+    if format == OutputFormat::Json {
+        let entries: Vec<_> = stats.iter().map(stats_to_json).collect();
+        println!("{}", serde_json::to_string_pretty(&entries)?);
+        return Ok(());
+    }
+
+    if stats.is_empty() {
+        print_info(
+            "No learned LSP timing statistics yet (run `lsif index` first)",
+            "ℹ️",
+        );
+        return Ok(());
+    }
+
+    print_info("Learned LSP timing statistics:", "⏱️");
+    for s in &stats {
+        display_server_stats(s);
+    }
+
+    Ok(())
+}
+
+fn display_server_stats(stats: &LspTimingStats) {
+    println!(
+        "\n🔌 {} ({} {})",
+        stats.language,
+        stats.server_name.as_deref().unwrap_or("unknown server"),
+        stats.server_version.as_deref().unwrap_or("unknown version")
+    );
+    println!(
+        "  Updated: {}",
+        stats.updated_at.format("%Y-%m-%d %H:%M:%S UTC")
+    );
+
+    if let Some(p) = stats.init_percentiles() {
+        println!("  {:<16} {}", "initialize", format_percentiles(&p));
+    }
+
+    let mut operations: Vec<_> = stats.operation_times_ms.keys().copied().collect();
+    operations.sort_by_key(|op| format!("{:?}", op));
+    for op in operations {
+        if let Some(p) = stats.operation_percentiles(op) {
+            println!("  {:<16} {}", format!("{:?}", op), format_percentiles(&p));
+        }
+    }
+
+    if let Some(ms_per_byte) = stats.ms_per_byte {
+        println!("  ms/byte: {:.5}", ms_per_byte);
+    }
+    if let Some(ms_per_line) = stats.ms_per_line {
+        println!("  ms/line: {:.3}", ms_per_line);
+    }
+}
+
+fn format_percentiles(p: &TimingPercentiles) -> String {
+    format!(
+        "p50={}ms p90={}ms p99={}ms (n={})",
+        p.p50.as_millis(),
+        p.p90.as_millis(),
+        p.p99.as_millis(),
+        p.count
+    )
+}
+
+fn percentiles_to_json(p: &TimingPercentiles) -> serde_json::Value {
+    json!({
+        "count": p.count,
+        "p50_ms": p.p50.as_millis() as u64,
+        "p90_ms": p.p90.as_millis() as u64,
+        "p99_ms": p.p99.as_millis() as u64,
+    })
+}
+
+fn stats_to_json(stats: &LspTimingStats) -> serde_json::Value {
+    let operations: serde_json::Map<String, serde_json::Value> = stats
+        .operation_times_ms
+        .keys()
+        .filter_map(|op| {
+            stats
+                .operation_percentiles(*op)
+                .map(|p| (format!("{:?}", op), percentiles_to_json(&p)))
+        })
+        .collect();
+
+    json!({
+        "language": stats.language,
+        "server_name": stats.server_name,
+        "server_version": stats.server_version,
+        "initialize": stats.init_percentiles().as_ref().map(percentiles_to_json),
+        "operations": operations,
+        "ms_per_byte": stats.ms_per_byte,
+        "ms_per_line": stats.ms_per_line,
+        "updated_at": stats.updated_at.to_rfc3339(),
+    })
+}
@@ -0,0 +1,143 @@
+use super::utils::*;
+use crate::output_format::OutputFormat;
+use anyhow::Result;
+use lsp::lsp_metrics::LspMetricsCollector;
+use lsp::lsp_trace::{read_trace, summarize_trace, TraceIncident, TraceSummary};
+use serde_json::json;
+
+/// `--lsp-trace`で記録したトレースを集計して表示
+pub fn handle_lsp_trace_summarize(path: &str, top: usize, format: OutputFormat) -> Result<()> {
+    let events = read_trace(path)?;
+    let collector = LspMetricsCollector::new();
+    let summary = summarize_trace(&events, &collector, top);
+
+    if format == OutputFormat::Json {
+        println!(
+            "{}",
+            serde_json::to_string_pretty(&summary_to_json(&summary))?
+        );
+        return Ok(());
+    }
+
+    print_info(&format!("LSP trace summary: {}", path), "🔍");
+    println!(
+        "  Events: {} ({} requests, {} notifications)",
+        summary.total_events, summary.requests, summary.notifications
+    );
+
+    let mut servers: Vec<_> = summary.servers.iter().collect();
+    servers.sort_by_key(|&(_, count)| std::cmp::Reverse(*count));
+    for (server, count) in servers {
+        println!("  🔌 {}: {} requests", server, count);
+    }
+
+    if !summary.methods.is_empty() {
+        println!("\n🐢 Methods by average latency:");
+        for m in &summary.methods {
+            let percentiles = m
+                .latency
+                .map(|p| {
+                    format!(
+                        " p50={}ms p90={}ms p99={}ms",
+                        p.p50.as_millis(),
+                        p.p90.as_millis(),
+                        p.p99.as_millis()
+                    )
+                })
+                .unwrap_or_default();
+            println!(
+                "  {:<36} n={:<5} avg={}ms max={}ms{} errors={} timeouts={}",
+                m.method,
+                m.metrics.count,
+                m.metrics.avg_duration.as_millis(),
+                m.metrics.max_duration.as_millis(),
+                percentiles,
+                m.errors,
+                m.timeouts
+            );
+        }
+    }
+
+    if !summary.slowest.is_empty() {
+        println!("\n⏱️  Slowest requests:");
+        for incident in &summary.slowest {
+            println!("  {}", format_incident(incident));
+        }
+    }
+
+    if !summary.errors.is_empty() {
+        println!("\n❌ Errors ({}):", summary.errors.len());
+        for incident in summary.errors.iter().take(top) {
+            println!("  {}", format_incident(incident));
+        }
+    }
+
+    if !summary.timeouts.is_empty() {
+        println!("\n⌛ Timeouts ({}):", summary.timeouts.len());
+        for incident in summary.timeouts.iter().take(top) {
+            println!("  {}", format_incident(incident));
+        }
+    }
+
+    Ok(())
+}
+
+fn format_incident(incident: &TraceIncident) -> String {
+    let mut line = format!(
+        "{} {} {} #{} {}ms",
+        incident.timestamp.format("%H:%M:%S%.3f"),
+        incident.server,
+        incident.method,
+        incident
+            .id
+            .map(|id| id.to_string())
+            .unwrap_or_else(|| "-".to_string()),
+        incident.latency_ms
+    );
+    if let Some(error) = &incident.error {
+        line.push_str(&format!(" {}", error));
+    }
+    line
+}
+
+fn incident_to_json(incident: &TraceIncident) -> serde_json::Value {
+    json!({
+        "timestamp": incident.timestamp.to_rfc3339(),
+        "server": incident.server,
+        "method": incident.method,
+        "id": incident.id,
+        "latency_ms": incident.latency_ms,
+        "error": incident.error,
+    })
+}
+
+fn summary_to_json(summary: &TraceSummary) -> serde_json::Value {
+    let methods: Vec<_> = summary
+        .methods
+        .iter()
+        .map(|m| {
+            json!({
+                "method": m.method,
+                "count": m.metrics.count,
+                "avg_ms": m.metrics.avg_duration.as_millis() as u64,
+                "max_ms": m.metrics.max_duration.as_millis() as u64,
+                "p50_ms": m.latency.map(|p| p.p50.as_millis() as u64),
+                "p90_ms": m.latency.map(|p| p.p90.as_millis() as u64),
+                "p99_ms": m.latency.map(|p| p.p99.as_millis() as u64),
+                "errors": m.errors,
+                "timeouts": m.timeouts,
+            })
+        })
+        .collect();
+
+    json!({
+        "total_events": summary.total_events,
+        "requests": summary.requests,
+        "notifications": summary.notifications,
+        "servers": summary.servers,
+        "methods": methods,
+        "slowest": summary.slowest.iter().map(incident_to_json).collect::<Vec<_>>(),
+        "errors": summary.errors.iter().map(incident_to_json).collect::<Vec<_>>(),
+        "timeouts": summary.timeouts.iter().map(incident_to_json).collect::<Vec<_>>(),
+    })
+}
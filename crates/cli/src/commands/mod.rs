@@ -1,7 +1,13 @@
 pub mod crawl;
 pub mod definition;
+pub mod deprecated;
+pub mod doctor;
 pub mod index;
+pub mod lsp_stats;
+pub mod lsp_trace;
+pub mod queries;
 pub mod references;
 pub mod search;
 pub mod stats;
 pub mod utils;
+pub mod watch;
@@ -0,0 +1,56 @@
+use super::utils::*;
+use crate::output_format::OutputFormat;
+use anyhow::{bail, Result};
+use lsp::query_loader::{query_dirs, validate_all};
+use serde_json::json;
+
+/// 使用中のtree-sitterクエリファイルを表示して検証
+pub fn handle_queries(language: Option<String>, format: OutputFormat) -> Result<()> {
+    let results: Vec<_> = validate_all()
+        .into_iter()
+        .filter(|(source, _)| {
+            language
+                .as_deref()
+                .map_or(true, |lang| source.language == lang)
+        })
+        .collect();
+    let invalid = results.iter().filter(|(_, result)| result.is_err()).count();
+
+    if format == OutputFormat::Json {
+        let entries: Vec<_> = results
+            .iter()
+            .map(|(source, result)| {
+                json!({
+                    "language": source.language,
+                    "query": source.kind.file_name(),
+                    "origin": source.origin.to_string(),
+                    "patterns": result.as_ref().ok(),
+                    "error": result.as_ref().err().map(|e| e.to_string()),
+                })
+            })
+            .collect();
+        println!("{}", serde_json::to_string_pretty(&entries)?);
+    } else {
+        print_info("tree-sitter queries:", "🌳");
+        for (source, result) in &results {
+            let name = format!("{}/{}", source.language, source.kind.file_name());
+            match result {
+                Ok(patterns) => println!(
+                    "  ✅ {:<28} {} ({} patterns)",
+                    name, source.origin, patterns
+                ),
+                Err(e) => println!("  ❌ {:<28} {}", name, e),
+            }
+        }
+
+        println!("\nOverride directories (first match wins):");
+        for dir in query_dirs() {
+            println!("  {}", dir.display());
+        }
+    }
+
+    if invalid > 0 {
+        bail!("{} invalid query file(s)", invalid);
+    }
+    Ok(())
+}
@@ -1,12 +1,18 @@
 use super::utils::*;
 use crate::output_format::{OutputFormat, OutputFormatter};
+use crate::reference_finder::find_references_to_symbol;
 use anyhow::Result;
+use lsif_core::ReferenceAccess;
+use std::path::Path;
 
 pub fn handle_references(
     db_path: &str,
+    project_root: &str,
     location: &str,
     _include_defs: bool,
     _group: bool,
+    writes_only: bool,
+    min_confidence: f32,
     format: OutputFormat,
 ) -> Result<()> {
     let (file, line, column) = parse_location(location)?;
@@ -20,34 +26,77 @@ pub fn handle_references(
         );
     }
 
-    let graph = load_graph(db_path)?;
+    let graph = open_graph(db_path)?;
 
-    if let Some(symbol) = find_symbol_at_location(&graph, &file, line, column) {
+    if let Some(symbol) = graph.find_symbol_at_location(&file, line, column) {
         // シンボルの参照を検索
-        let references = graph.find_references(&symbol.id);
-        
+        // 参照元のシンボルではなく、エッジに記録された出現位置を示す
+        let references: Vec<_> = graph
+            .find_reference_edges(&symbol.id, min_confidence)
+            .into_iter()
+            .map(|(mut reference, edge)| {
+                if let Some(range) = edge.range {
+                    reference.range = range;
+                }
+                (reference, edge.access())
+            })
+            .collect();
+        let references: Result<Vec<_>> = if references.is_empty() {
+            // LSPの参照がないインデックスはtree-sitterの名前解決で補う
+            find_references_to_symbol(Path::new(project_root), &symbol).map(|refs| {
+                refs.into_iter()
+                    .filter(|r| !r.is_definition && r.source.default_confidence() >= min_confidence)
+                    .map(|r| (r.symbol, r.access))
+                    .collect()
+            })
+        } else {
+            Ok(references)
+        };
+        // 書き換える箇所だけ（使われ方の分からない参照は書き換えうるものとして残す）
+        let references = references.map(|refs| {
+            refs.into_iter()
+                .filter(|(_, access)| {
+                    !writes_only || access.map_or(true, ReferenceAccess::may_write)
+                })
+                .collect::<Vec<_>>()
+        });
+        let access_name = |access: Option<ReferenceAccess>| match access {
+            Some(access) => Some(access.as_str()),
+            None if writes_only => Some("unknown"),
+            None => None,
+        };
+        let kind = if writes_only { "writes" } else { "references" };
+
         match references {
             Ok(refs) if !refs.is_empty() => {
                 if format == OutputFormat::Human {
-                    println!("Found {} references for '{}':", refs.len(), symbol.name);
-                    for reference in &refs {
+                    println!("Found {} {} for '{}':", refs.len(), kind, symbol.name);
+                    for (reference, access) in &refs {
                         // 1ベースの行番号で表示
-                        println!("  📍 {}:{}:{}", 
-                            reference.file_path, 
-                            reference.range.start.line + 1, 
-                            reference.range.start.character + 1
+                        let access = access_name(*access)
+                            .map(|access| format!(" ({})", access))
+                            .unwrap_or_default();
+                        println!(
+                            "  📍 {}:{}:{}{}",
+                            reference.file_path,
+                            reference.range.start.line + 1,
+                            reference.range.start.character + 1,
+                            access
                         );
                     }
                 } else {
                     let formatter = OutputFormatter::new(format);
-                    for reference in refs {
-                        println!("{}", formatter.format_symbol(&reference, None));
+                    for (reference, access) in refs {
+                        println!(
+                            "{}",
+                            formatter.format_symbol(&reference, access_name(access))
+                        );
                     }
                 }
             }
             Ok(_) => {
                 if format == OutputFormat::Human {
-                    print_warning(&format!("No references found for '{}'", symbol.name));
+                    print_warning(&format!("No {} found for '{}'", kind, symbol.name));
                 }
             }
             Err(e) => {
@@ -2,7 +2,7 @@ use super::utils::*;
 use crate::output_format::{OutputFormat, OutputFormatter};
 use crate::type_search::{AdvancedSearch, TypeFilter};
 use anyhow::Result;
-use lsif_core::SymbolKind;
+use lsif_core::{SymbolKind, SymbolModifiers};
 
 pub fn handle_search(
     db_path: &str,
@@ -16,7 +16,12 @@ pub fn handle_search(
     takes: Option<String>,
     implements: Option<String>,
     has_field: Option<String>,
+    modifier: Option<String>,
 ) -> Result<()> {
+    let modifiers = match modifier {
+        Some(list) => SymbolModifiers::parse_list(&list).map_err(anyhow::Error::msg)?,
+        None => SymbolModifiers::NONE,
+    };
     let formatter = OutputFormatter::new(format);
 
     if format == OutputFormat::Human {
@@ -45,12 +50,16 @@ pub fn handle_search(
         // Use advanced search with type filters
         let search = AdvancedSearch::new(&graph);
         let name_pattern = if query.is_empty() { None } else { Some(query) };
-        search.search(name_pattern, &type_filters, fuzzy, max_results)
+        let mut results = search.search(name_pattern, &type_filters, fuzzy, max_results);
+        results.retain(|s| s.modifiers.contains(modifiers));
+        results
     } else {
         // Use simple search
         let mut results = Vec::new();
         for symbol in graph.get_all_symbols() {
-            if should_include_symbol(symbol, &symbol_type, &path_pattern, query, fuzzy) {
+            if symbol.modifiers.contains(modifiers)
+                && should_include_symbol(symbol, &symbol_type, &path_pattern, query, fuzzy)
+            {
                 results.push(symbol.clone());
                 if results.len() >= max_results {
                     break;
@@ -90,11 +99,14 @@ fn should_include_symbol(
         }
     }
 
-    // Name matching
+    // Name matching (qualified names such as `crate::store::Db::close` are accepted too)
     if fuzzy {
-        symbol.name.to_lowercase().contains(&query.to_lowercase())
+        symbol
+            .display_name()
+            .to_lowercase()
+            .contains(&query.to_lowercase())
     } else {
-        symbol.name == query
+        symbol.matches_name(query)
     }
 }
 
@@ -118,7 +130,7 @@ fn display_search_results(results: &[lsif_core::Symbol], max_results: usize) {
             let kind = format!("{:?}", symbol.kind).to_lowercase();
             println!(
                 "  🔹 {} ({}) - {}",
-                symbol.name,
+                symbol.display_name(),
                 kind,
                 format_symbol_location(symbol)
             );
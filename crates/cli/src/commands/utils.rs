@@ -1,6 +1,7 @@
 use crate::storage::IndexStorage;
 use anyhow::Result;
-use lsif_core::{CodeGraph, Symbol};
+use lsif_core::compact_graph::CompactGraph;
+use lsif_core::{CodeGraph, Edge, EdgeKind, Range, Symbol};
 
 /// Parse location format: file.rs:10:5 or file.rs
 pub fn parse_location(location: &str) -> Result<(String, u32, u32)> {
@@ -12,9 +13,75 @@ pub fn parse_location(location: &str) -> Result<(String, u32, u32)> {
 }
 
 /// Load graph from database
+///
+/// Deserializes the whole graph. Read-only lookups should use [`open_graph`] instead.
 pub fn load_graph(db_path: &str) -> Result<CodeGraph> {
     let storage = IndexStorage::open(db_path)?;
-    Ok(storage.load_data::<CodeGraph>("graph")?.unwrap_or_default())
+    Ok(storage.load_graph()?.unwrap_or_default())
+}
+
+/// A graph opened for read-only lookups
+pub enum IndexGraph {
+    /// The memory-mapped `graph.csr` written alongside the graph (nothing is deserialized)
+    Mapped(CompactGraph),
+    /// The deserialized graph, used when `graph.csr` is missing or stale
+    Loaded(CodeGraph),
+}
+
+/// Open the graph for lookups, memory-mapping `graph.csr` when it matches the saved graph
+pub fn open_graph(db_path: &str) -> Result<IndexGraph> {
+    let storage = IndexStorage::open(db_path)?;
+    if let Some(graph) = storage.load_compact_graph()? {
+        return Ok(IndexGraph::Mapped(graph));
+    }
+    Ok(IndexGraph::Loaded(
+        storage.load_graph()?.unwrap_or_default(),
+    ))
+}
+
+impl IndexGraph {
+    /// Find symbol at location (see [`find_symbol_at_location`])
+    pub fn find_symbol_at_location(&self, file: &str, line: u32, column: u32) -> Option<Symbol> {
+        match self {
+            IndexGraph::Mapped(graph) => symbol_at_location(
+                graph
+                    .symbols_in_saved_order()
+                    .map(|s| (s, s.file_path(), s.range())),
+                file,
+                line,
+                column,
+            )
+            .map(|s| s.to_symbol()),
+            IndexGraph::Loaded(graph) => {
+                find_symbol_at_location(graph, file, line, column).cloned()
+            }
+        }
+    }
+
+    /// Reference edges pointing at a symbol with their source symbols, newest first
+    pub fn find_reference_edges(
+        &self,
+        symbol_id: &str,
+        min_confidence: f32,
+    ) -> Vec<(Symbol, Edge)> {
+        match self {
+            IndexGraph::Mapped(graph) => match graph.find_symbol(symbol_id) {
+                Some(symbol) => graph
+                    .incoming_edges(symbol.symbol_id())
+                    .filter(|(_, edge)| {
+                        edge.kind == EdgeKind::Reference && edge.confidence >= min_confidence
+                    })
+                    .map(|(source, edge)| (graph.symbol(source).to_symbol(), edge))
+                    .collect(),
+                None => Vec::new(),
+            },
+            IndexGraph::Loaded(graph) => graph
+                .find_reference_edges(symbol_id, min_confidence)
+                .into_iter()
+                .map(|(source, edge)| (source.clone(), *edge))
+                .collect(),
+        }
+    }
 }
 
 /// Find symbol at location with fuzzy column matching
@@ -24,36 +91,51 @@ pub fn find_symbol_at_location<'a>(
     line: u32,
     column: u32,
 ) -> Option<&'a Symbol> {
+    symbol_at_location(
+        graph
+            .get_all_symbols()
+            .map(|s| (s, s.file_path.as_str(), s.range)),
+        file,
+        line,
+        column,
+    )
+}
+
+/// The first symbol containing the location, or else the first one starting near it on that line
+fn symbol_at_location<'a, T>(
+    symbols: impl Iterator<Item = (T, &'a str, Range)>,
+    file: &str,
+    line: u32,
+    column: u32,
+) -> Option<T> {
     // ユーザー入力は1ベース、内部表現は0ベース
     let zero_based_line = line.saturating_sub(1);
     let zero_based_column = column.saturating_sub(1);
-    
-    // まず正確な位置でシンボルを検索
-    let symbol = graph.get_all_symbols().find(|s| {
+
+    // 正確な位置のシンボルがなければ、より緩い条件で最初に見つかったもの
+    let mut nearby = None;
+    for (symbol, file_path, range) in symbols {
         // ファイルパスの正規化（相対パスと絶対パスの両方に対応）
-        let matches_file = s.file_path == file 
-            || s.file_path.ends_with(file) 
-            || file.ends_with(&s.file_path);
-            
-        matches_file
-            && s.range.start.line <= zero_based_line
-            && s.range.end.line >= zero_based_line
-            && (zero_based_line != s.range.start.line || s.range.start.character <= zero_based_column)
-            && (zero_based_line != s.range.end.line || s.range.end.character >= zero_based_column)
-    });
-    
-    // 見つからない場合は、より緩い条件で検索
-    symbol.or_else(|| {
-        graph.get_all_symbols().find(|s| {
-            let matches_file = s.file_path == file 
-                || s.file_path.ends_with(file) 
-                || file.ends_with(&s.file_path);
-                
-            matches_file
-                && s.range.start.line == zero_based_line
-                && s.range.start.character <= zero_based_column + 10
-        })
-    })
+        let matches_file =
+            file_path == file || file_path.ends_with(file) || file.ends_with(file_path);
+        if !matches_file {
+            continue;
+        }
+        if range.start.line <= zero_based_line
+            && range.end.line >= zero_based_line
+            && (zero_based_line != range.start.line || range.start.character <= zero_based_column)
+            && (zero_based_line != range.end.line || range.end.character >= zero_based_column)
+        {
+            return Some(symbol);
+        }
+        if nearby.is_none()
+            && range.start.line == zero_based_line
+            && range.start.character <= zero_based_column + 10
+        {
+            nearby = Some(symbol);
+        }
+    }
+    nearby
 }
 
 /// Format symbol location for display
@@ -0,0 +1,312 @@
+use super::utils::*;
+use crate::differential_indexer::{DifferentialIndexMetadata, METADATA_KEY};
+use crate::reference_finder::{link_references, referencing_files};
+use crate::staleness::StalenessManifest;
+use crate::storage::IndexStorage;
+use anyhow::{anyhow, Result};
+use lsif_core::graph_serde::SerializedCodeGraph;
+use lsif_core::{qualify_symbols, CodeGraph};
+use lsp::incremental_parser::IncrementalParser;
+use lsp::tree_sitter_parser::TreeSitterParser;
+use notify::{EventKind, RecursiveMode, Watcher};
+use std::collections::{BTreeSet, HashSet};
+use std::path::{Path, PathBuf};
+use std::sync::mpsc::{self, RecvTimeoutError};
+use std::time::{Duration, Instant};
+use tracing::{debug, warn};
+
+/// ファイルの変更を監視し、変更されたファイルを増分で再インデックスする
+///
+/// 変更は`debounce`の間まとめてから反映し、グラフと鮮度マニフェストを保存する
+/// （そのため問い合わせの前の自動インデックスは走らない）。
+pub fn handle_watch(db_path: &str, project_root: &str, debounce: Duration) -> Result<()> {
+    let graph = IndexStorage::open_read_only(db_path)?
+        .load_graph()?
+        .ok_or_else(|| anyhow!("No index found at {}. Run `lsif index` first", db_path))?;
+    let project_root = Path::new(project_root);
+    let canonical_root = project_root.canonicalize()?;
+    let db_dir = Path::new(db_path).canonicalize().ok();
+    let mut indexer = WatchIndexer::new(project_root, graph);
+
+    let (tx, rx) = mpsc::channel();
+    let mut watcher = notify::recommended_watcher(tx)?;
+    watcher.watch(&canonical_root, RecursiveMode::Recursive)?;
+    print_info(
+        &format!("Watching {} (Ctrl+C to stop)", project_root.display()),
+        "👀",
+    );
+
+    let mut pending = BTreeSet::new();
+    loop {
+        match rx.recv_timeout(debounce) {
+            Ok(Ok(event)) => {
+                if !matches!(event.kind, EventKind::Access(_)) {
+                    pending.extend(event.paths.iter().filter_map(|path| {
+                        index_path(&canonical_root, project_root, db_dir.as_deref(), path)
+                    }));
+                }
+                continue;
+            }
+            Ok(Err(e)) => {
+                warn!("File watch error: {}", e);
+                continue;
+            }
+            Err(RecvTimeoutError::Timeout) => {}
+            Err(RecvTimeoutError::Disconnected) => return Ok(()),
+        }
+        if pending.is_empty() {
+            continue;
+        }
+
+        let start = Instant::now();
+        let paths: Vec<PathBuf> = std::mem::take(&mut pending).into_iter().collect();
+        let changed = indexer.apply_changes(&paths);
+        if changed.is_empty() {
+            continue;
+        }
+        indexer.save(db_path)?;
+        print_success(&format!(
+            "Reindexed {} file(s) in {:.0}ms",
+            changed.len(),
+            start.elapsed().as_secs_f64() * 1000.0
+        ));
+    }
+}
+
+/// 監視で届いた（正規化済みの）パスを、インデックスでのパス（`project_root`からのパス）にする
+///
+/// 対象外のファイル（インデックスのディレクトリ・除外ディレクトリ・未対応の言語）ならNone。
+fn index_path(
+    canonical_root: &Path,
+    project_root: &Path,
+    db_dir: Option<&Path>,
+    path: &Path,
+) -> Option<PathBuf> {
+    if db_dir.is_some_and(|db_dir| path.starts_with(db_dir)) {
+        return None;
+    }
+    let relative = path.strip_prefix(canonical_root).ok()?;
+    let excluded = relative.components().any(|component| {
+        matches!(
+            component.as_os_str().to_str(),
+            Some(".git" | "target" | "node_modules")
+        )
+    });
+    if excluded || !TreeSitterParser::supports_extension(relative) {
+        return None;
+    }
+    Some(project_root.join(relative))
+}
+
+/// 読み込んだグラフを、変更されたファイルの分だけ増分で更新する
+///
+/// ファイルごとに`IncrementalParser`で解析した木を保持し、変わった定義のシンボルだけを
+/// 差し替える。シンボルはtree-sitterで抽出するので、ファイルを初めて変更した時は
+/// そのファイルのシンボル（LSPで作ったものも）をまとめて置き換える。
+/// ハッシュは更新しないので、次の`lsif index`がこれらのファイルを改めてインデックスする。
+pub struct WatchIndexer {
+    project_root: PathBuf,
+    parser: IncrementalParser,
+    graph: CodeGraph,
+}
+
+impl WatchIndexer {
+    pub fn new(project_root: &Path, graph: CodeGraph) -> Self {
+        Self {
+            project_root: project_root.to_path_buf(),
+            parser: IncrementalParser::new(),
+            graph,
+        }
+    }
+
+    pub fn graph(&self) -> &CodeGraph {
+        &self.graph
+    }
+
+    /// 変更されたファイルを反映し、シンボルが変わったファイルを返す
+    ///
+    /// 消えたファイルはシンボルを削除する。変わったファイルとそれを参照していたファイルの
+    /// 参照は張り直す。
+    pub fn apply_changes(&mut self, paths: &[PathBuf]) -> Vec<PathBuf> {
+        let mut changed = Vec::new();
+        let mut referrers = HashSet::new();
+        for path in paths {
+            let file_path = path.to_string_lossy().to_string();
+            let region = if path.exists() {
+                let text = match std::fs::read_to_string(path) {
+                    Ok(text) => text,
+                    Err(e) => {
+                        warn!("Failed to read {}: {}", path.display(), e);
+                        continue;
+                    }
+                };
+                match self.parser.update(&file_path, text) {
+                    Ok(Some(region)) => Some(region),
+                    Ok(None) => continue,
+                    Err(e) => {
+                        warn!("Failed to reparse {}: {}", path.display(), e);
+                        continue;
+                    }
+                }
+            } else {
+                self.parser.close(&file_path);
+                None
+            };
+
+            // 差し替えると入ってくる参照のエッジも消えるので、先に参照元を集める
+            referrers.extend(referencing_files(&self.graph, &file_path));
+            let stats = match region {
+                Some(region) => region.apply(&mut self.graph),
+                None => self
+                    .graph
+                    .replace_symbols_in_rows(&file_path, None, 0, Vec::new()),
+            };
+            if stats.added == 0 && stats.removed == 0 && stats.shifted == 0 {
+                continue;
+            }
+            debug!(
+                "Updated {}: {} removed, {} added, {} shifted",
+                file_path, stats.removed, stats.added, stats.shifted
+            );
+            changed.push(path.clone());
+        }
+        if changed.is_empty() {
+            return changed;
+        }
+
+        let mut files = changed.clone();
+        files.extend(referrers.into_iter().filter(|path| !changed.contains(path)));
+        link_references(&mut self.graph, &files);
+        qualify_symbols(&mut self.graph, &self.project_root);
+        changed
+    }
+
+    /// グラフを保存し、鮮度マニフェストを取り直す
+    ///
+    /// 問い合わせのたびにsledのロックを握らないよう、保存する時だけストレージを開く。
+    pub fn save(&self, db_path: &str) -> Result<()> {
+        let storage = IndexStorage::open(db_path)?;
+        let files: Vec<PathBuf> = storage
+            .load_data::<DifferentialIndexMetadata>(METADATA_KEY)?
+            .map(|metadata| metadata.file_content_hashes.into_keys().collect())
+            .unwrap_or_default();
+        let manifest = StalenessManifest::capture(&self.project_root, &files);
+
+        let mut transaction = storage.transaction();
+        transaction.save_graph(&SerializedCodeGraph::from(&self.graph))?;
+        transaction.save_staleness_manifest(&manifest)?;
+        transaction.commit()
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use lsif_core::EdgeKind;
+    use petgraph::visit::EdgeRef;
+    use petgraph::Direction;
+    use std::fs;
+    use tempfile::TempDir;
+
+    /// `file`のシンボル`name`から`target_file`のシンボルへの参照先の名前
+    fn referenced_names(
+        graph: &CodeGraph,
+        file: &Path,
+        name: &str,
+        target_file: &Path,
+    ) -> Vec<String> {
+        let file = file.to_string_lossy();
+        let target_file = target_file.to_string_lossy();
+        let source = graph
+            .graph
+            .node_indices()
+            .find(|&idx| graph.graph[idx].file_path == file && graph.graph[idx].name == name)
+            .unwrap();
+        let mut names: Vec<String> = graph
+            .graph
+            .edges_directed(source, Direction::Outgoing)
+            .filter(|edge| edge.weight().kind == EdgeKind::Reference)
+            .map(|edge| &graph.graph[edge.target()])
+            .filter(|target| target.file_path == target_file)
+            .map(|target| target.name.clone())
+            .collect();
+        names.sort();
+        names
+    }
+
+    #[test]
+    fn test_apply_changes_relinks_references_into_edited_file() {
+        let temp_dir = TempDir::new().unwrap();
+        let store_dir = temp_dir.path().join("store");
+        fs::create_dir(&store_dir).unwrap();
+        let db = store_dir.join("db.go");
+        let file = store_dir.join("file.go");
+        fs::write(
+            &db,
+            "package store\n\ntype DB struct{}\n\nfunc (d *DB) Close() error { return nil }\n",
+        )
+        .unwrap();
+        fs::write(
+            &file,
+            "package store\n\nfunc cleanup(db *DB) { db.Close() }\n",
+        )
+        .unwrap();
+
+        let mut indexer = WatchIndexer::new(temp_dir.path(), CodeGraph::new());
+        assert_eq!(indexer.apply_changes(&[db.clone(), file.clone()]).len(), 2);
+        let before = referenced_names(indexer.graph(), &file, "cleanup", &db);
+        assert!(!before.is_empty());
+
+        // 定義の前に行を足し、Closeの本体を変える
+        fs::write(
+            &db,
+            "package store\n\n// DB is a store.\ntype DB struct{}\n\nfunc (d *DB) Close() error { return nil /* closed */ }\n",
+        )
+        .unwrap();
+        assert_eq!(indexer.apply_changes(&[db.clone()]), vec![db.clone()]);
+        // 差し替えたシンボルへの参照が張り直されている
+        assert_eq!(
+            referenced_names(indexer.graph(), &file, "cleanup", &db),
+            before
+        );
+
+        // 内容が同じなら何もしない
+        assert!(indexer.apply_changes(&[db.clone()]).is_empty());
+
+        // 消えたファイルのシンボルは削除する
+        fs::remove_file(&db).unwrap();
+        assert_eq!(indexer.apply_changes(&[db.clone()]), vec![db.clone()]);
+        let db_path = db.to_string_lossy();
+        let graph = indexer.graph();
+        assert!(graph
+            .graph
+            .node_indices()
+            .all(|idx| graph.graph[idx].file_path != db_path));
+    }
+
+    #[test]
+    fn test_index_path() {
+        let root = Path::new("/repo");
+        let db_dir = Path::new("/repo/.lsif-index.db");
+        assert_eq!(
+            index_path(
+                root,
+                Path::new("."),
+                Some(db_dir),
+                Path::new("/repo/src/main.rs")
+            ),
+            Some(PathBuf::from("./src/main.rs"))
+        );
+        for ignored in [
+            "/repo/.lsif-index.db/db.rs",
+            "/repo/target/debug/build.rs",
+            "/repo/README.md",
+            "/elsewhere/main.rs",
+        ] {
+            assert_eq!(
+                index_path(root, Path::new("."), Some(db_dir), Path::new(ignored)),
+                None
+            );
+        }
+    }
+}
@@ -6,8 +6,16 @@ use crate::storage::IndexStorage;
 use anyhow::Result;
 use lsif_core::{Position, Range, Symbol, SymbolKind};
 use lsp::adapter::lsp::GenericLspClient;
+use lsp::lsp_helpers::{declaration_symbol_modifiers, SourceDocComments};
 use lsp::lsp_pool::LspClientPool;
-use lsp_types;
+use lsp::lsp_result_cache::{
+    content_hash, dependency_fingerprint, workspace_fingerprint, CachedRequest, LspResultCache,
+    ResultCacheKey, ServerIdentity,
+};
+use lsp_types::{
+    GotoDefinitionParams, Location, PartialResultParams, ReferenceContext, ReferenceParams,
+    TextDocumentIdentifier, TextDocumentPositionParams, WorkDoneProgressParams,
+};
 use std::cmp::Ordering;
 use std::collections::{BinaryHeap, HashMap, HashSet};
 use std::path::{Path, PathBuf};
@@ -52,6 +60,12 @@ pub struct DefinitionCrawler {
     max_depth: u32,
     max_files: usize,
 
+    /// 定義・参照の応答の永続キャッシュ（`LSIF_NO_LSP_CACHE`で無効化）
+    lsp_result_cache: Option<LspResultCache>,
+    dependency_fingerprint: String,
+    /// ワークスペースの指紋（初回の問い合わせで計算）
+    workspace_fingerprint: Option<String>,
+
     // 統計情報
     crawled_files: HashSet<PathBuf>,
     reference_counts: HashMap<PathBuf, i32>,
@@ -66,6 +80,13 @@ impl DefinitionCrawler {
     ) -> Result<Self> {
         let storage = IndexStorage::open(storage_path)?;
         let lsp_pool = LspClientPool::with_defaults();
+        let lsp_result_cache = if std::env::var("LSIF_NO_LSP_CACHE").is_ok() {
+            None
+        } else {
+            LspResultCache::open_default()
+                .map_err(|e| warn!("LSP result cache disabled: {}", e))
+                .ok()
+        };
 
         Ok(Self {
             _storage: storage,
@@ -73,6 +94,9 @@ impl DefinitionCrawler {
             project_root: project_root.to_path_buf(),
             max_depth,
             max_files,
+            lsp_result_cache,
+            dependency_fingerprint: dependency_fingerprint(project_root),
+            workspace_fingerprint: None,
             crawled_files: HashSet::new(),
             reference_counts: HashMap::new(),
         })
@@ -154,6 +178,11 @@ impl DefinitionCrawler {
             "Crawl completed: {} files indexed, {} symbols found",
             stats.files_indexed, stats.symbols_found
         );
+        if let Some(cache) = &self.lsp_result_cache {
+            if let Err(e) = cache.flush() {
+                warn!("Failed to flush LSP result cache: {}", e);
+            }
+        }
 
         Ok(stats)
     }
@@ -295,15 +324,18 @@ impl DefinitionCrawler {
 
         // LSPシンボルをcore::Symbolに変換
         let mut result = Vec::new();
+        let file_path_str = file_path.to_string_lossy();
+        let docs = SourceDocComments::load(file_path);
         for doc_symbol in symbols {
-            result.push(Symbol {
+            let modifiers = declaration_symbol_modifiers(&doc_symbol, &file_path_str, None);
+            let mut symbol = Symbol {
                 id: format!(
                     "{}#{}:{}",
                     file_path.display(),
                     doc_symbol.range.start.line,
                     doc_symbol.name
                 ),
-                name: doc_symbol.name,
+                name: doc_symbol.name.clone(),
                 kind: Self::convert_symbol_kind(doc_symbol.kind),
                 file_path: file_path.to_string_lossy().to_string(),
                 range: Range {
@@ -316,9 +348,14 @@ impl DefinitionCrawler {
                         character: doc_symbol.range.end.character,
                     },
                 },
-                documentation: doc_symbol.detail,
-                detail: None,
-            });
+                documentation: None,
+                // フォールバックのdetailは宣言行
+                detail: doc_symbol.detail.clone(),
+                modifiers,
+                qualified_name: None,
+            };
+            docs.attach(&doc_symbol, &mut symbol);
+            result.push(symbol);
         }
 
         Ok(result)
@@ -326,56 +363,111 @@ impl DefinitionCrawler {
 
     /// 定義元を取得
     fn get_definitions(
-        &self,
-        _client: &mut GenericLspClient,
+        &mut self,
+        client: &mut GenericLspClient,
         file_path: &Path,
         position: &Position,
     ) -> Result<Vec<PathBuf>> {
-        let _lsp_position = lsp_types::Position {
+        let params = GotoDefinitionParams {
+            text_document_position_params: Self::position_params(file_path, position)?,
+            work_done_progress_params: WorkDoneProgressParams::default(),
+            partial_result_params: PartialResultParams::default(),
+        };
+        let request = CachedRequest::Definition {
             line: position.line,
             character: position.character,
         };
-
-        // get_definitionメソッドがない場合は、send_requestを使用
-        let _file_uri = format!("file://{}", file_path.display());
-        // TODO: 実際のLSP定義ジャンプAPIを呼び出す
-        let locations: Vec<lsp_types::Location> = Vec::new();
-
-        let mut paths = Vec::new();
-        for location in locations {
-            if let Ok(path) = location.uri.to_file_path() {
-                paths.push(path);
-            }
-        }
-
-        Ok(paths)
+        // 定義が見つからない結果もキャッシュするため、空のVecとして扱う
+        let locations: Vec<Location> =
+            self.cached_request(client, file_path, request, |client| {
+                client.goto_definitions(params)
+            })?;
+
+        Ok(locations
+            .into_iter()
+            .take(1)
+            .filter_map(|location| location.uri.to_file_path().ok())
+            .collect())
     }
 
     /// 参照を取得
     fn get_references(
-        &self,
-        _client: &mut GenericLspClient,
-        _file_path: &Path,
-        _position: &Position,
+        &mut self,
+        client: &mut GenericLspClient,
+        file_path: &Path,
+        position: &Position,
     ) -> Result<Vec<PathBuf>> {
-        let _lsp_position = lsp_types::Position {
-            line: _position.line,
-            character: _position.character,
+        let params = ReferenceParams {
+            text_document_position: Self::position_params(file_path, position)?,
+            context: ReferenceContext {
+                include_declaration: false,
+            },
+            work_done_progress_params: WorkDoneProgressParams::default(),
+            partial_result_params: PartialResultParams::default(),
         };
+        let request = CachedRequest::References {
+            line: position.line,
+            character: position.character,
+            include_declaration: false,
+        };
+        let locations: Vec<Location> =
+            self.cached_request(client, file_path, request, |client| {
+                client.find_references(params)
+            })?;
+
+        Ok(locations
+            .into_iter()
+            .filter_map(|location| location.uri.to_file_path().ok())
+            .collect())
+    }
 
-        // get_referencesメソッドがない場合は、send_requestを使用
-        let _file_uri = format!("file://{}", _file_path.display());
-        // TODO: 実際のLSP参照検索APIを呼び出す
-        let locations: Vec<lsp_types::Location> = Vec::new();
-
-        let mut paths = Vec::new();
-        for location in locations {
-            if let Ok(path) = location.uri.to_file_path() {
-                paths.push(path);
-            }
-        }
+    fn position_params(
+        file_path: &Path,
+        position: &Position,
+    ) -> Result<TextDocumentPositionParams> {
+        let uri = format!("file://{}", file_path.canonicalize()?.display());
+        Ok(TextDocumentPositionParams {
+            text_document: TextDocumentIdentifier { uri: uri.parse()? },
+            position: lsp_types::Position {
+                line: position.line,
+                character: position.character,
+            },
+        })
+    }
 
-        Ok(paths)
+    /// 永続キャッシュを通してLSPに問い合わせる
+    ///
+    /// 応答は他のファイルにも依存するので、ファイルの内容ではなく
+    /// ワークスペース全体の指紋とファイルのパスをキーにする。
+    fn cached_request<T, F>(
+        &mut self,
+        client: &mut GenericLspClient,
+        file_path: &Path,
+        request: CachedRequest,
+        fetch: F,
+    ) -> Result<T>
+    where
+        T: serde::Serialize + serde::de::DeserializeOwned,
+        F: FnOnce(&mut GenericLspClient) -> Result<T>,
+    {
+        let cache = match &self.lsp_result_cache {
+            Some(cache) => cache,
+            None => return fetch(client),
+        };
+        let workspace = self
+            .workspace_fingerprint
+            .get_or_insert_with(|| workspace_fingerprint(&self.project_root));
+        let relative = file_path
+            .strip_prefix(&self.project_root)
+            .unwrap_or(file_path);
+        let file_key = content_hash(format!("{}\0{}", workspace, relative.display()).as_bytes());
+        let key = ResultCacheKey::new(
+            ServerIdentity::from_server_info(client.get_language_id(), client.get_server_info()),
+            &file_key,
+            &self.dependency_fingerprint,
+            request,
+        );
+        cache.get_or_fetch(&key, || fetch(client))
     }
 
     /// ファイルがプロジェクト内かチェック
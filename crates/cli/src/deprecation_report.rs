@@ -0,0 +1,296 @@
+//! 非推奨APIの使用箇所の検出
+//!
+//! インデックス時に非推奨の印が付いたシンボル（`Symbol::deprecation`）への参照を集め、
+//! 参照元のパッケージごとにまとめる。CIでは既知の使用箇所をベースラインとして除外し、
+//! 新しい使用だけを失敗にできる。
+
+use crate::reference_finder::find_references_to_symbol;
+use anyhow::Result;
+use lsif_core::{suggested_replacement, CodeGraph, Position, Range, Symbol};
+use serde::{Deserialize, Serialize};
+use std::collections::{BTreeMap, HashMap, HashSet};
+use std::path::Path;
+
+/// 非推奨シンボルの使用箇所
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct DeprecatedUsage {
+    /// 参照元のパッケージ（ファイルのディレクトリ）
+    pub package: String,
+    pub file: String,
+    /// 1ベースの行・列
+    pub line: u32,
+    pub column: u32,
+    /// 使用している非推奨シンボル
+    pub symbol: String,
+    pub definition_file: String,
+    pub definition_line: u32,
+    /// 非推奨の説明
+    pub note: String,
+    /// 説明から推測した代わりのAPI
+    pub replacement: Option<String>,
+}
+
+impl DeprecatedUsage {
+    /// ベースラインとの照合キー（行番号は編集でずれるので含めない）
+    fn baseline_key(&self) -> (&str, &str, &str) {
+        (&self.file, &self.symbol, &self.definition_file)
+    }
+}
+
+/// 非推奨シンボルへの参照をすべて集める
+///
+/// グラフに参照エッジがないシンボルはtree-sitterの名前解決で探す。
+pub fn find_deprecated_usages(
+    graph: &CodeGraph,
+    project_root: &Path,
+) -> Result<Vec<DeprecatedUsage>> {
+    let mut usages = Vec::new();
+
+    for target in graph.get_all_symbols() {
+        if !target.is_deprecated() {
+            continue;
+        }
+        let note = target.deprecation().unwrap_or_default();
+
+        let references = match graph.find_references(&target.id)? {
+            // 定義ファイルが読めない場合は使用箇所なしとして扱う
+            refs if refs.is_empty() => find_references_to_symbol(project_root, target)
+                .map(|refs| {
+                    refs.into_iter()
+                        .filter(|r| !r.is_definition)
+                        .map(|r| r.symbol)
+                        .collect()
+                })
+                .unwrap_or_default(),
+            refs => refs,
+        };
+
+        let definition_file = relative_path(project_root, &target.file_path);
+        for reference in references {
+            let file = relative_path(project_root, &reference.file_path);
+            // 定義の内側（再帰呼び出しなど）は使用箇所に数えない
+            if file == definition_file && contains(target.range, reference.range.start) {
+                continue;
+            }
+            usages.push(DeprecatedUsage {
+                package: owning_package(&file),
+                file,
+                line: reference.range.start.line + 1,
+                column: reference.range.start.character + 1,
+                symbol: target.name.clone(),
+                definition_file: definition_file.clone(),
+                definition_line: target.range.start.line + 1,
+                note: note.to_string(),
+                replacement: suggested_replacement(note),
+            });
+        }
+    }
+
+    usages.sort_by(|a, b| {
+        (&a.package, &a.file, a.line, a.column).cmp(&(&b.package, &b.file, b.line, b.column))
+    });
+    usages.dedup();
+    Ok(usages)
+}
+
+/// ベースライン（以前の`lsif deprecated --format json`の出力）にない使用箇所だけを残す
+///
+/// 同じキーの使用箇所はベースラインの件数までを既知とし、増えた分を新しい使用として返す。
+/// 既知とみなすのは行番号が一致するものを優先し、残りは先頭から数える。
+pub fn exclude_baseline(
+    usages: Vec<DeprecatedUsage>,
+    baseline: &[DeprecatedUsage],
+) -> Vec<DeprecatedUsage> {
+    let mut remaining: HashMap<_, usize> = HashMap::new();
+    let mut known_lines = HashSet::new();
+    for usage in baseline {
+        *remaining.entry(usage.baseline_key()).or_default() += 1;
+        known_lines.insert((usage.baseline_key(), usage.line));
+    }
+
+    // 行が変わっていない使用箇所から既知の件数を使う
+    let mut known = vec![false; usages.len()];
+    for (i, usage) in usages.iter().enumerate() {
+        if !known_lines.contains(&(usage.baseline_key(), usage.line)) {
+            continue;
+        }
+        if let Some(count) = remaining.get_mut(&usage.baseline_key()).filter(|c| **c > 0) {
+            *count -= 1;
+            known[i] = true;
+        }
+    }
+    for (i, usage) in usages.iter().enumerate() {
+        if known[i] {
+            continue;
+        }
+        if let Some(count) = remaining.get_mut(&usage.baseline_key()).filter(|c| **c > 0) {
+            *count -= 1;
+            known[i] = true;
+        }
+    }
+
+    usages
+        .into_iter()
+        .zip(known)
+        .filter(|(_, known)| !known)
+        .map(|(usage, _)| usage)
+        .collect()
+}
+
+/// パッケージごとにまとめる
+pub fn group_by_package(usages: &[DeprecatedUsage]) -> BTreeMap<&str, Vec<&DeprecatedUsage>> {
+    let mut groups: BTreeMap<&str, Vec<&DeprecatedUsage>> = BTreeMap::new();
+    for usage in usages {
+        groups.entry(&usage.package).or_default().push(usage);
+    }
+    groups
+}
+
+/// ファイルを含むディレクトリ（ルート直下は`.`）
+fn owning_package(file: &str) -> String {
+    match Path::new(file).parent() {
+        Some(dir) if !dir.as_os_str().is_empty() => dir.to_string_lossy().to_string(),
+        _ => ".".to_string(),
+    }
+}
+
+/// プロジェクトルートからの相対パス（ルートの外ならそのまま）
+fn relative_path(project_root: &Path, file_path: &str) -> String {
+    let path = Path::new(file_path);
+    let path = path.strip_prefix(project_root).unwrap_or(path);
+    let path = path.strip_prefix(".").unwrap_or(path);
+    path.to_string_lossy().to_string()
+}
+
+fn contains(range: Range, position: Position) -> bool {
+    (range.start.line, range.start.character) <= (position.line, position.character)
+        && (position.line, position.character) <= (range.end.line, range.end.character)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use lsif_core::{EdgeKind, SymbolKind};
+    use std::fs;
+    use tempfile::TempDir;
+
+    fn symbol(id: &str, name: &str, file: &str, line: u32, doc: Option<&str>) -> Symbol {
+        Symbol {
+            id: id.to_string(),
+            kind: SymbolKind::Function,
+            name: name.to_string(),
+            file_path: file.to_string(),
+            range: Range {
+                start: Position { line, character: 0 },
+                end: Position {
+                    line: line + 2,
+                    character: 1,
+                },
+            },
+            documentation: doc.map(str::to_string),
+            detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
+        }
+    }
+
+    #[test]
+    fn test_usages_from_graph_edges() {
+        let mut graph = CodeGraph::new();
+        let open = graph.add_symbol(symbol(
+            "store/db.go#4:Open",
+            "Open",
+            "store/db.go",
+            3,
+            Some("Open opens the database.\n\n**Deprecated**: Use OpenContext instead."),
+        ));
+        let fresh = graph.add_symbol(symbol("store/db.go#9:New", "New", "store/db.go", 8, None));
+        let from_main = graph.add_symbol(symbol("main.go#5:ref", "Open", "main.go", 4, None));
+        let from_api = graph.add_symbol(symbol("api/h.go#7:ref", "Open", "api/h.go", 6, None));
+        let recursive =
+            graph.add_symbol(symbol("store/db.go#5:ref", "Open", "store/db.go", 4, None));
+        graph.add_edge(from_main, open, EdgeKind::Reference);
+        graph.add_edge(from_api, open, EdgeKind::Reference);
+        graph.add_edge(recursive, open, EdgeKind::Reference);
+        graph.add_edge(from_main, fresh, EdgeKind::Reference);
+
+        let usages = find_deprecated_usages(&graph, Path::new(".")).unwrap();
+        let sites: Vec<_> = usages
+            .iter()
+            .map(|u| (u.package.as_str(), u.file.as_str(), u.line))
+            .collect();
+        assert_eq!(sites, vec![(".", "main.go", 5), ("api", "api/h.go", 7)]);
+        assert_eq!(usages[0].replacement.as_deref(), Some("OpenContext"));
+        assert_eq!(usages[0].definition_file, "store/db.go");
+
+        let groups = group_by_package(&usages);
+        assert_eq!(groups.keys().copied().collect::<Vec<_>>(), vec![".", "api"]);
+
+        // 既知の使用箇所は行がずれても除外する
+        let mut baseline = vec![usages[0].clone()];
+        baseline[0].line = 42;
+        let new_usages = exclude_baseline(usages, &baseline);
+        assert_eq!(new_usages.len(), 1);
+        assert_eq!(new_usages[0].file, "api/h.go");
+    }
+
+    #[test]
+    fn test_exclude_baseline_reports_added_usages_in_known_file() {
+        let usage = |line| DeprecatedUsage {
+            package: ".".to_string(),
+            file: "main.go".to_string(),
+            line,
+            column: 2,
+            symbol: "Open".to_string(),
+            definition_file: "store/db.go".to_string(),
+            definition_line: 4,
+            note: "Use OpenContext instead.".to_string(),
+            replacement: Some("OpenContext".to_string()),
+        };
+        let baseline = vec![usage(10), usage(20)];
+
+        // 同じファイルで同じシンボルを使う箇所が増えたら、増えた分は新しい使用
+        let new_usages = exclude_baseline(vec![usage(5), usage(10), usage(20)], &baseline);
+        assert_eq!(new_usages, vec![usage(5)]);
+
+        // 行がずれただけなら件数が同じなので新しい使用はない
+        let new_usages = exclude_baseline(vec![usage(12), usage(22)], &baseline);
+        assert!(new_usages.is_empty());
+
+        // 件数が増えて行もずれた場合は、増えた件数だけを報告する
+        let new_usages = exclude_baseline(vec![usage(11), usage(21), usage(30)], &baseline);
+        assert_eq!(new_usages.len(), 1);
+    }
+
+    #[test]
+    fn test_usages_from_scope_resolution() {
+        let temp_dir = TempDir::new().unwrap();
+        fs::write(
+            temp_dir.path().join("lib.py"),
+            "def old():\n    pass\n\ndef new():\n    pass\n",
+        )
+        .unwrap();
+        fs::write(
+            temp_dir.path().join("app.py"),
+            "from lib import old, new\n\nold()\nnew()\n",
+        )
+        .unwrap();
+
+        let mut graph = CodeGraph::new();
+        let mut target = symbol(
+            "lib.py#1:old",
+            "old",
+            &temp_dir.path().join("lib.py").to_string_lossy(),
+            0,
+            Some("**Deprecated**: use new()"),
+        );
+        target.range.end.line = 1;
+        graph.add_symbol(target);
+
+        let usages = find_deprecated_usages(&graph, temp_dir.path()).unwrap();
+        let sites: Vec<_> = usages.iter().map(|u| (u.file.as_str(), u.line)).collect();
+        assert!(sites.contains(&("app.py", 3)));
+        assert!(usages.iter().all(|u| u.symbol == "old"));
+        assert_eq!(usages[0].replacement.as_deref(), Some("new()"));
+    }
+}
@@ -5,19 +5,34 @@ use std::time::{Duration, Instant};
 use tracing::{debug, error, info, warn};
 
 use crate::adaptive_parallel::{AdaptiveIncrementalProcessor, AdaptiveParallelConfig};
+use crate::cancellation::CancellationToken;
 use crate::git_diff::{FileChange, FileChangeStatus, GitDiffDetector};
-use crate::storage::IndexStorage;
+use crate::index_checkpoint::{Checkpointer, FailureLog, FileFailure};
+use crate::memory_budget::MemoryBudget;
+use crate::reference_finder::{link_references, referencing_files};
+use crate::staleness::StalenessManifest;
+use crate::storage::{GraphParts, IndexStorage, IndexTransaction};
+use crate::symbol_extraction_strategy::ChainedSymbolExtractor;
 use chrono::{DateTime, Utc};
 use indicatif::{ProgressBar, ProgressStyle};
-use lsif_core::{CodeGraph, Symbol, SymbolKind};
+use lsif_core::graph_serde::SerializedCodeGraph;
+use lsif_core::{qualify_symbols, CodeGraph, Symbol, SymbolKind};
 use serde::{Deserialize, Serialize};
 use walkdir;
 
 // LSP統合のためのインポート
 use lsp::language_detector::detect_project_language;
 use lsp::language_optimization::{OptimizationStrategy, ProjectOptimizationConfig};
+use lsp::lsp_helpers::{document_symbol_modifiers, SourceDocComments};
 use lsp::lsp_indexer::LspIndexer;
 use lsp::lsp_pool::{LspClientPool, PoolConfig};
+use lsp::lsp_result_cache::{
+    dependency_fingerprint, CachedRequest, LspResultCache, ResultCacheKey, ServerIdentity,
+};
+use lsp::tree_sitter_parser::TreeSitterParser;
+
+/// メタデータを保存するキー
+pub const METADATA_KEY: &str = "__differential_metadata__";
 
 /// 差分インデックスのメタデータ
 #[derive(Debug, Clone, Serialize, Deserialize)]
@@ -61,6 +76,12 @@ pub struct DifferentialIndexResult {
     pub full_reindex: bool,
     /// 差分率（変更ファイル数 / 全ファイル数）
     pub change_ratio: f64,
+    /// 中断され、処理済みのファイルだけを保存したか（次の実行で残りを処理する）
+    pub interrupted: bool,
+    /// チェックポイントから再開して処理を省いたファイル数
+    pub resumed_files: usize,
+    /// シンボルを抽出できなかったファイル
+    pub failed_files: Vec<(PathBuf, FileFailure)>,
 }
 
 /// シンボルのサマリー情報
@@ -94,6 +115,18 @@ pub struct DifferentialIndexer {
     optimization_strategy: OptimizationStrategy,
     /// プロジェクト最適化設定
     project_config: ProjectOptimizationConfig,
+    /// LSP応答の永続キャッシュ（LSPを使う時に遅延して開く）
+    lsp_result_cache: Option<LspResultCache>,
+    /// 永続キャッシュを開こうとしたか
+    lsp_result_cache_opened: bool,
+    /// 依存関係の指紋（キャッシュキーの一部）
+    dependency_fingerprint: String,
+    /// 中断の要求（ファイルの切れ目で確認する）
+    cancellation: Option<CancellationToken>,
+    /// フルインデックス中のメモリの上限
+    memory_budget: Option<MemoryBudget>,
+    /// 抽出に失敗したファイルの記録（実行をまたいで試行回数を数える）
+    failures: FailureLog,
 }
 
 impl DifferentialIndexer {
@@ -107,8 +140,8 @@ impl DifferentialIndexer {
         let project_root = project_root.as_ref().to_path_buf();
 
         // メタデータを読み込み
-        let metadata =
-            storage.load_data::<DifferentialIndexMetadata>("__differential_metadata__")?;
+        let metadata = storage.load_data::<DifferentialIndexMetadata>(METADATA_KEY)?;
+        let failures = FailureLog::load(&storage)?;
 
         // 適応的並列処理の設定
         let parallel_config = AdaptiveParallelConfig::default();
@@ -133,6 +166,13 @@ impl DifferentialIndexer {
         };
         let lsp_pool = LspClientPool::new(pool_config);
 
+        // 前回までに学習したLSP応答時間を読み込み、タイムアウトの初期値に使う
+        match storage.load_lsp_timing_stats() {
+            Ok(stats) if !stats.is_empty() => lsp_pool.seed_timing_stats(stats),
+            Ok(_) => {}
+            Err(e) => warn!("Failed to load LSP timing stats: {}", e),
+        }
+
         Ok(Self {
             storage,
             git_detector,
@@ -144,9 +184,37 @@ impl DifferentialIndexer {
             fallback_only: false,
             optimization_strategy,
             project_config,
+            lsp_result_cache: None,
+            lsp_result_cache_opened: false,
+            dependency_fingerprint: dependency_fingerprint(&project_root),
+            cancellation: None,
+            memory_budget: None,
+            failures,
         })
     }
 
+    /// 中断の要求を受け付ける
+    ///
+    /// 中断されると処理中のファイルを終えてから、処理済みのファイルだけを保存して返す。
+    pub fn set_cancellation(&mut self, token: CancellationToken) {
+        self.cancellation = Some(token);
+    }
+
+    fn is_cancelled(&self) -> bool {
+        self.cancellation
+            .as_ref()
+            .is_some_and(|token| token.is_cancelled())
+    }
+
+    /// フルインデックス中のメモリの上限を設定
+    ///
+    /// 上限があると、ファイルごとの結果をグラフに溜めずにシャードへ書き出し、
+    /// 全ファイルを処理してからシャードを読んで保存するグラフを組み立てる。
+    /// 差分インデックスは既存のグラフを読み込んで更新するので対象外。
+    pub fn set_memory_budget(&mut self, budget: MemoryBudget) {
+        self.memory_budget = Some(budget);
+    }
+
     /// フォールバックインデクサーのみを使用するモードを設定
     pub fn set_fallback_only(&mut self, fallback_only: bool) {
         self.fallback_only = fallback_only;
@@ -249,6 +317,21 @@ impl DifferentialIndexer {
         use lsp::adapter::lsp::get_language_id;
         let language_id = get_language_id(path).unwrap_or_else(|| "unknown".to_string());
 
+        // 同じ内容・同じサーバーの結果が永続キャッシュにあればLSPを呼ばない
+        let content_hash = self.git_detector.calculate_file_hash(path).ok();
+        if let Some(server) = self.lsp_pool.server_identity_for_language(&language_id) {
+            if let Some(lsp_symbols) =
+                self.cached_document_symbols(&server, content_hash.as_deref())
+            {
+                debug!(
+                    "LSP result cache hit for {} ({} symbols)",
+                    path.display(),
+                    lsp_symbols.len()
+                );
+                return Ok(self.convert_lsp_symbols_to_core(&lsp_symbols, path));
+            }
+        }
+
         // LSPがドキュメントシンボルをサポートしているかチェック
         if !self
             .lsp_pool
@@ -269,9 +352,24 @@ impl DifferentialIndexer {
                 match client_arc.lock() {
                     Ok(mut client) => {
                         debug!("Successfully locked LSP client, requesting symbols");
+                        let server = ServerIdentity::from_server_info(
+                            &language_id,
+                            client.get_server_info(),
+                        );
+                        if let Some(lsp_symbols) =
+                            self.cached_document_symbols(&server, content_hash.as_deref())
+                        {
+                            return Ok(self.convert_lsp_symbols_to_core(&lsp_symbols, path));
+                        }
+
                         // ドキュメントシンボルを取得
                         match client.get_document_symbols(&file_uri) {
                             Ok(lsp_symbols) => {
+                                self.store_document_symbols(
+                                    &server,
+                                    content_hash.as_deref(),
+                                    &lsp_symbols,
+                                );
                                 info!(
                                     "LSP extracted {} symbols from {} in {:?}",
                                     lsp_symbols.len(),
@@ -319,73 +417,105 @@ impl DifferentialIndexer {
         }
     }
 
-    /// ファイルからシンボルを抽出（フォールバック）
-    fn extract_symbols_with_fallback(&self, path: &Path) -> Result<Vec<Symbol>> {
-        use lsp::fallback_indexer::FallbackIndexer;
-
-        info!("Using fallback indexer for: {}", path.display());
+    /// LSP応答の永続キャッシュを取得（初回に開く。`LSIF_NO_LSP_CACHE`で無効化）
+    fn lsp_result_cache(&mut self) -> Option<&LspResultCache> {
+        if !self.lsp_result_cache_opened {
+            self.lsp_result_cache_opened = true;
+            if std::env::var("LSIF_NO_LSP_CACHE").is_err() {
+                match LspResultCache::open_default() {
+                    Ok(cache) => self.lsp_result_cache = Some(cache),
+                    Err(e) => warn!("LSP result cache disabled: {}", e),
+                }
+            }
+        }
+        self.lsp_result_cache.as_ref()
+    }
 
-        if let Some(fallback) = FallbackIndexer::from_extension(path) {
-            debug!(
-                "Fallback indexer found for extension: {:?}",
-                path.extension()
-            );
+    fn document_symbol_cache_key(
+        &self,
+        server: &ServerIdentity,
+        content_hash: &str,
+    ) -> ResultCacheKey {
+        ResultCacheKey::new(
+            server.clone(),
+            content_hash,
+            &self.dependency_fingerprint,
+            CachedRequest::DocumentSymbol,
+        )
+    }
 
-            match fallback.extract_symbols(path) {
-                Ok(lsp_symbols) => {
-                    info!(
-                        "Fallback indexer extracted {} symbols from {}",
-                        lsp_symbols.len(),
-                        path.display()
-                    );
+    /// キャッシュ済みのdocumentSymbol応答を取得
+    fn cached_document_symbols(
+        &mut self,
+        server: &ServerIdentity,
+        content_hash: Option<&str>,
+    ) -> Option<Vec<lsp_types::DocumentSymbol>> {
+        let key = self.document_symbol_cache_key(server, content_hash?);
+        self.lsp_result_cache()?.get(&key)
+    }
 
-                    // LSPシンボルをコアのSymbol型に変換
-                    let symbols = self.convert_lsp_symbols_to_core(&lsp_symbols, path);
-                    debug!(
-                        "Converted {} fallback symbols to core symbols",
-                        symbols.len()
-                    );
+    /// documentSymbol応答をキャッシュに保存
+    fn store_document_symbols(
+        &mut self,
+        server: &ServerIdentity,
+        content_hash: Option<&str>,
+        lsp_symbols: &[lsp_types::DocumentSymbol],
+    ) {
+        let key = match content_hash {
+            Some(content_hash) => self.document_symbol_cache_key(server, content_hash),
+            None => return,
+        };
+        if let Some(cache) = self.lsp_result_cache() {
+            if let Err(e) = cache.put(&key, &lsp_symbols) {
+                warn!("Failed to store LSP result in cache: {}", e);
+            }
+        }
+    }
 
-                    // 各シンボルをログ出力
-                    for symbol in &symbols {
-                        debug!(
-                            "  - {} ({:?}) at {}:{}",
-                            symbol.name,
-                            symbol.kind,
-                            symbol.range.start.line + 1,
-                            symbol.range.start.character + 1
-                        );
-                    }
+    /// ファイルからシンボルを抽出（フォールバック）
+    ///
+    /// tree-sitterを優先し、文法がない・失敗した場合のみ正規表現を使う。
+    fn extract_symbols_with_fallback(&self, path: &Path) -> Result<Vec<Symbol>> {
+        info!("Using fallback extraction for: {}", path.display());
 
-                    Ok(symbols)
-                }
-                Err(e) => {
-                    warn!("Fallback indexer failed for {}: {}", path.display(), e);
-                    Ok(Vec::new())
-                }
-            }
-        } else {
-            // フォールバックも使えない場合は空のリストを返す
-            warn!(
-                "No indexer available for file: {} (extension: {:?})",
-                path.display(),
-                path.extension()
+        let symbols = ChainedSymbolExtractor::with_fallbacks().extract(path)?;
+        for symbol in &symbols {
+            debug!(
+                "  - {} ({:?}) at {}:{}",
+                symbol.name,
+                symbol.kind,
+                symbol.range.start.line + 1,
+                symbol.range.start.character + 1
             );
-            Ok(Vec::new())
         }
+
+        Ok(symbols)
     }
 
     /// LSPのDocumentSymbolをコアのSymbol型に変換
+    ///
+    /// detailはシグネチャなので、ドキュメントコメントはソースから取り出して付ける。
     fn convert_lsp_symbols_to_core(
         &self,
         lsp_symbols: &[lsp_types::DocumentSymbol],
         path: &Path,
+    ) -> Vec<Symbol> {
+        let docs = SourceDocComments::load(path);
+        self.convert_lsp_symbol_tree(lsp_symbols, None, path, &docs)
+    }
+
+    fn convert_lsp_symbol_tree(
+        &self,
+        lsp_symbols: &[lsp_types::DocumentSymbol],
+        parent: Option<&lsp_types::DocumentSymbol>,
+        path: &Path,
+        docs: &SourceDocComments,
     ) -> Vec<Symbol> {
         let mut symbols = Vec::new();
         let path_str = path.to_string_lossy().to_string();
 
         for lsp_symbol in lsp_symbols {
-            let symbol = Symbol {
+            let mut symbol = Symbol {
                 id: format!(
                     "{}#{}:{}",
                     path_str,
@@ -405,14 +535,23 @@ impl DifferentialIndexer {
                         character: lsp_symbol.range.end.character,
                     },
                 },
-                documentation: lsp_symbol.detail.clone(),
-                detail: None,
+                documentation: None,
+                // goplsのシグネチャなど（完全修飾名や型による検索で使う）
+                detail: lsp_symbol.detail.clone(),
+                modifiers: document_symbol_modifiers(lsp_symbol, &path_str, parent),
+                qualified_name: None,
             };
+            docs.attach(lsp_symbol, &mut symbol);
             symbols.push(symbol);
 
             // 子シンボルも処理
             if let Some(children) = &lsp_symbol.children {
-                symbols.extend(self.convert_lsp_symbols_to_core(children, path));
+                symbols.extend(self.convert_lsp_symbol_tree(
+                    children,
+                    Some(lsp_symbol),
+                    path,
+                    docs,
+                ));
             }
         }
 
@@ -518,8 +657,15 @@ impl DifferentialIndexer {
             change_ratio * 100.0
         );
 
+        // 中断された実行の続きは、処理済みのファイルを飛ばすためにフルインデックスにしない
+        let resuming = self
+            .metadata
+            .as_ref()
+            .is_some_and(|m| m.last_commit.is_none() && m.hash_cache_path.is_none());
+
         // 差分が50%以上の場合はフルインデックスを実行
-        let (changes, full_reindex) = if change_ratio >= 0.5 && self.metadata.is_some() {
+        let (changes, full_reindex) = if change_ratio >= 0.5 && self.metadata.is_some() && !resuming
+        {
             warn!(
                 "Change ratio {:.1}% >= 50%, performing full reindex",
                 change_ratio * 100.0
@@ -533,6 +679,57 @@ impl DifferentialIndexer {
             (changes, false)
         };
 
+        // 前回までに失敗したファイルは、Gitの差分に出てこなくても再試行する
+        let changes = self.with_failed_files(changes);
+
+        // メモリの上限があるフルインデックスは、結果をシャードに書き出して最後に組み立てる
+        let spill = self.metadata.is_none() && self.memory_budget.is_some();
+
+        // 既存のCodeGraphを読み込むか新規作成
+        let mut graph = if spill {
+            CodeGraph::new()
+        } else {
+            self.storage.load_graph()?.unwrap_or_else(CodeGraph::new)
+        };
+        let mut new_file_hashes = HashMap::new();
+
+        // 全ファイルを処理する時はチェックポイントを書き、中断されていればその続きから処理する
+        let mut checkpoint = None;
+        let mut resumed_files = 0;
+        if self.metadata.is_none() {
+            let mut checkpointer = match Checkpointer::resume(&self.storage, &changes)? {
+                Some((resumed, hashes)) => {
+                    resumed_files = resumed.completed_count();
+                    info!(
+                        "Resuming from checkpoint ({} files already indexed)",
+                        resumed_files
+                    );
+                    if !spill {
+                        resumed.replay(&self.storage, &mut graph)?;
+                    }
+                    new_file_hashes.extend(hashes);
+                    resumed
+                }
+                None => Checkpointer::new(),
+            };
+            if let Some(budget) = self.memory_budget {
+                checkpointer.set_memory_budget(budget);
+            }
+            checkpoint = Some(checkpointer);
+        }
+        // 処理済みのファイルと、失敗を繰り返している（内容の変わっていない）ファイルは飛ばす
+        let changes: Vec<FileChange> = changes
+            .into_iter()
+            .filter(|change| {
+                !checkpoint
+                    .as_ref()
+                    .is_some_and(|checkpoint| checkpoint.should_skip(&change.path))
+                    && !self
+                        .failures
+                        .should_skip(&change.path, change.content_hash.as_deref())
+            })
+            .collect();
+
         let total_files = changes.len();
 
         // プログレスバーの設定
@@ -562,16 +759,19 @@ impl DifferentialIndexer {
             deleted_symbols: Vec::new(),
             full_reindex,
             change_ratio,
+            interrupted: false,
+            resumed_files,
+            failed_files: Vec::new(),
         };
 
-        // 既存のCodeGraphを読み込むか新規作成
-        let mut graph = self
-            .storage
-            .load_data::<CodeGraph>("graph")?
-            .unwrap_or_else(CodeGraph::new);
+        // 参照を張り直すファイル（変更したファイルと、そのシンボルを参照していたファイル）
+        let mut relink_files: HashSet<PathBuf> = changes
+            .iter()
+            .filter(|change| !matches!(change.status, FileChangeStatus::Deleted))
+            .map(|change| change.path.clone())
+            .collect();
 
         // ファイルごとに処理（並列処理対応）
-        let mut new_file_hashes = HashMap::new();
         let mut processed_count = 0;
 
         // 並列処理の閾値を確認（言語最適化設定を使用）
@@ -583,249 +783,252 @@ impl DifferentialIndexer {
             // 並列処理モード
             use rayon::prelude::*;
 
-            use lsp::fallback_indexer::FallbackIndexer;
-
             eprintln!("⚡ Using parallel processing for {} files", total_files);
 
-            // シンボル抽出を並列化
             let optimization_strategy = &self.optimization_strategy;
-            let symbols_results: Vec<(PathBuf, FileChangeStatus, Option<Vec<Symbol>>)> = changes
-                .par_iter()
-                .map(|change| {
-                    // 言語最適化によるファイルスキップ判定
-                    if let Some(strategy) =
-                        optimization_strategy.get_strategy_for_file(&change.path)
-                    {
-                        if strategy.should_skip_file(&change.path) {
-                            debug!(
-                                "Skipping file based on optimization strategy: {}",
-                                change.path.display()
-                            );
-                            return (change.path.clone(), change.status.clone(), None);
+            let fallback_chain = ChainedSymbolExtractor::with_fallbacks();
+            let cancellation = self.cancellation.clone();
+
+            // チェックポイントの間隔ごとに区切って処理する（メモリの上限があればさらに小さく）
+            let mut remaining = changes.as_slice();
+            while !remaining.is_empty() {
+                let chunk_size = checkpoint
+                    .as_ref()
+                    .map_or(total_files, Checkpointer::chunk_size)
+                    .clamp(1, remaining.len());
+                let (chunk, rest) = remaining.split_at(chunk_size);
+                remaining = rest;
+
+                // シンボル抽出を並列化
+                type Extracted = (
+                    PathBuf,
+                    FileChangeStatus,
+                    Option<Vec<Symbol>>,
+                    Option<anyhow::Error>,
+                );
+                let symbols_results: Vec<Extracted> = chunk
+                    .par_iter()
+                    .filter_map(|change| {
+                        // 中断されたら未着手のファイルは飛ばす（次の実行で処理する）
+                        if cancellation
+                            .as_ref()
+                            .is_some_and(|token| token.is_cancelled())
+                        {
+                            return None;
+                        }
+
+                        // 言語最適化によるファイルスキップ判定
+                        if let Some(strategy) =
+                            optimization_strategy.get_strategy_for_file(&change.path)
+                        {
+                            if strategy.should_skip_file(&change.path) {
+                                debug!(
+                                    "Skipping file based on optimization strategy: {}",
+                                    change.path.display()
+                                );
+                                return Some((
+                                    change.path.clone(),
+                                    change.status.clone(),
+                                    None,
+                                    None,
+                                ));
+                            }
                         }
-                    }
 
-                    let symbols = match &change.status {
-                        FileChangeStatus::Added
-                        | FileChangeStatus::Modified
-                        | FileChangeStatus::Renamed { .. }
-                        | FileChangeStatus::Untracked => {
-                            // ファイルごとにフォールバックインデクサーを作成
-                            match FallbackIndexer::from_extension(&change.path) {
-                                Some(indexer) => {
-                                    match indexer.extract_symbols(&change.path) {
-                                        Ok(doc_symbols) => {
-                                            // DocumentSymbolをSymbolに変換
-                                            let _file_uri =
-                                                format!("file://{}", change.path.display());
-                                            let symbols: Vec<Symbol> = doc_symbols
-                                                .into_iter()
-                                                .map(|doc_sym| {
-                                                    let file_path =
-                                                        change.path.to_string_lossy().to_string();
-                                                    Symbol {
-                                                        id: format!(
-                                                            "{}#{}:{}",
-                                                            file_path,
-                                                            doc_sym.range.start.line,
-                                                            doc_sym.name
-                                                        ),
-                                                        name: doc_sym.name,
-                                                        kind: match doc_sym.kind {
-                                                            lsp_types::SymbolKind::FUNCTION => {
-                                                                SymbolKind::Function
-                                                            }
-                                                            lsp_types::SymbolKind::CLASS => {
-                                                                SymbolKind::Class
-                                                            }
-                                                            lsp_types::SymbolKind::INTERFACE => {
-                                                                SymbolKind::Interface
-                                                            }
-                                                            lsp_types::SymbolKind::STRUCT => {
-                                                                SymbolKind::Class
-                                                            }
-                                                            lsp_types::SymbolKind::ENUM => {
-                                                                SymbolKind::Enum
-                                                            }
-                                                            lsp_types::SymbolKind::CONSTANT => {
-                                                                SymbolKind::Constant
-                                                            }
-                                                            lsp_types::SymbolKind::VARIABLE => {
-                                                                SymbolKind::Variable
-                                                            }
-                                                            lsp_types::SymbolKind::METHOD => {
-                                                                SymbolKind::Function
-                                                            }
-                                                            lsp_types::SymbolKind::PROPERTY => {
-                                                                SymbolKind::Property
-                                                            }
-                                                            _ => SymbolKind::Variable,
-                                                        },
-                                                        file_path: file_path.clone(),
-                                                        range: lsif_core::Range {
-                                                            start: lsif_core::Position {
-                                                                line: doc_sym.range.start.line,
-                                                                character: doc_sym
-                                                                    .range
-                                                                    .start
-                                                                    .character,
-                                                            },
-                                                            end: lsif_core::Position {
-                                                                line: doc_sym.range.end.line,
-                                                                character: doc_sym
-                                                                    .range
-                                                                    .end
-                                                                    .character,
-                                                            },
-                                                        },
-                                                        documentation: doc_sym.detail,
-                                                        detail: None,
-                                                    }
-                                                })
-                                                .collect();
-                                            Some(symbols)
-                                        }
-                                        Err(e) => {
-                                            warn!(
-                                                "Failed to extract symbols from {}: {}",
-                                                change.path.display(),
-                                                e
-                                            );
-                                            None
-                                        }
+                        let mut error = None;
+                        let symbols = match &change.status {
+                            FileChangeStatus::Added
+                            | FileChangeStatus::Modified
+                            | FileChangeStatus::Renamed { .. }
+                            | FileChangeStatus::Untracked => {
+                                // ファイルごとにフォールバックチェーンで抽出
+                                match fallback_chain.extract(&change.path) {
+                                    Ok(symbols) if !symbols.is_empty() => Some(symbols),
+                                    Ok(_) => {
+                                        debug!("No fallback symbols for {}", change.path.display());
+                                        None
+                                    }
+                                    Err(e) => {
+                                        warn!(
+                                            "Failed to extract symbols from {}: {}",
+                                            change.path.display(),
+                                            e
+                                        );
+                                        error = Some(e);
+                                        None
                                     }
-                                }
-                                None => {
-                                    debug!("No fallback indexer for {}", change.path.display());
-                                    None
                                 }
                             }
-                        }
-                        FileChangeStatus::Deleted => None,
-                    };
-                    (change.path.clone(), change.status.clone(), symbols)
-                })
-                .collect();
+                            FileChangeStatus::Deleted => None,
+                        };
+                        Some((change.path.clone(), change.status.clone(), symbols, error))
+                    })
+                    .collect();
+                result.interrupted = symbols_results.len() < chunk.len();
+
+                // グラフとハッシュの更新
+                for (path, status, symbols_opt, error) in symbols_results {
+                    let content_hash = chunk
+                        .iter()
+                        .find(|c| c.path == path)
+                        .and_then(|change| change.content_hash.clone());
+
+                    // 失敗したファイルは既存のシンボルを残し、ハッシュを記録せずに次の実行で再試行する
+                    if let Some(e) = &error {
+                        self.failures
+                            .record_failure(&path, content_hash.as_deref(), e);
+                        continue;
+                    }
+                    self.failures.forget(&path);
 
-            // グラフとハッシュの更新
-            for (path, status, symbols_opt) in symbols_results {
-                // ハッシュを記録
-                if let Some(change) = changes.iter().find(|c| c.path == path) {
-                    if let Some(ref hash) = change.content_hash {
+                    // ハッシュを記録
+                    if let Some(ref hash) = content_hash {
                         new_file_hashes.insert(path.clone(), hash.clone());
                     }
-                }
 
-                // プログレス表示
-                processed_count += 1;
-                if total_files > 10 && processed_count % 10 == 0 {
-                    eprintln!(
-                        "  ⚡ Processed {}/{} files ({:.0}%)",
-                        processed_count,
-                        total_files,
-                        (processed_count as f64 / total_files as f64) * 100.0
-                    );
-                }
+                    // プログレス表示
+                    processed_count += 1;
+                    if total_files > 10 && processed_count % 10 == 0 {
+                        eprintln!(
+                            "  ⚡ Processed {}/{} files ({:.0}%)",
+                            processed_count,
+                            total_files,
+                            (processed_count as f64 / total_files as f64) * 100.0
+                        );
+                    }
 
-                match status {
-                    FileChangeStatus::Added | FileChangeStatus::Untracked => {
-                        result.files_added += 1;
+                    match status {
+                        FileChangeStatus::Added | FileChangeStatus::Untracked => {
+                            result.files_added += 1;
+
+                            if let Some(checkpoint) = checkpoint.as_mut() {
+                                checkpoint.record_file(
+                                    &path,
+                                    content_hash,
+                                    symbols_opt.as_deref().unwrap_or_default(),
+                                );
+                                if checkpoint.is_due() {
+                                    checkpoint.save(&self.storage)?;
+                                }
+                            }
 
-                        if let Some(symbols) = symbols_opt {
-                            result.symbols_added += symbols.len();
+                            if let Some(symbols) = symbols_opt {
+                                result.symbols_added += symbols.len();
+
+                                for symbol in &symbols {
+                                    if result.added_symbols.len() < 20 {
+                                        result.added_symbols.push(SymbolSummary {
+                                            name: symbol.name.clone(),
+                                            kind: symbol.kind,
+                                            file_path: symbol.file_path.clone(),
+                                            line: symbol.range.start.line,
+                                        });
+                                    }
+                                }
+                                // 書き出したシンボルは最後にシャードから組み立てる
+                                if !spill {
+                                    for symbol in &symbols {
+                                        graph.add_symbol(symbol.clone());
+                                    }
+                                    graph.link_containment(&symbols);
+                                }
 
-                            for symbol in symbols {
-                                if result.added_symbols.len() < 20 {
-                                    result.added_symbols.push(SymbolSummary {
+                                // 参照の追加は並列処理モードではスキップ（非常に重いため）
+                                // 必要に応じて後で別途実行可能
+                            }
+                        }
+                        FileChangeStatus::Modified | FileChangeStatus::Renamed { .. } => {
+                            result.files_modified += 1;
+
+                            // 既存シンボルの削除
+                            let path_str = path.to_string_lossy();
+                            relink_files.extend(referencing_files(&graph, &path_str));
+                            let old_symbols: Vec<_> = graph
+                                .get_all_symbols()
+                                .filter(|s| s.file_path == path_str)
+                                .cloned()
+                                .collect();
+
+                            for symbol in &old_symbols {
+                                if result.deleted_symbols.len() < 20 {
+                                    result.deleted_symbols.push(SymbolSummary {
                                         name: symbol.name.clone(),
                                         kind: symbol.kind,
                                         file_path: symbol.file_path.clone(),
                                         line: symbol.range.start.line,
                                     });
                                 }
-                                graph.add_symbol(symbol);
+                                graph.remove_symbol(&symbol.id);
                             }
 
-                            // 参照の追加は並列処理モードではスキップ（非常に重いため）
-                            // 必要に応じて後で別途実行可能
-                        }
-                    }
-                    FileChangeStatus::Modified | FileChangeStatus::Renamed { .. } => {
-                        result.files_modified += 1;
+                            result.symbols_deleted += old_symbols.len();
 
-                        // 既存シンボルの削除
-                        let path_str = path.to_string_lossy();
-                        let old_symbols: Vec<_> = graph
-                            .get_all_symbols()
-                            .filter(|s| s.file_path == path_str)
-                            .cloned()
-                            .collect();
+                            // 新規シンボルの追加
+                            if let Some(symbols) = symbols_opt {
+                                result.symbols_updated += symbols.len();
 
-                        for symbol in &old_symbols {
-                            if result.deleted_symbols.len() < 20 {
-                                result.deleted_symbols.push(SymbolSummary {
-                                    name: symbol.name.clone(),
-                                    kind: symbol.kind,
-                                    file_path: symbol.file_path.clone(),
-                                    line: symbol.range.start.line,
-                                });
+                                for symbol in &symbols {
+                                    if result.added_symbols.len() < 20 {
+                                        result.added_symbols.push(SymbolSummary {
+                                            name: symbol.name.clone(),
+                                            kind: symbol.kind,
+                                            file_path: symbol.file_path.clone(),
+                                            line: symbol.range.start.line,
+                                        });
+                                    }
+                                    graph.add_symbol(symbol.clone());
+                                }
+                                graph.link_containment(&symbols);
+
+                                // 参照の追加はスキップ（非常に重いため）
+                                // if let Err(e) = self.add_references_to_graph(&mut graph, &path) {
+                                //     warn!("Failed to add references for {}: {}", path.display(), e);
+                                // }
                             }
-                            graph.remove_symbol(&symbol.id);
                         }
-
-                        result.symbols_deleted += old_symbols.len();
-
-                        // 新規シンボルの追加
-                        if let Some(symbols) = symbols_opt {
-                            result.symbols_updated += symbols.len();
-
-                            for symbol in symbols {
-                                if result.added_symbols.len() < 20 {
-                                    result.added_symbols.push(SymbolSummary {
+                        FileChangeStatus::Deleted => {
+                            result.files_deleted += 1;
+
+                            let path_str = path.to_string_lossy();
+                            let old_symbols: Vec<_> = graph
+                                .get_all_symbols()
+                                .filter(|s| s.file_path == path_str)
+                                .cloned()
+                                .collect();
+
+                            for symbol in &old_symbols {
+                                if result.deleted_symbols.len() < 20 {
+                                    result.deleted_symbols.push(SymbolSummary {
                                         name: symbol.name.clone(),
                                         kind: symbol.kind,
                                         file_path: symbol.file_path.clone(),
                                         line: symbol.range.start.line,
                                     });
                                 }
-                                graph.add_symbol(symbol);
+                                graph.remove_symbol(&symbol.id);
                             }
 
-                            // 参照の追加はスキップ（非常に重いため）
-                            // if let Err(e) = self.add_references_to_graph(&mut graph, &path) {
-                            //     warn!("Failed to add references for {}: {}", path.display(), e);
-                            // }
+                            result.symbols_deleted += old_symbols.len();
                         }
                     }
-                    FileChangeStatus::Deleted => {
-                        result.files_deleted += 1;
-
-                        let path_str = path.to_string_lossy();
-                        let old_symbols: Vec<_> = graph
-                            .get_all_symbols()
-                            .filter(|s| s.file_path == path_str)
-                            .cloned()
-                            .collect();
-
-                        for symbol in &old_symbols {
-                            if result.deleted_symbols.len() < 20 {
-                                result.deleted_symbols.push(SymbolSummary {
-                                    name: symbol.name.clone(),
-                                    kind: symbol.kind,
-                                    file_path: symbol.file_path.clone(),
-                                    line: symbol.range.start.line,
-                                });
-                            }
-                            graph.remove_symbol(&symbol.id);
-                        }
+                }
 
-                        result.symbols_deleted += old_symbols.len();
-                    }
+                if result.interrupted {
+                    break;
+                }
+                if let Some(checkpoint) = checkpoint.as_mut() {
+                    checkpoint.save(&self.storage)?;
                 }
             }
         } else {
             // シーケンシャル処理（小規模プロジェクト用）
             for change in changes {
+                // 中断されたら処理済みのファイルだけを保存する
+                if self.is_cancelled() {
+                    result.interrupted = true;
+                    break;
+                }
+
                 // コンテンツハッシュを記録
                 if let Some(ref hash) = change.content_hash {
                     new_file_hashes.insert(change.path.clone(), hash.clone());
@@ -868,7 +1071,26 @@ impl DifferentialIndexer {
                         }
 
                         let extraction_start = Instant::now();
-                        let symbols = self.extract_symbols_from_file(&change.path)?;
+                        let symbols = match self.extract_symbols_from_file(&change.path) {
+                            Ok(symbols) => symbols,
+                            // 失敗を記録して続ける（ハッシュを残さず、次の実行で再試行する）
+                            Err(e) => {
+                                warn!(
+                                    "Failed to extract symbols from {}: {}",
+                                    change.path.display(),
+                                    e
+                                );
+                                self.failures.record_failure(
+                                    &change.path,
+                                    change.content_hash.as_deref(),
+                                    &e,
+                                );
+                                new_file_hashes.remove(&change.path);
+                                result.files_added -= 1;
+                                continue;
+                            }
+                        };
+                        self.failures.forget(&change.path);
                         let extraction_time = extraction_start.elapsed();
 
                         info!(
@@ -896,7 +1118,7 @@ impl DifferentialIndexer {
                         result.symbols_added += symbols.len();
 
                         // グラフにシンボルを追加し、サマリーを記録
-                        for symbol in symbols {
+                        for symbol in &symbols {
                             info!(
                                 "Adding symbol to graph: {} (id: {}, kind: {:?})",
                                 symbol.name, symbol.id, symbol.kind
@@ -912,9 +1134,25 @@ impl DifferentialIndexer {
                                 });
                             }
 
-                            // グラフにシンボルを実際に追加
-                            graph.add_symbol(symbol.clone());
-                            debug!("Symbol added to graph successfully");
+                            // グラフにシンボルを実際に追加（書き出す時は最後にシャードから組み立てる）
+                            if !spill {
+                                graph.add_symbol(symbol.clone());
+                                debug!("Symbol added to graph successfully");
+                            }
+                        }
+                        if !spill {
+                            graph.link_containment(&symbols);
+                        }
+
+                        if let Some(checkpoint) = checkpoint.as_mut() {
+                            checkpoint.record_file(
+                                &change.path,
+                                change.content_hash.clone(),
+                                &symbols,
+                            );
+                            if checkpoint.is_due() {
+                                checkpoint.save(&self.storage)?;
+                            }
                         }
 
                         // 参照を検出してエッジを追加
@@ -925,10 +1163,30 @@ impl DifferentialIndexer {
                         // }
                     }
                     FileChangeStatus::Modified | FileChangeStatus::Renamed { .. } => {
+                        // 抽出に失敗したら既存のシンボルを残し、次の実行で再試行する
+                        let symbols = match self.extract_symbols_from_file(&change.path) {
+                            Ok(symbols) => symbols,
+                            Err(e) => {
+                                warn!(
+                                    "Failed to extract symbols from {}: {}",
+                                    change.path.display(),
+                                    e
+                                );
+                                self.failures.record_failure(
+                                    &change.path,
+                                    change.content_hash.as_deref(),
+                                    &e,
+                                );
+                                new_file_hashes.remove(&change.path);
+                                continue;
+                            }
+                        };
+                        self.failures.forget(&change.path);
                         result.files_modified += 1;
 
                         // 既存のシンボルを削除
                         let path_str = change.path.to_string_lossy();
+                        relink_files.extend(referencing_files(&graph, &path_str));
                         let old_symbols: Vec<_> = graph
                             .get_all_symbols()
                             .filter(|s| s.file_path == path_str)
@@ -950,10 +1208,9 @@ impl DifferentialIndexer {
                         result.symbols_deleted += old_symbols.len();
 
                         // 新しいシンボルを追加
-                        let symbols = self.extract_symbols_from_file(&change.path)?;
                         result.symbols_updated += symbols.len();
 
-                        for symbol in symbols {
+                        for symbol in &symbols {
                             // サマリーを記録（最大20件まで）
                             if result.added_symbols.len() < 20 {
                                 result.added_symbols.push(SymbolSummary {
@@ -963,8 +1220,9 @@ impl DifferentialIndexer {
                                     line: symbol.range.start.line,
                                 });
                             }
-                            graph.add_symbol(symbol);
+                            graph.add_symbol(symbol.clone());
                         }
+                        graph.link_containment(&symbols);
 
                         // 参照を検出してエッジを追加
                         // 参照の追加はスキップ（非常に重いため）
@@ -972,6 +1230,7 @@ impl DifferentialIndexer {
                     }
                     FileChangeStatus::Deleted => {
                         result.files_deleted += 1;
+                        self.failures.forget(&change.path);
 
                         // シンボルを削除
                         let path_str = change.path.to_string_lossy();
@@ -999,6 +1258,9 @@ impl DifferentialIndexer {
             }
         } // else節を閉じる
 
+        // 失敗したファイルは、前回までのものや再試行をやめたものも含めて報告する
+        result.failed_files = self.failures.failures();
+
         // プログレスバー完了
         if let Some(pb) = progress_bar {
             pb.finish_with_message(format!(
@@ -1011,6 +1273,28 @@ impl DifferentialIndexer {
             ));
         }
 
+        // 全てのシンボルが揃ってから参照を張る（書き出したシャードは組み立てる時に張る。
+        // 全ファイルの処理が中断された時はグラフを保存しないので張らない）
+        if !spill && !(result.interrupted && checkpoint.is_some()) {
+            let files: Vec<PathBuf> = if checkpoint.is_some() {
+                // 全ファイルの処理では、再開前に処理したファイルも含める
+                graph
+                    .get_all_symbols()
+                    .map(|symbol| symbol.file_path.as_str())
+                    .collect::<HashSet<_>>()
+                    .into_iter()
+                    .map(PathBuf::from)
+                    .collect()
+            } else {
+                relink_files.into_iter().collect()
+            };
+            let linked = link_references(&mut graph, &files);
+            debug!("Linked {} references in {} files", linked, files.len());
+        }
+
+        // 親のスコープが揃ってから完全修飾名を付ける
+        qualify_symbols(&mut graph, &self.project_root);
+
         // CodeGraphを保存
         info!(
             "Saving CodeGraph with {} symbols to database",
@@ -1026,19 +1310,6 @@ impl DifferentialIndexer {
             );
         }
 
-        match self.storage.save_data("graph", &graph) {
-            Ok(()) => {
-                info!(
-                    "CodeGraph with {} symbols saved successfully to database",
-                    graph.symbol_count()
-                );
-            }
-            Err(e) => {
-                error!("Failed to save CodeGraph: {}", e);
-                return Err(e);
-            }
-        }
-
         // ファイルハッシュを保存
         if let Some(ref mut metadata) = self.metadata {
             metadata.file_content_hashes.extend(new_file_hashes.clone());
@@ -1048,10 +1319,36 @@ impl DifferentialIndexer {
             );
         }
 
-        // メタデータを更新
-        debug!("Updating metadata...");
-        self.update_metadata()?;
-        debug!("Metadata updated successfully");
+        // グラフとメタデータをまとめて保存
+        // （全ファイルの処理が中断された時は、前回のインデックスを残してチェックポイントだけ書く）
+        let committed = match (result.interrupted, checkpoint.as_mut()) {
+            (true, Some(checkpoint)) => checkpoint
+                .save(&self.storage)
+                .and_then(|()| self.failures.save(&self.storage)),
+            (true, None) => self.commit_partial_index(&graph, new_file_hashes),
+            // 書き出したシャードを読み直し、保存するグラフを部分ごとに書き込む
+            (false, Some(checkpoint)) if spill => checkpoint
+                .save(&self.storage)
+                .and_then(|()| checkpoint.write_graph(&self.storage, &self.project_root))
+                .and_then(|parts| self.commit_graph_parts(&parts)),
+            (false, _) => self.commit_index(&SerializedCodeGraph::from(&graph)),
+        };
+        if let Err(e) = committed {
+            error!("Failed to save CodeGraph: {}", e);
+            return Err(e);
+        }
+
+        self.persist_lsp_timing_stats();
+        if let Some(cache) = &self.lsp_result_cache {
+            if let Err(e) = cache.flush() {
+                warn!("Failed to flush LSP result cache: {}", e);
+            }
+        }
+
+        // 中断されたら言語サーバーを残さない
+        if result.interrupted {
+            self.lsp_pool.shutdown_all();
+        }
 
         result.duration = start.elapsed();
 
@@ -1066,6 +1363,23 @@ impl DifferentialIndexer {
         Ok(result)
     }
 
+    /// 今回学習したLSP応答時間を保存（失敗してもインデックス結果には影響させない）
+    fn persist_lsp_timing_stats(&self) {
+        if self.fallback_only {
+            return;
+        }
+
+        let learned = self.lsp_pool.collect_timing_stats();
+        if learned.is_empty() {
+            return;
+        }
+
+        match self.storage.merge_lsp_timing_stats(learned) {
+            Ok(()) => debug!("LSP timing stats saved"),
+            Err(e) => warn!("Failed to save LSP timing stats: {}", e),
+        }
+    }
+
     /// ファイルが変更されているかをハッシュで確認
     #[allow(dead_code)]
     fn is_file_changed(&self, path: &Path, new_hash: &str) -> Result<bool> {
@@ -1106,7 +1420,7 @@ impl DifferentialIndexer {
                 }
                 Err(e) => {
                     warn!("Fallback indexer failed for {}: {}", path.display(), e);
-                    Ok(Vec::new())
+                    Err(e)
                 }
             }
         } else {
@@ -1159,7 +1473,7 @@ impl DifferentialIndexer {
                                 start_time.elapsed().as_secs_f64(),
                                 e
                             );
-                            Ok(Vec::new())
+                            Err(e)
                         }
                     }
                 }
@@ -1188,7 +1502,7 @@ impl DifferentialIndexer {
                                 e,
                                 fallback_error
                             );
-                            Ok(Vec::new())
+                            Err(fallback_error.context(format!("LSP also failed: {}", e)))
                         }
                     }
                 }
@@ -1196,16 +1510,47 @@ impl DifferentialIndexer {
         }
     }
 
-    /// メタデータを更新
-    fn update_metadata(&mut self) -> Result<()> {
+    /// グラフとメタデータを保存
+    ///
+    /// グラフ・メタデータ・鮮度マニフェストは1つのトランザクションで書き込むので、
+    /// 中断されても前回のインデックスがそのまま残る。ハッシュキャッシュは一時ファイルに書き、
+    /// コミットしてから置き換える（その間に中断されても古いキャッシュで余分に再インデックスするだけ）。
+    fn commit_index(&mut self, graph: &SerializedCodeGraph) -> Result<()> {
+        self.commit_index_with(
+            count_indexed_files(&graph.symbols),
+            graph.symbols.len(),
+            |transaction| transaction.save_graph(graph),
+        )
+    }
+
+    /// `Checkpointer::write_graph`で部分ごとに書き込んだグラフをインデックスとして保存
+    fn commit_graph_parts(&mut self, parts: &GraphParts) -> Result<()> {
+        self.commit_index_with(parts.file_count, parts.symbol_count, |transaction| {
+            transaction.save_graph_parts(parts)
+        })
+    }
+
+    fn commit_index_with(
+        &mut self,
+        indexed_files: usize,
+        total_symbols: usize,
+        save_graph: impl FnOnce(&mut IndexTransaction<'_>) -> Result<()>,
+    ) -> Result<()> {
         // DBディレクトリ内にハッシュキャッシュを保存（プロジェクトルートではなく）
         let db_dir = self.storage.get_db_path()?;
         let hash_cache_path = db_dir.join("hash-cache.json");
+        let staged_hash_cache_path = db_dir.join("hash-cache.json.tmp");
 
         info!("Saving hash cache to: {:?}", hash_cache_path);
 
+        // 失敗したファイルはハッシュを残さず、次の実行で変更として再試行させる
+        let failed: Vec<PathBuf> = self.failures.paths().map(Path::to_path_buf).collect();
+        for path in &failed {
+            self.git_detector.remove_cached_hash(path);
+        }
+
         // ハッシュキャッシュを保存
-        self.git_detector.save_hash_cache(&hash_cache_path)?;
+        self.git_detector.save_hash_cache(&staged_hash_cache_path)?;
 
         // 現在のファイルハッシュを収集
         let mut file_content_hashes = self
@@ -1222,35 +1567,130 @@ impl DifferentialIndexer {
             .filter(|e| e.file_type().is_file())
         {
             let path = entry.path();
-            if path
-                .extension()
-                .and_then(|s| s.to_str())
-                .map(|ext| {
-                    ext == "rs" || ext == "ts" || ext == "js" || ext == "tsx" || ext == "jsx"
-                })
-                .unwrap_or(false)
-            {
+            if TreeSitterParser::supports_extension(path) && !self.failures.contains(path) {
                 if let Ok(hash) = self.git_detector.calculate_file_hash(path) {
                     file_content_hashes.insert(path.to_path_buf(), hash);
                 }
             }
         }
+        for path in &failed {
+            file_content_hashes.remove(path);
+        }
 
         let metadata = DifferentialIndexMetadata {
             last_indexed_at: Utc::now(),
             last_commit: self.git_detector.get_head_commit(),
-            indexed_files: self.count_indexed_files()?,
-            total_symbols: self.count_total_symbols()?,
+            indexed_files,
+            total_symbols,
             hash_cache_path: Some(hash_cache_path),
             file_content_hashes,
         };
 
-        self.storage
-            .save_data("__differential_metadata__", &metadata)?;
-        self.metadata = Some(metadata.clone());
-
         // IndexStorageのメタデータも更新
-        let storage_metadata = crate::storage::IndexMetadata {
+        let storage_metadata = self.storage_metadata(&metadata);
+
+        // 次のコマンドがハッシュを取らずに鮮度を判定できるように
+        let manifest =
+            StalenessManifest::capture(&self.project_root, metadata.file_content_hashes.keys());
+
+        let mut transaction = self.storage.transaction();
+        save_graph(&mut transaction)?;
+        transaction.save_data(METADATA_KEY, &metadata)?;
+        transaction.save_metadata(&storage_metadata)?;
+        transaction.save_staleness_manifest(&manifest)?;
+        self.failures.save_in(&mut transaction)?;
+        Checkpointer::discard_in(&self.storage, &mut transaction)?;
+        transaction.commit()?;
+        info!(
+            "CodeGraph with {} symbols saved successfully to database",
+            total_symbols
+        );
+
+        std::fs::rename(&staged_hash_cache_path, &hash_cache_path)?;
+        self.metadata = Some(metadata);
+
+        Ok(())
+    }
+
+    /// 前回までに抽出に失敗したファイルを変更に加える
+    ///
+    /// 失敗したファイルはハッシュを残していないが、内容が変わらなければGitの差分には
+    /// 出てこないので、ここで再試行の対象にする。
+    fn with_failed_files(&self, mut changes: Vec<FileChange>) -> Vec<FileChange> {
+        let known: HashSet<PathBuf> = changes.iter().map(|change| change.path.clone()).collect();
+        for path in self.failures.paths() {
+            if known.contains(path) {
+                continue;
+            }
+            let change = if path.exists() {
+                FileChange {
+                    path: path.to_path_buf(),
+                    status: FileChangeStatus::Modified,
+                    content_hash: self.git_detector.calculate_file_hash(path).ok(),
+                }
+            } else {
+                FileChange {
+                    path: path.to_path_buf(),
+                    status: FileChangeStatus::Deleted,
+                    content_hash: None,
+                }
+            };
+            changes.push(change);
+        }
+        changes
+    }
+
+    /// 中断された実行の結果を保存
+    ///
+    /// 処理済みのファイルのハッシュだけを記録し、次の実行ではGitの差分ではなくハッシュで
+    /// 変更を検出させて残りのファイルだけを処理する。鮮度マニフェストは消しておき、
+    /// 次のコマンドが必ず差分を検出するようにする。
+    fn commit_partial_index(
+        &mut self,
+        graph: &CodeGraph,
+        processed_hashes: HashMap<PathBuf, String>,
+    ) -> Result<()> {
+        let mut file_content_hashes = self
+            .metadata
+            .as_ref()
+            .map(|m| m.file_content_hashes.clone())
+            .unwrap_or_default();
+        file_content_hashes.extend(processed_hashes);
+        file_content_hashes.retain(|path, _| !self.failures.contains(path));
+
+        let metadata = DifferentialIndexMetadata {
+            last_indexed_at: Utc::now(),
+            last_commit: None,
+            indexed_files: count_indexed_files(graph.get_all_symbols()),
+            total_symbols: graph.symbol_count(),
+            hash_cache_path: None,
+            file_content_hashes,
+        };
+        let storage_metadata = self.storage_metadata(&metadata);
+
+        let mut transaction = self.storage.transaction();
+        transaction.save_graph(&SerializedCodeGraph::from(graph))?;
+        transaction.save_data(METADATA_KEY, &metadata)?;
+        transaction.save_metadata(&storage_metadata)?;
+        transaction.clear_staleness_manifest();
+        self.failures.save_in(&mut transaction)?;
+        transaction.commit()?;
+        info!(
+            "Partial CodeGraph with {} symbols saved ({} files hashed)",
+            graph.symbol_count(),
+            metadata.file_content_hashes.len()
+        );
+
+        self.metadata = Some(metadata);
+        Ok(())
+    }
+
+    /// IndexStorageのメタデータ
+    fn storage_metadata(
+        &self,
+        metadata: &DifferentialIndexMetadata,
+    ) -> crate::storage::IndexMetadata {
+        crate::storage::IndexMetadata {
             format: crate::storage::IndexFormat::Lsif,
             version: "1.0.0".to_string(),
             created_at: Utc::now(),
@@ -1263,11 +1703,7 @@ impl DifferentialIndexer {
                 .iter()
                 .map(|(k, v)| (k.to_string_lossy().to_string(), v.clone()))
                 .collect(),
-        };
-
-        self.storage.save_metadata(&storage_metadata)?;
-
-        Ok(())
+        }
     }
 
     /// 全ファイル数をカウント（プロジェクト内の対象ファイル）
@@ -1301,28 +1737,27 @@ impl DifferentialIndexer {
         Ok(count)
     }
 
-    /// インデックス済みファイル数をカウント
-    fn count_indexed_files(&self) -> Result<usize> {
+    /// 総シンボル数をカウント
+    fn count_total_symbols(&self) -> Result<usize> {
         // CodeGraphから取得
-        if let Some(graph) = self.storage.load_data::<CodeGraph>("graph")? {
-            let mut files = HashSet::new();
-            for symbol in graph.get_all_symbols() {
-                files.insert(symbol.file_path.clone());
-            }
-            Ok(files.len())
+        if let Some(graph) = self.storage.load_graph()? {
+            Ok(graph.symbol_count())
         } else {
             Ok(0)
         }
     }
 
-    /// 総シンボル数をカウント
-    fn count_total_symbols(&self) -> Result<usize> {
-        // CodeGraphから取得
-        if let Some(graph) = self.storage.load_data::<CodeGraph>("graph")? {
-            Ok(graph.symbol_count())
+    /// 中断されたフルインデックスをチェックポイントから再開する
+    ///
+    /// チェックポイントがなければ通常の差分インデックスと同じ。
+    pub fn resume(&mut self) -> Result<DifferentialIndexResult> {
+        if Checkpointer::exists(&self.storage)? {
+            // フルインデックスとして続きを処理する
+            self.metadata = None;
         } else {
-            Ok(0)
+            info!("No checkpoint to resume from, indexing changes instead");
         }
+        self.index_differential()
     }
 
     /// 完全再インデックス
@@ -1330,8 +1765,12 @@ impl DifferentialIndexer {
         info!("Performing full reindex...");
         let start = Instant::now();
 
-        // メタデータをクリア
+        // 中断された前回の続きではなく最初からやり直す
+        Checkpointer::discard(&self.storage)?;
+
+        // メタデータと失敗の記録をクリア（失敗を繰り返したファイルも再試行する）
         self.metadata = None;
+        self.failures = FailureLog::default();
 
         // フォールバックオンリーモードではworkspace/symbolを試さない
         if !self.fallback_only {
@@ -1352,6 +1791,9 @@ impl DifferentialIndexer {
                     deleted_symbols: Vec::new(),
                     full_reindex: true,
                     change_ratio: 1.0,
+                    interrupted: false,
+                    resumed_files: 0,
+                    failed_files: Vec::new(),
                 });
             }
             // workspace/symbolが使えない場合は通常の処理
@@ -1442,13 +1884,10 @@ impl DifferentialIndexer {
                     graph.symbol_count()
                 );
 
-                // ストレージに保存
-                self.storage.save_data("graph", &graph)?;
+                // グラフとメタデータをまとめて保存
+                self.commit_index(&SerializedCodeGraph::from(&graph))?;
                 info!("Saved {} symbols to storage", graph.symbol_count());
 
-                // メタデータを更新
-                self.update_metadata()?;
-
                 Ok(true)
             }
             Err(e) => {
@@ -1543,7 +1982,8 @@ impl DifferentialIndexer {
             let ext = path.extension().and_then(|s| s.to_str()).unwrap_or("");
             debug!("  -> File extension: '{}' for {}", ext, path.display());
 
-            if matches!(ext, "rs" | "ts" | "tsx" | "js" | "jsx") {
+            // tree-sitterの文法がある言語はすべて対象（LSPがなくても抽出できる）
+            if TreeSitterParser::supports_extension(path) {
                 info!("  -> Found source file: {}", path.display());
                 let content_hash = self.git_detector.calculate_file_hash(path).ok();
                 changes.push(FileChange {
@@ -1665,9 +2105,20 @@ impl DifferentialIndexer {
     }
 }
 
+/// シンボルのあるファイルの数
+fn count_indexed_files<'a>(symbols: impl IntoIterator<Item = &'a Symbol>) -> usize {
+    symbols
+        .into_iter()
+        .map(|symbol| symbol.file_path.as_str())
+        .collect::<HashSet<_>>()
+        .len()
+}
+
 #[cfg(test)]
 mod tests {
     use super::*;
+    use crate::index_checkpoint::MAX_FILE_ATTEMPTS;
+    use lsif_core::ReferenceAccess;
     use std::fs;
     use tempfile::TempDir;
 
@@ -1701,6 +2152,9 @@ mod tests {
             deleted_symbols: Vec::new(),
             full_reindex: false,
             change_ratio: 0.3,
+            interrupted: false,
+            resumed_files: 0,
+            failed_files: Vec::new(),
         };
 
         assert_eq!(result.files_added, 5);
@@ -1749,6 +2203,222 @@ mod tests {
         assert!(result.files_added > 0 || result.files_modified > 0);
     }
 
+    /// `from`という名前のシンボルから出ている参照（参照先の名前, 使われ方）
+    fn references_from(storage_path: &Path, from: &str) -> Vec<(String, Option<ReferenceAccess>)> {
+        use petgraph::visit::EdgeRef;
+
+        let graph = IndexStorage::open(storage_path)
+            .unwrap()
+            .load_graph()
+            .unwrap()
+            .unwrap();
+        let from = graph
+            .graph
+            .node_indices()
+            .find(|&idx| graph.graph[idx].name == from)
+            .unwrap();
+        graph
+            .graph
+            .edges_directed(from, petgraph::Direction::Outgoing)
+            .filter(|edge| edge.weight().kind == lsif_core::EdgeKind::Reference)
+            .map(|edge| {
+                (
+                    graph.graph[edge.target()].name.clone(),
+                    edge.weight().access(),
+                )
+            })
+            .collect()
+    }
+
+    #[test]
+    fn test_index_links_references_with_access() {
+        let temp_dir = TempDir::new().unwrap();
+        let project_root = temp_dir.path().join("project");
+        let storage_path = temp_dir.path().join("index.db");
+        fs::create_dir(&project_root).unwrap();
+        fs::write(
+            project_root.join("store.rs"),
+            "pub fn reset(count: &mut u32) {\n    *count = 0;\n}\n",
+        )
+        .unwrap();
+        fs::write(
+            project_root.join("main.rs"),
+            "mod store;\nuse store::reset;\n\nfn main() {\n    let mut hits = 1;\n    reset(&mut hits);\n}\n",
+        )
+        .unwrap();
+        fs::write(project_root.join("util.rs"), "pub fn noop() {}\n").unwrap();
+
+        let index = || {
+            let mut indexer = DifferentialIndexer::new(&storage_path, &project_root).unwrap();
+            indexer.set_fallback_only(true);
+            indexer.index_differential().unwrap()
+        };
+
+        index();
+        let expected = vec![("reset".to_string(), Some(ReferenceAccess::Call))];
+        assert_eq!(references_from(&storage_path, "main"), expected);
+
+        // 参照先のファイルだけを変えても、変えていないファイルからの参照を張り直す
+        fs::write(
+            project_root.join("store.rs"),
+            "\npub fn reset(count: &mut u32) {\n    *count = 0;\n}\n",
+        )
+        .unwrap();
+        let result = index();
+        assert_eq!(result.files_modified, 1);
+        assert_eq!(references_from(&storage_path, "main"), expected);
+    }
+
+    #[test]
+    fn test_spilled_index_links_references_across_shards() {
+        let temp_dir = TempDir::new().unwrap();
+        let project_root = temp_dir.path().join("project");
+        let storage_path = temp_dir.path().join("index.db");
+        fs::create_dir(&project_root).unwrap();
+        fs::write(
+            project_root.join("store.rs"),
+            "pub fn reset(count: &mut u32) {\n    *count = 0;\n}\n",
+        )
+        .unwrap();
+        fs::write(
+            project_root.join("main.rs"),
+            "mod store;\nuse store::reset;\n\nfn main() {\n    let mut hits = 1;\n    reset(&mut hits);\n}\n",
+        )
+        .unwrap();
+
+        // 1ファイルごとにシャードを書き出すので、参照先は別のシャードにある
+        let mut indexer = DifferentialIndexer::new(&storage_path, &project_root).unwrap();
+        indexer.set_fallback_only(true);
+        indexer.set_memory_budget(MemoryBudget::from_bytes(1));
+        indexer.index_differential().unwrap();
+        drop(indexer);
+        let expected = vec![("reset".to_string(), Some(ReferenceAccess::Call))];
+        assert_eq!(references_from(&storage_path, "main"), expected);
+
+        // 部分ごとに保存したグラフから差分インデックスを続けられる
+        fs::write(
+            project_root.join("store.rs"),
+            "\npub fn reset(count: &mut u32) {\n    *count = 0;\n}\n",
+        )
+        .unwrap();
+        let mut indexer = DifferentialIndexer::new(&storage_path, &project_root).unwrap();
+        indexer.set_fallback_only(true);
+        assert_eq!(indexer.index_differential().unwrap().files_modified, 1);
+        drop(indexer);
+        assert_eq!(references_from(&storage_path, "main"), expected);
+    }
+
+    #[test]
+    fn test_index_go_and_python_files() {
+        let temp_dir = TempDir::new().unwrap();
+        let project_root = temp_dir.path().join("project");
+        let storage_path = temp_dir.path().join("index.db");
+        fs::create_dir_all(project_root.join("store")).unwrap();
+        fs::write(
+            project_root.join("go.mod"),
+            "module example.com/app\n\ngo 1.22\n",
+        )
+        .unwrap();
+        fs::write(
+            project_root.join("store/db.go"),
+            "package store\n\n// Open opens the store.\n//\n// Deprecated: use Connect.\nfunc Open() {}\n\ntype (\n\tConfig struct{}\n\tID int\n)\n",
+        )
+        .unwrap();
+        fs::write(
+            project_root.join("app.py"),
+            "def greet(name):\n    \"\"\"Say hello.\"\"\"\n    return name\n",
+        )
+        .unwrap();
+
+        let mut indexer = DifferentialIndexer::new(&storage_path, &project_root).unwrap();
+        indexer.set_fallback_only(true);
+        let result = indexer.index_differential().unwrap();
+        assert_eq!(result.files_added, 2);
+
+        let graph = IndexStorage::open(&storage_path)
+            .unwrap()
+            .load_data::<CodeGraph>("graph")
+            .unwrap()
+            .unwrap();
+        let find = |name: &str| {
+            graph
+                .get_all_symbols()
+                .find(|symbol| symbol.name == name)
+                .cloned()
+                .unwrap_or_else(|| panic!("{} was not indexed", name))
+        };
+
+        let open = find("Open");
+        assert!(open.is_deprecated());
+        assert_eq!(
+            open.qualified_name.as_deref(),
+            Some("example.com/app/store.Open")
+        );
+        // `type (...)`のグループの中の型
+        assert_eq!(find("ID").kind, SymbolKind::TypeAlias);
+        assert_eq!(find("Config").kind, SymbolKind::Struct);
+        assert_eq!(find("greet").documentation.as_deref(), Some("Say hello."));
+    }
+
+    #[test]
+    fn test_full_reindex_reports_unreadable_files() {
+        let temp_dir = TempDir::new().unwrap();
+        let project_root = temp_dir.path().join("project");
+        let storage_path = temp_dir.path().join("index.db");
+        fs::create_dir_all(&project_root).unwrap();
+        fs::write(project_root.join("good.rs"), "fn good() {}\n").unwrap();
+        // UTF-8でないファイルはどの戦略でも読めない
+        fs::write(project_root.join("broken.rs"), b"fn broken() {}\n\xff\xfe").unwrap();
+
+        let mut indexer = DifferentialIndexer::new(&storage_path, &project_root).unwrap();
+        indexer.set_fallback_only(true);
+        let result = indexer.full_reindex().unwrap();
+
+        assert_eq!(result.files_added, 1);
+        assert_eq!(result.failed_files.len(), 1);
+        let (path, failure) = &result.failed_files[0];
+        assert!(path.ends_with("broken.rs"));
+        assert_eq!(failure.attempts, 1);
+        assert!(failure
+            .last_error
+            .contains("All extraction strategies failed"));
+        // ハッシュを残さないので、次の実行で再試行される
+        let broken = path.clone();
+        assert!(!indexer
+            .metadata
+            .as_ref()
+            .unwrap()
+            .file_content_hashes
+            .contains_key(&broken));
+        drop(indexer);
+
+        // 試行回数は実行をまたいで数え、差分の実行でも報告する
+        for attempts in 2..=MAX_FILE_ATTEMPTS {
+            let mut indexer = DifferentialIndexer::new(&storage_path, &project_root).unwrap();
+            indexer.set_fallback_only(true);
+            let result = indexer.index_differential().unwrap();
+            assert_eq!(result.failed_files.len(), 1);
+            assert_eq!(result.failed_files[0].1.attempts, attempts);
+        }
+
+        // 失敗を繰り返したファイルは、内容が変わるまで再試行しない
+        let mut indexer = DifferentialIndexer::new(&storage_path, &project_root).unwrap();
+        indexer.set_fallback_only(true);
+        let result = indexer.index_differential().unwrap();
+        assert_eq!(result.failed_files[0].1.attempts, MAX_FILE_ATTEMPTS);
+        drop(indexer);
+
+        fs::write(&broken, "fn broken() {}\n").unwrap();
+        let mut indexer = DifferentialIndexer::new(&storage_path, &project_root).unwrap();
+        indexer.set_fallback_only(true);
+        let result = indexer.index_differential().unwrap();
+        assert!(result.failed_files.is_empty());
+        let graph = indexer.storage.load_graph().unwrap().unwrap();
+        assert!(graph
+            .get_all_symbols()
+            .any(|symbol| symbol.name == "broken"));
+    }
+
     #[test]
     fn test_convert_lsp_symbol_kind() {
         let temp_dir = TempDir::new().unwrap();
@@ -1,5 +1,6 @@
 use anyhow::{Context, Result};
 use git2::{Delta, DiffOptions, Repository, Status, StatusOptions};
+use lsp::tree_sitter_parser::TreeSitterParser;
 use std::collections::{HashMap, HashSet};
 use std::fs;
 use std::path::{Path, PathBuf};
@@ -244,9 +245,8 @@ impl GitDiffDetector {
                 continue;
             }
 
-            // 対象ファイルのみ処理（.rs, .ts, .js, .tsx, .jsx）
-            let ext = path.extension().and_then(|s| s.to_str()).unwrap_or("");
-            if !matches!(ext, "rs" | "ts" | "js" | "tsx" | "jsx") {
+            // 対象ファイルのみ処理（tree-sitterの文法がある言語）
+            if !TreeSitterParser::supports_extension(&path) {
                 continue;
             }
 
@@ -333,6 +333,11 @@ impl GitDiffDetector {
     pub fn set_cached_hash(&mut self, path: PathBuf, hash: String) {
         self.hash_cache.insert(path, hash);
     }
+
+    /// キャッシュされたハッシュを消す（次のハッシュベースの検出で変更として扱う）
+    pub fn remove_cached_hash(&mut self, path: &Path) {
+        self.hash_cache.remove(path);
+    }
 }
 
 #[cfg(test)]
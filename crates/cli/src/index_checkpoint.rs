@@ -0,0 +1,622 @@
+//! フルインデックスのチェックポイント
+//!
+//! 全ファイルを処理するインデックスは、一定数のファイルごとにその間に処理したファイルの
+//! シンボルを「シャード」として保存する。中断・失敗した後は保存済みのシャードからグラフを
+//! 組み立て直し、残りのファイルだけを処理する。
+//!
+//! 抽出に失敗したファイルは`FailureLog`に試行回数を記録する。この記録はチェックポイントと
+//! 違ってインデックスを保存しても残し、実行をまたいで`MAX_FILE_ATTEMPTS`回失敗したものは
+//! （内容が変わるまで）再試行せずに報告する。
+//!
+//! メモリの上限（`MemoryBudget`）を指定すると、溜めたシンボルが上限を超える前にシャードを書き、
+//! 保存するグラフはシャードから部分ごとに書き込む（`write_graph`）。
+
+use crate::git_diff::FileChange;
+use crate::memory_budget::{estimated_size, MemoryBudget};
+use crate::reference_finder::{file_reference_edges, ReferenceTargets};
+use crate::storage::{GraphParts, IndexStorage, IndexTransaction};
+use anyhow::Result;
+use chrono::{DateTime, Utc};
+use lsif_core::graph_serde::SerializedCodeGraph;
+use lsif_core::{qualify_symbols, CodeGraph, Symbol};
+use serde::{Deserialize, Serialize};
+use std::collections::{BTreeMap, HashMap, HashSet};
+use std::path::{Path, PathBuf};
+use tracing::debug;
+
+/// チェックポイントの記録の保存キー
+const CHECKPOINT_KEY: &str = "__index_checkpoint__";
+
+/// 失敗したファイルの記録の保存キー
+const FAILURES_KEY: &str = "__index_failures__";
+
+/// 何ファイルごとにチェックポイントを書くか（`LSIF_CHECKPOINT_INTERVAL`で変更できる）
+const DEFAULT_INTERVAL: usize = 500;
+
+/// この回数失敗したファイルは再試行しない
+pub const MAX_FILE_ATTEMPTS: u32 = 3;
+
+fn shard_key(index: usize) -> String {
+    format!("__index_checkpoint_shard_{}__", index)
+}
+
+/// 保存済みのチェックポイントの状態
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct IndexCheckpoint {
+    pub started_at: DateTime<Utc>,
+    /// 保存済みのシャードの数
+    pub shard_count: usize,
+}
+
+#[derive(Debug, Clone, Serialize, Deserialize, PartialEq)]
+pub struct FileFailure {
+    pub attempts: u32,
+    pub last_error: String,
+    /// 失敗した時の内容ハッシュ（変わったら試行回数を数え直す）
+    pub content_hash: Option<String>,
+}
+
+/// 抽出に失敗したファイルの記録
+///
+/// 失敗したファイルはハッシュを記録しないので、次の実行で変更として再試行される。
+/// 抽出できたファイル・消えたファイルの記録は消す。
+#[derive(Debug, Clone, Default, Serialize, Deserialize)]
+pub struct FailureLog {
+    failures: BTreeMap<PathBuf, FileFailure>,
+}
+
+impl FailureLog {
+    /// 保存済みの記録（なければ空）
+    pub fn load(storage: &IndexStorage) -> Result<Self> {
+        Ok(storage.load_data(FAILURES_KEY)?.unwrap_or_default())
+    }
+
+    /// 記録を`transaction`で保存する（インデックスの保存と同時に書くため）
+    pub fn save_in(&self, transaction: &mut IndexTransaction<'_>) -> Result<()> {
+        if self.failures.is_empty() {
+            transaction.delete(FAILURES_KEY);
+            Ok(())
+        } else {
+            transaction.save_data(FAILURES_KEY, self)
+        }
+    }
+
+    pub fn save(&self, storage: &IndexStorage) -> Result<()> {
+        let mut transaction = storage.transaction();
+        self.save_in(&mut transaction)?;
+        transaction.commit()
+    }
+
+    pub fn contains(&self, path: &Path) -> bool {
+        self.failures.contains_key(path)
+    }
+
+    /// 記録のあるファイル
+    pub fn paths(&self) -> impl Iterator<Item = &Path> {
+        self.failures.keys().map(PathBuf::as_path)
+    }
+
+    /// 再試行をやめたファイルか（`content_hash`は今の内容のハッシュ）
+    pub fn should_skip(&self, path: &Path, content_hash: Option<&str>) -> bool {
+        self.failures.get(path).is_some_and(|failure| {
+            failure.attempts >= MAX_FILE_ATTEMPTS && failure.content_hash.as_deref() == content_hash
+        })
+    }
+
+    /// 失敗したファイル（`attempts`が`MAX_FILE_ATTEMPTS`以上なら再試行をやめたもの）
+    pub fn failures(&self) -> Vec<(PathBuf, FileFailure)> {
+        self.failures
+            .iter()
+            .map(|(path, failure)| (path.clone(), failure.clone()))
+            .collect()
+    }
+
+    pub fn record_failure(
+        &mut self,
+        path: &Path,
+        content_hash: Option<&str>,
+        error: &anyhow::Error,
+    ) {
+        let failure = self
+            .failures
+            .entry(path.to_path_buf())
+            .or_insert(FileFailure {
+                attempts: 0,
+                last_error: String::new(),
+                content_hash: None,
+            });
+        if failure.content_hash.as_deref() != content_hash {
+            failure.attempts = 0;
+            failure.content_hash = content_hash.map(str::to_string);
+        }
+        failure.attempts += 1;
+        failure.last_error = error.to_string();
+    }
+
+    /// 抽出できた（または消えた）ファイルの記録を消す
+    pub fn forget(&mut self, path: &Path) {
+        self.failures.remove(path);
+    }
+}
+
+/// 処理済みのファイル
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct CheckpointedFile {
+    pub path: PathBuf,
+    pub content_hash: Option<String>,
+    pub symbols: Vec<Symbol>,
+}
+
+/// インデックス中のチェックポイント
+pub struct Checkpointer {
+    checkpoint: IndexCheckpoint,
+    /// 保存済みのシャードにあるファイルとその内容ハッシュ
+    completed: HashMap<PathBuf, Option<String>>,
+    /// 次のシャードに入るファイル
+    pending: Vec<CheckpointedFile>,
+    /// `pending`のシンボルの大きさの見積もり
+    pending_bytes: usize,
+    /// 前回の保存から状態が変わったか
+    dirty: bool,
+    interval: usize,
+    /// `pending`をこの大きさまでに書き出す（メモリの上限がある時）
+    spill_threshold: Option<usize>,
+    /// これまでに記録したファイルの数とシンボルの大きさ（1ファイルあたりの見積もり用）
+    recorded_files: usize,
+    recorded_bytes: usize,
+}
+
+impl Checkpointer {
+    /// 新しく始める
+    pub fn new() -> Self {
+        Self {
+            checkpoint: IndexCheckpoint {
+                started_at: Utc::now(),
+                shard_count: 0,
+            },
+            completed: HashMap::new(),
+            pending: Vec::new(),
+            pending_bytes: 0,
+            dirty: false,
+            interval: checkpoint_interval(),
+            spill_threshold: None,
+            recorded_files: 0,
+            recorded_bytes: 0,
+        }
+    }
+
+    /// 保存済みのチェックポイントから再開する（なければNone）
+    ///
+    /// シャードにある処理済みのファイルとその内容ハッシュを返す。中断後に内容が変わった
+    /// （`changes`のハッシュと違う）ファイルは処理済みとせず、もう一度処理させる。
+    /// シャードのシンボルは`replay`か`write_graph`で読む。
+    pub fn resume(
+        storage: &IndexStorage,
+        changes: &[FileChange],
+    ) -> Result<Option<(Self, HashMap<PathBuf, String>)>> {
+        let checkpoint = match storage.load_data::<IndexCheckpoint>(CHECKPOINT_KEY)? {
+            Some(checkpoint) => checkpoint,
+            None => return Ok(None),
+        };
+
+        let current: HashMap<&Path, Option<&String>> = changes
+            .iter()
+            .map(|change| (change.path.as_path(), change.content_hash.as_ref()))
+            .collect();
+        let mut completed = HashMap::new();
+        let mut hashes = HashMap::new();
+        for index in 0..checkpoint.shard_count {
+            let shard = storage
+                .load_data::<Vec<CheckpointedFile>>(&shard_key(index))?
+                .unwrap_or_default();
+            for file in shard {
+                if current.get(file.path.as_path()) != Some(&file.content_hash.as_ref()) {
+                    continue;
+                }
+                if let Some(hash) = &file.content_hash {
+                    hashes.insert(file.path.clone(), hash.clone());
+                }
+                completed.insert(file.path, file.content_hash);
+            }
+        }
+
+        let checkpointer = Self {
+            checkpoint,
+            completed,
+            ..Self::new()
+        };
+        Ok(Some((checkpointer, hashes)))
+    }
+
+    /// 保存済みのシャードのファイルを`graph`に加える（同じファイルの古いシンボルは置き換える）
+    pub fn replay(&self, storage: &IndexStorage, graph: &mut CodeGraph) -> Result<()> {
+        let mut files = Vec::new();
+        self.for_each_completed_file(storage, |file| files.push(file))?;
+        replace_file_symbols(graph, &files);
+        Ok(())
+    }
+
+    /// 保存済みのシャードから保存するグラフを部分ごとに書き込む
+    ///
+    /// 1回目はシャードを1つずつ読み、他のファイルへの参照を解決する索引（IDと名前だけ）を作る。
+    /// 2回目はもう一度読み、ファイルごとに包含関係・完全修飾名（どちらもファイルの中で閉じている）と
+    /// 索引から引いた参照を付けて、シャードごとに1部分として書き込む。`CodeGraph`全体も保存形式の
+    /// グラフ全体も作らないので、メモリに載るのは索引と読んでいるシャードだけになる。
+    pub fn write_graph(&self, storage: &IndexStorage, project_root: &Path) -> Result<GraphParts> {
+        let mut targets = ReferenceTargets::new();
+        self.for_each_completed_shard(storage, |files| {
+            for file in &files {
+                targets.add_graph(&file_graph(file));
+            }
+            Ok(())
+        })?;
+
+        let mut parts = GraphParts::new();
+        self.for_each_completed_shard(storage, |files| {
+            let mut part = SerializedCodeGraph {
+                symbols: Vec::new(),
+                edges: Vec::new(),
+            };
+            for file in &files {
+                let mut graph = file_graph(file);
+                qualify_symbols(&mut graph, project_root);
+                part.append(SerializedCodeGraph::from(&graph));
+                match file_reference_edges(&graph, &targets, &file.path) {
+                    Ok(edges) => part.edges.extend(edges),
+                    Err(e) => debug!(
+                        "Failed to link references in {}: {}",
+                        file.path.display(),
+                        e
+                    ),
+                }
+            }
+            storage.write_graph_part(&mut parts, &part)
+        })?;
+        Ok(parts)
+    }
+
+    /// シャードにある処理済みのファイルを順に渡す（中断後に内容が変わった古い記録は飛ばす）
+    fn for_each_completed_file(
+        &self,
+        storage: &IndexStorage,
+        mut f: impl FnMut(CheckpointedFile),
+    ) -> Result<()> {
+        self.for_each_completed_shard(storage, |files| {
+            files.into_iter().for_each(&mut f);
+            Ok(())
+        })
+    }
+
+    /// シャードの処理済みのファイルをシャードごとに渡す
+    fn for_each_completed_shard(
+        &self,
+        storage: &IndexStorage,
+        mut f: impl FnMut(Vec<CheckpointedFile>) -> Result<()>,
+    ) -> Result<()> {
+        for index in 0..self.checkpoint.shard_count {
+            let shard = storage
+                .load_data::<Vec<CheckpointedFile>>(&shard_key(index))?
+                .unwrap_or_default();
+            f(shard
+                .into_iter()
+                .filter(|file| self.completed.get(&file.path) == Some(&file.content_hash))
+                .collect())?;
+        }
+        Ok(())
+    }
+
+    /// 保存済みのチェックポイントがあるか
+    pub fn exists(storage: &IndexStorage) -> Result<bool> {
+        Ok(storage
+            .load_data::<IndexCheckpoint>(CHECKPOINT_KEY)?
+            .is_some())
+    }
+
+    /// 保存済みのチェックポイントを消す
+    pub fn discard(storage: &IndexStorage) -> Result<()> {
+        let mut transaction = storage.transaction();
+        Self::discard_in(storage, &mut transaction)?;
+        transaction.commit()
+    }
+
+    /// 保存済みのチェックポイントを`transaction`で消す（インデックスの保存と同時に消すため）
+    pub fn discard_in(
+        storage: &IndexStorage,
+        transaction: &mut IndexTransaction<'_>,
+    ) -> Result<()> {
+        let shard_count = storage
+            .load_data::<IndexCheckpoint>(CHECKPOINT_KEY)?
+            .map_or(0, |checkpoint| checkpoint.shard_count);
+        transaction.delete(CHECKPOINT_KEY);
+        for index in 0..shard_count {
+            transaction.delete(&shard_key(index));
+        }
+        Ok(())
+    }
+
+    /// 溜めたシンボルが上限の一部を超えたらシャードを書くようにする
+    pub fn set_memory_budget(&mut self, budget: MemoryBudget) {
+        self.spill_threshold = Some(budget.spill_threshold());
+    }
+
+    /// 何ファイルごとにチェックポイントを書くか
+    pub fn interval(&self) -> usize {
+        self.interval
+    }
+
+    /// 並列処理で一度に抽出するファイルの数
+    ///
+    /// メモリの上限がある時は、これまでの1ファイルあたりのシンボルの大きさから、
+    /// 1回分の結果が書き出す大きさに収まるように減らす。
+    pub fn chunk_size(&self) -> usize {
+        match self.spill_threshold {
+            Some(threshold) if self.recorded_files > 0 => {
+                let per_file = (self.recorded_bytes / self.recorded_files).max(1);
+                (threshold / per_file).clamp(1, self.interval)
+            }
+            _ => self.interval,
+        }
+    }
+
+    /// 保存済みのシャードにあるファイルの数
+    pub fn completed_count(&self) -> usize {
+        self.completed.len()
+    }
+
+    /// 保存済みのシャードにある（処理しなくてよい）ファイルか
+    pub fn should_skip(&self, path: &Path) -> bool {
+        self.completed.contains_key(path)
+    }
+
+    pub fn record_file(&mut self, path: &Path, content_hash: Option<String>, symbols: &[Symbol]) {
+        let bytes = estimated_size(symbols);
+        self.pending_bytes += bytes;
+        self.recorded_files += 1;
+        self.recorded_bytes += bytes;
+        self.pending.push(CheckpointedFile {
+            path: path.to_path_buf(),
+            content_hash,
+            symbols: symbols.to_vec(),
+        });
+        self.dirty = true;
+    }
+
+    /// 次のチェックポイントを書く時か
+    pub fn is_due(&self) -> bool {
+        self.pending.len() >= self.interval
+            || self
+                .spill_threshold
+                .is_some_and(|threshold| self.pending_bytes >= threshold)
+    }
+
+    /// 溜まったファイルを新しいシャードとして保存する
+    pub fn save(&mut self, storage: &IndexStorage) -> Result<()> {
+        if !self.dirty {
+            return Ok(());
+        }
+
+        let mut transaction = storage.transaction();
+        let mut checkpoint = self.checkpoint.clone();
+        if !self.pending.is_empty() {
+            transaction.save_data(&shard_key(checkpoint.shard_count), &self.pending)?;
+            checkpoint.shard_count += 1;
+        }
+        transaction.save_data(CHECKPOINT_KEY, &checkpoint)?;
+        transaction.commit()?;
+
+        self.completed.extend(
+            self.pending
+                .drain(..)
+                .map(|file| (file.path, file.content_hash)),
+        );
+        self.pending_bytes = 0;
+        self.checkpoint = checkpoint;
+        self.dirty = false;
+        Ok(())
+    }
+}
+
+impl Default for Checkpointer {
+    fn default() -> Self {
+        Self::new()
+    }
+}
+
+/// ファイルのシンボルを置き換える（再開前のグラフに同じファイルがあっても重複させない）
+fn replace_file_symbols(graph: &mut CodeGraph, files: &[CheckpointedFile]) {
+    let file_paths: HashSet<String> = files
+        .iter()
+        .map(|file| file.path.to_string_lossy().to_string())
+        .collect();
+    let old_ids: Vec<String> = graph
+        .get_all_symbols()
+        .filter(|symbol| file_paths.contains(&symbol.file_path))
+        .map(|symbol| symbol.id.clone())
+        .collect();
+    for id in &old_ids {
+        graph.remove_symbol(id);
+    }
+
+    for file in files {
+        for symbol in &file.symbols {
+            graph.add_symbol(symbol.clone());
+        }
+        graph.link_containment(&file.symbols);
+    }
+}
+
+/// 1ファイルのシンボルのグラフ（包含関係付き）
+fn file_graph(file: &CheckpointedFile) -> CodeGraph {
+    let mut graph = CodeGraph::new();
+    for symbol in &file.symbols {
+        graph.add_symbol(symbol.clone());
+    }
+    graph.link_containment(&file.symbols);
+    graph
+}
+
+fn checkpoint_interval() -> usize {
+    std::env::var("LSIF_CHECKPOINT_INTERVAL")
+        .ok()
+        .and_then(|value| value.parse().ok())
+        .filter(|&interval| interval > 0)
+        .unwrap_or(DEFAULT_INTERVAL)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::git_diff::FileChangeStatus;
+    use lsif_core::test_fixtures::SymbolBuilder;
+    use lsif_core::SymbolKind;
+    use tempfile::TempDir;
+
+    fn symbol(name: &str, file: &str) -> Symbol {
+        SymbolBuilder::new(&format!("{}#{}", file, name), name, file).build()
+    }
+
+    fn change(path: &str, hash: Option<&str>) -> FileChange {
+        FileChange {
+            path: PathBuf::from(path),
+            status: FileChangeStatus::Added,
+            content_hash: hash.map(str::to_string),
+        }
+    }
+
+    #[test]
+    fn test_resume_replays_shards() {
+        let temp_dir = TempDir::new().unwrap();
+        let storage = IndexStorage::open(temp_dir.path().join("index.db")).unwrap();
+
+        let mut checkpointer = Checkpointer::new();
+        checkpointer.record_file(
+            Path::new("a.rs"),
+            Some("ha".to_string()),
+            &[symbol("a", "a.rs")],
+        );
+        checkpointer.record_file(
+            Path::new("edited.rs"),
+            Some("old".to_string()),
+            &[symbol("edited", "edited.rs")],
+        );
+        checkpointer.save(&storage).unwrap();
+        checkpointer.record_file(Path::new("b.rs"), None, &[symbol("b", "b.rs")]);
+        checkpointer.save(&storage).unwrap();
+        // 保存していないファイルは再開時に失われる
+        checkpointer.record_file(Path::new("c.rs"), None, &[symbol("c", "c.rs")]);
+
+        let changes = [
+            change("a.rs", Some("ha")),
+            change("b.rs", None),
+            change("c.rs", None),
+            // 中断後に編集された
+            change("edited.rs", Some("new")),
+        ];
+        let mut graph = CodeGraph::new();
+        graph.add_symbol(symbol("a", "a.rs"));
+        let (resumed, hashes) = Checkpointer::resume(&storage, &changes).unwrap().unwrap();
+        resumed.replay(&storage, &mut graph).unwrap();
+        assert_eq!(graph.symbol_count(), 2);
+        assert_eq!(resumed.completed_count(), 2);
+        assert_eq!(hashes.get(Path::new("a.rs")), Some(&"ha".to_string()));
+        assert!(resumed.should_skip(Path::new("b.rs")));
+        assert!(!resumed.should_skip(Path::new("c.rs")));
+        assert!(!resumed.should_skip(Path::new("edited.rs")));
+
+        Checkpointer::discard(&storage).unwrap();
+        assert!(!Checkpointer::exists(&storage).unwrap());
+    }
+
+    #[test]
+    fn test_failure_log_counts_attempts_across_runs() {
+        let temp_dir = TempDir::new().unwrap();
+        let storage = IndexStorage::open(temp_dir.path().join("index.db")).unwrap();
+
+        // 実行ごとに読み込み、失敗を記録して保存する
+        for _ in 0..MAX_FILE_ATTEMPTS {
+            let mut failures = FailureLog::load(&storage).unwrap();
+            assert!(!failures.should_skip(Path::new("bad.rs"), Some("h1")));
+            failures.record_failure(
+                Path::new("bad.rs"),
+                Some("h1"),
+                &anyhow::anyhow!("parse error"),
+            );
+            failures.record_failure(Path::new("flaky.rs"), None, &anyhow::anyhow!("timeout"));
+            failures.forget(Path::new("flaky.rs"));
+            failures.save(&storage).unwrap();
+        }
+
+        let mut failures = FailureLog::load(&storage).unwrap();
+        assert!(failures.should_skip(Path::new("bad.rs"), Some("h1")));
+        assert!(!failures.contains(Path::new("flaky.rs")));
+        assert_eq!(failures.failures()[0].1.attempts, MAX_FILE_ATTEMPTS);
+        assert_eq!(failures.failures()[0].1.last_error, "parse error");
+
+        // 内容が変われば数え直す
+        assert!(!failures.should_skip(Path::new("bad.rs"), Some("h2")));
+        failures.record_failure(Path::new("bad.rs"), Some("h2"), &anyhow::anyhow!("again"));
+        assert_eq!(failures.failures()[0].1.attempts, 1);
+
+        failures.forget(Path::new("bad.rs"));
+        failures.save(&storage).unwrap();
+        assert!(FailureLog::load(&storage).unwrap().failures().is_empty());
+    }
+
+    #[test]
+    fn test_memory_budget_spills_and_builds_graph_from_shards() {
+        let temp_dir = TempDir::new().unwrap();
+        let storage = IndexStorage::open(temp_dir.path().join("index.db")).unwrap();
+
+        let mut checkpointer = Checkpointer::new();
+        checkpointer.set_memory_budget(MemoryBudget::from_bytes(4));
+        let mut outer = symbol("Outer", "a.rs");
+        outer.kind = SymbolKind::Struct;
+        outer.range.end.line = 10;
+        let mut inner = symbol("inner", "a.rs");
+        inner.range.start.line = 2;
+        inner.range.end.line = 3;
+        checkpointer.record_file(Path::new("a.rs"), Some("ha".to_string()), &[outer, inner]);
+        // 1ファイルでも上限を超えるので書き出す
+        assert!(checkpointer.is_due());
+        assert_eq!(checkpointer.chunk_size(), 1);
+        checkpointer.save(&storage).unwrap();
+        assert!(!checkpointer.is_due());
+
+        // 中断後に編集されたファイルは、新しい内容の記録だけを使う
+        checkpointer.record_file(
+            Path::new("b.rs"),
+            Some("old".to_string()),
+            &[symbol("old", "b.rs")],
+        );
+        checkpointer.save(&storage).unwrap();
+        let changes = [change("a.rs", Some("ha")), change("b.rs", Some("new"))];
+        let (mut resumed, _) = Checkpointer::resume(&storage, &changes).unwrap().unwrap();
+        resumed.record_file(
+            Path::new("b.rs"),
+            Some("new".to_string()),
+            &[symbol("new", "b.rs")],
+        );
+        resumed.save(&storage).unwrap();
+
+        let parts = resumed.write_graph(&storage, temp_dir.path()).unwrap();
+        assert_eq!(parts.symbol_count, 3);
+        assert_eq!(parts.file_count, 2);
+        // 目次をコミットするまでは読まれない
+        assert!(storage.load_graph().unwrap().is_none());
+        let mut transaction = storage.transaction();
+        transaction.save_graph_parts(&parts).unwrap();
+        transaction.commit().unwrap();
+
+        let graph = storage.load_graph().unwrap().unwrap();
+        assert_eq!(graph.symbol_count(), 3);
+        assert!(graph.find_symbol("b.rs#old").is_none());
+        assert_eq!(
+            graph
+                .find_symbol("a.rs#inner")
+                .unwrap()
+                .qualified_name
+                .as_deref(),
+            Some("crate::a::Outer::inner")
+        );
+    }
+}
@@ -1,10 +1,14 @@
 use anyhow::Result;
-use lsif_core::{CodeGraph, EdgeKind, Position, Range, Symbol, SymbolKind};
+use lsif_core::{
+    CodeGraph, Edge, EdgeKind, EdgeSource, Position, Range, ReferenceAccess, Symbol, SymbolKind,
+};
 use lsp::adapter::lsp::{GenericLspClient, LspAdapter};
+use lsp::lsp_helpers::{document_highlight_access, document_symbol_modifiers, SourceDocComments};
+use lsp::scope_resolver::ScopeResolver;
 use lsp_types::{
-    DocumentSymbol, GotoDefinitionParams, Location, PartialResultParams, Position as LspPosition,
-    ReferenceContext, ReferenceParams, TextDocumentIdentifier, TextDocumentPositionParams,
-    WorkDoneProgressParams,
+    DocumentHighlightParams, DocumentSymbol, GotoDefinitionParams, Location, PartialResultParams,
+    Position as LspPosition, ReferenceContext, ReferenceParams, TextDocumentIdentifier,
+    TextDocumentPositionParams, WorkDoneProgressParams,
 };
 use std::collections::{HashMap, HashSet};
 use std::path::{Path, PathBuf};
@@ -46,7 +50,8 @@ impl Indexer {
 
         // Convert and store symbols
         let mut file_syms = Vec::new();
-        self.process_symbols(&symbols, &file_uri, &mut file_syms, None);
+        let docs = SourceDocComments::load(file_path);
+        self.process_symbols(&symbols, &file_uri, &docs, &mut file_syms, None);
 
         // Now analyze references for each symbol
         info!("Analyzing references for {} symbols", file_syms.len());
@@ -102,22 +107,32 @@ impl Indexer {
         &mut self,
         symbols: &[DocumentSymbol],
         file_uri: &str,
+        docs: &SourceDocComments,
         collected: &mut Vec<Symbol>,
-        parent_idx: Option<petgraph::graph::NodeIndex>,
+        parent: Option<(&DocumentSymbol, petgraph::graph::NodeIndex)>,
     ) {
         for doc_symbol in symbols {
-            let symbol = self.convert_document_symbol(doc_symbol, file_uri);
+            let mut symbol =
+                self.convert_document_symbol(doc_symbol, parent.map(|(p, _)| p), file_uri);
+            docs.attach(doc_symbol, &mut symbol);
             let symbol_idx = self.graph.add_symbol(symbol.clone());
             collected.push(symbol);
 
             // Add containment edge if there's a parent
-            if let Some(parent) = parent_idx {
-                self.graph.add_edge(symbol_idx, parent, EdgeKind::Contains);
+            if let Some((_, parent_idx)) = parent {
+                self.graph
+                    .add_edge(symbol_idx, parent_idx, EdgeKind::Contains);
             }
 
             // Process children
             if let Some(children) = &doc_symbol.children {
-                self.process_symbols(children, file_uri, collected, Some(symbol_idx));
+                self.process_symbols(
+                    children,
+                    file_uri,
+                    docs,
+                    collected,
+                    Some((doc_symbol, symbol_idx)),
+                );
             }
         }
     }
@@ -136,14 +151,29 @@ impl Indexer {
 
         // Find references to this symbol
         let references = self.find_references(symbol, file_uri, client)?;
+        let syntax_accesses = syntax_accesses(&references);
+        let highlight_accesses = self.find_highlight_accesses(symbol, file_uri, client);
 
-        for reference_location in references {
+        for (reference_location, syntax_access) in references.into_iter().zip(syntax_accesses) {
             // Find or create symbol at reference location
             if let Some(ref_symbol) = self.find_symbol_at_location(&reference_location) {
                 if let Some(ref_idx) = self.graph.get_node_index(&ref_symbol.id) {
-                    // Add reference edge: ref_symbol references symbol
-                    self.graph
-                        .add_edge(ref_idx, symbol_idx, EdgeKind::Reference);
+                    // Add reference edge: ref_symbol references symbol.
+                    // The syntax at the site decides the access; documentHighlight only
+                    // fills in same-file sites in languages without a grammar.
+                    let access = syntax_access.or_else(|| {
+                        if reference_location.uri.as_str() != file_uri {
+                            return None;
+                        }
+                        let start = reference_location.range.start;
+                        highlight_accesses
+                            .get(&(start.line, start.character))
+                            .copied()
+                    });
+                    // The occurrence lies inside ref_symbol, so its range is in that file
+                    let edge = Edge::reference(EdgeSource::Lsp, access)
+                        .with_range(convert_range(&reference_location.range));
+                    self.graph.add_edge_with(ref_idx, symbol_idx, edge);
                     debug!("Added reference: {} -> {}", ref_symbol.name, symbol.name);
                 }
             }
@@ -154,8 +184,11 @@ impl Indexer {
             if let Some(def_symbol) = self.find_symbol_at_location(&definition) {
                 if let Some(def_idx) = self.graph.get_node_index(&def_symbol.id) {
                     // Add definition edge: symbol is defined by def_symbol
-                    self.graph
-                        .add_edge(symbol_idx, def_idx, EdgeKind::Definition);
+                    self.graph.add_edge_with(
+                        symbol_idx,
+                        def_idx,
+                        Edge::from_source(EdgeKind::Definition, EdgeSource::Lsp),
+                    );
                     debug!("Added definition: {} -> {}", symbol.name, def_symbol.name);
                 }
             }
@@ -191,6 +224,51 @@ impl Indexer {
         client.find_references(params)
     }
 
+    /// Read/write kinds of the symbol's occurrences in its own file, keyed by start position
+    ///
+    /// documentHighlight only covers one document; `syntax_accesses` handles the other files.
+    fn find_highlight_accesses(
+        &self,
+        symbol: &Symbol,
+        file_uri: &str,
+        client: &mut GenericLspClient,
+    ) -> HashMap<(u32, u32), ReferenceAccess> {
+        if !client.has_capability("textDocument/documentHighlight") {
+            return HashMap::new();
+        }
+        let uri = match file_uri.parse() {
+            Ok(uri) => uri,
+            Err(_) => return HashMap::new(),
+        };
+        let params = DocumentHighlightParams {
+            text_document_position_params: TextDocumentPositionParams {
+                text_document: TextDocumentIdentifier { uri },
+                position: LspPosition {
+                    line: symbol.range.start.line,
+                    character: symbol.range.start.character,
+                },
+            },
+            work_done_progress_params: WorkDoneProgressParams::default(),
+            partial_result_params: PartialResultParams::default(),
+        };
+
+        let highlights = match client.document_highlights(params) {
+            Ok(highlights) => highlights,
+            Err(e) => {
+                debug!("documentHighlight failed for {}: {}", symbol.name, e);
+                Vec::new()
+            }
+        };
+        highlights
+            .into_iter()
+            .filter_map(|highlight| {
+                let access = document_highlight_access(highlight.kind)?;
+                let start = highlight.range.start;
+                Some(((start.line, start.character), access))
+            })
+            .collect()
+    }
+
     /// Find definition using LSP
     fn find_definition(
         &self,
@@ -270,7 +348,11 @@ impl Indexer {
                                 self.graph.get_node_index(&symbol.id),
                                 self.graph.get_node_index(&ref_symbol.id),
                             ) {
-                                self.graph.add_edge(from_idx, to_idx, EdgeKind::Reference);
+                                self.graph.add_edge_with(
+                                    from_idx,
+                                    to_idx,
+                                    Edge::reference(EdgeSource::Lsp, None),
+                                );
                                 debug!(
                                     "Cross-file reference: {} -> {}",
                                     symbol.name, ref_symbol.name
@@ -327,7 +409,12 @@ impl Indexer {
     }
 
     /// Convert LSP DocumentSymbol to our Symbol type
-    fn convert_document_symbol(&self, doc_symbol: &DocumentSymbol, file_uri: &str) -> Symbol {
+    fn convert_document_symbol(
+        &self,
+        doc_symbol: &DocumentSymbol,
+        parent: Option<&DocumentSymbol>,
+        file_uri: &str,
+    ) -> Symbol {
         let file_path = file_uri.strip_prefix("file://").unwrap_or(file_uri);
 
         Symbol {
@@ -338,18 +425,12 @@ impl Indexer {
             name: doc_symbol.name.clone(),
             kind: self.convert_symbol_kind(doc_symbol.kind),
             file_path: file_path.to_string(),
-            range: Range {
-                start: Position {
-                    line: doc_symbol.range.start.line,
-                    character: doc_symbol.range.start.character,
-                },
-                end: Position {
-                    line: doc_symbol.range.end.line,
-                    character: doc_symbol.range.end.character,
-                },
-            },
-            documentation: doc_symbol.detail.clone(),
-            detail: None,
+            range: convert_range(&doc_symbol.range),
+            // detailはシグネチャ（ドキュメントコメントはprocess_symbolsで付ける）
+            documentation: None,
+            detail: doc_symbol.detail.clone(),
+            modifiers: document_symbol_modifiers(doc_symbol, file_path, parent),
+            qualified_name: None,
         }
     }
 
@@ -378,6 +459,73 @@ impl Indexer {
     }
 }
 
+/// Convert an LSP range to our Range type
+fn convert_range(range: &lsp_types::Range) -> Range {
+    Range {
+        start: Position {
+            line: range.start.line,
+            character: range.start.character,
+        },
+        end: Position {
+            line: range.end.line,
+            character: range.end.character,
+        },
+    }
+}
+
+/// Access kinds of each reference site, classified from the syntax around it
+///
+/// Each file is parsed once. Sites in files without a tree-sitter grammar, or that
+/// cannot be read, get `None`.
+fn syntax_accesses(locations: &[Location]) -> Vec<Option<ReferenceAccess>> {
+    let mut accesses = vec![None; locations.len()];
+    let mut by_file: HashMap<&str, Vec<usize>> = HashMap::new();
+    for (i, location) in locations.iter().enumerate() {
+        by_file.entry(location.uri.as_str()).or_default().push(i);
+    }
+
+    for (uri, indices) in by_file {
+        let path = match locations[indices[0]].uri.to_file_path() {
+            Ok(path) => path,
+            Err(()) => {
+                debug!("Cannot classify references in non-file URI {}", uri);
+                continue;
+            }
+        };
+        let mut resolver = match ScopeResolver::from_extension(&path) {
+            Some(resolver) => resolver,
+            None => continue,
+        };
+        let source = match std::fs::read_to_string(&path) {
+            Ok(source) => source,
+            Err(e) => {
+                debug!(
+                    "Cannot read {} to classify references: {}",
+                    path.display(),
+                    e
+                );
+                continue;
+            }
+        };
+        let positions: Vec<Position> = indices
+            .iter()
+            .map(|&i| Position {
+                line: locations[i].range.start.line,
+                character: locations[i].range.start.character,
+            })
+            .collect();
+        match resolver.accesses_at(&source, &positions) {
+            Ok(found) => {
+                for (i, access) in indices.into_iter().zip(found) {
+                    accesses[i] = access;
+                }
+            }
+            Err(e) => debug!("Failed to parse {}: {}", path.display(), e),
+        }
+    }
+    accesses
+}
+
 #[cfg(test)]
 mod tests {
     use super::*;
@@ -436,14 +584,15 @@ mod tests {
             children: None,
         };
 
-        let symbol = indexer.convert_document_symbol(&doc_symbol, "file:///test.rs");
+        let symbol = indexer.convert_document_symbol(&doc_symbol, None, "file:///test.rs");
 
         assert_eq!(symbol.name, "test_function");
         assert_eq!(symbol.kind, SymbolKind::Function);
         assert_eq!(symbol.file_path, "/test.rs");
         assert_eq!(symbol.range.start.line, 10);
         assert_eq!(symbol.range.end.line, 15);
-        assert_eq!(symbol.documentation, Some("fn test_function()".to_string()));
+        assert_eq!(symbol.detail, Some("fn test_function()".to_string()));
+        assert_eq!(symbol.documentation, None);
     }
 
     #[test]
@@ -511,7 +660,13 @@ mod tests {
         ];
 
         let mut collected = Vec::new();
-        indexer.process_symbols(&symbols, "file:///test.rs", &mut collected, None);
+        indexer.process_symbols(
+            &symbols,
+            "file:///test.rs",
+            &SourceDocComments::default(),
+            &mut collected,
+            None,
+        );
 
         assert_eq!(collected.len(), 2);
         assert_eq!(collected[0].name, "func1");
@@ -611,7 +766,13 @@ mod tests {
         }];
 
         let mut collected = Vec::new();
-        indexer.process_symbols(&symbols, "file:///test.rs", &mut collected, None);
+        indexer.process_symbols(
+            &symbols,
+            "file:///test.rs",
+            &SourceDocComments::default(),
+            &mut collected,
+            None,
+        );
 
         assert_eq!(collected.len(), 3);
         assert_eq!(collected[0].name, "MyStruct");
@@ -796,6 +957,8 @@ mod tests {
             },
             documentation: None,
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         };
 
         indexer
@@ -842,6 +1005,8 @@ mod tests {
             },
             documentation: None,
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         };
 
         indexer.graph.add_symbol(symbol);
@@ -872,6 +1037,8 @@ mod tests {
             },
             documentation: None,
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         };
 
         indexer.graph.add_symbol(symbol);
@@ -879,5 +1046,48 @@ mod tests {
         let graph = indexer.into_graph();
         assert_eq!(graph.symbol_count(), 1);
     }
+
+    #[test]
+    fn test_syntax_accesses_cover_other_files() {
+        let temp_dir = TempDir::new().unwrap();
+        let counter = temp_dir.path().join("counter.go");
+        let user = temp_dir.path().join("user.go");
+        fs::write(
+            &counter,
+            "package main\n\nfunc (c *Counter) Reset() {\n\tc.hits = 0\n}\n",
+        )
+        .unwrap();
+        fs::write(
+            &user,
+            "package main\n\nfunc use(c *Counter) {\n\tc.hits++\n\tprintln(c.hits)\n}\n",
+        )
+        .unwrap();
+
+        let location = |path: &Path, line, character| Location {
+            uri: Url::from_file_path(path).unwrap(),
+            range: lsp_types::Range {
+                start: LspPosition { line, character },
+                end: LspPosition {
+                    line,
+                    character: character + 4,
+                },
+            },
+        };
+        let accesses = syntax_accesses(&[
+            location(&counter, 3, 3),
+            location(&user, 3, 3),
+            location(&user, 4, 11),
+            location(&temp_dir.path().join("notes.txt"), 0, 0),
+        ]);
+        assert_eq!(
+            accesses,
+            vec![
+                Some(ReferenceAccess::Write),
+                Some(ReferenceAccess::ReadWrite),
+                Some(ReferenceAccess::Read),
+                None,
+            ]
+        );
+    }
 }
 // Differential test
@@ -5,16 +5,21 @@
 // CLI components
 pub mod adaptive_parallel;
 pub mod batch_graph_updater;
+pub mod cancellation;
 pub mod call_hierarchy_cmd;
 pub mod cli;
 pub mod commands;
 pub mod definition_crawler;
+pub mod deprecation_report;
 pub mod differential_indexer;
+pub mod index_checkpoint;
 pub mod indexer;
 pub mod lsp_unified_cli;
+pub mod memory_budget;
 pub mod output_format;
 pub mod parallel_processor;
 pub mod reference_finder;
+pub mod staleness;
 pub mod symbol_extraction_strategy;
 pub mod type_search;
 pub mod workspace_symbol_strategy;
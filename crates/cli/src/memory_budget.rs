@@ -0,0 +1,104 @@
+//! インデックス中のメモリ上限（`--max-memory`）
+//!
+//! 上限を指定したフルインデックスは、ファイルごとの結果をメモリに溜めずにチェックポイントの
+//! シャードへ書き出し、最後にシャードを1つずつ読んで保存するグラフを組み立てる。
+//! 上限はシンボルの大きさの見積もりで管理する（プロセス全体の使用量を測るわけではない）。
+
+use anyhow::{bail, Result};
+use lsif_core::Symbol;
+
+/// 溜めたシンボルを書き出すまでに使ってよい上限の割合
+///
+/// 残りは並列抽出中のバッファ、LSPの応答、シャードの読み書きに残しておく。
+const SPILL_FRACTION: usize = 4;
+
+/// インデックス中に使うメモリの上限
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub struct MemoryBudget {
+    bytes: usize,
+}
+
+impl MemoryBudget {
+    pub fn from_bytes(bytes: usize) -> Self {
+        Self { bytes }
+    }
+
+    /// 環境変数`LSIF_MAX_MEMORY`の上限（未設定ならNone）
+    pub fn from_env() -> Result<Option<Self>> {
+        match std::env::var("LSIF_MAX_MEMORY") {
+            Ok(value) => parse_memory_size(&value).map(Some),
+            Err(_) => Ok(None),
+        }
+    }
+
+    pub fn bytes(&self) -> usize {
+        self.bytes
+    }
+
+    /// シャードに書き出す前に溜めてよいシンボルの大きさ
+    pub fn spill_threshold(&self) -> usize {
+        (self.bytes / SPILL_FRACTION).max(1)
+    }
+}
+
+/// `512M`・`2G`・`64MiB`の形式（単位なしはバイト）
+pub fn parse_memory_size(value: &str) -> Result<MemoryBudget> {
+    let value = value.trim();
+    let split = value
+        .find(|c: char| !c.is_ascii_digit())
+        .unwrap_or(value.len());
+    let (number, unit) = value.split_at(split);
+    let number: usize = match number.parse() {
+        Ok(number) if number > 0 => number,
+        _ => bail!("invalid memory size: {}", value),
+    };
+    let unit = unit.trim().to_ascii_uppercase();
+    let multiplier: usize = match unit.trim_end_matches("IB").trim_end_matches('B') {
+        "" => 1,
+        "K" => 1 << 10,
+        "M" => 1 << 20,
+        "G" => 1 << 30,
+        _ => bail!("invalid memory size unit in {} (use K, M or G)", value),
+    };
+    match number.checked_mul(multiplier) {
+        Some(bytes) => Ok(MemoryBudget::from_bytes(bytes)),
+        None => bail!("memory size too large: {}", value),
+    }
+}
+
+/// シンボルがメモリ上で占める大きさの見積もり
+pub fn estimated_size(symbols: &[Symbol]) -> usize {
+    symbols
+        .iter()
+        .map(|symbol| {
+            std::mem::size_of::<Symbol>()
+                + symbol.id.len()
+                + symbol.name.len()
+                + symbol.file_path.len()
+                + symbol.documentation.as_ref().map_or(0, String::len)
+                + symbol.detail.as_ref().map_or(0, String::len)
+                + symbol.qualified_name.as_ref().map_or(0, String::len)
+        })
+        .sum()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_parse_memory_size() {
+        assert_eq!(parse_memory_size("4096").unwrap().bytes(), 4096);
+        assert_eq!(parse_memory_size("512M").unwrap().bytes(), 512 << 20);
+        assert_eq!(parse_memory_size("2g").unwrap().bytes(), 2 << 30);
+        assert_eq!(parse_memory_size("64MiB").unwrap().bytes(), 64 << 20);
+        assert_eq!(parse_memory_size("100KB").unwrap().bytes(), 100 << 10);
+        assert!(parse_memory_size("0").is_err());
+        assert!(parse_memory_size("1T").is_err());
+        assert!(parse_memory_size("lots").is_err());
+        assert_eq!(
+            parse_memory_size("1M").unwrap().spill_threshold(),
+            (1 << 20) / SPILL_FRACTION
+        );
+    }
+}
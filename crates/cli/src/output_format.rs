@@ -1,5 +1,5 @@
 use anyhow::Result;
-use lsif_core::Symbol;
+use lsif_core::{LineIndex, PositionEncoding, Symbol};
 use serde::{Deserialize, Serialize};
 use std::collections::HashMap;
 
@@ -109,17 +109,23 @@ impl OutputFormatter {
             "{}:{}:{}",
             symbol.file_path, symbol.range.start.line, symbol.range.start.character
         );
+        // The deprecation note itself is included in the JSON output
+        let name = if symbol.is_deprecated() {
+            format!("{} ⚠️ (deprecated)", symbol.display_name())
+        } else {
+            symbol.display_name().to_string()
+        };
 
         if let Some(ctx) = context {
-            format!("{} {} at {} - {}", emoji, symbol.name, location, ctx)
+            format!("{} {} at {} - {}", emoji, name, location, ctx)
         } else {
-            format!("{} {} at {}", emoji, symbol.name, location)
+            format!("{} {} at {}", emoji, name, location)
         }
     }
 
     fn format_quickfix(&self, symbol: &Symbol, context: Option<&str>) -> String {
         // Vim quickfix format: filename:line:col: text
-        let text = context.unwrap_or(&symbol.name);
+        let text = context.unwrap_or(symbol.display_name());
         format!(
             "{}:{}:{}: {}",
             symbol.file_path, symbol.range.start.line, symbol.range.start.character, text
@@ -128,7 +134,7 @@ impl OutputFormatter {
 
     fn format_grep(&self, symbol: &Symbol, context: Option<&str>) -> String {
         // Grep format: filename:line:col:text (no space after colon)
-        let text = context.unwrap_or(&symbol.name);
+        let text = context.unwrap_or(symbol.display_name());
         format!(
             "{}:{}:{}:{}",
             symbol.file_path, symbol.range.start.line, symbol.range.start.character, text
@@ -146,14 +152,15 @@ impl OutputFormatter {
     }
 
     fn format_tsv(&self, symbol: &Symbol) -> String {
-        // Tab-separated: file\tline\tcol\tname\tkind
+        // Tab-separated: file\tline\tcol\tname\tkind\tqualified_name
         format!(
-            "{}\t{}\t{}\t{}\t{:?}",
+            "{}\t{}\t{}\t{}\t{:?}\t{}",
             symbol.file_path,
             symbol.range.start.line,
             symbol.range.start.character,
             symbol.name,
-            symbol.kind
+            symbol.kind,
+            symbol.display_name()
         )
     }
 
@@ -164,17 +171,28 @@ impl OutputFormatter {
 
     // Helper methods
 
+    /// LSP clients expect UTF-16 columns; the graph stores UTF-8 byte columns.
+    /// Falls back to the stored columns when the file cannot be read.
     fn to_lsp_location(&self, symbol: &Symbol) -> LspLocation {
+        let range = match std::fs::read_to_string(&symbol.file_path) {
+            Ok(text) => LineIndex::new(&text).convert_range(
+                symbol.range,
+                PositionEncoding::CANONICAL,
+                PositionEncoding::Utf16,
+            ),
+            Err(_) => symbol.range,
+        };
+
         LspLocation {
             uri: format!("file://{}", symbol.file_path),
             range: LspRange {
                 start: LspPosition {
-                    line: symbol.range.start.line,
-                    character: symbol.range.start.character,
+                    line: range.start.line,
+                    character: range.start.character,
                 },
                 end: LspPosition {
-                    line: symbol.range.end.line,
-                    character: symbol.range.end.character,
+                    line: range.end.line,
+                    character: range.end.character,
                 },
             },
         }
@@ -183,6 +201,12 @@ impl OutputFormatter {
     fn to_json_object(&self, symbol: &Symbol) -> HashMap<String, serde_json::Value> {
         let mut obj = HashMap::new();
         obj.insert("name".to_string(), serde_json::json!(symbol.name));
+        if let Some(qualified_name) = &symbol.qualified_name {
+            obj.insert(
+                "qualified_name".to_string(),
+                serde_json::json!(qualified_name),
+            );
+        }
         obj.insert("file".to_string(), serde_json::json!(symbol.file_path));
         obj.insert(
             "line".to_string(),
@@ -205,6 +229,14 @@ impl OutputFormatter {
             obj.insert("doc".to_string(), serde_json::json!(doc));
         }
 
+        if let Some(note) = symbol.deprecation() {
+            obj.insert("deprecated".to_string(), serde_json::json!(note));
+        }
+
+        if !symbol.modifiers.is_empty() {
+            obj.insert("modifiers".to_string(), serde_json::json!(symbol.modifiers));
+        }
+
         obj
     }
 }
@@ -270,10 +302,128 @@ mod tests {
             },
             documentation: None,
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         };
 
         let formatter = OutputFormatter::new(OutputFormat::Quickfix);
         let output = formatter.format_symbol(&symbol, Some("function definition"));
         assert_eq!(output, "src/main.rs:10:5: function definition");
     }
+
+    #[test]
+    fn test_lsp_format_uses_utf16_columns() {
+        let mut file = tempfile::NamedTempFile::new().unwrap();
+        std::io::Write::write_all(&mut file, "// 日本語\nlet 名前 = foo();\n".as_bytes()).unwrap();
+        let path = file.path().to_string_lossy().to_string();
+
+        // "foo" starts at byte 13 but at UTF-16 unit 9
+        let symbol = Symbol {
+            id: "foo".to_string(),
+            name: "foo".to_string(),
+            kind: lsif_core::SymbolKind::Function,
+            file_path: path.clone(),
+            range: lsif_core::Range {
+                start: lsif_core::Position {
+                    line: 1,
+                    character: 13,
+                },
+                end: lsif_core::Position {
+                    line: 1,
+                    character: 16,
+                },
+            },
+            documentation: None,
+            detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
+        };
+
+        let lsp = OutputFormatter::new(OutputFormat::Lsp).format_symbol(&symbol, None);
+        let location: serde_json::Value = serde_json::from_str(&lsp).unwrap();
+        assert_eq!(location["range"]["start"]["character"], 9);
+        assert_eq!(location["range"]["end"]["character"], 12);
+
+        // Quickfix keeps byte columns, which is what Vim expects
+        let qf = OutputFormatter::new(OutputFormat::Quickfix).format_symbol(&symbol, None);
+        assert_eq!(qf, format!("{}:1:13: foo", path));
+    }
+
+    #[test]
+    fn test_deprecated_symbols_are_marked() {
+        let symbol = Symbol {
+            id: "open".to_string(),
+            name: "Open".to_string(),
+            kind: lsif_core::SymbolKind::Function,
+            file_path: "db.go".to_string(),
+            range: lsif_core::Range {
+                start: lsif_core::Position {
+                    line: 3,
+                    character: 5,
+                },
+                end: lsif_core::Position {
+                    line: 3,
+                    character: 9,
+                },
+            },
+            documentation: Some(
+                "Open opens the database.\n\n**Deprecated**: Use OpenContext instead.".to_string(),
+            ),
+            detail: None,
+            modifiers: lsif_core::SymbolModifiers::PUBLIC | lsif_core::SymbolModifiers::EXPORTED,
+            qualified_name: None,
+        };
+
+        let human = OutputFormatter::new(OutputFormat::Human).format_symbol(&symbol, None);
+        assert_eq!(human, "🔧 Open ⚠️ (deprecated) at db.go:3:5");
+
+        let json = OutputFormatter::new(OutputFormat::Json).format_symbol(&symbol, None);
+        let value: serde_json::Value = serde_json::from_str(&json).unwrap();
+        assert_eq!(value["deprecated"], "Use OpenContext instead.");
+        assert_eq!(
+            value["modifiers"],
+            serde_json::json!(["public", "exported"])
+        );
+    }
+
+    #[test]
+    fn test_qualified_names_in_output() {
+        let symbol = Symbol {
+            id: "close".to_string(),
+            name: "Close".to_string(),
+            kind: lsif_core::SymbolKind::Method,
+            file_path: "store/db.go".to_string(),
+            range: lsif_core::Range {
+                start: lsif_core::Position {
+                    line: 7,
+                    character: 0,
+                },
+                end: lsif_core::Position {
+                    line: 9,
+                    character: 1,
+                },
+            },
+            documentation: None,
+            detail: None,
+            modifiers: Default::default(),
+            qualified_name: Some("example.com/svc/store.(*DB).Close".to_string()),
+        };
+
+        let human = OutputFormatter::new(OutputFormat::Human).format_symbol(&symbol, None);
+        assert_eq!(
+            human,
+            "📍 example.com/svc/store.(*DB).Close at store/db.go:7:0"
+        );
+
+        let tsv = OutputFormatter::new(OutputFormat::Tsv).format_symbol(&symbol, None);
+        assert_eq!(
+            tsv,
+            "store/db.go\t7\t0\tClose\tMethod\texample.com/svc/store.(*DB).Close"
+        );
+
+        let json = OutputFormatter::new(OutputFormat::Json).format_symbol(&symbol, None);
+        let value: serde_json::Value = serde_json::from_str(&json).unwrap();
+        assert_eq!(value["name"], "Close");
+        assert_eq!(value["qualified_name"], "example.com/svc/store.(*DB).Close");
+    }
 }
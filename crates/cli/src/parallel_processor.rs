@@ -202,6 +202,8 @@ mod tests {
                 },
                 documentation: None,
                 detail: None,
+                modifiers: Default::default(),
+                qualified_name: None,
             }])
         });
 
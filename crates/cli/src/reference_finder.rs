@@ -1,12 +1,22 @@
 use anyhow::Result;
 /// 参照検索の実装
 ///
-/// ファイル内容を実際に検索して使用箇所を見つける
-use lsif_core::{Position, Range, Symbol, SymbolKind};
+/// ファイル内容を実際に検索して使用箇所を見つける。
+/// tree-sitterの文法がある言語はスコープを考慮した名前解決を使い、
+/// ローカル変数や別の型の同名メンバーを参照として数えない。
+use lsif_core::graph_serde::SerializedEdge;
+use lsif_core::{
+    CodeGraph, Edge, EdgeKind, EdgeSource, Position, Range, ReferenceAccess, Symbol, SymbolKind,
+};
 use lsp::adapter::language::{LanguageAdapter, RustLanguageAdapter, TypeScriptLanguageAdapter};
+use lsp::scope_resolver::{DefinitionKind, FileResolution, Resolution, ScopeResolver};
+use petgraph::graph::NodeIndex;
+use petgraph::visit::EdgeRef;
+use petgraph::Direction;
 use regex::Regex;
-use std::collections::HashSet;
-use std::path::Path;
+use std::collections::{HashMap, HashSet};
+use std::path::{Component, Path, PathBuf};
+use tracing::debug;
 use walkdir::WalkDir;
 
 /// 参照の検索結果
@@ -14,77 +24,640 @@ use walkdir::WalkDir;
 pub struct Reference {
     pub symbol: Symbol,
     pub is_definition: bool,
+    /// 使われ方（定義・インポート・正規表現で見つけたものはNone）
+    pub access: Option<ReferenceAccess>,
+    /// 見つけ方（名前解決ならtree-sitter、行の文字列検索なら正規表現）
+    pub source: EdgeSource,
 }
 
-/// プロジェクト全体から参照を検索
-pub fn find_all_references(
-    project_root: &Path,
-    target_name: &str,
-    target_kind: &SymbolKind,
-) -> Result<Vec<Reference>> {
-    let mut references = Vec::new();
-    let mut seen_locations = HashSet::new();
+/// 除外するディレクトリ
+const EXCLUDE_DIRS: [&str; 4] = ["target", ".git", "node_modules", ".vscode"];
 
-    // 除外するディレクトリ
-    let exclude_dirs = ["target", ".git", "node_modules", ".vscode"];
-
-    // 対象ファイルを走査
-    for entry in WalkDir::new(project_root)
+/// プロジェクト内のファイルを走査
+fn walk_files(project_root: &Path) -> impl Iterator<Item = PathBuf> {
+    WalkDir::new(project_root)
         .follow_links(false)
         .into_iter()
         .filter_entry(|e| {
             // 除外ディレクトリをスキップ
             if let Some(name) = e.file_name().to_str() {
-                !exclude_dirs.contains(&name)
+                !EXCLUDE_DIRS.contains(&name)
             } else {
                 true
             }
         })
         .filter_map(|e| e.ok())
         .filter(|e| e.file_type().is_file())
-        .filter(|e| e.file_type().is_file())
-    {
-        let path = entry.path();
-        // 言語アダプターを検出
-        // 言語に応じたアダプタを取得
-        let language_adapter = match path.extension().and_then(|s| s.to_str()) {
-            Some("rs") => Some(Box::new(RustLanguageAdapter) as Box<dyn LanguageAdapter>),
-            Some("ts") | Some("tsx") | Some("js") | Some("jsx") => {
-                Some(Box::new(TypeScriptLanguageAdapter) as Box<dyn LanguageAdapter>)
-            }
-            _ => None,
+        .map(|e| e.into_path())
+}
+
+/// プロジェクト全体から参照を検索
+pub fn find_all_references(
+    project_root: &Path,
+    target_name: &str,
+    target_kind: &SymbolKind,
+) -> Result<Vec<Reference>> {
+    let mut references = Vec::new();
+    let mut seen_locations = HashSet::new();
+
+    for path in walk_files(project_root) {
+        let path = path.as_path();
+        let content = match std::fs::read_to_string(path) {
+            Ok(content) => content,
+            Err(_) => continue,
         };
 
-        if let Some(adapter) = language_adapter {
-            if adapter.is_source_file(path) {
-                if let Ok(content) = std::fs::read_to_string(path) {
-                    let refs = find_references_in_file(
+        // 名前解決できればそれを使い、できなければ正規表現で検索
+        let resolved = ScopeResolver::from_extension(path)
+            .and_then(|mut resolver| resolver.resolve(&content).ok());
+        let refs = match resolved {
+            Some(resolution) => references_by_name(path, &resolution, target_name, target_kind),
+            None => {
+                // 言語に応じたアダプタを取得
+                let language_adapter = match path.extension().and_then(|s| s.to_str()) {
+                    Some("rs") => Some(Box::new(RustLanguageAdapter) as Box<dyn LanguageAdapter>),
+                    Some("ts") | Some("tsx") | Some("js") | Some("jsx") => {
+                        Some(Box::new(TypeScriptLanguageAdapter) as Box<dyn LanguageAdapter>)
+                    }
+                    _ => None,
+                };
+                match language_adapter {
+                    Some(adapter) if adapter.is_source_file(path) => find_references_in_file(
                         path,
                         &content,
                         target_name,
                         target_kind,
                         adapter.as_ref(),
-                    )?;
-
-                    // 重複を除外
-                    for reference in refs {
-                        let location_key = format!(
-                            "{}:{}:{}",
-                            reference.symbol.file_path,
-                            reference.symbol.range.start.line,
-                            reference.symbol.range.start.character
-                        );
-
-                        if seen_locations.insert(location_key) {
-                            references.push(reference);
+                    )?,
+                    _ => continue,
+                }
+            }
+        };
+
+        // 重複を除外
+        for reference in refs {
+            let location_key = format!(
+                "{}:{}:{}",
+                reference.symbol.file_path,
+                reference.symbol.range.start.line,
+                reference.symbol.range.start.character
+            );
+
+            if seen_locations.insert(location_key) {
+                references.push(reference);
+            }
+        }
+    }
+
+    sort_references(&mut references);
+    Ok(references)
+}
+
+/// 特定のシンボルへの参照を検索
+///
+/// 名前が同じでも、ローカル変数・別の型のメンバー・別モジュールの同名関数は含めない。
+pub fn find_references_to_symbol(project_root: &Path, target: &Symbol) -> Result<Vec<Reference>> {
+    let target_path = {
+        let path = Path::new(&target.file_path);
+        if path.is_absolute() || path.exists() {
+            path.to_path_buf()
+        } else {
+            project_root.join(path)
+        }
+    };
+    let target_key = normalize_path(&target_path);
+
+    // 定義ファイルで対象の定義を特定
+    let content = std::fs::read_to_string(&target_path)?;
+    let mut resolver = match ScopeResolver::from_extension(&target_path) {
+        Some(resolver) => resolver,
+        None => return find_all_references(project_root, &target.name, &target.kind),
+    };
+    let resolution = resolver.resolve(&content)?;
+    let definition = resolution.definitions.iter().position(|d| {
+        d.name == target.name
+            && matches!(d.kind, DefinitionKind::Item | DefinitionKind::Member { .. })
+            && d.range.start.line >= target.range.start.line
+            && d.range.start.line <= target.range.end.line
+    });
+    let definition = match definition {
+        Some(idx) => idx,
+        None => return find_all_references(project_root, &target.name, &target.kind),
+    };
+    let container = match &resolution.definitions[definition].kind {
+        DefinitionKind::Member { container } => Some(container.clone()),
+        _ => None,
+    };
+
+    let mut references = Vec::new();
+    for path in walk_files(project_root) {
+        let same_file = normalize_path(&path) == target_key;
+        let file_resolution = if same_file {
+            resolution.clone()
+        } else {
+            let mut resolver = match ScopeResolver::from_extension(&path) {
+                Some(resolver) => resolver,
+                None => continue,
+            };
+            let content = match std::fs::read_to_string(&path) {
+                Ok(content) => content,
+                Err(_) => continue,
+            };
+            match resolver.resolve(&content) {
+                Ok(resolution) => resolution,
+                Err(_) => continue,
+            }
+        };
+        let path_str = path.to_string_lossy().to_string();
+
+        if same_file {
+            let def = &file_resolution.definitions[definition];
+            references.push(Reference {
+                symbol: reference_symbol(&path_str, &def.name, def.range.clone(), target.kind),
+                is_definition: true,
+                access: None,
+                source: EdgeSource::TreeSitter,
+            });
+            for reference in &file_resolution.references {
+                if reference.resolution == Resolution::Definition(definition) {
+                    references.push(Reference {
+                        symbol: reference_symbol(
+                            &path_str,
+                            &reference.name,
+                            reference.range.clone(),
+                            SymbolKind::Reference,
+                        ),
+                        is_definition: false,
+                        access: Some(reference.access),
+                        source: EdgeSource::TreeSitter,
+                    });
+                }
+            }
+            continue;
+        }
+
+        // Goは同じディレクトリが同じパッケージ
+        let same_package = path.extension().and_then(|e| e.to_str()) == Some("go")
+            && path.parent().map(normalize_path) == target_key.parent().map(Path::to_path_buf);
+        let via_glob = file_resolution
+            .glob_imports
+            .iter()
+            .any(|module| module_matches(module, &path, &target_path));
+        let mut push = |name: &str, range: &Range, access: Option<ReferenceAccess>| {
+            references.push(Reference {
+                symbol: reference_symbol(&path_str, name, range.clone(), SymbolKind::Reference),
+                is_definition: false,
+                access,
+                source: EdgeSource::TreeSitter,
+            });
+        };
+
+        // 対象をインポートした束縛
+        let imports: HashSet<usize> = file_resolution
+            .definitions
+            .iter()
+            .enumerate()
+            .filter(|(_, d)| match &d.kind {
+                DefinitionKind::Import { module, name } => {
+                    *name == target.name && module_matches(module, &path, &target_path)
+                }
+                _ => false,
+            })
+            .map(|(idx, _)| idx)
+            .collect();
+        for &idx in &imports {
+            let def = &file_resolution.definitions[idx];
+            push(&def.name, &def.range, None);
+        }
+
+        for reference in &file_resolution.references {
+            let matched = match &reference.resolution {
+                Resolution::Definition(idx) => imports.contains(idx),
+                Resolution::Qualified { qualifier, name } => {
+                    *name == target.name
+                        && match &container {
+                            Some(container) => last_segment(qualifier) == container,
+                            None => module_matches(qualifier, &path, &target_path),
                         }
+                }
+                Resolution::Member { type_name, name } => {
+                    *name == target.name && container.as_deref() == Some(last_segment(type_name))
+                }
+                Resolution::Free => {
+                    reference.name == target.name
+                        && container.is_none()
+                        && (same_package || via_glob)
+                }
+                Resolution::Unknown => false,
+            };
+            if matched {
+                push(&reference.name, &reference.range, Some(reference.access));
+            }
+        }
+    }
+
+    sort_references(&mut references);
+    Ok(references)
+}
+
+/// ファイルの参照をtree-sitterで解決し、使われ方付きの`Reference`エッジとしてグラフに張る
+///
+/// 参照元は出現位置を囲む最も内側のシンボル。参照先は同じファイルの定義か、
+/// インポート・修飾・同じパッケージから1つに絞れる他のファイルのシンボル。
+/// 前回このファイルから張ったtree-sitterのエッジは張り直す。張ったエッジの数を返す。
+pub fn link_references(graph: &mut CodeGraph, files: &[PathBuf]) -> usize {
+    let mut targets = ReferenceTargets::new();
+    targets.add_graph(graph);
+    let mut by_file: HashMap<String, Vec<NodeIndex>> = HashMap::new();
+    for idx in graph.graph.node_indices() {
+        if is_link_target(&graph.graph[idx]) {
+            by_file
+                .entry(graph.graph[idx].file_path.clone())
+                .or_default()
+                .push(idx);
+        }
+    }
+
+    let mut linked = 0;
+    for path in files {
+        let file_nodes = match by_file.get(&*path.to_string_lossy()) {
+            Some(nodes) => nodes,
+            None => continue,
+        };
+        match link_file_references(graph, file_nodes, &targets, path) {
+            Ok(count) => linked += count,
+            Err(e) => debug!("Failed to link references in {}: {}", path.display(), e),
+        }
+    }
+    linked
+}
+
+/// `graph`にあるファイル`path`のシンボルから出る参照のエッジを、グラフに張らずに返す
+///
+/// 他のファイルのシンボルは`targets`から引くので、`graph`にはそのファイルのシンボルだけが
+/// あればよい（グラフ全体をメモリに載せずに参照を張るため）。
+pub fn file_reference_edges(
+    graph: &CodeGraph,
+    targets: &ReferenceTargets,
+    path: &Path,
+) -> Result<Vec<SerializedEdge>> {
+    let path_str = path.to_string_lossy();
+    let file_nodes: Vec<NodeIndex> = graph
+        .graph
+        .node_indices()
+        .filter(|&idx| {
+            let symbol = &graph.graph[idx];
+            symbol.file_path == path_str && is_link_target(symbol)
+        })
+        .collect();
+    Ok(resolve_file_references(graph, &file_nodes, targets, path)?
+        .into_iter()
+        .map(|(source, to_id, edge)| SerializedEdge {
+            from_id: graph.graph[source].id.clone(),
+            to_id,
+            edge,
+        })
+        .collect())
+}
+
+/// `file_path`のシンボルを参照している他のファイル
+///
+/// ファイルのシンボルを置き換えると入ってくるエッジも消えるので、置き換える前に集めて
+/// `link_references`で張り直す。
+pub fn referencing_files(graph: &CodeGraph, file_path: &str) -> HashSet<PathBuf> {
+    let mut files = HashSet::new();
+    for idx in graph.graph.node_indices() {
+        if graph.graph[idx].file_path != file_path {
+            continue;
+        }
+        for edge in graph.graph.edges_directed(idx, Direction::Incoming) {
+            let source = &graph.graph[edge.source()];
+            if edge.weight().kind == EdgeKind::Reference && source.file_path != file_path {
+                files.insert(PathBuf::from(&source.file_path));
+            }
+        }
+    }
+    files
+}
+
+/// 参照先になるシンボルか
+fn is_link_target(symbol: &Symbol) -> bool {
+    !matches!(symbol.kind, SymbolKind::Reference | SymbolKind::File)
+}
+
+/// 他のファイルにある参照先の候補
+#[derive(Debug, Clone)]
+struct ReferenceTarget {
+    id: String,
+    file_path: String,
+    /// 包む型の名前（Goのメソッドならレシーバーの型）
+    container: Option<String>,
+}
+
+/// 参照先の候補を名前から引く索引
+///
+/// シンボルそのものではなくIDと名前の解決に使う情報だけを持つので、グラフを読み込まずに
+/// ファイルごとのグラフから組み立てられる。
+#[derive(Debug, Default)]
+pub struct ReferenceTargets {
+    by_name: HashMap<String, Vec<ReferenceTarget>>,
+}
+
+impl ReferenceTargets {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// グラフのシンボルを候補に加える（包む型は`Contains`エッジから引く）
+    pub fn add_graph(&mut self, graph: &CodeGraph) {
+        for idx in graph.graph.node_indices() {
+            let symbol = &graph.graph[idx];
+            if !is_link_target(symbol) {
+                continue;
+            }
+            let container = split_receiver(&symbol.name)
+                .map(|(receiver, _)| receiver)
+                .or_else(|| container_name(graph, idx))
+                .map(str::to_string);
+            self.by_name
+                .entry(bare_name(&symbol.name).to_string())
+                .or_default()
+                .push(ReferenceTarget {
+                    id: symbol.id.clone(),
+                    file_path: symbol.file_path.clone(),
+                    container,
+                });
+        }
+    }
+
+    /// 他のファイルで`name`という名前を持ち、`accept`を満たす唯一のシンボル
+    fn unique_elsewhere(
+        &self,
+        name: &str,
+        path_str: &str,
+        accept: impl Fn(&ReferenceTarget) -> bool,
+    ) -> Option<&ReferenceTarget> {
+        let mut found = self
+            .by_name
+            .get(name)?
+            .iter()
+            .filter(|target| target.file_path != path_str && accept(target));
+        let first = found.next()?;
+        // 同名の候補が複数あれば決められない
+        found.next().is_none().then_some(first)
+    }
+}
+
+fn link_file_references(
+    graph: &mut CodeGraph,
+    file_nodes: &[NodeIndex],
+    targets: &ReferenceTargets,
+    path: &Path,
+) -> Result<usize> {
+    let edges = resolve_file_references(graph, file_nodes, targets, path)?;
+
+    // 前回張ったエッジを外す（何度張り直しても重複しないように）
+    let stale: Vec<_> = file_nodes
+        .iter()
+        .flat_map(|&idx| graph.graph.edges_directed(idx, Direction::Outgoing))
+        .filter(|edge| {
+            edge.weight().kind == EdgeKind::Reference
+                && edge.weight().source == EdgeSource::TreeSitter
+        })
+        .map(|edge| edge.id())
+        .collect();
+    for edge in stale {
+        graph.graph.remove_edge(edge);
+    }
+
+    let mut linked = 0;
+    for (source, to_id, edge) in edges {
+        if let Some(target) = graph.get_node_index(&to_id) {
+            graph.add_edge_with(source, target, edge);
+            linked += 1;
+        }
+    }
+    Ok(linked)
+}
+
+/// ファイルの参照を解決し、（参照元、参照先のID、エッジ）を返す
+fn resolve_file_references(
+    graph: &CodeGraph,
+    file_nodes: &[NodeIndex],
+    targets: &ReferenceTargets,
+    path: &Path,
+) -> Result<Vec<(NodeIndex, String, Edge)>> {
+    let path_str = path.to_string_lossy().to_string();
+    if file_nodes.is_empty() {
+        return Ok(Vec::new());
+    }
+    let mut resolver = match ScopeResolver::from_extension(path) {
+        Some(resolver) => resolver,
+        None => return Ok(Vec::new()),
+    };
+    let resolution = resolver.resolve(&std::fs::read_to_string(path)?)?;
+
+    let same_package = |candidate: &ReferenceTarget| {
+        let candidate = Path::new(&candidate.file_path);
+        path.extension().and_then(|e| e.to_str()) == Some("go")
+            && candidate.extension() == path.extension()
+            && candidate.parent() == path.parent()
+    };
+    let via_glob = |candidate: &ReferenceTarget| {
+        resolution
+            .glob_imports
+            .iter()
+            .any(|module| module_matches(module, path, Path::new(&candidate.file_path)))
+    };
+    let elsewhere = |target: Option<&ReferenceTarget>| target.map(|target| target.id.clone());
+
+    let mut edges = Vec::new();
+    for reference in &resolution.references {
+        let target = match &reference.resolution {
+            Resolution::Definition(idx) => {
+                let definition = &resolution.definitions[*idx];
+                match &definition.kind {
+                    DefinitionKind::Item | DefinitionKind::Member { .. } => file_nodes
+                        .iter()
+                        .map(|&idx| &graph.graph[idx])
+                        .find(|symbol| {
+                            bare_name(&symbol.name) == definition.name
+                                && range_contains(&symbol.range, &definition.range.start)
+                        })
+                        .map(|symbol| symbol.id.clone()),
+                    DefinitionKind::Import { module, name } if name != "*" && name != "default" => {
+                        elsewhere(targets.unique_elsewhere(name, &path_str, |candidate| {
+                            module_matches(module, path, Path::new(&candidate.file_path))
+                        }))
                     }
+                    _ => None,
                 }
             }
+            Resolution::Qualified { qualifier, name } => {
+                elsewhere(targets.unique_elsewhere(name, &path_str, |candidate| {
+                    match candidate.container.as_deref() {
+                        Some(container) => container == last_segment(qualifier),
+                        None => module_matches(qualifier, path, Path::new(&candidate.file_path)),
+                    }
+                }))
+            }
+            Resolution::Member { type_name, name } => {
+                elsewhere(targets.unique_elsewhere(name, &path_str, |candidate| {
+                    candidate.container.as_deref() == Some(last_segment(type_name))
+                }))
+            }
+            Resolution::Free => {
+                elsewhere(
+                    targets.unique_elsewhere(&reference.name, &path_str, |candidate| {
+                        candidate.container.is_none()
+                            && (same_package(candidate) || via_glob(candidate))
+                    }),
+                )
+            }
+            Resolution::Unknown => None,
+        };
+        let target = match target {
+            Some(target) => target,
+            None => continue,
+        };
+
+        // 出現位置を囲む最も内側のシンボルから張る
+        let source = file_nodes
+            .iter()
+            .copied()
+            .filter(|&idx| range_contains(&graph.graph[idx].range, &reference.range.start))
+            .max_by_key(|&idx| {
+                let start = graph.graph[idx].range.start;
+                (start.line, start.character)
+            });
+        if let Some(source) = source {
+            let edge = Edge::reference(EdgeSource::TreeSitter, Some(reference.access))
+                .with_range(reference.range);
+            edges.push((source, target, edge));
+        }
+    }
+    Ok(edges)
+}
+
+/// シンボルを包む型の名前（`Contains`エッジの親）
+fn container_name(graph: &CodeGraph, idx: NodeIndex) -> Option<&str> {
+    graph
+        .graph
+        .edges_directed(idx, Direction::Outgoing)
+        .find(|edge| edge.weight().kind == EdgeKind::Contains)
+        .map(|edge| &graph.graph[edge.target()])
+        .filter(|parent| {
+            matches!(
+                parent.kind,
+                SymbolKind::Class
+                    | SymbolKind::Struct
+                    | SymbolKind::Interface
+                    | SymbolKind::Trait
+                    | SymbolKind::Enum
+            )
+        })
+        .map(|parent| parent.name.as_str())
+}
+
+/// Goのメソッド名（`(*DB).Close`）からレシーバーを除いた名前
+fn bare_name(name: &str) -> &str {
+    split_receiver(name).map_or(name, |(_, method)| method)
+}
+
+/// Goのメソッド名をレシーバーの型（`DB`、`List[T]`なら`List`）と名前に分ける
+fn split_receiver(name: &str) -> Option<(&str, &str)> {
+    let (receiver, method) = name.strip_prefix('(')?.split_once(").")?;
+    let receiver = receiver.trim_start_matches('*');
+    let receiver = receiver.split('[').next().unwrap_or(receiver);
+    Some((receiver, method))
+}
+
+fn range_contains(range: &Range, position: &Position) -> bool {
+    let key = |p: &Position| (p.line, p.character);
+    key(&range.start) <= key(position) && key(position) <= key(&range.end)
+}
+
+/// 名前の一致で参照を集める（定義の特定はしない）
+fn references_by_name(
+    path: &Path,
+    resolution: &FileResolution,
+    target_name: &str,
+    target_kind: &SymbolKind,
+) -> Vec<Reference> {
+    let path_str = path.to_string_lossy().to_string();
+    let mut references = Vec::new();
+
+    for definition in &resolution.definitions {
+        let is_definition = match &definition.kind {
+            DefinitionKind::Item | DefinitionKind::Member { .. } => definition.name == target_name,
+            _ => false,
+        };
+        // インポートの束縛は参照として数える
+        let is_import = matches!(
+            &definition.kind,
+            DefinitionKind::Import { name, .. } if name == target_name
+        );
+        if is_definition || is_import {
+            references.push(Reference {
+                symbol: reference_symbol(
+                    &path_str,
+                    &definition.name,
+                    definition.range.clone(),
+                    if is_definition {
+                        *target_kind
+                    } else {
+                        SymbolKind::Reference
+                    },
+                ),
+                is_definition,
+                access: None,
+                source: EdgeSource::TreeSitter,
+            });
+        }
+    }
+
+    // ローカル変数・引数に解決されたものや型の分からないメンバーは除外
+    for reference in &resolution.references {
+        if resolution.referenced_name(reference) == Some(target_name) {
+            references.push(Reference {
+                symbol: reference_symbol(
+                    &path_str,
+                    &reference.name,
+                    reference.range.clone(),
+                    SymbolKind::Reference,
+                ),
+                is_definition: false,
+                access: Some(reference.access),
+                source: EdgeSource::TreeSitter,
+            });
         }
     }
 
-    // 結果をソート（ファイル名、行番号順）
+    references
+}
+
+fn reference_symbol(path_str: &str, name: &str, range: Range, kind: SymbolKind) -> Symbol {
+    Symbol {
+        id: format!(
+            "{}#{}:{}:{}",
+            path_str,
+            range.start.line + 1,
+            range.start.character,
+            name
+        ),
+        kind,
+        name: name.to_string(),
+        file_path: path_str.to_string(),
+        range,
+        documentation: None,
+        detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
+    }
+}
+
+/// 結果をソート（ファイル名、行番号順）
+fn sort_references(references: &mut [Reference]) {
     references.sort_by(|a, b| {
         a.symbol
             .file_path
@@ -98,8 +671,62 @@ pub fn find_all_references(
                     .cmp(&b.symbol.range.start.character),
             )
     });
+}
 
-    Ok(references)
+fn normalize_path(path: &Path) -> PathBuf {
+    path.canonicalize().unwrap_or_else(|_| path.to_path_buf())
+}
+
+/// `.`と`..`を取り除いたパス（ファイルシステムは参照しない）
+fn lexical_normalize(path: &Path) -> PathBuf {
+    let mut normalized = PathBuf::new();
+    for component in path.components() {
+        match component {
+            Component::ParentDir => {
+                normalized.pop();
+            }
+            Component::CurDir => {}
+            other => normalized.push(other),
+        }
+    }
+    normalized
+}
+
+fn last_segment(path: &str) -> &str {
+    path.rsplit(|c: char| c == ':' || c == '.' || c == '/')
+        .next()
+        .unwrap_or(path)
+}
+
+/// インポートしたモジュールが定義ファイルを指すか
+fn module_matches(module: &str, importing_file: &Path, target_file: &Path) -> bool {
+    // TS/JSの相対インポートはパスで比較
+    if module.starts_with("./") || module.starts_with("../") {
+        let base = importing_file.parent().unwrap_or(Path::new(""));
+        let resolved = lexical_normalize(&base.join(module));
+        // `./store`は`store.ts`か`store/index.ts`
+        let target = lexical_normalize(&target_file.with_extension(""));
+        let is_index = target.file_name().and_then(|n| n.to_str()) == Some("index");
+        return resolved == target || (is_index && target.parent() == Some(resolved.as_path()));
+    }
+
+    let stem = target_file
+        .file_stem()
+        .and_then(|s| s.to_str())
+        .unwrap_or_default();
+    let dir = target_file
+        .parent()
+        .and_then(|p| p.file_name())
+        .and_then(|s| s.to_str())
+        .unwrap_or_default();
+    match last_segment(module) {
+        "crate" | "super" | "self" => matches!(stem, "lib" | "main" | "mod"),
+        last => {
+            last == stem
+                || (matches!(stem, "mod" | "lib" | "__init__" | "index") && last == dir)
+                || (target_file.extension().and_then(|e| e.to_str()) == Some("go") && last == dir)
+        }
+    }
 }
 
 /// ファイル内の参照を検索
@@ -162,11 +789,15 @@ fn find_references_in_file(
                 },
                 documentation: None,
                 detail: None,
+                modifiers: Default::default(),
+                qualified_name: None,
             };
 
             references.push(Reference {
                 symbol,
                 is_definition,
+                access: None,
+                source: EdgeSource::Regex,
             });
         }
     }
@@ -413,8 +1044,12 @@ fn main() {
                     },
                     documentation: None,
                     detail: None,
+                    modifiers: Default::default(),
+                    qualified_name: None,
                 },
                 is_definition: false,
+                access: None,
+                source: EdgeSource::TreeSitter,
             },
             Reference {
                 symbol: Symbol {
@@ -434,8 +1069,12 @@ fn main() {
                     },
                     documentation: None,
                     detail: None,
+                    modifiers: Default::default(),
+                    qualified_name: None,
                 },
                 is_definition: true,
+                access: None,
+                source: EdgeSource::TreeSitter,
             },
             Reference {
                 symbol: Symbol {
@@ -455,8 +1094,12 @@ fn main() {
                     },
                     documentation: None,
                     detail: None,
+                    modifiers: Default::default(),
+                    qualified_name: None,
                 },
                 is_definition: false,
+                access: None,
+                source: EdgeSource::TreeSitter,
             },
         ];
 
@@ -544,4 +1187,204 @@ fn duplicate() {
             assert!(seen.insert(key), "Duplicate reference found");
         }
     }
+
+    #[test]
+    fn test_find_references_to_symbol_distinguishes_types() {
+        let temp_dir = TempDir::new().unwrap();
+        let store_dir = temp_dir.path().join("store");
+        fs::create_dir(&store_dir).unwrap();
+
+        fs::write(
+            store_dir.join("db.go"),
+            r#"package store
+
+type DB struct{}
+
+func (d *DB) Close() error { return nil }
+
+func Open() *DB { return &DB{} }
+"#,
+        )
+        .unwrap();
+        fs::write(
+            store_dir.join("file.go"),
+            r#"package store
+
+type File struct{}
+
+func (f *File) Close() error { return nil }
+
+func cleanup(db *DB) { db.Close() }
+"#,
+        )
+        .unwrap();
+        fs::write(
+            temp_dir.path().join("main.go"),
+            r#"package main
+
+import "example.com/app/store"
+
+func main() {
+    var db *store.DB = store.Open()
+    defer db.Close()
+    f := &store.File{}
+    f.Close()
+}
+"#,
+        )
+        .unwrap();
+
+        let target = Symbol {
+            id: "db.go#5:Close".to_string(),
+            kind: SymbolKind::Method,
+            name: "Close".to_string(),
+            file_path: store_dir.join("db.go").to_string_lossy().to_string(),
+            range: Range {
+                start: Position {
+                    line: 4,
+                    character: 0,
+                },
+                end: Position {
+                    line: 4,
+                    character: 41,
+                },
+            },
+            documentation: None,
+            detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
+        };
+
+        let refs = find_references_to_symbol(temp_dir.path(), &target).unwrap();
+        let locations: Vec<_> = refs
+            .iter()
+            .map(|r| {
+                let file = Path::new(&r.symbol.file_path)
+                    .file_name()
+                    .unwrap()
+                    .to_string_lossy()
+                    .to_string();
+                (file, r.symbol.range.start.line, r.is_definition)
+            })
+            .collect();
+
+        // File.Closeの定義・呼び出しは含まない
+        assert_eq!(
+            locations,
+            vec![
+                ("main.go".to_string(), 6, false),
+                ("db.go".to_string(), 4, true),
+                ("file.go".to_string(), 6, false),
+            ]
+        );
+        // どちらも呼び出し
+        assert!(refs
+            .iter()
+            .filter(|r| !r.is_definition)
+            .all(|r| r.access == Some(ReferenceAccess::Call)));
+    }
+
+    fn graph_symbol(
+        path: &Path,
+        name: &str,
+        kind: SymbolKind,
+        start: (u32, u32),
+        end: (u32, u32),
+    ) -> Symbol {
+        let file_path = path.to_string_lossy().to_string();
+        Symbol {
+            id: format!("{}#{}:{}", file_path, start.0 + 1, name),
+            kind,
+            name: name.to_string(),
+            file_path,
+            range: Range {
+                start: Position {
+                    line: start.0,
+                    character: start.1,
+                },
+                end: Position {
+                    line: end.0,
+                    character: end.1,
+                },
+            },
+            documentation: None,
+            detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
+        }
+    }
+
+    #[test]
+    fn test_link_references_across_files() {
+        let temp_dir = TempDir::new().unwrap();
+        let store_dir = temp_dir.path().join("store");
+        fs::create_dir(&store_dir).unwrap();
+        let db = store_dir.join("db.go");
+        let file = store_dir.join("file.go");
+        fs::write(
+            &db,
+            "package store\n\ntype DB struct{}\n\nfunc (d *DB) Close() error { return nil }\n",
+        )
+        .unwrap();
+        fs::write(
+            &file,
+            "package store\n\ntype File struct{}\n\nfunc (f *File) Close() error { return nil }\n\nfunc cleanup(db *DB) { db.Close() }\n",
+        )
+        .unwrap();
+
+        let symbols = vec![
+            graph_symbol(&db, "DB", SymbolKind::Struct, (2, 0), (2, 16)),
+            graph_symbol(&db, "(*DB).Close", SymbolKind::Method, (4, 0), (4, 41)),
+            graph_symbol(&file, "File", SymbolKind::Struct, (2, 0), (2, 18)),
+            graph_symbol(&file, "(*File).Close", SymbolKind::Method, (4, 0), (4, 43)),
+            graph_symbol(&file, "cleanup", SymbolKind::Function, (6, 0), (6, 35)),
+        ];
+        let mut graph = CodeGraph::new();
+        for symbol in &symbols {
+            graph.add_symbol(symbol.clone());
+        }
+        graph.link_containment(&symbols);
+
+        let files = vec![db.clone(), file.clone()];
+        link_references(&mut graph, &files);
+        // 張り直しても重複しない
+        link_references(&mut graph, &files);
+
+        let cleanup = graph.get_node_index(&symbols[4].id).unwrap();
+        let mut targets: Vec<_> = graph
+            .graph
+            .edges_directed(cleanup, Direction::Outgoing)
+            .filter(|edge| edge.weight().kind == EdgeKind::Reference)
+            .map(|edge| {
+                (
+                    graph.graph[edge.target()].name.clone(),
+                    edge.weight().access(),
+                    edge.weight().source,
+                )
+            })
+            .collect();
+        targets.sort_by(|a, b| a.0.cmp(&b.0));
+        // 同名のFile.Closeではなく、引数の型のDB.Closeに結び付く
+        assert_eq!(
+            targets,
+            vec![
+                (
+                    "(*DB).Close".to_string(),
+                    Some(ReferenceAccess::Call),
+                    EdgeSource::TreeSitter
+                ),
+                (
+                    "DB".to_string(),
+                    Some(ReferenceAccess::Read),
+                    EdgeSource::TreeSitter
+                ),
+            ]
+        );
+
+        let db_str = db.to_string_lossy();
+        assert_eq!(
+            referencing_files(&graph, &db_str),
+            HashSet::from([file.clone()])
+        );
+    }
 }
@@ -0,0 +1,176 @@
+//! インデックスが古くなったかの安価な判定
+//!
+//! インデックスのたびに、git HEAD・`.git/index`の更新時刻・インデックス済みファイルの一部
+//! （とそのディレクトリ）の更新時刻とサイズを記録しておき、コマンドの実行前に比べる。
+//! ファイルを読んでハッシュを取る差分検出は、どれかが変わっていた時だけ行う。
+
+use anyhow::{bail, Result};
+use git2::Repository;
+use serde::{Deserialize, Serialize};
+use std::collections::BTreeSet;
+use std::path::{Path, PathBuf};
+use std::time::{Duration, SystemTime};
+
+/// 記録するファイルとディレクトリの数の上限（それぞれ）
+const MAX_SAMPLES: usize = 256;
+
+/// インデックス時点のプロジェクトの状態
+#[derive(Debug, Clone, Serialize, Deserialize, PartialEq)]
+pub struct StalenessManifest {
+    pub indexed_at: SystemTime,
+    pub git_head: Option<String>,
+    pub git_index_mtime: Option<SystemTime>,
+    /// インデックス済みのファイルとそのディレクトリの一部
+    pub samples: Vec<PathSample>,
+}
+
+#[derive(Debug, Clone, Serialize, Deserialize, PartialEq)]
+pub struct PathSample {
+    pub path: PathBuf,
+    /// 存在しなければNone
+    pub mtime: Option<SystemTime>,
+    pub size: u64,
+}
+
+impl PathSample {
+    fn capture(path: &Path) -> Self {
+        let metadata = std::fs::metadata(path).ok();
+        Self {
+            path: path.to_path_buf(),
+            mtime: metadata.as_ref().and_then(|m| m.modified().ok()),
+            size: metadata.map(|m| m.len()).unwrap_or(0),
+        }
+    }
+}
+
+impl StalenessManifest {
+    /// 現在の状態を記録する（`files`はインデックス済みのファイル）
+    pub fn capture<'a>(project_root: &Path, files: impl IntoIterator<Item = &'a PathBuf>) -> Self {
+        let files: BTreeSet<&PathBuf> = files.into_iter().collect();
+        // ディレクトリの更新時刻はファイルの追加・削除で変わる
+        let dirs: BTreeSet<&Path> = files.iter().filter_map(|file| file.parent()).collect();
+
+        let samples = spread(files.iter().map(|file| file.as_path()))
+            .chain(spread(dirs.into_iter()))
+            .map(PathSample::capture)
+            .collect();
+        let (git_head, git_index_mtime) = git_state(project_root);
+
+        Self {
+            indexed_at: SystemTime::now(),
+            git_head,
+            git_index_mtime,
+            samples,
+        }
+    }
+
+    /// 記録時から変わったもの（変わっていなければNone）
+    pub fn stale_reason(&self, project_root: &Path) -> Option<String> {
+        let (git_head, git_index_mtime) = git_state(project_root);
+        if git_head != self.git_head {
+            return Some("git HEAD moved".to_string());
+        }
+        if git_index_mtime != self.git_index_mtime {
+            return Some("git index changed".to_string());
+        }
+        self.samples
+            .iter()
+            .find(|sample| PathSample::capture(&sample.path) != **sample)
+            .map(|sample| format!("{} changed", sample.path.display()))
+    }
+
+    /// 記録してからの時間
+    pub fn age(&self) -> Duration {
+        self.indexed_at.elapsed().unwrap_or_default()
+    }
+}
+
+/// 最大`MAX_SAMPLES`個を全体から等間隔に選ぶ
+fn spread<'a>(paths: impl ExactSizeIterator<Item = &'a Path>) -> impl Iterator<Item = &'a Path> {
+    let step = ((paths.len() + MAX_SAMPLES - 1) / MAX_SAMPLES).max(1);
+    paths.step_by(step)
+}
+
+/// HEADのコミットと`.git/index`の更新時刻
+fn git_state(project_root: &Path) -> (Option<String>, Option<SystemTime>) {
+    let repo = match Repository::open(project_root) {
+        Ok(repo) => repo,
+        Err(_) => return (None, None),
+    };
+    let head = repo
+        .head()
+        .ok()
+        .and_then(|head| head.target())
+        .map(|oid| oid.to_string());
+    let index_mtime = std::fs::metadata(repo.path().join("index"))
+        .and_then(|m| m.modified())
+        .ok();
+    (head, index_mtime)
+}
+
+/// `30s`・`5m`・`2h`・`1d`の形式（単位なしは秒）
+pub fn parse_duration(value: &str) -> Result<Duration> {
+    let value = value.trim();
+    let split = value
+        .find(|c: char| !c.is_ascii_digit())
+        .unwrap_or(value.len());
+    let (number, unit) = value.split_at(split);
+    let number: u64 = match number.parse() {
+        Ok(number) => number,
+        Err(_) => bail!("invalid duration: {}", value),
+    };
+    let seconds = match unit {
+        "" | "s" => number,
+        "m" => number * 60,
+        "h" => number * 60 * 60,
+        "d" => number * 24 * 60 * 60,
+        _ => bail!("invalid duration unit in {} (use s, m, h or d)", value),
+    };
+    Ok(Duration::from_secs(seconds))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::fs;
+    use tempfile::TempDir;
+
+    #[test]
+    fn test_parse_duration() {
+        assert_eq!(parse_duration("0").unwrap(), Duration::ZERO);
+        assert_eq!(parse_duration("45").unwrap(), Duration::from_secs(45));
+        assert_eq!(parse_duration("5m").unwrap(), Duration::from_secs(300));
+        assert_eq!(parse_duration("2h").unwrap(), Duration::from_secs(7200));
+        assert_eq!(parse_duration("1d").unwrap(), Duration::from_secs(86400));
+        assert!(parse_duration("5w").is_err());
+        assert!(parse_duration("m").is_err());
+    }
+
+    #[test]
+    fn test_stale_reason() {
+        let temp_dir = TempDir::new().unwrap();
+        let root = temp_dir.path();
+        let file = root.join("lib.rs");
+        fs::write(&file, "fn a() {}\n").unwrap();
+
+        let manifest = StalenessManifest::capture(root, [&file]);
+        assert_eq!(manifest.stale_reason(root), None);
+
+        // サイズが変われば更新時刻の粒度に関係なく検出できる
+        fs::write(&file, "fn a() {}\nfn b() {}\n").unwrap();
+        assert!(manifest.stale_reason(root).unwrap().contains("lib.rs"));
+
+        fs::remove_file(&file).unwrap();
+        assert!(manifest.stale_reason(root).is_some());
+    }
+
+    #[test]
+    fn test_samples_are_bounded() {
+        let files: Vec<PathBuf> = (0..1000)
+            .map(|i| PathBuf::from(format!("dir{}/file{}.rs", i % 3, i)))
+            .collect();
+        let manifest = StalenessManifest::capture(Path::new("."), &files);
+        // ファイル250個（4つおき）とディレクトリ3個
+        assert_eq!(manifest.samples.len(), 250 + 3);
+    }
+}
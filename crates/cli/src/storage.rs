@@ -1,6 +1,72 @@
+use crate::staleness::StalenessManifest;
 use anyhow::Result;
+use lsif_core::compact_graph::CompactGraph;
+use lsif_core::graph_serde::SerializedCodeGraph;
+use lsif_core::{CodeGraph, GraphBackend, GraphStore};
+use lsp::lsp_timing_stats::LspTimingStats;
 use serde::{Deserialize, Serialize};
+use std::collections::{HashMap, HashSet};
 use std::path::{Path, PathBuf};
+use tracing::{debug, warn};
+
+/// 学習済みLSP応答時間統計の保存キー
+const LSP_TIMING_STATS_KEY: &str = "__lsp_timing_stats__";
+
+/// 鮮度判定用のマニフェストの保存キー
+const STALENESS_MANIFEST_KEY: &str = "__staleness_manifest__";
+
+/// グラフの保存キー
+const GRAPH_KEY: &str = "graph";
+
+/// 読み取り用のグラフ（`COMPACT_GRAPH_FILE`）と対応するグラフの世代の保存キー
+///
+/// グラフを書き換えると消えるので、古い読み取り用のグラフを使うことはない。
+const COMPACT_GRAPH_GENERATION_KEY: &str = "__compact_graph_generation__";
+
+/// 読み取り用のグラフのファイル名（DBディレクトリの中）
+pub const COMPACT_GRAPH_FILE: &str = "graph.csr";
+
+/// 部分ごとに保存したグラフの目次の保存キー（あれば`graph`の代わりに読む）
+const GRAPH_PARTS_KEY: &str = "__graph_parts__";
+
+/// 部分ごとに保存したグラフの各部分の保存キーの接頭辞
+const GRAPH_PART_PREFIX: &str = "__graph_part_";
+
+fn graph_part_key(generation: u64, index: usize) -> String {
+    format!("{}{}_{}__", GRAPH_PART_PREFIX, generation, index)
+}
+
+/// 部分ごとに保存するグラフの目次
+///
+/// 部分は`IndexStorage::write_graph_part`で書いた時点でDBに入るが、
+/// `IndexTransaction::save_graph_parts`で目次をコミットするまで読まれない。
+/// グラフ全体をメモリに載せずに保存するために使う。
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct GraphParts {
+    generation: u64,
+    count: usize,
+    /// 書き込んだシンボルの数
+    pub symbol_count: usize,
+    /// 書き込んだシンボルのファイルの数（1つのファイルは1つの部分に収めること）
+    pub file_count: usize,
+}
+
+impl GraphParts {
+    pub fn new() -> Self {
+        Self {
+            generation: chrono::Utc::now().timestamp_nanos_opt().unwrap_or_default() as u64,
+            count: 0,
+            symbol_count: 0,
+            file_count: 0,
+        }
+    }
+}
+
+impl Default for GraphParts {
+    fn default() -> Self {
+        Self::new()
+    }
+}
 
 pub struct IndexStorage {
     pub(crate) db: sled::Db,
@@ -38,11 +104,14 @@ impl IndexStorage {
         Ok(self.db_path.clone())
     }
 
+    /// 値を保存する
+    ///
+    /// グラフなら読み取り用のグラフの世代と部分ごとに保存したグラフを同じバッチで消すので、
+    /// 途中で止まっても古いグラフと新しいグラフが混ざって見えることはない。
     pub fn save_data<T: Serialize>(&self, key: &str, data: &T) -> Result<()> {
-        let serialized = bincode::serialize(data)?;
-        self.db.insert(key, serialized)?;
-        self.db.flush()?;
-        Ok(())
+        let mut transaction = self.transaction();
+        transaction.save_data(key, data)?;
+        transaction.commit()
     }
 
     pub fn load_data<T: for<'de> Deserialize<'de>>(&self, key: &str) -> Result<Option<T>> {
@@ -54,6 +123,83 @@ impl IndexStorage {
         }
     }
 
+    /// 保存されているグラフ（部分ごとに保存したグラフはつなげて返す）
+    pub fn load_serialized_graph(&self) -> Result<Option<SerializedCodeGraph>> {
+        let parts = match self.load_data::<GraphParts>(GRAPH_PARTS_KEY)? {
+            Some(parts) => parts,
+            None => return self.load_data(GRAPH_KEY),
+        };
+        let mut graph = SerializedCodeGraph {
+            symbols: Vec::with_capacity(parts.symbol_count),
+            edges: Vec::new(),
+        };
+        for index in 0..parts.count {
+            let key = graph_part_key(parts.generation, index);
+            let part = self
+                .load_data::<SerializedCodeGraph>(&key)?
+                .ok_or_else(|| anyhow::anyhow!("Missing graph part: {}", key))?;
+            graph.append(part);
+        }
+        Ok(Some(graph))
+    }
+
+    /// 保存されているグラフを組み立てる
+    pub fn load_graph(&self) -> Result<Option<CodeGraph>> {
+        Ok(self
+            .load_serialized_graph()?
+            .map(SerializedCodeGraph::into_graph))
+    }
+
+    /// 保存されているグラフを`backend`の保存方式で組み立てる（`CodeGraph`を経由しない）
+    pub fn load_graph_store(
+        &self,
+        backend: GraphBackend,
+    ) -> Result<Option<Box<dyn GraphStore + Send + Sync>>> {
+        Ok(self
+            .load_serialized_graph()?
+            .map(|graph| backend.load_serialized(graph)))
+    }
+
+    /// グラフの1部分を書き込む（目次をコミットするまで読まれない）
+    pub fn write_graph_part(
+        &self,
+        parts: &mut GraphParts,
+        part: &SerializedCodeGraph,
+    ) -> Result<()> {
+        self.db.insert(
+            graph_part_key(parts.generation, parts.count),
+            bincode::serialize(part)?,
+        )?;
+        parts.count += 1;
+        parts.symbol_count += part.symbols.len();
+        parts.file_count += part
+            .symbols
+            .iter()
+            .map(|symbol| symbol.file_path.as_str())
+            .collect::<HashSet<_>>()
+            .len();
+        Ok(())
+    }
+
+    /// `generation`以外の世代のグラフの部分のキー（Noneなら全て）
+    ///
+    /// 書き換えられたグラフの部分と、コミットされなかった部分が対象になる。
+    fn graph_part_keys(&self, generation: Option<u64>) -> Result<Vec<sled::IVec>> {
+        let keep = generation.map(|generation| format!("{}{}_", GRAPH_PART_PREFIX, generation));
+        let mut keys = Vec::new();
+        for key in self.db.scan_prefix(GRAPH_PART_PREFIX).keys() {
+            let key = key?;
+            if keep
+                .as_ref()
+                .is_some_and(|keep| key.starts_with(keep.as_bytes()))
+            {
+                continue;
+            }
+            keys.push(key);
+        }
+        Ok(keys)
+    }
+
     pub fn list_keys(&self) -> Result<Vec<String>> {
         let mut keys = Vec::new();
         for k in self.db.iter().keys().flatten() {
@@ -69,6 +215,149 @@ impl IndexStorage {
         self.db.flush()?;
         Ok(())
     }
+
+    /// 複数のキーをまとめて書き込むトランザクションを始める
+    pub fn transaction(&self) -> IndexTransaction<'_> {
+        IndexTransaction {
+            storage: self,
+            batch: sled::Batch::default(),
+            staged_compact_graph: None,
+        }
+    }
+
+    pub fn compact_graph_path(&self) -> PathBuf {
+        self.db_path.join(COMPACT_GRAPH_FILE)
+    }
+
+    /// 保存されているグラフと同じ世代の読み取り用のグラフをメモリマップして開く
+    ///
+    /// ないか、グラフが後から書き換えられていればNone（呼び出し側は`graph`を読む）。
+    pub fn load_compact_graph(&self) -> Result<Option<CompactGraph>> {
+        let generation = match self.load_data::<u64>(COMPACT_GRAPH_GENERATION_KEY)? {
+            Some(generation) => generation,
+            None => return Ok(None),
+        };
+        let path = self.compact_graph_path();
+        if !path.exists() {
+            return Ok(None);
+        }
+        match CompactGraph::open(&path) {
+            Ok(graph) if graph.generation() == generation => Ok(Some(graph)),
+            Ok(graph) => {
+                debug!(
+                    "Compact graph generation {} does not match {}",
+                    graph.generation(),
+                    generation
+                );
+                Ok(None)
+            }
+            Err(e) => {
+                warn!("Ignoring unreadable compact graph: {}", e);
+                Ok(None)
+            }
+        }
+    }
+}
+
+/// まとめて書き込む変更
+///
+/// `commit`するまで何も書き込まない。`commit`は全ての変更を1つのバッチとして適用するので、
+/// 途中で中断されても読み手には前の状態か全て適用された状態のどちらかしか見えない。
+pub struct IndexTransaction<'a> {
+    storage: &'a IndexStorage,
+    batch: sled::Batch,
+    /// コミットしたら置き換える読み取り用のグラフの一時ファイル
+    staged_compact_graph: Option<PathBuf>,
+}
+
+impl IndexTransaction<'_> {
+    pub fn save_data<T: Serialize>(&mut self, key: &str, data: &T) -> Result<()> {
+        if key == GRAPH_KEY {
+            self.batch.remove(COMPACT_GRAPH_GENERATION_KEY);
+            self.remove_graph_parts(None)?;
+        }
+        self.batch.insert(key, bincode::serialize(data)?);
+        Ok(())
+    }
+
+    /// `IndexStorage::write_graph_part`で書いたグラフを保存する
+    ///
+    /// 目次だけを書き、前のグラフ（1つにまとめたものも部分ごとのものも）は消す。
+    /// 読み取り用のグラフは作らないので、読み手は部分をつなげて読む。
+    pub fn save_graph_parts(&mut self, parts: &GraphParts) -> Result<()> {
+        self.batch.remove(GRAPH_KEY);
+        self.batch.remove(COMPACT_GRAPH_GENERATION_KEY);
+        self.remove_graph_parts(Some(parts.generation))?;
+        self.batch
+            .insert(GRAPH_PARTS_KEY, bincode::serialize(parts)?);
+        Ok(())
+    }
+
+    /// 部分ごとに保存したグラフを消す（`generation`の部分は残す）
+    fn remove_graph_parts(&mut self, generation: Option<u64>) -> Result<()> {
+        if generation.is_none() {
+            self.batch.remove(GRAPH_PARTS_KEY);
+        }
+        for key in self.storage.graph_part_keys(generation)? {
+            self.batch.remove(key);
+        }
+        Ok(())
+    }
+
+    /// グラフと、クエリ用にメモリマップする読み取り用のグラフを保存する
+    ///
+    /// 読み取り用のグラフは一時ファイルに書き、コミットしてから置き換える。作れなかった時は
+    /// グラフだけを保存する（読み手は世代が合わないので`graph`を読む）。
+    pub fn save_graph(&mut self, graph: &SerializedCodeGraph) -> Result<()> {
+        self.save_data(GRAPH_KEY, graph)?;
+
+        let generation = chrono::Utc::now().timestamp_nanos_opt().unwrap_or_default() as u64;
+        let staged = self
+            .storage
+            .db_path
+            .join(format!("{}.tmp", COMPACT_GRAPH_FILE));
+        let written = CompactGraph::build(graph, generation)
+            .and_then(|bytes| std::fs::write(&staged, bytes).map_err(Into::into));
+        match written {
+            Ok(()) => {
+                self.batch.insert(
+                    COMPACT_GRAPH_GENERATION_KEY,
+                    bincode::serialize(&generation)?,
+                );
+                self.staged_compact_graph = Some(staged);
+            }
+            Err(e) => warn!("Failed to build compact graph: {}", e),
+        }
+        Ok(())
+    }
+
+    pub fn save_metadata(&mut self, metadata: &IndexMetadata) -> Result<()> {
+        self.save_data("__metadata__", metadata)
+    }
+
+    pub fn save_staleness_manifest(&mut self, manifest: &StalenessManifest) -> Result<()> {
+        self.save_data(STALENESS_MANIFEST_KEY, manifest)
+    }
+
+    pub fn clear_staleness_manifest(&mut self) {
+        self.delete(STALENESS_MANIFEST_KEY)
+    }
+
+    pub fn delete(&mut self, key: &str) {
+        self.batch.remove(key);
+    }
+
+    /// 全ての変更を適用してディスクに書き出す
+    pub fn commit(self) -> Result<()> {
+        self.storage.db.apply_batch(self.batch)?;
+        self.storage.db.flush()?;
+        if let Some(staged) = self.staged_compact_graph {
+            if let Err(e) = std::fs::rename(&staged, self.storage.compact_graph_path()) {
+                warn!("Failed to replace compact graph: {}", e);
+            }
+        }
+        Ok(())
+    }
 }
 
 #[derive(Debug, Clone, Serialize, Deserialize)]
@@ -107,6 +396,51 @@ impl IndexStorage {
             Ok(None)
         }
     }
+
+    /// インデックス時点のプロジェクトの状態を保存
+    pub fn save_staleness_manifest(&self, manifest: &StalenessManifest) -> Result<()> {
+        self.save_data(STALENESS_MANIFEST_KEY, manifest)
+    }
+
+    /// インデックス時点のプロジェクトの状態を読み込み（古いインデックスにはない）
+    pub fn load_staleness_manifest(&self) -> Result<Option<StalenessManifest>> {
+        self.load_data(STALENESS_MANIFEST_KEY)
+    }
+
+    /// 次のコマンドで必ず差分を検出させる
+    pub fn clear_staleness_manifest(&self) -> Result<()> {
+        self.delete(STALENESS_MANIFEST_KEY)
+    }
+
+    /// 学習済みのLSP応答時間統計を保存（キー: 言語@サーバーバージョン）
+    pub fn save_lsp_timing_stats(&self, stats: &HashMap<String, LspTimingStats>) -> Result<()> {
+        self.save_data(LSP_TIMING_STATS_KEY, stats)
+    }
+
+    /// 学習済みのLSP応答時間統計を読み込み
+    pub fn load_lsp_timing_stats(&self) -> Result<HashMap<String, LspTimingStats>> {
+        Ok(self
+            .load_data::<HashMap<String, LspTimingStats>>(LSP_TIMING_STATS_KEY)?
+            .unwrap_or_default())
+    }
+
+    /// 今回の実行で学習した統計を既存の統計にマージして保存
+    pub fn merge_lsp_timing_stats(&self, learned: Vec<LspTimingStats>) -> Result<()> {
+        if learned.is_empty() {
+            return Ok(());
+        }
+
+        let mut stored = self.load_lsp_timing_stats()?;
+        for stats in learned {
+            match stored.get_mut(&stats.key()) {
+                Some(existing) => existing.merge(&stats),
+                None => {
+                    stored.insert(stats.key(), stats);
+                }
+            }
+        }
+        self.save_lsp_timing_stats(&stored)
+    }
 }
 
 #[cfg(test)]
@@ -222,6 +556,142 @@ mod tests {
         assert_eq!(loaded, None);
     }
 
+    #[test]
+    fn test_transaction() {
+        let temp_dir = TempDir::new().unwrap();
+        let storage_path = temp_dir.path().join("test_transaction.db");
+        let storage = IndexStorage::open(&storage_path).unwrap();
+        storage.save_data("graph", &"old graph").unwrap();
+        storage.save_data("stale", &"value").unwrap();
+
+        // commitしなければ何も変わらない
+        let mut transaction = storage.transaction();
+        transaction.save_data("graph", &"new graph").unwrap();
+        transaction.save_data("metadata", &1u32).unwrap();
+        drop(transaction);
+        let loaded: Option<String> = storage.load_data("graph").unwrap();
+        assert_eq!(loaded, Some("old graph".to_string()));
+        assert_eq!(storage.load_data::<u32>("metadata").unwrap(), None);
+
+        let mut transaction = storage.transaction();
+        transaction.save_data("graph", &"new graph").unwrap();
+        transaction.save_data("metadata", &1u32).unwrap();
+        transaction.delete("stale");
+        transaction.commit().unwrap();
+        let loaded: Option<String> = storage.load_data("graph").unwrap();
+        assert_eq!(loaded, Some("new graph".to_string()));
+        assert_eq!(storage.load_data::<u32>("metadata").unwrap(), Some(1));
+        assert_eq!(storage.load_data::<String>("stale").unwrap(), None);
+    }
+
+    #[test]
+    fn test_compact_graph_follows_saved_graph() {
+        use lsif_core::test_fixtures::SymbolBuilder;
+        use lsif_core::CodeGraph;
+
+        let temp_dir = TempDir::new().unwrap();
+        let storage = IndexStorage::open(temp_dir.path().join("test_compact.db")).unwrap();
+        assert!(storage.load_compact_graph().unwrap().is_none());
+
+        let mut graph = CodeGraph::new();
+        graph.add_symbol(
+            SymbolBuilder::new("src/main.rs#main", "main", "src/main.rs")
+                .range((0, 0), (2, 1))
+                .build(),
+        );
+        let mut transaction = storage.transaction();
+        transaction
+            .save_graph(&SerializedCodeGraph::from(&graph))
+            .unwrap();
+        // コミットするまでは置き換えない
+        assert!(!storage.compact_graph_path().exists());
+        transaction.commit().unwrap();
+
+        let compact = storage.load_compact_graph().unwrap().unwrap();
+        assert_eq!(compact.symbol_count(), 1);
+        assert_eq!(
+            compact.find_symbol("src/main.rs#main").unwrap().name(),
+            "main"
+        );
+
+        // 読み取り用のグラフを作らずにグラフを書き換えたら使わない
+        storage.save_data("graph", &graph).unwrap();
+        assert!(storage.load_compact_graph().unwrap().is_none());
+    }
+
+    #[test]
+    fn test_graph_parts_replace_saved_graph() {
+        use lsif_core::test_fixtures::SymbolBuilder;
+
+        let symbol = |file_path: &str| {
+            SymbolBuilder::new(&format!("{}#main", file_path), "main", file_path)
+                .range((0, 0), (2, 1))
+                .build()
+        };
+        let part = |file_path: &str| SerializedCodeGraph {
+            symbols: vec![symbol(file_path)],
+            edges: Vec::new(),
+        };
+
+        let temp_dir = TempDir::new().unwrap();
+        let storage = IndexStorage::open(temp_dir.path().join("test_parts.db")).unwrap();
+        let mut transaction = storage.transaction();
+        transaction.save_graph(&part("old.rs")).unwrap();
+        transaction.commit().unwrap();
+
+        let mut parts = GraphParts::new();
+        storage.write_graph_part(&mut parts, &part("a.rs")).unwrap();
+        storage.write_graph_part(&mut parts, &part("b.rs")).unwrap();
+        assert_eq!((parts.symbol_count, parts.file_count), (2, 2));
+        // コミットするまでは前のグラフを読む
+        assert_eq!(storage.load_graph().unwrap().unwrap().symbol_count(), 1);
+
+        let mut transaction = storage.transaction();
+        transaction.save_graph_parts(&parts).unwrap();
+        transaction.commit().unwrap();
+        let graph = storage.load_graph().unwrap().unwrap();
+        assert_eq!(graph.symbol_count(), 2);
+        assert!(graph.find_symbol("b.rs#main").is_some());
+        assert!(storage.load_compact_graph().unwrap().is_none());
+        // どの保存方式でも部分をつなげて読む
+        for backend in GraphBackend::ALL {
+            let graph = storage.load_graph_store(backend).unwrap().unwrap();
+            assert_eq!(graph.symbol_count(), 2, "{backend}");
+            assert_eq!(
+                graph.get_symbol("b.rs#main").unwrap().file_path,
+                "b.rs",
+                "{backend}"
+            );
+        }
+
+        // 1つにまとめて保存し直したら部分は消える
+        let mut transaction = storage.transaction();
+        transaction.save_graph(&part("new.rs")).unwrap();
+        transaction.commit().unwrap();
+        let graph = storage.load_graph().unwrap().unwrap();
+        assert_eq!(graph.symbol_count(), 1);
+        assert!(storage
+            .list_keys()
+            .unwrap()
+            .iter()
+            .all(|key| !key.starts_with(GRAPH_PART_PREFIX)));
+
+        // トランザクションを使わずに保存しても同じ
+        let mut parts = GraphParts::new();
+        storage.write_graph_part(&mut parts, &part("c.rs")).unwrap();
+        let mut transaction = storage.transaction();
+        transaction.save_graph_parts(&parts).unwrap();
+        transaction.commit().unwrap();
+        storage.save_data(GRAPH_KEY, &part("direct.rs")).unwrap();
+        let graph = storage.load_graph().unwrap().unwrap();
+        assert!(graph.find_symbol("direct.rs#main").is_some());
+        assert!(storage
+            .list_keys()
+            .unwrap()
+            .iter()
+            .all(|key| key != GRAPH_PARTS_KEY && !key.starts_with(GRAPH_PART_PREFIX)));
+    }
+
     #[test]
     fn test_metadata_save_and_load() {
         let temp_dir = TempDir::new().unwrap();
@@ -267,6 +737,37 @@ mod tests {
         assert!(loaded.is_none());
     }
 
+    #[test]
+    fn test_lsp_timing_stats_merge_and_load() {
+        use lsp::lsp_health_check::LspOperationType;
+        use std::time::Duration;
+
+        let temp_dir = TempDir::new().unwrap();
+        let storage_path = temp_dir.path().join("test_lsp_stats.db");
+        let storage = IndexStorage::open(&storage_path).unwrap();
+
+        assert!(storage.load_lsp_timing_stats().unwrap().is_empty());
+
+        let mut first = LspTimingStats::new("rust");
+        first.server_version = Some("1.0.0".to_string());
+        first.record_init(Duration::from_millis(900));
+        storage.merge_lsp_timing_stats(vec![first]).unwrap();
+
+        let mut second = LspTimingStats::new("rust");
+        second.server_version = Some("1.0.0".to_string());
+        second.record_init(Duration::from_millis(1100));
+        second.record_operation(LspOperationType::DocumentSymbol, Duration::from_millis(40));
+        storage.merge_lsp_timing_stats(vec![second]).unwrap();
+
+        let loaded = storage.load_lsp_timing_stats().unwrap();
+        assert_eq!(loaded.len(), 1);
+        let stats = &loaded["rust@1.0.0"];
+        assert_eq!(stats.init_times_ms, vec![900, 1100]);
+        assert!(stats
+            .operation_percentiles(LspOperationType::DocumentSymbol)
+            .is_some());
+    }
+
     #[test]
     fn test_index_format() {
         // IndexFormatのシリアライズ/デシリアライズをテスト
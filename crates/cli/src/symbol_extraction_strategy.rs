@@ -1,6 +1,9 @@
 /// シンボル抽出戦略パターン
 use anyhow::Result;
-use lsif_core::Symbol;
+use lsif_core::{Symbol, SymbolModifiers};
+use lsp::lsp_helpers::{
+    declaration_symbol_modifiers, document_symbol_modifiers, SourceDocComments,
+};
 use std::path::Path;
 use tracing::{debug, info, warn};
 
@@ -86,7 +89,11 @@ impl SymbolExtractionStrategy for LspExtractionStrategy {
         let lsp_symbols = client.get_document_symbols(&file_uri)?;
 
         // LSPシンボルをコアのSymbol型に変換
-        Ok(convert_lsp_symbols_to_core(&lsp_symbols, path))
+        Ok(convert_lsp_symbols_to_core(
+            &lsp_symbols,
+            path,
+            document_symbol_modifiers,
+        ))
     }
 
     fn priority(&self) -> u32 {
@@ -94,8 +101,61 @@ impl SymbolExtractionStrategy for LspExtractionStrategy {
     }
 }
 
-// 正規表現ベースのフォールバック実装は削除
-// LSPベースの実装のみを使用
+/// tree-sitterベースの抽出戦略（LSPが使えない時のデフォルト）
+///
+/// 定義全体の範囲を返すので、入れ子は`CodeGraph::link_containment`で復元できる。
+pub struct TreeSitterExtractionStrategy;
+
+impl SymbolExtractionStrategy for TreeSitterExtractionStrategy {
+    fn name(&self) -> &str {
+        "TreeSitter"
+    }
+
+    fn supports(&self, path: &Path) -> bool {
+        lsp::tree_sitter_parser::TreeSitterParser::supports_extension(path)
+    }
+
+    fn extract(&self, path: &Path) -> Result<Vec<Symbol>> {
+        // Parserはスレッド間で共有できないので呼び出しごとに作成
+        let mut parser = lsp::tree_sitter_parser::TreeSitterParser::from_extension(path)
+            .ok_or_else(|| anyhow::anyhow!("No tree-sitter grammar for {}", path.display()))?;
+        let source = std::fs::read_to_string(path)?;
+        parser.extract_outline(&source, &path.to_string_lossy())
+    }
+
+    fn priority(&self) -> u32 {
+        50
+    }
+}
+
+/// 正規表現ベースの抽出戦略（最後の手段）
+pub struct RegexExtractionStrategy;
+
+impl SymbolExtractionStrategy for RegexExtractionStrategy {
+    fn name(&self) -> &str {
+        "Regex"
+    }
+
+    fn supports(&self, path: &Path) -> bool {
+        lsp::fallback_indexer::FallbackIndexer::from_extension(path).is_some()
+    }
+
+    fn extract(&self, path: &Path) -> Result<Vec<Symbol>> {
+        let indexer = lsp::fallback_indexer::FallbackIndexer::from_extension(path)
+            .ok_or_else(|| anyhow::anyhow!("No fallback indexer for {}", path.display()))?;
+        let lsp_symbols = indexer.extract_symbols(path)?;
+        // detailには宣言行が入っているので可視性もそこから判定する
+        Ok(convert_lsp_symbols_to_core(
+            &lsp_symbols,
+            path,
+            declaration_symbol_modifiers,
+        ))
+    }
+
+    fn priority(&self) -> u32 {
+        10
+    }
+}
 
 /// チェーンオブレスポンシビリティパターンでの抽出器
 pub struct ChainedSymbolExtractor {
@@ -109,6 +169,13 @@ impl ChainedSymbolExtractor {
         }
     }
 
+    /// tree-sitter → 正規表現の順に試すフォールバックチェーン
+    pub fn with_fallbacks() -> Self {
+        Self::new()
+            .add_strategy(Box::new(TreeSitterExtractionStrategy))
+            .add_strategy(Box::new(RegexExtractionStrategy))
+    }
+
     /// 戦略を追加
     pub fn add_strategy(mut self, strategy: Box<dyn SymbolExtractionStrategy>) -> Self {
         self.strategies.push(strategy);
@@ -119,7 +186,12 @@ impl ChainedSymbolExtractor {
     }
 
     /// シンボルを抽出（最初に成功した戦略の結果を返す）
+    ///
+    /// 対応する戦略がすべて失敗した場合はエラーを返す（呼び出し側で失敗として記録する）。
+    /// 対応する戦略がない、またはシンボルがなかった場合は空の結果を返す。
     pub fn extract(&self, path: &Path) -> Result<Vec<Symbol>> {
+        let mut errors = Vec::new();
+        let mut succeeded = false;
         for strategy in &self.strategies {
             if !strategy.supports(path) {
                 debug!(
@@ -152,6 +224,7 @@ impl ChainedSymbolExtractor {
                         strategy.name(),
                         path.display()
                     );
+                    succeeded = true;
                 }
                 Err(e) => {
                     warn!(
@@ -160,13 +233,20 @@ impl ChainedSymbolExtractor {
                         path.display(),
                         e
                     );
+                    errors.push(format!("{}: {}", strategy.name(), e));
                 }
             }
         }
 
+        if succeeded || errors.is_empty() {
+            return Ok(Vec::new());
+        }
         // すべての戦略が失敗した場合
-        warn!("All extraction strategies failed for {}", path.display());
-        Ok(Vec::new())
+        Err(anyhow::anyhow!(
+            "All extraction strategies failed for {} ({})",
+            path.display(),
+            errors.join("; ")
+        ))
     }
 
     /// 戦略の数を取得
@@ -181,29 +261,39 @@ impl Default for ChainedSymbolExtractor {
     }
 }
 
+/// 修飾子の判定（`document_symbol_modifiers`か`declaration_symbol_modifiers`）
+type ModifiersFn =
+    fn(&lsp_types::DocumentSymbol, &str, Option<&lsp_types::DocumentSymbol>) -> SymbolModifiers;
+
 /// LSPシンボルをコアのSymbol型に変換（ヘルパー関数）
+///
+/// detailはシグネチャか宣言行なので`Symbol.detail`に入れ、ドキュメントコメントはソースから付ける。
 fn convert_lsp_symbols_to_core(
     lsp_symbols: &[lsp_types::DocumentSymbol],
     path: &Path,
+    modifiers: ModifiersFn,
 ) -> Vec<Symbol> {
     use lsif_core::{Position, Range};
 
     let mut symbols = Vec::new();
     let file_path = path.to_string_lossy().to_string();
+    let docs = SourceDocComments::load(path);
 
     fn convert_symbol(
         symbol: &lsp_types::DocumentSymbol,
         file_path: &str,
-        parent_name: Option<&str>,
+        parent: Option<(&lsp_types::DocumentSymbol, &str)>,
+        modifiers: ModifiersFn,
+        docs: &SourceDocComments,
         results: &mut Vec<Symbol>,
     ) {
-        let full_name = if let Some(parent) = parent_name {
-            format!("{}::{}", parent, symbol.name)
+        let full_name = if let Some((_, parent_name)) = parent {
+            format!("{}::{}", parent_name, symbol.name)
         } else {
             symbol.name.clone()
         };
 
-        let core_symbol = Symbol {
+        let mut core_symbol = Symbol {
             id: format!("{}#{}:{}", file_path, symbol.range.start.line, full_name),
             name: symbol.name.clone(),
             kind: convert_symbol_kind(symbol.kind),
@@ -218,22 +308,32 @@ fn convert_lsp_symbols_to_core(
                     character: symbol.range.end.character,
                 },
             },
-            documentation: symbol.detail.clone(),
-            detail: None,
+            documentation: None,
+            detail: symbol.detail.clone(),
+            modifiers: modifiers(symbol, file_path, parent.map(|(p, _)| p)),
+            qualified_name: None,
         };
+        docs.attach(symbol, &mut core_symbol);
 
         results.push(core_symbol);
 
         // 子シンボルを再帰的に処理
         if let Some(children) = &symbol.children {
             for child in children {
-                convert_symbol(child, file_path, Some(&full_name), results);
+                convert_symbol(
+                    child,
+                    file_path,
+                    Some((symbol, &full_name)),
+                    modifiers,
+                    docs,
+                    results,
+                );
             }
         }
     }
 
     for symbol in lsp_symbols {
-        convert_symbol(symbol, &file_path, None, &mut symbols);
+        convert_symbol(symbol, &file_path, None, modifiers, &docs, &mut symbols);
     }
 
     symbols
@@ -333,4 +433,44 @@ mod tests {
         assert_eq!(extractor.strategies[1].name(), "Medium");
         assert_eq!(extractor.strategies[2].name(), "Low");
     }
+
+    #[test]
+    fn test_fallback_chain_prefers_tree_sitter() {
+        let dir = tempfile::tempdir().unwrap();
+        let path = dir.path().join("lib.rs");
+        std::fs::write(
+            &path,
+            "impl Foo {\n    fn bar(\n        &self,\n    ) {}\n}\n",
+        )
+        .unwrap();
+
+        let extractor = ChainedSymbolExtractor::with_fallbacks();
+        assert_eq!(extractor.strategies[0].name(), "TreeSitter");
+        assert_eq!(extractor.strategies[1].name(), "Regex");
+
+        let symbols = extractor.extract(&path).unwrap();
+        let bar = symbols.iter().find(|s| s.name == "bar").unwrap();
+        assert_eq!(bar.kind, lsif_core::SymbolKind::Method);
+        // 複数行のシグネチャでも定義全体の範囲を持つ
+        assert_eq!(bar.range.start.line, 1);
+        assert_eq!(bar.range.end.line, 3);
+
+        // 拡張子が未対応なら正規表現も含めて対象外
+        let unknown = dir.path().join("notes.txt");
+        std::fs::write(&unknown, "fn main() {}").unwrap();
+        assert!(extractor.extract(&unknown).unwrap().is_empty());
+
+        // 対応する戦略がすべて失敗したらエラー（UTF-8でないファイルは読めない）
+        let broken = dir.path().join("broken.rs");
+        std::fs::write(&broken, b"fn main() {}\n\xff\xfe").unwrap();
+        let error = extractor.extract(&broken).unwrap_err();
+        assert!(error
+            .to_string()
+            .contains("All extraction strategies failed"));
+
+        // 空のファイルは失敗ではない
+        let empty = dir.path().join("empty.rs");
+        std::fs::write(&empty, "").unwrap();
+        assert!(extractor.extract(&empty).unwrap().is_empty());
+    }
 }
@@ -1,6 +1,7 @@
 use anyhow::Result;
 use lsif_core::{CodeGraph, Symbol, SymbolKind};
 use petgraph::visit::EdgeRef;
+use rayon::prelude::*;
 use regex::Regex;
 
 /// Type-based search filters
@@ -43,19 +44,13 @@ impl<'a> TypeSearchEngine<'a> {
     }
 
     /// Search symbols by type filters
+    ///
+    /// Symbols are matched in parallel on the current rayon thread pool. The results are
+    /// the first `max_results` matches in graph order, whatever the number of threads.
     pub fn search(&self, filters: &[TypeFilter], max_results: usize) -> Vec<Symbol> {
-        let mut results = Vec::new();
-
-        for symbol in self.graph.get_all_symbols() {
-            if self.matches_all_filters(symbol, filters) {
-                results.push(symbol.clone());
-                if results.len() >= max_results {
-                    break;
-                }
-            }
-        }
-
-        results
+        first_matches(self.graph, max_results, |symbol| {
+            self.matches_all_filters(symbol, filters)
+        })
     }
 
     /// Check if symbol matches all filters
@@ -149,7 +144,7 @@ impl<'a> TypeSearchEngine<'a> {
         // Find all fields/properties of this symbol
         if let Some(node_idx) = self.graph.get_node_index(&symbol.id) {
             for edge in self.graph.graph.edges(node_idx) {
-                if matches!(edge.weight(), lsif_core::EdgeKind::Contains) {
+                if matches!(edge.weight().kind, lsif_core::EdgeKind::Contains) {
                     if let Some(field) = self.graph.graph.node_weight(edge.target()) {
                         if matches!(field.kind, SymbolKind::Field | SymbolKind::Property) {
                             if let Some(detail) = &field.detail {
@@ -220,7 +215,7 @@ impl<'a> TypeSearchEngine<'a> {
         if let Some(node_idx) = self.graph.get_node_index(&symbol.id) {
             // Check outgoing Implementation edges
             for edge in self.graph.graph.edges(node_idx) {
-                if matches!(edge.weight(), lsif_core::EdgeKind::Implementation) {
+                if matches!(edge.weight().kind, lsif_core::EdgeKind::Implementation) {
                     if let Some(target) = self.graph.graph.node_weight(edge.target()) {
                         if target.name.contains(type_name) {
                             return true;
@@ -255,35 +250,55 @@ impl<'a> AdvancedSearch<'a> {
         fuzzy: bool,
         max_results: usize,
     ) -> Vec<Symbol> {
-        let mut results = Vec::new();
+        let lowercase_pattern = name_pattern.map(str::to_lowercase);
 
-        for symbol in self.graph.get_all_symbols() {
+        first_matches(self.graph, max_results, |symbol| {
             // Check name pattern
             if let Some(pattern) = name_pattern {
                 if fuzzy {
-                    if !symbol.name.to_lowercase().contains(&pattern.to_lowercase()) {
-                        continue;
+                    let pattern = lowercase_pattern.as_deref().unwrap_or(pattern);
+                    if !symbol.name.to_lowercase().contains(pattern) {
+                        return false;
                     }
                 } else if !symbol.name.contains(pattern) {
-                    continue;
+                    return false;
                 }
             }
 
             // Check type filters
-            if !type_filters.is_empty()
-                && !self.type_engine.matches_all_filters(symbol, type_filters)
-            {
-                continue;
-            }
+            type_filters.is_empty() || self.type_engine.matches_all_filters(symbol, type_filters)
+        })
+    }
+}
 
-            results.push(symbol.clone());
-            if results.len() >= max_results {
-                break;
-            }
+/// The first `max_results` symbols in graph order that satisfy `predicate`, checked in parallel
+///
+/// Symbols are checked in chunks so that a small `max_results` stops the scan early.
+fn first_matches<F>(graph: &CodeGraph, max_results: usize, predicate: F) -> Vec<Symbol>
+where
+    F: Fn(&Symbol) -> bool + Sync,
+{
+    const CHUNK_SIZE: usize = 4096;
+
+    let symbols: Vec<&Symbol> = graph.get_all_symbols().collect();
+    let mut results = Vec::new();
+    for chunk in symbols.chunks(CHUNK_SIZE) {
+        if results.len() >= max_results {
+            break;
         }
-
-        results
+        let matches: Vec<&Symbol> = chunk
+            .par_iter()
+            .copied()
+            .filter(|symbol| predicate(symbol))
+            .collect();
+        results.extend(
+            matches
+                .into_iter()
+                .take(max_results - results.len())
+                .cloned(),
+        );
     }
+    results
 }
 
 #[cfg(test)]
@@ -298,4 +313,68 @@ mod tests {
         let filter = TypeFilter::from_arg("implements", "Iterator").unwrap();
         assert!(matches!(filter, TypeFilter::Implements(s) if s == "Iterator"));
     }
+
+    #[test]
+    fn test_search_keeps_graph_order_with_any_thread_count() {
+        use lsif_core::{Position, Range};
+
+        let mut graph = CodeGraph::new();
+        for i in 0..10_000 {
+            let returns = if i % 3 == 0 {
+                "Result<String>"
+            } else {
+                "usize"
+            };
+            graph.add_symbol(Symbol {
+                id: format!("src/lib.rs#f{}", i),
+                kind: SymbolKind::Function,
+                name: format!("handler_{}", i),
+                file_path: "src/lib.rs".to_string(),
+                range: Range {
+                    start: Position {
+                        line: i,
+                        character: 0,
+                    },
+                    end: Position {
+                        line: i,
+                        character: 10,
+                    },
+                },
+                documentation: None,
+                detail: Some(format!("fn handler_{}() -> {}", i, returns)),
+                modifiers: Default::default(),
+                qualified_name: None,
+            });
+        }
+        let filters = [TypeFilter::Returns("Result".to_string())];
+
+        let run = |threads: usize, max_results: usize| -> Vec<String> {
+            let pool = rayon::ThreadPoolBuilder::new()
+                .num_threads(threads)
+                .build()
+                .unwrap();
+            pool.install(|| TypeSearchEngine::new(&graph).search(&filters, max_results))
+                .into_iter()
+                .map(|symbol| symbol.name)
+                .collect()
+        };
+        let sequential = run(1, 2000);
+        assert_eq!(sequential.len(), 2000);
+        assert_eq!(sequential[..2], ["handler_0", "handler_3"]);
+        assert_eq!(run(4, 2000), sequential);
+        assert_eq!(run(4, 3334).len(), 3334);
+
+        let fuzzy = AdvancedSearch::new(&graph).search(Some("HANDLER_99"), &filters, true, 5);
+        let names: Vec<&str> = fuzzy.iter().map(|symbol| symbol.name.as_str()).collect();
+        assert_eq!(
+            names,
+            [
+                "handler_99",
+                "handler_990",
+                "handler_993",
+                "handler_996",
+                "handler_999"
+            ]
+        );
+    }
 }
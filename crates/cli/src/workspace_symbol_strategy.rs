@@ -4,6 +4,9 @@
 /// プロジェクト全体のシンボルを効率的に取得する
 use anyhow::Result;
 use lsif_core::Symbol;
+use lsp::lsp_helpers::{
+    document_symbol_modifiers, symbol_information_modifiers, SourceDocComments,
+};
 use std::collections::HashSet;
 use std::path::{Path, PathBuf};
 use std::sync::{Arc, Mutex};
@@ -105,20 +108,8 @@ impl WorkspaceSymbolExtractionStrategy {
         client: &mut lsp::adapter::lsp::GenericLspClient,
         query: &str,
     ) -> Result<Vec<lsp_types::SymbolInformation>> {
-        use lsp_types::{PartialResultParams, WorkDoneProgressParams, WorkspaceSymbolParams};
-
-        let params = WorkspaceSymbolParams {
-            query: query.to_string(),
-            work_done_progress_params: WorkDoneProgressParams::default(),
-            partial_result_params: PartialResultParams::default(),
-        };
-
-        client
-            .send_request::<_, Option<Vec<lsp_types::SymbolInformation>>>(
-                "workspace/symbol",
-                params,
-            )?
-            .ok_or_else(|| anyhow::anyhow!("No workspace symbols returned"))
+        // 位置はクライアントが正規のエンコーディング（UTF-8バイト）に変換して返す
+        client.search_workspace_symbols(query)
     }
 
     /// WorkspaceSymbolをコアのSymbol型に変換
@@ -156,6 +147,8 @@ impl WorkspaceSymbolExtractionStrategy {
             },
             documentation: ws_symbol.container_name.clone(),
             detail: None,
+            modifiers: symbol_information_modifiers(ws_symbol),
+            qualified_name: None,
         })
     }
 }
@@ -254,13 +247,16 @@ fn convert_document_symbols_to_core(
 
     let mut symbols = Vec::new();
     let file_path = path.to_string_lossy().to_string();
+    let docs = SourceDocComments::load(path);
 
     fn process_symbol(
         symbol: &lsp_types::DocumentSymbol,
+        parent: Option<&lsp_types::DocumentSymbol>,
         file_path: &str,
+        docs: &SourceDocComments,
         symbols: &mut Vec<Symbol>,
     ) {
-        symbols.push(Symbol {
+        let mut core_symbol = Symbol {
             id: format!(
                 "{}#{}:{}",
                 file_path, symbol.selection_range.start.line, symbol.name
@@ -278,20 +274,25 @@ fn convert_document_symbols_to_core(
                     character: symbol.range.end.character,
                 },
             },
-            documentation: symbol.detail.clone(),
-            detail: None,
-        });
+            // detailはシグネチャ（ドキュメントコメントはソースから付ける）
+            documentation: None,
+            detail: symbol.detail.clone(),
+            modifiers: document_symbol_modifiers(symbol, file_path, parent),
+            qualified_name: None,
+        };
+        docs.attach(symbol, &mut core_symbol);
+        symbols.push(core_symbol);
 
         // 子シンボルも処理
         if let Some(children) = &symbol.children {
             for child in children {
-                process_symbol(child, file_path, symbols);
+                process_symbol(child, Some(symbol), file_path, docs, symbols);
             }
         }
     }
 
     for symbol in lsp_symbols {
-        process_symbol(symbol, &file_path, &mut symbols);
+        process_symbol(symbol, None, &file_path, &docs, &mut symbols);
     }
 
     symbols
@@ -569,6 +570,8 @@ mod tests {
             },
             documentation: ws_symbol.container_name.clone(),
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         };
 
         assert_eq!(symbol.name, "globalFunction");
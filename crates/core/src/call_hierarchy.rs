@@ -1,5 +1,5 @@
-use super::graph::{CodeGraph, EdgeKind, Symbol};
-use petgraph::visit::EdgeRef;
+use super::graph::{CodeGraph, EdgeKind, Range, Symbol};
+use super::graph_store::GraphStore;
 use petgraph::Direction;
 use std::collections::HashSet;
 
@@ -9,34 +9,36 @@ pub struct CallHierarchy {
     pub callers: Vec<CallHierarchy>,
     pub callees: Vec<CallHierarchy>,
     pub depth: usize,
+    /// Where the call happens, if the edge recorded it (None at the root)
+    pub call_site: Option<Range>,
 }
 
-pub struct CallHierarchyAnalyzer<'a> {
-    graph: &'a CodeGraph,
+pub struct CallHierarchyAnalyzer<'a, G: GraphStore + ?Sized = CodeGraph> {
+    graph: &'a G,
 }
 
-impl<'a> CallHierarchyAnalyzer<'a> {
-    pub fn new(graph: &'a CodeGraph) -> Self {
+impl<'a, G: GraphStore + ?Sized> CallHierarchyAnalyzer<'a, G> {
+    pub fn new(graph: &'a G) -> Self {
         Self { graph }
     }
 
     /// Get incoming calls (who calls this function)
     pub fn get_incoming_calls(&self, symbol_id: &str, max_depth: usize) -> Option<CallHierarchy> {
-        let _symbol = self.graph.find_symbol(symbol_id)?;
+        let _symbol = self.graph.get_symbol(symbol_id)?;
         let mut visited = HashSet::new();
         self.build_incoming_hierarchy(symbol_id, &mut visited, 0, max_depth)
     }
 
     /// Get outgoing calls (what this function calls)
     pub fn get_outgoing_calls(&self, symbol_id: &str, max_depth: usize) -> Option<CallHierarchy> {
-        let _symbol = self.graph.find_symbol(symbol_id)?;
+        let _symbol = self.graph.get_symbol(symbol_id)?;
         let mut visited = HashSet::new();
         self.build_outgoing_hierarchy(symbol_id, &mut visited, 0, max_depth)
     }
 
     /// Get full call hierarchy (both incoming and outgoing)
     pub fn get_full_hierarchy(&self, symbol_id: &str, max_depth: usize) -> Option<CallHierarchy> {
-        let symbol = self.graph.find_symbol(symbol_id)?.clone();
+        let symbol = self.graph.get_symbol(symbol_id)?.into_owned();
         let mut visited_in = HashSet::new();
         let mut visited_out = HashSet::new();
 
@@ -48,6 +50,7 @@ impl<'a> CallHierarchyAnalyzer<'a> {
             callers,
             callees,
             depth: 0,
+            call_site: None,
         })
     }
 
@@ -63,7 +66,7 @@ impl<'a> CallHierarchyAnalyzer<'a> {
         }
 
         visited.insert(symbol_id.to_string());
-        let symbol = self.graph.find_symbol(symbol_id)?.clone();
+        let symbol = self.graph.get_symbol(symbol_id)?.into_owned();
 
         let callers = if depth < max_depth {
             self.get_callers(symbol_id, visited, depth + 1, max_depth)
@@ -76,6 +79,7 @@ impl<'a> CallHierarchyAnalyzer<'a> {
             callers,
             callees: Vec::new(),
             depth,
+            call_site: None,
         })
     }
 
@@ -91,7 +95,7 @@ impl<'a> CallHierarchyAnalyzer<'a> {
         }
 
         visited.insert(symbol_id.to_string());
-        let symbol = self.graph.find_symbol(symbol_id)?.clone();
+        let symbol = self.graph.get_symbol(symbol_id)?.into_owned();
 
         let callees = if depth < max_depth {
             self.get_callees(symbol_id, visited, depth + 1, max_depth)
@@ -104,6 +108,7 @@ impl<'a> CallHierarchyAnalyzer<'a> {
             callers: Vec::new(),
             callees,
             depth,
+            call_site: None,
         })
     }
 
@@ -116,23 +121,13 @@ impl<'a> CallHierarchyAnalyzer<'a> {
     ) -> Vec<CallHierarchy> {
         let mut callers = Vec::new();
 
-        if let Some(node_idx) = self.graph.get_node_index(symbol_id) {
-            for edge in self
-                .graph
-                .graph
-                .edges_directed(node_idx, Direction::Incoming)
-            {
-                if matches!(edge.weight(), EdgeKind::Reference) {
-                    let source_idx = edge.source();
-                    if let Some(caller) = self.graph.graph.node_weight(source_idx) {
-                        if !visited.contains(&caller.id) {
-                            if let Some(hierarchy) =
-                                self.build_incoming_hierarchy(&caller.id, visited, depth, max_depth)
-                            {
-                                callers.push(hierarchy);
-                            }
-                        }
-                    }
+        for (caller, edge) in self.graph.edges_of(symbol_id, Direction::Incoming) {
+            if matches!(edge.kind, EdgeKind::Reference) && !visited.contains(&caller.id) {
+                if let Some(mut hierarchy) =
+                    self.build_incoming_hierarchy(&caller.id, visited, depth, max_depth)
+                {
+                    hierarchy.call_site = edge.range;
+                    callers.push(hierarchy);
                 }
             }
         }
@@ -149,23 +144,13 @@ impl<'a> CallHierarchyAnalyzer<'a> {
     ) -> Vec<CallHierarchy> {
         let mut callees = Vec::new();
 
-        if let Some(node_idx) = self.graph.get_node_index(symbol_id) {
-            for edge in self
-                .graph
-                .graph
-                .edges_directed(node_idx, Direction::Outgoing)
-            {
-                if matches!(edge.weight(), EdgeKind::Reference) {
-                    let target_idx = edge.target();
-                    if let Some(callee) = self.graph.graph.node_weight(target_idx) {
-                        if !visited.contains(&callee.id) {
-                            if let Some(hierarchy) =
-                                self.build_outgoing_hierarchy(&callee.id, visited, depth, max_depth)
-                            {
-                                callees.push(hierarchy);
-                            }
-                        }
-                    }
+        for (callee, edge) in self.graph.edges_of(symbol_id, Direction::Outgoing) {
+            if matches!(edge.kind, EdgeKind::Reference) && !visited.contains(&callee.id) {
+                if let Some(mut hierarchy) =
+                    self.build_outgoing_hierarchy(&callee.id, visited, depth, max_depth)
+                {
+                    hierarchy.call_site = edge.range;
+                    callees.push(hierarchy);
                 }
             }
         }
@@ -214,30 +199,19 @@ impl<'a> CallHierarchyAnalyzer<'a> {
 
         visited.insert(current.to_string());
 
-        if let Some(node_idx) = self.graph.get_node_index(current) {
-            for edge in self
-                .graph
-                .graph
-                .edges_directed(node_idx, Direction::Outgoing)
-            {
-                if matches!(edge.weight(), EdgeKind::Reference) {
-                    let target_idx = edge.target();
-                    if let Some(next_symbol) = self.graph.graph.node_weight(target_idx) {
-                        if !visited.contains(&next_symbol.id) {
-                            current_path.push(next_symbol.id.clone());
-                            self.dfs_paths(
-                                &next_symbol.id,
-                                target,
-                                current_path,
-                                visited,
-                                all_paths,
-                                depth + 1,
-                                max_depth,
-                            );
-                            current_path.pop();
-                        }
-                    }
-                }
+        for (next_symbol, edge) in self.graph.edges_of(current, Direction::Outgoing) {
+            if matches!(edge.kind, EdgeKind::Reference) && !visited.contains(&next_symbol.id) {
+                current_path.push(next_symbol.id.clone());
+                self.dfs_paths(
+                    &next_symbol.id,
+                    target,
+                    current_path,
+                    visited,
+                    all_paths,
+                    depth + 1,
+                    max_depth,
+                );
+                current_path.pop();
             }
         }
 
@@ -257,9 +231,15 @@ pub fn format_hierarchy(hierarchy: &CallHierarchy, prefix: &str, is_last: bool)
         "├── "
     };
 
+    // Show the call site 1-based, like the other commands
+    let call_site = hierarchy
+        .call_site
+        .map(|range| format!(" ({}:{})", range.start.line + 1, range.start.character + 1))
+        .unwrap_or_default();
+
     result.push_str(&format!(
-        "{}{}{}\n",
-        prefix, connector, hierarchy.symbol.name
+        "{}{}{}{}\n",
+        prefix, connector, hierarchy.symbol.name, call_site
     ));
 
     let new_prefix = if hierarchy.depth == 0 {
@@ -288,7 +268,9 @@ pub fn format_hierarchy(hierarchy: &CallHierarchy, prefix: &str, is_last: bool)
 #[cfg(test)]
 mod tests {
     use super::*;
-    use crate::graph::{Position, Range, Symbol, SymbolKind};
+    use crate::edge::{Edge, EdgeSource, ReferenceAccess};
+    use crate::graph::{Position, Symbol, SymbolKind};
+    use crate::graph_store::GraphBackend;
 
     fn create_test_graph() -> CodeGraph {
         let mut graph = CodeGraph::new();
@@ -311,6 +293,8 @@ mod tests {
             },
             documentation: None,
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         };
 
         let calc_sym = Symbol {
@@ -330,6 +314,8 @@ mod tests {
             },
             documentation: None,
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         };
 
         let add_sym = Symbol {
@@ -349,6 +335,8 @@ mod tests {
             },
             documentation: None,
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         };
 
         // Add symbols to graph
@@ -357,7 +345,21 @@ mod tests {
         let add_idx = graph.add_symbol(add_sym);
 
         // Add edges (main -> calculate -> add)
-        graph.add_edge(main_idx, calc_idx, EdgeKind::Reference);
+        let call_site = Range {
+            start: Position {
+                line: 3,
+                character: 4,
+            },
+            end: Position {
+                line: 3,
+                character: 13,
+            },
+        };
+        graph.add_edge_with(
+            main_idx,
+            calc_idx,
+            Edge::reference(EdgeSource::Lsp, Some(ReferenceAccess::Call)).with_range(call_site),
+        );
         graph.add_edge(calc_idx, add_idx, EdgeKind::Reference);
 
         graph
@@ -365,50 +367,58 @@ mod tests {
 
     #[test]
     fn test_outgoing_calls() {
-        let graph = create_test_graph();
-        let analyzer = CallHierarchyAnalyzer::new(&graph);
-
-        let hierarchy = analyzer.get_outgoing_calls("main", 2).unwrap();
-        assert_eq!(hierarchy.symbol.name, "main");
-        assert_eq!(hierarchy.callees.len(), 1);
-        assert_eq!(hierarchy.callees[0].symbol.name, "calculate");
-        assert_eq!(hierarchy.callees[0].callees.len(), 1);
-        assert_eq!(hierarchy.callees[0].callees[0].symbol.name, "add");
+        for backend in GraphBackend::ALL {
+            let graph = backend.load(create_test_graph());
+            let analyzer = CallHierarchyAnalyzer::new(&*graph);
+
+            let hierarchy = analyzer.get_outgoing_calls("main", 2).unwrap();
+            assert_eq!(hierarchy.symbol.name, "main");
+            assert_eq!(hierarchy.callees.len(), 1);
+            assert_eq!(hierarchy.callees[0].symbol.name, "calculate");
+            assert_eq!(hierarchy.callees[0].callees.len(), 1);
+            assert_eq!(hierarchy.callees[0].callees[0].symbol.name, "add");
+        }
     }
 
     #[test]
     fn test_incoming_calls() {
-        let graph = create_test_graph();
-        let analyzer = CallHierarchyAnalyzer::new(&graph);
-
-        let hierarchy = analyzer.get_incoming_calls("add", 2).unwrap();
-        assert_eq!(hierarchy.symbol.name, "add");
-        assert_eq!(hierarchy.callers.len(), 1);
-        assert_eq!(hierarchy.callers[0].symbol.name, "calculate");
-        assert_eq!(hierarchy.callers[0].callers.len(), 1);
-        assert_eq!(hierarchy.callers[0].callers[0].symbol.name, "main");
+        for backend in GraphBackend::ALL {
+            let graph = backend.load(create_test_graph());
+            let analyzer = CallHierarchyAnalyzer::new(&*graph);
+
+            let hierarchy = analyzer.get_incoming_calls("add", 2).unwrap();
+            assert_eq!(hierarchy.symbol.name, "add");
+            assert_eq!(hierarchy.callers.len(), 1);
+            assert_eq!(hierarchy.callers[0].symbol.name, "calculate");
+            assert_eq!(hierarchy.callers[0].callers.len(), 1);
+            assert_eq!(hierarchy.callers[0].callers[0].symbol.name, "main");
+        }
     }
 
     #[test]
     fn test_call_paths() {
-        let graph = create_test_graph();
-        let analyzer = CallHierarchyAnalyzer::new(&graph);
+        for backend in GraphBackend::ALL {
+            let graph = backend.load(create_test_graph());
+            let analyzer = CallHierarchyAnalyzer::new(&*graph);
 
-        let paths = analyzer.find_call_paths("main", "add", 3);
-        assert_eq!(paths.len(), 1);
-        assert_eq!(paths[0], vec!["main", "calculate", "add"]);
+            let paths = analyzer.find_call_paths("main", "add", 3);
+            assert_eq!(paths.len(), 1);
+            assert_eq!(paths[0], vec!["main", "calculate", "add"]);
+        }
     }
 
     #[test]
     fn test_format_hierarchy() {
-        let graph = create_test_graph();
-        let analyzer = CallHierarchyAnalyzer::new(&graph);
+        for backend in GraphBackend::ALL {
+            let graph = backend.load(create_test_graph());
+            let analyzer = CallHierarchyAnalyzer::new(&*graph);
 
-        let hierarchy = analyzer.get_outgoing_calls("main", 2).unwrap();
-        let formatted = format_hierarchy(&hierarchy, "", true);
+            let hierarchy = analyzer.get_outgoing_calls("main", 2).unwrap();
+            let formatted = format_hierarchy(&hierarchy, "", true);
 
-        assert!(formatted.contains("main"));
-        assert!(formatted.contains("└── calculate"));
-        assert!(formatted.contains("    └── add"));
+            assert!(formatted.contains("main"));
+            assert!(formatted.contains("└── calculate (4:5)"));
+            assert!(formatted.contains("    └── add"));
+        }
     }
 }
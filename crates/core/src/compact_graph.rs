@@ -0,0 +1,859 @@
+//! 読み取り専用のコンパクトなグラフ
+//!
+//! クエリのコマンドはグラフを読むだけなので、`CodeGraph`（`StableDiGraph`と文字列IDの
+//! `HashMap`）を組み立てる代わりに、保存時に作ったこの形式をメモリマップして使う。
+//! 文字列は1つの表にまとめて番号で参照し、シンボルはIDの順に並べた番号（`SymbolId`）で引く。
+//! エッジは出る方向と入る方向のCSR（シンボルごとの範囲を指すオフセットの配列と、
+//! エッジを詰めて並べた配列）で持つ。読み込みはヘッダと各区画の大きさを確かめるだけで、
+//! 値は使う時にバイト列から読む。列挙の順序は`CodeGraph`と同じ（保存した時の順のシンボルと、
+//! シンボルごとに新しい順のエッジ）なので、どちらを読んでもコマンドの出力は変わらない。
+//!
+//! 配置（数値はすべてリトルエンディアン）:
+//!
+//! | 区画 | 内容 |
+//! |------|------|
+//! | ヘッダ | マジック・バージョン・世代・各区画の要素数 |
+//! | 文字列のオフセット | `u32` × (文字列数 + 1) |
+//! | 文字列 | UTF-8を連結したもの（4バイト境界まで詰める） |
+//! | シンボル | 固定長のレコード（IDの順） |
+//! | 保存した順 | `u32` × シンボル数（保存した時の順に並べたシンボルの番号） |
+//! | 出るエッジのオフセット | `u32` × (シンボル数 + 1) |
+//! | 出るエッジ | 固定長のレコード（参照先・範囲・種類など、新しい順） |
+//! | 入るエッジのオフセット | `u32` × (シンボル数 + 1) |
+//! | 入るエッジ | 参照元のシンボルと出るエッジの番号（新しい順） |
+
+use crate::edge::{Edge, EdgeRoles, EdgeSource};
+use crate::graph::{CodeGraph, EdgeKind, Position, Range, Symbol, SymbolKind};
+use crate::graph_serde::{SerializedCodeGraph, SerializedEdge};
+use crate::modifiers::SymbolModifiers;
+use crate::string_interner::StringTable;
+use anyhow::{bail, Context, Result};
+use memmap2::Mmap;
+use std::cmp::Reverse;
+use std::fs::File;
+use std::ops::Deref;
+use std::path::Path;
+
+const MAGIC: &[u8; 8] = b"LSIFCSR\0";
+
+/// 配置を変えたら上げる（違うバージョンのファイルは読まない）
+pub const FORMAT_VERSION: u32 = 2;
+
+const HEADER_LEN: usize = 40;
+const SYMBOL_RECORD_LEN: usize = 44;
+const EDGE_RECORD_LEN: usize = 28;
+const INCOMING_RECORD_LEN: usize = 8;
+
+/// 文字列のない`Option`のフィールド
+const NO_STRING: u32 = u32::MAX;
+
+/// エッジに出現位置がある
+const EDGE_HAS_RANGE: u8 = 1;
+
+/// 番号から種類に戻す表（番号は列挙子の順）
+const SYMBOL_KINDS: [SymbolKind; 31] = [
+    SymbolKind::File,
+    SymbolKind::Module,
+    SymbolKind::Namespace,
+    SymbolKind::Package,
+    SymbolKind::Class,
+    SymbolKind::Method,
+    SymbolKind::Property,
+    SymbolKind::Field,
+    SymbolKind::Constructor,
+    SymbolKind::Enum,
+    SymbolKind::Interface,
+    SymbolKind::Function,
+    SymbolKind::Variable,
+    SymbolKind::Constant,
+    SymbolKind::String,
+    SymbolKind::Number,
+    SymbolKind::Boolean,
+    SymbolKind::Array,
+    SymbolKind::Object,
+    SymbolKind::Key,
+    SymbolKind::Null,
+    SymbolKind::EnumMember,
+    SymbolKind::Struct,
+    SymbolKind::Event,
+    SymbolKind::Operator,
+    SymbolKind::TypeParameter,
+    SymbolKind::Parameter,
+    SymbolKind::Reference,
+    SymbolKind::Trait,
+    SymbolKind::TypeAlias,
+    SymbolKind::Unknown,
+];
+
+const EDGE_KINDS: [EdgeKind; 8] = [
+    EdgeKind::Definition,
+    EdgeKind::Reference,
+    EdgeKind::TypeDefinition,
+    EdgeKind::Implementation,
+    EdgeKind::Override,
+    EdgeKind::Import,
+    EdgeKind::Export,
+    EdgeKind::Contains,
+];
+
+const EDGE_SOURCES: [EdgeSource; 4] = [
+    EdgeSource::Lsp,
+    EdgeSource::TreeSitter,
+    EdgeSource::Regex,
+    EdgeSource::Derived,
+];
+
+/// シンボルの番号（IDの順に並べた位置）
+#[derive(Debug, Clone, Copy, PartialEq, Eq, PartialOrd, Ord, Hash)]
+pub struct SymbolId(pub u32);
+
+/// グラフのバイト列（メモリマップしたファイルか、読み込んだもの）
+enum GraphBytes {
+    Mapped(Mmap),
+    Owned(Vec<u8>),
+}
+
+impl Deref for GraphBytes {
+    type Target = [u8];
+
+    fn deref(&self) -> &[u8] {
+        match self {
+            Self::Mapped(mmap) => mmap,
+            Self::Owned(bytes) => bytes,
+        }
+    }
+}
+
+/// 各区画の開始位置
+#[derive(Debug, Clone, Copy)]
+struct Layout {
+    string_offsets: usize,
+    strings: usize,
+    symbols: usize,
+    saved_order: usize,
+    outgoing_offsets: usize,
+    outgoing: usize,
+    incoming_offsets: usize,
+    incoming: usize,
+    end: usize,
+}
+
+impl Layout {
+    fn new(
+        string_count: usize,
+        string_bytes: usize,
+        symbol_count: usize,
+        edge_count: usize,
+    ) -> Self {
+        let string_offsets = HEADER_LEN;
+        let strings = string_offsets + (string_count + 1) * 4;
+        let symbols = strings + padded(string_bytes);
+        let saved_order = symbols + symbol_count * SYMBOL_RECORD_LEN;
+        let outgoing_offsets = saved_order + symbol_count * 4;
+        let outgoing = outgoing_offsets + (symbol_count + 1) * 4;
+        let incoming_offsets = outgoing + edge_count * EDGE_RECORD_LEN;
+        let incoming = incoming_offsets + (symbol_count + 1) * 4;
+        let end = incoming + edge_count * INCOMING_RECORD_LEN;
+        Self {
+            string_offsets,
+            strings,
+            symbols,
+            saved_order,
+            outgoing_offsets,
+            outgoing,
+            incoming_offsets,
+            incoming,
+            end,
+        }
+    }
+}
+
+/// 読み取り専用のグラフ
+pub struct CompactGraph {
+    bytes: GraphBytes,
+    layout: Layout,
+    generation: u64,
+    string_count: usize,
+    symbol_count: usize,
+    edge_count: usize,
+}
+
+impl CompactGraph {
+    /// ファイルをメモリマップして開く
+    pub fn open<P: AsRef<Path>>(path: P) -> Result<Self> {
+        let path = path.as_ref();
+        let file = File::open(path)
+            .with_context(|| format!("failed to open compact graph {}", path.display()))?;
+        // SAFETY: 書き込みは別のファイルに書いてから置き換えるので、開いたファイルの内容は変わらない
+        let mmap = unsafe { Mmap::map(&file) }
+            .with_context(|| format!("failed to map compact graph {}", path.display()))?;
+        Self::parse(GraphBytes::Mapped(mmap))
+    }
+
+    /// 読み込み済みのバイト列から開く
+    pub fn from_bytes(bytes: Vec<u8>) -> Result<Self> {
+        Self::parse(GraphBytes::Owned(bytes))
+    }
+
+    fn parse(bytes: GraphBytes) -> Result<Self> {
+        if bytes.len() < HEADER_LEN || &bytes[..8] != MAGIC {
+            bail!("not a compact graph");
+        }
+        let version = read_u32(&bytes, 8);
+        if version != FORMAT_VERSION {
+            bail!(
+                "unsupported compact graph version {} (expected {})",
+                version,
+                FORMAT_VERSION
+            );
+        }
+        let generation = read_u64(&bytes, 16);
+        let string_count = read_u32(&bytes, 24) as usize;
+        let string_bytes = read_u32(&bytes, 28) as usize;
+        let symbol_count = read_u32(&bytes, 32) as usize;
+        let edge_count = read_u32(&bytes, 36) as usize;
+
+        let layout = Layout::new(string_count, string_bytes, symbol_count, edge_count);
+        if layout.end != bytes.len() {
+            bail!(
+                "compact graph is truncated or corrupted ({} bytes, expected {})",
+                bytes.len(),
+                layout.end
+            );
+        }
+        let graph = Self {
+            bytes,
+            layout,
+            generation,
+            string_count,
+            symbol_count,
+            edge_count,
+        };
+        // オフセットの配列の終わりが区画の大きさと合っているか
+        if graph.offset(graph.layout.string_offsets, string_count) as usize != string_bytes
+            || graph.offset(graph.layout.outgoing_offsets, symbol_count) as usize != edge_count
+            || graph.offset(graph.layout.incoming_offsets, symbol_count) as usize != edge_count
+        {
+            bail!("compact graph has inconsistent offsets");
+        }
+        Ok(graph)
+    }
+
+    /// 保存形式のグラフからバイト列を作る
+    ///
+    /// `generation`は同時に保存したグラフと対応しているかを確かめるための値。
+    /// 同じIDのシンボルが複数あれば後のものを使い、端点のないエッジは捨てる（`into_graph`と同じ）。
+    pub fn build(graph: &SerializedCodeGraph, generation: u64) -> Result<Vec<u8>> {
+        let symbols = &graph.symbols;
+
+        // IDの順に並べ、同じIDは後のものを残す
+        let mut order: Vec<usize> = (0..symbols.len()).collect();
+        order.sort_by(|&a, &b| symbols[a].id.cmp(&symbols[b].id));
+        let mut sorted: Vec<usize> = Vec::with_capacity(order.len());
+        for index in order {
+            match sorted.last_mut() {
+                Some(last) if symbols[*last].id == symbols[index].id => *last = index,
+                _ => sorted.push(index),
+            }
+        }
+        let position = |id: &str| {
+            sorted
+                .binary_search_by(|&index| symbols[index].id.as_str().cmp(id))
+                .ok()
+        };
+
+        // 保存した時の順（`CodeGraph`の`get_all_symbols`の順）に並べたシンボルの番号
+        let mut saved_order: Vec<u32> = (0..sorted.len() as u32).collect();
+        saved_order.sort_by_key(|&id| sorted[id as usize]);
+
+        // エッジを参照元の順に並べる（同じ参照元の中ではpetgraphと同じく新しい順）
+        let mut edges: Vec<(u32, u32, usize, &SerializedEdge)> = graph
+            .edges
+            .iter()
+            .enumerate()
+            .filter_map(|(added, edge)| {
+                let from = position(&edge.from_id)?;
+                let to = position(&edge.to_id)?;
+                Some((from as u32, to as u32, added, edge))
+            })
+            .collect();
+        edges.sort_by_key(|&(from, _, added, _)| (from, Reverse(added)));
+        let mut incoming: Vec<(u32, u32, u32, usize)> = edges
+            .iter()
+            .enumerate()
+            .map(|(index, &(from, to, added, _))| (to, from, index as u32, added))
+            .collect();
+        incoming.sort_by_key(|&(to, _, _, added)| (to, Reverse(added)));
+
+        let mut table = StringTable::new();
+        let mut symbol_records = Vec::with_capacity(sorted.len() * SYMBOL_RECORD_LEN);
+        for &index in &sorted {
+            let symbol = &symbols[index];
+            let mut optional = |value: &Option<String>| match value {
+                Some(value) => table.intern(value),
+                None => NO_STRING,
+            };
+            let documentation = optional(&symbol.documentation);
+            let detail = optional(&symbol.detail);
+            let qualified_name = optional(&symbol.qualified_name);
+            for value in [
+                table.intern(&symbol.id),
+                table.intern(&symbol.name),
+                table.intern(&symbol.file_path),
+                documentation,
+                detail,
+                qualified_name,
+            ] {
+                symbol_records.extend_from_slice(&value.to_le_bytes());
+            }
+            write_range(&mut symbol_records, &symbol.range);
+            symbol_records.push(symbol.kind as u8);
+            symbol_records.push(0);
+            symbol_records.extend_from_slice(&symbol.modifiers.bits().to_le_bytes());
+        }
+
+        let string_bytes: usize = table.strings().iter().map(String::len).sum();
+        if table.len() >= NO_STRING as usize
+            || string_bytes > u32::MAX as usize
+            || edges.len() > u32::MAX as usize
+        {
+            bail!("graph is too large for the compact layout");
+        }
+        let layout = Layout::new(table.len(), string_bytes, sorted.len(), edges.len());
+
+        let mut bytes = Vec::with_capacity(layout.end);
+        bytes.extend_from_slice(MAGIC);
+        bytes.extend_from_slice(&FORMAT_VERSION.to_le_bytes());
+        bytes.extend_from_slice(&0u32.to_le_bytes());
+        bytes.extend_from_slice(&generation.to_le_bytes());
+        for count in [table.len(), string_bytes, sorted.len(), edges.len()] {
+            bytes.extend_from_slice(&(count as u32).to_le_bytes());
+        }
+
+        let mut offset = 0u32;
+        bytes.extend_from_slice(&offset.to_le_bytes());
+        for string in table.strings() {
+            offset += string.len() as u32;
+            bytes.extend_from_slice(&offset.to_le_bytes());
+        }
+        for string in table.strings() {
+            bytes.extend_from_slice(string.as_bytes());
+        }
+        bytes.resize(layout.symbols, 0);
+        bytes.extend_from_slice(&symbol_records);
+        for id in saved_order {
+            bytes.extend_from_slice(&id.to_le_bytes());
+        }
+
+        write_offsets(
+            &mut bytes,
+            sorted.len(),
+            edges.iter().map(|&(from, _, _, _)| from),
+        );
+        for &(_, to, _, edge) in &edges {
+            let edge = &edge.edge;
+            bytes.extend_from_slice(&to.to_le_bytes());
+            write_range(&mut bytes, &edge.range.unwrap_or(EMPTY_RANGE));
+            bytes.extend_from_slice(&edge.confidence.to_bits().to_le_bytes());
+            bytes.push(edge.kind as u8);
+            bytes.push(edge.source as u8);
+            bytes.push(edge.roles.bits());
+            bytes.push(if edge.range.is_some() {
+                EDGE_HAS_RANGE
+            } else {
+                0
+            });
+        }
+
+        write_offsets(
+            &mut bytes,
+            sorted.len(),
+            incoming.iter().map(|&(to, _, _, _)| to),
+        );
+        for &(_, from, edge_index, _) in &incoming {
+            bytes.extend_from_slice(&from.to_le_bytes());
+            bytes.extend_from_slice(&edge_index.to_le_bytes());
+        }
+
+        debug_assert_eq!(bytes.len(), layout.end);
+        Ok(bytes)
+    }
+
+    /// 保存した時の世代
+    pub fn generation(&self) -> u64 {
+        self.generation
+    }
+
+    pub fn symbol_count(&self) -> usize {
+        self.symbol_count
+    }
+
+    pub fn edge_count(&self) -> usize {
+        self.edge_count
+    }
+
+    /// IDでシンボルを探す（二分探索）
+    pub fn find_symbol(&self, id: &str) -> Option<SymbolRef<'_>> {
+        let mut low = 0;
+        let mut high = self.symbol_count;
+        while low < high {
+            let mid = (low + high) / 2;
+            let symbol = self.symbol(SymbolId(mid as u32));
+            match symbol.id().cmp(id) {
+                std::cmp::Ordering::Less => low = mid + 1,
+                std::cmp::Ordering::Greater => high = mid,
+                std::cmp::Ordering::Equal => return Some(symbol),
+            }
+        }
+        None
+    }
+
+    /// 番号のシンボル（`id`は`symbol_count`未満であること）
+    pub fn symbol(&self, id: SymbolId) -> SymbolRef<'_> {
+        assert!(
+            (id.0 as usize) < self.symbol_count,
+            "symbol id out of range"
+        );
+        SymbolRef { graph: self, id }
+    }
+
+    /// 全シンボル（IDの順）
+    pub fn symbols(&self) -> impl Iterator<Item = SymbolRef<'_>> {
+        (0..self.symbol_count as u32).map(move |id| SymbolRef {
+            graph: self,
+            id: SymbolId(id),
+        })
+    }
+
+    /// 全シンボル（保存した時の順、`CodeGraph::get_all_symbols`と同じ）
+    pub fn symbols_in_saved_order(&self) -> impl Iterator<Item = SymbolRef<'_>> {
+        (0..self.symbol_count).filter_map(move |index| {
+            let id = read_u32(&self.bytes, self.layout.saved_order + index * 4);
+            ((id as usize) < self.symbol_count).then_some(SymbolRef {
+                graph: self,
+                id: SymbolId(id),
+            })
+        })
+    }
+
+    /// シンボルから出るエッジ（参照先とエッジ、新しい順）
+    pub fn outgoing_edges(&self, id: SymbolId) -> impl Iterator<Item = (SymbolId, Edge)> + '_ {
+        self.edge_range(self.layout.outgoing_offsets, id)
+            .filter_map(move |index| self.edge_at(index))
+    }
+
+    /// シンボルに入るエッジ（参照元とエッジ、新しい順）
+    pub fn incoming_edges(&self, id: SymbolId) -> impl Iterator<Item = (SymbolId, Edge)> + '_ {
+        self.edge_range(self.layout.incoming_offsets, id)
+            .filter_map(move |index| {
+                let record = self.layout.incoming + index * INCOMING_RECORD_LEN;
+                let from = read_u32(&self.bytes, record);
+                let edge_index = read_u32(&self.bytes, record + 4) as usize;
+                if from as usize >= self.symbol_count || edge_index >= self.edge_count {
+                    return None;
+                }
+                let (_, edge) = self.edge_at(edge_index)?;
+                Some((SymbolId(from), edge))
+            })
+    }
+
+    /// `CodeGraph`に戻す（書き換えが必要な時）
+    ///
+    /// シンボルは保存した時の順に戻す。エッジはシンボルごとの順序だけを保つ（グラフ全体の順は失われる）。
+    pub fn to_code_graph(&self) -> CodeGraph {
+        let symbols: Vec<Symbol> = self
+            .symbols_in_saved_order()
+            .map(|symbol| symbol.to_symbol())
+            .collect();
+        let mut edges = Vec::with_capacity(self.edge_count);
+        for from in self.symbols_in_saved_order() {
+            // 新しい順に並んでいるので、古いものから追加し直す
+            let outgoing: Vec<(SymbolId, Edge)> = self.outgoing_edges(from.symbol_id()).collect();
+            for (to, edge) in outgoing.into_iter().rev() {
+                edges.push(SerializedEdge {
+                    from_id: from.id().to_string(),
+                    to_id: self.symbol(to).id().to_string(),
+                    edge,
+                });
+            }
+        }
+        SerializedCodeGraph { symbols, edges }.into_graph()
+    }
+
+    fn offset(&self, base: usize, index: usize) -> u32 {
+        read_u32(&self.bytes, base + index * 4)
+    }
+
+    /// シンボルのエッジの番号の範囲（壊れたオフセットは区画の中に収める）
+    fn edge_range(&self, base: usize, id: SymbolId) -> std::ops::Range<usize> {
+        let index = id.0 as usize;
+        if index >= self.symbol_count {
+            return 0..0;
+        }
+        let start = (self.offset(base, index) as usize).min(self.edge_count);
+        let end = (self.offset(base, index + 1) as usize).clamp(start, self.edge_count);
+        start..end
+    }
+
+    /// 出るエッジの番号のエッジ
+    fn edge_at(&self, index: usize) -> Option<(SymbolId, Edge)> {
+        let record = self.layout.outgoing + index * EDGE_RECORD_LEN;
+        let to = read_u32(&self.bytes, record);
+        if to as usize >= self.symbol_count {
+            return None;
+        }
+        let range = read_range(&self.bytes, record + 4);
+        let confidence = f32::from_bits(read_u32(&self.bytes, record + 20));
+        let kind = *EDGE_KINDS.get(self.bytes[record + 24] as usize)?;
+        let source = *EDGE_SOURCES.get(self.bytes[record + 25] as usize)?;
+        let roles = EdgeRoles::from_bits(self.bytes[record + 26]);
+        let has_range = self.bytes[record + 27] & EDGE_HAS_RANGE != 0;
+        let edge = Edge {
+            kind,
+            range: has_range.then_some(range),
+            roles,
+            source,
+            confidence,
+        };
+        Some((SymbolId(to), edge))
+    }
+
+    /// 文字列表の文字列（壊れていれば空文字列）
+    fn string(&self, id: u32) -> &str {
+        let index = id as usize;
+        if index >= self.string_count {
+            return "";
+        }
+        let start = self.offset(self.layout.string_offsets, index) as usize;
+        let end = self.offset(self.layout.string_offsets, index + 1) as usize;
+        self.bytes
+            .get(self.layout.strings + start..self.layout.strings + end)
+            .and_then(|bytes| std::str::from_utf8(bytes).ok())
+            .unwrap_or("")
+    }
+
+    fn optional_string(&self, id: u32) -> Option<&str> {
+        (id != NO_STRING).then(|| self.string(id))
+    }
+}
+
+/// グラフの中のシンボル（フィールドは使う時にバイト列から読む）
+#[derive(Clone, Copy)]
+pub struct SymbolRef<'a> {
+    graph: &'a CompactGraph,
+    id: SymbolId,
+}
+
+impl<'a> SymbolRef<'a> {
+    fn record(&self) -> usize {
+        self.graph.layout.symbols + self.id.0 as usize * SYMBOL_RECORD_LEN
+    }
+
+    fn field(&self, index: usize) -> u32 {
+        read_u32(&self.graph.bytes, self.record() + index * 4)
+    }
+
+    pub fn symbol_id(&self) -> SymbolId {
+        self.id
+    }
+
+    pub fn id(&self) -> &'a str {
+        self.graph.string(self.field(0))
+    }
+
+    pub fn name(&self) -> &'a str {
+        self.graph.string(self.field(1))
+    }
+
+    pub fn file_path(&self) -> &'a str {
+        self.graph.string(self.field(2))
+    }
+
+    pub fn documentation(&self) -> Option<&'a str> {
+        self.graph.optional_string(self.field(3))
+    }
+
+    pub fn detail(&self) -> Option<&'a str> {
+        self.graph.optional_string(self.field(4))
+    }
+
+    pub fn qualified_name(&self) -> Option<&'a str> {
+        self.graph.optional_string(self.field(5))
+    }
+
+    pub fn range(&self) -> Range {
+        read_range(&self.graph.bytes, self.record() + 24)
+    }
+
+    pub fn kind(&self) -> SymbolKind {
+        SYMBOL_KINDS
+            .get(self.graph.bytes[self.record() + 40] as usize)
+            .copied()
+            .unwrap_or(SymbolKind::Unknown)
+    }
+
+    pub fn modifiers(&self) -> SymbolModifiers {
+        let record = self.record();
+        SymbolModifiers::from_bits(u16::from_le_bytes([
+            self.graph.bytes[record + 42],
+            self.graph.bytes[record + 43],
+        ]))
+    }
+
+    /// 所有する`Symbol`にする
+    pub fn to_symbol(&self) -> Symbol {
+        Symbol {
+            id: self.id().to_string(),
+            kind: self.kind(),
+            name: self.name().to_string(),
+            file_path: self.file_path().to_string(),
+            range: self.range(),
+            documentation: self.documentation().map(str::to_string),
+            detail: self.detail().map(str::to_string),
+            modifiers: self.modifiers(),
+            qualified_name: self.qualified_name().map(str::to_string),
+        }
+    }
+}
+
+const EMPTY_RANGE: Range = Range {
+    start: Position {
+        line: 0,
+        character: 0,
+    },
+    end: Position {
+        line: 0,
+        character: 0,
+    },
+};
+
+fn padded(len: usize) -> usize {
+    len.div_ceil(4) * 4
+}
+
+fn read_u32(bytes: &[u8], offset: usize) -> u32 {
+    u32::from_le_bytes([
+        bytes[offset],
+        bytes[offset + 1],
+        bytes[offset + 2],
+        bytes[offset + 3],
+    ])
+}
+
+fn read_u64(bytes: &[u8], offset: usize) -> u64 {
+    u64::from(read_u32(bytes, offset)) | u64::from(read_u32(bytes, offset + 4)) << 32
+}
+
+fn read_range(bytes: &[u8], offset: usize) -> Range {
+    Range {
+        start: Position {
+            line: read_u32(bytes, offset),
+            character: read_u32(bytes, offset + 4),
+        },
+        end: Position {
+            line: read_u32(bytes, offset + 8),
+            character: read_u32(bytes, offset + 12),
+        },
+    }
+}
+
+fn write_range(bytes: &mut Vec<u8>, range: &Range) {
+    for value in [
+        range.start.line,
+        range.start.character,
+        range.end.line,
+        range.end.character,
+    ] {
+        bytes.extend_from_slice(&value.to_le_bytes());
+    }
+}
+
+/// 並べ替え済みのキーからCSRのオフセットの配列を書く
+fn write_offsets(bytes: &mut Vec<u8>, count: usize, keys: impl Iterator<Item = u32>) {
+    let mut offsets = vec![0u32; count + 1];
+    for key in keys {
+        offsets[key as usize + 1] += 1;
+    }
+    for index in 1..offsets.len() {
+        offsets[index] += offsets[index - 1];
+    }
+    for offset in offsets {
+        bytes.extend_from_slice(&offset.to_le_bytes());
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::edge::ReferenceAccess;
+    use crate::graph_store::GraphStore;
+    use crate::test_fixtures::SymbolBuilder;
+    use petgraph::Direction;
+    use tempfile::TempDir;
+
+    fn symbol(id: &str, name: &str, line: u32) -> Symbol {
+        SymbolBuilder::new(id, name, "src/lib.rs")
+            .range((line, 4), (line + 2, 1))
+            .detail(&format!("fn {}()", name))
+            .modifiers(SymbolModifiers::PUBLIC)
+            .qualified_name(&format!("crate::{}", name))
+            .build()
+    }
+
+    fn sample_graph() -> CodeGraph {
+        let mut graph = CodeGraph::new();
+        let mut module = symbol("src/lib.rs#module", "lib", 0);
+        module.kind = SymbolKind::Module;
+        module.documentation = Some("The crate root".to_string());
+        module.qualified_name = None;
+        let module = graph.add_symbol(module);
+        let main = graph.add_symbol(symbol("src/lib.rs#main", "main", 1));
+        let helper = graph.add_symbol(symbol("src/lib.rs#helper", "helper", 10));
+        graph.add_edge(main, module, EdgeKind::Contains);
+        graph.add_edge(helper, module, EdgeKind::Contains);
+        graph.add_edge_with(
+            main,
+            helper,
+            Edge::reference(EdgeSource::TreeSitter, Some(ReferenceAccess::Call)).with_range(
+                Range {
+                    start: Position {
+                        line: 2,
+                        character: 4,
+                    },
+                    end: Position {
+                        line: 2,
+                        character: 10,
+                    },
+                },
+            ),
+        );
+        graph
+    }
+
+    #[test]
+    fn test_round_trip_through_mapped_file() {
+        let graph = sample_graph();
+        let bytes = CompactGraph::build(&SerializedCodeGraph::from(&graph), 42).unwrap();
+        let temp_dir = TempDir::new().unwrap();
+        let path = temp_dir.path().join("graph.csr");
+        std::fs::write(&path, bytes).unwrap();
+
+        let compact = CompactGraph::open(&path).unwrap();
+        assert_eq!(compact.generation(), 42);
+        assert_eq!(compact.symbol_count(), 3);
+        assert_eq!(compact.edge_count(), 3);
+
+        let ids: Vec<&str> = compact.symbols().map(|symbol| symbol.id()).collect();
+        assert_eq!(
+            ids,
+            ["src/lib.rs#helper", "src/lib.rs#main", "src/lib.rs#module"]
+        );
+        for original in graph.get_all_symbols() {
+            let symbol = compact.find_symbol(&original.id).unwrap();
+            assert_eq!(&symbol.to_symbol(), original);
+        }
+        assert!(compact.find_symbol("src/lib.rs#missing").is_none());
+
+        let main = compact.find_symbol("src/lib.rs#main").unwrap().symbol_id();
+        let helper = compact
+            .find_symbol("src/lib.rs#helper")
+            .unwrap()
+            .symbol_id();
+        let mut outgoing: Vec<(SymbolId, EdgeKind)> = compact
+            .outgoing_edges(main)
+            .map(|(to, edge)| (to, edge.kind))
+            .collect();
+        outgoing.sort_by_key(|&(to, _)| to);
+        assert_eq!(outgoing.len(), 2);
+        assert_eq!(outgoing[0], (helper, EdgeKind::Reference));
+
+        let incoming: Vec<(SymbolId, Edge)> = compact.incoming_edges(helper).collect();
+        assert_eq!(incoming.len(), 1);
+        assert_eq!(incoming[0].0, main);
+        assert_eq!(incoming[0].1.access(), Some(ReferenceAccess::Call));
+        assert_eq!(incoming[0].1.source, EdgeSource::TreeSitter);
+        assert_eq!(incoming[0].1.range.unwrap().end.character, 10);
+
+        let restored = compact.to_code_graph();
+        assert_eq!(restored.symbol_count(), 3);
+        assert_eq!(
+            restored.find_references("src/lib.rs#helper").unwrap().len(),
+            graph.find_references("src/lib.rs#helper").unwrap().len()
+        );
+    }
+
+    #[test]
+    fn test_iterates_in_the_same_order_as_code_graph() {
+        let mut graph = sample_graph();
+        let extra = graph.add_symbol(symbol("src/lib.rs#extra", "extra", 20));
+        let main = graph.get_node_index("src/lib.rs#main").unwrap();
+        let helper = graph.get_node_index("src/lib.rs#helper").unwrap();
+        graph.add_edge(extra, helper, EdgeKind::Reference);
+        graph.add_edge(main, extra, EdgeKind::Reference);
+        let compact = CompactGraph::from_bytes(
+            CompactGraph::build(&SerializedCodeGraph::from(&graph), 1).unwrap(),
+        )
+        .unwrap();
+
+        let saved: Vec<&str> = compact
+            .symbols_in_saved_order()
+            .map(|symbol| symbol.id())
+            .collect();
+        let expected: Vec<&str> = graph.get_all_symbols().map(|s| s.id.as_str()).collect();
+        assert_eq!(saved, expected);
+
+        // シンボルごとのエッジは`CodeGraph`と同じく新しい順
+        for original in graph.get_all_symbols() {
+            let id = compact.find_symbol(&original.id).unwrap().symbol_id();
+            for direction in [Direction::Outgoing, Direction::Incoming] {
+                let expected: Vec<(String, Edge)> =
+                    GraphStore::edges_of(&graph, &original.id, direction)
+                        .map(|(other, edge)| (other.id.clone(), edge))
+                        .collect();
+                let edges: Vec<(SymbolId, Edge)> = match direction {
+                    Direction::Outgoing => compact.outgoing_edges(id).collect(),
+                    Direction::Incoming => compact.incoming_edges(id).collect(),
+                };
+                let actual: Vec<(String, Edge)> = edges
+                    .into_iter()
+                    .map(|(other, edge)| (compact.symbol(other).id().to_string(), edge))
+                    .collect();
+                assert_eq!(actual, expected, "{} {:?}", original.id, direction);
+            }
+        }
+
+        let restored = compact.to_code_graph();
+        let restored_ids: Vec<&str> = restored.get_all_symbols().map(|s| s.id.as_str()).collect();
+        assert_eq!(restored_ids, expected);
+    }
+
+    #[test]
+    fn test_rejects_other_versions_and_truncated_files() {
+        let bytes = CompactGraph::build(&SerializedCodeGraph::from(&sample_graph()), 1).unwrap();
+
+        let mut other_version = bytes.clone();
+        other_version[8..12].copy_from_slice(&(FORMAT_VERSION + 1).to_le_bytes());
+        assert!(CompactGraph::from_bytes(other_version).is_err());
+
+        let truncated = bytes[..bytes.len() - 1].to_vec();
+        assert!(CompactGraph::from_bytes(truncated).is_err());
+
+        assert!(CompactGraph::from_bytes(b"not a graph".to_vec()).is_err());
+        assert!(CompactGraph::from_bytes(bytes).is_ok());
+    }
+
+    #[test]
+    fn test_kind_tables_cover_every_variant() {
+        // 保存する番号は列挙子の順なので、表の順と一致していること
+        for (index, kind) in SYMBOL_KINDS.iter().enumerate() {
+            assert_eq!(*kind as usize, index);
+        }
+        for (index, kind) in EDGE_KINDS.iter().enumerate() {
+            assert_eq!(*kind as usize, index);
+        }
+        for (index, source) in EDGE_SOURCES.iter().enumerate() {
+            assert_eq!(*source as usize, index);
+        }
+        assert_eq!(SymbolKind::Unknown as usize, SYMBOL_KINDS.len() - 1);
+    }
+}
@@ -1,6 +1,7 @@
-use crate::graph::{CodeGraph, EdgeKind, SymbolKind};
+use crate::graph::{CodeGraph, EdgeKind, Symbol, SymbolKind};
 use petgraph::algo::tarjan_scc;
 use petgraph::visit::EdgeRef;
+use rayon::prelude::*;
 use std::collections::{HashMap, HashSet};
 
 /// 複雑度分析器
@@ -70,7 +71,7 @@ impl<'a> ComplexityAnalyzer<'a> {
         
         // Contains関係で内部ノードを探索
         for edge in self.graph.graph.edges(node) {
-            if *edge.weight() == EdgeKind::Contains {
+            if edge.weight().kind == EdgeKind::Contains {
                 *edges_count += 1;
                 self.traverse_control_flow(edge.target(), visited, edges_count, nodes_count);
             }
@@ -121,7 +122,7 @@ impl<'a> ComplexityAnalyzer<'a> {
         
         // 子ノードを再帰的に処理
         for edge in self.graph.graph.edges(node) {
-            if *edge.weight() == EdgeKind::Contains {
+            if edge.weight().kind == EdgeKind::Contains {
                 self.calculate_cognitive_recursive(edge.target(), complexity, nesting_level);
             }
         }
@@ -130,24 +131,26 @@ impl<'a> ComplexityAnalyzer<'a> {
     /// ブランチ数をカウント
     fn count_branches(&self, node: petgraph::stable_graph::NodeIndex) -> usize {
         self.graph.graph.edges(node)
-            .filter(|e| matches!(e.weight(), EdgeKind::Reference | EdgeKind::Definition))
+            .filter(|e| matches!(e.weight().kind, EdgeKind::Reference | EdgeKind::Definition))
             .count()
     }
 
     /// すべての関数の複雑度を計算
+    ///
+    /// 関数ごとの計算はrayonの現在のスレッドプールで並列に行う。
+    /// 同じIDの関数が複数あれば、スレッド数によらずグラフで後のものを使う。
     pub fn analyze_all_functions(&self) -> HashMap<String, ComplexityMetrics> {
-        let mut results = HashMap::new();
-        
-        for node in self.graph.graph.node_indices() {
-            if let Some(symbol) = self.graph.graph.node_weight(node) {
-                if matches!(symbol.kind, SymbolKind::Function | SymbolKind::Method) {
-                    let metrics = self.calculate_metrics(&symbol.id);
-                    results.insert(symbol.id.clone(), metrics);
-                }
-            }
-        }
-        
-        results
+        let functions: Vec<&Symbol> = self
+            .graph
+            .get_all_symbols()
+            .filter(|symbol| matches!(symbol.kind, SymbolKind::Function | SymbolKind::Method))
+            .collect();
+
+        let metrics: Vec<(String, ComplexityMetrics)> = functions
+            .into_par_iter()
+            .map(|symbol| (symbol.id.clone(), self.calculate_metrics(&symbol.id)))
+            .collect();
+        metrics.into_iter().collect()
     }
 
     /// シンボルの総合的なメトリクスを計算
@@ -173,12 +176,12 @@ impl<'a> ComplexityAnalyzer<'a> {
         if let Some(node) = self.graph.symbol_index.get(symbol_id) {
             let fan_in = self.graph.graph
                 .edges_directed(*node, petgraph::Direction::Incoming)
-                .filter(|e| *e.weight() == EdgeKind::Reference)
+                .filter(|e| e.weight().kind == EdgeKind::Reference)
                 .count();
             
             let fan_out = self.graph.graph
                 .edges_directed(*node, petgraph::Direction::Outgoing)
-                .filter(|e| *e.weight() == EdgeKind::Reference)
+                .filter(|e| e.weight().kind == EdgeKind::Reference)
                 .count();
             
             (fan_in, fan_out)
@@ -204,7 +207,7 @@ impl<'a> ComplexityAnalyzer<'a> {
         let mut max_depth = current_depth;
         
         for edge in self.graph.graph.edges(node) {
-            if *edge.weight() == EdgeKind::Contains {
+            if edge.weight().kind == EdgeKind::Contains {
                 let child_depth = self.calculate_depth_recursive(edge.target(), current_depth + 1);
                 max_depth = max_depth.max(child_depth);
             }
@@ -220,7 +223,7 @@ impl<'a> ComplexityAnalyzer<'a> {
             
             // 外部依存を収集
             for edge in self.graph.graph.edges(*node) {
-                if matches!(edge.weight(), EdgeKind::Import | EdgeKind::Reference) {
+                if matches!(edge.weight().kind, EdgeKind::Import | EdgeKind::Reference) {
                     if let Some(target_symbol) = self.graph.graph.node_weight(edge.target()) {
                         // 異なるファイルへの参照を外部依存とみなす
                         if let Some(source_symbol) = self.graph.graph.node_weight(*node) {
@@ -302,6 +305,8 @@ mod tests {
             },
             documentation: None,
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         };
         
         let func_node = graph.add_symbol(func);
@@ -319,6 +324,8 @@ mod tests {
                 },
                 documentation: None,
                 detail: None,
+                modifiers: Default::default(),
+                qualified_name: None,
             };
             
             let block_node = graph.add_symbol(block);
@@ -397,6 +404,8 @@ mod tests {
             },
             documentation: None,
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         };
         
         let b = Symbol {
@@ -410,6 +419,8 @@ mod tests {
             },
             documentation: None,
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         };
         
         let c = Symbol {
@@ -423,6 +434,8 @@ mod tests {
             },
             documentation: None,
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         };
         
         let a_node = graph.add_symbol(a);
@@ -457,6 +470,8 @@ mod tests {
                 },
                 documentation: None,
                 detail: None,
+                modifiers: Default::default(),
+                qualified_name: None,
             };
             
             let func_node = graph.add_symbol(func);
@@ -474,6 +489,8 @@ mod tests {
                     },
                     documentation: None,
                     detail: None,
+                    modifiers: Default::default(),
+                    qualified_name: None,
                 };
                 
                 let block_node = graph.add_symbol(block);
@@ -518,6 +535,8 @@ mod tests {
             },
             documentation: None,
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         };
         graph.add_symbol(func);
         
@@ -547,6 +566,8 @@ mod tests {
             },
             documentation: None,
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         };
         
         let _simple_node = graph.add_symbol(simple);
@@ -563,6 +584,8 @@ mod tests {
             },
             documentation: None,
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         };
         
         let complex_node = graph.add_symbol(complex);
@@ -580,6 +603,8 @@ mod tests {
                 },
                 documentation: None,
                 detail: None,
+                modifiers: Default::default(),
+                qualified_name: None,
             };
             let var_node = graph.add_symbol(var);
             graph.add_edge(complex_node, var_node, EdgeKind::Contains);
@@ -131,7 +131,7 @@ impl<'a> DefinitionChainAnalyzer<'a> {
     fn get_immediate_definition(&self, symbol_id: &str) -> Option<Symbol> {
         if let Some(node_idx) = self.graph.get_node_index(symbol_id) {
             for edge in self.graph.graph.edges(node_idx) {
-                if matches!(edge.weight(), EdgeKind::Definition) {
+                if matches!(edge.weight().kind, EdgeKind::Definition) {
                     return self.graph.graph.node_weight(edge.target()).cloned();
                 }
             }
@@ -145,7 +145,7 @@ impl<'a> DefinitionChainAnalyzer<'a> {
 
         if let Some(node_idx) = self.graph.get_node_index(symbol_id) {
             for edge in self.graph.graph.edges(node_idx) {
-                if matches!(edge.weight(), EdgeKind::Definition) {
+                if matches!(edge.weight().kind, EdgeKind::Definition) {
                     if let Some(def) = self.graph.graph.node_weight(edge.target()) {
                         definitions.push(def.clone());
                     }
@@ -286,6 +286,8 @@ mod tests {
             },
             documentation: None,
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         }
     }
 
@@ -0,0 +1,423 @@
+//! ドキュメントコメントの正規化
+//!
+//! 言語ごとのコメント（Goの`//`ブロック、Rustの`///`、Pythonのdocstring、TSDoc/JSDoc）
+//! から取り出したテキストのタグを解釈し、`Symbol.documentation`に保存するMarkdownにそろえる。
+//! 非推奨の印は`**Deprecated**`で始まる段落として残るので、保存後も
+//! `deprecation_note`で取り出せる。
+
+/// 正規化したMarkdownで非推奨を表す段落の先頭
+pub const DEPRECATED_MARKER: &str = "**Deprecated**";
+
+/// タグを分解したドキュメント
+#[derive(Debug, Clone, Default, PartialEq)]
+pub struct DocComment {
+    /// タグ以外の本文
+    pub body: String,
+    /// (引数名, 説明)
+    pub params: Vec<(String, String)>,
+    pub returns: Option<String>,
+    pub throws: Vec<String>,
+    pub see: Vec<String>,
+    pub examples: Vec<String>,
+    /// 非推奨の説明（説明がなければ空文字列）
+    pub deprecated: Option<String>,
+    /// 上記以外のタグ (タグ名, 内容)
+    pub other_tags: Vec<(String, String)>,
+}
+
+/// 解析中のタグ
+enum Section {
+    Body,
+    Param(usize),
+    Returns,
+    Throws(usize),
+    See(usize),
+    Example(usize),
+    Deprecated,
+    Other(usize),
+}
+
+impl DocComment {
+    /// コメント記号を除いたテキストを解析
+    ///
+    /// JSDoc/TSDocの`@param`等、Sphinxの`:param x:`と`.. deprecated::`、
+    /// Goの`Deprecated:`段落を認識する。
+    pub fn parse(text: &str) -> Self {
+        let mut doc = DocComment::default();
+        let mut body = Vec::new();
+        let mut section = Section::Body;
+        let mut paragraph_start = true;
+        // Markdownのコードブロック内はタグとして解釈しない
+        let mut in_fence = false;
+
+        for line in text.lines() {
+            let trimmed = line.trim();
+            if in_fence || trimmed.starts_with("```") {
+                if trimmed.starts_with("```") {
+                    in_fence = !in_fence;
+                }
+                doc.continue_section(&section, line, trimmed, &mut body);
+                continue;
+            }
+            if trimmed.is_empty() {
+                // タグの説明は空行で終わる（@exampleのコードは空行を含みうる）
+                match section {
+                    Section::Example(i) => doc.examples[i].push('\n'),
+                    _ => {
+                        section = Section::Body;
+                        body.push(String::new());
+                    }
+                }
+                paragraph_start = true;
+                continue;
+            }
+
+            let tag = match trimmed.strip_prefix('@') {
+                Some(tagged) => {
+                    let (tag, rest) = split_word(tagged);
+                    if tag.chars().all(|c| c.is_ascii_alphabetic()) {
+                        Some((tag, rest.to_string()))
+                    } else {
+                        None
+                    }
+                }
+                None => trimmed.strip_prefix(':').and_then(sphinx_field),
+            };
+            if let Some((tag, rest)) = tag {
+                section = doc.start_tag(tag, &rest);
+            } else if trimmed.starts_with(".. deprecated::") {
+                // 版番号は説明に含めない
+                doc.deprecated = Some(String::new());
+                section = Section::Deprecated;
+            } else if let (true, Some(rest)) =
+                (paragraph_start, trimmed.strip_prefix("Deprecated:"))
+            {
+                doc.deprecated = Some(rest.trim().to_string());
+                section = Section::Deprecated;
+            } else {
+                doc.continue_section(&section, line, trimmed, &mut body);
+            }
+            paragraph_start = false;
+        }
+
+        doc.body = body.join("\n").trim().to_string();
+        for example in &mut doc.examples {
+            *example = example.trim_end().to_string();
+        }
+        doc
+    }
+
+    fn start_tag(&mut self, tag: &str, rest: &str) -> Section {
+        match tag {
+            "param" | "arg" | "argument" | "parameter" => {
+                // `{type}`は読み飛ばす
+                let rest = match rest.strip_prefix('{').and_then(|r| r.split_once('}')) {
+                    Some((_, after)) => after.trim_start(),
+                    None => rest,
+                };
+                let (name, description) = split_word(rest);
+                let description = description.trim_start_matches('-').trim();
+                self.params.push((
+                    name.trim_matches(|c| c == '[' || c == ']').to_string(),
+                    description.to_string(),
+                ));
+                Section::Param(self.params.len() - 1)
+            }
+            "returns" | "return" => {
+                self.returns = Some(strip_type(rest).to_string());
+                Section::Returns
+            }
+            "throws" | "exception" | "raises" | "raise" => {
+                self.throws.push(strip_type(rest).to_string());
+                Section::Throws(self.throws.len() - 1)
+            }
+            "see" => {
+                self.see.push(rest.to_string());
+                Section::See(self.see.len() - 1)
+            }
+            "example" => {
+                self.examples.push(String::new());
+                if !rest.is_empty() {
+                    self.examples.last_mut().unwrap().push_str(rest);
+                    self.examples.last_mut().unwrap().push('\n');
+                }
+                Section::Example(self.examples.len() - 1)
+            }
+            "deprecated" => {
+                self.deprecated = Some(rest.to_string());
+                Section::Deprecated
+            }
+            _ => {
+                self.other_tags.push((tag.to_string(), rest.to_string()));
+                Section::Other(self.other_tags.len() - 1)
+            }
+        }
+    }
+
+    fn continue_section(
+        &mut self,
+        section: &Section,
+        line: &str,
+        trimmed: &str,
+        body: &mut Vec<String>,
+    ) {
+        let target = match *section {
+            Section::Body => {
+                body.push(line.trim_end().to_string());
+                return;
+            }
+            Section::Example(i) => {
+                self.examples[i].push_str(line.trim_end());
+                self.examples[i].push('\n');
+                return;
+            }
+            Section::Param(i) => &mut self.params[i].1,
+            Section::Returns => self.returns.get_or_insert_with(String::new),
+            Section::Throws(i) => &mut self.throws[i],
+            Section::See(i) => &mut self.see[i],
+            Section::Deprecated => self.deprecated.get_or_insert_with(String::new),
+            Section::Other(i) => &mut self.other_tags[i].1,
+        };
+        if !target.is_empty() {
+            target.push(' ');
+        }
+        target.push_str(trimmed);
+    }
+
+    /// 非推奨にする（既に説明があれば残す）
+    pub fn mark_deprecated(&mut self, note: Option<&str>) {
+        match (&mut self.deprecated, note) {
+            (Some(existing), Some(note)) if existing.is_empty() => *existing = note.to_string(),
+            (Some(_), _) => {}
+            (None, note) => self.deprecated = Some(note.unwrap_or_default().to_string()),
+        }
+    }
+
+    pub fn is_empty(&self) -> bool {
+        *self == DocComment::default()
+    }
+
+    /// Markdownに変換（空ならNone）
+    pub fn to_markdown(&self) -> Option<String> {
+        let mut sections = Vec::new();
+        if !self.body.is_empty() {
+            sections.push(self.body.clone());
+        }
+        if let Some(note) = &self.deprecated {
+            if note.is_empty() {
+                sections.push(DEPRECATED_MARKER.to_string());
+            } else {
+                sections.push(format!("{}: {}", DEPRECATED_MARKER, note));
+            }
+        }
+        if !self.params.is_empty() {
+            let items: Vec<String> = self
+                .params
+                .iter()
+                .map(|(name, description)| {
+                    if description.is_empty() {
+                        format!("- `{}`", name)
+                    } else {
+                        format!("- `{}`: {}", name, description)
+                    }
+                })
+                .collect();
+            sections.push(format!("**Parameters**\n{}", items.join("\n")));
+        }
+        if let Some(returns) = &self.returns {
+            sections.push(format!("**Returns**: {}", returns));
+        }
+        if !self.throws.is_empty() {
+            let items: Vec<String> = self.throws.iter().map(|t| format!("- {}", t)).collect();
+            sections.push(format!("**Throws**\n{}", items.join("\n")));
+        }
+        for example in &self.examples {
+            sections.push(format!("**Example**\n```\n{}\n```", example));
+        }
+        if !self.see.is_empty() {
+            sections.push(format!("**See**: {}", self.see.join(", ")));
+        }
+        for (tag, text) in &self.other_tags {
+            sections.push(format!("*@{}* {}", tag, text).trim_end().to_string());
+        }
+
+        if sections.is_empty() {
+            None
+        } else {
+            Some(sections.join("\n\n"))
+        }
+    }
+}
+
+/// 正規化したドキュメントから非推奨の説明を取り出す
+///
+/// 非推奨でなければNone、説明がなければ空文字列。
+pub fn deprecation_note(documentation: &str) -> Option<&str> {
+    documentation
+        .split("\n\n")
+        .find_map(|paragraph| paragraph.strip_prefix(DEPRECATED_MARKER))
+        .map(|rest| rest.trim_start_matches(':').trim())
+}
+
+/// 非推奨の説明から代わりのAPIを推測する
+///
+/// `Use X instead`、`replaced by X`、`in favor of X`、`{@link X}`の形を認識する。
+pub fn suggested_replacement(note: &str) -> Option<String> {
+    if let Some(start) = note.find("{@link") {
+        let rest = &note[start + "{@link".len()..];
+        let name = rest[..rest.find('}')?].trim();
+        let name = name.split(['|', ' ']).next().unwrap_or(name);
+        return (!name.is_empty()).then(|| name.to_string());
+    }
+
+    let lower = note.to_ascii_lowercase();
+    ["use ", "replaced by ", "in favor of ", "in favour of "]
+        .iter()
+        .filter_map(|phrase| {
+            let start = lower
+                .match_indices(phrase)
+                .map(|(i, _)| i)
+                .find(|&i| i == 0 || !lower[..i].ends_with(|c: char| c.is_alphanumeric()))?;
+            Some(start + phrase.len())
+        })
+        .min()
+        .and_then(|start| {
+            let word = note[start..].split_whitespace().next()?;
+            let word = word
+                .trim_matches(|c: char| matches!(c, '`' | '"' | '\'' | '[' | ']'))
+                .trim_end_matches(|c: char| matches!(c, '.' | ',' | ';' | ':' | '`'));
+            (!word.is_empty()).then(|| word.to_string())
+        })
+}
+
+/// 先頭の単語と残り
+fn split_word(text: &str) -> (&str, &str) {
+    match text.split_once(char::is_whitespace) {
+        Some((word, rest)) => (word, rest.trim()),
+        None => (text, ""),
+    }
+}
+
+/// `{type}`を除く
+fn strip_type(text: &str) -> &str {
+    match text.strip_prefix('{').and_then(|r| r.split_once('}')) {
+        Some((_, after)) => after.trim(),
+        None => text,
+    }
+}
+
+/// Sphinxのフィールド（`param x: 説明`）を(タグ, `x 説明`)に変換
+fn sphinx_field(field: &str) -> Option<(&'static str, String)> {
+    let (head, rest) = field.split_once(':')?;
+    let (tag, name) = split_word(head);
+    let tag = match tag {
+        "param" | "parameter" | "arg" | "argument" => "param",
+        "returns" | "return" => "returns",
+        "raises" | "raise" | "except" | "exception" => "throws",
+        _ => return None,
+    };
+    Some((tag, format!("{} {}", name, rest.trim()).trim().to_string()))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_jsdoc_tags() {
+        let doc = DocComment::parse(
+            "Fetches a user.\n\n@param {string} id - The user id\n  that may span lines\n@returns {Promise<User>} the user\n@deprecated Use fetchUserById instead.",
+        );
+        assert_eq!(doc.body, "Fetches a user.");
+        assert_eq!(
+            doc.params,
+            vec![(
+                "id".to_string(),
+                "The user id that may span lines".to_string()
+            )]
+        );
+        assert_eq!(doc.returns.as_deref(), Some("the user"));
+        assert_eq!(
+            doc.deprecated.as_deref(),
+            Some("Use fetchUserById instead.")
+        );
+
+        let markdown = doc.to_markdown().unwrap();
+        assert_eq!(
+            markdown,
+            "Fetches a user.\n\n**Deprecated**: Use fetchUserById instead.\n\n**Parameters**\n- `id`: The user id that may span lines\n\n**Returns**: the user"
+        );
+        assert_eq!(
+            deprecation_note(&markdown),
+            Some("Use fetchUserById instead.")
+        );
+    }
+
+    #[test]
+    fn test_go_deprecated_paragraph() {
+        let doc = DocComment::parse(
+            "Open opens the database.\n\nDeprecated: Use OpenContext instead.\nIt will be removed.",
+        );
+        assert_eq!(doc.body, "Open opens the database.");
+        assert_eq!(
+            doc.deprecated.as_deref(),
+            Some("Use OpenContext instead. It will be removed.")
+        );
+
+        // 段落の途中の`Deprecated:`は本文
+        let doc = DocComment::parse("Returns the value.\nDeprecated: fields are ignored.");
+        assert!(doc.deprecated.is_none());
+    }
+
+    #[test]
+    fn test_sphinx_fields() {
+        let doc = DocComment::parse(
+            "Say hello.\n\n:param name: who to greet\n:returns: greeting\n\n.. deprecated:: 2.0\n   Use greet_all.",
+        );
+        assert_eq!(doc.body, "Say hello.");
+        assert_eq!(
+            doc.params,
+            vec![("name".to_string(), "who to greet".to_string())]
+        );
+        assert_eq!(doc.returns.as_deref(), Some("greeting"));
+        assert_eq!(doc.deprecated.as_deref(), Some("Use greet_all."));
+    }
+
+    #[test]
+    fn test_suggested_replacement() {
+        assert_eq!(
+            suggested_replacement("Use OpenContext instead.").as_deref(),
+            Some("OpenContext")
+        );
+        assert_eq!(
+            suggested_replacement("since 0.2; use `load_v2()`").as_deref(),
+            Some("load_v2()")
+        );
+        assert_eq!(
+            suggested_replacement("Replaced by {@link UserApi.fetch}.").as_deref(),
+            Some("UserApi.fetch")
+        );
+        assert_eq!(
+            suggested_replacement("Superseded, in favor of new_api.").as_deref(),
+            Some("new_api")
+        );
+        // 単語の途中の`use`は対象外
+        assert_eq!(suggested_replacement("Causes misuse of memory"), None);
+        assert_eq!(suggested_replacement(""), None);
+    }
+
+    #[test]
+    fn test_mark_deprecated_and_plain_text() {
+        let mut doc = DocComment::parse("設定");
+        assert_eq!(doc.to_markdown().as_deref(), Some("設定"));
+        assert_eq!(deprecation_note("設定"), None);
+
+        doc.mark_deprecated(None);
+        assert_eq!(doc.to_markdown().as_deref(), Some("設定\n\n**Deprecated**"));
+        assert_eq!(deprecation_note(&doc.to_markdown().unwrap()), Some(""));
+
+        doc.mark_deprecated(Some("use Config2"));
+        assert_eq!(doc.deprecated.as_deref(), Some("use Config2"));
+        assert!(DocComment::parse("").to_markdown().is_none());
+    }
+}
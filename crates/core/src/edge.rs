@@ -0,0 +1,330 @@
+//! エッジの情報（種類・出現位置・役割・出どころ・確からしさ）
+//!
+//! `CodeGraph.graph`のエッジの重み。参照なら、参照元のシンボルの中のどこで
+//! 使われているか（呼び出し位置など）と、LSP・tree-sitter・正規表現のどれで
+//! 見つけたかを持つ。bincodeでは役割は数値、JSONでは名前の配列になる。
+
+use crate::graph::{EdgeKind, Range};
+use serde::de::Error as _;
+use serde::{Deserialize, Deserializer, Serialize, Serializer};
+use std::fmt;
+use std::ops::{BitOr, BitOrAssign};
+
+/// エッジ
+#[derive(Debug, Clone, Copy, Serialize, Deserialize, PartialEq)]
+pub struct Edge {
+    pub kind: EdgeKind,
+    /// 出現位置（参照元のシンボルのファイル内の範囲）
+    pub range: Option<Range>,
+    pub roles: EdgeRoles,
+    pub source: EdgeSource,
+    /// 確からしさ（0.0〜1.0）
+    pub confidence: f32,
+}
+
+impl Edge {
+    /// 出現位置・役割のないエッジ（インデクサが導いたもの）
+    pub fn new(kind: EdgeKind) -> Self {
+        Self::from_source(kind, EdgeSource::Derived)
+    }
+
+    /// 出どころの既定の確からしさを持つエッジ
+    pub fn from_source(kind: EdgeKind, source: EdgeSource) -> Self {
+        Self {
+            kind,
+            range: None,
+            roles: EdgeRoles::NONE,
+            source,
+            confidence: source.default_confidence(),
+        }
+    }
+
+    /// 使われ方付きの`Reference`エッジ
+    pub fn reference(source: EdgeSource, access: Option<ReferenceAccess>) -> Self {
+        Self::from_source(EdgeKind::Reference, source)
+            .with_roles(access.map(EdgeRoles::from_access).unwrap_or_default())
+    }
+
+    pub fn with_range(mut self, range: Range) -> Self {
+        self.range = Some(range);
+        self
+    }
+
+    pub fn with_roles(mut self, roles: EdgeRoles) -> Self {
+        self.roles = roles;
+        self
+    }
+
+    pub fn with_confidence(mut self, confidence: f32) -> Self {
+        self.confidence = confidence.clamp(0.0, 1.0);
+        self
+    }
+
+    /// 参照の使われ方（役割がなければNone）
+    pub fn access(&self) -> Option<ReferenceAccess> {
+        self.roles.access()
+    }
+}
+
+impl From<EdgeKind> for Edge {
+    fn from(kind: EdgeKind) -> Self {
+        Self::new(kind)
+    }
+}
+
+/// エッジをどうやって見つけたか
+#[derive(Debug, Clone, Copy, Serialize, Deserialize, PartialEq, Eq, Hash)]
+pub enum EdgeSource {
+    /// 言語サーバーの応答
+    Lsp,
+    /// tree-sitterの構文とスコープの解決
+    TreeSitter,
+    /// 正規表現による名前の一致（同名の別シンボルを含みうる）
+    Regex,
+    /// インデクサが他の情報から導いたもの（範囲の入れ子、LSIFの読み込みなど）
+    Derived,
+}
+
+impl EdgeSource {
+    pub fn as_str(self) -> &'static str {
+        match self {
+            Self::Lsp => "lsp",
+            Self::TreeSitter => "tree-sitter",
+            Self::Regex => "regex",
+            Self::Derived => "derived",
+        }
+    }
+
+    /// 出どころごとの既定の確からしさ
+    pub fn default_confidence(self) -> f32 {
+        match self {
+            Self::Lsp | Self::Derived => 1.0,
+            Self::TreeSitter => 0.8,
+            Self::Regex => 0.3,
+        }
+    }
+}
+
+/// 参照の使われ方（読み取り・書き込みなど）
+///
+/// tree-sitterの構文の文脈か、LSPの`textDocument/documentHighlight`の種類から判定し、
+/// エッジには`EdgeRoles`として保存する。
+#[derive(Debug, Clone, Copy, Serialize, Deserialize, PartialEq, Eq, Hash)]
+pub enum ReferenceAccess {
+    /// 値を読む
+    Read,
+    /// 値を書き換える（代入の左辺）
+    Write,
+    /// 読んでから書き換える（`x++`、`x += 1`）
+    ReadWrite,
+    /// アドレスを取る（Goの`&x`、Rustの`&mut x`）
+    AddressTaken,
+    /// 呼び出す
+    Call,
+}
+
+impl ReferenceAccess {
+    pub fn as_str(self) -> &'static str {
+        match self {
+            Self::Read => "read",
+            Self::Write => "write",
+            Self::ReadWrite => "read-write",
+            Self::AddressTaken => "address-taken",
+            Self::Call => "call",
+        }
+    }
+
+    pub fn from_name(name: &str) -> Option<Self> {
+        match name.to_ascii_lowercase().replace('_', "-").as_str() {
+            "read" => Some(Self::Read),
+            "write" => Some(Self::Write),
+            "read-write" | "readwrite" => Some(Self::ReadWrite),
+            "address-taken" | "address" => Some(Self::AddressTaken),
+            "call" => Some(Self::Call),
+            _ => None,
+        }
+    }
+
+    /// 値を書き換える可能性があるか（アドレスを取った場合も書き換えられうる）
+    pub fn may_write(self) -> bool {
+        matches!(self, Self::Write | Self::ReadWrite | Self::AddressTaken)
+    }
+}
+
+/// エッジの役割の集合
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq, Hash)]
+pub struct EdgeRoles(u8);
+
+impl EdgeRoles {
+    pub const NONE: Self = Self(0);
+    pub const READ: Self = Self(1 << 0);
+    pub const WRITE: Self = Self(1 << 1);
+    pub const CALL: Self = Self(1 << 2);
+    pub const ADDRESS_TAKEN: Self = Self(1 << 3);
+
+    const NAMES: [(Self, &'static str); 4] = [
+        (Self::READ, "read"),
+        (Self::WRITE, "write"),
+        (Self::CALL, "call"),
+        (Self::ADDRESS_TAKEN, "address-taken"),
+    ];
+
+    pub fn bits(self) -> u8 {
+        self.0
+    }
+
+    pub fn from_bits(bits: u8) -> Self {
+        Self(bits)
+    }
+
+    pub fn is_empty(self) -> bool {
+        self.0 == 0
+    }
+
+    pub fn contains(self, other: Self) -> bool {
+        self.0 & other.0 == other.0
+    }
+
+    pub fn insert(&mut self, other: Self) {
+        self.0 |= other.0;
+    }
+
+    /// 含まれる役割の名前
+    pub fn names(self) -> impl Iterator<Item = &'static str> {
+        Self::NAMES
+            .into_iter()
+            .filter(move |(flag, _)| self.contains(*flag))
+            .map(|(_, name)| name)
+    }
+
+    pub fn from_name(name: &str) -> Option<Self> {
+        let name = name.trim().to_ascii_lowercase().replace('_', "-");
+        Self::NAMES
+            .into_iter()
+            .find(|(_, n)| *n == name)
+            .map(|(flag, _)| flag)
+    }
+
+    pub fn from_access(access: ReferenceAccess) -> Self {
+        match access {
+            ReferenceAccess::Read => Self::READ,
+            ReferenceAccess::Write => Self::WRITE,
+            ReferenceAccess::ReadWrite => Self::READ | Self::WRITE,
+            ReferenceAccess::AddressTaken => Self::ADDRESS_TAKEN,
+            ReferenceAccess::Call => Self::CALL,
+        }
+    }
+
+    /// 役割から見た使われ方（書き込みを優先する）
+    pub fn access(self) -> Option<ReferenceAccess> {
+        if self.contains(Self::READ | Self::WRITE) {
+            Some(ReferenceAccess::ReadWrite)
+        } else if self.contains(Self::WRITE) {
+            Some(ReferenceAccess::Write)
+        } else if self.contains(Self::ADDRESS_TAKEN) {
+            Some(ReferenceAccess::AddressTaken)
+        } else if self.contains(Self::CALL) {
+            Some(ReferenceAccess::Call)
+        } else if self.contains(Self::READ) {
+            Some(ReferenceAccess::Read)
+        } else {
+            None
+        }
+    }
+}
+
+impl BitOr for EdgeRoles {
+    type Output = Self;
+
+    fn bitor(self, rhs: Self) -> Self {
+        Self(self.0 | rhs.0)
+    }
+}
+
+impl BitOrAssign for EdgeRoles {
+    fn bitor_assign(&mut self, rhs: Self) {
+        self.0 |= rhs.0;
+    }
+}
+
+impl fmt::Display for EdgeRoles {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        f.write_str(&self.names().collect::<Vec<_>>().join(","))
+    }
+}
+
+impl Serialize for EdgeRoles {
+    fn serialize<S: Serializer>(&self, serializer: S) -> Result<S::Ok, S::Error> {
+        if serializer.is_human_readable() {
+            serializer.collect_seq(self.names())
+        } else {
+            serializer.serialize_u8(self.0)
+        }
+    }
+}
+
+impl<'de> Deserialize<'de> for EdgeRoles {
+    fn deserialize<D: Deserializer<'de>>(deserializer: D) -> Result<Self, D::Error> {
+        if deserializer.is_human_readable() {
+            let names = Vec::<String>::deserialize(deserializer)?;
+            names.iter().try_fold(Self::NONE, |acc, name| {
+                Self::from_name(name)
+                    .map(|flag| acc | flag)
+                    .ok_or_else(|| D::Error::custom(format!("unknown edge role: {}", name)))
+            })
+        } else {
+            u8::deserialize(deserializer).map(Self)
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::graph::Position;
+
+    #[test]
+    fn test_roles_and_access() {
+        for access in [
+            ReferenceAccess::Read,
+            ReferenceAccess::Write,
+            ReferenceAccess::ReadWrite,
+            ReferenceAccess::AddressTaken,
+            ReferenceAccess::Call,
+        ] {
+            assert_eq!(EdgeRoles::from_access(access).access(), Some(access));
+        }
+        assert_eq!(EdgeRoles::NONE.access(), None);
+        assert!(ReferenceAccess::ReadWrite.may_write());
+        assert!(!ReferenceAccess::Call.may_write());
+        assert_eq!(
+            ReferenceAccess::from_name("address_taken"),
+            Some(ReferenceAccess::AddressTaken)
+        );
+    }
+
+    #[test]
+    fn test_edge_defaults_and_serialize() {
+        let call_site = Range {
+            start: Position {
+                line: 3,
+                character: 4,
+            },
+            end: Position {
+                line: 3,
+                character: 9,
+            },
+        };
+        let edge =
+            Edge::reference(EdgeSource::Regex, Some(ReferenceAccess::Call)).with_range(call_site);
+        assert_eq!(edge.confidence, EdgeSource::Regex.default_confidence());
+        assert_eq!(edge.access(), Some(ReferenceAccess::Call));
+        assert_eq!(Edge::new(EdgeKind::Contains).confidence, 1.0);
+
+        let json = serde_json::to_string(&edge).unwrap();
+        assert!(json.contains(r#""roles":["call"]"#));
+        assert!(json.contains(r#""source":"Regex""#));
+        let back: Edge = serde_json::from_str(&json).unwrap();
+        assert_eq!(back, edge);
+    }
+}
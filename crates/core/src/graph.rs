@@ -1,8 +1,12 @@
-use petgraph::stable_graph::{NodeIndex, StableDiGraph};
+use petgraph::stable_graph::{EdgeIndex, NodeIndex, StableDiGraph};
 use petgraph::visit::EdgeRef;
 use serde::{Deserialize, Serialize};
 use std::collections::HashMap;
 
+use crate::edge::Edge;
+use crate::modifiers::SymbolModifiers;
+use crate::position_encoding::PositionEncoding;
+
 #[derive(Debug, Clone, Serialize, Deserialize, PartialEq)]
 pub struct Symbol {
     pub id: String,
@@ -12,6 +16,37 @@ pub struct Symbol {
     pub range: Range,
     pub documentation: Option<String>,
     pub detail: Option<String>,
+    pub modifiers: SymbolModifiers,
+    /// 完全修飾名（`crate::store::Db::close`、`example.com/svc/store.(*DB).Close`など）
+    ///
+    /// インデックスの最後に`qualified_name::qualify_symbols`で付ける。
+    pub qualified_name: Option<String>,
+}
+
+impl Symbol {
+    /// 非推奨の説明（非推奨でなければNone、説明がなければ空文字列）
+    ///
+    /// 抽出時に`DocComment`で正規化したドキュメントの`**Deprecated**`段落から読む。
+    pub fn deprecation(&self) -> Option<&str> {
+        self.documentation
+            .as_deref()
+            .and_then(crate::documentation::deprecation_note)
+    }
+
+    /// 非推奨か（ドキュメントの印に加え、LSPの`SymbolTag::Deprecated`などで付いた修飾子も見る）
+    pub fn is_deprecated(&self) -> bool {
+        self.modifiers.contains(SymbolModifiers::DEPRECATED) || self.deprecation().is_some()
+    }
+
+    /// 表示用の名前（完全修飾名があればそれ）
+    pub fn display_name(&self) -> &str {
+        self.qualified_name.as_deref().unwrap_or(&self.name)
+    }
+
+    /// 名前か完全修飾名が一致するか
+    pub fn matches_name(&self, query: &str) -> bool {
+        self.name == query || self.qualified_name.as_deref() == Some(query)
+    }
 }
 
 #[derive(Debug, Clone, Copy, Serialize, Deserialize, PartialEq)]
@@ -73,9 +108,17 @@ pub enum EdgeKind {
     Contains,
 }
 
+/// `CodeGraph::replace_symbols_in_rows`で変更したシンボルの数
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq)]
+pub struct RowUpdateStats {
+    pub removed: usize,
+    pub shifted: usize,
+    pub added: usize,
+}
+
 #[derive(Debug, Clone)]
 pub struct CodeGraph {
-    pub graph: StableDiGraph<Symbol, EdgeKind>,
+    pub graph: StableDiGraph<Symbol, Edge>,
     pub symbol_index: HashMap<String, NodeIndex>,
 }
 
@@ -89,6 +132,9 @@ impl Default for CodeGraph {
 }
 
 impl CodeGraph {
+    /// シンボルの`Range`の列位置の単位（全てこのエンコーディングで保存する）
+    pub const POSITION_ENCODING: PositionEncoding = PositionEncoding::CANONICAL;
+
     pub fn new() -> Self {
         Self::default()
     }
@@ -122,7 +168,130 @@ impl CodeGraph {
     }
 
     pub fn add_edge(&mut self, from: NodeIndex, to: NodeIndex, kind: EdgeKind) {
-        self.graph.add_edge(from, to, kind);
+        self.graph.add_edge(from, to, Edge::new(kind));
+    }
+
+    /// 出現位置・役割・出どころ付きのエッジを張る
+    pub fn add_edge_with(&mut self, from: NodeIndex, to: NodeIndex, edge: Edge) -> EdgeIndex {
+        self.graph.add_edge(from, to, edge)
+    }
+
+    /// 範囲の入れ子から`Contains`エッジ（子→最も内側の親）を張る
+    ///
+    /// `symbols`はグラフに追加済みであること。異なるファイルのシンボルは結ばない。
+    pub fn link_containment(&mut self, symbols: &[Symbol]) {
+        fn key(position: &Position) -> (u32, u32) {
+            (position.line, position.character)
+        }
+
+        // 開始位置の昇順、同じ開始位置なら外側を先に
+        let mut ordered: Vec<&Symbol> = symbols.iter().collect();
+        ordered.sort_by(|a, b| {
+            (a.file_path.as_str(), key(&a.range.start))
+                .cmp(&(b.file_path.as_str(), key(&b.range.start)))
+                .then_with(|| key(&b.range.end).cmp(&key(&a.range.end)))
+        });
+
+        let mut enclosing: Vec<&Symbol> = Vec::new();
+        for symbol in ordered {
+            while let Some(parent) = enclosing.last() {
+                if parent.file_path == symbol.file_path
+                    && key(&parent.range.end) >= key(&symbol.range.end)
+                    && parent.range != symbol.range
+                {
+                    break;
+                }
+                enclosing.pop();
+            }
+
+            if let Some(parent) = enclosing.last() {
+                if let (Some(child_idx), Some(parent_idx)) = (
+                    self.get_node_index(&symbol.id),
+                    self.get_node_index(&parent.id),
+                ) {
+                    self.add_edge(child_idx, parent_idx, EdgeKind::Contains);
+                }
+            }
+            enclosing.push(symbol);
+        }
+    }
+
+    /// ファイルの変更された行のシンボルだけを差し替える
+    ///
+    /// 変更前の行範囲`old_rows`（両端を含む）と重なるシンボルを削除して`symbols`を追加し、
+    /// それより後ろのシンボルは`row_delta`行ずらす（エッジはそのまま）。
+    /// `old_rows`がNoneならファイルの全シンボルを置き換える。
+    /// 削除したシンボルへ他のファイルから入ってくる参照のエッジも消えるので、
+    /// 呼び出し側で参照元のファイルの参照を張り直すこと。
+    pub fn replace_symbols_in_rows(
+        &mut self,
+        file_path: &str,
+        old_rows: Option<(u32, u32)>,
+        row_delta: i64,
+        symbols: Vec<Symbol>,
+    ) -> RowUpdateStats {
+        let mut stats = RowUpdateStats::default();
+        let in_file: Vec<NodeIndex> = self
+            .graph
+            .node_indices()
+            .filter(|&idx| self.graph[idx].file_path == file_path)
+            .collect();
+
+        let mut shifted = Vec::new();
+        for idx in in_file {
+            let range = self.graph[idx].range;
+            match old_rows {
+                Some((first, _)) if range.end.line < first => {}
+                Some((_, last)) if range.start.line > last => {
+                    if row_delta != 0 {
+                        shifted.push(idx);
+                    }
+                }
+                _ => {
+                    let id = self.graph[idx].id.clone();
+                    if self.symbol_index.get(&id) == Some(&idx) {
+                        self.symbol_index.remove(&id);
+                    }
+                    self.graph.remove_node(idx);
+                    stats.removed += 1;
+                }
+            }
+        }
+
+        // IDに行番号を含むシンボルは新しい行のIDにする。
+        // ずらした後のIDが他のシンボルの古いIDと衝突しないよう、先に全て索引から外す
+        for &idx in &shifted {
+            let id = &self.graph[idx].id;
+            if self.symbol_index.get(id) == Some(&idx) {
+                let id = id.clone();
+                self.symbol_index.remove(&id);
+            }
+        }
+        for &idx in &shifted {
+            let symbol = &mut self.graph[idx];
+            let old_line = symbol.range.start.line;
+            let shift = |line: u32| (line as i64 + row_delta).max(0) as u32;
+            symbol.range.start.line = shift(symbol.range.start.line);
+            symbol.range.end.line = shift(symbol.range.end.line);
+            let line_id = format!("{}#{}:{}", symbol.file_path, old_line + 1, symbol.name);
+            if symbol.id == line_id {
+                symbol.id = format!(
+                    "{}#{}:{}",
+                    symbol.file_path,
+                    symbol.range.start.line + 1,
+                    symbol.name
+                );
+            }
+            self.symbol_index.insert(symbol.id.clone(), idx);
+        }
+        stats.shifted = shifted.len();
+
+        stats.added = symbols.len();
+        for symbol in &symbols {
+            self.add_symbol(symbol.clone());
+        }
+        self.link_containment(&symbols);
+        stats
     }
 
     pub fn find_symbol(&self, id: &str) -> Option<&Symbol> {
@@ -137,7 +306,7 @@ impl CodeGraph {
                 .graph
                 .edges_directed(node_idx, petgraph::Direction::Incoming)
             {
-                if matches!(edge.weight(), EdgeKind::Definition) {
+                if matches!(edge.weight().kind, EdgeKind::Definition) {
                     return self.graph.node_weight(edge.source());
                 }
             }
@@ -169,7 +338,7 @@ impl CodeGraph {
                 // このシンボルが参照している定義を探す
                 if let Some(&node_idx) = self.symbol_index.get(&symbol.id) {
                     for edge in self.graph.edges(node_idx) {
-                        if matches!(edge.weight(), EdgeKind::Reference) {
+                        if matches!(edge.weight().kind, EdgeKind::Reference) {
                             return self.graph.node_weight(edge.target());
                         }
                     }
@@ -189,7 +358,7 @@ impl CodeGraph {
                 .graph
                 .edges_directed(node_idx, petgraph::Direction::Incoming)
             {
-                if matches!(edge.weight(), EdgeKind::Implementation) {
+                if matches!(edge.weight().kind, EdgeKind::Implementation) {
                     if let Some(symbol) = self.graph.node_weight(edge.source()) {
                         implementations.push(symbol);
                     }
@@ -209,7 +378,7 @@ impl CodeGraph {
                 .graph
                 .edges_directed(node_idx, petgraph::Direction::Incoming)
             {
-                if matches!(edge.weight(), EdgeKind::Override) {
+                if matches!(edge.weight().kind, EdgeKind::Override) {
                     if let Some(symbol) = self.graph.node_weight(edge.source()) {
                         overrides.push(symbol);
                     }
@@ -229,7 +398,7 @@ impl CodeGraph {
                 .graph
                 .edges_directed(node_idx, petgraph::Direction::Incoming)
             {
-                if matches!(edge.weight(), EdgeKind::Reference) {
+                if matches!(edge.weight().kind, EdgeKind::Reference) {
                     if let Some(symbol) = self.graph.node_weight(edge.source()) {
                         references.push(symbol.clone());
                     }
@@ -241,6 +410,28 @@ impl CodeGraph {
         }
     }
 
+    /// 参照元のシンボルと参照のエッジ（出現位置・使われ方・確からしさ）
+    ///
+    /// `min_confidence`より確からしさの低いエッジ（正規表現の一致など）は除く。
+    pub fn find_reference_edges(
+        &self,
+        symbol_id: &str,
+        min_confidence: f32,
+    ) -> Vec<(&Symbol, &Edge)> {
+        let node_idx = match self.symbol_index.get(symbol_id) {
+            Some(&idx) => idx,
+            None => return Vec::new(),
+        };
+        self.graph
+            .edges_directed(node_idx, petgraph::Direction::Incoming)
+            .filter(|edge| {
+                edge.weight().kind == EdgeKind::Reference
+                    && edge.weight().confidence >= min_confidence
+            })
+            .filter_map(|edge| Some((self.graph.node_weight(edge.source())?, edge.weight())))
+            .collect()
+    }
+
     /// Find symbol at a specific position in a file
     pub fn find_symbol_at_position(
         &self,
@@ -291,7 +482,7 @@ impl CodeGraph {
                 .graph
                 .edges_directed(node_idx, petgraph::Direction::Outgoing)
             {
-                if edge_type.is_none() || edge_type == Some(*edge.weight()) {
+                if edge_type.is_none() || edge_type == Some(edge.weight().kind) {
                     if let Some(symbol) = self.graph.node_weight(edge.target()) {
                         targets.push(symbol.clone());
                     }
@@ -315,7 +506,7 @@ impl CodeGraph {
                 .graph
                 .edges_directed(node_idx, petgraph::Direction::Incoming)
             {
-                if edge_type.is_none() || edge_type == Some(*edge.weight()) {
+                if edge_type.is_none() || edge_type == Some(edge.weight().kind) {
                     if let Some(symbol) = self.graph.node_weight(edge.source()) {
                         sources.push(symbol.clone());
                     }
@@ -331,6 +522,7 @@ impl CodeGraph {
 #[cfg(test)]
 mod tests {
     use super::*;
+    use crate::edge::{EdgeSource, ReferenceAccess};
 
     fn create_test_symbol(id: &str, name: &str, kind: SymbolKind) -> Symbol {
         Symbol {
@@ -350,6 +542,8 @@ mod tests {
             },
             documentation: None,
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         }
     }
 
@@ -399,7 +593,7 @@ mod tests {
         // The edge should exist in the graph
         let edges: Vec<_> = graph.graph.edges(idx2).collect();
         assert_eq!(edges.len(), 1);
-        assert_eq!(*edges[0].weight(), EdgeKind::Reference);
+        assert_eq!(edges[0].weight().kind, EdgeKind::Reference);
     }
 
     #[test]
@@ -434,6 +628,41 @@ mod tests {
         assert_eq!(references.len(), 2);
     }
 
+    #[test]
+    fn test_reference_edges() {
+        let mut graph = CodeGraph::new();
+        let field = graph.add_symbol(create_test_symbol("field1", "count", SymbolKind::Field));
+        let caller = graph.add_symbol(create_test_symbol("func1", "bump", SymbolKind::Function));
+        let guess = graph.add_symbol(create_test_symbol("func2", "other", SymbolKind::Function));
+        let call_site = Range {
+            start: Position {
+                line: 7,
+                character: 4,
+            },
+            end: Position {
+                line: 7,
+                character: 9,
+            },
+        };
+        graph.add_edge_with(
+            caller,
+            field,
+            Edge::reference(EdgeSource::TreeSitter, Some(ReferenceAccess::ReadWrite))
+                .with_range(call_site),
+        );
+        graph.add_edge_with(guess, field, Edge::reference(EdgeSource::Regex, None));
+
+        assert_eq!(graph.find_reference_edges("field1", 0.0).len(), 2);
+        // 正規表現の一致は確からしさで除ける
+        let references = graph.find_reference_edges("field1", 0.5);
+        assert_eq!(references.len(), 1);
+        let (symbol, edge) = references[0];
+        assert_eq!(symbol.id, "func1");
+        assert_eq!(edge.access(), Some(ReferenceAccess::ReadWrite));
+        assert_eq!(edge.range, Some(call_site));
+        assert_eq!(edge.source, EdgeSource::TreeSitter);
+    }
+
     #[test]
     fn test_find_definition() {
         let mut graph = CodeGraph::new();
@@ -653,6 +882,87 @@ mod tests {
         assert!(graph.get_node_index("nonexistent").is_none());
         assert_eq!(graph.get_all_symbols().count(), 0);
     }
+
+    #[test]
+    fn test_link_containment() {
+        let spanning = |id: &str, start: u32, end: u32| {
+            let mut symbol = create_test_symbol(id, id, SymbolKind::Function);
+            symbol.range.start.line = start;
+            symbol.range.end.line = end;
+            symbol
+        };
+        let symbols = vec![
+            spanning("method", 2, 4),
+            spanning("impl", 1, 10),
+            spanning("inner", 3, 3),
+            spanning("other", 12, 14),
+        ];
+
+        let mut graph = CodeGraph::new();
+        for symbol in &symbols {
+            graph.add_symbol(symbol.clone());
+        }
+        graph.link_containment(&symbols);
+
+        let parent_of = |id: &str| {
+            let idx = graph.get_node_index(id).unwrap();
+            graph
+                .graph
+                .edges(idx)
+                .find(|e| e.weight().kind == EdgeKind::Contains)
+                .map(|e| graph.graph[e.target()].id.clone())
+        };
+        assert_eq!(parent_of("method").as_deref(), Some("impl"));
+        assert_eq!(parent_of("inner").as_deref(), Some("method"));
+        assert_eq!(parent_of("impl"), None);
+        assert_eq!(parent_of("other"), None);
+    }
+
+    #[test]
+    fn test_replace_symbols_in_rows() {
+        let at = |name: &str, start: u32, end: u32| {
+            let mut symbol = create_test_symbol(
+                &format!("a.rs#{}:{}", start + 1, name),
+                name,
+                SymbolKind::Function,
+            );
+            symbol.file_path = "a.rs".to_string();
+            symbol.range.start.line = start;
+            symbol.range.end.line = end;
+            symbol
+        };
+
+        let mut graph = CodeGraph::new();
+        for symbol in [at("first", 0, 2), at("edited", 4, 6), at("last", 8, 10)] {
+            graph.add_symbol(symbol);
+        }
+        let first = graph.get_node_index("a.rs#1:first").unwrap();
+        let last = graph.get_node_index("a.rs#9:last").unwrap();
+        graph.add_edge(first, last, EdgeKind::Reference);
+
+        // 5〜7行目に2行追加された
+        let stats =
+            graph.replace_symbols_in_rows("a.rs", Some((4, 6)), 2, vec![at("edited", 4, 8)]);
+        assert_eq!(
+            stats,
+            RowUpdateStats {
+                removed: 1,
+                shifted: 1,
+                added: 1
+            }
+        );
+
+        assert!(graph.find_symbol("a.rs#1:first").is_some());
+        assert_eq!(
+            graph.find_symbol("a.rs#5:edited").unwrap().range.end.line,
+            8
+        );
+        assert!(graph.find_symbol("a.rs#9:last").is_none());
+        let moved = graph.find_symbol("a.rs#11:last").unwrap();
+        assert_eq!(moved.range.start.line, 10);
+        // ずらしたシンボルのエッジは残る
+        assert_eq!(graph.find_references("a.rs#11:last").unwrap().len(), 1);
+    }
 }
 // Test comment
 
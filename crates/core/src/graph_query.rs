@@ -1,5 +1,7 @@
 use super::graph::{CodeGraph, EdgeKind, Symbol, SymbolKind};
-use petgraph::visit::EdgeRef;
+use super::graph_store::GraphStore;
+use crate::modifiers::SymbolModifiers;
+use rayon::prelude::*;
 use std::collections::{HashSet, VecDeque};
 use std::fmt;
 
@@ -312,23 +314,38 @@ impl QueryParser {
 }
 
 /// Query execution engine
-pub struct QueryEngine<'a> {
-    graph: &'a CodeGraph,
+pub struct QueryEngine<'a, G: GraphStore + ?Sized = CodeGraph> {
+    graph: &'a G,
+    /// これより確からしさの低いエッジはたどらない
+    min_confidence: f32,
 }
 
-impl<'a> QueryEngine<'a> {
-    pub fn new(graph: &'a CodeGraph) -> Self {
-        Self { graph }
+impl<'a, G: GraphStore + ?Sized> QueryEngine<'a, G> {
+    pub fn new(graph: &'a G) -> Self {
+        Self {
+            graph,
+            min_confidence: 0.0,
+        }
     }
 
-    /// Execute a query pattern on the graph
-    pub fn execute(&self, pattern: &QueryPattern) -> QueryResult {
-        let mut all_matches = Vec::new();
+    /// 確からしさの低いエッジ（正規表現の一致など）を除いてたどる
+    pub fn with_min_confidence(mut self, min_confidence: f32) -> Self {
+        self.min_confidence = min_confidence;
+        self
+    }
 
+    /// Execute a query pattern on the graph
+    ///
+    /// 候補ごとのパターンの照合はrayonの現在のスレッドプールで並列に行う。
+    /// 結果は候補の順（グラフのシンボルの順）で、スレッド数によらない。
+    pub fn execute(&self, pattern: &QueryPattern) -> QueryResult
+    where
+        G: Sync,
+    {
         // If no nodes specified, return empty
         if pattern.nodes.is_empty() {
             return QueryResult {
-                matches: all_matches,
+                matches: Vec::new(),
             };
         }
 
@@ -336,11 +353,10 @@ impl<'a> QueryEngine<'a> {
         let first_candidates = self.find_matching_nodes(&pattern.nodes[0]);
 
         // For each candidate, try to match the full pattern
-        for candidate in first_candidates {
-            if let Some(match_result) = self.match_pattern_from(candidate, pattern) {
-                all_matches.push(match_result);
-            }
-        }
+        let all_matches = first_candidates
+            .into_par_iter()
+            .filter_map(|candidate| self.match_pattern_from(candidate, pattern))
+            .collect();
 
         QueryResult {
             matches: all_matches,
@@ -351,9 +367,9 @@ impl<'a> QueryEngine<'a> {
     fn find_matching_nodes(&self, pattern: &NodePattern) -> Vec<Symbol> {
         let mut matches = Vec::new();
 
-        for symbol in self.graph.get_all_symbols() {
-            if self.node_matches_pattern(symbol, pattern) {
-                matches.push(symbol.clone());
+        for symbol in self.graph.symbols() {
+            if self.node_matches_pattern(&symbol, pattern) {
+                matches.push(symbol.into_owned());
             }
         }
 
@@ -397,15 +413,32 @@ impl<'a> QueryEngine<'a> {
 
     /// Check if a symbol property matches a filter
     fn property_matches(&self, symbol: &Symbol, filter: &PropertyFilter) -> bool {
-        let value = match filter.key.as_str() {
+        // 修飾子は集合として照合（`async,public`ならすべてを持つシンボル）
+        if matches!(filter.key.as_str(), "modifier" | "modifiers") {
+            return match SymbolModifiers::parse_list(&filter.value) {
+                Ok(wanted) => symbol.modifiers.contains(wanted),
+                Err(_) => false,
+            };
+        }
+
+        let value: &str = match filter.key.as_str() {
             "name" => &symbol.name,
             "id" => &symbol.id,
             "file" | "file_path" => &symbol.file_path,
+            "qualified_name" | "fqn" => symbol.qualified_name.as_deref().unwrap_or(""),
+            "doc" | "documentation" => symbol.documentation.as_deref().unwrap_or(""),
+            "deprecated" => {
+                if symbol.is_deprecated() {
+                    "true"
+                } else {
+                    "false"
+                }
+            }
             _ => return false,
         };
 
         match filter.operator {
-            FilterOperator::Equals => value == &filter.value,
+            FilterOperator::Equals => value == filter.value,
             FilterOperator::Contains => value.contains(&filter.value),
             FilterOperator::StartsWith => value.starts_with(&filter.value),
             FilterOperator::EndsWith => value.ends_with(&filter.value),
@@ -493,49 +526,43 @@ impl<'a> QueryEngine<'a> {
 
             // Continue traversal if not at max depth
             if rel.max_depth.is_none() || depth < rel.max_depth.unwrap() {
-                if let Some(node_idx) = self.graph.get_node_index(&current_id) {
-                    // Traverse edges based on direction
-                    let edges: Vec<_> = match rel.direction {
-                        Direction::Forward => self.graph.graph.edges(node_idx).collect(),
-                        Direction::Backward => self
-                            .graph
-                            .graph
-                            .edges_directed(node_idx, petgraph::Direction::Incoming)
-                            .collect(),
-                        Direction::Both => {
-                            let mut edges = self.graph.graph.edges(node_idx).collect::<Vec<_>>();
-                            edges.extend(
-                                self.graph
-                                    .graph
-                                    .edges_directed(node_idx, petgraph::Direction::Incoming),
-                            );
-                            edges
-                        }
-                    };
+                // Traverse edges based on direction (to the symbol at the other end)
+                let edges: Vec<_> = match rel.direction {
+                    Direction::Forward => self
+                        .graph
+                        .edges_of(&current_id, petgraph::Direction::Outgoing)
+                        .collect(),
+                    Direction::Backward => self
+                        .graph
+                        .edges_of(&current_id, petgraph::Direction::Incoming)
+                        .collect(),
+                    Direction::Both => self
+                        .graph
+                        .edges_of(&current_id, petgraph::Direction::Outgoing)
+                        .chain(
+                            self.graph
+                                .edges_of(&current_id, petgraph::Direction::Incoming),
+                        )
+                        .collect(),
+                };
 
-                    for edge in edges {
-                        // Check edge type
-                        if let Some(ref edge_type) = rel.edge_type {
-                            if !matches_edge_kind(edge.weight(), edge_type) {
-                                continue;
-                            }
+                for (target, edge) in edges {
+                    // Check edge type
+                    if let Some(ref edge_type) = rel.edge_type {
+                        if !matches_edge_kind(&edge.kind, edge_type) {
+                            continue;
                         }
+                    }
+                    if edge.confidence < self.min_confidence {
+                        continue;
+                    }
 
-                        let target_idx = if rel.direction == Direction::Backward {
-                            edge.source()
-                        } else {
-                            edge.target()
-                        };
-
-                        if let Some(target) = self.graph.graph.node_weight(target_idx) {
-                            let target_id = target.id.clone();
-                            if !visited.contains(&target_id) {
-                                visited.insert(target_id.clone());
-                                let mut new_path = path.clone();
-                                new_path.push(target.clone());
-                                queue.push_back((target_id, new_path, depth + 1));
-                            }
-                        }
+                    let target_id = target.id.clone();
+                    if !visited.contains(&target_id) {
+                        visited.insert(target_id.clone());
+                        let mut new_path = path.clone();
+                        new_path.push(target.into_owned());
+                        queue.push_back((target_id, new_path, depth + 1));
                     }
                 }
             }
@@ -634,6 +661,7 @@ pub fn format_query_results(results: &QueryResult) -> String {
 mod tests {
     use super::*;
     use crate::graph::{Position, Range, Symbol};
+    use crate::graph_store::GraphBackend;
 
     fn create_test_symbol(id: &str, name: &str, kind: SymbolKind) -> Symbol {
         Symbol {
@@ -653,6 +681,8 @@ mod tests {
             },
             documentation: None,
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         }
     }
 
@@ -722,20 +752,81 @@ mod tests {
         // Execute query
         let query = "(fn:Function)-[:Reference]->(cls:Class)";
         let pattern = QueryParser::parse(query).unwrap();
-        let engine = QueryEngine::new(&graph);
-        let results = engine.execute(&pattern);
-
-        assert_eq!(results.matches.len(), 1);
-        assert_eq!(results.matches[0].bindings.len(), 2);
-
-        // Check bindings
-        let bindings = &results.matches[0].bindings;
-        assert!(bindings
-            .iter()
-            .any(|(var, sym)| var == "fn" && sym.name == "main"));
-        assert!(bindings
-            .iter()
-            .any(|(var, sym)| var == "cls" && sym.name == "MyClass"));
+        for backend in GraphBackend::ALL {
+            let graph = backend.load(graph.clone());
+            let engine = QueryEngine::new(&*graph);
+            let results = engine.execute(&pattern);
+
+            assert_eq!(results.matches.len(), 1);
+            assert_eq!(results.matches[0].bindings.len(), 2);
+
+            // Check bindings
+            let bindings = &results.matches[0].bindings;
+            assert!(bindings
+                .iter()
+                .any(|(var, sym)| var == "fn" && sym.name == "main"));
+            assert!(bindings
+                .iter()
+                .any(|(var, sym)| var == "cls" && sym.name == "MyClass"));
+        }
+    }
+
+    #[test]
+    fn test_query_min_confidence() {
+        let mut graph = CodeGraph::new();
+        let func = graph.add_symbol(create_test_symbol("fn:main", "main", SymbolKind::Function));
+        let class = graph.add_symbol(create_test_symbol("class:A", "A", SymbolKind::Class));
+        graph.add_edge_with(
+            func,
+            class,
+            crate::edge::Edge::reference(crate::edge::EdgeSource::Regex, None),
+        );
+
+        let pattern = QueryParser::parse("(fn:Function)-[:Reference]->(cls:Class)").unwrap();
+        for backend in GraphBackend::ALL {
+            let graph = backend.load(graph.clone());
+            assert_eq!(QueryEngine::new(&*graph).execute(&pattern).matches.len(), 1);
+            let engine = QueryEngine::new(&*graph).with_min_confidence(0.5);
+            assert!(engine.execute(&pattern).matches.is_empty());
+        }
+    }
+
+    #[test]
+    fn test_query_results_do_not_depend_on_threads() {
+        let mut graph = CodeGraph::new();
+        let mut classes = Vec::new();
+        for i in 0..50 {
+            classes.push(graph.add_symbol(create_test_symbol(
+                &format!("class:C{}", i),
+                &format!("C{}", i),
+                SymbolKind::Class,
+            )));
+        }
+        for i in 0..200 {
+            let func = graph.add_symbol(create_test_symbol(
+                &format!("fn:f{}", i),
+                &format!("f{}", i),
+                SymbolKind::Function,
+            ));
+            graph.add_edge(func, classes[i % 50], EdgeKind::Reference);
+        }
+
+        let pattern = QueryParser::parse("(fn:Function)-[:Reference]->(cls:Class)").unwrap();
+        let run = |threads: usize| -> Vec<Vec<String>> {
+            let pool = rayon::ThreadPoolBuilder::new()
+                .num_threads(threads)
+                .build()
+                .unwrap();
+            pool.install(|| QueryEngine::new(&graph).execute(&pattern))
+                .matches
+                .iter()
+                .map(|m| m.bindings.iter().map(|(_, s)| s.id.clone()).collect())
+                .collect()
+        };
+        let sequential = run(1);
+        assert_eq!(sequential.len(), 200);
+        assert_eq!(sequential[0], ["fn:f0", "class:C0"]);
+        assert_eq!(run(4), sequential);
     }
 
     #[test]
@@ -849,6 +940,17 @@ mod tests {
         };
         assert!(engine.property_matches(&symbol, &filter));
 
+        // Test qualified name
+        let filter = PropertyFilter {
+            key: "qualified_name".to_string(),
+            operator: FilterOperator::StartsWith,
+            value: "crate::api::".to_string(),
+        };
+        assert!(!engine.property_matches(&symbol, &filter));
+        let mut qualified = symbol.clone();
+        qualified.qualified_name = Some("crate::api::TestFunction".to_string());
+        assert!(engine.property_matches(&qualified, &filter));
+
         // Test invalid key
         let filter = PropertyFilter {
             key: "invalid".to_string(),
@@ -856,6 +958,29 @@ mod tests {
             value: "test".to_string(),
         };
         assert!(!engine.property_matches(&symbol, &filter));
+
+        // Test deprecated
+        let filter = PropertyFilter {
+            key: "deprecated".to_string(),
+            operator: FilterOperator::Equals,
+            value: "true".to_string(),
+        };
+        assert!(!engine.property_matches(&symbol, &filter));
+        let mut deprecated = symbol.clone();
+        deprecated.documentation = Some("Old API\n\n**Deprecated**: use New".to_string());
+        assert!(engine.property_matches(&deprecated, &filter));
+
+        // Test modifiers
+        let filter = PropertyFilter {
+            key: "modifiers".to_string(),
+            operator: FilterOperator::Contains,
+            value: "pub,async".to_string(),
+        };
+        assert!(!engine.property_matches(&symbol, &filter));
+        let mut async_fn = symbol.clone();
+        async_fn.modifiers =
+            SymbolModifiers::PUBLIC | SymbolModifiers::ASYNC | SymbolModifiers::STATIC;
+        assert!(engine.property_matches(&async_fn, &filter));
     }
 
     #[test]
@@ -1,55 +1,97 @@
-use super::graph::{CodeGraph, EdgeKind, Symbol};
+use super::edge::Edge;
+use super::graph::{CodeGraph, Symbol};
 use petgraph::graph::NodeIndex;
 use serde::{Deserialize, Deserializer, Serialize, Serializer};
 use std::collections::HashMap;
 
+/// 保存形式のままのグラフ
+///
+/// `CodeGraph`と同じ形式で読み書きできる。読み込むと消える壊れたエッジや重複したIDを
+/// 診断するために使う（`integrity::check_serialized`）。ファイルごとのグラフをつなげて、
+/// `CodeGraph`を組み立てずに保存するためにも使う。
 #[derive(Serialize, Deserialize)]
-struct SerializedCodeGraph {
-    symbols: Vec<Symbol>,
-    edges: Vec<SerializedEdge>,
+pub struct SerializedCodeGraph {
+    pub symbols: Vec<Symbol>,
+    pub edges: Vec<SerializedEdge>,
 }
 
 #[derive(Serialize, Deserialize)]
-struct SerializedEdge {
-    from_id: String,
-    to_id: String,
-    kind: EdgeKind,
+pub struct SerializedEdge {
+    pub from_id: String,
+    pub to_id: String,
+    pub edge: Edge,
 }
 
-impl Serialize for CodeGraph {
-    fn serialize<S>(&self, serializer: S) -> Result<S::Ok, S::Error>
-    where
-        S: Serializer,
-    {
+impl SerializedCodeGraph {
+    /// `CodeGraph`を組み立てる（端点のないエッジは捨てる）
+    pub fn into_graph(self) -> CodeGraph {
+        let mut graph = CodeGraph::new();
+
+        // First, add all symbols to establish node indices
+        let mut id_to_node: HashMap<String, NodeIndex> = HashMap::new();
+        for symbol in self.symbols {
+            let node_idx = graph.add_symbol(symbol.clone());
+            id_to_node.insert(symbol.id, node_idx);
+        }
+
+        // Then, add all edges
+        for edge in self.edges {
+            if let (Some(&from_idx), Some(&to_idx)) =
+                (id_to_node.get(&edge.from_id), id_to_node.get(&edge.to_id))
+            {
+                graph.add_edge_with(from_idx, to_idx, edge.edge);
+            }
+        }
+
+        graph
+    }
+
+    /// 別のグラフのシンボルとエッジを加える（IDが重ならないファイル単位のグラフ同士で使う）
+    pub fn append(&mut self, other: SerializedCodeGraph) {
+        self.symbols.extend(other.symbols);
+        self.edges.extend(other.edges);
+    }
+}
+
+impl From<&CodeGraph> for SerializedCodeGraph {
+    fn from(graph: &CodeGraph) -> Self {
         let mut symbols = Vec::new();
         let mut edges = Vec::new();
 
         // Collect all symbols
-        for id in self.symbol_index.keys() {
-            if let Some(symbol) = self.find_symbol(id) {
+        for id in graph.symbol_index.keys() {
+            if let Some(symbol) = graph.find_symbol(id) {
                 symbols.push(symbol.clone());
             }
         }
 
         // Collect all edges
-        for edge in self.graph.edge_indices() {
-            if let Some((from, to)) = self.graph.edge_endpoints(edge) {
+        for edge in graph.graph.edge_indices() {
+            if let Some((from, to)) = graph.graph.edge_endpoints(edge) {
                 if let (Some(from_symbol), Some(to_symbol)) =
-                    (self.graph.node_weight(from), self.graph.node_weight(to))
+                    (graph.graph.node_weight(from), graph.graph.node_weight(to))
                 {
-                    if let Some(edge_kind) = self.graph.edge_weight(edge) {
+                    if let Some(weight) = graph.graph.edge_weight(edge) {
                         edges.push(SerializedEdge {
                             from_id: from_symbol.id.clone(),
                             to_id: to_symbol.id.clone(),
-                            kind: *edge_kind,
+                            edge: *weight,
                         });
                     }
                 }
             }
         }
 
-        let serialized = SerializedCodeGraph { symbols, edges };
-        serialized.serialize(serializer)
+        Self { symbols, edges }
+    }
+}
+
+impl Serialize for CodeGraph {
+    fn serialize<S>(&self, serializer: S) -> Result<S::Ok, S::Error>
+    where
+        S: Serializer,
+    {
+        SerializedCodeGraph::from(self).serialize(serializer)
     }
 }
 
@@ -59,32 +101,15 @@ impl<'de> Deserialize<'de> for CodeGraph {
         D: Deserializer<'de>,
     {
         let serialized = SerializedCodeGraph::deserialize(deserializer)?;
-        let mut graph = CodeGraph::new();
-
-        // First, add all symbols to establish node indices
-        let mut id_to_node: HashMap<String, NodeIndex> = HashMap::new();
-        for symbol in serialized.symbols {
-            let node_idx = graph.add_symbol(symbol.clone());
-            id_to_node.insert(symbol.id, node_idx);
-        }
-
-        // Then, add all edges
-        for edge in serialized.edges {
-            if let (Some(&from_idx), Some(&to_idx)) =
-                (id_to_node.get(&edge.from_id), id_to_node.get(&edge.to_id))
-            {
-                graph.add_edge(from_idx, to_idx, edge.kind);
-            }
-        }
-
-        Ok(graph)
+        Ok(serialized.into_graph())
     }
 }
 
 #[cfg(test)]
 mod tests {
     use super::*;
-    use crate::graph::{Position, Range, SymbolKind};
+    use crate::edge::{EdgeSource, ReferenceAccess};
+    use crate::graph::{EdgeKind, Position, Range, SymbolKind};
 
     fn create_test_symbol(id: &str, name: &str) -> Symbol {
         Symbol {
@@ -104,6 +129,8 @@ mod tests {
             },
             documentation: None,
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         }
     }
 
@@ -176,6 +203,8 @@ mod tests {
             },
             documentation: Some("Interface documentation".to_string()),
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         };
 
         let class = Symbol {
@@ -195,6 +224,8 @@ mod tests {
             },
             documentation: Some("Class documentation".to_string()),
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         };
 
         let method = Symbol {
@@ -214,6 +245,8 @@ mod tests {
             },
             documentation: None,
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         };
 
         let interface_idx = graph.add_symbol(interface);
@@ -248,12 +281,49 @@ mod tests {
         assert_eq!(implementations[0].id, "class1");
     }
 
+    #[test]
+    fn test_serialize_deserialize_edge_data() {
+        let mut graph = CodeGraph::new();
+        let field = graph.add_symbol(create_test_symbol("field1", "count"));
+        let write = graph.add_symbol(create_test_symbol("ref1", "count"));
+        let guess = graph.add_symbol(create_test_symbol("ref2", "count"));
+        let site = Range {
+            start: Position {
+                line: 4,
+                character: 8,
+            },
+            end: Position {
+                line: 4,
+                character: 13,
+            },
+        };
+        graph.add_edge_with(
+            write,
+            field,
+            Edge::reference(EdgeSource::Lsp, Some(ReferenceAccess::Write)).with_range(site),
+        );
+        graph.add_edge_with(guess, field, Edge::reference(EdgeSource::Regex, None));
+
+        let serialized = serde_json::to_string(&graph).unwrap();
+        let deserialized: CodeGraph = serde_json::from_str(&serialized).unwrap();
+
+        let mut references = deserialized.find_reference_edges("field1", 0.0);
+        references.sort_by(|a, b| a.0.id.cmp(&b.0.id));
+        assert_eq!(references.len(), 2);
+        let (_, written) = references[0];
+        assert_eq!(written.access(), Some(ReferenceAccess::Write));
+        assert_eq!(written.range, Some(site));
+        assert_eq!(written.source, EdgeSource::Lsp);
+        assert_eq!(references[1].1.source, EdgeSource::Regex);
+        assert_eq!(deserialized.find_reference_edges("field1", 0.5).len(), 1);
+    }
+
     #[test]
     fn test_serialized_edge_structure() {
         let edge = SerializedEdge {
             from_id: "from".to_string(),
             to_id: "to".to_string(),
-            kind: EdgeKind::Definition,
+            edge: Edge::new(EdgeKind::Definition),
         };
 
         let json = serde_json::to_string(&edge).unwrap();
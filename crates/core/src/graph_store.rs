@@ -0,0 +1,381 @@
+//! グラフの保存方式の共通インターフェース
+//!
+//! 呼び出し階層・型の関係・クエリ・LSIF出力はこのトレイトだけでグラフをたどるので、
+//! petgraphの`CodeGraph`（標準）と文字列をインターン化した`InternedGraph`のどちらでも
+//! 同じ結果になる。どちらを使うかは`GraphBackend`（CLIの`--graph-backend`か
+//! 環境変数`LSIF_GRAPH_BACKEND`）で選ぶ。
+
+use petgraph::visit::EdgeRef;
+use petgraph::Direction;
+use std::borrow::Cow;
+use std::fmt;
+use std::str::FromStr;
+
+use crate::edge::Edge;
+use crate::graph::{CodeGraph, Symbol};
+use crate::graph_serde::SerializedCodeGraph;
+use crate::interned_graph::InternedGraph;
+
+/// シンボルとエッジを持つグラフ
+///
+/// シンボルはIDで引く。同じIDを2回追加すると、検索では新しい方を返す。
+/// 列挙の順序はどの実装でも同じ（シンボルとグラフ全体のエッジは追加した順、
+/// あるシンボルのエッジは新しい順）。
+pub trait GraphStore {
+    fn add_symbol(&mut self, symbol: Symbol);
+
+    /// IDで指定したシンボルの間にエッジを張る（どちらかがなければ張らずにfalse）
+    fn add_edge_between(&mut self, from: &str, to: &str, edge: Edge) -> bool;
+
+    fn get_symbol(&self, id: &str) -> Option<Cow<'_, Symbol>>;
+
+    /// IDの異なるシンボルの数
+    fn symbol_count(&self) -> usize;
+
+    fn edge_count(&self) -> usize;
+
+    /// 全シンボル
+    fn symbols(&self) -> Box<dyn Iterator<Item = Cow<'_, Symbol>> + '_>;
+
+    /// 全エッジ（張った元、張った先、エッジ）
+    fn edges(&self) -> Box<dyn Iterator<Item = (Cow<'_, Symbol>, Cow<'_, Symbol>, Edge)> + '_>;
+
+    /// シンボルのエッジと相手のシンボル（`Outgoing`なら張った先、`Incoming`なら張った元）
+    fn edges_of(
+        &self,
+        id: &str,
+        direction: Direction,
+    ) -> Box<dyn Iterator<Item = (Cow<'_, Symbol>, Edge)> + '_>;
+}
+
+impl<G: GraphStore + ?Sized> GraphStore for Box<G> {
+    fn add_symbol(&mut self, symbol: Symbol) {
+        (**self).add_symbol(symbol)
+    }
+
+    fn add_edge_between(&mut self, from: &str, to: &str, edge: Edge) -> bool {
+        (**self).add_edge_between(from, to, edge)
+    }
+
+    fn get_symbol(&self, id: &str) -> Option<Cow<'_, Symbol>> {
+        (**self).get_symbol(id)
+    }
+
+    fn symbol_count(&self) -> usize {
+        (**self).symbol_count()
+    }
+
+    fn edge_count(&self) -> usize {
+        (**self).edge_count()
+    }
+
+    fn symbols(&self) -> Box<dyn Iterator<Item = Cow<'_, Symbol>> + '_> {
+        (**self).symbols()
+    }
+
+    fn edges(&self) -> Box<dyn Iterator<Item = (Cow<'_, Symbol>, Cow<'_, Symbol>, Edge)> + '_> {
+        (**self).edges()
+    }
+
+    fn edges_of(
+        &self,
+        id: &str,
+        direction: Direction,
+    ) -> Box<dyn Iterator<Item = (Cow<'_, Symbol>, Edge)> + '_> {
+        (**self).edges_of(id, direction)
+    }
+}
+
+impl GraphStore for CodeGraph {
+    fn add_symbol(&mut self, symbol: Symbol) {
+        CodeGraph::add_symbol(self, symbol);
+    }
+
+    fn add_edge_between(&mut self, from: &str, to: &str, edge: Edge) -> bool {
+        match (self.get_node_index(from), self.get_node_index(to)) {
+            (Some(from), Some(to)) => {
+                self.add_edge_with(from, to, edge);
+                true
+            }
+            _ => false,
+        }
+    }
+
+    fn get_symbol(&self, id: &str) -> Option<Cow<'_, Symbol>> {
+        self.find_symbol(id).map(Cow::Borrowed)
+    }
+
+    fn symbol_count(&self) -> usize {
+        CodeGraph::symbol_count(self)
+    }
+
+    fn edge_count(&self) -> usize {
+        self.graph.edge_count()
+    }
+
+    fn symbols(&self) -> Box<dyn Iterator<Item = Cow<'_, Symbol>> + '_> {
+        Box::new(self.graph.node_weights().map(Cow::Borrowed))
+    }
+
+    fn edges(&self) -> Box<dyn Iterator<Item = (Cow<'_, Symbol>, Cow<'_, Symbol>, Edge)> + '_> {
+        Box::new(self.graph.edge_references().map(|edge| {
+            (
+                Cow::Borrowed(&self.graph[edge.source()]),
+                Cow::Borrowed(&self.graph[edge.target()]),
+                *edge.weight(),
+            )
+        }))
+    }
+
+    fn edges_of(
+        &self,
+        id: &str,
+        direction: Direction,
+    ) -> Box<dyn Iterator<Item = (Cow<'_, Symbol>, Edge)> + '_> {
+        let node_idx = match self.get_node_index(id) {
+            Some(idx) => idx,
+            None => return Box::new(std::iter::empty()),
+        };
+        Box::new(
+            self.graph
+                .edges_directed(node_idx, direction)
+                .map(move |edge| {
+                    let other = match direction {
+                        Direction::Outgoing => edge.target(),
+                        Direction::Incoming => edge.source(),
+                    };
+                    (Cow::Borrowed(&self.graph[other]), *edge.weight())
+                }),
+        )
+    }
+}
+
+/// グラフの保存方式
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq)]
+pub enum GraphBackend {
+    /// petgraphの`CodeGraph`
+    #[default]
+    Standard,
+    /// 文字列をインターン化した`InternedGraph`（同じファイルパスや名前の多い大きなグラフ向け）
+    Interned,
+}
+
+impl GraphBackend {
+    pub const ALL: [GraphBackend; 2] = [GraphBackend::Standard, GraphBackend::Interned];
+
+    /// 環境変数`LSIF_GRAPH_BACKEND`の保存方式（未設定なら標準）
+    pub fn from_env() -> anyhow::Result<Self> {
+        match std::env::var("LSIF_GRAPH_BACKEND") {
+            Ok(value) => value
+                .parse()
+                .map_err(|e: String| anyhow::anyhow!("LSIF_GRAPH_BACKEND: {}", e)),
+            Err(_) => Ok(Self::default()),
+        }
+    }
+
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            GraphBackend::Standard => "standard",
+            GraphBackend::Interned => "interned",
+        }
+    }
+
+    /// 読み込んだグラフをこの保存方式にする
+    pub fn load(&self, graph: CodeGraph) -> Box<dyn GraphStore + Send + Sync> {
+        match self {
+            GraphBackend::Standard => Box::new(graph),
+            GraphBackend::Interned => Box::new(InternedGraph::from(&graph)),
+        }
+    }
+
+    /// 保存形式のグラフから直接この保存方式のグラフを作る（`CodeGraph`を経由しない）
+    pub fn load_serialized(&self, graph: SerializedCodeGraph) -> Box<dyn GraphStore + Send + Sync> {
+        match self {
+            GraphBackend::Standard => Box::new(graph.into_graph()),
+            GraphBackend::Interned => Box::new(InternedGraph::from(graph)),
+        }
+    }
+}
+
+impl FromStr for GraphBackend {
+    type Err = String;
+
+    fn from_str(value: &str) -> Result<Self, Self::Err> {
+        match value.trim().to_ascii_lowercase().as_str() {
+            "standard" | "petgraph" => Ok(GraphBackend::Standard),
+            "interned" => Ok(GraphBackend::Interned),
+            other => Err(format!(
+                "unknown graph backend '{}' (use standard or interned)",
+                other
+            )),
+        }
+    }
+}
+
+impl fmt::Display for GraphBackend {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        f.write_str(self.as_str())
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::edge::{EdgeSource, ReferenceAccess};
+    use crate::graph::{EdgeKind, Position, Range, SymbolKind};
+    use crate::graph_serde::SerializedEdge;
+    use crate::lsif::generate_lsif;
+    use crate::test_fixtures::SymbolBuilder;
+
+    fn symbol(id: &str, kind: SymbolKind, file: &str, line: u32) -> Symbol {
+        SymbolBuilder::new(id, id.rsplit("::").next().unwrap(), file)
+            .kind(kind)
+            .range((line, 0), (line + 3, 1))
+            .documentation(&format!("Docs for {}", id))
+            .qualified_name(id)
+            .build()
+    }
+
+    fn sample_graph() -> CodeGraph {
+        let mut graph = CodeGraph::new();
+        graph.add_symbol(symbol("Shape", SymbolKind::Interface, "shape.rs", 0));
+        graph.add_symbol(symbol("Circle", SymbolKind::Class, "shape.rs", 10));
+        graph.add_symbol(symbol("Circle::area", SymbolKind::Method, "shape.rs", 12));
+        graph.add_symbol(symbol("main", SymbolKind::Function, "main.rs", 0));
+        graph.add_symbol(symbol("total", SymbolKind::Variable, "main.rs", 2));
+
+        let call =
+            Edge::reference(EdgeSource::Lsp, Some(ReferenceAccess::Call)).with_range(Range {
+                start: Position {
+                    line: 1,
+                    character: 4,
+                },
+                end: Position {
+                    line: 1,
+                    character: 8,
+                },
+            });
+        for (from, to, edge) in [
+            ("Circle", "Shape", Edge::new(EdgeKind::Definition)),
+            ("main", "Circle::area", call),
+            ("total", "Circle", Edge::new(EdgeKind::Reference)),
+            ("main", "Circle", Edge::new(EdgeKind::Reference)),
+            ("Circle::area", "Circle", Edge::new(EdgeKind::Contains)),
+        ] {
+            assert!(GraphStore::add_edge_between(&mut graph, from, to, edge));
+        }
+        graph
+    }
+
+    fn ids<'a>(symbols: impl Iterator<Item = Cow<'a, Symbol>>) -> Vec<String> {
+        symbols.map(|symbol| symbol.id.clone()).collect()
+    }
+
+    #[test]
+    fn test_backends_agree() {
+        let standard = GraphBackend::Standard.load(sample_graph());
+        let interned = GraphBackend::Interned.load(sample_graph());
+
+        assert_eq!(standard.symbol_count(), interned.symbol_count());
+        assert_eq!(standard.edge_count(), interned.edge_count());
+        assert_eq!(ids(standard.symbols()), ids(interned.symbols()));
+        assert_eq!(
+            standard.get_symbol("Circle::area").map(Cow::into_owned),
+            interned.get_symbol("Circle::area").map(Cow::into_owned)
+        );
+        assert!(interned.get_symbol("missing").is_none());
+
+        let edges = |graph: &dyn GraphStore| -> Vec<(String, String, Edge)> {
+            graph
+                .edges()
+                .map(|(from, to, edge)| (from.id.clone(), to.id.clone(), edge))
+                .collect()
+        };
+        assert_eq!(edges(&*standard), edges(&*interned));
+
+        for id in ["Shape", "Circle", "Circle::area", "main", "missing"] {
+            for direction in [Direction::Outgoing, Direction::Incoming] {
+                let neighbors = |graph: &dyn GraphStore| -> Vec<(String, Edge)> {
+                    graph
+                        .edges_of(id, direction)
+                        .map(|(other, edge)| (other.id.clone(), edge))
+                        .collect()
+                };
+                assert_eq!(neighbors(&*standard), neighbors(&*interned), "{id}");
+            }
+        }
+    }
+
+    #[test]
+    fn test_backends_agree_when_loaded_from_serialized_graph() {
+        // 重複したIDと端点のないエッジも、`CodeGraph`に読み込んだ時と同じに扱う
+        let serialized = || {
+            let mut graph = SerializedCodeGraph::from(&sample_graph());
+            graph
+                .symbols
+                .push(symbol("main", SymbolKind::Function, "other.rs", 7));
+            graph.edges.push(SerializedEdge {
+                from_id: "total".to_string(),
+                to_id: "main".to_string(),
+                edge: Edge::new(EdgeKind::Reference),
+            });
+            graph.edges.push(SerializedEdge {
+                from_id: "main".to_string(),
+                to_id: "missing".to_string(),
+                edge: Edge::new(EdgeKind::Reference),
+            });
+            graph
+        };
+
+        let via_graph = GraphBackend::Interned.load(serialized().into_graph());
+        for backend in GraphBackend::ALL {
+            let graph = backend.load_serialized(serialized());
+            assert_eq!(graph.symbol_count(), via_graph.symbol_count(), "{backend}");
+            assert_eq!(graph.edge_count(), via_graph.edge_count(), "{backend}");
+            assert_eq!(
+                graph.get_symbol("main").unwrap().file_path,
+                "other.rs",
+                "{backend}"
+            );
+            let callers = |graph: &dyn GraphStore| -> Vec<String> {
+                ids(graph
+                    .edges_of("main", Direction::Incoming)
+                    .map(|(other, _)| other))
+            };
+            assert_eq!(callers(&*graph), vec!["total"], "{backend}");
+            assert_eq!(callers(&*graph), callers(&*via_graph), "{backend}");
+        }
+    }
+
+    #[test]
+    fn test_edges_of_newest_first() {
+        let graph = sample_graph();
+        let callers: Vec<_> = ids(graph
+            .edges_of("Circle", Direction::Incoming)
+            .map(|(other, _)| other));
+        assert_eq!(callers, vec!["Circle::area", "main", "total"]);
+    }
+
+    #[test]
+    fn test_backends_generate_same_lsif() {
+        let parse = |lsif: String| -> Vec<serde_json::Value> {
+            lsif.lines()
+                .map(|line| serde_json::from_str(line).unwrap())
+                .collect()
+        };
+        let standard = generate_lsif(GraphBackend::Standard.load(sample_graph())).unwrap();
+        let interned = generate_lsif(GraphBackend::Interned.load(sample_graph())).unwrap();
+        assert_eq!(parse(standard), parse(interned));
+    }
+
+    #[test]
+    fn test_parse_backend() {
+        assert_eq!("interned".parse(), Ok(GraphBackend::Interned));
+        assert_eq!(" Standard ".parse(), Ok(GraphBackend::Standard));
+        assert_eq!("petgraph".parse(), Ok(GraphBackend::Standard));
+        assert!("sled".parse::<GraphBackend>().is_err());
+        for backend in GraphBackend::ALL {
+            assert_eq!(backend.as_str().parse(), Ok(backend));
+        }
+    }
+}
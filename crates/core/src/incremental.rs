@@ -148,10 +148,7 @@ impl IncrementalIndex {
 
     fn update_symbol(&mut self, symbol: Symbol) -> Result<()> {
         // Remove old symbol
-        if let Some(node_idx) = self.graph.get_node_index(&symbol.id) {
-            self.graph.graph.remove_node(node_idx);
-            self.graph.symbol_index.remove(&symbol.id);
-        }
+        self.graph.remove_symbol(&symbol.id);
 
         // Add updated symbol
         self.add_symbol(symbol)?;
@@ -159,10 +156,7 @@ impl IncrementalIndex {
     }
 
     fn remove_symbol(&mut self, symbol_id: &str) -> Result<()> {
-        if let Some(node_idx) = self.graph.get_node_index(symbol_id) {
-            self.graph.graph.remove_node(node_idx);
-            self.graph.symbol_index.remove(symbol_id);
-        }
+        self.graph.remove_symbol(symbol_id);
         Ok(())
     }
 
@@ -187,7 +181,10 @@ impl IncrementalIndex {
             if let Some(node_idx) = self.graph.get_node_index(&symbol_id) {
                 // Find all symbols referenced by this symbol
                 for edge in self.graph.graph.edges(node_idx) {
-                    if matches!(edge.weight(), EdgeKind::Reference | EdgeKind::Definition) {
+                    if matches!(
+                        edge.weight().kind,
+                        EdgeKind::Reference | EdgeKind::Definition
+                    ) {
                         if let Some(target_symbol) = self.graph.graph.node_weight(edge.target()) {
                             if live_symbols.insert(target_symbol.id.clone()) {
                                 to_visit.push(target_symbol.id.clone());
@@ -347,6 +344,8 @@ mod tests {
             },
             documentation: None,
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         }
     }
 
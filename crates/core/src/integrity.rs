@@ -0,0 +1,310 @@
+//! インデックスの整合性の検査と修復
+//!
+//! `lsif doctor`が使う。`symbol_index`とノードの対応・IDの重複・端点のないエッジと、
+//! シンボルの範囲がファイルの行数に収まっているかを調べる。
+
+use crate::graph::{CodeGraph, EdgeKind};
+use crate::graph_serde::SerializedCodeGraph;
+use petgraph::stable_graph::NodeIndex;
+use serde::Serialize;
+use std::collections::{BTreeMap, HashMap, HashSet};
+use std::fmt;
+
+/// 見つかった問題
+#[derive(Debug, Clone, PartialEq, Eq, Serialize)]
+#[serde(tag = "kind", rename_all = "snake_case")]
+pub enum IntegrityIssue {
+    /// `symbol_index`が削除済みのノードを指している
+    DanglingIndexEntry { symbol_id: String },
+    /// `symbol_index`が別のIDのシンボルを指している
+    MisindexedSymbol {
+        symbol_id: String,
+        actual_id: String,
+    },
+    /// 同じIDのシンボルが複数ある
+    DuplicateId { symbol_id: String, count: usize },
+    /// 端点のシンボルがないエッジ（保存形式でだけ起こる。読み込むと消える）
+    DanglingEdge {
+        from_id: String,
+        to_id: String,
+        kind: EdgeKind,
+    },
+    /// 範囲がファイルの行数を超えている（インデックス後にファイルが短くなった）
+    RangeOutOfBounds {
+        symbol_id: String,
+        file_path: String,
+        line: u32,
+        line_count: usize,
+    },
+}
+
+impl IntegrityIssue {
+    /// 再インデックスすれば直るファイル
+    pub fn file_path(&self) -> Option<&str> {
+        match self {
+            Self::RangeOutOfBounds { file_path, .. } => Some(file_path),
+            _ => None,
+        }
+    }
+}
+
+impl fmt::Display for IntegrityIssue {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        match self {
+            Self::DanglingIndexEntry { symbol_id } => {
+                write!(f, "index entry {} points to a removed node", symbol_id)
+            }
+            Self::MisindexedSymbol {
+                symbol_id,
+                actual_id,
+            } => write!(f, "index entry {} points to {}", symbol_id, actual_id),
+            Self::DuplicateId { symbol_id, count } => {
+                write!(f, "{} symbols share the id {}", count, symbol_id)
+            }
+            Self::DanglingEdge {
+                from_id,
+                to_id,
+                kind,
+            } => write!(
+                f,
+                "{:?} edge {} -> {} has a missing end",
+                kind, from_id, to_id
+            ),
+            Self::RangeOutOfBounds {
+                symbol_id,
+                file_path,
+                line,
+                line_count,
+            } => write!(
+                f,
+                "{} is on line {} but {} has {} lines",
+                symbol_id,
+                line + 1,
+                file_path,
+                line_count
+            ),
+        }
+    }
+}
+
+/// グラフの構造を検査する（`symbol_index`とノードの対応、IDの重複）
+pub fn check_graph(graph: &CodeGraph) -> Vec<IntegrityIssue> {
+    let mut issues = Vec::new();
+
+    let index: BTreeMap<&String, &NodeIndex> = graph.symbol_index.iter().collect();
+    for (id, &idx) in index {
+        match graph.graph.node_weight(idx) {
+            None => issues.push(IntegrityIssue::DanglingIndexEntry {
+                symbol_id: id.clone(),
+            }),
+            Some(symbol) if symbol.id != *id => issues.push(IntegrityIssue::MisindexedSymbol {
+                symbol_id: id.clone(),
+                actual_id: symbol.id.clone(),
+            }),
+            Some(_) => {}
+        }
+    }
+
+    issues.extend(duplicate_ids(
+        graph.graph.node_weights().map(|symbol| symbol.id.as_str()),
+    ));
+    issues
+}
+
+/// 保存形式のグラフを検査する（IDの重複、端点のないエッジ）
+pub fn check_serialized(serialized: &SerializedCodeGraph) -> Vec<IntegrityIssue> {
+    let mut issues = duplicate_ids(serialized.symbols.iter().map(|symbol| symbol.id.as_str()));
+
+    let ids: HashSet<&str> = serialized
+        .symbols
+        .iter()
+        .map(|symbol| symbol.id.as_str())
+        .collect();
+    for edge in &serialized.edges {
+        if !ids.contains(edge.from_id.as_str()) || !ids.contains(edge.to_id.as_str()) {
+            issues.push(IntegrityIssue::DanglingEdge {
+                from_id: edge.from_id.clone(),
+                to_id: edge.to_id.clone(),
+                kind: edge.edge.kind,
+            });
+        }
+    }
+    issues
+}
+
+/// シンボルの範囲がファイルに収まっているか検査する
+///
+/// `line_count`はファイルの行数（`line_count`関数の数え方）を返す。
+/// 読めないファイルはNoneを返せば検査しない。
+pub fn check_ranges<F>(graph: &CodeGraph, mut line_count: F) -> Vec<IntegrityIssue>
+where
+    F: FnMut(&str) -> Option<usize>,
+{
+    let mut line_counts: HashMap<&str, Option<usize>> = HashMap::new();
+    let mut issues = Vec::new();
+
+    for symbol in graph.graph.node_weights() {
+        let count = *line_counts
+            .entry(symbol.file_path.as_str())
+            .or_insert_with(|| line_count(&symbol.file_path));
+        let count = match count {
+            Some(count) => count,
+            None => continue,
+        };
+        if symbol.range.end.line as usize >= count {
+            issues.push(IntegrityIssue::RangeOutOfBounds {
+                symbol_id: symbol.id.clone(),
+                file_path: symbol.file_path.clone(),
+                line: symbol.range.end.line,
+                line_count: count,
+            });
+        }
+    }
+    issues
+}
+
+/// 位置として有効な行数（改行の数+1。末尾の改行の後ろの空行も数える）
+pub fn line_count(content: &str) -> usize {
+    content.matches('\n').count() + 1
+}
+
+/// 構造の問題を直し、直した数を返す
+///
+/// 壊れた索引を除き、索引にないノードは同じIDが索引にあれば重複として削除、
+/// なければ索引に加える。
+pub fn repair_graph(graph: &mut CodeGraph) -> usize {
+    let before = graph.symbol_index.len();
+    let nodes = &graph.graph;
+    graph.symbol_index.retain(|id, idx| {
+        nodes
+            .node_weight(*idx)
+            .is_some_and(|symbol| symbol.id == *id)
+    });
+    let mut fixed = before - graph.symbol_index.len();
+
+    let unindexed: Vec<NodeIndex> = graph
+        .graph
+        .node_indices()
+        .filter(|&idx| graph.symbol_index.get(&graph.graph[idx].id) != Some(&idx))
+        .collect();
+    for idx in unindexed {
+        let id = graph.graph[idx].id.clone();
+        if graph.symbol_index.contains_key(&id) {
+            graph.graph.remove_node(idx);
+        } else {
+            graph.symbol_index.insert(id, idx);
+        }
+        fixed += 1;
+    }
+    fixed
+}
+
+fn duplicate_ids<'a>(ids: impl Iterator<Item = &'a str>) -> Vec<IntegrityIssue> {
+    let mut counts: BTreeMap<&str, usize> = BTreeMap::new();
+    for id in ids {
+        *counts.entry(id).or_default() += 1;
+    }
+    counts
+        .into_iter()
+        .filter(|&(_, count)| count > 1)
+        .map(|(id, count)| IntegrityIssue::DuplicateId {
+            symbol_id: id.to_string(),
+            count,
+        })
+        .collect()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::edge::Edge;
+    use crate::graph::{Position, Range, Symbol, SymbolKind};
+    use crate::graph_serde::SerializedEdge;
+
+    fn symbol(id: &str, file: &str, line: u32) -> Symbol {
+        Symbol {
+            id: id.to_string(),
+            kind: SymbolKind::Function,
+            name: id.to_string(),
+            file_path: file.to_string(),
+            range: Range {
+                start: Position { line, character: 0 },
+                end: Position { line, character: 5 },
+            },
+            documentation: None,
+            detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
+        }
+    }
+
+    #[test]
+    fn test_check_and_repair_graph() {
+        let mut graph = CodeGraph::new();
+        let a = graph.add_symbol(symbol("a", "a.rs", 0));
+        graph.add_symbol(symbol("b", "a.rs", 1));
+        // 同じIDの2つ目のノードは索引から外れる
+        graph.add_symbol(symbol("b", "a.rs", 1));
+        graph.graph.remove_node(a);
+
+        let issues = check_graph(&graph);
+        assert_eq!(
+            issues,
+            vec![
+                IntegrityIssue::DanglingIndexEntry {
+                    symbol_id: "a".to_string()
+                },
+                IntegrityIssue::DuplicateId {
+                    symbol_id: "b".to_string(),
+                    count: 2
+                },
+            ]
+        );
+
+        assert_eq!(repair_graph(&mut graph), 2);
+        assert!(check_graph(&graph).is_empty());
+        assert_eq!(graph.graph.node_count(), 1);
+        assert!(graph.find_symbol("b").is_some());
+    }
+
+    #[test]
+    fn test_check_serialized() {
+        let serialized = SerializedCodeGraph {
+            symbols: vec![symbol("a", "a.rs", 0), symbol("a", "a.rs", 0)],
+            edges: vec![SerializedEdge {
+                from_id: "a".to_string(),
+                to_id: "gone".to_string(),
+                edge: Edge::new(EdgeKind::Reference),
+            }],
+        };
+
+        let issues = check_serialized(&serialized);
+        assert_eq!(issues.len(), 2);
+        assert!(issues.contains(&IntegrityIssue::DanglingEdge {
+            from_id: "a".to_string(),
+            to_id: "gone".to_string(),
+            kind: EdgeKind::Reference,
+        }));
+        assert_eq!(serialized.into_graph().graph.edge_count(), 0);
+    }
+
+    #[test]
+    fn test_check_ranges() {
+        let mut graph = CodeGraph::new();
+        graph.add_symbol(symbol("short", "short.rs", 3));
+        graph.add_symbol(symbol("ok", "long.rs", 3));
+        graph.add_symbol(symbol("unreadable", "missing.rs", 100));
+
+        let issues = check_ranges(&graph, |path| match path {
+            "short.rs" => Some(line_count("fn a() {}\n")),
+            "long.rs" => Some(line_count("\n\n\nfn ok() {}")),
+            _ => None,
+        });
+        assert_eq!(issues.len(), 1);
+        assert_eq!(issues[0].file_path(), Some("short.rs"));
+        assert_eq!(
+            issues[0].to_string(),
+            "short is on line 4 but short.rs has 2 lines"
+        );
+    }
+}
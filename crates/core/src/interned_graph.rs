@@ -1,115 +1,64 @@
-use dashmap::DashMap;
-use std::sync::Arc;
+//! 文字列をインターン化したグラフ（`GraphBackend::Interned`）
+//!
+//! IDやファイルパス・名前・ドキュメントはグローバルインターナーの4バイトのIDで持つので、
+//! 同じ文字列の多い大きなグラフでは`CodeGraph`よりメモリが少ない。
+//! 代わりにシンボルを返すたびに`Symbol`を組み立てる。エッジはシンボルの番号の隣接リストで持ち、
+//! 列挙の順序は`CodeGraph`と同じ（`GraphStore`を参照）。
+
+use petgraph::Direction;
+use std::borrow::Cow;
+use std::collections::HashMap;
 
 use crate::{
+    edge::Edge,
     graph::{CodeGraph, EdgeKind, Position, Range, Symbol, SymbolKind},
-    string_interner::{intern, InternedString, InternedSymbol},
+    graph_serde::SerializedCodeGraph,
+    graph_store::GraphStore,
+    string_interner::{intern, lookup, InternedString, InternedSymbol},
 };
 
 /// String interningを使用した最適化グラフ
+#[derive(Debug, Clone, Default)]
 pub struct InternedGraph {
-    /// Symbol ID -> InternedSymbol のマッピング
-    symbols: Arc<DashMap<InternedString, InternedSymbol>>,
-    /// エッジ情報（インターン化されたID使用）
-    edges: Arc<DashMap<(InternedString, InternedString, EdgeKind), ()>>,
+    /// 追加した順のシンボル（添字がシンボルの番号）
+    symbols: Vec<InternedSymbol>,
+    /// Symbol ID -> シンボルの番号（同じIDなら新しい方）
+    index: HashMap<InternedString, u32>,
+    /// (張った元, 張った先, エッジ)
+    edges: Vec<(u32, u32, Edge)>,
+    /// シンボルごとの出る・入るエッジの添字（張った順）
+    outgoing: Vec<Vec<u32>>,
+    incoming: Vec<Vec<u32>>,
 }
 
 impl InternedGraph {
     /// 新しいインターン化グラフを作成
     pub fn new() -> Self {
-        Self {
-            symbols: Arc::new(DashMap::new()),
-            edges: Arc::new(DashMap::new()),
-        }
-    }
-
-    /// Symbolを追加（文字列をインターン化）
-    pub fn add_symbol(&self, symbol: Symbol) -> InternedString {
-        // グローバルインターナーを使用
-        let interned_id = intern(&symbol.id);
-        let interned_symbol = InternedSymbol {
-            id: interned_id,
-            name: intern(&symbol.name),
-            kind: symbol.kind,
-            file_path: intern(&symbol.file_path),
-            range: symbol.range,
-            documentation: symbol.documentation.as_deref().map(intern),
-            detail: symbol.detail.as_deref().map(intern),
-        };
-
-        self.symbols.insert(interned_id, interned_symbol);
-        interned_id
+        Self::default()
     }
 
     /// バッチでSymbolを追加
-    pub fn add_symbols_batch(&self, symbols: Vec<Symbol>) {
+    pub fn add_symbols_batch(&mut self, symbols: Vec<Symbol>) {
+        self.symbols.reserve(symbols.len());
+        self.index.reserve(symbols.len());
         for symbol in symbols {
             self.add_symbol(symbol);
         }
     }
 
-    /// エッジを追加
-    pub fn add_edge(&self, from: &str, to: &str, kind: EdgeKind) {
-        let from_id = intern(from);
-        let to_id = intern(to);
-        self.edges.insert((from_id, to_id, kind), ());
-    }
-
-    /// Symbolを取得
-    pub fn get_symbol(&self, id: &str) -> Option<Symbol> {
-        let interned_id = intern(id);
-        self.symbols
-            .get(&interned_id)
-            .map(|entry| entry.value().to_symbol())
-    }
-
-    /// 全Symbolを取得
-    pub fn all_symbols(&self) -> Vec<Symbol> {
-        self.symbols
-            .iter()
-            .map(|entry| entry.value().to_symbol())
-            .collect()
-    }
-
-    /// Symbol数を取得
-    pub fn symbol_count(&self) -> usize {
-        self.symbols.len()
-    }
-
-    /// エッジ数を取得  
-    pub fn edge_count(&self) -> usize {
-        self.edges.len()
-    }
-
-    /// 参照を検索
+    /// 参照元のシンボルのID
     pub fn find_references(&self, symbol_id: &str) -> Vec<String> {
-        let target_id = intern(symbol_id);
-
-        self.edges
-            .iter()
-            .filter_map(|entry| {
-                let ((from, to, kind), _) = entry.pair();
-                if *to == target_id && *kind == EdgeKind::Reference {
-                    Some(from.as_str().to_string())
-                } else {
-                    None
-                }
-            })
+        self.edges_of(symbol_id, Direction::Incoming)
+            .filter(|(_, edge)| edge.kind == EdgeKind::Reference)
+            .map(|(source, _)| source.into_owned().id)
             .collect()
     }
 
-    /// 定義を検索
+    /// 定義のシンボルのID
     pub fn find_definition(&self, symbol_id: &str) -> Option<String> {
-        let source_id = intern(symbol_id);
-
-        self.edges.iter().find_map(|entry| {
-            let ((from, to, kind), _) = entry.pair();
-            if *from == source_id && *kind == EdgeKind::Definition {
-                Some(to.as_str().to_string())
-            } else {
-                None
-            }
-        })
+        self.edges_of(symbol_id, Direction::Outgoing)
+            .find(|(_, edge)| edge.kind == EdgeKind::Definition)
+            .map(|(target, _)| target.into_owned().id)
     }
 
     /// メモリ使用量の推定値を取得（バイト単位）
@@ -117,15 +66,14 @@ impl InternedGraph {
         // インターン化されたシンボルのサイズ
         let symbol_size = std::mem::size_of::<InternedSymbol>();
         let symbols_memory = self.symbols.len() * symbol_size;
+        let index_memory = self.index.len() * std::mem::size_of::<(InternedString, u32)>();
 
-        // エッジのサイズ（インターン化されたIDは小さい）
-        let edge_size = std::mem::size_of::<(InternedString, InternedString, EdgeKind)>();
+        // エッジと隣接リスト（シンボルの番号は4バイト）
+        let edge_size = std::mem::size_of::<(u32, u32, Edge)>() + 2 * std::mem::size_of::<u32>();
         let edges_memory = self.edges.len() * edge_size;
 
-        // グローバルインターナーのメモリ使用量（推定）
-        let interner_memory = 0; // グローバルインターナーの使用量は別途計測
-
-        symbols_memory + edges_memory + interner_memory
+        // グローバルインターナーのメモリ使用量は別途計測
+        symbols_memory + index_memory + edges_memory
     }
 
     /// インターナーの統計情報を取得
@@ -136,45 +84,144 @@ impl InternedGraph {
     /// 通常のCodeGraphに変換
     pub fn to_code_graph(&self) -> CodeGraph {
         let mut graph = CodeGraph::new();
-
-        // 全Symbolを追加
-        for entry in self.symbols.iter() {
-            let symbol = entry.value().to_symbol();
-            graph.add_symbol(symbol);
+        let nodes: Vec<_> = self
+            .symbols
+            .iter()
+            .map(|symbol| graph.add_symbol(symbol.to_symbol()))
+            .collect();
+        for &(from, to, edge) in &self.edges {
+            graph.add_edge_with(nodes[from as usize], nodes[to as usize], edge);
         }
+        graph
+    }
+
+    fn position(&self, id: &str) -> Option<u32> {
+        self.index.get(&lookup(id)?).copied()
+    }
 
-        // 全エッジを追加
-        for entry in self.edges.iter() {
-            let ((from, to, kind), _) = entry.pair();
-            // String IDから既存のNodeIndexを取得
-            let from_str = from.as_str();
-            let to_str = to.as_str();
-
-            if let (Some(from_symbol), Some(to_symbol)) =
-                (graph.find_symbol(from_str), graph.find_symbol(to_str))
-            {
-                if let (Some(from_idx), Some(to_idx)) = (
-                    graph.symbol_index.get(&from_symbol.id),
-                    graph.symbol_index.get(&to_symbol.id),
-                ) {
-                    graph.add_edge(*from_idx, *to_idx, *kind);
-                }
+    fn symbol_at(&self, position: u32) -> Cow<'_, Symbol> {
+        Cow::Owned(self.symbols[position as usize].to_symbol())
+    }
+
+    fn push_edge(&mut self, from: u32, to: u32, edge: Edge) {
+        let edge_index = self.edges.len() as u32;
+        self.edges.push((from, to, edge));
+        self.outgoing[from as usize].push(edge_index);
+        self.incoming[to as usize].push(edge_index);
+    }
+}
+
+impl From<&CodeGraph> for InternedGraph {
+    /// シンボルとエッジを`CodeGraph`と同じ順序で写す
+    fn from(graph: &CodeGraph) -> Self {
+        let mut interned = InternedGraph::new();
+        let mut positions = HashMap::with_capacity(graph.graph.node_count());
+        for node in graph.graph.node_indices() {
+            positions.insert(node, interned.symbols.len() as u32);
+            interned.add_symbol(graph.graph[node].clone());
+        }
+        for edge in graph.graph.edge_indices() {
+            if let Some((from, to)) = graph.graph.edge_endpoints(edge) {
+                interned.push_edge(positions[&from], positions[&to], graph.graph[edge]);
             }
         }
+        interned
+    }
+}
 
-        graph
+impl From<SerializedCodeGraph> for InternedGraph {
+    /// 保存形式のグラフから`CodeGraph`を組み立てずに作る
+    ///
+    /// `SerializedCodeGraph::into_graph`と同じく、端点のないエッジは捨て、同じIDのシンボルへの
+    /// エッジは後から追加した方に張る。
+    fn from(graph: SerializedCodeGraph) -> Self {
+        let mut interned = InternedGraph::new();
+        for symbol in graph.symbols {
+            interned.add_symbol(symbol);
+        }
+        for edge in graph.edges {
+            interned.add_edge_between(&edge.from_id, &edge.to_id, edge.edge);
+        }
+        interned
     }
 }
 
-impl Default for InternedGraph {
-    fn default() -> Self {
-        Self::new()
+impl GraphStore for InternedGraph {
+    fn add_symbol(&mut self, symbol: Symbol) {
+        let position = self.symbols.len() as u32;
+        let symbol = InternedSymbol::from_symbol(symbol);
+        self.index.insert(symbol.id, position);
+        self.symbols.push(symbol);
+        self.outgoing.push(Vec::new());
+        self.incoming.push(Vec::new());
+    }
+
+    fn add_edge_between(&mut self, from: &str, to: &str, edge: Edge) -> bool {
+        match (self.position(from), self.position(to)) {
+            (Some(from), Some(to)) => {
+                self.push_edge(from, to, edge);
+                true
+            }
+            _ => false,
+        }
+    }
+
+    fn get_symbol(&self, id: &str) -> Option<Cow<'_, Symbol>> {
+        self.position(id).map(|position| self.symbol_at(position))
+    }
+
+    fn symbol_count(&self) -> usize {
+        self.index.len()
+    }
+
+    fn edge_count(&self) -> usize {
+        self.edges.len()
+    }
+
+    fn symbols(&self) -> Box<dyn Iterator<Item = Cow<'_, Symbol>> + '_> {
+        Box::new(
+            self.symbols
+                .iter()
+                .map(|symbol| Cow::Owned(symbol.to_symbol())),
+        )
+    }
+
+    fn edges(&self) -> Box<dyn Iterator<Item = (Cow<'_, Symbol>, Cow<'_, Symbol>, Edge)> + '_> {
+        Box::new(
+            self.edges
+                .iter()
+                .map(|&(from, to, edge)| (self.symbol_at(from), self.symbol_at(to), edge)),
+        )
+    }
+
+    fn edges_of(
+        &self,
+        id: &str,
+        direction: Direction,
+    ) -> Box<dyn Iterator<Item = (Cow<'_, Symbol>, Edge)> + '_> {
+        let position = match self.position(id) {
+            Some(position) => position as usize,
+            None => return Box::new(std::iter::empty()),
+        };
+        let adjacency = match direction {
+            Direction::Outgoing => &self.outgoing[position],
+            Direction::Incoming => &self.incoming[position],
+        };
+        // CodeGraph（petgraph）と同じく新しいエッジから
+        Box::new(adjacency.iter().rev().map(move |&edge_index| {
+            let (from, to, edge) = self.edges[edge_index as usize];
+            let other = match direction {
+                Direction::Outgoing => to,
+                Direction::Incoming => from,
+            };
+            (self.symbol_at(other), edge)
+        }))
     }
 }
 
 /// ベンチマーク用のヘルパー関数
 pub fn create_test_graph_interned(num_symbols: usize) -> InternedGraph {
-    let graph = InternedGraph::new();
+    let mut graph = InternedGraph::new();
 
     let symbols: Vec<Symbol> = (0..num_symbols)
         .map(|i| Symbol {
@@ -202,17 +249,19 @@ pub fn create_test_graph_interned(num_symbols: usize) -> InternedGraph {
                 None
             },
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         })
         .collect();
 
     graph.add_symbols_batch(symbols);
 
     // エッジを追加（各シンボルから次のシンボルへの参照）
-    for i in 0..num_symbols - 1 {
-        graph.add_edge(
+    for i in 0..num_symbols.saturating_sub(1) {
+        graph.add_edge_between(
             &format!("symbol_{}", i),
             &format!("symbol_{}", i + 1),
-            EdgeKind::Reference,
+            Edge::new(EdgeKind::Reference),
         );
     }
 
@@ -225,7 +274,7 @@ mod tests {
 
     #[test]
     fn test_interned_graph_basic() {
-        let graph = InternedGraph::new();
+        let mut graph = InternedGraph::new();
 
         let symbol = Symbol {
             id: "test_id".to_string(),
@@ -244,10 +293,11 @@ mod tests {
             },
             documentation: None,
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         };
 
-        let id = graph.add_symbol(symbol.clone());
-        assert_eq!(id.as_str(), "test_id");
+        graph.add_symbol(symbol.clone());
 
         let retrieved = graph.get_symbol("test_id").unwrap();
         assert_eq!(retrieved.name, "test_func");
@@ -257,7 +307,7 @@ mod tests {
 
     #[test]
     fn test_interned_strings_deduplication() {
-        let graph = InternedGraph::new();
+        let mut graph = InternedGraph::new();
 
         // 同じファイルパスを持つ複数のシンボル
         for i in 0..10 {
@@ -278,6 +328,8 @@ mod tests {
                 },
                 documentation: Some("Shared documentation".to_string()), // 同じドキュメント
                 detail: None,
+                modifiers: Default::default(),
+                qualified_name: None,
             };
             graph.add_symbol(symbol);
         }
@@ -298,7 +350,7 @@ mod tests {
 
     #[test]
     fn test_edges_and_references() {
-        let graph = InternedGraph::new();
+        let mut graph = InternedGraph::new();
 
         for i in 0..3 {
             let symbol = Symbol {
@@ -318,13 +370,20 @@ mod tests {
                 },
                 documentation: None,
                 detail: None,
+                modifiers: Default::default(),
+                qualified_name: None,
             };
             graph.add_symbol(symbol);
         }
 
-        graph.add_edge("symbol_0", "symbol_1", EdgeKind::Reference);
-        graph.add_edge("symbol_2", "symbol_1", EdgeKind::Reference);
-        graph.add_edge("symbol_1", "symbol_0", EdgeKind::Definition);
+        for (from, to, kind) in [
+            ("symbol_0", "symbol_1", EdgeKind::Reference),
+            ("symbol_2", "symbol_1", EdgeKind::Reference),
+            ("symbol_1", "symbol_0", EdgeKind::Definition),
+        ] {
+            assert!(graph.add_edge_between(from, to, Edge::new(kind)));
+        }
+        assert!(!graph.add_edge_between("symbol_0", "missing", Edge::new(EdgeKind::Reference)));
 
         let refs = graph.find_references("symbol_1");
         assert_eq!(refs.len(), 2);
@@ -337,7 +396,7 @@ mod tests {
 
     #[test]
     fn test_conversion_to_code_graph() {
-        let interned_graph = InternedGraph::new();
+        let mut interned_graph = InternedGraph::new();
 
         for i in 0..10 {
             let symbol = Symbol {
@@ -357,22 +416,29 @@ mod tests {
                 },
                 documentation: None,
                 detail: None,
+                modifiers: Default::default(),
+                qualified_name: None,
             };
             interned_graph.add_symbol(symbol);
         }
 
-        interned_graph.add_edge("id_0", "id_1", EdgeKind::Reference);
+        interned_graph.add_edge_between("id_0", "id_1", Edge::new(EdgeKind::Reference));
 
         let code_graph = interned_graph.to_code_graph();
         assert_eq!(code_graph.symbol_count(), 10);
 
         let refs = code_graph.find_references("id_1");
         assert_eq!(refs.unwrap().len(), 1);
+
+        // 戻しても同じ
+        let round_trip = InternedGraph::from(&code_graph);
+        assert_eq!(round_trip.symbol_count(), 10);
+        assert_eq!(round_trip.find_references("id_1"), vec!["id_0".to_string()]);
     }
 
     #[test]
     fn test_large_scale_memory_efficiency() {
-        let graph = InternedGraph::new();
+        let mut graph = InternedGraph::new();
 
         // 1000個のシンボル、100個のユニークなファイルパス
         for i in 0..1000 {
@@ -393,6 +459,8 @@ mod tests {
                 },
                 documentation: Some(format!("Doc type {}", i % 10)), // 10個のユニークなドキュメント
                 detail: None,
+                modifiers: Default::default(),
+                qualified_name: None,
             };
             graph.add_symbol(symbol);
         }
@@ -3,42 +3,55 @@
 //! This crate provides the core logic for code indexing and graph operations.
 
 pub mod call_hierarchy;
+pub mod compact_graph;
 pub mod complexity;
 pub mod definition_chain;
+pub mod documentation;
+pub mod edge;
 pub mod fuzzy_search;
 pub mod graph;
 pub mod graph_builder;
 pub mod graph_query;
 pub mod graph_serde;
+pub mod graph_store;
 pub mod incremental;
+pub mod integrity;
+pub mod interned_graph;
 pub mod lsif;
+pub mod modifiers;
 pub mod parallel;
+pub mod position_encoding;
 pub mod public_api;
+pub mod qualified_name;
+pub mod string_interner;
 pub mod test_fixtures;
 pub mod type_relations;
 
-// パフォーマンス検証用（本番では標準実装を使用）
-#[cfg(feature = "experimental-optimizations")]
-pub mod interned_graph;
+// パフォーマンス検証用（本番では`GraphBackend`で選んだ実装を使用）
 #[cfg(feature = "experimental-optimizations")]
 pub mod memory_pool;
 #[cfg(feature = "experimental-optimizations")]
 pub mod optimized_graph;
-#[cfg(feature = "experimental-optimizations")]
-pub mod string_interner;
 
 // Re-export main types
 pub use call_hierarchy::CallHierarchy;
 pub use complexity::{ComplexityAnalyzer, ComplexityMetrics};
+pub use documentation::{suggested_replacement, DocComment};
 pub use fuzzy_search::{FuzzySearchIndex, MatchType, SearchResult};
-pub use graph::{CodeGraph, EdgeKind, Position, Range, Symbol, SymbolKind};
+pub use edge::{Edge, EdgeRoles, EdgeSource, ReferenceAccess};
+pub use graph::{CodeGraph, EdgeKind, Position, Range, RowUpdateStats, Symbol, SymbolKind};
 pub use graph_builder::GraphBuilder;
+pub use graph_store::{GraphBackend, GraphStore};
 pub use graph_query::{
     NodePattern, PropertyFilter, QueryPattern, QueryResult, RelationshipPattern,
 };
 pub use incremental::IncrementalIndex;
+pub use integrity::IntegrityIssue;
 pub use lsif::LsifGenerator;
+pub use modifiers::SymbolModifiers;
+pub use position_encoding::{convert_column, LineIndex, PositionEncoding};
 pub use public_api::{ApiInfo, PublicApiAnalyzer, Visibility};
+pub use qualified_name::{qualify_symbols, split_qualified_name};
 pub use type_relations::TypeRelations;
 
 // Utility functions
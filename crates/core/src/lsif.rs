@@ -1,4 +1,6 @@
-use super::graph::{CodeGraph, Position, Range, Symbol, SymbolKind};
+use super::graph::{CodeGraph, EdgeKind, Position, Range, Symbol, SymbolKind};
+use super::graph_store::GraphStore;
+use super::position_encoding::{convert_column, PositionEncoding};
 use anyhow::Result;
 use serde::{Deserialize, Serialize};
 use serde_json::{json, Value};
@@ -62,20 +64,26 @@ pub mod labels {
     pub const TEXTDOCUMENT_HOVER: &str = "textDocument/hover";
 }
 
-// LSIF Generator - generates LSIF from CodeGraph
-pub struct LsifGenerator {
-    graph: CodeGraph,
+// LSIF Generator - generates LSIF from any graph backend
+pub struct LsifGenerator<G: GraphStore = CodeGraph> {
+    graph: G,
     id_counter: usize,
     vertex_ids: HashMap<String, String>, // Symbol ID -> LSIF vertex ID
+    result_set_ids: HashMap<String, String>, // Symbol ID -> result set ID
+    documents: HashMap<String, String>,  // file_path -> document ID
+    source_lines: SourceLines,
     elements: Vec<LsifElement>,
 }
 
-impl LsifGenerator {
-    pub fn new(graph: CodeGraph) -> Self {
+impl<G: GraphStore> LsifGenerator<G> {
+    pub fn new(graph: G) -> Self {
         Self {
             graph,
             id_counter: 0,
             vertex_ids: HashMap::new(),
+            result_set_ids: HashMap::new(),
+            documents: HashMap::new(),
+            source_lines: SourceLines::default(),
             elements: Vec::new(),
         }
     }
@@ -93,20 +101,14 @@ impl LsifGenerator {
         let project_id = self.generate_project()?;
 
         // 3. Collect all symbols first to avoid borrowing issues
-        let mut all_symbols = Vec::new();
-        for symbol_id in self.graph.symbol_index.keys() {
-            if let Some(symbol) = self.graph.find_symbol(symbol_id) {
-                all_symbols.push(symbol.clone());
-            }
-        }
+        let all_symbols: Vec<Symbol> = self.graph.symbols().map(|s| s.into_owned()).collect();
 
         // 4. Generate documents and their contents
-        let mut documents: HashMap<String, String> = HashMap::new(); // file_path -> document_id
-
         for symbol in &all_symbols {
-            if !documents.contains_key(&symbol.file_path) {
+            if !self.documents.contains_key(&symbol.file_path) {
                 let doc_id = self.generate_document(&symbol.file_path)?;
-                documents.insert(symbol.file_path.clone(), doc_id.clone());
+                self.documents
+                    .insert(symbol.file_path.clone(), doc_id.clone());
 
                 // Link document to project
                 self.generate_contains_edge(&project_id, &doc_id)?;
@@ -115,8 +117,10 @@ impl LsifGenerator {
 
         // 5. Generate ranges and symbols
         for symbol in &all_symbols {
-            let doc_id = documents
+            let doc_id = self
+                .documents
                 .get(&symbol.file_path)
+                .cloned()
                 .ok_or_else(|| anyhow::anyhow!("Document not found"))?;
 
             // Generate range for symbol
@@ -124,11 +128,13 @@ impl LsifGenerator {
             self.vertex_ids.insert(symbol.id.clone(), range_id.clone());
 
             // Link range to document
-            self.generate_contains_edge(doc_id, &range_id)?;
+            self.generate_contains_edge(&doc_id, &range_id)?;
 
             // Generate result set for the range
             let result_set_id = self.generate_result_set()?;
             self.generate_next_edge(&range_id, &result_set_id)?;
+            self.result_set_ids
+                .insert(symbol.id.clone(), result_set_id.clone());
 
             // Generate hover result if documentation exists
             if let Some(doc) = &symbol.documentation {
@@ -208,20 +214,31 @@ impl LsifGenerator {
     }
 
     fn generate_range(&mut self, symbol: &Symbol) -> Result<String> {
+        self.generate_range_vertex(&symbol.file_path, &symbol.range)
+    }
+
+    fn generate_range_vertex(&mut self, file_path: &str, range: &Range) -> Result<String> {
+        // The graph stores UTF-8 byte columns; LSIF declares utf-16 in its metadata
+        let range = self.source_lines.convert_range(
+            file_path,
+            *range,
+            PositionEncoding::CANONICAL,
+            PositionEncoding::Utf16,
+        );
         let id = self.next_id();
         let mut data = HashMap::new();
         data.insert(
             "start".to_string(),
             json!({
-                "line": symbol.range.start.line,
-                "character": symbol.range.start.character
+                "line": range.start.line,
+                "character": range.start.character
             }),
         );
         data.insert(
             "end".to_string(),
             json!({
-                "line": symbol.range.end.line,
-                "character": symbol.range.end.character
+                "line": range.end.line,
+                "character": range.end.character
             }),
         );
 
@@ -316,19 +333,125 @@ impl LsifGenerator {
         Ok(())
     }
 
+    fn generate_item_edge(&mut self, from: &str, to: &str, document: &str) -> Result<()> {
+        let id = self.next_id();
+        let mut data = HashMap::new();
+        data.insert("document".to_string(), json!(document));
+        data.insert("property".to_string(), json!("references"));
+
+        let edge = Edge {
+            id,
+            element_type: "edge".to_string(),
+            label: labels::ITEM.to_string(),
+            out_v: from.to_string(),
+            in_v: to.to_string(),
+            data,
+        };
+
+        self.elements.push(LsifElement::Edge(edge));
+        Ok(())
+    }
+
     fn generate_reference_edges(&mut self) -> Result<()> {
-        // This would generate definition and reference edges based on the graph relationships
-        // For now, we'll keep it simple
+        // Target symbol ID -> (document ID, file path, occurrence range) of each reference
+        let mut occurrences: Vec<(String, Vec<(String, String, Range)>)> = Vec::new();
+        for (source, target, edge) in self.graph.edges() {
+            if edge.kind != EdgeKind::Reference {
+                continue;
+            }
+            let doc_id = match self.documents.get(&source.file_path) {
+                Some(doc_id) => doc_id.clone(),
+                None => continue,
+            };
+            // Older edges carry no call site; fall back to the referencing symbol
+            let range = edge.range.unwrap_or(source.range);
+            let occurrence = (doc_id, source.file_path.clone(), range);
+            match occurrences.iter_mut().find(|(id, _)| *id == target.id) {
+                Some((_, ranges)) => ranges.push(occurrence),
+                None => occurrences.push((target.id.clone(), vec![occurrence])),
+            }
+        }
+
+        for (target_id, ranges) in occurrences {
+            let result_set_id = match self.result_set_ids.get(&target_id) {
+                Some(id) => id.clone(),
+                None => continue,
+            };
+
+            let reference_result_id = self.next_id();
+            self.elements.push(LsifElement::Vertex(Vertex {
+                id: reference_result_id.clone(),
+                element_type: "vertex".to_string(),
+                label: labels::REFERENCE_RESULT.to_string(),
+                data: HashMap::new(),
+            }));
+
+            let edge_id = self.next_id();
+            self.elements.push(LsifElement::Edge(Edge {
+                id: edge_id,
+                element_type: "edge".to_string(),
+                label: labels::TEXTDOCUMENT_REFERENCES.to_string(),
+                out_v: result_set_id.clone(),
+                in_v: reference_result_id.clone(),
+                data: HashMap::new(),
+            }));
+
+            for (doc_id, file_path, range) in ranges {
+                let range_id = self.generate_range_vertex(&file_path, &range)?;
+                self.generate_contains_edge(&doc_id, &range_id)?;
+                self.generate_next_edge(&range_id, &result_set_id)?;
+                self.generate_item_edge(&reference_result_id, &range_id, &doc_id)?;
+            }
+        }
         Ok(())
     }
 }
 
+// Lines of the indexed files, read once per file to convert columns between encodings
+#[derive(Default)]
+struct SourceLines {
+    files: HashMap<String, Option<Vec<String>>>,
+}
+
+impl SourceLines {
+    // Files that can't be read keep their columns (identical for ASCII lines)
+    fn convert_range(
+        &mut self,
+        file_path: &str,
+        range: Range,
+        from: PositionEncoding,
+        to: PositionEncoding,
+    ) -> Range {
+        let lines = self.files.entry(file_path.to_string()).or_insert_with(|| {
+            std::fs::read_to_string(file_path)
+                .ok()
+                .map(|text| text.split('\n').map(str::to_string).collect())
+        });
+        let lines = match lines {
+            Some(lines) => lines,
+            None => return range,
+        };
+        let convert = |position: Position| Position {
+            line: position.line,
+            character: lines
+                .get(position.line as usize)
+                .map(|line| convert_column(line, position.character, from, to))
+                .unwrap_or(position.character),
+        };
+        Range {
+            start: convert(range.start),
+            end: convert(range.end),
+        }
+    }
+}
+
 // LSIF Parser - parses LSIF format into CodeGraph
 pub struct LsifParser {
     graph: CodeGraph,
     documents: HashMap<String, String>,   // vertex_id -> uri
     ranges: HashMap<String, LsifRange>,   // vertex_id -> range
     result_sets: HashMap<String, String>, // range_id -> result_set_id
+    source_lines: SourceLines,
 }
 
 #[derive(Debug, Clone)]
@@ -351,6 +474,7 @@ impl LsifParser {
             documents: HashMap::new(),
             ranges: HashMap::new(),
             result_sets: HashMap::new(),
+            source_lines: SourceLines::default(),
         }
     }
 
@@ -427,17 +551,27 @@ impl LsifParser {
                     // Create symbol from range
                     if let Some(range) = self.ranges.get(out_v) {
                         if let Some(doc_uri) = self.documents.get(&range.document_id) {
+                            // Back from the utf-16 columns of LSIF to the graph's UTF-8 bytes
+                            let path = doc_uri.strip_prefix("file://").unwrap_or(doc_uri);
+                            let range = self.source_lines.convert_range(
+                                path,
+                                Range {
+                                    start: range.start,
+                                    end: range.end,
+                                },
+                                PositionEncoding::Utf16,
+                                PositionEncoding::CANONICAL,
+                            );
                             let symbol = Symbol {
                                 id: out_v.to_string(),
                                 kind: SymbolKind::Function,
                                 name: format!("symbol_{out_v}"),
                                 file_path: doc_uri.clone(),
-                                range: Range {
-                                    start: range.start,
-                                    end: range.end,
-                                },
+                                range,
                                 documentation: None,
                                 detail: None,
+                                modifiers: Default::default(),
+                                qualified_name: None,
                             };
                             self.graph.add_symbol(symbol);
                         }
@@ -462,7 +596,7 @@ impl LsifParser {
 }
 
 // Public API
-pub fn generate_lsif(graph: CodeGraph) -> Result<String> {
+pub fn generate_lsif<G: GraphStore>(graph: G) -> Result<String> {
     let mut generator = LsifGenerator::new(graph);
     generator.generate()
 }
@@ -473,7 +607,7 @@ pub fn parse_lsif(content: &str) -> Result<CodeGraph> {
     Ok(parser.into_graph())
 }
 
-pub fn write_lsif<W: Write>(writer: &mut W, graph: CodeGraph) -> Result<()> {
+pub fn write_lsif<W: Write, G: GraphStore>(writer: &mut W, graph: G) -> Result<()> {
     let lsif_content = generate_lsif(graph)?;
     writer.write_all(lsif_content.as_bytes())?;
     Ok(())
@@ -482,6 +616,7 @@ pub fn write_lsif<W: Write>(writer: &mut W, graph: CodeGraph) -> Result<()> {
 #[cfg(test)]
 mod tests {
     use super::*;
+    use crate::graph_store::GraphBackend;
 
     fn create_test_graph() -> CodeGraph {
         let mut graph = CodeGraph::new();
@@ -503,6 +638,8 @@ mod tests {
             },
             documentation: Some("Main function".to_string()),
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         };
 
         let symbol2 = Symbol {
@@ -522,6 +659,8 @@ mod tests {
             },
             documentation: None,
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         };
 
         let idx1 = graph.add_symbol(symbol1);
@@ -653,6 +792,8 @@ mod tests {
             },
             documentation: None,
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         };
 
         let range_id = generator.generate_range(&symbol).unwrap();
@@ -669,6 +810,55 @@ mod tests {
         }
     }
 
+    #[test]
+    fn test_ranges_are_exported_in_utf16() {
+        let temp_dir = tempfile::TempDir::new().unwrap();
+        let path = temp_dir.path().join("main.rs");
+        // "é" is 2 UTF-8 bytes but 1 UTF-16 unit, "𝕏" is 4 bytes but 2 units
+        std::fs::write(&path, "fn main() {\n    let é = \"𝕏\"; run();\n}\n").unwrap();
+        let file_path = path.to_string_lossy().to_string();
+        let byte_range = Range {
+            start: Position {
+                line: 1,
+                character: 21,
+            },
+            end: Position {
+                line: 1,
+                character: 24,
+            },
+        };
+
+        let mut generator = LsifGenerator::new(CodeGraph::new());
+        generator
+            .generate_range_vertex(&file_path, &byte_range)
+            .unwrap();
+        let vertex = match &generator.elements[0] {
+            LsifElement::Vertex(vertex) => vertex,
+            _ => panic!("Expected vertex"),
+        };
+        assert_eq!(vertex.data["start"]["character"], 18);
+        assert_eq!(vertex.data["end"]["character"], 21);
+
+        // Parsing converts back to UTF-8 byte columns
+        let mut source_lines = SourceLines::default();
+        let parsed = source_lines.convert_range(
+            &file_path,
+            Range {
+                start: Position {
+                    line: 1,
+                    character: 18,
+                },
+                end: Position {
+                    line: 1,
+                    character: 21,
+                },
+            },
+            PositionEncoding::Utf16,
+            PositionEncoding::CANONICAL,
+        );
+        assert_eq!(parsed, byte_range);
+    }
+
     #[test]
     fn test_generate_contains_edge() {
         let graph = CodeGraph::new();
@@ -719,6 +909,68 @@ mod tests {
         }
     }
 
+    #[test]
+    fn test_generate_reference_edges_uses_call_site() {
+        for backend in GraphBackend::ALL {
+            check_reference_edges_use_call_site(backend);
+        }
+    }
+
+    fn check_reference_edges_use_call_site(backend: GraphBackend) {
+        let mut graph = create_test_graph();
+        let call_site = Range {
+            start: Position {
+                line: 2,
+                character: 4,
+            },
+            end: Position {
+                line: 2,
+                character: 10,
+            },
+        };
+        let main_idx = graph.get_node_index("symbol1").unwrap();
+        let helper_idx = graph.get_node_index("symbol2").unwrap();
+        graph.add_edge_with(
+            main_idx,
+            helper_idx,
+            crate::edge::Edge::reference(crate::edge::EdgeSource::Lsp, None).with_range(call_site),
+        );
+
+        let mut generator = LsifGenerator::new(backend.load(graph));
+        generator.generate().unwrap();
+
+        let reference_results = generator
+            .elements
+            .iter()
+            .filter(|e| matches!(e, LsifElement::Vertex(v) if v.label == labels::REFERENCE_RESULT))
+            .count();
+        assert_eq!(reference_results, 1);
+
+        let items: Vec<&Edge> = generator
+            .elements
+            .iter()
+            .filter_map(|e| match e {
+                LsifElement::Edge(edge) if edge.label == labels::ITEM => Some(edge),
+                _ => None,
+            })
+            .collect();
+        assert_eq!(items.len(), 2);
+
+        // The edge without a call site falls back to the range of main,
+        // the other one points at the call itself
+        let starts: Vec<Value> = items
+            .iter()
+            .filter_map(|item| {
+                generator.elements.iter().find_map(|e| match e {
+                    LsifElement::Vertex(v) if v.id == item.in_v => v.data.get("start").cloned(),
+                    _ => None,
+                })
+            })
+            .collect();
+        assert!(starts.contains(&json!({"line": 0, "character": 0})));
+        assert!(starts.contains(&json!({"line": 2, "character": 4})));
+    }
+
     #[test]
     #[ignore] // TODO: fix test - graph structure changed
     fn test_full_lsif_generation() {
@@ -855,31 +1107,35 @@ mod tests {
 
     #[test]
     fn test_write_lsif() {
-        let graph = create_test_graph();
-        let mut buffer = Vec::new();
+        for backend in GraphBackend::ALL {
+            let graph = backend.load(create_test_graph());
+            let mut buffer = Vec::new();
 
-        write_lsif(&mut buffer, graph).unwrap();
+            write_lsif(&mut buffer, graph).unwrap();
 
-        let content = String::from_utf8(buffer).unwrap();
-        assert!(content.contains("metaData"));
-        assert!(content.contains("project"));
+            let content = String::from_utf8(buffer).unwrap();
+            assert!(content.contains("metaData"));
+            assert!(content.contains("project"));
 
-        // Verify each line is valid JSON
-        for line in content.lines() {
-            if !line.trim().is_empty() {
-                serde_json::from_str::<Value>(line).unwrap();
+            // Verify each line is valid JSON
+            for line in content.lines() {
+                if !line.trim().is_empty() {
+                    serde_json::from_str::<Value>(line).unwrap();
+                }
             }
         }
     }
 
     #[test]
     fn test_empty_graph_generation() {
-        let graph = CodeGraph::new();
-        let lsif = generate_lsif(graph).unwrap();
+        for backend in GraphBackend::ALL {
+            let graph = backend.load(CodeGraph::new());
+            let lsif = generate_lsif(graph).unwrap();
 
-        // Should still have metadata and project
-        assert!(lsif.contains("metaData"));
-        assert!(lsif.contains("project"));
+            // Should still have metadata and project
+            assert!(lsif.contains("metaData"));
+            assert!(lsif.contains("project"));
+        }
     }
 
     #[test]
@@ -78,6 +78,8 @@ impl SymbolPool {
                 symbol.file_path = file_path;
                 symbol.range = range;
                 symbol.documentation = documentation;
+                symbol.modifiers = Default::default();
+                symbol.qualified_name = None;
 
                 stats.reuses += 1;
                 stats.pool_hits += 1;
@@ -99,6 +101,8 @@ impl SymbolPool {
                 range,
                 documentation,
                 detail: None,
+                modifiers: Default::default(),
+                qualified_name: None,
             }),
         }
     }
@@ -0,0 +1,380 @@
+//! シンボルの修飾子（可視性、async、staticなど）
+//!
+//! 抽出時（LSPの`SymbolTag`、tree-sitter、正規表現のフォールバック）に判定して
+//! `Symbol.modifiers`に保存する。bincodeでは数値、JSONでは名前の配列になる。
+
+use crate::graph::SymbolKind;
+use crate::public_api::Visibility;
+use serde::de::Error as _;
+use serde::{Deserialize, Deserializer, Serialize, Serializer};
+use std::fmt;
+use std::ops::{BitOr, BitOrAssign};
+use std::path::Path;
+
+/// 修飾子の集合
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq, Hash)]
+pub struct SymbolModifiers(u16);
+
+impl SymbolModifiers {
+    pub const NONE: Self = Self(0);
+    /// `pub`、`public`、`export`された定義
+    pub const PUBLIC: Self = Self(1 << 0);
+    pub const PROTECTED: Self = Self(1 << 1);
+    pub const PRIVATE: Self = Self(1 << 2);
+    /// `pub(crate)`などパッケージ内だけで見える
+    pub const INTERNAL: Self = Self(1 << 3);
+    /// モジュールの外へエクスポートされている（TSの`export`、Goの大文字始まり）
+    pub const EXPORTED: Self = Self(1 << 4);
+    pub const ASYNC: Self = Self(1 << 5);
+    pub const STATIC: Self = Self(1 << 6);
+    pub const ABSTRACT: Self = Self(1 << 7);
+    pub const TEST: Self = Self(1 << 8);
+    /// 生成されたコード（`Code generated ... DO NOT EDIT.`など）
+    pub const GENERATED: Self = Self(1 << 9);
+    pub const DEPRECATED: Self = Self(1 << 10);
+
+    const NAMES: [(Self, &'static str); 11] = [
+        (Self::PUBLIC, "public"),
+        (Self::PROTECTED, "protected"),
+        (Self::PRIVATE, "private"),
+        (Self::INTERNAL, "internal"),
+        (Self::EXPORTED, "exported"),
+        (Self::ASYNC, "async"),
+        (Self::STATIC, "static"),
+        (Self::ABSTRACT, "abstract"),
+        (Self::TEST, "test"),
+        (Self::GENERATED, "generated"),
+        (Self::DEPRECATED, "deprecated"),
+    ];
+
+    pub fn bits(self) -> u16 {
+        self.0
+    }
+
+    pub fn from_bits(bits: u16) -> Self {
+        Self(bits)
+    }
+
+    pub fn is_empty(self) -> bool {
+        self.0 == 0
+    }
+
+    pub fn contains(self, other: Self) -> bool {
+        self.0 & other.0 == other.0
+    }
+
+    pub fn insert(&mut self, other: Self) {
+        self.0 |= other.0;
+    }
+
+    pub fn remove(&mut self, other: Self) {
+        self.0 &= !other.0;
+    }
+
+    /// 含まれる修飾子の名前
+    pub fn names(self) -> impl Iterator<Item = &'static str> {
+        Self::NAMES
+            .into_iter()
+            .filter(move |(flag, _)| self.contains(*flag))
+            .map(|(_, name)| name)
+    }
+
+    /// 名前から修飾子を取得（`pub`や`export`などの別名も受け付ける）
+    pub fn from_name(name: &str) -> Option<Self> {
+        let name = name.trim().to_ascii_lowercase();
+        let alias = match name.as_str() {
+            "pub" => "public",
+            "export" => "exported",
+            "crate" => "internal",
+            "generated_code" => "generated",
+            "tests" => "test",
+            other => other,
+        };
+        Self::NAMES
+            .into_iter()
+            .find(|(_, n)| *n == alias)
+            .map(|(flag, _)| flag)
+    }
+
+    /// カンマ区切りの名前を解析（`async,public`）
+    pub fn parse_list(list: &str) -> Result<Self, String> {
+        list.split(',')
+            .filter(|name| !name.trim().is_empty())
+            .try_fold(Self::NONE, |acc, name| match Self::from_name(name) {
+                Some(flag) => Ok(acc | flag),
+                None => Err(format!(
+                    "Unknown modifier: {}. Valid modifiers: {}",
+                    name.trim(),
+                    Self::NAMES.map(|(_, n)| n).join(", ")
+                )),
+            })
+    }
+
+    /// 宣言のシグネチャ（`pub async fn`、`export default class`など）から判定
+    ///
+    /// 本体や引数の中の単語を拾わないよう、名前より前のキーワードだけを見る。
+    pub fn from_signature(signature: &str) -> Self {
+        let mut modifiers = Self::NONE;
+        let mut signature = signature.trim_start();
+        // `pub(crate)`や`pub(super)`はパッケージ内だけで見える
+        if let Some(rest) = signature
+            .strip_prefix("pub(")
+            .or_else(|| signature.strip_prefix("pub ("))
+        {
+            modifiers.insert(Self::INTERNAL);
+            signature = rest.split_once(')').map_or("", |(_, rest)| rest);
+        }
+        let head = match signature.find(['(', '{', '=', ':', '<']) {
+            Some(end) => &signature[..end],
+            None => signature,
+        };
+        for word in head.split_whitespace() {
+            match word {
+                "pub" | "public" => modifiers.insert(Self::PUBLIC),
+                "protected" => modifiers.insert(Self::PROTECTED),
+                "private" => modifiers.insert(Self::PRIVATE),
+                "internal" => modifiers.insert(Self::INTERNAL),
+                "export" => modifiers.insert(Self::EXPORTED | Self::PUBLIC),
+                "async" => modifiers.insert(Self::ASYNC),
+                "static" => modifiers.insert(Self::STATIC),
+                "abstract" => modifiers.insert(Self::ABSTRACT),
+                _ => {}
+            }
+        }
+        modifiers
+    }
+
+    /// シグネチャに現れない言語ごとの規則で補う（言語は拡張子で判定）
+    ///
+    /// Goは名前の大文字・小文字、Pythonは先頭の`_`、Rustは`pub`がなければ非公開。
+    /// テストファイルの定義はテストとみなす。`parent_kind`は直接囲んでいる定義の種別で、
+    /// Rustのトレイトとimplの中の定義は外側の可視性に従う。
+    /// 修飾子を宣言のソースから読んだ時に使う。
+    pub fn apply_language_rules(
+        &mut self,
+        name: &str,
+        file_path: &str,
+        parent_kind: Option<SymbolKind>,
+    ) {
+        self.apply_rules(name, file_path, parent_kind, true);
+    }
+
+    /// 宣言のソースを見ていない時（LSPのdetailなど）に言語ごとの規則で補う
+    ///
+    /// rust-analyzerのdetail（`fn(&self) -> usize`）には`pub`が現れないので、
+    /// Rustの可視性は決めずに残す。
+    pub fn apply_language_rules_without_source(
+        &mut self,
+        name: &str,
+        file_path: &str,
+        parent_kind: Option<SymbolKind>,
+    ) {
+        self.apply_rules(name, file_path, parent_kind, false);
+    }
+
+    fn apply_rules(
+        &mut self,
+        name: &str,
+        file_path: &str,
+        parent_kind: Option<SymbolKind>,
+        from_source: bool,
+    ) {
+        if is_test_file(file_path) {
+            self.insert(Self::TEST);
+        }
+
+        let extension = Path::new(file_path)
+            .extension()
+            .and_then(|ext| ext.to_str())
+            .unwrap_or_default();
+        match extension {
+            "rs" => {
+                if from_source
+                    && self.visibility().is_none()
+                    && !matches!(parent_kind, Some(SymbolKind::Trait | SymbolKind::Class))
+                {
+                    self.insert(Self::PRIVATE);
+                }
+            }
+            "go" => {
+                // goplsのメソッド名（`(*DB).Close`）はメソッド名の部分を見る
+                let name = name.rsplit('.').next().unwrap_or(name);
+                // 大文字始まりはパッケージの外へエクスポートされる
+                if name.starts_with(char::is_uppercase) {
+                    self.insert(Self::EXPORTED | Self::PUBLIC);
+                } else {
+                    self.insert(Self::PRIVATE);
+                }
+            }
+            // `__init__`などは公開、`__x`は名前マングリングの対象、`_x`は慣例的な内部使用
+            "py" | "pyi" if name.starts_with("__") && name.ends_with("__") => {
+                self.insert(Self::PUBLIC);
+            }
+            "py" | "pyi" if name.starts_with("__") => self.insert(Self::PRIVATE),
+            "py" | "pyi" if name.starts_with('_') => self.insert(Self::PROTECTED),
+            "py" | "pyi" => self.insert(Self::PUBLIC),
+            _ => {}
+        }
+    }
+
+    /// 判定済みの可視性（可視性の修飾子がなければNone）
+    pub fn visibility(self) -> Option<Visibility> {
+        if self.contains(Self::PRIVATE) {
+            Some(Visibility::Private)
+        } else if self.contains(Self::PROTECTED) {
+            Some(Visibility::Protected)
+        } else if self.contains(Self::INTERNAL) {
+            Some(Visibility::Internal)
+        } else if self.contains(Self::PUBLIC) || self.contains(Self::EXPORTED) {
+            Some(Visibility::Public)
+        } else {
+            None
+        }
+    }
+}
+
+/// テストファイルか（`_test.go`、`test_*.py`、`*.spec.ts`、`tests/`以下など）
+fn is_test_file(file_path: &str) -> bool {
+    let path = Path::new(file_path);
+    let file_name = path
+        .file_name()
+        .and_then(|name| name.to_str())
+        .unwrap_or_default();
+    file_name.ends_with("_test.go")
+        || file_name.ends_with("_test.py")
+        || (file_name.starts_with("test_") && file_name.ends_with(".py"))
+        || file_name.contains(".test.")
+        || file_name.contains(".spec.")
+        || path
+            .parent()
+            .into_iter()
+            .flat_map(|dir| dir.components())
+            .any(|c| matches!(c.as_os_str().to_str(), Some("tests" | "__tests__")))
+}
+
+impl BitOr for SymbolModifiers {
+    type Output = Self;
+
+    fn bitor(self, rhs: Self) -> Self {
+        Self(self.0 | rhs.0)
+    }
+}
+
+impl BitOrAssign for SymbolModifiers {
+    fn bitor_assign(&mut self, rhs: Self) {
+        self.0 |= rhs.0;
+    }
+}
+
+impl fmt::Display for SymbolModifiers {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        f.write_str(&self.names().collect::<Vec<_>>().join(","))
+    }
+}
+
+impl Serialize for SymbolModifiers {
+    fn serialize<S: Serializer>(&self, serializer: S) -> Result<S::Ok, S::Error> {
+        if serializer.is_human_readable() {
+            serializer.collect_seq(self.names())
+        } else {
+            serializer.serialize_u16(self.0)
+        }
+    }
+}
+
+impl<'de> Deserialize<'de> for SymbolModifiers {
+    fn deserialize<D: Deserializer<'de>>(deserializer: D) -> Result<Self, D::Error> {
+        if deserializer.is_human_readable() {
+            let names = Vec::<String>::deserialize(deserializer)?;
+            names.iter().try_fold(Self::NONE, |acc, name| {
+                Self::from_name(name)
+                    .map(|flag| acc | flag)
+                    .ok_or_else(|| D::Error::custom(format!("unknown modifier: {}", name)))
+            })
+        } else {
+            u16::deserialize(deserializer).map(Self)
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_from_signature() {
+        let m = SymbolModifiers::from_signature("pub async fn fetch(url: &str) -> Result<()>");
+        assert_eq!(m, SymbolModifiers::PUBLIC | SymbolModifiers::ASYNC);
+
+        let m = SymbolModifiers::from_signature("pub(crate) fn helper()");
+        assert_eq!(m, SymbolModifiers::INTERNAL);
+        assert_eq!(m.visibility(), Some(Visibility::Internal));
+
+        let m = SymbolModifiers::from_signature("export abstract class Shape {");
+        assert!(m.contains(SymbolModifiers::EXPORTED | SymbolModifiers::ABSTRACT));
+
+        // 引数の中のキーワードは無視する
+        let m = SymbolModifiers::from_signature("fn run(static_files: bool, export: bool)");
+        assert!(m.is_empty());
+        assert_eq!(m.visibility(), None);
+    }
+
+    #[test]
+    fn test_parse_and_serialize() {
+        let m = SymbolModifiers::parse_list("pub, async").unwrap();
+        assert_eq!(m.to_string(), "public,async");
+        assert!(SymbolModifiers::parse_list("sync").is_err());
+
+        let json = serde_json::to_string(&m).unwrap();
+        assert_eq!(json, r#"["public","async"]"#);
+        let back: SymbolModifiers = serde_json::from_str(&json).unwrap();
+        assert_eq!(back, m);
+    }
+
+    #[test]
+    fn test_language_rules() {
+        let rules = |name: &str, file_path: &str, parent_kind| {
+            let mut modifiers = SymbolModifiers::NONE;
+            modifiers.apply_language_rules(name, file_path, parent_kind);
+            modifiers
+        };
+
+        assert_eq!(
+            rules("Open", "store/db.go", None),
+            SymbolModifiers::EXPORTED | SymbolModifiers::PUBLIC
+        );
+        assert_eq!(rules("open", "store/db.go", None), SymbolModifiers::PRIVATE);
+        assert_eq!(
+            rules("(*DB).Close", "store/db.go", None),
+            SymbolModifiers::EXPORTED | SymbolModifiers::PUBLIC
+        );
+        assert_eq!(
+            rules("TestOpen", "store/db_test.go", None),
+            SymbolModifiers::EXPORTED | SymbolModifiers::PUBLIC | SymbolModifiers::TEST
+        );
+
+        assert_eq!(rules("__init__", "app.py", None), SymbolModifiers::PUBLIC);
+        assert_eq!(rules("__secret", "app.py", None), SymbolModifiers::PRIVATE);
+        assert_eq!(rules("_helper", "app.py", None), SymbolModifiers::PROTECTED);
+        assert_eq!(rules("run", "app.py", None), SymbolModifiers::PUBLIC);
+
+        assert_eq!(rules("load", "src/lib.rs", None), SymbolModifiers::PRIVATE);
+        assert_eq!(
+            rules("load", "src/lib.rs", Some(SymbolKind::Trait)),
+            SymbolModifiers::NONE
+        );
+        let mut public = SymbolModifiers::PUBLIC;
+        public.apply_language_rules("load", "src/lib.rs", None);
+        assert_eq!(public, SymbolModifiers::PUBLIC);
+        // ソースを見ていなければ`pub`がないことから非公開とは決めない
+        let mut unknown = SymbolModifiers::NONE;
+        unknown.apply_language_rules_without_source("load", "src/lib.rs", None);
+        assert_eq!(unknown.visibility(), None);
+
+        assert_eq!(rules("render", "src/app.ts", None), SymbolModifiers::NONE);
+        assert_eq!(
+            rules("renders", "src/app.spec.ts", None),
+            SymbolModifiers::TEST
+        );
+    }
+}
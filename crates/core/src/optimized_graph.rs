@@ -198,6 +198,8 @@ pub fn create_test_graph_optimized(num_symbols: usize) -> OptimizedCodeGraph {
                 None
             },
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         })
         .collect();
 
@@ -240,6 +242,8 @@ mod tests {
             },
             documentation: None,
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         };
 
         let id = graph.add_symbol(symbol.clone());
@@ -273,6 +277,8 @@ mod tests {
                 },
                 documentation: None,
                 detail: None,
+                modifiers: Default::default(),
+                qualified_name: None,
             })
             .collect();
 
@@ -306,6 +312,8 @@ mod tests {
                 },
                 documentation: None,
                 detail: None,
+                modifiers: Default::default(),
+                qualified_name: None,
             };
             graph.add_symbol(symbol);
         }
@@ -358,6 +366,8 @@ mod tests {
                 },
                 documentation: None,
                 detail: None,
+                modifiers: Default::default(),
+                qualified_name: None,
             })
             .collect();
 
@@ -396,6 +406,8 @@ mod tests {
                 },
                 documentation: None,
                 detail: None,
+                modifiers: Default::default(),
+                qualified_name: None,
             };
             opt_graph.add_symbol(symbol);
         }
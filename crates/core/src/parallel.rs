@@ -179,7 +179,10 @@ impl ParallelIncrementalIndex {
         while let Some(symbol_id) = to_visit.pop() {
             if let Some(node_idx) = graph.get_node_index(&symbol_id) {
                 for edge in graph.graph.edges(node_idx) {
-                    if matches!(edge.weight(), EdgeKind::Reference | EdgeKind::Definition) {
+                    if matches!(
+                        edge.weight().kind,
+                        EdgeKind::Reference | EdgeKind::Definition
+                    ) {
                         if let Some(target_symbol) = graph.graph.node_weight(edge.target()) {
                             if live_symbols.insert(target_symbol.id.clone()) {
                                 to_visit.push(target_symbol.id.clone());
@@ -505,6 +508,8 @@ mod tests {
             },
             documentation: None,
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         }
     }
 
@@ -706,6 +711,8 @@ mod tests {
             },
             documentation: None,
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         };
 
         assert!(ParallelIncrementalIndex::is_entry_point(&main_symbol));
@@ -727,6 +734,8 @@ mod tests {
             },
             documentation: None,
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         };
 
         assert!(ParallelIncrementalIndex::is_entry_point(&test_symbol));
@@ -748,6 +757,8 @@ mod tests {
             },
             documentation: None,
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         };
 
         assert!(!ParallelIncrementalIndex::is_entry_point(&private_symbol));
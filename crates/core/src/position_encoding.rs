@@ -0,0 +1,218 @@
+//! Position.characterのエンコーディング変換
+//!
+//! LSPサーバーはデフォルトでUTF-16コード単位、tree-sitterと正規表現の
+//! フォールバックはバイトオフセットを返す。`CodeGraph`には常に
+//! `PositionEncoding::CANONICAL`（UTF-8バイト）で保存し、境界で変換する。
+
+use crate::graph::{Position, Range};
+use serde::{Deserialize, Serialize};
+
+/// 列位置の単位
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Hash, Serialize, Deserialize, Default)]
+pub enum PositionEncoding {
+    /// UTF-8のバイト数（tree-sitter、正規表現、Vimのquickfix）
+    #[default]
+    Utf8,
+    /// UTF-16のコード単位（LSPのデフォルト、VS Code）
+    Utf16,
+    /// Unicodeのコードポイント数
+    Utf32,
+}
+
+impl PositionEncoding {
+    /// `CodeGraph`に保存する正規のエンコーディング
+    pub const CANONICAL: PositionEncoding = PositionEncoding::Utf8;
+
+    /// LSPの`PositionEncodingKind`の文字列
+    pub fn as_lsp_str(self) -> &'static str {
+        match self {
+            PositionEncoding::Utf8 => "utf-8",
+            PositionEncoding::Utf16 => "utf-16",
+            PositionEncoding::Utf32 => "utf-32",
+        }
+    }
+
+    /// LSPの`PositionEncodingKind`の文字列から変換
+    pub fn from_lsp_str(s: &str) -> Option<Self> {
+        match s {
+            "utf-8" => Some(PositionEncoding::Utf8),
+            "utf-16" => Some(PositionEncoding::Utf16),
+            "utf-32" => Some(PositionEncoding::Utf32),
+            _ => None,
+        }
+    }
+
+    /// 1文字あたりの単位数
+    fn units(self, ch: char) -> u32 {
+        match self {
+            PositionEncoding::Utf8 => ch.len_utf8() as u32,
+            PositionEncoding::Utf16 => ch.len_utf16() as u32,
+            PositionEncoding::Utf32 => 1,
+        }
+    }
+}
+
+/// 行内の列を別のエンコーディングに変換
+///
+/// 文字の途中を指す列はその文字の先頭に丸める。行末を超える列は超過分をそのまま足す。
+pub fn convert_column(
+    line: &str,
+    column: u32,
+    from: PositionEncoding,
+    to: PositionEncoding,
+) -> u32 {
+    if from == to {
+        return column;
+    }
+
+    let mut from_units = 0u32;
+    let mut to_units = 0u32;
+    for ch in line.chars() {
+        if from_units >= column {
+            return to_units;
+        }
+        let next = from_units + from.units(ch);
+        if next > column {
+            // 文字の途中
+            return to_units;
+        }
+        from_units = next;
+        to_units += to.units(ch);
+    }
+
+    to_units + column.saturating_sub(from_units)
+}
+
+/// ソースの行単位の索引（位置の変換用）
+pub struct LineIndex<'a> {
+    lines: Vec<&'a str>,
+}
+
+impl<'a> LineIndex<'a> {
+    pub fn new(text: &'a str) -> Self {
+        Self {
+            lines: text.split('\n').collect(),
+        }
+    }
+
+    /// 行の内容（存在しない行は空文字列）
+    pub fn line(&self, line: u32) -> &'a str {
+        self.lines.get(line as usize).copied().unwrap_or("")
+    }
+
+    pub fn convert_position(
+        &self,
+        position: Position,
+        from: PositionEncoding,
+        to: PositionEncoding,
+    ) -> Position {
+        Position {
+            line: position.line,
+            character: convert_column(self.line(position.line), position.character, from, to),
+        }
+    }
+
+    pub fn convert_range(
+        &self,
+        range: Range,
+        from: PositionEncoding,
+        to: PositionEncoding,
+    ) -> Range {
+        Range {
+            start: self.convert_position(range.start, from, to),
+            end: self.convert_position(range.end, from, to),
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    // "é"はUTF-8で2バイト・UTF-16で1単位、"𝕏"はUTF-8で4バイト・UTF-16で2単位
+    const LINE: &str = "let é = \"𝕏\"; fn foo() {}";
+
+    #[test]
+    fn test_convert_column_roundtrip() {
+        let byte_col = LINE.find("foo").unwrap() as u32;
+        let utf16_col = convert_column(
+            LINE,
+            byte_col,
+            PositionEncoding::Utf8,
+            PositionEncoding::Utf16,
+        );
+        let utf32_col = convert_column(
+            LINE,
+            byte_col,
+            PositionEncoding::Utf8,
+            PositionEncoding::Utf32,
+        );
+
+        assert_eq!(byte_col, 20);
+        assert_eq!(utf16_col, 17);
+        assert_eq!(utf32_col, 16);
+        assert_eq!(
+            convert_column(
+                LINE,
+                utf16_col,
+                PositionEncoding::Utf16,
+                PositionEncoding::Utf8
+            ),
+            byte_col
+        );
+        assert_eq!(
+            convert_column(
+                LINE,
+                utf32_col,
+                PositionEncoding::Utf32,
+                PositionEncoding::Utf16
+            ),
+            utf16_col
+        );
+    }
+
+    #[test]
+    fn test_convert_column_edges() {
+        // 文字の途中は先頭に丸める（"é"の2バイト目）
+        assert_eq!(
+            convert_column(LINE, 5, PositionEncoding::Utf8, PositionEncoding::Utf16),
+            4
+        );
+        // 行末を超える列は超過分を維持
+        let len16 = LINE.encode_utf16().count() as u32;
+        assert_eq!(
+            convert_column(
+                LINE,
+                len16 + 3,
+                PositionEncoding::Utf16,
+                PositionEncoding::Utf8
+            ),
+            LINE.len() as u32 + 3
+        );
+        // ASCIIのみなら変わらない
+        assert_eq!(
+            convert_column("abc", 2, PositionEncoding::Utf16, PositionEncoding::Utf8),
+            2
+        );
+    }
+
+    #[test]
+    fn test_line_index_convert_range() {
+        let text = "fn main() {}\nlet 名前 = 1;\n";
+        let index = LineIndex::new(text);
+        let range = Range {
+            start: Position {
+                line: 1,
+                character: 4,
+            },
+            end: Position {
+                line: 1,
+                character: 10,
+            },
+        };
+
+        let converted = index.convert_range(range, PositionEncoding::Utf8, PositionEncoding::Utf16);
+        assert_eq!(converted.start.character, 4);
+        assert_eq!(converted.end.character, 6);
+    }
+}
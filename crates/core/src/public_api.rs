@@ -1,4 +1,5 @@
 use crate::graph::{CodeGraph, EdgeKind, Symbol, SymbolKind};
+use rayon::prelude::*;
 
 /// 公開API分析のための構造体
 #[derive(Debug, Clone)]
@@ -31,13 +32,21 @@ impl PublicApiAnalyzer {
     }
 
     /// 言語固有の公開API判定
+    ///
+    /// シンボルごとの判定はrayonの現在のスレッドプールで並列に行う。
+    /// 重要度の同じAPIはグラフのシンボルの順で、スレッド数によらない。
     pub fn extract_public_apis(&self, language: &str) -> Vec<ApiInfo> {
-        let mut apis = Vec::new();
-        
-        for node in self.graph.graph.node_indices() {
-            if let Some(symbol) = self.graph.graph.node_weight(node) {
+        let nodes: Vec<_> = self.graph.graph.node_indices().collect();
+
+        let mut apis: Vec<ApiInfo> = nodes
+            .into_par_iter()
+            .filter_map(|node| {
+                let symbol = self.graph.graph.node_weight(node)?;
                 let visibility = self.determine_visibility(symbol, language);
                 let is_exported = self.is_exported(node);
+                if visibility != Visibility::Public && !is_exported {
+                    return None;
+                }
                 let reference_count = self.count_references(node);
                 let importance_score = self.calculate_importance_score(
                     visibility,
@@ -45,25 +54,26 @@ impl PublicApiAnalyzer {
                     reference_count,
                     symbol.kind,
                 );
-                
-                if visibility == Visibility::Public || is_exported {
-                    apis.push(ApiInfo {
-                        symbol: symbol.clone(),
-                        visibility,
-                        is_exported,
-                        reference_count,
-                        importance_score,
-                    });
-                }
-            }
-        }
-        
+                Some(ApiInfo {
+                    symbol: symbol.clone(),
+                    visibility,
+                    is_exported,
+                    reference_count,
+                    importance_score,
+                })
+            })
+            .collect();
+
         apis.sort_by(|a, b| b.importance_score.partial_cmp(&a.importance_score).unwrap());
         apis
     }
 
     /// シンボルの可視性を判定
     fn determine_visibility(&self, symbol: &Symbol, language: &str) -> Visibility {
+        // 抽出時に判定済みならそれを使う
+        if let Some(visibility) = symbol.modifiers.visibility() {
+            return visibility;
+        }
         match language {
             "rust" => self.determine_rust_visibility(symbol),
             "typescript" | "javascript" => self.determine_typescript_visibility(symbol),
@@ -136,13 +146,13 @@ impl PublicApiAnalyzer {
     fn is_exported(&self, node: petgraph::stable_graph::NodeIndex) -> bool {
         // Export エッジを持っているか確認
         self.graph.graph.edges(node)
-            .any(|edge| *edge.weight() == EdgeKind::Export)
+            .any(|edge| edge.weight().kind == EdgeKind::Export)
     }
 
     /// 参照カウントを取得
     fn count_references(&self, node: petgraph::stable_graph::NodeIndex) -> usize {
         self.graph.graph.edges_directed(node, petgraph::Direction::Incoming)
-            .filter(|edge| *edge.weight() == EdgeKind::Reference)
+            .filter(|edge| edge.weight().kind == EdgeKind::Reference)
             .count()
     }
 
@@ -242,6 +252,8 @@ mod tests {
             },
             documentation: None,
             detail,
+            modifiers: Default::default(),
+            qualified_name: None,
         }
     }
 
@@ -368,7 +380,7 @@ mod tests {
         let main_node = graph.add_symbol(main_symbol.clone());
         
         // Add export edge
-        graph.graph.add_edge(main_node, main_node, EdgeKind::Export);
+        graph.graph.add_edge(main_node, main_node, EdgeKind::Export.into());
         
         let analyzer = PublicApiAnalyzer::new(graph);
         let entry_points = analyzer.identify_entry_points();
@@ -537,5 +549,13 @@ mod tests {
             None
         );
         assert_eq!(analyzer.determine_visibility(&go_public, "go"), Visibility::Public);
+
+        // 抽出時に判定した修飾子が優先される
+        let mut go_internal = create_test_symbol("PublicName", SymbolKind::Function, None);
+        go_internal.modifiers = crate::SymbolModifiers::INTERNAL;
+        assert_eq!(
+            analyzer.determine_visibility(&go_internal, "go"),
+            Visibility::Internal
+        );
     }
 }
\ No newline at end of file
@@ -0,0 +1,401 @@
+//! 完全修飾名の計算
+//!
+//! `Contains`エッジで親のシンボルをたどり、ファイルのモジュールパスと言語の規則で
+//! `crate::store::Db::close`（Rust）や`github.com/org/svc/internal/store.(*DB).Close`（Go）の
+//! ような名前を作る。結果は`Symbol.qualified_name`に保存する。
+
+use crate::graph::{CodeGraph, EdgeKind, Symbol, SymbolKind};
+use petgraph::stable_graph::NodeIndex;
+use petgraph::visit::EdgeRef;
+use std::collections::HashMap;
+use std::path::{Path, PathBuf};
+
+/// 親をたどる深さの上限（`Contains`の循環対策）
+const MAX_DEPTH: usize = 64;
+
+/// グラフの全シンボルに完全修飾名を付け、付けた数を返す
+///
+/// 参照と`impl`ブロック（名前を持たない）には付けない。
+pub fn qualify_symbols(graph: &mut CodeGraph, project_root: &Path) -> usize {
+    let mut modules = ModulePaths::new(project_root);
+    let indices: Vec<NodeIndex> = graph.graph.node_indices().collect();
+
+    let mut names = Vec::new();
+    for idx in indices {
+        let symbol = &graph.graph[idx];
+        if symbol.kind == SymbolKind::Reference || symbol.name.starts_with("impl ") {
+            continue;
+        }
+        let language = match Language::from_path(&symbol.file_path) {
+            Some(language) => language,
+            None => continue,
+        };
+
+        // 外側から順の親
+        let mut parents = Vec::new();
+        let mut current = idx;
+        while parents.len() < MAX_DEPTH {
+            let parent = graph
+                .graph
+                .edges(current)
+                .find(|edge| edge.weight().kind == EdgeKind::Contains)
+                .map(|edge| edge.target());
+            match parent {
+                Some(parent) => {
+                    parents.push(&graph.graph[parent]);
+                    current = parent;
+                }
+                None => break,
+            }
+        }
+        parents.reverse();
+
+        let module = modules.module_path(&symbol.file_path, language);
+        names.push((idx, qualify(symbol, &parents, &module, language)));
+    }
+
+    let count = names.len();
+    for (idx, name) in names {
+        graph.graph[idx].qualified_name = Some(name);
+    }
+    count
+}
+
+/// 完全修飾名を親のスコープと名前に分ける（`crate::a::B::c`なら`crate::a::B`と`c`）
+pub fn split_qualified_name(qualified: &str) -> Option<(&str, &str)> {
+    if qualified.contains("::") {
+        qualified.rsplit_once("::")
+    } else {
+        qualified.rsplit_once('.')
+    }
+}
+
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+enum Language {
+    Rust,
+    Go,
+    Python,
+    TypeScript,
+}
+
+impl Language {
+    fn from_path(file_path: &str) -> Option<Self> {
+        match Path::new(file_path).extension()?.to_str()? {
+            "rs" => Some(Self::Rust),
+            "go" => Some(Self::Go),
+            "py" => Some(Self::Python),
+            "ts" | "tsx" | "js" | "jsx" | "mjs" | "cjs" => Some(Self::TypeScript),
+            _ => None,
+        }
+    }
+
+    fn separator(self) -> &'static str {
+        match self {
+            Self::Rust => "::",
+            _ => ".",
+        }
+    }
+}
+
+fn qualify(symbol: &Symbol, parents: &[&Symbol], module: &str, language: Language) -> String {
+    let mut segments: Vec<String> = Vec::new();
+    if !module.is_empty() {
+        segments.push(module.to_string());
+    }
+    segments.extend(parents.iter().map(|parent| scope_segment(parent)));
+    // Goのメソッドはレシーバの型の中に入れ子にならないので、名前（goplsの`(*DB).Close`）か
+    // シグネチャ（tree-sitterの`func (db *DB) Close()`）から取る
+    let mut name = symbol.name.as_str();
+    if language == Language::Go {
+        match split_go_method_name(name) {
+            Some((receiver, method)) => {
+                segments.push(receiver);
+                name = method;
+            }
+            None => {
+                if let Some(receiver) = symbol.detail.as_deref().and_then(go_receiver) {
+                    segments.push(receiver);
+                }
+            }
+        }
+    }
+    segments.push(name.to_string());
+    segments.join(language.separator())
+}
+
+/// 親のシンボルの名前（`impl Display for Db`や`impl<T> Stack<T>`は型名）
+fn scope_segment(parent: &Symbol) -> String {
+    match parent.name.strip_prefix("impl ") {
+        Some(target) => {
+            let target = target.rsplit(" for ").next().unwrap_or(target).trim();
+            let target = target.trim_start_matches('&').trim_start_matches("dyn ");
+            target.split('<').next().unwrap_or(target).to_string()
+        }
+        None => parent.name.clone(),
+    }
+}
+
+/// `func (d *DB) Close()`のレシーバの型（`(*DB)`か`DB`）
+fn go_receiver(signature: &str) -> Option<String> {
+    let receiver = signature
+        .strip_prefix("func")?
+        .trim_start()
+        .strip_prefix('(')?;
+    let receiver = &receiver[..receiver.find(')')?];
+    // 型パラメータ（`*List[T]`）は除く
+    receiver_type(receiver.split_whitespace().last()?)
+}
+
+/// goplsのメソッド名`(*DB).Close`・`(DB).Close`のレシーバの型とメソッド名
+fn split_go_method_name(name: &str) -> Option<(String, &str)> {
+    let rest = name.strip_prefix('(')?;
+    let end = rest.find(").")?;
+    let method = &rest[end + 2..];
+    if method.is_empty() {
+        return None;
+    }
+    receiver_type(&rest[..end]).map(|receiver| (receiver, method))
+}
+
+/// レシーバの型（`*DB`は`(*DB)`、型パラメータは除く）
+fn receiver_type(type_name: &str) -> Option<String> {
+    let type_name = type_name.trim();
+    let type_name = type_name.split('[').next().unwrap_or(type_name);
+    match type_name.strip_prefix('*') {
+        Some(pointee) => Some(format!("(*{})", pointee)),
+        None if !type_name.is_empty() => Some(type_name.to_string()),
+        None => None,
+    }
+}
+
+/// ファイルのモジュールパス（Goの`go.mod`は読み込みを使い回す）
+struct ModulePaths {
+    project_root: PathBuf,
+    /// ディレクトリ → (go.modのあるディレクトリ, モジュール名)
+    go_modules: HashMap<PathBuf, Option<(PathBuf, String)>>,
+}
+
+impl ModulePaths {
+    fn new(project_root: &Path) -> Self {
+        Self {
+            project_root: project_root.to_path_buf(),
+            go_modules: HashMap::new(),
+        }
+    }
+
+    fn module_path(&mut self, file_path: &str, language: Language) -> String {
+        let path = Path::new(file_path);
+        let relative = path.strip_prefix(&self.project_root).unwrap_or(path);
+        let relative = relative.strip_prefix(".").unwrap_or(relative);
+        let mut components: Vec<String> = relative
+            .with_extension("")
+            .components()
+            .map(|c| c.as_os_str().to_string_lossy().to_string())
+            .collect();
+
+        match language {
+            Language::Rust => {
+                // `src/`より下がモジュール、`lib.rs`・`main.rs`・`mod.rs`は親のモジュール
+                if let Some(src) = components.iter().rposition(|c| c == "src") {
+                    components.drain(..=src);
+                }
+                if matches!(
+                    components.last().map(String::as_str),
+                    Some("lib" | "main" | "mod")
+                ) {
+                    components.pop();
+                }
+                std::iter::once("crate".to_string())
+                    .chain(components)
+                    .collect::<Vec<_>>()
+                    .join("::")
+            }
+            Language::Go => {
+                let dir = path.parent().unwrap_or(Path::new(""));
+                match self.go_module(dir) {
+                    Some((module_dir, module)) => {
+                        let package = dir.strip_prefix(&module_dir).unwrap_or(Path::new(""));
+                        if package.as_os_str().is_empty() {
+                            module
+                        } else {
+                            format!("{}/{}", module, package.to_string_lossy())
+                        }
+                    }
+                    None => {
+                        components.pop();
+                        components.join("/")
+                    }
+                }
+            }
+            Language::Python => {
+                if components.last().map(String::as_str) == Some("__init__") {
+                    components.pop();
+                }
+                if components.first().map(String::as_str) == Some("src") {
+                    components.remove(0);
+                }
+                components.join(".")
+            }
+            Language::TypeScript => {
+                if components.len() > 1 && components.last().map(String::as_str) == Some("index") {
+                    components.pop();
+                }
+                components.join("/")
+            }
+        }
+    }
+
+    /// `dir`を含むGoモジュール（プロジェクトルートより上は探さない）
+    fn go_module(&mut self, dir: &Path) -> Option<(PathBuf, String)> {
+        if let Some(cached) = self.go_modules.get(dir) {
+            return cached.clone();
+        }
+
+        let module = match std::fs::read_to_string(dir.join("go.mod")) {
+            Ok(content) => content
+                .lines()
+                .find_map(|line| line.trim().strip_prefix("module "))
+                .map(|name| (dir.to_path_buf(), name.trim().trim_matches('"').to_string())),
+            Err(_) => match dir.parent() {
+                Some(parent) if dir != self.project_root && !dir.as_os_str().is_empty() => {
+                    self.go_module(parent)
+                }
+                _ => None,
+            },
+        };
+        self.go_modules.insert(dir.to_path_buf(), module.clone());
+        module
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::graph::{Position, Range};
+    use std::fs;
+    use tempfile::TempDir;
+
+    fn symbol(file: &str, name: &str, kind: SymbolKind, lines: (u32, u32)) -> Symbol {
+        Symbol {
+            id: format!("{}#{}:{}", file, lines.0 + 1, name),
+            kind,
+            name: name.to_string(),
+            file_path: file.to_string(),
+            range: Range {
+                start: Position {
+                    line: lines.0,
+                    character: 0,
+                },
+                end: Position {
+                    line: lines.1,
+                    character: 1,
+                },
+            },
+            documentation: None,
+            detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
+        }
+    }
+
+    fn qualified(graph: &CodeGraph, id: &str) -> Option<String> {
+        graph.find_symbol(id).unwrap().qualified_name.clone()
+    }
+
+    #[test]
+    fn test_rust_qualified_names() {
+        let file = "crates/core/src/store/mod.rs";
+        let symbols = vec![
+            symbol(file, "Db", SymbolKind::Struct, (0, 2)),
+            symbol(file, "impl Drop for Db", SymbolKind::Class, (4, 10)),
+            symbol(file, "close", SymbolKind::Method, (5, 7)),
+        ];
+        let mut graph = CodeGraph::new();
+        graph.add_symbols(symbols.clone());
+        graph.link_containment(&symbols);
+
+        assert_eq!(qualify_symbols(&mut graph, Path::new(".")), 2);
+        assert_eq!(
+            qualified(&graph, "crates/core/src/store/mod.rs#6:close").as_deref(),
+            Some("crate::store::Db::close")
+        );
+        assert_eq!(
+            qualified(&graph, "crates/core/src/store/mod.rs#1:Db").as_deref(),
+            Some("crate::store::Db")
+        );
+        assert_eq!(
+            qualified(&graph, "crates/core/src/store/mod.rs#5:impl Drop for Db"),
+            None
+        );
+    }
+
+    #[test]
+    fn test_go_qualified_names() {
+        let temp_dir = TempDir::new().unwrap();
+        let root = temp_dir.path();
+        fs::create_dir_all(root.join("internal/store")).unwrap();
+        fs::write(
+            root.join("go.mod"),
+            "module github.com/org/svc\n\ngo 1.22\n",
+        )
+        .unwrap();
+
+        let file = root
+            .join("internal/store/db.go")
+            .to_string_lossy()
+            .to_string();
+        let mut close = symbol(&file, "Close", SymbolKind::Method, (10, 12));
+        close.detail = Some("func (db *DB) Close() error".to_string());
+        // goplsのdocumentSymbolは名前にレシーバを含め、シグネチャには含めない
+        let mut flush = symbol(&file, "(*DB).Flush", SymbolKind::Method, (14, 16));
+        flush.detail = Some("func() error".to_string());
+        let mut len = symbol(&file, "(List[T]).Len", SymbolKind::Method, (18, 20));
+        len.detail = Some("func() int".to_string());
+        let mut graph = CodeGraph::new();
+        graph.add_symbol(close.clone());
+        graph.add_symbol(flush.clone());
+        graph.add_symbol(len.clone());
+        graph.add_symbol(symbol(&file, "Open", SymbolKind::Function, (2, 4)));
+
+        qualify_symbols(&mut graph, root);
+        assert_eq!(
+            qualified(&graph, &close.id).as_deref(),
+            Some("github.com/org/svc/internal/store.(*DB).Close")
+        );
+        assert_eq!(
+            qualified(&graph, &flush.id).as_deref(),
+            Some("github.com/org/svc/internal/store.(*DB).Flush")
+        );
+        assert_eq!(
+            qualified(&graph, &len.id).as_deref(),
+            Some("github.com/org/svc/internal/store.List.Len")
+        );
+        let (scope, name) =
+            split_qualified_name("github.com/org/svc/internal/store.(*DB).Close").unwrap();
+        assert_eq!(
+            (scope, name),
+            ("github.com/org/svc/internal/store.(*DB)", "Close")
+        );
+    }
+
+    #[test]
+    fn test_python_and_typescript_module_paths() {
+        let mut graph = CodeGraph::new();
+        let class = symbol("src/pkg/models.py", "User", SymbolKind::Class, (0, 5));
+        let method = symbol("src/pkg/models.py", "save", SymbolKind::Method, (2, 3));
+        let component = symbol("web/ui/index.tsx", "Button", SymbolKind::Function, (0, 3));
+        let symbols = vec![class, method.clone(), component.clone()];
+        graph.add_symbols(symbols.clone());
+        graph.link_containment(&symbols);
+
+        qualify_symbols(&mut graph, Path::new("."));
+        assert_eq!(
+            qualified(&graph, &method.id).as_deref(),
+            Some("pkg.models.User.save")
+        );
+        assert_eq!(
+            qualified(&graph, &component.id).as_deref(),
+            Some("web/ui.Button")
+        );
+    }
+}
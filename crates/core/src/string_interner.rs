@@ -1,6 +1,7 @@
 use dashmap::DashMap;
 use once_cell::sync::Lazy;
 use parking_lot::RwLock;
+use std::collections::HashMap;
 use std::fmt;
 use std::sync::Arc;
 
@@ -88,6 +89,13 @@ impl StringInterner {
         InternedString(id)
     }
 
+    /// インターン化済みならそのID（文字列を追加しない）
+    pub fn lookup(&self, s: &str) -> Option<InternedString> {
+        self.string_to_id
+            .get(s)
+            .map(|id| InternedString(*id.value()))
+    }
+
     /// バッチでインターン化
     pub fn intern_batch(&self, strings: Vec<String>) -> Vec<InternedString> {
         strings.iter().map(|s| self.intern(s)).collect()
@@ -128,6 +136,50 @@ impl Default for StringInterner {
     }
 }
 
+/// 保存用の文字列表
+///
+/// `StringInterner`は文字列をリークして`'static`にするので、保存のたびに作るとメモリが増え続ける。
+/// ファイルに書き出す表（`compact_graph`）はこちらで作る。IDは追加した順の連番。
+#[derive(Debug, Default)]
+pub struct StringTable {
+    ids: HashMap<String, u32>,
+    strings: Vec<String>,
+}
+
+impl StringTable {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// 文字列のIDを返す（初めての文字列なら追加する）
+    pub fn intern(&mut self, s: &str) -> u32 {
+        if let Some(&id) = self.ids.get(s) {
+            return id;
+        }
+        let id = self.strings.len() as u32;
+        self.ids.insert(s.to_string(), id);
+        self.strings.push(s.to_string());
+        id
+    }
+
+    pub fn get(&self, id: u32) -> Option<&str> {
+        self.strings.get(id as usize).map(String::as_str)
+    }
+
+    /// ID順の文字列
+    pub fn strings(&self) -> &[String] {
+        &self.strings
+    }
+
+    pub fn len(&self) -> usize {
+        self.strings.len()
+    }
+
+    pub fn is_empty(&self) -> bool {
+        self.strings.is_empty()
+    }
+}
+
 /// グローバルなインターナー
 static GLOBAL_INTERNER: Lazy<StringInterner> = Lazy::new(StringInterner::new);
 
@@ -136,6 +188,11 @@ pub fn intern(s: &str) -> InternedString {
     GLOBAL_INTERNER.intern(s)
 }
 
+/// グローバルインターナーでインターン化済みの文字列を探す（検索用、リークしない）
+pub fn lookup(s: &str) -> Option<InternedString> {
+    GLOBAL_INTERNER.lookup(s)
+}
+
 /// グローバルインターナーの統計情報を取得
 pub fn interner_stats() -> InternerStats {
     GLOBAL_INTERNER.stats()
@@ -151,6 +208,8 @@ pub struct InternedSymbol {
     pub range: crate::Range,
     pub documentation: Option<InternedString>,
     pub detail: Option<InternedString>,
+    pub modifiers: crate::SymbolModifiers,
+    pub qualified_name: Option<InternedString>,
 }
 
 impl InternedSymbol {
@@ -164,6 +223,8 @@ impl InternedSymbol {
             range: symbol.range,
             documentation: symbol.documentation.as_deref().map(intern),
             detail: symbol.detail.as_deref().map(intern),
+            modifiers: symbol.modifiers,
+            qualified_name: symbol.qualified_name.as_deref().map(intern),
         }
     }
 
@@ -177,6 +238,8 @@ impl InternedSymbol {
             range: self.range,
             documentation: self.documentation.map(|d| d.as_str().to_string()),
             detail: self.detail.map(|d| d.as_str().to_string()),
+            modifiers: self.modifiers,
+            qualified_name: self.qualified_name.map(|q| q.as_str().to_string()),
         }
     }
 
@@ -186,7 +249,8 @@ impl InternedSymbol {
         4 * 3 + // id, name, file_path
         std::mem::size_of::<crate::SymbolKind>() +
         std::mem::size_of::<crate::Range>() +
-        8 // 2 * Option<InternedString> (documentation, detail)
+        std::mem::size_of::<crate::SymbolModifiers>() +
+        12 // 3 * Option<InternedString> (documentation, detail, qualified_name)
     }
 }
 
@@ -212,6 +276,11 @@ mod tests {
         let stats = interner.stats();
         assert_eq!(stats.total_strings, 2); // "hello"と"world"のみ
         assert_eq!(stats.cache_hits, 1); // "hello"の2回目
+
+        // 検索は追加しない
+        assert_eq!(interner.lookup("hello"), Some(s1));
+        assert_eq!(interner.lookup("missing"), None);
+        assert_eq!(interner.len(), 2);
     }
 
     #[test]
@@ -245,6 +314,8 @@ mod tests {
             },
             documentation: Some("Test doc".to_string()),
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         };
 
         let interned = InternedSymbol::from_symbol(symbol.clone());
@@ -281,6 +352,18 @@ mod tests {
         assert_eq!(interner.len(), 3); // ユニークな文字列は3つ
     }
 
+    #[test]
+    fn test_string_table() {
+        let mut table = StringTable::new();
+        assert_eq!(table.intern("src/a.rs"), 0);
+        assert_eq!(table.intern("main"), 1);
+        assert_eq!(table.intern("src/a.rs"), 0);
+        assert_eq!(table.len(), 2);
+        assert_eq!(table.get(1), Some("main"));
+        assert_eq!(table.get(2), None);
+        assert_eq!(table.strings(), ["src/a.rs", "main"]);
+    }
+
     #[test]
     fn test_memory_estimation() {
         let interner = StringInterner::new();
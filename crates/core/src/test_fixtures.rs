@@ -1,5 +1,7 @@
 //! テストフィクスチャ - test_projectsの代替として各言語のサンプルコードを提供
 
+use crate::graph::{Position, Range, Symbol, SymbolKind};
+use crate::modifiers::SymbolModifiers;
 use std::collections::HashMap;
 
 /// 各言語のテストコードサンプル
@@ -96,10 +98,110 @@ pub struct TestFile {
     pub content: String,
 }
 
+/// テスト用のシンボルを組み立てるビルダー
+///
+/// 既定は`0:0-0:10`の範囲の関数で、ドキュメント・詳細・修飾子・完全修飾名は持たない。
+/// テストごとに`Symbol`のリテラルを書かずに、必要なフィールドだけ変える。
+#[derive(Debug, Clone)]
+pub struct SymbolBuilder {
+    symbol: Symbol,
+}
+
+impl SymbolBuilder {
+    pub fn new(id: &str, name: &str, file_path: &str) -> Self {
+        Self {
+            symbol: Symbol {
+                id: id.to_string(),
+                kind: SymbolKind::Function,
+                name: name.to_string(),
+                file_path: file_path.to_string(),
+                range: Range {
+                    start: Position {
+                        line: 0,
+                        character: 0,
+                    },
+                    end: Position {
+                        line: 0,
+                        character: 10,
+                    },
+                },
+                documentation: None,
+                detail: None,
+                modifiers: SymbolModifiers::default(),
+                qualified_name: None,
+            },
+        }
+    }
+
+    pub fn kind(mut self, kind: SymbolKind) -> Self {
+        self.symbol.kind = kind;
+        self
+    }
+
+    /// 範囲を`(start_line, start_character)`から`(end_line, end_character)`にする
+    pub fn range(mut self, start: (u32, u32), end: (u32, u32)) -> Self {
+        self.symbol.range = Range {
+            start: Position {
+                line: start.0,
+                character: start.1,
+            },
+            end: Position {
+                line: end.0,
+                character: end.1,
+            },
+        };
+        self
+    }
+
+    pub fn documentation(mut self, documentation: &str) -> Self {
+        self.symbol.documentation = Some(documentation.to_string());
+        self
+    }
+
+    pub fn detail(mut self, detail: &str) -> Self {
+        self.symbol.detail = Some(detail.to_string());
+        self
+    }
+
+    pub fn modifiers(mut self, modifiers: SymbolModifiers) -> Self {
+        self.symbol.modifiers = modifiers;
+        self
+    }
+
+    pub fn qualified_name(mut self, qualified_name: &str) -> Self {
+        self.symbol.qualified_name = Some(qualified_name.to_string());
+        self
+    }
+
+    pub fn build(self) -> Symbol {
+        self.symbol
+    }
+}
+
 #[cfg(test)]
 mod tests {
     use super::*;
 
+    #[test]
+    fn test_symbol_builder_defaults_and_overrides() {
+        let symbol = SymbolBuilder::new("src/lib.rs#Shape", "Shape", "src/lib.rs").build();
+        assert_eq!(symbol.kind, SymbolKind::Function);
+        assert_eq!(symbol.range.end.character, 10);
+        assert!(symbol.documentation.is_none() && symbol.qualified_name.is_none());
+
+        let symbol = SymbolBuilder::new("src/lib.rs#Shape", "Shape", "src/lib.rs")
+            .kind(SymbolKind::Interface)
+            .range((3, 0), (6, 1))
+            .documentation("A shape")
+            .qualified_name("crate::Shape")
+            .build();
+        assert_eq!(symbol.kind, SymbolKind::Interface);
+        assert_eq!(symbol.range.start.line, 3);
+        assert_eq!(symbol.range.end.line, 6);
+        assert_eq!(symbol.documentation.as_deref(), Some("A shape"));
+        assert_eq!(symbol.qualified_name.as_deref(), Some("crate::Shape"));
+    }
+
     #[test]
     fn test_all_samples_available() {
         let samples = TestFixtures::all_samples();
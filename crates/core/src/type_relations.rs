@@ -1,5 +1,6 @@
 use super::graph::{CodeGraph, EdgeKind, Symbol, SymbolKind};
-use petgraph::visit::EdgeRef;
+use super::graph_store::GraphStore;
+use petgraph::Direction;
 use std::collections::{HashSet, VecDeque};
 
 /// Result of collecting type-related symbols
@@ -24,12 +25,12 @@ pub struct TypeRelations {
 }
 
 /// Analyzer for collecting type-related symbols recursively
-pub struct TypeRelationsAnalyzer<'a> {
-    graph: &'a CodeGraph,
+pub struct TypeRelationsAnalyzer<'a, G: GraphStore + ?Sized = CodeGraph> {
+    graph: &'a G,
 }
 
-impl<'a> TypeRelationsAnalyzer<'a> {
-    pub fn new(graph: &'a CodeGraph) -> Self {
+impl<'a, G: GraphStore + ?Sized> TypeRelationsAnalyzer<'a, G> {
+    pub fn new(graph: &'a G) -> Self {
         Self { graph }
     }
 
@@ -39,7 +40,7 @@ impl<'a> TypeRelationsAnalyzer<'a> {
         type_symbol_id: &str,
         max_depth: usize,
     ) -> Option<TypeRelations> {
-        let root_type = self.graph.find_symbol(type_symbol_id)?.clone();
+        let root_type = self.graph.get_symbol(type_symbol_id)?.into_owned();
 
         // Check if it's actually a type-like symbol
         if !self.is_type_symbol(&root_type) {
@@ -102,11 +103,14 @@ impl<'a> TypeRelationsAnalyzer<'a> {
             }
 
             // Get direct references
-            let refs = self.graph.find_references(&current_id).unwrap_or_default();
+            let refs = self
+                .graph
+                .edges_of(&current_id, Direction::Incoming)
+                .filter(|(_, edge)| matches!(edge.kind, EdgeKind::Reference));
 
-            for reference in refs {
+            for (reference, _) in refs {
                 if visited.insert(reference.id.clone()) {
-                    all_references.push(reference);
+                    all_references.push(reference.into_owned());
 
                     // Add to queue for recursive search
                     if depth < max_depth {
@@ -116,24 +120,14 @@ impl<'a> TypeRelationsAnalyzer<'a> {
             }
 
             // Also check for symbols that have this type
-            if let Some(node_idx) = self.graph.get_node_index(&current_id) {
-                // Look for incoming edges
-                for edge in self
-                    .graph
-                    .graph
-                    .edges_directed(node_idx, petgraph::Direction::Incoming)
+            for (source, edge) in self.graph.edges_of(&current_id, Direction::Incoming) {
+                if matches!(edge.kind, EdgeKind::Reference | EdgeKind::Definition)
+                    && visited.insert(source.id.clone())
                 {
-                    if matches!(edge.weight(), EdgeKind::Reference | EdgeKind::Definition) {
-                        if let Some(source) = self.graph.graph.node_weight(edge.source()) {
-                            if visited.insert(source.id.clone()) {
-                                all_references.push(source.clone());
-
-                                if depth < max_depth {
-                                    queue.push_back((source.id.clone(), depth + 1));
-                                }
-                            }
-                        }
+                    if depth < max_depth {
+                        queue.push_back((source.id.clone(), depth + 1));
                     }
+                    all_references.push(source.into_owned());
                 }
             }
         }
@@ -145,8 +139,8 @@ impl<'a> TypeRelationsAnalyzer<'a> {
     pub fn find_type_hierarchy(&self, type_symbol_id: &str) -> TypeHierarchy {
         let mut hierarchy = TypeHierarchy::new();
 
-        if let Some(root) = self.graph.find_symbol(type_symbol_id) {
-            hierarchy.root = Some(root.clone());
+        if let Some(root) = self.graph.get_symbol(type_symbol_id) {
+            hierarchy.root = Some(root.into_owned());
 
             // Find parents (what this type extends/implements)
             self.find_parent_types(type_symbol_id, &mut hierarchy.parents, &mut HashSet::new());
@@ -170,39 +164,29 @@ impl<'a> TypeRelationsAnalyzer<'a> {
     pub fn group_relations_by_type(&self, type_symbol_id: &str) -> RelationGroups {
         let mut groups = RelationGroups::default();
 
-        if let Some(node_idx) = self.graph.get_node_index(type_symbol_id) {
-            // Analyze outgoing edges
-            for edge in self.graph.graph.edges(node_idx) {
-                if let Some(target) = self.graph.graph.node_weight(edge.target()) {
-                    match edge.weight() {
-                        EdgeKind::Definition => {
-                            groups.definitions.push(target.clone());
-                        }
-                        EdgeKind::Reference => {
-                            groups.references.push(target.clone());
-                        }
-                        _ => {}
-                    }
+        // Analyze outgoing edges
+        for (target, edge) in self.graph.edges_of(type_symbol_id, Direction::Outgoing) {
+            match edge.kind {
+                EdgeKind::Definition => {
+                    groups.definitions.push(target.into_owned());
+                }
+                EdgeKind::Reference => {
+                    groups.references.push(target.into_owned());
                 }
+                _ => {}
             }
+        }
 
-            // Analyze incoming edges
-            for edge in self
-                .graph
-                .graph
-                .edges_directed(node_idx, petgraph::Direction::Incoming)
-            {
-                if let Some(source) = self.graph.graph.node_weight(edge.source()) {
-                    match edge.weight() {
-                        EdgeKind::Definition => {
-                            groups.defined_by.push(source.clone());
-                        }
-                        EdgeKind::Reference => {
-                            groups.referenced_by.push(source.clone());
-                        }
-                        _ => {}
-                    }
+        // Analyze incoming edges
+        for (source, edge) in self.graph.edges_of(type_symbol_id, Direction::Incoming) {
+            match edge.kind {
+                EdgeKind::Definition => {
+                    groups.defined_by.push(source.into_owned());
+                }
+                EdgeKind::Reference => {
+                    groups.referenced_by.push(source.into_owned());
                 }
+                _ => {}
             }
         }
 
@@ -256,60 +240,50 @@ impl<'a> TypeRelationsAnalyzer<'a> {
             return;
         }
 
-        if let Some(node_idx) = self.graph.get_node_index(symbol_id) {
-            // Collect incoming references (who uses this type)
-            for edge in self
-                .graph
-                .graph
-                .edges_directed(node_idx, petgraph::Direction::Incoming)
-            {
-                if let Some(source) = self.graph.graph.node_weight(edge.source()) {
-                    match source.kind {
-                        SymbolKind::Variable | SymbolKind::Parameter => {
-                            users.push(source.clone());
-                        }
-                        SymbolKind::Class | SymbolKind::Interface => {
-                            // Check if it's extending or implementing
-                            if matches!(edge.weight(), EdgeKind::Definition) {
-                                extensions.push(source.clone());
-                            } else {
-                                implementations.push(source.clone());
-                            }
-                        }
-                        SymbolKind::Method => {
-                            methods.push(source.clone());
-                        }
-                        SymbolKind::Property | SymbolKind::Field => {
-                            members.push(source.clone());
-                        }
-                        _ => {}
-                    }
-
-                    // Recurse
-                    if current_depth < max_depth {
-                        self.collect_recursive(
-                            &source.id,
-                            users,
-                            implementations,
-                            extensions,
-                            members,
-                            methods,
-                            type_parameters,
-                            visited,
-                            current_depth + 1,
-                            max_depth,
-                        );
+        // Collect incoming references (who uses this type)
+        for (source, edge) in self.graph.edges_of(symbol_id, Direction::Incoming) {
+            match source.kind {
+                SymbolKind::Variable | SymbolKind::Parameter => {
+                    users.push(source.as_ref().clone());
+                }
+                SymbolKind::Class | SymbolKind::Interface => {
+                    // Check if it's extending or implementing
+                    if matches!(edge.kind, EdgeKind::Definition) {
+                        extensions.push(source.as_ref().clone());
+                    } else {
+                        implementations.push(source.as_ref().clone());
                     }
                 }
+                SymbolKind::Method => {
+                    methods.push(source.as_ref().clone());
+                }
+                SymbolKind::Property | SymbolKind::Field => {
+                    members.push(source.as_ref().clone());
+                }
+                _ => {}
             }
 
-            // Collect outgoing references (what this type depends on)
-            for edge in self.graph.graph.edges(node_idx) {
-                if let Some(target) = self.graph.graph.node_weight(edge.target()) {
-                    if self.is_type_symbol(target) {
-                        type_parameters.push(target.clone());
-                    }
-                }
+            // Recurse
+            if current_depth < max_depth {
+                self.collect_recursive(
+                    &source.id,
+                    users,
+                    implementations,
+                    extensions,
+                    members,
+                    methods,
+                    type_parameters,
+                    visited,
+                    current_depth + 1,
+                    max_depth,
+                );
+            }
+        }
+
+        // Collect outgoing references (what this type depends on)
+        for (target, _) in self.graph.edges_of(symbol_id, Direction::Outgoing) {
+            if self.is_type_symbol(&target) {
+                type_parameters.push(target.into_owned());
             }
         }
     }
@@ -318,7 +292,7 @@ impl<'a> TypeRelationsAnalyzer<'a> {
     fn find_related_types(
         &self,
         symbol_id: &str,
-        direction: petgraph::Direction,
+        direction: Direction,
         results: &mut Vec<Symbol>,
         visited: &mut HashSet<String>,
     ) {
@@ -326,21 +300,10 @@ impl<'a> TypeRelationsAnalyzer<'a> {
             return;
         }
 
-        if let Some(node_idx) = self.graph.get_node_index(symbol_id) {
-            for edge in self.graph.graph.edges_directed(node_idx, direction) {
-                if matches!(edge.weight(), EdgeKind::Definition) {
-                    let related_node = match direction {
-                        petgraph::Direction::Outgoing => edge.target(),
-                        petgraph::Direction::Incoming => edge.source(),
-                    };
-
-                    if let Some(related) = self.graph.graph.node_weight(related_node) {
-                        if self.is_type_symbol(related) {
-                            results.push(related.clone());
-                            self.find_related_types(&related.id, direction, results, visited);
-                        }
-                    }
-                }
+        for (related, edge) in self.graph.edges_of(symbol_id, direction) {
+            if matches!(edge.kind, EdgeKind::Definition) && self.is_type_symbol(&related) {
+                results.push(related.as_ref().clone());
+                self.find_related_types(&related.id, direction, results, visited);
             }
         }
     }
@@ -351,7 +314,7 @@ impl<'a> TypeRelationsAnalyzer<'a> {
         parents: &mut Vec<Symbol>,
         visited: &mut HashSet<String>,
     ) {
-        self.find_related_types(symbol_id, petgraph::Direction::Outgoing, parents, visited);
+        self.find_related_types(symbol_id, Direction::Outgoing, parents, visited);
     }
 
     fn find_child_types(
@@ -360,7 +323,7 @@ impl<'a> TypeRelationsAnalyzer<'a> {
         children: &mut Vec<Symbol>,
         visited: &mut HashSet<String>,
     ) {
-        self.find_related_types(symbol_id, petgraph::Direction::Incoming, children, visited);
+        self.find_related_types(symbol_id, Direction::Incoming, children, visited);
     }
 }
 
@@ -473,6 +436,7 @@ pub fn format_type_relations(relations: &TypeRelations) -> String {
 mod tests {
     use super::*;
     use crate::graph::{Position, Range};
+    use crate::graph_store::GraphBackend;
 
     fn create_type_symbol(id: &str, name: &str, kind: SymbolKind) -> Symbol {
         Symbol {
@@ -492,6 +456,8 @@ mod tests {
             },
             documentation: None,
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         }
     }
 
@@ -522,13 +488,16 @@ mod tests {
         // Method belongs to Base
         graph.add_edge(method_idx, base_idx, EdgeKind::Reference);
 
-        let analyzer = TypeRelationsAnalyzer::new(&graph);
-        let relations = analyzer.collect_type_relations("type:Base", 2).unwrap();
+        for backend in GraphBackend::ALL {
+            let graph = backend.load(graph.clone());
+            let analyzer = TypeRelationsAnalyzer::new(&*graph);
+            let relations = analyzer.collect_type_relations("type:Base", 2).unwrap();
 
-        assert_eq!(relations.root_type.id, "type:Base");
-        assert_eq!(relations.users.len(), 2); // var1 and var2
-        assert_eq!(relations.extensions.len(), 1); // Derived
-        assert_eq!(relations.methods.len(), 1); // foo
+            assert_eq!(relations.root_type.id, "type:Base");
+            assert_eq!(relations.users.len(), 2); // var1 and var2
+            assert_eq!(relations.extensions.len(), 1); // Derived
+            assert_eq!(relations.methods.len(), 1); // foo
+        }
     }
 
     #[test]
@@ -551,13 +520,16 @@ mod tests {
         graph.add_edge(y_idx, b_idx, EdgeKind::Reference);
         graph.add_edge(b_idx, a_idx, EdgeKind::Reference);
 
-        let analyzer = TypeRelationsAnalyzer::new(&graph);
-        let refs = analyzer.find_all_type_references("type:A", 2);
+        for backend in GraphBackend::ALL {
+            let graph = backend.load(graph.clone());
+            let analyzer = TypeRelationsAnalyzer::new(&*graph);
+            let refs = analyzer.find_all_type_references("type:A", 2);
 
-        // Should find x directly and y through B
-        assert!(refs.len() >= 2);
-        assert!(refs.iter().any(|r| r.id == "var:x"));
-        assert!(refs.iter().any(|r| r.id == "type:B"));
+            // Should find x directly and y through B
+            assert!(refs.len() >= 2);
+            assert!(refs.iter().any(|r| r.id == "var:x"));
+            assert!(refs.iter().any(|r| r.id == "type:B"));
+        }
     }
 
     #[test]
@@ -582,12 +554,15 @@ mod tests {
         graph.add_edge(derived1_idx, base_idx, EdgeKind::Definition);
         graph.add_edge(derived2_idx, base_idx, EdgeKind::Definition);
 
-        let analyzer = TypeRelationsAnalyzer::new(&graph);
-        let hierarchy = analyzer.find_type_hierarchy("type:Base");
+        for backend in GraphBackend::ALL {
+            let graph = backend.load(graph.clone());
+            let analyzer = TypeRelationsAnalyzer::new(&*graph);
+            let hierarchy = analyzer.find_type_hierarchy("type:Base");
 
-        assert!(hierarchy.root.is_some());
-        assert_eq!(hierarchy.parents.len(), 1); // Interface
-        assert_eq!(hierarchy.children.len(), 2); // Derived1, Derived2
+            assert!(hierarchy.root.is_some());
+            assert_eq!(hierarchy.parents.len(), 1); // Interface
+            assert_eq!(hierarchy.children.len(), 2); // Derived1, Derived2
+        }
     }
 
     #[test]
@@ -614,12 +589,15 @@ mod tests {
         // Field of MyType
         graph.add_edge(field_idx, type_idx, EdgeKind::Reference);
 
-        let analyzer = TypeRelationsAnalyzer::new(&graph);
-        let groups = analyzer.group_relations_by_type("type:MyType");
+        for backend in GraphBackend::ALL {
+            let graph = backend.load(graph.clone());
+            let analyzer = TypeRelationsAnalyzer::new(&*graph);
+            let groups = analyzer.group_relations_by_type("type:MyType");
 
-        assert!(!groups.referenced_by.is_empty());
-        assert_eq!(groups.variables_of_type.len(), 1);
-        assert_eq!(groups.functions_returning_type.len(), 1);
-        assert_eq!(groups.fields_of_type.len(), 1);
+            assert!(!groups.referenced_by.is_empty());
+            assert_eq!(groups.variables_of_type.len(), 1);
+            assert_eq!(groups.functions_returning_type.len(), 1);
+            assert_eq!(groups.fields_of_type.len(), 1);
+        }
     }
 }
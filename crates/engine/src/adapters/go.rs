@@ -1,8 +1,8 @@
 //! Go language adapter
 
-use super::{LanguageAdapter, ParsedQuery};
+use super::{indexed_parent_scope, LanguageAdapter, ParsedQuery};
 use anyhow::Result;
-use lsif_core::{Symbol, SymbolKind};
+use lsif_core::{Symbol, SymbolKind, SymbolModifiers, Visibility};
 
 pub struct GoAdapter;
 
@@ -24,6 +24,9 @@ impl LanguageAdapter for GoAdapter {
     }
 
     fn is_public(&self, symbol: &Symbol) -> bool {
+        if let Some(visibility) = symbol.modifiers.visibility() {
+            return visibility == Visibility::Public;
+        }
         // Go convention: uppercase first letter means public
         symbol.name.chars().next().is_some_and(|c| c.is_uppercase())
     }
@@ -89,13 +92,17 @@ impl LanguageAdapter for GoAdapter {
     }
 
     fn is_test(&self, symbol: &Symbol) -> bool {
-        symbol.name.starts_with("Test")
+        symbol.modifiers.contains(SymbolModifiers::TEST)
+            || symbol.name.starts_with("Test")
             || symbol.name.starts_with("Benchmark")
             || symbol.name.starts_with("Example")
             || symbol.file_path.ends_with("_test.go")
     }
 
     fn get_parent_scope(&self, symbol: &Symbol) -> Option<String> {
+        if let Some(scope) = indexed_parent_scope(symbol) {
+            return Some(scope);
+        }
         // Check for receiver in method
         if let Some(detail) = &symbol.detail {
             if detail.contains("func (") {
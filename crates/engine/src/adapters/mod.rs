@@ -11,7 +11,7 @@ pub use rust::RustAdapter;
 pub use typescript::TypeScriptAdapter;
 
 use anyhow::Result;
-use lsif_core::{Symbol, SymbolKind};
+use lsif_core::{split_qualified_name, Symbol, SymbolKind};
 
 /// Language-specific search adapter trait
 pub trait LanguageAdapter {
@@ -34,12 +34,21 @@ pub trait LanguageAdapter {
     fn is_test(&self, symbol: &Symbol) -> bool;
 
     /// Get the parent scope of a symbol
+    ///
+    /// Implementations should prefer [`indexed_parent_scope`] and only fall back to
+    /// parsing the symbol detail for indexes built without qualified names.
     fn get_parent_scope(&self, symbol: &Symbol) -> Option<String>;
 
     /// Score relevance for language-specific patterns
     fn score_relevance(&self, symbol: &Symbol, query: &str) -> f32;
 }
 
+/// Parent scope taken from the qualified name computed at index time
+pub fn indexed_parent_scope(symbol: &Symbol) -> Option<String> {
+    let qualified = symbol.qualified_name.as_deref()?;
+    split_qualified_name(qualified).map(|(scope, _)| scope.to_string())
+}
+
 /// Parsed language-specific query
 #[derive(Debug, Clone)]
 pub struct ParsedQuery {
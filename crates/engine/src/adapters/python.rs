@@ -1,8 +1,8 @@
 //! Python language adapter
 
-use super::{LanguageAdapter, ParsedQuery};
+use super::{indexed_parent_scope, LanguageAdapter, ParsedQuery};
 use anyhow::Result;
-use lsif_core::{Symbol, SymbolKind};
+use lsif_core::{Symbol, SymbolKind, SymbolModifiers, Visibility};
 
 pub struct PythonAdapter;
 
@@ -24,6 +24,9 @@ impl LanguageAdapter for PythonAdapter {
     }
 
     fn is_public(&self, symbol: &Symbol) -> bool {
+        if let Some(visibility) = symbol.modifiers.visibility() {
+            return visibility == Visibility::Public;
+        }
         // Python convention: _ prefix means private
         !symbol.name.starts_with('_') || symbol.name.starts_with("__init__")
     }
@@ -88,7 +91,8 @@ impl LanguageAdapter for PythonAdapter {
     }
 
     fn is_test(&self, symbol: &Symbol) -> bool {
-        symbol.name.starts_with("test_")
+        symbol.modifiers.contains(SymbolModifiers::TEST)
+            || symbol.name.starts_with("test_")
             || symbol.file_path.contains("test_")
             || symbol.file_path.contains("tests/")
             || symbol
@@ -98,6 +102,9 @@ impl LanguageAdapter for PythonAdapter {
     }
 
     fn get_parent_scope(&self, symbol: &Symbol) -> Option<String> {
+        if let Some(scope) = indexed_parent_scope(symbol) {
+            return Some(scope);
+        }
         if let Some(detail) = &symbol.detail {
             if detail.contains("class ") {
                 let class_part = detail.split("class ").nth(1)?;
@@ -1,8 +1,8 @@
 //! Rust language adapter
 
-use super::{LanguageAdapter, ParsedQuery};
+use super::{indexed_parent_scope, LanguageAdapter, ParsedQuery};
 use anyhow::Result;
-use lsif_core::{Symbol, SymbolKind};
+use lsif_core::{Symbol, SymbolKind, SymbolModifiers, Visibility};
 
 pub struct RustAdapter;
 
@@ -24,6 +24,9 @@ impl LanguageAdapter for RustAdapter {
     }
 
     fn is_public(&self, symbol: &Symbol) -> bool {
+        if let Some(visibility) = symbol.modifiers.visibility() {
+            return visibility == Visibility::Public;
+        }
         // Check if symbol is public
         !symbol.name.starts_with("_")
             && !symbol
@@ -117,10 +120,11 @@ impl LanguageAdapter for RustAdapter {
 
     fn is_test(&self, symbol: &Symbol) -> bool {
         // Check if symbol is a test function
-        symbol
-            .detail
-            .as_ref()
-            .is_some_and(|d| d.contains("#[test]") || d.contains("#[cfg(test)]"))
+        symbol.modifiers.contains(SymbolModifiers::TEST)
+            || symbol
+                .detail
+                .as_ref()
+                .is_some_and(|d| d.contains("#[test]") || d.contains("#[cfg(test)]"))
             || symbol.name.starts_with("test_")
             || symbol.name.ends_with("_test")
             || symbol.file_path.contains("/tests/")
@@ -128,6 +132,9 @@ impl LanguageAdapter for RustAdapter {
     }
 
     fn get_parent_scope(&self, symbol: &Symbol) -> Option<String> {
+        if let Some(scope) = indexed_parent_scope(symbol) {
+            return Some(scope);
+        }
         // Extract module path from symbol
         if let Some(detail) = &symbol.detail {
             if detail.contains("impl") {
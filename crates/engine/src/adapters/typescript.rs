@@ -1,8 +1,8 @@
 //! TypeScript/JavaScript language adapter
 
-use super::{LanguageAdapter, ParsedQuery};
+use super::{indexed_parent_scope, LanguageAdapter, ParsedQuery};
 use anyhow::Result;
-use lsif_core::{Symbol, SymbolKind};
+use lsif_core::{Symbol, SymbolKind, SymbolModifiers, Visibility};
 
 pub struct TypeScriptAdapter;
 
@@ -24,6 +24,9 @@ impl LanguageAdapter for TypeScriptAdapter {
     }
 
     fn is_public(&self, symbol: &Symbol) -> bool {
+        if let Some(visibility) = symbol.modifiers.visibility() {
+            return visibility == Visibility::Public;
+        }
         // Check if symbol is exported
         symbol.detail.as_ref().is_none_or(|d| {
             d.contains("export")
@@ -134,7 +137,8 @@ impl LanguageAdapter for TypeScriptAdapter {
 
     fn is_test(&self, symbol: &Symbol) -> bool {
         // Check if symbol is a test
-        symbol.name.contains("test")
+        symbol.modifiers.contains(SymbolModifiers::TEST)
+            || symbol.name.contains("test")
             || symbol.name.contains("spec")
             || symbol.file_path.contains(".test.")
             || symbol.file_path.contains(".spec.")
@@ -148,6 +152,9 @@ impl LanguageAdapter for TypeScriptAdapter {
     }
 
     fn get_parent_scope(&self, symbol: &Symbol) -> Option<String> {
+        if let Some(scope) = indexed_parent_scope(symbol) {
+            return Some(scope);
+        }
         // Extract class or namespace from symbol
         if let Some(detail) = &symbol.detail {
             // Look for class membership
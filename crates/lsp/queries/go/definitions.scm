@@ -0,0 +1,2 @@
+(short_var_declaration left: (expression_list (identifier) @definition))
+(var_spec name: (identifier) @definition)
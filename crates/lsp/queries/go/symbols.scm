@@ -0,0 +1,6 @@
+; シンボル定義（キャプチャ名がシンボル種別になる）
+(function_declaration name: (identifier) @function)
+(method_declaration name: (field_identifier) @method)
+(type_declaration (type_spec name: (type_identifier) @type))
+(const_declaration (const_spec name: (identifier) @constant))
+(var_declaration (var_spec name: (identifier) @variable))
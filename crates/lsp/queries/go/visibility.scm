@@ -0,0 +1 @@
+; Goは大文字小文字で判断する
@@ -0,0 +1,2 @@
+(assignment left: (identifier) @definition)
+(parameter (identifier) @parameter)
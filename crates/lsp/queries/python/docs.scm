@@ -0,0 +1 @@
+; Pythonはdocstringを使う（コメントはドキュメントとみなさない）
@@ -0,0 +1,2 @@
+(identifier) @reference
+(attribute) @attribute_access
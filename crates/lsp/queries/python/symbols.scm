@@ -0,0 +1,5 @@
+; シンボル定義（キャプチャ名がシンボル種別になる）
+(function_definition name: (identifier) @function)
+(class_definition name: (identifier) @class)
+(assignment left: (identifier) @variable)
+(decorated_definition) @decorated
@@ -0,0 +1 @@
+; Pythonは命名規則で判断する
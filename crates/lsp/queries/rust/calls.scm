@@ -0,0 +1,4 @@
+(call_expression function: (identifier) @call)
+(call_expression function: (scoped_identifier name: (identifier) @call))
+(call_expression function: (field_expression field: (field_identifier) @call))
+(macro_invocation macro: (identifier) @macro_call)
@@ -0,0 +1,2 @@
+(let_declaration pattern: (identifier) @definition)
+(parameter pattern: (identifier) @definition)
@@ -0,0 +1,3 @@
+; 定義の直前にあればドキュメントとみなすコメント
+((line_comment) @doc (#match? @doc "^///"))
+((block_comment) @doc (#match? @doc "^/\\*\\*"))
@@ -0,0 +1,2 @@
+(identifier) @reference
+(type_identifier) @type_reference
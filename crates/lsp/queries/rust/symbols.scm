@@ -0,0 +1,10 @@
+; シンボル定義（キャプチャ名がシンボル種別になる）
+(function_item name: (identifier) @function)
+(struct_item name: (type_identifier) @struct)
+(enum_item name: (type_identifier) @enum)
+(trait_item name: (type_identifier) @trait)
+(impl_item type: (type_identifier) @impl)
+(mod_item name: (identifier) @module)
+(const_item name: (identifier) @constant)
+(static_item name: (identifier) @static)
+(type_alias name: (type_identifier) @type_alias)
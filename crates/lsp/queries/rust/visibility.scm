@@ -0,0 +1 @@
+(visibility_modifier) @visibility
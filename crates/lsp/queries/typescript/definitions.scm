@@ -0,0 +1,2 @@
+(variable_declarator name: (identifier) @definition)
+(formal_parameters (required_parameter pattern: (identifier) @parameter))
@@ -0,0 +1,2 @@
+; 定義の直前にあればドキュメントとみなすコメント
+((comment) @doc (#match? @doc "^/\\*\\*"))
@@ -0,0 +1,5 @@
+(import_statement source: (string) @import)
+((call_expression
+   function: (identifier) @_require
+   arguments: (arguments (string) @import))
+ (#eq? @_require "require"))
@@ -0,0 +1,8 @@
+; シンボル定義（キャプチャ名がシンボル種別になる）
+(function_declaration name: (identifier) @function)
+(class_declaration name: (type_identifier) @class)
+(interface_declaration name: (type_identifier) @interface)
+(enum_declaration name: (identifier) @enum)
+(type_alias_declaration name: (type_identifier) @type_alias)
+(variable_declarator name: (identifier) @variable)
+(method_definition name: (property_identifier) @method)
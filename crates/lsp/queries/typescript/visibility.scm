@@ -0,0 +1,2 @@
+(export_statement) @export
+(accessibility_modifier) @access
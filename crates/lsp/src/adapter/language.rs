@@ -306,20 +306,22 @@ fn generic_is_definition_context(
     position: usize,
     patterns: &[DefinitionPattern],
 ) -> bool {
+    // positionはUTF-8のバイト位置（文字の途中なら直前の文字境界に丸める）
+    let mut end = position.min(line.len());
+    while !line.is_char_boundary(end) {
+        end -= 1;
+    }
+    // 位置より前の部分を取得
+    let before = &line[..end];
+
     // 現在位置が単語の先頭かを確認
-    if position > 0 {
-        let prev_char = line.chars().nth(position - 1);
-        if let Some(ch) = prev_char {
-            if ch.is_alphanumeric() || ch == '_' {
-                // 単語の途中なので定義ではない
-                return false;
-            }
+    if let Some(ch) = before.chars().next_back() {
+        if ch.is_alphanumeric() || ch == '_' {
+            // 単語の途中なので定義ではない
+            return false;
         }
     }
 
-    // 位置より前の部分を取得
-    let before = &line[..position.min(line.len())];
-
     // 前方の単語列を取得
     let words: Vec<&str> = before.split_whitespace().collect();
     if words.is_empty() {
@@ -456,6 +458,18 @@ mod tests {
         assert!(!patterns.is_empty());
     }
 
+    #[test]
+    fn test_definition_context_with_multibyte_prefix() {
+        let adapter = RustLanguageAdapter;
+        // positionはバイト位置（"é"は2バイト）
+        let line = "let é = 1; fn foo() {}";
+        let position = line.find("foo").unwrap();
+        assert!(adapter.is_definition_context(line, position));
+        assert!(!adapter.is_definition_context(line, position + 1));
+        // 文字の途中でもパニックしない
+        adapter.is_definition_context(line, 5);
+    }
+
     #[test]
     fn test_detect_language() {
         assert!(detect_language_adapter("main.rs").is_some());
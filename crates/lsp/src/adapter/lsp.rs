@@ -1,12 +1,15 @@
 use crate::lsp_health_check::{LspHealthChecker, LspOperationType, LspStartupValidator};
+use crate::lsp_timing_stats::LspTimingStats;
+use crate::lsp_trace::{LspTraceEvent, LspTracer, TraceDirection, TraceKind};
 use crate::timeout_predictor::TimeoutPredictor;
 use anyhow::{anyhow, Result};
+use lsif_core::{LineIndex, PositionEncoding};
 use lsp_types::{
-    ClientCapabilities, ClientInfo, DidOpenTextDocumentParams, DocumentSymbol,
-    DocumentSymbolParams, GotoDefinitionParams, GotoDefinitionResponse, InitializeParams,
-    InitializeResult, InitializedParams, Location, PartialResultParams, ReferenceParams,
-    SymbolInformation, TextDocumentIdentifier, TextDocumentItem, Url, WorkDoneProgressParams,
-    WorkspaceFolder,
+    ClientCapabilities, ClientInfo, DidOpenTextDocumentParams, DocumentHighlight,
+    DocumentHighlightParams, DocumentSymbol, DocumentSymbolParams, GotoDefinitionParams,
+    GotoDefinitionResponse, InitializeParams, InitializeResult, InitializedParams, Location,
+    PartialResultParams, ReferenceParams, SymbolInformation, TextDocumentIdentifier,
+    TextDocumentItem, Url, WorkDoneProgressParams, WorkspaceFolder,
 };
 use serde::{Deserialize, Serialize};
 use std::io::{BufRead, BufReader, BufWriter, Write};
@@ -52,6 +55,7 @@ pub trait LspAdapter {
                     }),
                     ..Default::default()
                 }),
+                general: Some(general_client_capabilities()),
                 ..Default::default()
             },
             trace: Some(lsp_types::TraceValue::Off),
@@ -119,6 +123,8 @@ pub struct GenericLspClient {
     health_checker: LspHealthChecker,
     /// LSPサーバーのCapabilities
     server_capabilities: Option<lsp_types::ServerCapabilities>,
+    /// LSPサーバーの名前とバージョン
+    server_info: Option<lsp_types::ServerInfo>,
 }
 
 impl GenericLspClient {
@@ -151,6 +157,7 @@ impl GenericLspClient {
             timeout_predictor: TimeoutPredictor::new(),
             health_checker: LspHealthChecker::new(),
             server_capabilities: None,
+            server_info: None,
         };
 
         // 初期化前にプロセスが生きているか再確認
@@ -209,6 +216,7 @@ impl GenericLspClient {
 
         // サーバーのCapabilitiesを保存
         self.server_capabilities = Some(response.capabilities.clone());
+        self.server_info = response.server_info.clone();
 
         // 言語固有の最適化を適用
         self.optimize_for_language();
@@ -376,6 +384,7 @@ impl GenericLspClient {
         });
 
         capabilities.workspace = Some(workspace);
+        capabilities.general = Some(general_client_capabilities());
 
         capabilities
     }
@@ -461,6 +470,37 @@ impl GenericLspClient {
         self.server_capabilities.as_ref()
     }
 
+    /// サーバーと合意した位置エンコーディング（未指定ならLSPのデフォルトのUTF-16）
+    pub fn position_encoding(&self) -> PositionEncoding {
+        self.server_capabilities
+            .as_ref()
+            .map(negotiated_position_encoding)
+            .unwrap_or(PositionEncoding::Utf16)
+    }
+
+    /// サーバー情報（名前とバージョン）を取得
+    pub fn get_server_info(&self) -> Option<&lsp_types::ServerInfo> {
+        self.server_info.as_ref()
+    }
+
+    /// 保存済みの応答時間統計で学習状態を初期化
+    pub fn seed_timing_stats(&mut self, stats: &LspTimingStats) {
+        self.health_checker.seed_from_stats(stats);
+        self.timeout_predictor.seed_from_stats(stats);
+    }
+
+    /// このクライアントが学習した応答時間統計を取得
+    pub fn export_timing_stats(&self) -> LspTimingStats {
+        let mut stats = LspTimingStats::new(&self.language_id);
+        if let Some(info) = &self.server_info {
+            stats.server_name = Some(info.name.clone());
+            stats.server_version = info.version.clone();
+        }
+        self.health_checker.export_stats_into(&mut stats);
+        self.timeout_predictor.export_stats_into(&mut stats);
+        stats
+    }
+
     /// 言語固有の最適化を適用（Capabilitiesに基づく）
     pub fn optimize_for_language(&mut self) {
         use tracing::info;
@@ -546,7 +586,7 @@ impl GenericLspClient {
                     uri: Url::parse(file_uri)?,
                     language_id: self.language_id.clone(),
                     version: 0,
-                    text: content,
+                    text: content.clone(),
                 },
             },
         )?;
@@ -576,11 +616,11 @@ impl GenericLspClient {
             self.health_checker.get_health_status().current_phase
         );
 
-        match response {
-            Some(lsp_types::DocumentSymbolResponse::Nested(symbols)) => Ok(symbols),
+        let mut symbols = match response {
+            Some(lsp_types::DocumentSymbolResponse::Nested(symbols)) => symbols,
             Some(lsp_types::DocumentSymbolResponse::Flat(symbols)) => {
                 // Convert flat symbols to nested format
-                Ok(symbols
+                symbols
                     .into_iter()
                     .map(|s| {
                         #[allow(deprecated)]
@@ -595,10 +635,19 @@ impl GenericLspClient {
                             children: None,
                         }
                     })
-                    .collect())
+                    .collect()
             }
-            None => Ok(Vec::new()),
+            None => Vec::new(),
+        };
+
+        // サーバーのエンコーディングから正規のエンコーディングに変換
+        let encoding = self.position_encoding();
+        if encoding != PositionEncoding::CANONICAL {
+            let index = LineIndex::new(&content);
+            convert_document_symbols(&mut symbols, &index, encoding, PositionEncoding::CANONICAL);
         }
+
+        Ok(symbols)
     }
 
     pub fn find_references(&mut self, params: ReferenceParams) -> Result<Vec<Location>> {
@@ -610,13 +659,27 @@ impl GenericLspClient {
             ));
         }
 
+        let encoding = self.position_encoding();
+        let mut params = params;
+        to_server_position(&mut params.text_document_position, encoding);
+
         let response: Option<Vec<Location>> =
             self.send_request("textDocument/references", params)?;
 
-        Ok(response.unwrap_or_default())
+        let mut locations = response.unwrap_or_default();
+        to_canonical_locations(&mut locations, encoding);
+        Ok(locations)
     }
 
     pub fn goto_definition(&mut self, params: GotoDefinitionParams) -> Result<Location> {
+        self.goto_definitions(params)?
+            .into_iter()
+            .next()
+            .ok_or_else(|| anyhow!("No definition found"))
+    }
+
+    /// 定義の候補をすべて取得（見つからなければ空）
+    pub fn goto_definitions(&mut self, params: GotoDefinitionParams) -> Result<Vec<Location>> {
         // Capabilityをチェック
         if !self.has_capability("textDocument/definition") {
             return Err(anyhow!(
@@ -625,25 +688,68 @@ impl GenericLspClient {
             ));
         }
 
+        let encoding = self.position_encoding();
+        let mut params = params;
+        to_server_position(&mut params.text_document_position_params, encoding);
+
         let response: Option<GotoDefinitionResponse> =
             self.send_request("textDocument/definition", params)?;
 
-        match response {
-            Some(GotoDefinitionResponse::Scalar(location)) => Ok(location),
-            Some(GotoDefinitionResponse::Array(locations)) => locations
-                .into_iter()
-                .next()
-                .ok_or_else(|| anyhow!("No definition found")),
+        let mut locations = match response {
+            Some(GotoDefinitionResponse::Scalar(location)) => vec![location],
+            Some(GotoDefinitionResponse::Array(locations)) => locations,
             Some(GotoDefinitionResponse::Link(links)) => links
                 .into_iter()
-                .next()
                 .map(|link| Location {
                     uri: link.target_uri,
                     range: link.target_selection_range,
                 })
-                .ok_or_else(|| anyhow!("No definition found")),
-            None => Err(anyhow!("No definition found")),
+                .collect(),
+            None => Vec::new(),
+        };
+
+        to_canonical_locations(&mut locations, encoding);
+        Ok(locations)
+    }
+
+    /// ドキュメント内の同じシンボルの出現箇所（読み書きの種類付き）を取得
+    pub fn document_highlights(
+        &mut self,
+        params: DocumentHighlightParams,
+    ) -> Result<Vec<DocumentHighlight>> {
+        if !self.has_capability("textDocument/documentHighlight") {
+            return Err(anyhow!(
+                "LSP server for {} does not support textDocument/documentHighlight",
+                self.language_id
+            ));
         }
+
+        let encoding = self.position_encoding();
+        let mut params = params;
+        to_server_position(&mut params.text_document_position_params, encoding);
+        let uri = params
+            .text_document_position_params
+            .text_document
+            .uri
+            .clone();
+
+        let response: Option<Vec<DocumentHighlight>> =
+            self.send_request("textDocument/documentHighlight", params)?;
+
+        // ハイライトは要求したドキュメントの中の範囲
+        let mut highlights = response.unwrap_or_default();
+        let mut locations: Vec<Location> = highlights
+            .iter()
+            .map(|highlight| Location {
+                uri: uri.clone(),
+                range: highlight.range,
+            })
+            .collect();
+        to_canonical_locations(&mut locations, encoding);
+        for (highlight, location) in highlights.iter_mut().zip(locations) {
+            highlight.range = location.range;
+        }
+        Ok(highlights)
     }
 
     /// ワークスペースシンボルを検索
@@ -656,8 +762,15 @@ impl GenericLspClient {
             partial_result_params: PartialResultParams::default(),
         };
 
-        self.send_request::<_, Option<Vec<SymbolInformation>>>("workspace/symbol", params)?
-            .ok_or_else(|| anyhow!("No workspace symbols found"))
+        let mut symbols = self
+            .send_request::<_, Option<Vec<SymbolInformation>>>("workspace/symbol", params)?
+            .ok_or_else(|| anyhow!("No workspace symbols found"))?;
+        // documentSymbolと同じく正規のエンコーディングの列で返す
+        to_canonical_locations(
+            symbols.iter_mut().map(|symbol| &mut symbol.location),
+            self.position_encoding(),
+        );
+        Ok(symbols)
     }
 
     pub fn send_request<P: Serialize, R: for<'de> Deserialize<'de>>(
@@ -696,11 +809,30 @@ impl GenericLspClient {
         self.writer.write_all(request_str.as_bytes())?;
         self.writer.flush()?;
 
+        if let Some(tracer) = LspTracer::global() {
+            tracer.record(
+                &self
+                    .trace_event(TraceDirection::Send, TraceKind::Request)
+                    .with_method(method)
+                    .with_id(self.request_id)
+                    .with_payload(serde_json::to_value(&request.params).ok()),
+            );
+        }
+
         // Read response with timeout
         let start = Instant::now();
         loop {
             let elapsed = start.elapsed();
             if elapsed > timeout {
+                if let Some(tracer) = LspTracer::global() {
+                    tracer.record(
+                        &self
+                            .trace_event(TraceDirection::Send, TraceKind::Timeout)
+                            .with_method(method)
+                            .with_id(self.request_id)
+                            .with_latency(elapsed),
+                    );
+                }
                 return Err(anyhow!(
                     "LSP request '{}' timed out after {:?}",
                     method,
@@ -717,12 +849,36 @@ impl GenericLspClient {
                         self.health_checker.record_response_time(response_time);
                         debug!("LSP request '{}' completed in {:?}", method, response_time);
 
+                        if let Some(tracer) = LspTracer::global() {
+                            let mut event = self
+                                .trace_event(TraceDirection::Receive, TraceKind::Response)
+                                .with_method(method)
+                                .with_id(self.request_id)
+                                .with_latency(response_time)
+                                .with_error(response.get("error").cloned())
+                                .with_payload(response.get("result").cloned());
+                            // initializeの応答でサーバー情報が初めて分かる
+                            if event.server.is_none() {
+                                event.server = server_label(
+                                    serde_json::from_value(
+                                        response["result"]["serverInfo"].clone(),
+                                    )
+                                    .ok()
+                                    .as_ref(),
+                                );
+                            }
+                            tracer.record(&event);
+                        }
+
                         if let Some(error) = response.get("error") {
                             return Err(anyhow!("LSP error: {:?}", error));
                         }
                         if let Some(result) = response.get("result") {
                             return Ok(serde_json::from_value(result.clone())?);
                         }
+                    } else if let Some(tracer) = LspTracer::global() {
+                        // サーバーからの通知・リクエスト、または古い応答
+                        tracer.record(&self.trace_incoming(&response));
                     }
                 }
                 Ok(None) => {
@@ -751,9 +907,49 @@ impl GenericLspClient {
         self.writer.write_all(notification_str.as_bytes())?;
         self.writer.flush()?;
 
+        if let Some(tracer) = LspTracer::global() {
+            tracer.record(
+                &self
+                    .trace_event(TraceDirection::Send, TraceKind::Notification)
+                    .with_method(method)
+                    .with_payload(serde_json::to_value(&notification.params).ok()),
+            );
+        }
+
         Ok(())
     }
 
+    /// このクライアントの言語・サーバー情報を付けたトレースイベントを作る
+    fn trace_event(&self, direction: TraceDirection, kind: TraceKind) -> LspTraceEvent {
+        LspTraceEvent::new(direction, kind, &self.language_id)
+            .with_server(server_label(self.server_info.as_ref()))
+    }
+
+    /// サーバーから届いた、待っている応答以外のメッセージをトレースイベントにする
+    fn trace_incoming(&self, message: &serde_json::Value) -> LspTraceEvent {
+        let kind = match (message.get("id"), message.get("method")) {
+            (Some(_), Some(_)) => TraceKind::Request,
+            (None, Some(_)) => TraceKind::Notification,
+            _ => TraceKind::Response,
+        };
+        let mut event = self
+            .trace_event(TraceDirection::Receive, kind)
+            .with_error(message.get("error").cloned())
+            .with_payload(
+                message
+                    .get("params")
+                    .or_else(|| message.get("result"))
+                    .cloned(),
+            );
+        if let Some(method) = message["method"].as_str() {
+            event = event.with_method(method);
+        }
+        if let Some(id) = message["id"].as_i64() {
+            event = event.with_id(id);
+        }
+        event
+    }
+
     fn try_read_message(
         &mut self,
         timeout: std::time::Duration,
@@ -822,6 +1018,146 @@ impl GenericLspClient {
         self.child.wait()?;
         Ok(())
     }
+
+    /// サーバーを終了させる（共有されていて所有権を渡せない場合）
+    ///
+    /// `shutdown`と`exit`を送り、`grace`以内に終了しなければkillする。
+    pub fn terminate(&mut self, grace: Duration) {
+        let _ = self.send_request_with_timeout::<_, ()>("shutdown", serde_json::Value::Null, grace);
+        let _ = self.send_notification("exit", serde_json::Value::Null);
+
+        let deadline = std::time::Instant::now() + grace;
+        while std::time::Instant::now() < deadline {
+            match self.child.try_wait() {
+                Ok(Some(_)) | Err(_) => return,
+                Ok(None) => std::thread::sleep(Duration::from_millis(20)),
+            }
+        }
+        debug!(
+            "{} server did not exit in time, killing it",
+            self.language_id
+        );
+        let _ = self.child.kill();
+        let _ = self.child.wait();
+    }
+}
+
+/// クライアントが扱える位置エンコーディング（正規のUTF-8を優先）
+pub(crate) fn general_client_capabilities() -> lsp_types::GeneralClientCapabilities {
+    lsp_types::GeneralClientCapabilities {
+        position_encodings: Some(vec![
+            lsp_types::PositionEncodingKind::UTF8,
+            lsp_types::PositionEncodingKind::UTF16,
+        ]),
+        ..Default::default()
+    }
+}
+
+/// サーバーが選んだ位置エンコーディング（未指定ならLSPのデフォルトのUTF-16）
+pub fn negotiated_position_encoding(caps: &lsp_types::ServerCapabilities) -> PositionEncoding {
+    caps.position_encoding
+        .as_ref()
+        .and_then(|kind| PositionEncoding::from_lsp_str(kind.as_str()))
+        .unwrap_or(PositionEncoding::Utf16)
+}
+
+/// LSPの範囲をエンコーディング間で変換
+pub fn convert_lsp_range(
+    range: lsp_types::Range,
+    index: &LineIndex,
+    from: PositionEncoding,
+    to: PositionEncoding,
+) -> lsp_types::Range {
+    let convert = |pos: lsp_types::Position| lsp_types::Position {
+        line: pos.line,
+        character: lsif_core::convert_column(index.line(pos.line), pos.character, from, to),
+    };
+    lsp_types::Range {
+        start: convert(range.start),
+        end: convert(range.end),
+    }
+}
+
+/// DocumentSymbol（子を含む）の範囲をエンコーディング間で変換
+pub fn convert_document_symbols(
+    symbols: &mut [DocumentSymbol],
+    index: &LineIndex,
+    from: PositionEncoding,
+    to: PositionEncoding,
+) {
+    for symbol in symbols {
+        symbol.range = convert_lsp_range(symbol.range, index, from, to);
+        symbol.selection_range = convert_lsp_range(symbol.selection_range, index, from, to);
+        if let Some(children) = symbol.children.as_mut() {
+            convert_document_symbols(children, index, from, to);
+        }
+    }
+}
+
+/// URIのファイル内容を読む（ローカルファイル以外はNone）
+fn read_uri_text(uri: &Url) -> Option<String> {
+    uri.to_file_path()
+        .ok()
+        .and_then(|path| std::fs::read_to_string(path).ok())
+}
+
+/// 正規のエンコーディングの位置をサーバーのエンコーディングに変換
+pub(crate) fn to_server_position(
+    params: &mut lsp_types::TextDocumentPositionParams,
+    encoding: PositionEncoding,
+) {
+    if encoding == PositionEncoding::CANONICAL {
+        return;
+    }
+    if let Some(text) = read_uri_text(&params.text_document.uri) {
+        let line = LineIndex::new(&text).line(params.position.line);
+        params.position.character = lsif_core::convert_column(
+            line,
+            params.position.character,
+            PositionEncoding::CANONICAL,
+            encoding,
+        );
+    }
+}
+
+/// サーバーが返した位置を正規のエンコーディングに変換（ファイルごとに1回だけ読む）
+pub(crate) fn to_canonical_locations<'a>(
+    locations: impl IntoIterator<Item = &'a mut Location>,
+    encoding: PositionEncoding,
+) {
+    if encoding == PositionEncoding::CANONICAL {
+        return;
+    }
+
+    let locations: Vec<&mut Location> = locations.into_iter().collect();
+
+    // ドキュメントごとに1回だけ読み込み、行インデックスも共有する
+    let mut texts: std::collections::HashMap<Url, Option<String>> =
+        std::collections::HashMap::new();
+    for location in &locations {
+        texts
+            .entry(location.uri.clone())
+            .or_insert_with(|| read_uri_text(&location.uri));
+    }
+    let indexes: std::collections::HashMap<&Url, LineIndex> = texts
+        .iter()
+        .filter_map(|(uri, text)| text.as_deref().map(|text| (uri, LineIndex::new(text))))
+        .collect();
+
+    for location in locations {
+        if let Some(index) = indexes.get(&location.uri) {
+            location.range =
+                convert_lsp_range(location.range, index, encoding, PositionEncoding::CANONICAL);
+        }
+    }
+}
+
+/// トレース用のサーバー表記（名前@バージョン）
+fn server_label(info: Option<&lsp_types::ServerInfo>) -> Option<String> {
+    info.map(|info| match &info.version {
+        Some(version) => format!("{}@{}", info.name, version),
+        None => info.name.clone(),
+    })
 }
 
 #[derive(Serialize)]
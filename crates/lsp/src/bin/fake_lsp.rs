@@ -0,0 +1,25 @@
+//! シナリオ駆動のフェイクLSPサーバー
+//!
+//! 使い方: `fake_lsp <scenario.json>`（標準入出力でLSPを話す）
+use anyhow::{anyhow, Result};
+use lsp::fake_lsp_server::{FakeLspExit, FakeLspScenario, FakeLspServer};
+use std::io::{stdin, stdout, BufReader};
+use std::path::PathBuf;
+
+fn main() -> Result<()> {
+    let scenario_path = std::env::args()
+        .nth(1)
+        .map(PathBuf::from)
+        .ok_or_else(|| anyhow!("Usage: fake_lsp <scenario.json>"))?;
+
+    let scenario = FakeLspScenario::load(&scenario_path)?;
+    let mut server = FakeLspServer::new(scenario);
+
+    let mut reader = BufReader::new(stdin().lock());
+    let mut writer = stdout().lock();
+
+    match server.run(&mut reader, &mut writer)? {
+        FakeLspExit::Exit | FakeLspExit::Eof => Ok(()),
+        FakeLspExit::Crash(code) => std::process::exit(code),
+    }
+}
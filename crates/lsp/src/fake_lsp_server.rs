@@ -0,0 +1,567 @@
+/// シナリオ駆動のフェイクLSPサーバー
+///
+/// 実際の言語サーバーをインストールせずに、プール・ヘルスチェック・
+/// タイムアウト予測・自動切り替えのロジックを決定的にテストするためのもの。
+/// シナリオ（JSON）でCapabilities、定型レスポンス、遅延、不正メッセージ、
+/// N回目のリクエストでのクラッシュ、進捗通知を宣言する。
+use anyhow::{anyhow, Context, Result};
+use serde::{Deserialize, Serialize};
+use serde_json::Value;
+use std::collections::HashMap;
+use std::io::{BufRead, Write};
+use std::path::{Path, PathBuf};
+use std::process::{Child, Command, Stdio};
+use std::time::Duration;
+
+use crate::adapter::lsp::LspAdapter;
+
+/// フェイクサーバーのシナリオ定義
+#[derive(Debug, Clone, Default, Serialize, Deserialize)]
+#[serde(default)]
+pub struct FakeLspScenario {
+    /// initializeで返すServerCapabilities（生のJSON）
+    pub capabilities: Value,
+    /// initializeで返すサーバー情報
+    pub server_info: Option<FakeServerInfo>,
+    /// メソッドごとの定型レスポンス（上から順にマッチ）
+    pub responses: Vec<CannedResponse>,
+    /// 定型レスポンスがないリクエストに適用する遅延
+    pub default_delay_ms: u64,
+    /// 起動直後（最初のメッセージを読む前）の遅延
+    pub startup_delay_ms: u64,
+    /// N件のリクエストを処理した後にクラッシュする
+    pub crash_after_requests: Option<usize>,
+    /// クラッシュ時の終了コード
+    pub crash_exit_code: i32,
+    /// `initialized`通知の後に送る進捗通知
+    pub progress: Vec<ProgressScript>,
+    /// 未知のメソッドにMethodNotFoundを返す（falseならnullを返す）
+    pub strict: bool,
+}
+
+/// initializeレスポンスのserverInfo
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct FakeServerInfo {
+    pub name: String,
+    pub version: Option<String>,
+}
+
+/// 定型レスポンス
+#[derive(Debug, Clone, Default, Serialize, Deserialize)]
+#[serde(default)]
+pub struct CannedResponse {
+    /// 対象のメソッド名
+    pub method: String,
+    /// 返す結果
+    pub result: Option<Value>,
+    /// 返すエラー（resultより優先）
+    pub error: Option<CannedError>,
+    /// 応答前の人工的な遅延
+    pub delay_ms: u64,
+    /// 正しいメッセージの代わりに書き出す生バイト列
+    pub malformed: Option<String>,
+    /// 応答しない（ハングのシミュレーション）
+    pub no_response: bool,
+    /// このルールを適用する回数（Noneなら無制限）
+    pub times: Option<usize>,
+}
+
+/// JSON-RPCエラー
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct CannedError {
+    pub code: i64,
+    pub message: String,
+}
+
+/// 進捗通知のスクリプト
+#[derive(Debug, Clone, Default, Serialize, Deserialize)]
+#[serde(default)]
+pub struct ProgressScript {
+    pub token: String,
+    pub title: String,
+    /// reportの回数
+    pub steps: u32,
+    /// 各通知の間隔
+    pub delay_ms: u64,
+}
+
+/// サーバーの終了理由
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub enum FakeLspExit {
+    /// `exit`通知による正常終了
+    Exit,
+    /// 入力のEOF
+    Eof,
+    /// シナリオによるクラッシュ
+    Crash(i32),
+}
+
+impl FakeLspScenario {
+    /// JSONファイルからシナリオを読み込む
+    pub fn load(path: &Path) -> Result<Self> {
+        let content = std::fs::read_to_string(path)
+            .with_context(|| format!("Failed to read scenario: {}", path.display()))?;
+        serde_json::from_str(&content)
+            .with_context(|| format!("Invalid scenario file: {}", path.display()))
+    }
+
+    /// シナリオをJSONファイルに書き出す
+    pub fn save(&self, path: &Path) -> Result<()> {
+        std::fs::write(path, serde_json::to_string_pretty(self)?)?;
+        Ok(())
+    }
+
+    /// 定型レスポンスを追加（ビルダー）
+    pub fn with_response(mut self, response: CannedResponse) -> Self {
+        self.responses.push(response);
+        self
+    }
+
+    /// Capabilitiesを設定（ビルダー）
+    pub fn with_capabilities(mut self, capabilities: Value) -> Self {
+        self.capabilities = capabilities;
+        self
+    }
+
+    /// クラッシュまでのリクエスト数を設定（ビルダー）
+    pub fn with_crash_after(mut self, requests: usize) -> Self {
+        self.crash_after_requests = Some(requests);
+        self
+    }
+}
+
+impl CannedResponse {
+    /// 結果を返すレスポンス
+    pub fn result(method: &str, result: Value) -> Self {
+        Self {
+            method: method.to_string(),
+            result: Some(result),
+            ..Default::default()
+        }
+    }
+
+    /// エラーを返すレスポンス
+    pub fn error(method: &str, code: i64, message: &str) -> Self {
+        Self {
+            method: method.to_string(),
+            error: Some(CannedError {
+                code,
+                message: message.to_string(),
+            }),
+            ..Default::default()
+        }
+    }
+
+    /// 応答しない（ハングする）レスポンス
+    pub fn hang(method: &str) -> Self {
+        Self {
+            method: method.to_string(),
+            no_response: true,
+            ..Default::default()
+        }
+    }
+
+    /// 遅延を設定
+    pub fn delayed(mut self, delay_ms: u64) -> Self {
+        self.delay_ms = delay_ms;
+        self
+    }
+
+    /// 適用回数を設定
+    pub fn times(mut self, times: usize) -> Self {
+        self.times = Some(times);
+        self
+    }
+}
+
+/// フェイクLSPサーバー本体
+pub struct FakeLspServer {
+    scenario: FakeLspScenario,
+    /// ルールごとの使用回数
+    used: HashMap<usize, usize>,
+    /// 処理したリクエスト数
+    request_count: usize,
+}
+
+impl FakeLspServer {
+    pub fn new(scenario: FakeLspScenario) -> Self {
+        Self {
+            scenario,
+            used: HashMap::new(),
+            request_count: 0,
+        }
+    }
+
+    /// 処理したリクエスト数
+    pub fn request_count(&self) -> usize {
+        self.request_count
+    }
+
+    /// メッセージループを実行
+    pub fn run<R: BufRead, W: Write>(
+        &mut self,
+        reader: &mut R,
+        writer: &mut W,
+    ) -> Result<FakeLspExit> {
+        sleep_ms(self.scenario.startup_delay_ms);
+
+        loop {
+            let message = match read_message(reader)? {
+                Some(message) => message,
+                None => return Ok(FakeLspExit::Eof),
+            };
+
+            let method = message
+                .get("method")
+                .and_then(|m| m.as_str())
+                .unwrap_or_default()
+                .to_string();
+
+            // レスポンス（クライアントからの返信）は無視
+            if method.is_empty() {
+                continue;
+            }
+
+            let id = match message.get("id") {
+                Some(id) => id.clone(),
+                None => {
+                    // 通知
+                    match method.as_str() {
+                        "exit" => return Ok(FakeLspExit::Exit),
+                        "initialized" => self.send_progress(writer)?,
+                        _ => {}
+                    }
+                    continue;
+                }
+            };
+
+            if let Some(limit) = self.scenario.crash_after_requests {
+                if self.request_count >= limit {
+                    return Ok(FakeLspExit::Crash(self.scenario.crash_exit_code));
+                }
+            }
+            self.request_count += 1;
+
+            self.handle_request(&method, id, writer)?;
+        }
+    }
+
+    fn handle_request<W: Write>(&mut self, method: &str, id: Value, writer: &mut W) -> Result<()> {
+        if let Some(canned) = self.next_canned(method) {
+            sleep_ms(canned.delay_ms);
+
+            if canned.no_response {
+                return Ok(());
+            }
+            if let Some(raw) = canned.malformed {
+                writer.write_all(raw.as_bytes())?;
+                writer.flush()?;
+                return Ok(());
+            }
+
+            let response = match canned.error {
+                Some(error) => serde_json::json!({
+                    "jsonrpc": "2.0",
+                    "id": id,
+                    "error": { "code": error.code, "message": error.message },
+                }),
+                None => serde_json::json!({
+                    "jsonrpc": "2.0",
+                    "id": id,
+                    "result": canned.result.unwrap_or(Value::Null),
+                }),
+            };
+            return write_message(writer, &response);
+        }
+
+        sleep_ms(self.scenario.default_delay_ms);
+
+        let response = match method {
+            "initialize" => {
+                let mut result = serde_json::json!({ "capabilities": self.scenario.capabilities });
+                if self.scenario.capabilities.is_null() {
+                    result["capabilities"] = serde_json::json!({});
+                }
+                if let Some(info) = &self.scenario.server_info {
+                    result["serverInfo"] = serde_json::json!({
+                        "name": info.name,
+                        "version": info.version,
+                    });
+                }
+                serde_json::json!({ "jsonrpc": "2.0", "id": id, "result": result })
+            }
+            "shutdown" => serde_json::json!({ "jsonrpc": "2.0", "id": id, "result": null }),
+            _ if self.scenario.strict => serde_json::json!({
+                "jsonrpc": "2.0",
+                "id": id,
+                "error": { "code": -32601, "message": format!("Method not found: {}", method) },
+            }),
+            _ => serde_json::json!({ "jsonrpc": "2.0", "id": id, "result": null }),
+        };
+
+        write_message(writer, &response)
+    }
+
+    /// メソッドにマッチする、使用回数が残っている最初のルールを取得
+    fn next_canned(&mut self, method: &str) -> Option<CannedResponse> {
+        let index = self
+            .scenario
+            .responses
+            .iter()
+            .enumerate()
+            .position(|(i, r)| {
+                r.method == method
+                    && r.times
+                        .map(|times| self.used.get(&i).copied().unwrap_or(0) < times)
+                        .unwrap_or(true)
+            })?;
+
+        *self.used.entry(index).or_insert(0) += 1;
+        Some(self.scenario.responses[index].clone())
+    }
+
+    fn send_progress<W: Write>(&self, writer: &mut W) -> Result<()> {
+        for script in &self.scenario.progress {
+            let begin = serde_json::json!({
+                "jsonrpc": "2.0",
+                "method": "$/progress",
+                "params": {
+                    "token": script.token,
+                    "value": { "kind": "begin", "title": script.title, "percentage": 0 },
+                },
+            });
+            write_message(writer, &begin)?;
+
+            for step in 1..=script.steps {
+                sleep_ms(script.delay_ms);
+                let report = serde_json::json!({
+                    "jsonrpc": "2.0",
+                    "method": "$/progress",
+                    "params": {
+                        "token": script.token,
+                        "value": {
+                            "kind": "report",
+                            "percentage": step * 100 / script.steps.max(1),
+                        },
+                    },
+                });
+                write_message(writer, &report)?;
+            }
+
+            let end = serde_json::json!({
+                "jsonrpc": "2.0",
+                "method": "$/progress",
+                "params": { "token": script.token, "value": { "kind": "end" } },
+            });
+            write_message(writer, &end)?;
+        }
+        Ok(())
+    }
+}
+
+/// フェイクサーバーのバイナリを起動するアダプタ
+pub struct FakeLspAdapter {
+    binary: PathBuf,
+    scenario_path: PathBuf,
+    language_id: String,
+}
+
+impl FakeLspAdapter {
+    pub fn new(binary: impl Into<PathBuf>, scenario_path: impl Into<PathBuf>) -> Self {
+        Self {
+            binary: binary.into(),
+            scenario_path: scenario_path.into(),
+            language_id: "fake".to_string(),
+        }
+    }
+
+    /// 名乗る言語IDを設定（プールのテスト用）
+    pub fn with_language_id(mut self, language_id: &str) -> Self {
+        self.language_id = language_id.to_string();
+        self
+    }
+}
+
+impl LspAdapter for FakeLspAdapter {
+    fn spawn_command(&self) -> Result<Child> {
+        Command::new(&self.binary)
+            .arg(&self.scenario_path)
+            .stdin(Stdio::piped())
+            .stdout(Stdio::piped())
+            .stderr(Stdio::null())
+            .spawn()
+            .map_err(|e| anyhow!("Failed to spawn fake LSP server: {}", e))
+    }
+
+    fn language_id(&self) -> &str {
+        &self.language_id
+    }
+
+    fn supports_workspace_symbol(&self) -> bool {
+        true
+    }
+}
+
+fn sleep_ms(ms: u64) {
+    if ms > 0 {
+        std::thread::sleep(Duration::from_millis(ms));
+    }
+}
+
+/// Content-Lengthヘッダー付きのメッセージを1件読む
+fn read_message<R: BufRead>(reader: &mut R) -> Result<Option<Value>> {
+    let mut content_length = None;
+
+    loop {
+        let mut line = String::new();
+        if reader.read_line(&mut line)? == 0 {
+            return Ok(None);
+        }
+        let line = line.trim_end();
+        if line.is_empty() {
+            break;
+        }
+        if let Some(length) = line.strip_prefix("Content-Length:") {
+            content_length = Some(length.trim().parse::<usize>()?);
+        }
+    }
+
+    let length = content_length.ok_or_else(|| anyhow!("Missing Content-Length header"))?;
+    let mut buffer = vec![0u8; length];
+    reader.read_exact(&mut buffer)?;
+    Ok(Some(serde_json::from_slice(&buffer)?))
+}
+
+fn write_message<W: Write>(writer: &mut W, message: &Value) -> Result<()> {
+    let content = serde_json::to_string(message)?;
+    write!(
+        writer,
+        "Content-Length: {}\r\n\r\n{}",
+        content.len(),
+        content
+    )?;
+    writer.flush()?;
+    Ok(())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::io::Cursor;
+
+    fn frame(message: Value) -> String {
+        let content = serde_json::to_string(&message).unwrap();
+        format!("Content-Length: {}\r\n\r\n{}", content.len(), content)
+    }
+
+    fn request(id: u64, method: &str) -> String {
+        frame(serde_json::json!({ "jsonrpc": "2.0", "id": id, "method": method, "params": {} }))
+    }
+
+    fn notification(method: &str) -> String {
+        frame(serde_json::json!({ "jsonrpc": "2.0", "method": method, "params": {} }))
+    }
+
+    fn run(scenario: FakeLspScenario, input: String) -> (FakeLspExit, Vec<Value>) {
+        let mut server = FakeLspServer::new(scenario);
+        let mut reader = Cursor::new(input.into_bytes());
+        let mut output = Vec::new();
+        let exit = server.run(&mut reader, &mut output).unwrap();
+
+        let mut messages = Vec::new();
+        let mut cursor = Cursor::new(output);
+        while let Ok(Some(message)) = read_message(&mut cursor) {
+            messages.push(message);
+        }
+        (exit, messages)
+    }
+
+    #[test]
+    fn test_initialize_returns_capabilities() {
+        let scenario = FakeLspScenario::default()
+            .with_capabilities(serde_json::json!({ "documentSymbolProvider": true }));
+        let (exit, messages) = run(scenario, request(1, "initialize") + &notification("exit"));
+
+        assert_eq!(exit, FakeLspExit::Exit);
+        assert_eq!(messages.len(), 1);
+        assert_eq!(
+            messages[0]["result"]["capabilities"]["documentSymbolProvider"],
+            true
+        );
+    }
+
+    #[test]
+    fn test_canned_response_times() {
+        let scenario = FakeLspScenario::default()
+            .with_response(
+                CannedResponse::error("textDocument/documentSymbol", -32801, "busy").times(1),
+            )
+            .with_response(CannedResponse::result(
+                "textDocument/documentSymbol",
+                serde_json::json!([]),
+            ));
+        let (_, messages) = run(
+            scenario,
+            request(1, "textDocument/documentSymbol") + &request(2, "textDocument/documentSymbol"),
+        );
+
+        assert_eq!(messages[0]["error"]["code"], -32801);
+        assert_eq!(messages[1]["result"], serde_json::json!([]));
+    }
+
+    #[test]
+    fn test_crash_after_requests() {
+        let scenario = FakeLspScenario {
+            crash_exit_code: 3,
+            ..Default::default()
+        }
+        .with_crash_after(1);
+        let (exit, messages) = run(scenario, request(1, "initialize") + &request(2, "shutdown"));
+
+        assert_eq!(exit, FakeLspExit::Crash(3));
+        assert_eq!(messages.len(), 1);
+    }
+
+    #[test]
+    fn test_progress_after_initialized() {
+        let scenario = FakeLspScenario {
+            progress: vec![ProgressScript {
+                token: "indexing".to_string(),
+                title: "Indexing".to_string(),
+                steps: 2,
+                delay_ms: 0,
+            }],
+            ..Default::default()
+        };
+        let (exit, messages) = run(scenario, notification("initialized"));
+
+        assert_eq!(exit, FakeLspExit::Eof);
+        // begin + report x2 + end
+        assert_eq!(messages.len(), 4);
+        assert!(messages.iter().all(|m| m["method"] == "$/progress"));
+    }
+
+    #[test]
+    fn test_strict_mode_method_not_found() {
+        let scenario = FakeLspScenario {
+            strict: true,
+            ..Default::default()
+        };
+        let (_, messages) = run(scenario, request(1, "textDocument/hover"));
+        assert_eq!(messages[0]["error"]["code"], -32601);
+    }
+
+    #[test]
+    fn test_scenario_roundtrip() {
+        let temp_dir = tempfile::TempDir::new().unwrap();
+        let path = temp_dir.path().join("scenario.json");
+        let scenario = FakeLspScenario::default().with_response(
+            CannedResponse::result("workspace/symbol", serde_json::json!([])).delayed(50),
+        );
+        scenario.save(&path).unwrap();
+
+        let loaded = FakeLspScenario::load(&path).unwrap();
+        assert_eq!(loaded.responses.len(), 1);
+        assert_eq!(loaded.responses[0].delay_ms, 50);
+    }
+}
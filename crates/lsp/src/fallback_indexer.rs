@@ -93,14 +93,22 @@ impl FallbackIndexer {
         let content = std::fs::read_to_string(file_path)?;
         let lines: Vec<&str> = content.lines().collect();
 
-        match self.language {
-            FallbackLanguage::Rust => self.extract_rust_symbols(&lines),
+        let mut symbols = match self.language {
+            FallbackLanguage::Rust => self.extract_rust_symbols(&lines)?,
             FallbackLanguage::TypeScript | FallbackLanguage::JavaScript => {
-                self.extract_typescript_symbols(&lines)
+                self.extract_typescript_symbols(&lines)?
+            }
+            FallbackLanguage::Python => self.extract_python_symbols(&lines)?,
+            FallbackLanguage::Go => self.extract_go_symbols(&lines)?,
+        };
+
+        // 宣言行をdetailに残す（`pub async fn`などの修飾子の判定に使う）
+        for symbol in &mut symbols {
+            if let Some(line) = lines.get(symbol.range.start.line as usize) {
+                symbol.detail = Some(line.trim().to_string());
             }
-            FallbackLanguage::Python => self.extract_python_symbols(&lines),
-            FallbackLanguage::Go => self.extract_go_symbols(&lines),
         }
+        Ok(symbols)
     }
 
     /// Rustのシンボルを抽出
@@ -301,6 +309,8 @@ impl FallbackIndexer {
     }
 
     /// DocumentSymbolを作成するヘルパー関数
+    ///
+    /// 列は`str`のバイト位置（正規のUTF-8）で渡す。
     #[allow(deprecated)]
     fn create_symbol(
         &self,
@@ -415,6 +425,10 @@ trait MyTrait {
         assert!(symbols
             .iter()
             .any(|s| s.name == "MyTrait" && s.kind == SymbolKind::INTERFACE));
+
+        // 宣言行がdetailに入る
+        let new = symbols.iter().find(|s| s.name == "new").unwrap();
+        assert_eq!(new.detail.as_deref(), Some("pub fn new() -> Self {"));
     }
 
     #[test]
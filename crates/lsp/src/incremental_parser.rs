@@ -0,0 +1,343 @@
+//! ファイルの変更をtree-sitterの増分解析で反映する
+//!
+//! 開いているファイルごとに解析済みの木を保持し、テキストの差分から`InputEdit`を作って
+//! 再解析する。変更された領域（を含むトップレベルの定義）のシンボルだけを抽出するので、
+//! 巨大な生成ファイルでも編集から問い合わせまでの遅延が小さい。
+
+use crate::tree_sitter_parser::TreeSitterParser;
+use anyhow::{anyhow, Result};
+use lsif_core::{CodeGraph, RowUpdateStats, Symbol};
+use std::collections::HashMap;
+use std::path::Path;
+use tree_sitter::{InputEdit, Node, Point, Tree};
+
+/// 1回の更新で変わった領域とそのシンボル
+#[derive(Debug, Clone)]
+pub struct ReparsedRegion {
+    pub file_path: String,
+    /// 変更前の行範囲（両端を含む）。Noneなら初回の解析でファイル全体
+    pub old_rows: Option<(u32, u32)>,
+    /// 変更後の行範囲（両端を含む）
+    pub new_rows: Option<(u32, u32)>,
+    /// 変更より後ろの行のずれ
+    pub row_delta: i64,
+    /// 変更後の領域にあるシンボル
+    pub symbols: Vec<Symbol>,
+}
+
+impl ReparsedRegion {
+    /// グラフのシンボルを差し替える（変更のない部分のシンボルとエッジは保つ）
+    pub fn apply(self, graph: &mut CodeGraph) -> RowUpdateStats {
+        graph.replace_symbols_in_rows(&self.file_path, self.old_rows, self.row_delta, self.symbols)
+    }
+}
+
+/// 解析済みのファイル
+struct ParsedFile {
+    extension: String,
+    text: String,
+    tree: Tree,
+}
+
+/// 開いているファイルの木を保持する増分パーサー
+#[derive(Default)]
+pub struct IncrementalParser {
+    /// 拡張子ごとのパーサー（クエリのコンパイルを使い回す）
+    parsers: HashMap<String, TreeSitterParser>,
+    files: HashMap<String, ParsedFile>,
+}
+
+impl IncrementalParser {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    pub fn is_open(&self, file_path: &str) -> bool {
+        self.files.contains_key(file_path)
+    }
+
+    /// 保持している最新の木
+    pub fn tree(&self, file_path: &str) -> Option<&Tree> {
+        self.files.get(file_path).map(|file| &file.tree)
+    }
+
+    /// ファイルの木を破棄
+    pub fn close(&mut self, file_path: &str) -> bool {
+        self.files.remove(file_path).is_some()
+    }
+
+    /// ファイルの新しい内容を反映（内容が同じならNone）
+    pub fn update(&mut self, file_path: &str, text: String) -> Result<Option<ReparsedRegion>> {
+        let extension = Path::new(file_path)
+            .extension()
+            .and_then(|ext| ext.to_str())
+            .unwrap_or_default()
+            .to_string();
+        if !self.parsers.contains_key(&extension) {
+            let parser = TreeSitterParser::from_extension(Path::new(file_path))
+                .ok_or_else(|| anyhow!("Unsupported file for tree-sitter: {}", file_path))?;
+            self.parsers.insert(extension.clone(), parser);
+        }
+        let parser = self.parsers.get_mut(&extension).unwrap();
+
+        let mut file = match self.files.remove(file_path) {
+            Some(file) if file.extension == extension => file,
+            // 初回（または拡張子が変わった）は全体を解析
+            _ => {
+                let tree = parser.parse(&text)?;
+                let symbols = parser.outline_from_tree(&tree, &text, file_path, None);
+                self.files.insert(
+                    file_path.to_string(),
+                    ParsedFile {
+                        extension,
+                        text,
+                        tree,
+                    },
+                );
+                return Ok(Some(ReparsedRegion {
+                    file_path: file_path.to_string(),
+                    old_rows: None,
+                    new_rows: None,
+                    row_delta: 0,
+                    symbols,
+                }));
+            }
+        };
+
+        let edit = match compute_edit(&file.text, &text) {
+            Some(edit) => edit,
+            None => {
+                self.files.insert(file_path.to_string(), file);
+                return Ok(None);
+            }
+        };
+
+        file.tree.edit(&edit);
+        // 失敗した場合、編集済みの古い木は内容と食い違うので保持しない
+        let tree = parser.parse_incremental(&text, Some(&file.tree))?;
+
+        // 編集箇所と構文が変わった範囲を、それを含むトップレベルの定義まで広げる
+        let mut start = (edit.start_byte, edit.start_position);
+        let mut end = (edit.new_end_byte, edit.new_end_position);
+        for changed in file.tree.changed_ranges(&tree) {
+            if changed.start_byte < start.0 {
+                start = (changed.start_byte, changed.start_point);
+            }
+            if changed.end_byte > end.0 {
+                end = (changed.end_byte, changed.end_point);
+            }
+        }
+        let (start, end) = expand_to_top_level(tree.root_node(), start, end);
+
+        let new_rows = (start.1.row as u32, end.1.row as u32);
+        let old_end_row = edit.old_end_position.row as i64;
+        let new_end_row = edit.new_end_position.row as i64;
+        let row_delta = new_end_row - old_end_row;
+        // 領域は編集箇所を含むので、開始行は変更前と同じ、終了行は差分だけずれる
+        let old_rows = (new_rows.0, (new_rows.1 as i64 - row_delta).max(0) as u32);
+
+        let symbols: Vec<Symbol> = parser
+            .outline_from_tree(&tree, &text, file_path, Some(start.0..end.0))
+            .into_iter()
+            .filter(|s| s.range.start.line >= new_rows.0 && s.range.end.line <= new_rows.1)
+            .collect();
+
+        self.files.insert(
+            file_path.to_string(),
+            ParsedFile {
+                extension,
+                text,
+                tree,
+            },
+        );
+
+        Ok(Some(ReparsedRegion {
+            file_path: file_path.to_string(),
+            old_rows: Some(old_rows),
+            new_rows: Some(new_rows),
+            row_delta,
+            symbols,
+        }))
+    }
+}
+
+/// 変更前後のテキストの差分を1つの編集として計算（共通の先頭と末尾を除いた部分）
+pub fn compute_edit(old: &str, new: &str) -> Option<InputEdit> {
+    if old == new {
+        return None;
+    }
+
+    let mut prefix = old
+        .bytes()
+        .zip(new.bytes())
+        .take_while(|(a, b)| a == b)
+        .count();
+    while !old.is_char_boundary(prefix) || !new.is_char_boundary(prefix) {
+        prefix -= 1;
+    }
+
+    let max_suffix = old.len().min(new.len()) - prefix;
+    let mut suffix = old
+        .bytes()
+        .rev()
+        .zip(new.bytes().rev())
+        .take(max_suffix)
+        .take_while(|(a, b)| a == b)
+        .count();
+    while !old.is_char_boundary(old.len() - suffix) || !new.is_char_boundary(new.len() - suffix) {
+        suffix -= 1;
+    }
+
+    let old_end = old.len() - suffix;
+    let new_end = new.len() - suffix;
+    let start_position = point_at(old, prefix);
+    Some(InputEdit {
+        start_byte: prefix,
+        old_end_byte: old_end,
+        new_end_byte: new_end,
+        start_position,
+        old_end_position: advance_point(start_position, &old[prefix..old_end]),
+        new_end_position: advance_point(start_position, &new[prefix..new_end]),
+    })
+}
+
+/// バイト位置の行と列（列はバイト単位）
+fn point_at(text: &str, byte: usize) -> Point {
+    advance_point(Point::new(0, 0), &text[..byte])
+}
+
+fn advance_point(start: Point, text: &str) -> Point {
+    match text.rfind('\n') {
+        Some(last_newline) => Point::new(
+            start.row + text.matches('\n').count(),
+            text.len() - last_newline - 1,
+        ),
+        None => Point::new(start.row, start.column + text.len()),
+    }
+}
+
+/// 範囲を、重なるトップレベルのノード全体まで広げる
+///
+/// 末尾がコメントなら、そのドキュメントコメントが付く次の定義も含める。
+fn expand_to_top_level(
+    root: Node,
+    mut start: (usize, Point),
+    mut end: (usize, Point),
+) -> ((usize, Point), (usize, Point)) {
+    let mut cursor = root.walk();
+    let children: Vec<Node> = root.children(&mut cursor).collect();
+
+    let mut last_included = None;
+    for (i, child) in children.iter().enumerate() {
+        if child.end_byte() < start.0 || child.start_byte() > end.0 {
+            continue;
+        }
+        if child.start_byte() < start.0 {
+            start = (child.start_byte(), child.start_position());
+        }
+        if child.end_byte() > end.0 {
+            end = (child.end_byte(), child.end_position());
+        }
+        last_included = Some(i);
+    }
+
+    if let Some(mut i) = last_included {
+        while children[i].kind().ends_with("comment") && i + 1 < children.len() {
+            i += 1;
+            end = (children[i].end_byte(), children[i].end_position());
+        }
+    }
+
+    (start, end)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    const SOURCE: &str = r#"fn first() {
+    println!("first");
+}
+
+fn second() {
+    println!("second");
+}
+
+fn third() {
+    println!("third");
+}
+"#;
+
+    #[test]
+    fn test_compute_edit() {
+        let edit =
+            compute_edit("let a = 1;\nlet b = 2;\n", "let a = 1;\nlet bc = 2;\nx\n").unwrap();
+        assert_eq!(edit.start_byte, 16);
+        assert_eq!(edit.start_position, Point::new(1, 5));
+        assert_eq!(edit.old_end_position, Point::new(1, 5));
+        assert_eq!(edit.new_end_position, Point::new(2, 1));
+
+        assert!(compute_edit("same", "same").is_none());
+
+        // マルチバイト文字の途中で区切らない
+        let edit = compute_edit("名前", "名札").unwrap();
+        assert_eq!(edit.start_byte, "名".len());
+    }
+
+    #[test]
+    fn test_only_changed_definition_is_reextracted() {
+        let mut parser = IncrementalParser::new();
+        let mut graph = CodeGraph::new();
+
+        let initial = parser
+            .update("lib.rs", SOURCE.to_string())
+            .unwrap()
+            .unwrap();
+        assert_eq!(initial.symbols.len(), 3);
+        initial.apply(&mut graph);
+
+        // secondの本体に1行追加
+        let edited = SOURCE.replace(
+            "    println!(\"second\");\n",
+            "    println!(\"second\");\n    println!(\"again\");\n",
+        );
+        let region = parser.update("lib.rs", edited.clone()).unwrap().unwrap();
+        assert_eq!(region.old_rows, Some((4, 6)));
+        assert_eq!(region.new_rows, Some((4, 7)));
+        assert_eq!(region.row_delta, 1);
+        let names: Vec<_> = region.symbols.iter().map(|s| s.name.as_str()).collect();
+        assert_eq!(names, vec!["second"]);
+
+        let stats = region.apply(&mut graph);
+        assert_eq!(
+            stats,
+            RowUpdateStats {
+                removed: 1,
+                shifted: 1,
+                added: 1
+            }
+        );
+        assert_eq!(
+            graph.find_symbol("lib.rs#5:second").unwrap().range.end.line,
+            7
+        );
+        assert_eq!(
+            graph
+                .find_symbol("lib.rs#10:third")
+                .unwrap()
+                .range
+                .start
+                .line,
+            9
+        );
+
+        // 増分解析の木は全体を解析し直したものと一致する
+        let mut full = TreeSitterParser::rust().unwrap();
+        assert_eq!(
+            parser.tree("lib.rs").unwrap().root_node().to_sexp(),
+            full.parse(&edited).unwrap().root_node().to_sexp()
+        );
+
+        assert!(parser.update("lib.rs", edited).unwrap().is_none());
+    }
+}
@@ -19,15 +19,23 @@ pub mod lsp_manager;
 pub mod lsp_metrics;
 pub mod lsp_performance_benchmark;
 pub mod lsp_pool;
+pub mod lsp_result_cache;
 pub mod lsp_rpc_client;
+pub mod lsp_timing_stats;
+pub mod lsp_trace;
 pub mod unified_indexer;
 
 // その他のモジュール
+#[cfg(any(test, feature = "fake-lsp"))]
+pub mod fake_lsp_server;
 pub mod fallback_indexer;
+pub mod incremental_parser;
 pub mod language_detector;
 pub mod language_optimization;
 pub mod optimized_io;
+pub mod query_loader;
 pub mod regex_cache;
+pub mod scope_resolver;
 pub mod timeout_predictor;
 pub mod tree_sitter_parser;
 
@@ -50,8 +58,13 @@ pub use language_optimization::{
 };
 pub use lsp_client::LspClient;
 pub use lsp_indexer::LspIndexer;
-pub use lsp_manager::{LspServerConfig, LspServerRegistry, ProjectIndex, UnifiedLspManager};
+pub use lsp_manager::{
+    LspServerConfig, LspServerRegistry, ProjectIndex, ServerInstallation, UnifiedLspManager,
+};
+pub use lsp_result_cache::LspResultCache;
 pub use lsp_rpc_client::LspRpcClient;
+pub use lsp_timing_stats::LspTimingStats;
+pub use scope_resolver::ScopeResolver;
 pub use timeout_predictor::{PredictorStatistics, TimeoutPredictor};
 pub use tree_sitter_parser::TreeSitterParser;
 pub use unified_indexer::{IndexResult, UnifiedIndexer};
@@ -17,6 +17,11 @@ use std::sync::atomic::{AtomicU64, Ordering};
 use std::time::{Duration, Instant};
 
 use crate::adapter::language::LanguageAdapter;
+use crate::adapter::lsp::{
+    convert_document_symbols, general_client_capabilities, negotiated_position_encoding,
+    to_canonical_locations, to_server_position,
+};
+use lsif_core::{LineIndex, PositionEncoding};
 
 pub struct LspClient {
     process: Child,
@@ -24,6 +29,8 @@ pub struct LspClient {
     stdout: BufReader<ChildStdout>,
     request_id: AtomicU64,
     adapter: Box<dyn LanguageAdapter>,
+    /// サーバーと合意した位置エンコーディング
+    position_encoding: PositionEncoding,
 }
 
 impl LspClient {
@@ -46,6 +53,7 @@ impl LspClient {
             stdout: BufReader::new(stdout),
             request_id: AtomicU64::new(1),
             adapter,
+            position_encoding: PositionEncoding::Utf16,
         })
     }
 
@@ -94,6 +102,7 @@ impl LspClient {
 
         let response: InitializeResult =
             self.send_request_with_timeout("initialize", params, timeout)?;
+        self.position_encoding = negotiated_position_encoding(&response.capabilities);
 
         // initialized通知を送信
         self.send_notification("initialized", serde_json::json!({}))?;
@@ -123,11 +132,11 @@ impl LspClient {
         let response: DocumentSymbolResponse =
             self.send_request("textDocument/documentSymbol", params)?;
 
-        match response {
-            DocumentSymbolResponse::Nested(symbols) => Ok(symbols),
+        let mut symbols = match response {
+            DocumentSymbolResponse::Nested(symbols) => symbols,
             DocumentSymbolResponse::Flat(symbols) => {
                 // SymbolInformationをDocumentSymbolに変換
-                Ok(symbols
+                symbols
                     .into_iter()
                     .map(|s| {
                         #[allow(deprecated)]
@@ -142,9 +151,22 @@ impl LspClient {
                             children: None,
                         }
                     })
-                    .collect())
+                    .collect()
             }
+        };
+
+        // サーバーのエンコーディングから正規のエンコーディングに変換
+        if self.position_encoding != PositionEncoding::CANONICAL {
+            let content = std::fs::read_to_string(&absolute_path)?;
+            convert_document_symbols(
+                &mut symbols,
+                &LineIndex::new(&content),
+                self.position_encoding,
+                PositionEncoding::CANONICAL,
+            );
         }
+
+        Ok(symbols)
     }
 
     /// 参照を検索
@@ -161,7 +183,7 @@ impl LspClient {
         let uri = Url::from_file_path(&absolute_path)
             .map_err(|_| anyhow!("Invalid file path: {:?}", absolute_path))?;
 
-        let params = ReferenceParams {
+        let mut params = ReferenceParams {
             text_document_position: TextDocumentPositionParams {
                 text_document: TextDocumentIdentifier { uri },
                 position,
@@ -172,11 +194,14 @@ impl LspClient {
             work_done_progress_params: Default::default(),
             partial_result_params: Default::default(),
         };
+        to_server_position(&mut params.text_document_position, self.position_encoding);
 
         let response: Option<Vec<Location>> =
             self.send_request("textDocument/references", params)?;
 
-        Ok(response.unwrap_or_default())
+        let mut locations = response.unwrap_or_default();
+        to_canonical_locations(&mut locations, self.position_encoding);
+        Ok(locations)
     }
 
     /// 定義位置を取得
@@ -196,25 +221,31 @@ impl LspClient {
         let uri = Url::from_file_path(&absolute_path)
             .map_err(|_| anyhow!("Invalid file path: {:?}", absolute_path))?;
 
-        let params = TextDocumentPositionParams {
+        let mut params = TextDocumentPositionParams {
             text_document: TextDocumentIdentifier { uri },
             position,
         };
+        to_server_position(&mut params, self.position_encoding);
 
         let response: Option<lsp_types::GotoDefinitionResponse> =
             self.send_request("textDocument/definition", params)?;
 
-        match response {
-            Some(lsp_types::GotoDefinitionResponse::Scalar(location)) => Ok(Some(location)),
-            Some(lsp_types::GotoDefinitionResponse::Array(mut locations)) => Ok(locations.pop()),
+        let mut location = match response {
+            Some(lsp_types::GotoDefinitionResponse::Scalar(location)) => Some(location),
+            Some(lsp_types::GotoDefinitionResponse::Array(mut locations)) => locations.pop(),
             Some(lsp_types::GotoDefinitionResponse::Link(mut links)) => {
-                Ok(links.pop().map(|link| Location {
+                links.pop().map(|link| Location {
                     uri: link.target_uri,
                     range: link.target_selection_range,
-                }))
+                })
             }
-            None => Ok(None),
+            None => None,
+        };
+
+        if let Some(location) = location.as_mut() {
+            to_canonical_locations(std::slice::from_mut(location), self.position_encoding);
         }
+        Ok(location)
     }
 
     /// 型情報を取得（ホバー）
@@ -329,7 +360,7 @@ impl LspClient {
                 diagnostic: None,
             }),
             window: None,
-            general: None,
+            general: Some(general_client_capabilities()),
             experimental: None,
         }
     }
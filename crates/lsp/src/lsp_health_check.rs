@@ -1,12 +1,15 @@
 use anyhow::{anyhow, Result};
+use serde::{Deserialize, Serialize};
 use std::collections::HashMap;
 use std::io::{BufRead, BufReader, Write};
 use std::process::Child;
 use std::time::{Duration, Instant};
 use tracing::{debug, info, warn};
 
+use crate::lsp_timing_stats::LspTimingStats;
+
 /// LSP操作の種類
-#[derive(Debug, Clone, Copy, PartialEq, Eq, Hash)]
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Hash, Serialize, Deserialize)]
 pub enum LspOperationType {
     Initialize,
     DocumentSymbol,
@@ -28,12 +31,16 @@ pub enum LspOperationType {
 pub struct LspHealthChecker {
     /// 初期化時のレスポンス時間
     init_response_time: Option<Duration>,
+    /// 前回までの実行から引き継いだ初期化時間（この実行で測るまでの代わり）
+    seeded_init_time: Option<Duration>,
     /// 通常操作のレスポンス時間の履歴
     operation_times: Vec<Duration>,
     /// ウォームアップ中のレスポンス時間（初期化後の最初の数回）
     warmup_times: Vec<Duration>,
     /// 操作種別ごとのレスポンス時間
     operation_type_times: HashMap<LspOperationType, Vec<Duration>>,
+    /// 前回までの実行から引き継いだ操作種別ごとのレスポンス時間
+    seeded_operation_type_times: HashMap<LspOperationType, Vec<Duration>>,
     /// ウォームアップ期間のリクエスト数
     warmup_requests: usize,
     /// 現在のリクエスト数
@@ -52,9 +59,11 @@ impl LspHealthChecker {
     pub fn new() -> Self {
         Self {
             init_response_time: None,
+            seeded_init_time: None,
             operation_times: Vec::new(),
             warmup_times: Vec::new(),
             operation_type_times: HashMap::new(),
+            seeded_operation_type_times: HashMap::new(),
             warmup_requests: 5, // 初期化後の最初の5リクエストはウォームアップ期間
             request_count: 0,
             max_history: 100,
@@ -212,7 +221,7 @@ impl LspHealthChecker {
     /// 初期化用のタイムアウトを計算
     pub fn calculate_init_timeout(&self) -> Duration {
         // 既に初期化時間が記録されている場合はそれを基準に
-        if let Some(init_time) = self.init_response_time {
+        if let Some(init_time) = self.init_time() {
             let timeout = init_time * 3; // 初期化時間の3倍
             return timeout
                 .max(Duration::from_secs(2))
@@ -226,7 +235,7 @@ impl LspHealthChecker {
     /// ウォームアップ期間用のタイムアウトを計算
     pub fn calculate_warmup_timeout(&self) -> Duration {
         // ウォームアップ期間は初期化時間を基準に
-        if let Some(init_time) = self.init_response_time {
+        if let Some(init_time) = self.init_time() {
             // 初期化時間の2倍（通常操作よりは長め）
             let timeout = init_time * 2;
             return timeout
@@ -245,19 +254,34 @@ impl LspHealthChecker {
         Duration::from_secs(10) // デフォルト
     }
 
+    /// 初期化時間（この実行で測った値、なければ引き継いだ値）
+    fn init_time(&self) -> Option<Duration> {
+        self.init_response_time.or(self.seeded_init_time)
+    }
+
     /// 操作種別ごとの平均レスポンス時間
+    ///
+    /// この実行で測った履歴があればそれを、なければ引き継いだ履歴を使う。
     pub fn average_response_time_for_operation(
         &self,
         op_type: LspOperationType,
     ) -> Option<Duration> {
-        self.operation_type_times.get(&op_type).and_then(|times| {
+        let average = |times: &Vec<Duration>| {
             if times.is_empty() {
                 None
             } else {
                 let total: Duration = times.iter().sum();
                 Some(total / times.len() as u32)
             }
-        })
+        };
+        self.operation_type_times
+            .get(&op_type)
+            .and_then(average)
+            .or_else(|| {
+                self.seeded_operation_type_times
+                    .get(&op_type)
+                    .and_then(average)
+            })
     }
 
     /// 操作種別に応じたタイムアウトを計算
@@ -351,6 +375,44 @@ impl LspHealthChecker {
         self.calculate_timeout_for_operation(op_type)
     }
 
+    /// 保存済みの統計で学習状態を初期化（前回の実行から引き継ぐ）
+    ///
+    /// 引き継いだ値はこの実行で測った値とは別に持ち、測った値があればそちらを優先する。
+    pub fn seed_from_stats(&mut self, stats: &LspTimingStats) {
+        if let Some(init) = stats.init_percentiles() {
+            // 初期化時間は保守的にp90を採用
+            self.seeded_init_time = Some(init.p90);
+        }
+
+        let per_type_limit = self.max_history / 10;
+        for (op_type, samples) in &stats.operation_times_ms {
+            let start = samples.len().saturating_sub(per_type_limit);
+            let times = samples[start..]
+                .iter()
+                .map(|ms| Duration::from_millis(*ms))
+                .collect();
+            self.seeded_operation_type_times.insert(*op_type, times);
+        }
+
+        debug!(
+            "Seeded health checker for {} with {} operation types",
+            stats.language,
+            stats.operation_times_ms.len()
+        );
+    }
+
+    /// この実行で測った応答時間を統計に書き出す（引き継いだ値は書き出さない）
+    pub fn export_stats_into(&self, stats: &mut LspTimingStats) {
+        if let Some(init) = self.init_response_time {
+            stats.record_init(init);
+        }
+        for (op_type, times) in &self.operation_type_times {
+            for duration in times {
+                stats.record_operation(*op_type, *duration);
+            }
+        }
+    }
+
     /// ヘルスステータスを取得
     pub fn get_health_status(&self) -> HealthStatus {
         HealthStatus {
@@ -488,4 +550,68 @@ mod tests {
         let ts_wait = validator.wait_for_startup("typescript");
         assert_eq!(ts_wait, Duration::from_millis(30));
     }
+
+    #[test]
+    fn test_seed_and_export_stats() {
+        let mut stats = LspTimingStats::new("go");
+        stats.record_init(Duration::from_millis(4000));
+        for _ in 0..5 {
+            stats.record_operation(LspOperationType::References, Duration::from_secs(10));
+        }
+
+        let mut checker = LspHealthChecker::new();
+        checker.seed_from_stats(&stats);
+
+        // 初回のリクエストから学習済みの値が使われる
+        assert_eq!(
+            checker.calculate_init_timeout(),
+            Duration::from_secs(10) // 4秒 * 3 を上限10秒でクランプ
+        );
+        assert_eq!(
+            checker.calculate_timeout_for_operation(LspOperationType::References),
+            Duration::from_secs(20)
+        );
+
+        // 引き継いだ値は書き出さない（保存済みの統計にマージすると増えていくため）
+        let mut exported = LspTimingStats::new("go");
+        checker.export_stats_into(&mut exported);
+        assert!(exported.init_times_ms.is_empty());
+        assert!(exported.operation_times_ms.is_empty());
+    }
+
+    #[test]
+    fn test_measured_times_take_precedence_over_seeded() {
+        let mut stats = LspTimingStats::new("go");
+        stats.record_init(Duration::from_millis(4000));
+        for _ in 0..5 {
+            stats.record_operation(LspOperationType::References, Duration::from_secs(10));
+        }
+
+        let mut checker = LspHealthChecker::new();
+        checker.seed_from_stats(&stats);
+        checker.record_init_time(Duration::from_millis(1000));
+        for _ in 0..8 {
+            checker.record_response_time_for_operation(
+                Duration::from_secs(4),
+                LspOperationType::References,
+            );
+        }
+
+        // 測った初期化時間は後から引き継いでも上書きされない
+        checker.seed_from_stats(&stats);
+        assert_eq!(checker.calculate_init_timeout(), Duration::from_secs(3));
+        assert_eq!(
+            checker.calculate_timeout_for_operation(LspOperationType::References),
+            Duration::from_secs(8)
+        );
+
+        // 書き出すのはこの実行の観測だけ
+        let mut exported = LspTimingStats::new("go");
+        checker.export_stats_into(&mut exported);
+        assert_eq!(exported.init_times_ms, vec![1000]);
+        assert_eq!(
+            exported.operation_times_ms[&LspOperationType::References],
+            vec![4000; 3]
+        );
+    }
 }
@@ -1,8 +1,15 @@
 use super::adapter::lsp::{
     detect_language, GenericLspClient, RustAnalyzerAdapter, TypeScriptAdapter,
 };
+use crate::tree_sitter_parser::TreeSitterParser;
 use anyhow::{Context, Result};
+use lsif_core::{Range, ReferenceAccess, Symbol, SymbolKind, SymbolModifiers};
+use lsp_types::{
+    DocumentHighlightKind, DocumentSymbol, SymbolInformation, SymbolKind as LspSymbolKind,
+    SymbolTag,
+};
 use std::path::Path;
+use tracing::debug;
 
 /// LSPクライアントを作成するためのヘルパー関数群
 pub struct LspClientHelpers;
@@ -44,3 +51,316 @@ impl LspClientHelpers {
         }
     }
 }
+
+/// LSPのDocumentSymbolから修飾子を判定
+///
+/// detailのシグネチャと非推奨タグに加え、言語ごとの規則を適用する。
+/// detailはサーバーが整形したもので`pub`などを含むとは限らないので、
+/// Rustの可視性は決めない（`apply_language_rules_without_source`）。
+/// `parent`はシンボルを直接囲んでいるDocumentSymbol。
+pub fn document_symbol_modifiers(
+    symbol: &DocumentSymbol,
+    file_path: &str,
+    parent: Option<&DocumentSymbol>,
+) -> SymbolModifiers {
+    let mut modifiers = document_symbol_signature_modifiers(symbol);
+    modifiers.apply_language_rules_without_source(
+        &symbol.name,
+        file_path,
+        parent.and_then(|parent| container_kind(parent.kind)),
+    );
+    modifiers
+}
+
+/// `FallbackIndexer`のDocumentSymbol（detailにソースの宣言行が入る）から修飾子を判定
+pub fn declaration_symbol_modifiers(
+    symbol: &DocumentSymbol,
+    file_path: &str,
+    parent: Option<&DocumentSymbol>,
+) -> SymbolModifiers {
+    let mut modifiers = document_symbol_signature_modifiers(symbol);
+    modifiers.apply_language_rules(
+        &symbol.name,
+        file_path,
+        parent.and_then(|parent| container_kind(parent.kind)),
+    );
+    modifiers
+}
+
+#[allow(deprecated)]
+fn document_symbol_signature_modifiers(symbol: &DocumentSymbol) -> SymbolModifiers {
+    lsp_modifiers(
+        symbol.detail.as_deref(),
+        symbol.tags.as_deref(),
+        symbol.deprecated,
+    )
+}
+
+/// LSPのSymbolInformation（workspace/symbolの結果）から修飾子を判定
+#[allow(deprecated)]
+pub fn symbol_information_modifiers(symbol: &SymbolInformation) -> SymbolModifiers {
+    let mut modifiers = lsp_modifiers(None, symbol.tags.as_deref(), symbol.deprecated);
+    modifiers.apply_language_rules_without_source(&symbol.name, symbol.location.uri.path(), None);
+    modifiers
+}
+
+/// 中の定義の可視性を決める外側の定義の種別（rust-analyzerはimplを`OBJECT`で返す）
+fn container_kind(kind: LspSymbolKind) -> Option<SymbolKind> {
+    match kind {
+        LspSymbolKind::INTERFACE => Some(SymbolKind::Trait),
+        LspSymbolKind::OBJECT | LspSymbolKind::CLASS => Some(SymbolKind::Class),
+        _ => None,
+    }
+}
+
+fn lsp_modifiers(
+    detail: Option<&str>,
+    tags: Option<&[SymbolTag]>,
+    deprecated: Option<bool>,
+) -> SymbolModifiers {
+    let mut modifiers = detail
+        .map(SymbolModifiers::from_signature)
+        .unwrap_or_default();
+    if deprecated == Some(true) || tags.is_some_and(|tags| tags.contains(&SymbolTag::DEPRECATED)) {
+        modifiers.insert(SymbolModifiers::DEPRECATED);
+    }
+    modifiers
+}
+
+/// ソースのドキュメントコメント（tree-sitterのアウトラインから取り出す）
+///
+/// LSPのDocumentSymbolのdetailはシグネチャなので、ドキュメントコメントは
+/// 同じファイルのアウトラインから名前と位置が対応する定義を探して付ける。
+#[derive(Debug, Default)]
+pub struct SourceDocComments {
+    /// (名前, 定義全体の範囲, ドキュメント)
+    definitions: Vec<(String, Range, Option<String>)>,
+}
+
+impl SourceDocComments {
+    /// ファイルを読んで解析する（読めない・文法がなければ空）
+    pub fn load(path: &Path) -> Self {
+        match std::fs::read_to_string(path) {
+            Ok(source) => Self::from_source(path, &source),
+            Err(e) => {
+                debug!("Failed to read {} for doc comments: {}", path.display(), e);
+                Self::default()
+            }
+        }
+    }
+
+    pub fn from_source(path: &Path, source: &str) -> Self {
+        let mut parser = match TreeSitterParser::from_extension(path) {
+            Some(parser) => parser,
+            None => return Self::default(),
+        };
+        match parser.extract_outline(source, &path.to_string_lossy()) {
+            Ok(outline) => Self {
+                definitions: outline
+                    .into_iter()
+                    .map(|symbol| (symbol.name, symbol.range, symbol.documentation))
+                    .collect(),
+            },
+            Err(e) => {
+                debug!("Failed to parse {} for doc comments: {}", path.display(), e);
+                Self::default()
+            }
+        }
+    }
+
+    /// DocumentSymbolのドキュメント（名前の位置を含む最も内側の同名の定義）
+    pub fn find(&self, symbol: &DocumentSymbol) -> Option<&str> {
+        let line = symbol.selection_range.start.line;
+        let name = short_name(&symbol.name);
+        self.definitions
+            .iter()
+            .filter(|(n, range, _)| {
+                short_name(n) == name && range.start.line <= line && line <= range.end.line
+            })
+            .min_by_key(|(_, range, _)| range.end.line - range.start.line)
+            .and_then(|(_, _, doc)| doc.as_deref())
+    }
+
+    /// 変換したシンボルにドキュメントを付ける（非推奨ならその修飾子も付ける）
+    pub fn attach(&self, doc_symbol: &DocumentSymbol, symbol: &mut Symbol) {
+        if let Some(doc) = self.find(doc_symbol) {
+            symbol.documentation = Some(doc.to_string());
+        }
+        if symbol.is_deprecated() {
+            symbol.modifiers.insert(SymbolModifiers::DEPRECATED);
+        }
+    }
+}
+
+/// goplsのメソッド名（`(*DB).Close`）はメソッド名の部分で照合する
+fn short_name(name: &str) -> &str {
+    name.rsplit('.').next().unwrap_or(name)
+}
+
+/// `textDocument/documentHighlight`の種類から参照の使われ方を判定（`TEXT`は判断しない）
+pub fn document_highlight_access(kind: Option<DocumentHighlightKind>) -> Option<ReferenceAccess> {
+    match kind {
+        Some(DocumentHighlightKind::READ) => Some(ReferenceAccess::Read),
+        Some(DocumentHighlightKind::WRITE) => Some(ReferenceAccess::Write),
+        _ => None,
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use lsp_types::{Position, Range, SymbolKind};
+
+    #[test]
+    #[allow(deprecated)]
+    fn test_document_symbol_modifiers() {
+        let range = Range::new(Position::new(0, 0), Position::new(2, 1));
+        let symbol = DocumentSymbol {
+            name: "fetch".to_string(),
+            detail: Some("async fn(url: &str)".to_string()),
+            kind: SymbolKind::FUNCTION,
+            tags: Some(vec![SymbolTag::DEPRECATED]),
+            deprecated: None,
+            range,
+            selection_range: range,
+            children: None,
+        };
+        // rust-analyzerのdetailに`pub`は現れないので可視性は決めない
+        assert_eq!(
+            document_symbol_modifiers(&symbol, "src/lib.rs", None),
+            SymbolModifiers::ASYNC | SymbolModifiers::DEPRECATED
+        );
+
+        // フォールバックの宣言行からは可視性も判定する
+        let declaration = DocumentSymbol {
+            detail: Some("pub async fn fetch(url: &str) {".to_string()),
+            ..symbol.clone()
+        };
+        assert_eq!(
+            declaration_symbol_modifiers(&declaration, "src/lib.rs", None),
+            SymbolModifiers::PUBLIC | SymbolModifiers::ASYNC | SymbolModifiers::DEPRECATED
+        );
+        let private = DocumentSymbol {
+            detail: Some("fn fetch(url: &str) {".to_string()),
+            tags: None,
+            ..symbol
+        };
+        assert_eq!(
+            declaration_symbol_modifiers(&private, "src/lib.rs", None),
+            SymbolModifiers::PRIVATE
+        );
+    }
+
+    #[test]
+    fn test_document_symbol_language_rules() {
+        let range = Range::new(Position::new(0, 0), Position::new(2, 1));
+        let symbol = |name: &str, kind| DocumentSymbol {
+            name: name.to_string(),
+            detail: None,
+            kind,
+            tags: None,
+            deprecated: None,
+            range,
+            selection_range: range,
+            children: None,
+        };
+
+        // goplsはシグネチャに可視性を含めないので名前から判定する
+        let close = symbol("(*DB).Close", SymbolKind::METHOD);
+        assert_eq!(
+            document_symbol_modifiers(&close, "store/db.go", None),
+            SymbolModifiers::EXPORTED | SymbolModifiers::PUBLIC
+        );
+        let helper = symbol("_helper", SymbolKind::FUNCTION);
+        assert_eq!(
+            document_symbol_modifiers(&helper, "tests/test_app.py", None),
+            SymbolModifiers::PROTECTED | SymbolModifiers::TEST
+        );
+
+        // implの中の定義は外側の可視性に従う
+        let method = symbol("len", SymbolKind::METHOD);
+        let implementation = symbol("impl Stack", SymbolKind::OBJECT);
+        assert_eq!(
+            declaration_symbol_modifiers(&method, "src/stack.rs", Some(&implementation)),
+            SymbolModifiers::NONE
+        );
+        assert_eq!(
+            declaration_symbol_modifiers(&method, "src/stack.rs", None),
+            SymbolModifiers::PRIVATE
+        );
+        assert_eq!(
+            document_symbol_modifiers(&method, "src/stack.rs", None),
+            SymbolModifiers::NONE
+        );
+    }
+
+    #[test]
+    fn test_source_doc_comments() {
+        let source = "// Open opens the database.\n//\n// Deprecated: use Connect.\nfunc Open() {}\n\ntype DB struct{}\n\n// Close closes the database.\nfunc (db *DB) Close() {}\n";
+        let docs = SourceDocComments::from_source(Path::new("store/db.go"), source);
+
+        let symbol = |name: &str, line| {
+            let range = Range::new(Position::new(line, 0), Position::new(line, 10));
+            DocumentSymbol {
+                name: name.to_string(),
+                detail: Some("func()".to_string()),
+                kind: SymbolKind::FUNCTION,
+                tags: None,
+                deprecated: None,
+                range,
+                selection_range: range,
+                children: None,
+            }
+        };
+
+        let open = symbol("Open", 3);
+        assert!(docs.find(&open).unwrap().contains("**Deprecated**"));
+        let close = symbol("(*DB).Close", 8);
+        assert_eq!(docs.find(&close), Some("Close closes the database."));
+        assert_eq!(docs.find(&symbol("DB", 5)), None);
+        // 名前が違えば同じ行でも付けない
+        assert_eq!(docs.find(&symbol("Connect", 3)), None);
+
+        let mut core_symbol = Symbol {
+            id: "store/db.go#4:Open".to_string(),
+            name: "Open".to_string(),
+            kind: lsif_core::SymbolKind::Function,
+            file_path: "store/db.go".to_string(),
+            range: lsif_core::Range {
+                start: lsif_core::Position {
+                    line: 3,
+                    character: 0,
+                },
+                end: lsif_core::Position {
+                    line: 3,
+                    character: 14,
+                },
+            },
+            documentation: None,
+            detail: open.detail.clone(),
+            modifiers: SymbolModifiers::NONE,
+            qualified_name: None,
+        };
+        docs.attach(&open, &mut core_symbol);
+        assert!(core_symbol.is_deprecated());
+        assert!(core_symbol.modifiers.contains(SymbolModifiers::DEPRECATED));
+        assert_eq!(core_symbol.detail.as_deref(), Some("func()"));
+    }
+
+    #[test]
+    fn test_document_highlight_access() {
+        assert_eq!(
+            document_highlight_access(Some(DocumentHighlightKind::WRITE)),
+            Some(ReferenceAccess::Write)
+        );
+        assert_eq!(
+            document_highlight_access(Some(DocumentHighlightKind::READ)),
+            Some(ReferenceAccess::Read)
+        );
+        assert_eq!(
+            document_highlight_access(Some(DocumentHighlightKind::TEXT)),
+            None
+        );
+        assert_eq!(document_highlight_access(None), None);
+    }
+}
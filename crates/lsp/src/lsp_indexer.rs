@@ -1,3 +1,4 @@
+use crate::lsp_helpers::document_symbol_modifiers;
 use anyhow::Result;
 use lsif_core::{CodeGraph, Position, Range, Symbol, SymbolKind};
 use lsp_types::DocumentSymbol;
@@ -18,12 +19,17 @@ impl LspIndexer {
 
     pub fn index_from_symbols(&mut self, symbols: Vec<DocumentSymbol>) -> Result<()> {
         for symbol in symbols {
-            self.process_symbol(&symbol, None)?;
+            self.process_symbol(&symbol, None, None)?;
         }
         Ok(())
     }
 
-    fn process_symbol(&mut self, symbol: &DocumentSymbol, parent_id: Option<String>) -> Result<()> {
+    fn process_symbol(
+        &mut self,
+        symbol: &DocumentSymbol,
+        parent: Option<&DocumentSymbol>,
+        parent_id: Option<String>,
+    ) -> Result<()> {
         // Create symbol ID
         let symbol_id = format!(
             "{}#{}:{}",
@@ -46,8 +52,11 @@ impl LspIndexer {
                     character: symbol.range.end.character,
                 },
             },
-            documentation: symbol.detail.clone(),
-            detail: None,
+            // detailはシグネチャでドキュメントではない
+            documentation: None,
+            detail: symbol.detail.clone(),
+            modifiers: document_symbol_modifiers(symbol, &self.file_path, parent),
+            qualified_name: None,
         };
 
         let _node_index = self.graph.add_symbol(our_symbol);
@@ -66,7 +75,7 @@ impl LspIndexer {
         // Process children recursively
         if let Some(children) = &symbol.children {
             for child in children {
-                self.process_symbol(child, Some(symbol_id.clone()))?;
+                self.process_symbol(child, Some(symbol), Some(symbol_id.clone()))?;
             }
         }
 
@@ -5,6 +5,7 @@ use tracing::{info, warn};
 
 use super::language_detector::{create_language_adapter, detect_file_language};
 use super::lsp_client::LspClient;
+use super::lsp_helpers::document_symbol_modifiers;
 use lsif_core::CodeGraph;
 use lsif_core::{Range, Symbol, SymbolKind as LSIFSymbolKind};
 
@@ -95,7 +96,7 @@ impl LspIntegration {
         let symbols = self.client.document_symbols(uri.clone())?;
 
         for symbol in symbols {
-            self.process_symbol(&uri, &symbol, graph, None)?;
+            self.process_symbol(&uri, &symbol, None, graph, None)?;
         }
 
         self.analyze_references(&uri, graph).await?;
@@ -108,6 +109,7 @@ impl LspIntegration {
         &mut self,
         file_uri: &Url,
         symbol: &DocumentSymbol,
+        parent: Option<&DocumentSymbol>,
         graph: &mut CodeGraph,
         _parent_id: Option<String>,
     ) -> Result<String> {
@@ -133,15 +135,24 @@ impl LspIntegration {
                     character: symbol.selection_range.end.character,
                 },
             },
-            documentation: symbol.detail.clone(),
-            detail: None,
+            // detailはシグネチャでドキュメントではない
+            documentation: None,
+            detail: symbol.detail.clone(),
+            modifiers: document_symbol_modifiers(symbol, file_uri.path(), parent),
+            qualified_name: None,
         };
 
         graph.add_symbol(lsif_symbol);
 
         if let Some(children) = &symbol.children {
             for child in children {
-                self.process_symbol(file_uri, child, graph, Some(symbol_id.clone()))?;
+                self.process_symbol(
+                    file_uri,
+                    child,
+                    Some(symbol),
+                    graph,
+                    Some(symbol_id.clone()),
+                )?;
             }
         }
 
@@ -4,6 +4,7 @@ use lsp_types::*;
 use serde_json::Value;
 use std::collections::HashMap;
 use std::path::{Path, PathBuf};
+use std::process::{Command, Stdio};
 use std::sync::Arc;
 use tokio::sync::RwLock;
 use tracing::{error, info, warn};
@@ -20,6 +21,55 @@ pub struct LspServerConfig {
     pub workspace_folders: Vec<WorkspaceFolder>,
 }
 
+impl LspServerConfig {
+    /// サーバーがインストールされているか、バージョンと合わせて調べる
+    ///
+    /// `--version`（goplsは`version`）を標準入力なしで実行する。起動できなければ未インストール。
+    pub fn installation(&self) -> ServerInstallation {
+        let version_args: &[&str] = match self.command.as_str() {
+            "gopls" => &["version"],
+            _ => &["--version"],
+        };
+        let output = Command::new(&self.command)
+            .args(version_args)
+            .stdin(Stdio::null())
+            .output();
+
+        let (installed, version) = match output {
+            // 標準エラーにバージョンを出すサーバーもある
+            Ok(output) if output.status.success() => {
+                let version = [&output.stdout, &output.stderr].iter().find_map(|stream| {
+                    String::from_utf8_lossy(stream)
+                        .lines()
+                        .map(str::trim)
+                        .find(|line| !line.is_empty())
+                        .map(str::to_string)
+                });
+                (true, version)
+            }
+            Ok(_) => (true, None),
+            Err(_) => (false, None),
+        };
+
+        ServerInstallation {
+            language_id: self.language_id.clone(),
+            command: self.command.clone(),
+            installed,
+            version,
+        }
+    }
+}
+
+/// LSPサーバーのインストール状況
+#[derive(Debug, Clone, serde::Serialize)]
+pub struct ServerInstallation {
+    pub language_id: String,
+    pub command: String,
+    pub installed: bool,
+    /// バージョン表示の1行目（取れなければNone）
+    pub version: Option<String>,
+}
+
 /// 言語ごとのLSPサーバー設定
 pub struct LspServerRegistry {
     configs: HashMap<String, LspServerConfig>,
@@ -129,6 +179,13 @@ impl LspServerRegistry {
         self.configs.get(language)
     }
 
+    /// 設定済みの全サーバー（言語ID順）
+    pub fn configs(&self) -> Vec<&LspServerConfig> {
+        let mut configs: Vec<_> = self.configs.values().collect();
+        configs.sort_by(|a, b| a.language_id.cmp(&b.language_id));
+        configs
+    }
+
     pub fn detect_language(path: &Path) -> Option<String> {
         let ext = path.extension()?.to_str()?;
 
@@ -434,6 +491,21 @@ mod tests {
         assert_eq!(rust_config.language_id, "rust");
     }
 
+    #[test]
+    fn test_missing_server_installation() {
+        let config = LspServerConfig {
+            language_id: "none".to_string(),
+            command: "lsif-no-such-language-server".to_string(),
+            args: vec![],
+            initialization_options: None,
+            workspace_folders: vec![],
+        };
+
+        let installation = config.installation();
+        assert!(!installation.installed);
+        assert_eq!(installation.version, None);
+    }
+
     #[tokio::test]
     async fn test_manager_creation() {
         let manager = UnifiedLspManager::new();
@@ -214,6 +214,11 @@ impl LspMetricsCollector {
         };
     }
 
+    /// 操作別のメトリクスを取得
+    pub fn operation_metrics(&self) -> HashMap<String, OperationMetrics> {
+        self.metrics.read().unwrap().operation_metrics.clone()
+    }
+
     /// メトリクスのサマリーを取得
     pub fn get_summary(&self) -> MetricsSummary {
         let metrics = self.metrics.read().unwrap();
@@ -8,10 +8,18 @@ use std::sync::{
 use std::time::{Duration, Instant};
 use tracing::{debug, info, warn};
 
-use crate::adapter::lsp::{detect_language, get_language_id, GenericLspClient};
+use crate::adapter::lsp::{detect_language, get_language_id, GenericLspClient, LspAdapter};
+use crate::lsp_result_cache::ServerIdentity;
+use crate::lsp_timing_stats::{find_stats_for, LspTimingStats};
 
 type LanguageId = String;
 
+/// シャットダウン時にサーバーの終了を待つ時間
+const SHUTDOWN_GRACE: Duration = Duration::from_secs(2);
+
+/// 言語IDからアダプタを生成する関数（テストでフェイクサーバーを差し込むために使用）
+pub type AdapterFactory = Arc<dyn Fn(&str) -> Option<Box<dyn LspAdapter>> + Send + Sync>;
+
 /// LSPクライアントプール - LSPサーバーの再利用と管理
 pub struct LspClientPool {
     /// 言語IDごとのクライアントプール（複数インスタンス対応）
@@ -20,6 +28,10 @@ pub struct LspClientPool {
     config: PoolConfig,
     /// 次のインスタンスID
     next_instance_id: Arc<AtomicUsize>,
+    /// アダプタの生成方法（Noneなら拡張子から検出）
+    adapter_factory: Option<AdapterFactory>,
+    /// 前回までに学習した応答時間統計（キー: 言語@バージョン）
+    timing_stats: Arc<Mutex<HashMap<String, LspTimingStats>>>,
 }
 
 /// プールされたクライアント
@@ -93,14 +105,87 @@ impl LspClientPool {
             clients: Arc::new(Mutex::new(HashMap::new())),
             config,
             next_instance_id: Arc::new(AtomicUsize::new(0)),
+            adapter_factory: None,
+            timing_stats: Arc::new(Mutex::new(HashMap::new())),
         }
     }
 
+    /// アダプタの生成方法を差し替える
+    pub fn with_adapter_factory(mut self, factory: AdapterFactory) -> Self {
+        self.adapter_factory = Some(factory);
+        self
+    }
+
     /// デフォルト設定でプールを作成
     pub fn with_defaults() -> Self {
         Self::new(PoolConfig::default())
     }
 
+    /// 保存済みの応答時間統計を設定（以降に作成するクライアントに適用）
+    pub fn seed_timing_stats(&self, stats: HashMap<String, LspTimingStats>) {
+        info!(
+            "Loaded learned LSP timing statistics for {} servers",
+            stats.len()
+        );
+        *self.timing_stats.lock().unwrap() = stats;
+    }
+
+    /// プール内の全クライアントが学習した統計を集める（同じサーバーのインスタンスはマージ）
+    pub fn collect_timing_stats(&self) -> Vec<LspTimingStats> {
+        let clients = self.clients.lock().unwrap();
+        let mut merged: HashMap<String, LspTimingStats> = HashMap::new();
+
+        for instances in clients.values() {
+            for pooled in instances {
+                // 使用中のクライアントは待たずにスキップ
+                let client = match pooled.client.try_lock() {
+                    Ok(client) => client,
+                    Err(_) => continue,
+                };
+                let stats = client.export_timing_stats();
+                if stats.is_empty() {
+                    continue;
+                }
+                match merged.get_mut(&stats.key()) {
+                    Some(existing) => existing.merge(&stats),
+                    None => {
+                        merged.insert(stats.key(), stats);
+                    }
+                }
+            }
+        }
+
+        merged.into_values().collect()
+    }
+
+    /// 言語のサーバー識別情報を取得
+    ///
+    /// 起動済みのクライアントがあればそのserverInfoを使い、なければ前回までの
+    /// 統計に記録されたものを使う（サーバーを起動せずにキャッシュを引くため）。
+    pub fn server_identity_for_language(&self, language_id: &str) -> Option<ServerIdentity> {
+        {
+            let clients = self.clients.lock().unwrap();
+            if let Some(instances) = clients.get(language_id) {
+                for pooled in instances {
+                    if let Ok(client) = pooled.client.try_lock() {
+                        return Some(ServerIdentity::from_server_info(
+                            language_id,
+                            client.get_server_info(),
+                        ));
+                    }
+                }
+            }
+        }
+
+        let stats = self.timing_stats.lock().unwrap();
+        find_stats_for(&stats, language_id, None).map(|s| {
+            ServerIdentity::new(
+                s.server_name.as_deref().unwrap_or(language_id),
+                s.server_version.as_deref().unwrap_or("unknown"),
+            )
+        })
+    }
+
     /// 言語のCapabilities情報を取得
     pub fn get_capabilities_for_language(&self, language_id: &str) -> Option<CapabilitiesSummary> {
         let clients = self.clients.lock().unwrap();
@@ -311,14 +396,17 @@ impl LspClientPool {
         project_root: &Path,
     ) -> Result<GenericLspClient> {
         // 言語IDからアダプターを作成
-        let adapter = match language_id {
-            "rust" => detect_language("file.rs"),
-            "typescript" => detect_language("file.ts"),
-            "javascript" => detect_language("file.js"),
-            "python" => detect_language("file.py"),
-            "go" => detect_language("file.go"),
-            "java" => detect_language("file.java"),
-            _ => None,
+        let adapter = match &self.adapter_factory {
+            Some(factory) => factory(language_id),
+            None => match language_id {
+                "rust" => detect_language("file.rs"),
+                "typescript" => detect_language("file.ts"),
+                "javascript" => detect_language("file.js"),
+                "python" => detect_language("file.py"),
+                "go" => detect_language("file.go"),
+                "java" => detect_language("file.java"),
+                _ => None,
+            },
         }
         .ok_or_else(|| anyhow::anyhow!("Unsupported language: {}", language_id))?;
 
@@ -326,12 +414,39 @@ impl LspClientPool {
         let mut client = GenericLspClient::new_uninit(adapter)
             .with_context(|| format!("Failed to create {} LSP client", language_id))?;
 
+        // 学習済みの統計で初期化前に学習状態を初期化し、初期化時間をタイムアウトに反映
+        // （バージョンは初期化前なので不明）
+        let init_timeout = {
+            let stats = self.timing_stats.lock().unwrap();
+            let learned = find_stats_for(&stats, language_id, None);
+            if let Some(learned) = learned {
+                debug!("Seeding {} LSP client with learned timings", language_id);
+                client.seed_timing_stats(learned);
+            }
+            learned
+                .and_then(|s| s.init_percentiles())
+                .map(|p| (p.p99 * 2).clamp(Duration::from_secs(1), Duration::from_secs(60)))
+                .unwrap_or(self.config.init_timeout)
+        };
+
         // プロジェクトルートを指定して初期化
         let init_start = Instant::now();
         client
-            .initialize(project_root, Some(self.config.init_timeout))
+            .initialize(project_root, Some(init_timeout))
             .with_context(|| format!("Failed to initialize {} LSP client", language_id))?;
 
+        // サーバーバージョンに一致する統計があれば引き継ぐ値を差し替える
+        // （初期化で測った時間は引き継いだ値とは別に持つので上書きされない）
+        {
+            let stats = self.timing_stats.lock().unwrap();
+            let version = client.get_server_info().and_then(|i| i.version.clone());
+            if version.is_some() {
+                if let Some(learned) = find_stats_for(&stats, language_id, version.as_deref()) {
+                    client.seed_timing_stats(learned);
+                }
+            }
+        }
+
         let init_duration = init_start.elapsed();
         info!(
             "LSP client for {} initialized in {:?}",
@@ -386,15 +501,20 @@ impl LspClientPool {
     }
 
     /// すべてのクライアントをシャットダウン
+    ///
+    /// サーバーのプロセスは手放しても終了しないので、`shutdown`と`exit`を送ってから手放す。
     pub fn shutdown_all(&self) {
         let mut clients = self.clients.lock().unwrap();
 
-        for language_id in clients.keys().cloned().collect::<Vec<_>>() {
+        for (language_id, instances) in clients.drain() {
             info!("Shutting down LSP client for {}", language_id);
+            for pooled in instances {
+                match pooled.client.lock() {
+                    Ok(mut client) => client.terminate(SHUTDOWN_GRACE),
+                    Err(poisoned) => poisoned.into_inner().terminate(SHUTDOWN_GRACE),
+                }
+            }
         }
-
-        // クライアントをクリア（デストラクタがシャットダウンを処理）
-        clients.clear();
     }
 
     /// 統計情報を取得
@@ -0,0 +1,637 @@
+/// LSP応答のコンテンツアドレス型永続キャッシュ
+///
+/// `HierarchicalCache`はファイルパスで無効化するメモリ上のキャッシュなので、
+/// CLIの実行をまたいだりブランチを切り替えたりすると結果が失われる。
+/// ここでは (サーバー名, サーバーバージョン, ファイル内容のハッシュ, 依存関係の指紋)
+/// をキーにしてディスクに保存し、同じ内容のファイルならLSPを呼ばずに済ませる。
+use crate::adapter::lsp::get_language_id;
+use anyhow::{Context, Result};
+use serde::{de::DeserializeOwned, Deserialize, Serialize};
+use std::path::{Path, PathBuf};
+use std::sync::atomic::{AtomicU64, Ordering};
+use tracing::{debug, info, warn};
+use xxhash_rust::xxh3::{xxh3_128, xxh3_64};
+
+/// デフォルトの最大キャッシュサイズ（256MB）
+pub const DEFAULT_MAX_CACHE_BYTES: u64 = 256 * 1024 * 1024;
+
+/// 保存形式のバージョン（位置を正規のUTF-8に揃えた時点で2）
+const CACHE_FORMAT_VERSION: u32 = 2;
+
+/// 依存関係の指紋に含めるファイル（プロジェクトルート直下）
+const DEPENDENCY_FILES: &[&str] = &[
+    "Cargo.toml",
+    "Cargo.lock",
+    "package.json",
+    "package-lock.json",
+    "pnpm-lock.yaml",
+    "yarn.lock",
+    "bun.lockb",
+    "tsconfig.json",
+    "go.mod",
+    "go.sum",
+    "pyproject.toml",
+    "requirements.txt",
+    "poetry.lock",
+    "uv.lock",
+];
+
+/// ワークスペースの指紋から除外するディレクトリ
+const EXCLUDE_DIRS: &[&str] = &[".git", "target", "node_modules", ".vscode", ".idea"];
+
+/// LSPサーバーの識別情報（initializeのserverInfo）
+#[derive(Debug, Clone, PartialEq, Eq, Hash, Serialize, Deserialize)]
+pub struct ServerIdentity {
+    pub name: String,
+    pub version: String,
+}
+
+impl ServerIdentity {
+    pub fn new(name: &str, version: &str) -> Self {
+        Self {
+            name: name.to_string(),
+            version: version.to_string(),
+        }
+    }
+
+    /// serverInfoから作成（名前がなければ言語ID、バージョンがなければ"unknown"）
+    pub fn from_server_info(language_id: &str, info: Option<&lsp_types::ServerInfo>) -> Self {
+        Self {
+            name: info
+                .map(|i| i.name.clone())
+                .unwrap_or_else(|| language_id.to_string()),
+            version: info
+                .and_then(|i| i.version.clone())
+                .unwrap_or_else(|| "unknown".to_string()),
+        }
+    }
+}
+
+/// キャッシュするリクエストの種類
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum CachedRequest {
+    DocumentSymbol,
+    Definition {
+        line: u32,
+        character: u32,
+    },
+    References {
+        line: u32,
+        character: u32,
+        include_declaration: bool,
+    },
+}
+
+impl CachedRequest {
+    fn tag(&self) -> String {
+        match self {
+            CachedRequest::DocumentSymbol => "documentSymbol".to_string(),
+            CachedRequest::Definition { line, character } => {
+                format!("definition:{}:{}", line, character)
+            }
+            CachedRequest::References {
+                line,
+                character,
+                include_declaration,
+            } => format!("references:{}:{}:{}", line, character, include_declaration),
+        }
+    }
+}
+
+/// キャッシュのキー
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct ResultCacheKey {
+    pub server: ServerIdentity,
+    /// ファイル内容のハッシュ（`content_hash`で計算）
+    pub content_hash: String,
+    /// 依存関係の指紋（`dependency_fingerprint`で計算）
+    pub dependency_fingerprint: String,
+    pub request: CachedRequest,
+}
+
+impl ResultCacheKey {
+    pub fn new(
+        server: ServerIdentity,
+        content_hash: &str,
+        dependency_fingerprint: &str,
+        request: CachedRequest,
+    ) -> Self {
+        Self {
+            server,
+            content_hash: content_hash.to_string(),
+            dependency_fingerprint: dependency_fingerprint.to_string(),
+            request,
+        }
+    }
+
+    /// ストレージ上のキー（各要素をまとめたハッシュ）
+    pub fn digest(&self) -> String {
+        let material = format!(
+            "{}\0{}\0{}\0{}\0{}\0{}",
+            CACHE_FORMAT_VERSION,
+            self.server.name,
+            self.server.version,
+            self.content_hash,
+            self.dependency_fingerprint,
+            self.request.tag()
+        );
+        format!("{:032x}", xxh3_128(material.as_bytes()))
+    }
+}
+
+/// キャッシュの統計
+#[derive(Debug, Clone, Default)]
+pub struct ResultCacheStats {
+    pub entries: usize,
+    pub total_bytes: u64,
+    pub max_bytes: u64,
+    pub hits: u64,
+    pub misses: u64,
+    pub evictions: u64,
+}
+
+/// ディスク上の永続キャッシュ（LRUでサイズ上限を管理）
+pub struct LspResultCache {
+    db: sled::Db,
+    /// digest -> JSONシリアライズした応答
+    entries: sled::Tree,
+    /// digest -> 最終アクセスの論理時刻
+    access: sled::Tree,
+    max_bytes: u64,
+    total_bytes: AtomicU64,
+    clock: AtomicU64,
+    hits: AtomicU64,
+    misses: AtomicU64,
+    evictions: AtomicU64,
+}
+
+impl LspResultCache {
+    /// キャッシュを開く（なければ作成）
+    pub fn open<P: AsRef<Path>>(dir: P, max_bytes: u64) -> Result<Self> {
+        let dir = dir.as_ref();
+        std::fs::create_dir_all(dir)
+            .with_context(|| format!("Failed to create LSP cache dir: {}", dir.display()))?;
+
+        let db = sled::open(dir)
+            .with_context(|| format!("Failed to open LSP cache: {}", dir.display()))?;
+        let entries = db.open_tree("entries")?;
+        let access = db.open_tree("access")?;
+
+        let mut total_bytes = 0u64;
+        for item in entries.iter() {
+            let (key, value) = item?;
+            total_bytes += (key.len() + value.len()) as u64;
+        }
+
+        let mut clock = 0u64;
+        for item in access.iter() {
+            let (_, value) = item?;
+            clock = clock.max(decode_tick(&value));
+        }
+
+        debug!(
+            "Opened LSP result cache at {} ({} entries, {} bytes)",
+            dir.display(),
+            entries.len(),
+            total_bytes
+        );
+
+        Ok(Self {
+            db,
+            entries,
+            access,
+            max_bytes,
+            total_bytes: AtomicU64::new(total_bytes),
+            clock: AtomicU64::new(clock + 1),
+            hits: AtomicU64::new(0),
+            misses: AtomicU64::new(0),
+            evictions: AtomicU64::new(0),
+        })
+    }
+
+    /// デフォルトの場所で開く
+    ///
+    /// `LSIF_LSP_CACHE_DIR`と`LSIF_LSP_CACHE_MAX_MB`で場所と上限を変更できる。
+    pub fn open_default() -> Result<Self> {
+        let max_bytes = std::env::var("LSIF_LSP_CACHE_MAX_MB")
+            .ok()
+            .and_then(|v| v.parse::<u64>().ok())
+            .map(|mb| mb * 1024 * 1024)
+            .unwrap_or(DEFAULT_MAX_CACHE_BYTES);
+        Self::open(default_cache_dir(), max_bytes)
+    }
+
+    /// キャッシュから応答を取得
+    pub fn get<T: DeserializeOwned>(&self, key: &ResultCacheKey) -> Option<T> {
+        let digest = key.digest();
+        let value = match self.entries.get(digest.as_bytes()) {
+            Ok(Some(value)) => value,
+            Ok(None) => {
+                self.misses.fetch_add(1, Ordering::Relaxed);
+                return None;
+            }
+            Err(e) => {
+                warn!("Failed to read LSP result cache: {}", e);
+                self.misses.fetch_add(1, Ordering::Relaxed);
+                return None;
+            }
+        };
+
+        match serde_json::from_slice(&value) {
+            Ok(result) => {
+                self.touch(&digest);
+                self.hits.fetch_add(1, Ordering::Relaxed);
+                debug!("LSP result cache hit: {:?}", key.request);
+                Some(result)
+            }
+            Err(e) => {
+                // 形式が変わった古いエントリは捨てる
+                debug!("Discarding unreadable LSP cache entry: {}", e);
+                self.remove(&digest);
+                self.misses.fetch_add(1, Ordering::Relaxed);
+                None
+            }
+        }
+    }
+
+    /// 応答をキャッシュに保存
+    pub fn put<T: Serialize>(&self, key: &ResultCacheKey, result: &T) -> Result<()> {
+        let digest = key.digest();
+        let value = serde_json::to_vec(result)?;
+        let size = (digest.len() + value.len()) as u64;
+
+        if size > self.max_bytes {
+            debug!("LSP result too large to cache ({} bytes)", size);
+            return Ok(());
+        }
+
+        if let Some(old) = self.entries.insert(digest.as_bytes(), value)? {
+            self.total_bytes
+                .fetch_sub((digest.len() + old.len()) as u64, Ordering::Relaxed);
+        }
+        self.total_bytes.fetch_add(size, Ordering::Relaxed);
+        self.touch(&digest);
+
+        if self.total_bytes.load(Ordering::Relaxed) > self.max_bytes {
+            self.evict()?;
+        }
+        Ok(())
+    }
+
+    /// キャッシュにあればそれを返し、なければ`fetch`の結果を保存して返す
+    ///
+    /// `fetch`が失敗した時は何も保存しない。
+    pub fn get_or_fetch<T, F>(&self, key: &ResultCacheKey, fetch: F) -> Result<T>
+    where
+        T: Serialize + DeserializeOwned,
+        F: FnOnce() -> Result<T>,
+    {
+        if let Some(result) = self.get(key) {
+            return Ok(result);
+        }
+        let result = fetch()?;
+        if let Err(e) = self.put(key, &result) {
+            warn!("Failed to store LSP result in cache: {}", e);
+        }
+        Ok(result)
+    }
+
+    /// ディスクに書き出す
+    pub fn flush(&self) -> Result<()> {
+        self.db.flush()?;
+        Ok(())
+    }
+
+    /// 全エントリを削除
+    pub fn clear(&self) -> Result<()> {
+        self.entries.clear()?;
+        self.access.clear()?;
+        self.total_bytes.store(0, Ordering::Relaxed);
+        info!("Cleared LSP result cache");
+        Ok(())
+    }
+
+    /// 統計を取得
+    pub fn stats(&self) -> ResultCacheStats {
+        ResultCacheStats {
+            entries: self.entries.len(),
+            total_bytes: self.total_bytes.load(Ordering::Relaxed),
+            max_bytes: self.max_bytes,
+            hits: self.hits.load(Ordering::Relaxed),
+            misses: self.misses.load(Ordering::Relaxed),
+            evictions: self.evictions.load(Ordering::Relaxed),
+        }
+    }
+
+    fn touch(&self, digest: &str) {
+        let tick = self.clock.fetch_add(1, Ordering::Relaxed);
+        if let Err(e) = self
+            .access
+            .insert(digest.as_bytes(), &tick.to_be_bytes()[..])
+        {
+            warn!("Failed to update LSP cache access time: {}", e);
+        }
+    }
+
+    fn remove(&self, digest: &str) {
+        if let Ok(Some(old)) = self.entries.remove(digest.as_bytes()) {
+            self.total_bytes
+                .fetch_sub((digest.len() + old.len()) as u64, Ordering::Relaxed);
+        }
+        let _ = self.access.remove(digest.as_bytes());
+    }
+
+    /// 最も長く使われていないエントリから上限の9割まで削除
+    fn evict(&self) -> Result<()> {
+        let target = self.max_bytes / 10 * 9;
+
+        let mut by_age: Vec<(u64, String)> = Vec::new();
+        for item in self.access.iter() {
+            let (key, value) = item?;
+            by_age.push((
+                decode_tick(&value),
+                String::from_utf8_lossy(&key).into_owned(),
+            ));
+        }
+        by_age.sort_unstable();
+
+        let mut evicted = 0u64;
+        for (_, digest) in by_age {
+            if self.total_bytes.load(Ordering::Relaxed) <= target {
+                break;
+            }
+            self.remove(&digest);
+            evicted += 1;
+        }
+
+        self.evictions.fetch_add(evicted, Ordering::Relaxed);
+        debug!("Evicted {} LSP cache entries", evicted);
+        Ok(())
+    }
+}
+
+fn decode_tick(bytes: &[u8]) -> u64 {
+    bytes.try_into().map(u64::from_be_bytes).unwrap_or_default()
+}
+
+/// デフォルトのキャッシュディレクトリ
+///
+/// ブランチやチェックアウトをまたいで共有できるよう、プロジェクトの外に置く。
+pub fn default_cache_dir() -> PathBuf {
+    if let Ok(dir) = std::env::var("LSIF_LSP_CACHE_DIR") {
+        return PathBuf::from(dir);
+    }
+
+    let base = std::env::var("XDG_CACHE_HOME")
+        .map(PathBuf::from)
+        .or_else(|_| std::env::var("HOME").map(|home| PathBuf::from(home).join(".cache")))
+        .unwrap_or_else(|_| std::env::temp_dir());
+    base.join("lsif-indexer").join("lsp-results")
+}
+
+/// ファイル内容のハッシュ（`GitDiffDetector::calculate_file_hash`と同じ形式）
+pub fn content_hash(content: &[u8]) -> String {
+    format!("{:016x}", xxh3_64(content))
+}
+
+/// 依存関係の指紋（マニフェストとロックファイルの内容から計算）
+pub fn dependency_fingerprint(project_root: &Path) -> String {
+    let mut material = Vec::new();
+    for name in DEPENDENCY_FILES {
+        if let Ok(content) = std::fs::read(project_root.join(name)) {
+            material.extend_from_slice(name.as_bytes());
+            material.push(0);
+            material.extend_from_slice(content_hash(&content).as_bytes());
+            material.push(0);
+        }
+    }
+    content_hash(&material)
+}
+
+/// ワークスペース全体の指紋（ソースファイルのパスと内容から計算）
+///
+/// 定義や参照の応答は他のファイルにも依存するので、リクエストしたファイルの
+/// ハッシュの代わりにこれをキーに使う。どこか1ファイルでも変われば別のキーになる。
+pub fn workspace_fingerprint(project_root: &Path) -> String {
+    let mut files: Vec<PathBuf> = walkdir::WalkDir::new(project_root)
+        .follow_links(false)
+        .into_iter()
+        .filter_entry(|e| {
+            e.file_name()
+                .to_str()
+                .map_or(true, |name| !EXCLUDE_DIRS.contains(&name))
+        })
+        .filter_map(|e| e.ok())
+        .filter(|e| e.file_type().is_file() && get_language_id(e.path()).is_some())
+        .map(|e| e.into_path())
+        .collect();
+    files.sort();
+
+    let mut material = Vec::new();
+    for path in files {
+        if let Ok(content) = std::fs::read(&path) {
+            let relative = path.strip_prefix(project_root).unwrap_or(&path);
+            material.extend_from_slice(relative.to_string_lossy().as_bytes());
+            material.push(0);
+            material.extend_from_slice(content_hash(&content).as_bytes());
+            material.push(0);
+        }
+    }
+    content_hash(&material)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use tempfile::TempDir;
+
+    fn key(content: &str, version: &str) -> ResultCacheKey {
+        ResultCacheKey::new(
+            ServerIdentity::new("rust-analyzer", version),
+            &content_hash(content.as_bytes()),
+            "deps",
+            CachedRequest::DocumentSymbol,
+        )
+    }
+
+    #[test]
+    fn test_roundtrip_and_reopen() {
+        let dir = TempDir::new().unwrap();
+        {
+            let cache = LspResultCache::open(dir.path(), DEFAULT_MAX_CACHE_BYTES).unwrap();
+            assert!(cache
+                .get::<Vec<String>>(&key("fn main() {}", "1.0"))
+                .is_none());
+            cache
+                .put(&key("fn main() {}", "1.0"), &vec!["main".to_string()])
+                .unwrap();
+            cache.flush().unwrap();
+        }
+
+        // 実行をまたいでも同じ内容なら取得できる
+        let cache = LspResultCache::open(dir.path(), DEFAULT_MAX_CACHE_BYTES).unwrap();
+        assert_eq!(
+            cache.get::<Vec<String>>(&key("fn main() {}", "1.0")),
+            Some(vec!["main".to_string()])
+        );
+
+        // 内容やサーバーバージョンが違えば別のエントリ
+        assert!(cache
+            .get::<Vec<String>>(&key("fn other() {}", "1.0"))
+            .is_none());
+        assert!(cache
+            .get::<Vec<String>>(&key("fn main() {}", "2.0"))
+            .is_none());
+
+        let stats = cache.stats();
+        assert_eq!(stats.entries, 1);
+        assert_eq!(stats.hits, 1);
+        assert_eq!(stats.misses, 2);
+    }
+
+    #[test]
+    fn test_request_is_part_of_key() {
+        let base = key("x", "1.0");
+        let definition = ResultCacheKey {
+            request: CachedRequest::Definition {
+                line: 1,
+                character: 2,
+            },
+            ..base.clone()
+        };
+        assert_ne!(base.digest(), definition.digest());
+    }
+
+    fn location(uri: &str, line: u32) -> lsp_types::Location {
+        lsp_types::Location {
+            uri: uri.parse().unwrap(),
+            range: lsp_types::Range {
+                start: lsp_types::Position { line, character: 0 },
+                end: lsp_types::Position { line, character: 4 },
+            },
+        }
+    }
+
+    #[test]
+    fn test_definition_and_references_hit_and_invalidate() {
+        let project = TempDir::new().unwrap();
+        let cache_dir = TempDir::new().unwrap();
+        let cache = LspResultCache::open(cache_dir.path(), DEFAULT_MAX_CACHE_BYTES).unwrap();
+        std::fs::write(project.path().join("a.rs"), "fn a() { b(); }").unwrap();
+        std::fs::write(project.path().join("b.rs"), "pub fn b() {}").unwrap();
+
+        let server = ServerIdentity::new("rust-analyzer", "1.0");
+        let definition = CachedRequest::Definition {
+            line: 0,
+            character: 9,
+        };
+        let references = CachedRequest::References {
+            line: 0,
+            character: 7,
+            include_declaration: false,
+        };
+        let key_for = |request, deps: &str| {
+            ResultCacheKey::new(
+                server.clone(),
+                &workspace_fingerprint(project.path()),
+                deps,
+                request,
+            )
+        };
+
+        let fetch_definition = |deps: &str, calls: &mut usize| {
+            cache
+                .get_or_fetch(&key_for(definition, deps), || {
+                    *calls += 1;
+                    Ok(location("file:///b.rs", 0))
+                })
+                .unwrap()
+        };
+        let mut definition_calls = 0;
+        let first = fetch_definition("deps", &mut definition_calls);
+        assert_eq!(first, location("file:///b.rs", 0));
+        assert_eq!(fetch_definition("deps", &mut definition_calls), first);
+        assert_eq!(definition_calls, 1);
+
+        // 依存関係が変われば問い合わせ直す
+        fetch_definition("deps-2", &mut definition_calls);
+        assert_eq!(definition_calls, 2);
+
+        let fetch_references = |calls: &mut usize| {
+            cache
+                .get_or_fetch(&key_for(references, "deps"), || {
+                    *calls += 1;
+                    Ok(vec![location("file:///a.rs", 0)])
+                })
+                .unwrap()
+        };
+        let mut reference_calls = 0;
+        fetch_references(&mut reference_calls);
+        fetch_references(&mut reference_calls);
+        assert_eq!(reference_calls, 1);
+
+        // 参照元の別ファイルが変われば無効になる
+        std::fs::write(project.path().join("b.rs"), "pub fn b() { b(); }").unwrap();
+        fetch_references(&mut reference_calls);
+        assert_eq!(reference_calls, 2);
+
+        // 失敗した問い合わせは保存しない
+        let failed: Result<Vec<lsp_types::Location>> = cache
+            .get_or_fetch(&key_for(references, "other"), || {
+                Err(anyhow::anyhow!("timeout"))
+            });
+        assert!(failed.is_err());
+        assert!(cache
+            .get::<Vec<lsp_types::Location>>(&key_for(references, "other"))
+            .is_none());
+    }
+
+    #[test]
+    fn test_workspace_fingerprint_ignores_build_output() {
+        let dir = TempDir::new().unwrap();
+        std::fs::write(dir.path().join("main.rs"), "fn main() {}").unwrap();
+        let before = workspace_fingerprint(dir.path());
+
+        std::fs::create_dir(dir.path().join("target")).unwrap();
+        std::fs::write(dir.path().join("target").join("gen.rs"), "x").unwrap();
+        std::fs::write(dir.path().join("notes.txt"), "x").unwrap();
+        assert_eq!(before, workspace_fingerprint(dir.path()));
+
+        std::fs::write(dir.path().join("main.rs"), "fn main() { }").unwrap();
+        assert_ne!(before, workspace_fingerprint(dir.path()));
+    }
+
+    #[test]
+    fn test_lru_eviction() {
+        let dir = TempDir::new().unwrap();
+        let payload = "x".repeat(100);
+        let cache = LspResultCache::open(dir.path(), 500).unwrap();
+
+        cache.put(&key("a", "1"), &payload).unwrap();
+        cache.put(&key("b", "1"), &payload).unwrap();
+        // aを使ってbより新しくする
+        assert!(cache.get::<String>(&key("a", "1")).is_some());
+        cache.put(&key("c", "1"), &payload).unwrap();
+        cache.put(&key("d", "1"), &payload).unwrap();
+
+        let stats = cache.stats();
+        assert!(stats.total_bytes <= 500);
+        assert!(stats.evictions > 0);
+        assert!(cache.get::<String>(&key("b", "1")).is_none());
+        assert!(cache.get::<String>(&key("a", "1")).is_some());
+        assert!(cache.get::<String>(&key("d", "1")).is_some());
+    }
+
+    #[test]
+    fn test_dependency_fingerprint_changes_with_lockfile() {
+        let dir = TempDir::new().unwrap();
+        let empty = dependency_fingerprint(dir.path());
+
+        std::fs::write(dir.path().join("Cargo.lock"), "version = 3").unwrap();
+        let locked = dependency_fingerprint(dir.path());
+        assert_ne!(empty, locked);
+
+        std::fs::write(dir.path().join("Cargo.lock"), "version = 4").unwrap();
+        assert_ne!(locked, dependency_fingerprint(dir.path()));
+    }
+}
@@ -0,0 +1,265 @@
+/// 学習したLSPの応答時間統計（永続化用）
+///
+/// `LspHealthChecker`と`TimeoutPredictor`はメモリ上でしか学習しないため、
+/// CLIの起動ごとにデフォルトタイムアウトから始まってしまう。
+/// 言語とサーバーバージョンごとにサンプルを保存し、次回の起動時に読み込む。
+use chrono::{DateTime, Utc};
+use serde::{Deserialize, Serialize};
+use std::collections::HashMap;
+use std::time::Duration;
+
+use crate::lsp_health_check::LspOperationType;
+
+/// 操作ごとに保持する最大サンプル数
+pub const MAX_TIMING_SAMPLES: usize = 200;
+
+/// 言語・サーバーごとの応答時間統計
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct LspTimingStats {
+    /// 言語ID
+    pub language: String,
+    /// サーバー名（initializeのserverInfo）
+    pub server_name: Option<String>,
+    /// サーバーバージョン（initializeのserverInfo）
+    pub server_version: Option<String>,
+    /// 初期化時間のサンプル（ミリ秒）
+    pub init_times_ms: Vec<u64>,
+    /// 操作種別ごとの応答時間サンプル（ミリ秒）
+    pub operation_times_ms: HashMap<LspOperationType, Vec<u64>>,
+    /// バイトあたりの処理時間（TimeoutPredictor）
+    pub ms_per_byte: Option<f64>,
+    /// 行あたりの処理時間（TimeoutPredictor）
+    pub ms_per_line: Option<f64>,
+    /// 最終更新時刻
+    pub updated_at: DateTime<Utc>,
+}
+
+/// パーセンタイルのサマリー
+#[derive(Debug, Clone, Copy, PartialEq)]
+pub struct TimingPercentiles {
+    pub count: usize,
+    pub p50: Duration,
+    pub p90: Duration,
+    pub p99: Duration,
+}
+
+impl LspTimingStats {
+    pub fn new(language: &str) -> Self {
+        Self {
+            language: language.to_string(),
+            server_name: None,
+            server_version: None,
+            init_times_ms: Vec::new(),
+            operation_times_ms: HashMap::new(),
+            ms_per_byte: None,
+            ms_per_line: None,
+            updated_at: Utc::now(),
+        }
+    }
+
+    /// 保存用のキー（言語@バージョン）
+    pub fn key(&self) -> String {
+        Self::key_for(&self.language, self.server_version.as_deref())
+    }
+
+    /// 言語とバージョンから保存用のキーを作る
+    pub fn key_for(language: &str, server_version: Option<&str>) -> String {
+        format!("{}@{}", language, server_version.unwrap_or("unknown"))
+    }
+
+    /// サンプルも学習済みのレートも1件もないか
+    pub fn is_empty(&self) -> bool {
+        self.init_times_ms.is_empty()
+            && self.operation_times_ms.values().all(|v| v.is_empty())
+            && self.ms_per_byte.is_none()
+            && self.ms_per_line.is_none()
+    }
+
+    /// 初期化時間を記録
+    pub fn record_init(&mut self, duration: Duration) {
+        push_sample(&mut self.init_times_ms, duration);
+    }
+
+    /// 操作の応答時間を記録
+    pub fn record_operation(&mut self, op_type: LspOperationType, duration: Duration) {
+        push_sample(
+            self.operation_times_ms.entry(op_type).or_default(),
+            duration,
+        );
+    }
+
+    /// 別の統計（新しいもの）をマージする
+    pub fn merge(&mut self, newer: &LspTimingStats) {
+        if newer.server_name.is_some() {
+            self.server_name = newer.server_name.clone();
+        }
+        if newer.server_version.is_some() {
+            self.server_version = newer.server_version.clone();
+        }
+
+        for ms in &newer.init_times_ms {
+            push_sample(&mut self.init_times_ms, Duration::from_millis(*ms));
+        }
+        for (op_type, samples) in &newer.operation_times_ms {
+            let target = self.operation_times_ms.entry(*op_type).or_default();
+            for ms in samples {
+                push_sample(target, Duration::from_millis(*ms));
+            }
+        }
+
+        if newer.ms_per_byte.is_some() {
+            self.ms_per_byte = newer.ms_per_byte;
+        }
+        if newer.ms_per_line.is_some() {
+            self.ms_per_line = newer.ms_per_line;
+        }
+        self.updated_at = self.updated_at.max(newer.updated_at);
+    }
+
+    /// 初期化時間のパーセンタイル
+    pub fn init_percentiles(&self) -> Option<TimingPercentiles> {
+        percentiles(&self.init_times_ms)
+    }
+
+    /// 操作種別ごとのパーセンタイル
+    pub fn operation_percentiles(&self, op_type: LspOperationType) -> Option<TimingPercentiles> {
+        self.operation_times_ms
+            .get(&op_type)
+            .and_then(|samples| percentiles(samples))
+    }
+}
+
+/// サンプルを追加し、上限を超えたら古いものから捨てる
+fn push_sample(samples: &mut Vec<u64>, duration: Duration) {
+    samples.push(duration.as_millis() as u64);
+    if samples.len() > MAX_TIMING_SAMPLES {
+        let excess = samples.len() - MAX_TIMING_SAMPLES;
+        samples.drain(..excess);
+    }
+}
+
+/// サンプルからp50/p90/p99を計算（nearest-rank法）
+pub fn percentiles(samples: &[u64]) -> Option<TimingPercentiles> {
+    if samples.is_empty() {
+        return None;
+    }
+
+    let mut sorted = samples.to_vec();
+    sorted.sort_unstable();
+
+    let rank = |p: f64| -> Duration {
+        let index = ((p * sorted.len() as f64).ceil() as usize).clamp(1, sorted.len()) - 1;
+        Duration::from_millis(sorted[index])
+    };
+
+    Some(TimingPercentiles {
+        count: sorted.len(),
+        p50: rank(0.50),
+        p90: rank(0.90),
+        p99: rank(0.99),
+    })
+}
+
+/// 言語に一致する統計を探す（バージョン一致を優先し、なければ最新のもの）
+pub fn find_stats_for<'a>(
+    stats: &'a HashMap<String, LspTimingStats>,
+    language: &str,
+    server_version: Option<&str>,
+) -> Option<&'a LspTimingStats> {
+    if server_version.is_some() {
+        if let Some(exact) = stats.get(&LspTimingStats::key_for(language, server_version)) {
+            return Some(exact);
+        }
+    }
+
+    stats
+        .values()
+        .filter(|s| s.language == language)
+        .max_by_key(|s| s.updated_at)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_percentiles() {
+        let samples: Vec<u64> = (1..=100).collect();
+        let p = percentiles(&samples).unwrap();
+
+        assert_eq!(p.count, 100);
+        assert_eq!(p.p50, Duration::from_millis(50));
+        assert_eq!(p.p90, Duration::from_millis(90));
+        assert_eq!(p.p99, Duration::from_millis(99));
+
+        assert!(percentiles(&[]).is_none());
+        assert_eq!(percentiles(&[7]).unwrap().p99, Duration::from_millis(7));
+    }
+
+    #[test]
+    fn test_sample_cap() {
+        let mut stats = LspTimingStats::new("rust");
+        for i in 0..(MAX_TIMING_SAMPLES + 10) {
+            stats.record_operation(
+                LspOperationType::DocumentSymbol,
+                Duration::from_millis(i as u64),
+            );
+        }
+
+        let samples = &stats.operation_times_ms[&LspOperationType::DocumentSymbol];
+        assert_eq!(samples.len(), MAX_TIMING_SAMPLES);
+        // 古いサンプルから捨てられる
+        assert_eq!(samples[0], 10);
+    }
+
+    #[test]
+    fn test_merge() {
+        let mut stored = LspTimingStats::new("go");
+        stored.record_init(Duration::from_millis(800));
+
+        let mut newer = LspTimingStats::new("go");
+        newer.server_version = Some("v0.15.0".to_string());
+        newer.record_init(Duration::from_millis(600));
+        newer.record_operation(LspOperationType::References, Duration::from_millis(120));
+        newer.ms_per_line = Some(0.2);
+
+        stored.merge(&newer);
+
+        assert_eq!(stored.init_times_ms, vec![800, 600]);
+        assert_eq!(stored.server_version.as_deref(), Some("v0.15.0"));
+        assert_eq!(stored.ms_per_line, Some(0.2));
+        assert_eq!(
+            stored
+                .operation_percentiles(LspOperationType::References)
+                .unwrap()
+                .count,
+            1
+        );
+    }
+
+    #[test]
+    fn test_is_empty_considers_rates() {
+        let mut stats = LspTimingStats::new("rust");
+        assert!(stats.is_empty());
+
+        stats.ms_per_byte = Some(0.01);
+        assert!(!stats.is_empty());
+    }
+
+    #[test]
+    fn test_find_stats_for() {
+        let mut map = HashMap::new();
+        let mut old = LspTimingStats::new("rust");
+        old.server_version = Some("1.0".to_string());
+        map.insert(old.key(), old);
+        let mut new = LspTimingStats::new("rust");
+        new.server_version = Some("2.0".to_string());
+        map.insert(new.key(), new);
+
+        let exact = find_stats_for(&map, "rust", Some("1.0")).unwrap();
+        assert_eq!(exact.server_version.as_deref(), Some("1.0"));
+
+        assert!(find_stats_for(&map, "rust", Some("3.0")).is_some());
+        assert!(find_stats_for(&map, "go", None).is_none());
+    }
+}
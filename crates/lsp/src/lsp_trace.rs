@@ -0,0 +1,400 @@
+/// JSON-RPCプロトコルのトレース
+///
+/// `--lsp-trace <file>`（環境変数`LSIF_LSP_TRACE`）が指定されると、
+/// 全クライアントの送受信メッセージをタイムスタンプ・サーバー情報・レイテンシ付きで
+/// JSONLとして書き出す。`lsif lsp-trace summarize`で遅いメソッドやエラーを集計できる。
+use anyhow::{Context, Result};
+use chrono::{DateTime, Utc};
+use once_cell::sync::OnceCell;
+use serde::{Deserialize, Serialize};
+use serde_json::Value;
+use std::collections::HashMap;
+use std::fs::File;
+use std::io::{BufRead, BufReader, BufWriter, Write};
+use std::path::Path;
+use std::sync::Mutex;
+use std::time::Duration;
+use tracing::warn;
+
+use crate::lsp_metrics::{LspMetricsCollector, OperationMetrics};
+use crate::lsp_timing_stats::{percentiles, TimingPercentiles};
+
+/// トレース出力先を指定する環境変数
+pub const LSP_TRACE_ENV: &str = "LSIF_LSP_TRACE";
+
+/// メッセージの方向
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum TraceDirection {
+    /// クライアント -> サーバー
+    Send,
+    /// サーバー -> クライアント
+    Receive,
+}
+
+/// メッセージの種類
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum TraceKind {
+    Request,
+    Response,
+    Notification,
+    /// 応答が来ないままタイムアウトしたリクエスト
+    Timeout,
+}
+
+/// トレースの1行
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct LspTraceEvent {
+    pub timestamp: DateTime<Utc>,
+    pub direction: TraceDirection,
+    pub kind: TraceKind,
+    pub language: String,
+    /// サーバー名@バージョン（initialize完了前は不明）
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub server: Option<String>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub method: Option<String>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub id: Option<i64>,
+    /// リクエスト送信から応答（またはタイムアウト）までの時間
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub latency_ms: Option<u64>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub error: Option<Value>,
+    /// params / result の本体
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub payload: Option<Value>,
+}
+
+impl LspTraceEvent {
+    pub fn new(direction: TraceDirection, kind: TraceKind, language: &str) -> Self {
+        Self {
+            timestamp: Utc::now(),
+            direction,
+            kind,
+            language: language.to_string(),
+            server: None,
+            method: None,
+            id: None,
+            latency_ms: None,
+            error: None,
+            payload: None,
+        }
+    }
+
+    pub fn with_server(mut self, server: Option<String>) -> Self {
+        self.server = server;
+        self
+    }
+
+    pub fn with_method(mut self, method: &str) -> Self {
+        self.method = Some(method.to_string());
+        self
+    }
+
+    pub fn with_id(mut self, id: i64) -> Self {
+        self.id = Some(id);
+        self
+    }
+
+    pub fn with_latency(mut self, latency: Duration) -> Self {
+        self.latency_ms = Some(latency.as_millis() as u64);
+        self
+    }
+
+    pub fn with_error(mut self, error: Option<Value>) -> Self {
+        self.error = error;
+        self
+    }
+
+    pub fn with_payload(mut self, payload: Option<Value>) -> Self {
+        self.payload = payload;
+        self
+    }
+}
+
+/// トレースの書き出し先（複数のクライアントから共有される）
+pub struct LspTracer {
+    writer: Mutex<BufWriter<File>>,
+}
+
+static GLOBAL_TRACER: OnceCell<Option<LspTracer>> = OnceCell::new();
+
+impl LspTracer {
+    /// ファイルを作成してトレースを開始
+    pub fn create<P: AsRef<Path>>(path: P) -> Result<Self> {
+        let path = path.as_ref();
+        let file = File::create(path)
+            .with_context(|| format!("Failed to create LSP trace file: {}", path.display()))?;
+        Ok(Self {
+            writer: Mutex::new(BufWriter::new(file)),
+        })
+    }
+
+    /// 環境変数`LSIF_LSP_TRACE`で指定されたトレーサーを取得（未指定ならNone）
+    pub fn global() -> Option<&'static LspTracer> {
+        GLOBAL_TRACER
+            .get_or_init(|| {
+                let path = std::env::var(LSP_TRACE_ENV).ok()?;
+                match Self::create(&path) {
+                    Ok(tracer) => Some(tracer),
+                    Err(e) => {
+                        warn!("LSP trace disabled: {}", e);
+                        None
+                    }
+                }
+            })
+            .as_ref()
+    }
+
+    /// イベントを1行書き出す（行単位でフラッシュし、異常終了でも残るようにする）
+    pub fn record(&self, event: &LspTraceEvent) {
+        let line = match serde_json::to_string(event) {
+            Ok(line) => line,
+            Err(e) => {
+                warn!("Failed to serialize LSP trace event: {}", e);
+                return;
+            }
+        };
+
+        let mut writer = self.writer.lock().unwrap();
+        if let Err(e) = writeln!(writer, "{}", line).and_then(|_| writer.flush()) {
+            warn!("Failed to write LSP trace: {}", e);
+        }
+    }
+}
+
+/// トレースファイルを読み込む（壊れた行は読み飛ばす）
+pub fn read_trace<P: AsRef<Path>>(path: P) -> Result<Vec<LspTraceEvent>> {
+    let path = path.as_ref();
+    let file = File::open(path)
+        .with_context(|| format!("Failed to open LSP trace: {}", path.display()))?;
+
+    let mut events = Vec::new();
+    for (line_no, line) in BufReader::new(file).lines().enumerate() {
+        let line = line?;
+        if line.trim().is_empty() {
+            continue;
+        }
+        match serde_json::from_str(&line) {
+            Ok(event) => events.push(event),
+            Err(e) => warn!("Skipping invalid trace line {}: {}", line_no + 1, e),
+        }
+    }
+    Ok(events)
+}
+
+/// メソッドごとの集計
+#[derive(Debug, Clone)]
+pub struct MethodTraceSummary {
+    pub method: String,
+    pub metrics: OperationMetrics,
+    pub latency: Option<TimingPercentiles>,
+    pub errors: u64,
+    pub timeouts: u64,
+}
+
+/// 個別の遅いリクエスト・エラー・タイムアウト
+#[derive(Debug, Clone)]
+pub struct TraceIncident {
+    pub timestamp: DateTime<Utc>,
+    pub server: String,
+    pub method: String,
+    pub id: Option<i64>,
+    pub latency_ms: u64,
+    pub error: Option<Value>,
+}
+
+/// トレース全体のサマリー
+#[derive(Debug, Clone, Default)]
+pub struct TraceSummary {
+    pub total_events: usize,
+    pub requests: u64,
+    pub notifications: u64,
+    /// サーバー（名前@バージョン、なければ言語）ごとのリクエスト数
+    pub servers: HashMap<String, u64>,
+    /// 平均レイテンシの降順
+    pub methods: Vec<MethodTraceSummary>,
+    /// レイテンシの降順
+    pub slowest: Vec<TraceIncident>,
+    pub errors: Vec<TraceIncident>,
+    pub timeouts: Vec<TraceIncident>,
+}
+
+/// トレースを集計する（完了したリクエストは`collector`にも記録する）
+pub fn summarize_trace(
+    events: &[LspTraceEvent],
+    collector: &LspMetricsCollector,
+    top_n: usize,
+) -> TraceSummary {
+    let mut summary = TraceSummary {
+        total_events: events.len(),
+        ..Default::default()
+    };
+    let mut latencies: HashMap<String, Vec<u64>> = HashMap::new();
+    let mut errors: HashMap<String, u64> = HashMap::new();
+    let mut timeouts: HashMap<String, u64> = HashMap::new();
+    let mut completed = Vec::new();
+
+    for event in events {
+        let method = event
+            .method
+            .clone()
+            .unwrap_or_else(|| "unknown".to_string());
+        let server = event
+            .server
+            .clone()
+            .unwrap_or_else(|| event.language.clone());
+
+        match (event.direction, event.kind) {
+            (TraceDirection::Send, TraceKind::Request) => {
+                summary.requests += 1;
+                *summary.servers.entry(server).or_default() += 1;
+            }
+            (_, TraceKind::Notification) => summary.notifications += 1,
+            // メソッドが分からない古い応答は集計しない
+            (TraceDirection::Receive, TraceKind::Response) if event.method.is_some() => {
+                let latency_ms = event.latency_ms.unwrap_or_default();
+                let success = event.error.is_none();
+                collector.record_operation_complete(
+                    &method,
+                    Duration::from_millis(latency_ms),
+                    success,
+                );
+                latencies
+                    .entry(method.clone())
+                    .or_default()
+                    .push(latency_ms);
+
+                let incident = TraceIncident {
+                    timestamp: event.timestamp,
+                    server,
+                    method: method.clone(),
+                    id: event.id,
+                    latency_ms,
+                    error: event.error.clone(),
+                };
+                if !success {
+                    *errors.entry(method).or_default() += 1;
+                    summary.errors.push(incident.clone());
+                }
+                completed.push(incident);
+            }
+            (_, TraceKind::Timeout) => {
+                let latency_ms = event.latency_ms.unwrap_or_default();
+                collector.record_operation_complete(
+                    &method,
+                    Duration::from_millis(latency_ms),
+                    false,
+                );
+                *timeouts.entry(method.clone()).or_default() += 1;
+                summary.timeouts.push(TraceIncident {
+                    timestamp: event.timestamp,
+                    server,
+                    method,
+                    id: event.id,
+                    latency_ms,
+                    error: None,
+                });
+            }
+            _ => {}
+        }
+    }
+
+    summary.methods = collector
+        .operation_metrics()
+        .into_iter()
+        .map(|(method, metrics)| MethodTraceSummary {
+            latency: latencies.get(&method).and_then(|l| percentiles(l)),
+            errors: errors.get(&method).copied().unwrap_or_default(),
+            timeouts: timeouts.get(&method).copied().unwrap_or_default(),
+            method,
+            metrics,
+        })
+        .collect();
+    summary
+        .methods
+        .sort_by(|a, b| b.metrics.avg_duration.cmp(&a.metrics.avg_duration));
+
+    completed.sort_by(|a, b| b.latency_ms.cmp(&a.latency_ms));
+    completed.truncate(top_n);
+    summary.slowest = completed;
+
+    summary
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use tempfile::TempDir;
+
+    fn response(method: &str, latency_ms: u64, error: Option<Value>) -> LspTraceEvent {
+        LspTraceEvent::new(TraceDirection::Receive, TraceKind::Response, "go")
+            .with_server(Some("gopls@v0.15.0".to_string()))
+            .with_method(method)
+            .with_latency(Duration::from_millis(latency_ms))
+            .with_error(error)
+    }
+
+    #[test]
+    fn test_write_and_read_trace() {
+        let dir = TempDir::new().unwrap();
+        let path = dir.path().join("trace.jsonl");
+
+        let tracer = LspTracer::create(&path).unwrap();
+        tracer.record(
+            &LspTraceEvent::new(TraceDirection::Send, TraceKind::Request, "go")
+                .with_method("textDocument/documentSymbol")
+                .with_id(1),
+        );
+        tracer.record(&response("textDocument/documentSymbol", 42, None));
+        drop(tracer);
+
+        // 壊れた行があっても読み飛ばす
+        let mut content = std::fs::read_to_string(&path).unwrap();
+        content.push_str("{not json\n");
+        std::fs::write(&path, content).unwrap();
+
+        let events = read_trace(&path).unwrap();
+        assert_eq!(events.len(), 2);
+        assert_eq!(events[0].kind, TraceKind::Request);
+        assert_eq!(events[1].latency_ms, Some(42));
+    }
+
+    #[test]
+    fn test_summarize_trace() {
+        let events = vec![
+            LspTraceEvent::new(TraceDirection::Send, TraceKind::Request, "go")
+                .with_method("initialize"),
+            response("initialize", 900, None),
+            response("textDocument/documentSymbol", 20, None),
+            response(
+                "textDocument/references",
+                5,
+                Some(serde_json::json!({"code": -32603, "message": "boom"})),
+            ),
+            LspTraceEvent::new(TraceDirection::Send, TraceKind::Timeout, "go")
+                .with_method("workspace/symbol")
+                .with_latency(Duration::from_secs(2)),
+            LspTraceEvent::new(TraceDirection::Receive, TraceKind::Notification, "go")
+                .with_method("window/logMessage"),
+        ];
+
+        let collector = LspMetricsCollector::new();
+        let summary = summarize_trace(&events, &collector, 2);
+
+        assert_eq!(summary.requests, 1);
+        assert_eq!(summary.notifications, 1);
+        assert_eq!(summary.errors.len(), 1);
+        assert_eq!(summary.timeouts.len(), 1);
+        assert_eq!(summary.slowest.len(), 2);
+        assert_eq!(summary.slowest[0].method, "initialize");
+        // 平均レイテンシが最も大きいのはタイムアウトしたメソッド
+        assert_eq!(summary.methods[0].method, "workspace/symbol");
+        assert_eq!(summary.methods[0].timeouts, 1);
+
+        // メトリクスコレクタにも反映される
+        assert_eq!(collector.get_summary().total_requests, 4);
+    }
+}
@@ -0,0 +1,400 @@
+//! tree-sitterクエリファイルの読み込み
+//!
+//! 言語ごとのクエリ（`queries/<言語>/<種類>.scm`）は組み込みの既定値を持ち、
+//! 以下の順で見つかった最初のファイルで上書きできる。
+//!
+//! 1. プロジェクトの`.lsif/queries/<言語>/<種類>.scm`
+//! 2. 環境変数`LSIF_QUERY_DIR`のディレクトリ
+//! 3. ユーザー設定の`$XDG_CONFIG_HOME/lsif-indexer/queries`（既定は`~/.config`）
+//!
+//! 先頭行が`; extends`のファイルは組み込みのクエリに追記される。
+//! フレームワーク固有のキャプチャ（`@function.route`など）を足すときに使う。
+
+use anyhow::{anyhow, Result};
+use once_cell::sync::Lazy;
+use std::collections::{HashMap, HashSet};
+use std::fmt;
+use std::path::{Path, PathBuf};
+use std::sync::{Mutex, RwLock};
+use tree_sitter::{Language, Query};
+
+/// 組み込みのクエリがある言語
+pub const QUERY_LANGUAGES: &[&str] = &["rust", "typescript", "python", "go"];
+
+/// シンボルクエリで使えるキャプチャ名（`.`以降は自由に付けられる）
+const SYMBOL_CAPTURES: &[&str] = &[
+    "function",
+    "method",
+    "struct",
+    "enum",
+    "trait",
+    "class",
+    "interface",
+    "module",
+    "constant",
+    "variable",
+    "static",
+    "type_alias",
+    "parameter",
+    "impl",
+    "type",
+    "decorated",
+];
+
+/// 追記モードを示す先頭行
+const EXTENDS_MARKER: &str = "; extends";
+
+/// クエリの種類
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Hash)]
+pub enum QueryKind {
+    Symbols,
+    Definitions,
+    References,
+    Visibility,
+    Calls,
+    Imports,
+    Docs,
+}
+
+impl QueryKind {
+    pub const ALL: [QueryKind; 7] = [
+        QueryKind::Symbols,
+        QueryKind::Definitions,
+        QueryKind::References,
+        QueryKind::Visibility,
+        QueryKind::Calls,
+        QueryKind::Imports,
+        QueryKind::Docs,
+    ];
+
+    pub fn file_name(self) -> &'static str {
+        match self {
+            QueryKind::Symbols => "symbols.scm",
+            QueryKind::Definitions => "definitions.scm",
+            QueryKind::References => "references.scm",
+            QueryKind::Visibility => "visibility.scm",
+            QueryKind::Calls => "calls.scm",
+            QueryKind::Imports => "imports.scm",
+            QueryKind::Docs => "docs.scm",
+        }
+    }
+}
+
+/// クエリの出どころ
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub enum QueryOrigin {
+    Builtin,
+    /// 組み込みを置き換えたファイル
+    Override(PathBuf),
+    /// 組み込みに追記したファイル
+    Extends(PathBuf),
+}
+
+impl fmt::Display for QueryOrigin {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        match self {
+            QueryOrigin::Builtin => write!(f, "built-in"),
+            QueryOrigin::Override(path) => write!(f, "{}", path.display()),
+            QueryOrigin::Extends(path) => write!(f, "{} (extends built-in)", path.display()),
+        }
+    }
+}
+
+/// 解決済みのクエリ本文
+#[derive(Debug, Clone)]
+pub struct QuerySource {
+    pub language: &'static str,
+    pub kind: QueryKind,
+    pub text: String,
+    pub origin: QueryOrigin,
+    /// 上書きファイルの1行目が`text`の何行目か（エラー位置の換算用）
+    line_offset: usize,
+}
+
+impl QuerySource {
+    /// クエリをコンパイルして検証（エラーはファイルと行・列付き）
+    pub fn compile(&self, language: Language) -> Result<Query> {
+        let query = Query::new(language, &self.text).map_err(|e| {
+            let (location, row) = match &self.origin {
+                QueryOrigin::Builtin => (self.display_name(), e.row),
+                QueryOrigin::Override(path) | QueryOrigin::Extends(path) => {
+                    if e.row < self.line_offset {
+                        (format!("built-in {}", self.display_name()), e.row)
+                    } else {
+                        (path.display().to_string(), e.row - self.line_offset)
+                    }
+                }
+            };
+            anyhow!(
+                "{}:{}:{}: invalid {} query ({:?}): {}",
+                location,
+                row + 1,
+                e.column + 1,
+                self.language,
+                e.kind,
+                e.message.trim()
+            )
+        })?;
+
+        if self.kind == QueryKind::Symbols {
+            for name in query.capture_names() {
+                let base = name.split('.').next().unwrap_or(name);
+                if !name.starts_with('_') && !SYMBOL_CAPTURES.contains(&base) {
+                    return Err(anyhow!(
+                        "{}: unknown symbol capture @{} (expected one of {}; prefix with _ for helper captures)",
+                        self.origin,
+                        name,
+                        SYMBOL_CAPTURES.join(", ")
+                    ));
+                }
+            }
+        }
+
+        Ok(query)
+    }
+
+    fn display_name(&self) -> String {
+        format!("queries/{}/{}", self.language, self.kind.file_name())
+    }
+}
+
+/// プロジェクトのクエリを探す基準ディレクトリ
+static PROJECT_ROOT: Lazy<RwLock<PathBuf>> = Lazy::new(|| RwLock::new(PathBuf::from(".")));
+
+/// 解決済みのクエリ本文（言語・種類ごと）
+static SOURCES: Lazy<Mutex<HashMap<(&'static str, QueryKind), QuerySource>>> =
+    Lazy::new(|| Mutex::new(HashMap::new()));
+
+/// プロジェクトのルートを設定（読み込み済みのクエリは破棄する）
+pub fn set_project_root(root: &Path) {
+    *PROJECT_ROOT.write().unwrap() = root.to_path_buf();
+    SOURCES.lock().unwrap().clear();
+}
+
+/// 上書きファイルを探すディレクトリ（優先順）
+pub fn query_dirs() -> Vec<PathBuf> {
+    let mut dirs = vec![PROJECT_ROOT.read().unwrap().join(".lsif").join("queries")];
+    if let Ok(dir) = std::env::var("LSIF_QUERY_DIR") {
+        dirs.push(PathBuf::from(dir));
+    }
+    let config = std::env::var("XDG_CONFIG_HOME")
+        .map(PathBuf::from)
+        .or_else(|_| std::env::var("HOME").map(|home| PathBuf::from(home).join(".config")));
+    if let Ok(config) = config {
+        dirs.push(config.join("lsif-indexer").join("queries"));
+    }
+    dirs
+}
+
+/// 組み込みのクエリ本文
+pub fn builtin_source(language: &str, kind: QueryKind) -> Option<&'static str> {
+    macro_rules! builtin {
+        ($lang:literal) => {
+            match kind {
+                QueryKind::Symbols => include_str!(concat!("../queries/", $lang, "/symbols.scm")),
+                QueryKind::Definitions => {
+                    include_str!(concat!("../queries/", $lang, "/definitions.scm"))
+                }
+                QueryKind::References => {
+                    include_str!(concat!("../queries/", $lang, "/references.scm"))
+                }
+                QueryKind::Visibility => {
+                    include_str!(concat!("../queries/", $lang, "/visibility.scm"))
+                }
+                QueryKind::Calls => include_str!(concat!("../queries/", $lang, "/calls.scm")),
+                QueryKind::Imports => include_str!(concat!("../queries/", $lang, "/imports.scm")),
+                QueryKind::Docs => include_str!(concat!("../queries/", $lang, "/docs.scm")),
+            }
+        };
+    }
+
+    match language {
+        "rust" => Some(builtin!("rust")),
+        "typescript" => Some(builtin!("typescript")),
+        "python" => Some(builtin!("python")),
+        "go" => Some(builtin!("go")),
+        _ => None,
+    }
+}
+
+/// 上書きを考慮してクエリ本文を解決
+pub fn resolve_source(language: &'static str, kind: QueryKind) -> Result<QuerySource> {
+    if let Some(source) = SOURCES.lock().unwrap().get(&(language, kind)) {
+        return Ok(source.clone());
+    }
+
+    let builtin = builtin_source(language, kind)
+        .ok_or_else(|| anyhow!("No built-in queries for language '{}'", language))?;
+    let mut source = QuerySource {
+        language,
+        kind,
+        text: builtin.to_string(),
+        origin: QueryOrigin::Builtin,
+        line_offset: 0,
+    };
+
+    let override_path = query_dirs()
+        .into_iter()
+        .map(|dir| dir.join(language).join(kind.file_name()))
+        .find(|path| path.is_file());
+    if let Some(path) = override_path {
+        let text = std::fs::read_to_string(&path)
+            .map_err(|e| anyhow!("Failed to read query file {}: {}", path.display(), e))?;
+        let extends = text
+            .lines()
+            .find(|line| !line.trim().is_empty())
+            .map(|line| line.trim() == EXTENDS_MARKER)
+            .unwrap_or(false);
+        source = if extends {
+            let mut combined = builtin.to_string();
+            if !combined.ends_with('\n') {
+                combined.push('\n');
+            }
+            QuerySource {
+                language,
+                kind,
+                line_offset: combined.lines().count(),
+                text: combined + &text,
+                origin: QueryOrigin::Extends(path),
+            }
+        } else {
+            QuerySource {
+                language,
+                kind,
+                text,
+                origin: QueryOrigin::Override(path),
+                line_offset: 0,
+            }
+        };
+    }
+
+    SOURCES
+        .lock()
+        .unwrap()
+        .insert((language, kind), source.clone());
+    Ok(source)
+}
+
+/// クエリを読み込んでコンパイル
+pub fn load_query(language: Language, name: &'static str, kind: QueryKind) -> Result<Query> {
+    resolve_source(name, kind)?.compile(language)
+}
+
+/// 言語名に対応するtree-sitterの文法
+pub fn grammar(language: &str) -> Option<Language> {
+    match language {
+        "rust" => Some(tree_sitter_rust::language()),
+        "typescript" => Some(tree_sitter_typescript::language_typescript()),
+        "python" => Some(tree_sitter_python::language()),
+        "go" => Some(tree_sitter_go::language()),
+        _ => None,
+    }
+}
+
+/// クエリの読み込みエラーを一度だけ警告
+pub fn warn_once(error: &anyhow::Error) {
+    static REPORTED: Lazy<Mutex<HashSet<String>>> = Lazy::new(|| Mutex::new(HashSet::new()));
+
+    let message = error.to_string();
+    if REPORTED.lock().unwrap().insert(message.clone()) {
+        tracing::warn!("Failed to load tree-sitter queries: {}", message);
+    }
+}
+
+/// 全言語・全種類のクエリの検証結果
+pub fn validate_all() -> Vec<(QuerySource, Result<usize>)> {
+    let mut results = Vec::new();
+    for &language in QUERY_LANGUAGES {
+        let grammar = match grammar(language) {
+            Some(grammar) => grammar,
+            None => continue,
+        };
+        for kind in QueryKind::ALL {
+            match resolve_source(language, kind) {
+                Ok(source) => {
+                    let result = source.compile(grammar).map(|q| q.pattern_count());
+                    results.push((source, result));
+                }
+                Err(e) => {
+                    let source = QuerySource {
+                        language,
+                        kind,
+                        text: String::new(),
+                        origin: QueryOrigin::Builtin,
+                        line_offset: 0,
+                    };
+                    results.push((source, Err(e)));
+                }
+            }
+        }
+    }
+    results
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_builtin_queries_compile() {
+        for &language in QUERY_LANGUAGES {
+            let grammar = grammar(language).unwrap();
+            for kind in QueryKind::ALL {
+                let source = QuerySource {
+                    language,
+                    kind,
+                    text: builtin_source(language, kind).unwrap().to_string(),
+                    origin: QueryOrigin::Builtin,
+                    line_offset: 0,
+                };
+                if let Err(e) = source.compile(grammar) {
+                    panic!("{}", e);
+                }
+            }
+        }
+    }
+
+    #[test]
+    fn test_error_points_at_override_line() {
+        let source = QuerySource {
+            language: "rust",
+            kind: QueryKind::Symbols,
+            text: format!(
+                "{}(function_item name: (identifier) @function)\n(no_such_node) @function\n",
+                builtin_source("rust", QueryKind::Symbols).unwrap()
+            ),
+            origin: QueryOrigin::Extends(PathBuf::from("custom/symbols.scm")),
+            line_offset: builtin_source("rust", QueryKind::Symbols)
+                .unwrap()
+                .lines()
+                .count(),
+        };
+
+        let error = source.compile(tree_sitter_rust::language()).unwrap_err();
+        assert!(
+            error.to_string().starts_with("custom/symbols.scm:2:"),
+            "{}",
+            error
+        );
+    }
+
+    #[test]
+    fn test_unknown_symbol_capture_is_rejected() {
+        let source = QuerySource {
+            language: "rust",
+            kind: QueryKind::Symbols,
+            text: "(function_item name: (identifier) @handler)".to_string(),
+            origin: QueryOrigin::Override(PathBuf::from("symbols.scm")),
+            line_offset: 0,
+        };
+        let error = source.compile(tree_sitter_rust::language()).unwrap_err();
+        assert!(error.to_string().contains("@handler"));
+
+        // 種別の後ろに任意の名前を付けたキャプチャは使える
+        let source = QuerySource {
+            text: "(function_item name: (identifier) @function.route)".to_string(),
+            ..source
+        };
+        assert!(source.compile(tree_sitter_rust::language()).is_ok());
+    }
+}
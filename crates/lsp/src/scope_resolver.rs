@@ -0,0 +1,2112 @@
+//! tree-sitterによるスコープを考慮した名前解決
+//!
+//! LSPなしでも識別子の出現を正しい定義に結び付けるための、スコープグラフ風の解決器。
+//! ローカルスコープ・引数・インポート・パッケージ修飾（`pkg.Func`、`mod::func`）と、
+//! 型が分かっている値のメンバー参照（Goのセレクタ式など）を扱う。
+
+use crate::tree_sitter_parser::TreeSitterParser;
+use anyhow::Result;
+use lsif_core::{Position, Range, ReferenceAccess};
+use std::collections::HashMap;
+use std::path::Path;
+use tree_sitter::Node;
+
+/// 解決器が対応する言語
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum ResolverLanguage {
+    Rust,
+    TypeScript,
+    Python,
+    Go,
+}
+
+impl ResolverLanguage {
+    pub fn from_extension(path: &Path) -> Option<Self> {
+        match path.extension()?.to_str()? {
+            "rs" => Some(Self::Rust),
+            "ts" | "tsx" | "js" | "jsx" | "mjs" | "cjs" => Some(Self::TypeScript),
+            "py" | "pyi" => Some(Self::Python),
+            "go" => Some(Self::Go),
+            _ => None,
+        }
+    }
+
+    /// スコープを作るノード
+    fn is_scope(self, kind: &str) -> bool {
+        match self {
+            Self::Rust => matches!(
+                kind,
+                "source_file"
+                    | "block"
+                    | "function_item"
+                    | "closure_expression"
+                    | "for_expression"
+                    | "match_arm"
+                    | "if_expression"
+                    | "if_let_expression"
+                    | "while_expression"
+                    | "while_let_expression"
+                    | "impl_item"
+                    | "trait_item"
+                    | "mod_item"
+            ),
+            Self::TypeScript => matches!(
+                kind,
+                "program"
+                    | "statement_block"
+                    | "function_declaration"
+                    | "generator_function_declaration"
+                    | "function"
+                    | "arrow_function"
+                    | "method_definition"
+                    | "class_body"
+                    | "for_statement"
+                    | "for_in_statement"
+                    | "catch_clause"
+            ),
+            Self::Python => matches!(
+                kind,
+                "module"
+                    | "function_definition"
+                    | "class_definition"
+                    | "lambda"
+                    | "list_comprehension"
+                    | "set_comprehension"
+                    | "dictionary_comprehension"
+                    | "generator_expression"
+            ),
+            Self::Go => matches!(
+                kind,
+                "source_file"
+                    | "block"
+                    | "function_declaration"
+                    | "method_declaration"
+                    | "func_literal"
+                    | "for_statement"
+                    | "if_statement"
+                    | "expression_switch_statement"
+                    | "type_switch_statement"
+                    | "select_statement"
+                    | "expression_case"
+                    | "type_case"
+                    | "default_case"
+                    | "communication_case"
+            ),
+        }
+    }
+
+    /// 名前として解決する識別子ノード
+    fn is_identifier(self, kind: &str) -> bool {
+        match self {
+            Self::Rust | Self::Go => matches!(kind, "identifier" | "type_identifier"),
+            Self::TypeScript => matches!(
+                kind,
+                "identifier" | "type_identifier" | "shorthand_property_identifier"
+            ),
+            Self::Python => kind == "identifier",
+        }
+    }
+
+    /// インポート文（中の識別子は参照として扱わない）
+    fn is_import(self, kind: &str) -> bool {
+        match self {
+            Self::Rust => kind == "use_declaration",
+            Self::TypeScript => kind == "import_statement",
+            Self::Python => matches!(kind, "import_statement" | "import_from_statement"),
+            Self::Go => kind == "import_declaration",
+        }
+    }
+}
+
+/// 名前の定義の種類
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub enum DefinitionKind {
+    /// 関数・型・定数などの宣言（スコープ全体から見える）
+    Item,
+    /// 型のメンバー（メソッド・フィールド・列挙子）
+    Member { container: String },
+    /// ローカル変数
+    Local,
+    /// 引数
+    Parameter,
+    /// インポートした名前（`name`は元の名前、モジュール全体なら`*`）
+    Import { module: String, name: String },
+}
+
+/// 名前の定義
+#[derive(Debug, Clone)]
+pub struct NameDefinition {
+    pub name: String,
+    /// 名前の識別子の範囲
+    pub range: Range,
+    pub kind: DefinitionKind,
+    /// 値の型（関数は戻り値の型、型の宣言はその型自身）
+    pub type_name: Option<String>,
+}
+
+/// 参照の解決結果
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub enum Resolution {
+    /// 同じファイル内の定義（`FileResolution::definitions`の添字）
+    Definition(usize),
+    /// パッケージ・モジュール修飾の参照（`pkg.Func`、`module::func`）
+    Qualified { qualifier: String, name: String },
+    /// 型が分かっている値のメンバー参照（定義は他のファイル）
+    Member { type_name: String, name: String },
+    /// ファイル内で見つからない名前（同じパッケージの他ファイル・組み込みなど）
+    Free,
+    /// 型が分からない値のメンバー参照（どの定義か判断できない）
+    Unknown,
+}
+
+/// 識別子の出現
+#[derive(Debug, Clone)]
+pub struct NameReference {
+    pub name: String,
+    pub range: Range,
+    pub resolution: Resolution,
+    /// 構文の文脈から見た使われ方（代入の左辺・呼び出しなど）
+    pub access: ReferenceAccess,
+}
+
+/// 1ファイルの解決結果
+#[derive(Debug, Clone, Default)]
+pub struct FileResolution {
+    pub definitions: Vec<NameDefinition>,
+    pub references: Vec<NameReference>,
+    /// ワイルドカードでインポートしたモジュール（`use foo::*`、`from foo import *`、`import . "foo"`）
+    pub glob_imports: Vec<String>,
+}
+
+impl FileResolution {
+    /// 参照が同じファイル内の定義に解決された場合はその定義
+    pub fn definition_of(&self, reference: &NameReference) -> Option<&NameDefinition> {
+        match reference.resolution {
+            Resolution::Definition(idx) => self.definitions.get(idx),
+            _ => None,
+        }
+    }
+
+    /// 参照が指す定義の名前（ローカル変数・引数・型不明のメンバーはNone）
+    pub fn referenced_name<'a>(&'a self, reference: &'a NameReference) -> Option<&'a str> {
+        match &reference.resolution {
+            Resolution::Definition(idx) => {
+                let definition = self.definitions.get(*idx)?;
+                match &definition.kind {
+                    DefinitionKind::Item | DefinitionKind::Member { .. } => {
+                        Some(definition.name.as_str())
+                    }
+                    DefinitionKind::Import { name, .. } if name != "*" && name != "default" => {
+                        Some(name.as_str())
+                    }
+                    _ => None,
+                }
+            }
+            Resolution::Qualified { name, .. } | Resolution::Member { name, .. } => {
+                Some(name.as_str())
+            }
+            Resolution::Free => Some(reference.name.as_str()),
+            Resolution::Unknown => None,
+        }
+    }
+}
+
+/// tree-sitterベースの名前解決器
+pub struct ScopeResolver {
+    parser: TreeSitterParser,
+    language: ResolverLanguage,
+}
+
+impl ScopeResolver {
+    /// 拡張子から対応する解決器を作成
+    pub fn from_extension(path: &Path) -> Option<Self> {
+        let language = ResolverLanguage::from_extension(path)?;
+        let parser = TreeSitterParser::from_extension(path)?;
+        Some(Self { parser, language })
+    }
+
+    pub fn language(&self) -> ResolverLanguage {
+        self.language
+    }
+
+    /// ソースを解析し、全ての識別子の出現を定義に結び付ける
+    pub fn resolve(&mut self, source: &str) -> Result<FileResolution> {
+        let tree = self.parser.parse(source)?;
+        let root = tree.root_node();
+
+        let mut walker = Walker::new(self.language, source, root.id());
+        // 宣言を先に集めてから（巻き上げ）、文書順に参照を解決する
+        walker.hoist(root, root.id());
+        walker.visit(root);
+        Ok(walker.result)
+    }
+
+    /// 各位置にある識別子の使われ方（識別子がなければNone）
+    ///
+    /// LSPの参照のように、名前解決はせずに出現位置の構文だけを見たい時に使う。
+    pub fn accesses_at(
+        &mut self,
+        source: &str,
+        positions: &[Position],
+    ) -> Result<Vec<Option<ReferenceAccess>>> {
+        let tree = self.parser.parse(source)?;
+        let root = tree.root_node();
+        Ok(positions
+            .iter()
+            .map(|position| {
+                // 列は正規のエンコーディング（UTF-8バイト）なのでtree-sitterの列と同じ
+                let point = tree_sitter::Point {
+                    row: position.line as usize,
+                    column: position.character as usize,
+                };
+                let node = root.named_descendant_for_point_range(point, point)?;
+                let identifier = self.language.is_identifier(node.kind())
+                    || matches!(node.kind(), "field_identifier" | "property_identifier");
+                identifier.then(|| syntax_access(node, source))
+            })
+            .collect())
+    }
+}
+
+/// 解決中の状態
+struct Walker<'s> {
+    language: ResolverLanguage,
+    source: &'s str,
+    root: usize,
+    result: FileResolution,
+    /// スコープノードごとの巻き上げ済み定義
+    hoisted: HashMap<usize, Vec<usize>>,
+    /// 定義の名前として使われている識別子ノード
+    definition_nodes: HashMap<usize, usize>,
+    /// (型名, メンバー名) → 定義
+    members: HashMap<(String, String), usize>,
+    /// 現在のスコープの連鎖（名前 → 定義）
+    scopes: Vec<HashMap<String, usize>>,
+}
+
+impl<'s> Walker<'s> {
+    fn new(language: ResolverLanguage, source: &'s str, root: usize) -> Self {
+        Self {
+            language,
+            source,
+            root,
+            result: FileResolution::default(),
+            hoisted: HashMap::new(),
+            definition_nodes: HashMap::new(),
+            members: HashMap::new(),
+            scopes: Vec::new(),
+        }
+    }
+
+    fn text(&self, node: Node) -> &'s str {
+        &self.source[node.byte_range()]
+    }
+
+    fn field_text(&self, node: Node, field: &str) -> Option<&'s str> {
+        node.child_by_field_name(field).map(|n| self.text(n))
+    }
+
+    fn range(node: Node) -> Range {
+        // tree-sitterの列はUTF-8バイトなので正規のエンコーディングのまま使える
+        Range {
+            start: Position {
+                line: node.start_position().row as u32,
+                character: node.start_position().column as u32,
+            },
+            end: Position {
+                line: node.end_position().row as u32,
+                character: node.end_position().column as u32,
+            },
+        }
+    }
+
+    // ---- 定義の登録 ----
+
+    fn add_definition(
+        &mut self,
+        name: String,
+        range: Range,
+        kind: DefinitionKind,
+        type_name: Option<String>,
+    ) -> usize {
+        self.result.definitions.push(NameDefinition {
+            name,
+            range,
+            kind,
+            type_name,
+        });
+        self.result.definitions.len() - 1
+    }
+
+    /// 巻き上げる定義（スコープ全体から見える）
+    fn hoist_definition(
+        &mut self,
+        scope: usize,
+        name_node: Node,
+        kind: DefinitionKind,
+        type_name: Option<String>,
+    ) {
+        if self.definition_nodes.contains_key(&name_node.id()) {
+            return;
+        }
+        let idx = self.add_definition(
+            self.text(name_node).to_string(),
+            Self::range(name_node),
+            kind,
+            type_name,
+        );
+        self.definition_nodes.insert(name_node.id(), idx);
+        self.hoisted.entry(scope).or_default().push(idx);
+    }
+
+    /// 型のメンバーの定義（`値.名前`や`型::名前`でのみ参照される）
+    fn add_member(&mut self, container: String, name_node: Node, type_name: Option<String>) {
+        if self.definition_nodes.contains_key(&name_node.id()) {
+            return;
+        }
+        let name = self.text(name_node).to_string();
+        let idx = self.add_definition(
+            name.clone(),
+            Self::range(name_node),
+            DefinitionKind::Member {
+                container: container.clone(),
+            },
+            type_name,
+        );
+        self.definition_nodes.insert(name_node.id(), idx);
+        self.members.entry((container, name)).or_insert(idx);
+    }
+
+    /// 宣言か、型のメンバーならメンバーとして登録
+    fn hoist_named(&mut self, def: Node, name_node: Node, scope: usize, type_name: Option<String>) {
+        match self.member_container(def) {
+            Some(container) => self.add_member(container, name_node, type_name),
+            None => self.hoist_definition(scope, name_node, DefinitionKind::Item, type_name),
+        }
+    }
+
+    fn add_import(&mut self, scope: usize, binding: Node, module: String, name: String) {
+        self.hoist_definition(
+            scope,
+            binding,
+            DefinitionKind::Import { module, name },
+            None,
+        );
+    }
+
+    /// 文書順に現れるローカルな定義（既に巻き上げ済みなら型だけ補う）
+    fn define(&mut self, name_node: Node, kind: DefinitionKind, type_name: Option<String>) {
+        if let Some(&idx) = self.definition_nodes.get(&name_node.id()) {
+            let definition = &mut self.result.definitions[idx];
+            if definition.type_name.is_none() {
+                definition.type_name = type_name;
+            }
+            return;
+        }
+
+        let name = self.text(name_node).to_string();
+        if name == "_" {
+            return;
+        }
+        let idx = self.add_definition(name.clone(), Self::range(name_node), kind, type_name);
+        self.definition_nodes.insert(name_node.id(), idx);
+        if let Some(scope) = self.scopes.last_mut() {
+            scope.insert(name, idx);
+        }
+    }
+
+    // ---- 宣言の巻き上げ ----
+
+    fn hoist(&mut self, node: Node, scope: usize) {
+        self.hoist_node(node, scope);
+        let inner = if self.language.is_scope(node.kind()) {
+            node.id()
+        } else {
+            scope
+        };
+        for child in named_children(node) {
+            self.hoist(child, inner);
+        }
+    }
+
+    fn hoist_node(&mut self, node: Node, scope: usize) {
+        match self.language {
+            ResolverLanguage::Rust => self.hoist_rust(node, scope),
+            ResolverLanguage::TypeScript => self.hoist_typescript(node, scope),
+            ResolverLanguage::Python => self.hoist_python(node, scope),
+            ResolverLanguage::Go => self.hoist_go(node, scope),
+        }
+    }
+
+    fn hoist_rust(&mut self, node: Node, scope: usize) {
+        match node.kind() {
+            "function_item" | "function_signature_item" => {
+                if let Some(name) = node.child_by_field_name("name") {
+                    let returns = self
+                        .field_text(node, "return_type")
+                        .and_then(simple_type_name)
+                        .map(|t| self.resolve_self_type(node, t));
+                    self.hoist_named(node, name, scope, returns);
+                }
+            }
+            "struct_item" | "enum_item" | "union_item" | "trait_item" | "type_item" => {
+                if let Some(name) = node.child_by_field_name("name") {
+                    let own = self.text(name).to_string();
+                    self.hoist_named(node, name, scope, Some(own));
+                }
+            }
+            "const_item" | "static_item" => {
+                if let Some(name) = node.child_by_field_name("name") {
+                    let type_name = self.field_text(node, "type").and_then(simple_type_name);
+                    self.hoist_named(node, name, scope, type_name);
+                }
+            }
+            "mod_item" | "macro_definition" => {
+                if let Some(name) = node.child_by_field_name("name") {
+                    self.hoist_definition(scope, name, DefinitionKind::Item, None);
+                }
+            }
+            "field_declaration" | "enum_variant" => {
+                if let (Some(name), Some(container)) = (
+                    node.child_by_field_name("name"),
+                    self.member_container(node),
+                ) {
+                    let type_name = self.field_text(node, "type").and_then(simple_type_name);
+                    self.add_member(container, name, type_name);
+                }
+            }
+            "use_declaration" => {
+                if let Some(argument) = node.child_by_field_name("argument") {
+                    self.hoist_rust_use(argument, String::new(), scope);
+                }
+            }
+            _ => {}
+        }
+    }
+
+    fn hoist_rust_use(&mut self, node: Node, prefix: String, scope: usize) {
+        match node.kind() {
+            "identifier" => {
+                let name = self.text(node).to_string();
+                self.add_import(scope, node, prefix, name);
+            }
+            "scoped_identifier" => {
+                if let Some(name) = node.child_by_field_name("name") {
+                    let module = match node.child_by_field_name("path") {
+                        Some(path) => join_path(&prefix, self.text(path)),
+                        None => prefix,
+                    };
+                    let original = self.text(name).to_string();
+                    self.add_import(scope, name, module, original);
+                }
+            }
+            "use_as_clause" => {
+                if let (Some(path), Some(alias)) = (
+                    node.child_by_field_name("path"),
+                    node.child_by_field_name("alias"),
+                ) {
+                    let (module, original) = match (
+                        path.child_by_field_name("path"),
+                        path.child_by_field_name("name"),
+                    ) {
+                        (Some(parent), Some(name)) => (
+                            join_path(&prefix, self.text(parent)),
+                            self.text(name).to_string(),
+                        ),
+                        _ => (prefix, self.text(path).to_string()),
+                    };
+                    self.add_import(scope, alias, module, original);
+                }
+            }
+            "scoped_use_list" => {
+                let prefix = match node.child_by_field_name("path") {
+                    Some(path) => join_path(&prefix, self.text(path)),
+                    None => prefix,
+                };
+                if let Some(list) = node.child_by_field_name("list") {
+                    self.hoist_rust_use(list, prefix, scope);
+                }
+            }
+            "use_list" => {
+                for child in named_children(node) {
+                    self.hoist_rust_use(child, prefix.clone(), scope);
+                }
+            }
+            "use_wildcard" => {
+                let module = self.text(node).trim_end_matches('*').trim_end_matches("::");
+                self.result.glob_imports.push(join_path(&prefix, module));
+            }
+            _ => {}
+        }
+    }
+
+    fn hoist_typescript(&mut self, node: Node, scope: usize) {
+        match node.kind() {
+            "function_declaration" | "generator_function_declaration" => {
+                if let Some(name) = node.child_by_field_name("name") {
+                    let returns = self
+                        .field_text(node, "return_type")
+                        .and_then(annotation_type);
+                    self.hoist_definition(scope, name, DefinitionKind::Item, returns);
+                }
+            }
+            "class_declaration"
+            | "abstract_class_declaration"
+            | "interface_declaration"
+            | "type_alias_declaration"
+            | "enum_declaration" => {
+                if let Some(name) = node.child_by_field_name("name") {
+                    let own = self.text(name).to_string();
+                    self.hoist_definition(scope, name, DefinitionKind::Item, Some(own));
+                }
+            }
+            "method_definition"
+            | "method_signature"
+            | "abstract_method_signature"
+            | "public_field_definition"
+            | "property_signature" => {
+                if let (Some(name), Some(container)) = (
+                    node.child_by_field_name("name"),
+                    self.member_container(node),
+                ) {
+                    let type_name = self
+                        .field_text(node, "return_type")
+                        .or_else(|| self.field_text(node, "type"))
+                        .and_then(annotation_type);
+                    self.add_member(container, name, type_name);
+                }
+            }
+            // モジュール直下の変数は関数から前方参照できる
+            "variable_declarator" if self.is_module_level(node, scope) => {
+                if let Some(name) = node.child_by_field_name("name") {
+                    if name.kind() == "identifier" {
+                        let type_name = self.field_text(node, "type").and_then(annotation_type);
+                        self.hoist_definition(scope, name, DefinitionKind::Item, type_name);
+                    }
+                }
+            }
+            "import_statement" => {
+                let module = match self.field_text(node, "source") {
+                    Some(source) => unquote(source).to_string(),
+                    None => return,
+                };
+                for clause in named_children(node) {
+                    if clause.kind() != "import_clause" {
+                        continue;
+                    }
+                    for item in named_children(clause) {
+                        match item.kind() {
+                            "identifier" => {
+                                self.add_import(scope, item, module.clone(), "default".into())
+                            }
+                            "namespace_import" => {
+                                if let Some(id) = named_children(item)
+                                    .into_iter()
+                                    .find(|n| n.kind() == "identifier")
+                                {
+                                    self.add_import(scope, id, module.clone(), "*".into());
+                                }
+                            }
+                            "named_imports" => {
+                                for specifier in named_children(item) {
+                                    if let Some(name) = specifier.child_by_field_name("name") {
+                                        let binding =
+                                            specifier.child_by_field_name("alias").unwrap_or(name);
+                                        let original = self.text(name).to_string();
+                                        self.add_import(scope, binding, module.clone(), original);
+                                    }
+                                }
+                            }
+                            _ => {}
+                        }
+                    }
+                }
+            }
+            _ => {}
+        }
+    }
+
+    fn hoist_python(&mut self, node: Node, scope: usize) {
+        match node.kind() {
+            "function_definition" => {
+                if let Some(name) = node.child_by_field_name("name") {
+                    let returns = self
+                        .field_text(node, "return_type")
+                        .and_then(simple_type_name);
+                    self.hoist_named(node, name, scope, returns);
+                }
+            }
+            "class_definition" => {
+                if let Some(name) = node.child_by_field_name("name") {
+                    let own = self.text(name).to_string();
+                    self.hoist_named(node, name, scope, Some(own));
+                }
+            }
+            "assignment" => {
+                let left = match node.child_by_field_name("left") {
+                    Some(left) => left,
+                    None => return,
+                };
+                let type_name = self.field_text(node, "type").and_then(simple_type_name);
+                match left.kind() {
+                    // クラス直下の代入はクラス属性、モジュール直下はグローバル
+                    "identifier" => match node.parent().and_then(|p| self.member_container(p)) {
+                        Some(container) => self.add_member(container, left, type_name),
+                        None if scope == self.root => {
+                            self.hoist_definition(scope, left, DefinitionKind::Item, type_name)
+                        }
+                        None => {}
+                    },
+                    // self.x = ... はインスタンス属性
+                    "attribute" => {
+                        let is_self = left
+                            .child_by_field_name("object")
+                            .map(|o| self.text(o) == "self")
+                            .unwrap_or(false);
+                        if let (true, Some(attribute), Some(owner)) = (
+                            is_self,
+                            left.child_by_field_name("attribute"),
+                            self.owner_of(node),
+                        ) {
+                            self.add_member(owner, attribute, type_name);
+                        }
+                    }
+                    _ => {}
+                }
+            }
+            "import_statement" => {
+                for name in field_children(node, "name") {
+                    match name.kind() {
+                        // import a.b は a を束縛する
+                        "dotted_name" => {
+                            if let Some(first) = name.named_child(0) {
+                                let module = self.text(first).to_string();
+                                self.add_import(scope, first, module, "*".into());
+                            }
+                        }
+                        "aliased_import" => {
+                            if let (Some(module), Some(alias)) = (
+                                name.child_by_field_name("name"),
+                                name.child_by_field_name("alias"),
+                            ) {
+                                let module = self.text(module).to_string();
+                                self.add_import(scope, alias, module, "*".into());
+                            }
+                        }
+                        _ => {}
+                    }
+                }
+            }
+            "import_from_statement" => {
+                let module = match self.field_text(node, "module_name") {
+                    Some(module) => module.to_string(),
+                    None => return,
+                };
+                if named_children(node)
+                    .iter()
+                    .any(|n| n.kind() == "wildcard_import")
+                {
+                    self.result.glob_imports.push(module.clone());
+                }
+                for name in field_children(node, "name") {
+                    match name.kind() {
+                        "dotted_name" => {
+                            if let Some(first) = name.named_child(0) {
+                                let original = self.text(name).to_string();
+                                self.add_import(scope, first, module.clone(), original);
+                            }
+                        }
+                        "aliased_import" => {
+                            if let (Some(original), Some(alias)) = (
+                                name.child_by_field_name("name"),
+                                name.child_by_field_name("alias"),
+                            ) {
+                                let original = self.text(original).to_string();
+                                self.add_import(scope, alias, module.clone(), original);
+                            }
+                        }
+                        _ => {}
+                    }
+                }
+            }
+            _ => {}
+        }
+    }
+
+    fn hoist_go(&mut self, node: Node, scope: usize) {
+        match node.kind() {
+            "function_declaration" => {
+                if let Some(name) = node.child_by_field_name("name") {
+                    let returns = self.go_result_type(node);
+                    self.hoist_definition(scope, name, DefinitionKind::Item, returns);
+                }
+            }
+            "method_declaration" => {
+                let receiver = node
+                    .child_by_field_name("receiver")
+                    .and_then(|r| named_children(r).into_iter().next())
+                    .and_then(|p| self.field_text(p, "type"))
+                    .and_then(simple_type_name);
+                if let (Some(name), Some(receiver)) = (node.child_by_field_name("name"), receiver) {
+                    let returns = self.go_result_type(node);
+                    self.add_member(receiver, name, returns);
+                }
+            }
+            "type_spec" | "type_alias" => {
+                if let Some(name) = node.child_by_field_name("name") {
+                    let own = self.text(name).to_string();
+                    self.hoist_definition(scope, name, DefinitionKind::Item, Some(own));
+                }
+            }
+            "field_declaration" | "method_spec" => {
+                if let Some(container) = self.member_container(node) {
+                    let type_name = self
+                        .field_text(node, "type")
+                        .or_else(|| self.field_text(node, "result"))
+                        .and_then(simple_type_name);
+                    for name in field_children(node, "name") {
+                        self.add_member(container.clone(), name, type_name.clone());
+                    }
+                }
+            }
+            // パッケージ直下の変数・定数は同じパッケージ全体から見える
+            "const_spec" | "var_spec" if scope == self.root => {
+                let type_name = self.field_text(node, "type").and_then(simple_type_name);
+                for name in field_children(node, "name") {
+                    self.hoist_definition(scope, name, DefinitionKind::Item, type_name.clone());
+                }
+            }
+            "import_spec" => {
+                let path = match node.child_by_field_name("path") {
+                    Some(path) => path,
+                    None => return,
+                };
+                let module = unquote(self.text(path)).to_string();
+                match node.child_by_field_name("name") {
+                    Some(alias) if alias.kind() == "package_identifier" => {
+                        self.add_import(scope, alias, module, "*".into())
+                    }
+                    Some(alias) if alias.kind() == "dot" => self.result.glob_imports.push(module),
+                    Some(_) => {}
+                    None => {
+                        // 別名なしはパスの最後の要素（/v2などのバージョンは除く）で束縛される
+                        let name = go_package_name(&module).to_string();
+                        let idx = self.add_definition(
+                            name,
+                            Self::range(path),
+                            DefinitionKind::Import {
+                                module,
+                                name: "*".into(),
+                            },
+                            None,
+                        );
+                        self.hoisted.entry(scope).or_default().push(idx);
+                    }
+                }
+            }
+            _ => {}
+        }
+    }
+
+    fn go_result_type(&self, node: Node) -> Option<String> {
+        let result = node.child_by_field_name("result")?;
+        if result.kind() == "parameter_list" {
+            let first = named_children(result).into_iter().next()?;
+            self.field_text(first, "type").and_then(simple_type_name)
+        } else {
+            simple_type_name(self.text(result))
+        }
+    }
+
+    fn is_module_level(&self, node: Node, scope: usize) -> bool {
+        scope == self.root
+            && !ancestors(node).any(|a| {
+                matches!(
+                    a.kind(),
+                    "function_declaration" | "function" | "arrow_function" | "method_definition"
+                )
+            })
+    }
+
+    /// 宣言が型のメンバーならその型名
+    fn member_container(&self, node: Node) -> Option<String> {
+        let mut parent = node.parent()?;
+        if parent.kind() == "decorated_definition" {
+            parent = parent.parent()?;
+        }
+        let owner = parent.parent();
+        match (self.language, parent.kind()) {
+            (ResolverLanguage::Rust, "declaration_list") => {
+                let owner = owner?;
+                match owner.kind() {
+                    "impl_item" => self.field_text(owner, "type").and_then(simple_type_name),
+                    "trait_item" => self.field_text(owner, "name").map(str::to_string),
+                    _ => None,
+                }
+            }
+            (ResolverLanguage::Rust, "field_declaration_list" | "enum_variant_list") => {
+                self.field_text(owner?, "name").map(str::to_string)
+            }
+            (ResolverLanguage::TypeScript, "class_body" | "object_type" | "interface_body") => {
+                let owner = owner?;
+                match owner.kind() {
+                    "class_declaration"
+                    | "abstract_class_declaration"
+                    | "class"
+                    | "interface_declaration" => self.field_text(owner, "name").map(str::to_string),
+                    _ => None,
+                }
+            }
+            (ResolverLanguage::Python, "block") => {
+                let owner = owner?;
+                if owner.kind() == "class_definition" {
+                    self.field_text(owner, "name").map(str::to_string)
+                } else {
+                    None
+                }
+            }
+            (
+                ResolverLanguage::Go,
+                "field_declaration_list" | "interface_type" | "method_spec_list",
+            ) => {
+                let type_spec = ancestors(parent).find(|a| a.kind() == "type_spec")?;
+                self.field_text(type_spec, "name").map(str::to_string)
+            }
+            _ => None,
+        }
+    }
+
+    /// ノードを囲むimpl・クラスの型名（`Self`・`self`・`this`の型）
+    fn owner_of(&self, node: Node) -> Option<String> {
+        let owner = ancestors(node).find(|a| {
+            matches!(
+                a.kind(),
+                "impl_item"
+                    | "trait_item"
+                    | "class_declaration"
+                    | "abstract_class_declaration"
+                    | "class"
+                    | "class_definition"
+            )
+        })?;
+        match owner.kind() {
+            "impl_item" => self.field_text(owner, "type").and_then(simple_type_name),
+            _ => self.field_text(owner, "name").map(str::to_string),
+        }
+    }
+
+    fn resolve_self_type(&self, node: Node, type_name: String) -> String {
+        if type_name == "Self" {
+            self.owner_of(node).unwrap_or(type_name)
+        } else {
+            type_name
+        }
+    }
+
+    // ---- 参照の解決 ----
+
+    fn lookup(&self, name: &str) -> Option<usize> {
+        self.scopes
+            .iter()
+            .rev()
+            .find_map(|scope| scope.get(name).copied())
+    }
+
+    fn push_reference(&mut self, node: Node, resolution: Resolution) {
+        self.result.references.push(NameReference {
+            name: self.text(node).to_string(),
+            range: Self::range(node),
+            resolution,
+            access: syntax_access(node, self.source),
+        });
+    }
+
+    /// 識別子をスコープの連鎖で解決
+    fn reference(&mut self, node: Node) {
+        if self.definition_nodes.contains_key(&node.id()) {
+            return;
+        }
+        let name = self.text(node);
+        if name == "Self" {
+            return;
+        }
+        let resolution = match self.lookup(name) {
+            Some(idx) => Resolution::Definition(idx),
+            None => Resolution::Free,
+        };
+        self.push_reference(node, resolution);
+    }
+
+    fn member_resolution(&self, type_name: &str, name: &str) -> Resolution {
+        match self.members.get(&(type_name.to_string(), name.to_string())) {
+            Some(&idx) => Resolution::Definition(idx),
+            None => Resolution::Member {
+                type_name: type_name.to_string(),
+                name: name.to_string(),
+            },
+        }
+    }
+
+    /// `値.名前`の名前を解決
+    fn member_reference(&mut self, object: Node, name_node: Node) {
+        if self.definition_nodes.contains_key(&name_node.id()) {
+            return;
+        }
+        let name = self.text(name_node);
+        let resolution = match self.object_import(object) {
+            // モジュール全体のインポートはパッケージ修飾
+            Some((module, original)) if original == "*" || original == "default" => {
+                Resolution::Qualified {
+                    qualifier: module,
+                    name: name.to_string(),
+                }
+            }
+            // 名前付きインポートは型として扱う（クラスの静的メンバーなど）
+            Some((_, original)) => self.member_resolution(&original, name),
+            None => match self.infer_type(object) {
+                Some(type_name) => self.member_resolution(&type_name, name),
+                None => Resolution::Unknown,
+            },
+        };
+        self.push_reference(name_node, resolution);
+    }
+
+    /// 識別子がインポートを指していれば(モジュール, 元の名前)
+    fn object_import(&self, object: Node) -> Option<(String, String)> {
+        if !self.language.is_identifier(object.kind()) {
+            return None;
+        }
+        let idx = self.lookup(self.text(object))?;
+        match &self.result.definitions[idx].kind {
+            DefinitionKind::Import { module, name } => Some((module.clone(), name.clone())),
+            _ => None,
+        }
+    }
+
+    /// 式の値の型（分かる範囲で）
+    fn infer_type(&self, node: Node) -> Option<String> {
+        match node.kind() {
+            "identifier" | "type_identifier" => {
+                let idx = self.lookup(self.text(node))?;
+                self.result.definitions[idx].type_name.clone()
+            }
+            "self" | "this" => self.owner_of(node),
+            // Go: T{...}、&T{...}、Rust: T { ... }、TS: new T()
+            "composite_literal" | "new_expression" | "struct_expression" => {
+                let type_node = node
+                    .child_by_field_name("type")
+                    .or_else(|| node.child_by_field_name("constructor"))
+                    .or_else(|| node.child_by_field_name("name"))?;
+                simple_type_name(self.text(type_node))
+            }
+            "unary_expression" | "reference_expression" | "pointer_expression" => node
+                .child_by_field_name("operand")
+                .or_else(|| node.child_by_field_name("value"))
+                .or_else(|| node.child_by_field_name("argument"))
+                .and_then(|operand| self.infer_type(operand)),
+            "parenthesized_expression" => self.infer_type(node.named_child(0)?),
+            "call_expression" | "call" => {
+                let function = node.child_by_field_name("function")?;
+                self.infer_call_type(function)
+            }
+            _ => None,
+        }
+    }
+
+    /// 呼び出し結果の型（関数の戻り値・コンストラクタ）
+    fn infer_call_type(&self, function: Node) -> Option<String> {
+        match function.kind() {
+            "identifier" => {
+                let idx = self.lookup(self.text(function))?;
+                self.result.definitions[idx].type_name.clone()
+            }
+            // Rust: Type::new() などは型自身を返すとみなす
+            "scoped_identifier" => {
+                let path = simple_type_name(self.field_text(function, "path")?)?;
+                let path = self.resolve_self_type(function, path);
+                let name = self.field_text(function, "name")?;
+                match self.members.get(&(path.clone(), name.to_string())) {
+                    Some(&idx) => self.result.definitions[idx].type_name.clone(),
+                    None if is_constructor_name(name) => Some(path),
+                    None => None,
+                }
+            }
+            // メソッド呼び出しは戻り値の型が分かる場合のみ
+            "field_expression" | "selector_expression" | "member_expression" | "attribute" => {
+                let (object, name) = match self.language {
+                    ResolverLanguage::Rust => ("value", "field"),
+                    ResolverLanguage::Go => ("operand", "field"),
+                    ResolverLanguage::TypeScript => ("object", "property"),
+                    ResolverLanguage::Python => ("object", "attribute"),
+                };
+                let type_name = self.infer_type(function.child_by_field_name(object)?)?;
+                let name = self.field_text(function, name)?;
+                let idx = *self.members.get(&(type_name, name.to_string()))?;
+                self.result.definitions[idx].type_name.clone()
+            }
+            _ => None,
+        }
+    }
+
+    fn visit(&mut self, node: Node) {
+        let scoped = self.language.is_scope(node.kind());
+        if scoped {
+            let mut frame = HashMap::new();
+            if let Some(hoisted) = self.hoisted.get(&node.id()) {
+                for &idx in hoisted {
+                    frame.insert(self.result.definitions[idx].name.clone(), idx);
+                }
+            }
+            self.scopes.push(frame);
+        }
+
+        if self.language.is_import(node.kind()) {
+            // インポートの束縛は巻き上げ時に登録済み
+        } else if !self.visit_special(node) {
+            if self.language.is_identifier(node.kind()) {
+                self.reference(node);
+            } else {
+                self.visit_children(node);
+            }
+        }
+
+        if scoped {
+            self.scopes.pop();
+        }
+    }
+
+    fn visit_children(&mut self, node: Node) {
+        for child in named_children(node) {
+            self.visit(child);
+        }
+    }
+
+    /// 指定したフィールド以外の子を訪問
+    fn visit_children_except(&mut self, node: Node, skip: &[usize]) {
+        for child in named_children(node) {
+            if !skip.contains(&child.id()) {
+                self.visit(child);
+            }
+        }
+    }
+
+    fn visit_special(&mut self, node: Node) -> bool {
+        match self.language {
+            ResolverLanguage::Rust => self.visit_rust(node),
+            ResolverLanguage::TypeScript => self.visit_typescript(node),
+            ResolverLanguage::Python => self.visit_python(node),
+            ResolverLanguage::Go => self.visit_go(node),
+        }
+    }
+
+    /// 値を先に訪問してからパターンを束縛（`let x = x + 1`の右辺は外側のx）
+    fn bind_after_value(
+        &mut self,
+        node: Node,
+        pattern_field: &str,
+        kind: DefinitionKind,
+        type_name: Option<String>,
+    ) {
+        let pattern = match node.child_by_field_name(pattern_field) {
+            Some(pattern) => pattern,
+            None => {
+                self.visit_children(node);
+                return;
+            }
+        };
+        let body_fields = ["body", "consequence", "alternative", "condition"];
+        let bodies: Vec<Node> = body_fields
+            .iter()
+            .filter_map(|f| node.child_by_field_name(f))
+            .collect();
+
+        let mut skip: Vec<usize> = bodies.iter().map(|b| b.id()).collect();
+        skip.push(pattern.id());
+        self.visit_children_except(node, &skip);
+        self.declare_pattern(pattern, &kind, type_name);
+        for body in bodies {
+            self.visit(body);
+        }
+    }
+
+    /// パターン中の識別子を束縛（型や値の位置は参照として訪問）
+    fn declare_pattern(&mut self, node: Node, kind: &DefinitionKind, type_name: Option<String>) {
+        match node.kind() {
+            "identifier"
+            | "shorthand_property_identifier_pattern"
+            | "shorthand_field_identifier" => {
+                // Rustのパターン中の大文字始まりは列挙子・定数
+                let is_constant = self.language == ResolverLanguage::Rust
+                    && self
+                        .text(node)
+                        .chars()
+                        .next()
+                        .map(char::is_uppercase)
+                        .unwrap_or(false);
+                if is_constant {
+                    self.reference(node);
+                } else {
+                    self.define(node, kind.clone(), type_name);
+                }
+            }
+            "scoped_identifier"
+            | "type_identifier"
+            | "attribute"
+            | "subscript"
+            | "member_expression"
+            | "subscript_expression"
+            | "field_expression"
+            | "selector_expression"
+            | "index_expression" => self.visit(node),
+            _ => {
+                let type_field = node.child_by_field_name("type").map(|n| n.id());
+                let value_field = match node.kind() {
+                    "assignment_pattern" | "object_assignment_pattern" => {
+                        node.child_by_field_name("right").map(|n| n.id())
+                    }
+                    // マッチガード
+                    "match_pattern" => node.child_by_field_name("condition").map(|n| n.id()),
+                    _ => None,
+                };
+                let key_field = match node.kind() {
+                    "pair_pattern" => node.child_by_field_name("key").map(|n| n.id()),
+                    _ => None,
+                };
+                for child in named_children(node) {
+                    let id = Some(child.id());
+                    if id == type_field || id == value_field {
+                        self.visit(child);
+                    } else if id != key_field {
+                        self.declare_pattern(child, kind, None);
+                    }
+                }
+            }
+        }
+    }
+
+    fn visit_rust(&mut self, node: Node) -> bool {
+        match node.kind() {
+            "let_declaration" => {
+                let type_name = match node.child_by_field_name("type") {
+                    Some(t) => simple_type_name(self.text(t)),
+                    None => node
+                        .child_by_field_name("value")
+                        .and_then(|v| self.infer_type(v)),
+                };
+                self.bind_after_value(node, "pattern", DefinitionKind::Local, type_name);
+            }
+            "parameter" => {
+                let type_name = self
+                    .field_text(node, "type")
+                    .and_then(simple_type_name)
+                    .map(|t| self.resolve_self_type(node, t));
+                self.bind_after_value(node, "pattern", DefinitionKind::Parameter, type_name);
+            }
+            "closure_parameters" => {
+                for child in named_children(node) {
+                    if child.kind() == "parameter" {
+                        self.visit(child);
+                    } else {
+                        self.declare_pattern(child, &DefinitionKind::Parameter, None);
+                    }
+                }
+            }
+            "for_expression" | "if_let_expression" | "while_let_expression" | "let_condition" => {
+                self.bind_after_value(node, "pattern", DefinitionKind::Local, None);
+            }
+            // アームの式はパターンの束縛の後
+            "match_arm" => {
+                let pattern = node.child_by_field_name("pattern");
+                if let Some(pattern) = pattern {
+                    self.declare_pattern(pattern, &DefinitionKind::Local, None);
+                }
+                let skip: Vec<usize> = pattern.iter().map(|p| p.id()).collect();
+                self.visit_children_except(node, &skip);
+            }
+            "field_expression" => {
+                let value = node.child_by_field_name("value");
+                let field = node.child_by_field_name("field");
+                if let Some(value) = value {
+                    self.visit(value);
+                }
+                if let (Some(value), Some(field)) = (value, field) {
+                    if field.kind() == "field_identifier" {
+                        self.member_reference(value, field);
+                    }
+                }
+            }
+            "scoped_identifier" | "scoped_type_identifier" => {
+                let name = match node.child_by_field_name("name") {
+                    Some(name) => name,
+                    None => return false,
+                };
+                let resolution = match node.child_by_field_name("path") {
+                    None => Resolution::Free,
+                    Some(path) => {
+                        let path_text = self.text(path);
+                        let resolution = match path.kind() {
+                            "identifier" | "type_identifier" if path_text == "Self" => {
+                                match self.owner_of(node) {
+                                    Some(owner) => self.member_resolution(&owner, self.text(name)),
+                                    None => Resolution::Free,
+                                }
+                            }
+                            "identifier" | "type_identifier" => match self.lookup(path_text) {
+                                Some(idx) => match &self.result.definitions[idx].kind {
+                                    DefinitionKind::Import {
+                                        module,
+                                        name: original,
+                                    } => Resolution::Qualified {
+                                        qualifier: join_path(module, original),
+                                        name: self.text(name).to_string(),
+                                    },
+                                    _ => {
+                                        let owner = self.result.definitions[idx].name.clone();
+                                        self.member_resolution(&owner, self.text(name))
+                                    }
+                                },
+                                None => Resolution::Qualified {
+                                    qualifier: path_text.to_string(),
+                                    name: self.text(name).to_string(),
+                                },
+                            },
+                            _ => Resolution::Qualified {
+                                qualifier: path_text.to_string(),
+                                name: self.text(name).to_string(),
+                            },
+                        };
+                        self.visit(path);
+                        resolution
+                    }
+                };
+                if !self.definition_nodes.contains_key(&name.id()) {
+                    self.push_reference(name, resolution);
+                }
+            }
+            "field_initializer" => {
+                if let Some(value) = node.child_by_field_name("value") {
+                    self.visit(value);
+                }
+                let owner = ancestors(node)
+                    .find(|a| a.kind() == "struct_expression")
+                    .and_then(|s| self.field_text(s, "name"))
+                    .and_then(simple_type_name);
+                if let (Some(field), Some(owner)) = (node.child_by_field_name("field"), owner) {
+                    if field.kind() == "field_identifier" {
+                        let resolution = self.member_resolution(&owner, self.text(field));
+                        self.push_reference(field, resolution);
+                    }
+                }
+            }
+            _ => return false,
+        }
+        true
+    }
+
+    fn visit_go(&mut self, node: Node) -> bool {
+        match node.kind() {
+            "short_var_declaration" => {
+                let right: Vec<Node> = node
+                    .child_by_field_name("right")
+                    .map(named_children)
+                    .unwrap_or_default();
+                for value in &right {
+                    self.visit(*value);
+                }
+                let types: Vec<Option<String>> =
+                    right.iter().map(|v| self.infer_type(*v)).collect();
+                if let Some(left) = node.child_by_field_name("left") {
+                    for (i, name) in named_children(left).into_iter().enumerate() {
+                        let type_name = if types.len() == 1 && i > 0 {
+                            None
+                        } else {
+                            types.get(i).cloned().flatten()
+                        };
+                        self.declare_pattern(name, &DefinitionKind::Local, type_name);
+                    }
+                }
+            }
+            "var_spec" | "const_spec" => {
+                let names = field_children(node, "name");
+                let skip: Vec<usize> = names.iter().map(|n| n.id()).collect();
+                self.visit_children_except(node, &skip);
+                let values: Vec<Node> = node
+                    .child_by_field_name("value")
+                    .map(named_children)
+                    .unwrap_or_default();
+                let declared = self.field_text(node, "type").and_then(simple_type_name);
+                for (i, name) in names.into_iter().enumerate() {
+                    let type_name = declared
+                        .clone()
+                        .or_else(|| values.get(i).and_then(|v| self.infer_type(*v)));
+                    self.define(name, DefinitionKind::Local, type_name);
+                }
+            }
+            "parameter_declaration" | "variadic_parameter_declaration" => {
+                let names = field_children(node, "name");
+                let skip: Vec<usize> = names.iter().map(|n| n.id()).collect();
+                self.visit_children_except(node, &skip);
+                let type_name = self.field_text(node, "type").and_then(simple_type_name);
+                for name in names {
+                    self.define(name, DefinitionKind::Parameter, type_name.clone());
+                }
+            }
+            "range_clause" => {
+                if let Some(right) = node.child_by_field_name("right") {
+                    self.visit(right);
+                }
+                if let Some(left) = node.child_by_field_name("left") {
+                    let declares = children(node).iter().any(|c| c.kind() == ":=");
+                    if declares {
+                        for name in named_children(left) {
+                            self.declare_pattern(name, &DefinitionKind::Local, None);
+                        }
+                    } else {
+                        self.visit(left);
+                    }
+                }
+            }
+            "type_switch_statement" => {
+                let alias = node.child_by_field_name("alias");
+                let value = node.child_by_field_name("value");
+                if let Some(value) = value {
+                    self.visit(value);
+                }
+                if let Some(alias) = alias {
+                    for name in named_children(alias) {
+                        self.declare_pattern(name, &DefinitionKind::Local, None);
+                    }
+                }
+                let skip: Vec<usize> = alias.into_iter().chain(value).map(|n| n.id()).collect();
+                self.visit_children_except(node, &skip);
+            }
+            "selector_expression" => {
+                let operand = node.child_by_field_name("operand");
+                if let Some(operand) = operand {
+                    self.visit(operand);
+                }
+                if let (Some(operand), Some(field)) = (operand, node.child_by_field_name("field")) {
+                    self.member_reference(operand, field);
+                }
+            }
+            "qualified_type" => {
+                let package = node.child_by_field_name("package");
+                let name = node.child_by_field_name("name");
+                if let (Some(package), Some(name)) = (package, name) {
+                    let package_idx = self.lookup(self.text(package));
+                    if let Some(idx) = package_idx {
+                        self.push_reference(package, Resolution::Definition(idx));
+                    }
+                    let resolution = match package_idx.map(|i| &self.result.definitions[i].kind) {
+                        Some(DefinitionKind::Import { module, .. }) => Resolution::Qualified {
+                            qualifier: module.clone(),
+                            name: self.text(name).to_string(),
+                        },
+                        _ => Resolution::Qualified {
+                            qualifier: self.text(package).to_string(),
+                            name: self.text(name).to_string(),
+                        },
+                    };
+                    self.push_reference(name, resolution);
+                }
+            }
+            _ => return false,
+        }
+        true
+    }
+
+    fn visit_typescript(&mut self, node: Node) -> bool {
+        match node.kind() {
+            "variable_declarator" => {
+                let type_name = match node.child_by_field_name("type") {
+                    Some(t) => annotation_type(self.text(t)),
+                    None => node
+                        .child_by_field_name("value")
+                        .and_then(|v| self.infer_type(v)),
+                };
+                self.bind_after_value(node, "name", DefinitionKind::Local, type_name);
+            }
+            "required_parameter" | "optional_parameter" => {
+                let type_name = self.field_text(node, "type").and_then(annotation_type);
+                self.bind_after_value(node, "pattern", DefinitionKind::Parameter, type_name);
+            }
+            "arrow_function" if node.child_by_field_name("parameter").is_some() => {
+                self.bind_after_value(node, "parameter", DefinitionKind::Parameter, None);
+            }
+            "catch_clause" => {
+                self.bind_after_value(node, "parameter", DefinitionKind::Local, None);
+            }
+            "for_in_statement" => {
+                let declares = node.child_by_field_name("kind").is_some();
+                if declares {
+                    self.bind_after_value(node, "left", DefinitionKind::Local, None);
+                } else {
+                    self.visit_children(node);
+                }
+            }
+            "member_expression" => {
+                let object = node.child_by_field_name("object");
+                if let Some(object) = object {
+                    self.visit(object);
+                }
+                if let (Some(object), Some(property)) =
+                    (object, node.child_by_field_name("property"))
+                {
+                    self.member_reference(object, property);
+                }
+            }
+            "nested_type_identifier" => {
+                let module = node.child_by_field_name("module");
+                if let Some(module) = module {
+                    self.visit(module);
+                }
+                if let (Some(module), Some(name)) = (module, node.child_by_field_name("name")) {
+                    self.member_reference(module, name);
+                }
+            }
+            // オブジェクトリテラルのキーは名前の参照ではない
+            "pair" => {
+                if let Some(value) = node.child_by_field_name("value") {
+                    self.visit(value);
+                }
+            }
+            _ => return false,
+        }
+        true
+    }
+
+    fn visit_python(&mut self, node: Node) -> bool {
+        match node.kind() {
+            "assignment" => {
+                let type_name = match node.child_by_field_name("type") {
+                    Some(t) => simple_type_name(self.text(t)),
+                    None => node
+                        .child_by_field_name("right")
+                        .and_then(|v| self.infer_type(v)),
+                };
+                self.bind_after_value(node, "left", DefinitionKind::Local, type_name);
+            }
+            "parameters" | "lambda_parameters" => {
+                let owner = match node.parent() {
+                    Some(function) if function.kind() == "function_definition" => {
+                        self.member_container(function)
+                    }
+                    _ => None,
+                };
+                for (i, parameter) in named_children(node).into_iter().enumerate() {
+                    // メソッドの第1引数（self・cls）はクラスの型
+                    let implicit = if i == 0 { owner.clone() } else { None };
+                    self.declare_python_parameter(parameter, implicit);
+                }
+            }
+            "for_statement" | "for_in_clause" => {
+                self.bind_after_value(node, "left", DefinitionKind::Local, None);
+            }
+            "list_comprehension"
+            | "set_comprehension"
+            | "dictionary_comprehension"
+            | "generator_expression" => {
+                // 内包表記は for 節を先に束縛する
+                let clauses: Vec<Node> = named_children(node)
+                    .into_iter()
+                    .filter(|c| matches!(c.kind(), "for_in_clause" | "if_clause"))
+                    .collect();
+                let skip: Vec<usize> = clauses.iter().map(|c| c.id()).collect();
+                for clause in clauses {
+                    self.visit(clause);
+                }
+                self.visit_children_except(node, &skip);
+            }
+            "as_pattern" => {
+                let alias = node.child_by_field_name("alias");
+                let skip: Vec<usize> = alias.iter().map(|a| a.id()).collect();
+                self.visit_children_except(node, &skip);
+                if let Some(alias) = alias {
+                    self.declare_pattern(alias, &DefinitionKind::Local, None);
+                }
+            }
+            "attribute" => {
+                let object = node.child_by_field_name("object");
+                if let Some(object) = object {
+                    self.visit(object);
+                }
+                if let (Some(object), Some(attribute)) =
+                    (object, node.child_by_field_name("attribute"))
+                {
+                    self.member_reference(object, attribute);
+                }
+            }
+            // キーワード引数の名前は呼び出し先の引数
+            "keyword_argument" => {
+                if let Some(value) = node.child_by_field_name("value") {
+                    self.visit(value);
+                }
+            }
+            _ => return false,
+        }
+        true
+    }
+
+    fn declare_python_parameter(&mut self, parameter: Node, implicit: Option<String>) {
+        match parameter.kind() {
+            "identifier" => self.define(parameter, DefinitionKind::Parameter, implicit),
+            "typed_parameter" => {
+                let type_name = self
+                    .field_text(parameter, "type")
+                    .and_then(simple_type_name);
+                for child in named_children(parameter) {
+                    if Some(child.id()) == parameter.child_by_field_name("type").map(|t| t.id()) {
+                        self.visit(child);
+                    } else {
+                        self.declare_python_parameter(child, type_name.clone());
+                    }
+                }
+            }
+            "default_parameter" | "typed_default_parameter" => {
+                let name = parameter.child_by_field_name("name");
+                let skip: Vec<usize> = name.iter().map(|n| n.id()).collect();
+                self.visit_children_except(parameter, &skip);
+                if let Some(name) = name {
+                    let type_name = self
+                        .field_text(parameter, "type")
+                        .and_then(simple_type_name);
+                    self.define(name, DefinitionKind::Parameter, type_name.or(implicit));
+                }
+            }
+            "list_splat_pattern" | "dictionary_splat_pattern" => {
+                for child in named_children(parameter) {
+                    self.declare_python_parameter(child, None);
+                }
+            }
+            _ => self.visit(parameter),
+        }
+    }
+}
+
+fn named_children(node: Node) -> Vec<Node> {
+    let mut cursor = node.walk();
+    node.named_children(&mut cursor).collect()
+}
+
+fn children(node: Node) -> Vec<Node> {
+    let mut cursor = node.walk();
+    node.children(&mut cursor).collect()
+}
+
+fn field_children<'t>(node: Node<'t>, field: &str) -> Vec<Node<'t>> {
+    let mut cursor = node.walk();
+    node.children_by_field_name(field, &mut cursor).collect()
+}
+
+/// 識別子の出現の使われ方（構文の文脈から判定）
+fn syntax_access(node: Node, source: &str) -> ReferenceAccess {
+    // `s.count`・`mod::f`の名前部分はアクセス式全体の位置で判定する
+    let mut expr = node;
+    if let Some(parent) = node.parent() {
+        let name_field = match parent.kind() {
+            "field_expression" | "selector_expression" => "field",
+            "member_expression" => "property",
+            "attribute" => "attribute",
+            "scoped_identifier" => "name",
+            _ => "",
+        };
+        if is_field(parent, name_field, node) {
+            expr = parent;
+        }
+    }
+    let mut parent = match expr.parent() {
+        Some(parent) => parent,
+        None => return ReferenceAccess::Read,
+    };
+    while parent.kind() == "parenthesized_expression" {
+        expr = parent;
+        parent = match parent.parent() {
+            Some(parent) => parent,
+            None => return ReferenceAccess::Read,
+        };
+    }
+    // 多重代入の左辺（`a, b = ...`）は代入文で判定する
+    if matches!(
+        parent.kind(),
+        "expression_list" | "pattern_list" | "tuple_pattern"
+    ) {
+        if let Some(statement) = parent.parent() {
+            if is_field(statement, "left", parent) {
+                expr = parent;
+                parent = statement;
+            }
+        }
+    }
+
+    match parent.kind() {
+        "call_expression" | "call" if is_field(parent, "function", expr) => ReferenceAccess::Call,
+        "new_expression" if is_field(parent, "constructor", expr) => ReferenceAccess::Call,
+        "macro_invocation" if is_field(parent, "macro", expr) => ReferenceAccess::Call,
+        "assignment_expression" | "assignment" | "short_var_declaration"
+            if is_field(parent, "left", expr) =>
+        {
+            ReferenceAccess::Write
+        }
+        // Goの`x += 1`は`=`以外の演算子の代入文
+        "assignment_statement" if is_field(parent, "left", expr) => {
+            match operator(parent, source) {
+                Some("=") => ReferenceAccess::Write,
+                _ => ReferenceAccess::ReadWrite,
+            }
+        }
+        "compound_assignment_expr" | "augmented_assignment_expression" | "augmented_assignment"
+            if is_field(parent, "left", expr) =>
+        {
+            ReferenceAccess::ReadWrite
+        }
+        "update_expression" | "inc_statement" | "dec_statement" => ReferenceAccess::ReadWrite,
+        "unary_expression" if operator(parent, source) == Some("&") => {
+            ReferenceAccess::AddressTaken
+        }
+        "reference_expression"
+            if children(parent)
+                .iter()
+                .any(|c| c.kind() == "mutable_specifier") =>
+        {
+            ReferenceAccess::AddressTaken
+        }
+        _ => ReferenceAccess::Read,
+    }
+}
+
+/// 演算子の字句（`x += 1`の`+=`など）
+fn operator<'s>(node: Node, source: &'s str) -> Option<&'s str> {
+    node.child_by_field_name("operator")
+        .map(|n| &source[n.byte_range()])
+}
+
+/// `node`が`parent`の`field`フィールドの子か
+fn is_field(parent: Node, field: &str, node: Node) -> bool {
+    parent.child_by_field_name(field).map(|n| n.id()) == Some(node.id())
+}
+
+fn ancestors(node: Node) -> impl Iterator<Item = Node> {
+    std::iter::successors(node.parent(), |n| n.parent())
+}
+
+fn join_path(prefix: &str, name: &str) -> String {
+    if prefix.is_empty() {
+        name.to_string()
+    } else if name.is_empty() {
+        prefix.to_string()
+    } else {
+        format!("{}::{}", prefix, name)
+    }
+}
+
+fn unquote(text: &str) -> &str {
+    text.trim_matches(|c: char| c == '"' || c == '\'' || c == '`')
+}
+
+/// Goのインポートパスから既定のパッケージ名
+fn go_package_name(path: &str) -> &str {
+    let mut segments = path.rsplit('/');
+    let last = segments.next().unwrap_or(path);
+    let is_version =
+        last.len() > 1 && last.starts_with('v') && last[1..].chars().all(|c| c.is_ascii_digit());
+    if is_version {
+        segments.next().unwrap_or(last)
+    } else {
+        last
+    }
+}
+
+fn is_constructor_name(name: &str) -> bool {
+    name == "new" || name == "default" || name.starts_with("new_") || name.starts_with("from")
+}
+
+/// TSの型注釈（`: Foo`）から型名
+fn annotation_type(text: &str) -> Option<String> {
+    simple_type_name(text.trim_start_matches(':'))
+}
+
+/// 型の表記から名前部分（`&mut Foo<T>`→`Foo`、`*os.File`→`os.File`）
+fn simple_type_name(text: &str) -> Option<String> {
+    let mut rest = text.trim();
+    loop {
+        let before = rest;
+        rest = rest
+            .trim_start_matches(|c: char| c == '&' || c == '*' || c == '-' || c == '>')
+            .trim_start();
+        if let Some(stripped) = rest.strip_prefix("mut ") {
+            rest = stripped.trim_start();
+        }
+        if rest.starts_with('\'') {
+            rest = rest.split_once(' ').map(|(_, r)| r).unwrap_or("");
+        }
+        if rest == before {
+            break;
+        }
+    }
+    let end = rest
+        .find(|c: char| !(c.is_alphanumeric() || c == '_' || c == '.' || c == ':'))
+        .unwrap_or(rest.len());
+    let name = rest[..end].trim_end_matches(|c: char| c == ':' || c == '.');
+    if name.is_empty() {
+        None
+    } else {
+        Some(name.to_string())
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn resolve(file: &str, source: &str) -> FileResolution {
+        ScopeResolver::from_extension(Path::new(file))
+            .unwrap()
+            .resolve(source)
+            .unwrap()
+    }
+
+    fn references_named<'a>(resolution: &'a FileResolution, name: &str) -> Vec<&'a NameReference> {
+        resolution
+            .references
+            .iter()
+            .filter(|r| r.name == name)
+            .collect()
+    }
+
+    #[test]
+    fn test_rust_locals_shadow_items() {
+        let source = r#"
+fn close() {}
+
+fn run(close: bool) {
+    if close {
+        let open = close;
+    }
+    close();
+}
+"#;
+        let resolution = resolve("lib.rs", source);
+        let refs = references_named(&resolution, "close");
+        assert_eq!(refs.len(), 3);
+        // 全て引数を指し、関数closeには結び付かない
+        for r in refs {
+            let definition = resolution.definition_of(r).unwrap();
+            assert_eq!(definition.kind, DefinitionKind::Parameter);
+        }
+    }
+
+    #[test]
+    fn test_rust_imports_and_methods() {
+        let source = r#"
+use crate::store::{Store, open as open_store};
+
+struct File;
+
+impl File {
+    fn close(&self) {}
+    fn new() -> Self { File }
+}
+
+fn main() {
+    let f = File::new();
+    f.close();
+    let s = open_store();
+    s.close();
+}
+"#;
+        let resolution = resolve("main.rs", source);
+
+        let closes = references_named(&resolution, "close");
+        assert_eq!(closes.len(), 2);
+        // File::closeに解決
+        let file_close = resolution.definition_of(closes[0]).unwrap();
+        assert_eq!(
+            file_close.kind,
+            DefinitionKind::Member {
+                container: "File".to_string()
+            }
+        );
+        // 型の分からない値のメンバーは判断しない
+        assert_eq!(closes[1].resolution, Resolution::Unknown);
+
+        let open = references_named(&resolution, "open_store")[0];
+        assert_eq!(
+            resolution.definition_of(open).unwrap().kind,
+            DefinitionKind::Import {
+                module: "crate::store".to_string(),
+                name: "open".to_string()
+            }
+        );
+    }
+
+    #[test]
+    fn test_go_selectors_and_packages() {
+        let source = r#"
+package main
+
+import (
+    "os"
+    log "github.com/sirupsen/logrus"
+)
+
+type Conn struct {
+    addr string
+}
+
+func (c *Conn) Close() error { return nil }
+
+func NewConn() *Conn { return &Conn{} }
+
+func main() {
+    c := NewConn()
+    c.Close()
+    f, _ := os.Open("x")
+    f.Close()
+    log.Info(c.addr)
+}
+"#;
+        let resolution = resolve("main.go", source);
+
+        let closes = references_named(&resolution, "Close");
+        assert_eq!(closes.len(), 2);
+        assert_eq!(
+            resolution.definition_of(closes[0]).unwrap().kind,
+            DefinitionKind::Member {
+                container: "Conn".to_string()
+            }
+        );
+        assert_eq!(closes[1].resolution, Resolution::Unknown);
+
+        let open = references_named(&resolution, "Open")[0];
+        assert_eq!(
+            open.resolution,
+            Resolution::Qualified {
+                qualifier: "os".to_string(),
+                name: "Open".to_string()
+            }
+        );
+        let info = references_named(&resolution, "Info")[0];
+        assert_eq!(
+            info.resolution,
+            Resolution::Qualified {
+                qualifier: "github.com/sirupsen/logrus".to_string(),
+                name: "Info".to_string()
+            }
+        );
+        let addr = references_named(&resolution, "addr")[0];
+        assert!(matches!(
+            resolution.definition_of(addr).unwrap().kind,
+            DefinitionKind::Member { .. }
+        ));
+    }
+
+    #[test]
+    fn test_typescript_imports_and_this() {
+        let source = r#"
+import * as path from "path";
+import { Store } from "./store";
+
+class Cache {
+    private store = new Store();
+    get(key: string) {
+        const store = this.store;
+        return path.join(key, store.name);
+    }
+}
+"#;
+        let resolution = resolve("cache.ts", source);
+
+        let join = references_named(&resolution, "join")[0];
+        assert_eq!(
+            join.resolution,
+            Resolution::Qualified {
+                qualifier: "path".to_string(),
+                name: "join".to_string()
+            }
+        );
+
+        // this.storeはフィールド、storeはローカル変数
+        let stores = references_named(&resolution, "store");
+        let kinds: Vec<_> = stores
+            .iter()
+            .map(|r| resolution.definition_of(r).map(|d| d.kind.clone()))
+            .collect();
+        assert!(kinds.contains(&Some(DefinitionKind::Member {
+            container: "Cache".to_string()
+        })));
+        assert!(kinds.contains(&Some(DefinitionKind::Local)));
+    }
+
+    #[test]
+    fn test_python_self_and_parameters() {
+        let source = r#"
+import os
+
+class Reader:
+    def __init__(self, path):
+        self.path = path
+
+    def read(self):
+        return os.path.exists(self.path)
+
+def read(path):
+    return Reader(path).read()
+"#;
+        let resolution = resolve("reader.py", source);
+
+        let paths = references_named(&resolution, "path");
+        let member_refs: Vec<_> = paths
+            .iter()
+            .filter(|r| {
+                matches!(
+                    resolution.definition_of(r).map(|d| &d.kind),
+                    Some(DefinitionKind::Member { .. })
+                )
+            })
+            .collect();
+        // self.path（readの中）
+        assert_eq!(member_refs.len(), 1);
+
+        // os.pathはモジュール修飾
+        assert!(paths.iter().any(|r| r.resolution
+            == Resolution::Qualified {
+                qualifier: "os".to_string(),
+                name: "path".to_string()
+            }));
+
+        // Reader(path).read() はReader.readに解決
+        let reads = references_named(&resolution, "read");
+        assert_eq!(reads.len(), 1);
+        assert_eq!(
+            resolution.definition_of(reads[0]).unwrap().kind,
+            DefinitionKind::Member {
+                container: "Reader".to_string()
+            }
+        );
+    }
+
+    fn accesses(resolution: &FileResolution, name: &str) -> Vec<ReferenceAccess> {
+        references_named(resolution, name)
+            .iter()
+            .map(|r| r.access)
+            .collect()
+    }
+
+    #[test]
+    fn test_go_reference_access() {
+        let source = r#"
+package main
+
+type Counter struct {
+    hits int
+}
+
+func (c *Counter) Inc() {
+    c.hits++
+    c.hits += 2
+    c.hits = 0
+    p := &c.hits
+    println(c.hits, p)
+    c.Inc()
+}
+"#;
+        let resolution = resolve("counter.go", source);
+        assert_eq!(
+            accesses(&resolution, "hits"),
+            vec![
+                ReferenceAccess::ReadWrite,
+                ReferenceAccess::ReadWrite,
+                ReferenceAccess::Write,
+                ReferenceAccess::AddressTaken,
+                ReferenceAccess::Read,
+            ]
+        );
+        assert_eq!(accesses(&resolution, "Inc"), vec![ReferenceAccess::Call]);
+    }
+
+    #[test]
+    fn test_accesses_at_positions() {
+        let source = "package main\n\nfunc bump(c *Counter) {\n\tc.hits++\n\tprintln(c.hits)\n\tp := &c.hits\n}\n";
+        let at = |line, character| Position { line, character };
+        let accesses = ScopeResolver::from_extension(Path::new("bump.go"))
+            .unwrap()
+            .accesses_at(
+                source,
+                &[at(3, 3), at(4, 11), at(5, 9), at(4, 1), at(2, 22)],
+            )
+            .unwrap();
+        assert_eq!(
+            accesses,
+            vec![
+                Some(ReferenceAccess::ReadWrite),
+                Some(ReferenceAccess::Read),
+                Some(ReferenceAccess::AddressTaken),
+                Some(ReferenceAccess::Call),
+                // 識別子でない位置
+                None,
+            ]
+        );
+    }
+
+    #[test]
+    fn test_reference_access_other_languages() {
+        let rust = resolve(
+            "stats.rs",
+            r#"
+struct Stats { count: u32 }
+
+fn bump(stats: &mut Stats) -> u32 {
+    stats.count += 1;
+    stats.count = 0;
+    let r = &mut stats.count;
+    bump(stats)
+}
+"#,
+        );
+        assert_eq!(
+            accesses(&rust, "count"),
+            vec![
+                ReferenceAccess::ReadWrite,
+                ReferenceAccess::Write,
+                ReferenceAccess::AddressTaken,
+            ]
+        );
+        assert_eq!(accesses(&rust, "bump"), vec![ReferenceAccess::Call]);
+
+        let typescript = resolve(
+            "total.ts",
+            r#"
+let total = 0;
+function add(n: number) {
+    total++;
+    total = n;
+    console.log(total);
+}
+"#,
+        );
+        assert_eq!(
+            accesses(&typescript, "total"),
+            vec![
+                ReferenceAccess::ReadWrite,
+                ReferenceAccess::Write,
+                ReferenceAccess::Read,
+            ]
+        );
+
+        let python = resolve(
+            "cache.py",
+            r#"
+class Cache:
+    def clear(self):
+        self.size += 1
+        print(self.size)
+        self.clear()
+"#,
+        );
+        assert_eq!(
+            accesses(&python, "size"),
+            vec![ReferenceAccess::ReadWrite, ReferenceAccess::Read]
+        );
+        assert_eq!(accesses(&python, "clear"), vec![ReferenceAccess::Call]);
+    }
+}
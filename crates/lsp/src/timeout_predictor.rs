@@ -2,6 +2,8 @@ use std::collections::{HashMap, VecDeque};
 use std::time::Duration;
 use tracing::info;
 
+use crate::lsp_timing_stats::LspTimingStats;
+
 /// LSP操作タイプ（パフォーマンス分析に基づく）
 #[derive(Debug, Clone, Copy, Hash, Eq, PartialEq)]
 pub enum LspOperation {
@@ -156,7 +158,7 @@ impl TimeoutPredictor {
         file_size: usize,
         line_count: usize,
         duration: Duration,
-_success: bool,
+        _success: bool,
     ) {
         // 履歴に追加
         let history = self
@@ -268,10 +270,15 @@ _success: bool,
 
     /// 履歴から統計情報を更新
     fn update_statistics(&mut self) {
-        if self.history.is_empty() {
-            return;
+        if let Some((new_ms_per_byte, new_ms_per_line)) = self.observed_rates() {
+            // 移動平均で更新（既存の値と新しい値の重み付き平均）
+            self.ms_per_byte = self.ms_per_byte * 0.3 + new_ms_per_byte * 0.7;
+            self.ms_per_line = self.ms_per_line * 0.3 + new_ms_per_line * 0.7;
         }
+    }
 
+    /// この実行の履歴だけから求めたバイト・行あたりの処理時間
+    fn observed_rates(&self) -> Option<(f64, f64)> {
         let mut total_bytes_per_ms = 0.0;
         let mut total_lines_per_ms = 0.0;
         let mut count = 0;
@@ -284,13 +291,30 @@ _success: bool,
             }
         }
 
-        if count > 0 {
-            // 移動平均で更新（既存の値と新しい値の重み付き平均）
-            let new_ms_per_byte = total_bytes_per_ms / count as f64;
-            let new_ms_per_line = total_lines_per_ms / count as f64;
+        if count == 0 {
+            return None;
+        }
+        Some((
+            total_bytes_per_ms / count as f64,
+            total_lines_per_ms / count as f64,
+        ))
+    }
 
-            self.ms_per_byte = self.ms_per_byte * 0.3 + new_ms_per_byte * 0.7;
-            self.ms_per_line = self.ms_per_line * 0.3 + new_ms_per_line * 0.7;
+    /// 保存済みの統計からサイズあたりの処理時間を引き継ぐ
+    pub fn seed_from_stats(&mut self, stats: &LspTimingStats) {
+        if let Some(ms_per_byte) = stats.ms_per_byte {
+            self.ms_per_byte = ms_per_byte;
+        }
+        if let Some(ms_per_line) = stats.ms_per_line {
+            self.ms_per_line = ms_per_line;
+        }
+    }
+
+    /// この実行で測った値を統計に書き出す（引き継いだ値は混ぜず、測っていない場合は何もしない）
+    pub fn export_stats_into(&self, stats: &mut LspTimingStats) {
+        if let Some((ms_per_byte, ms_per_line)) = self.observed_rates() {
+            stats.ms_per_byte = Some(ms_per_byte);
+            stats.ms_per_line = Some(ms_per_line);
         }
     }
 
@@ -392,6 +416,28 @@ mod tests {
         assert!(stats.avg_ms_per_line > 0.0);
     }
 
+    #[test]
+    fn test_export_only_observed_rates() {
+        let mut stats = LspTimingStats::new("go");
+        stats.ms_per_byte = Some(1.0);
+        stats.ms_per_line = Some(20.0);
+
+        let mut predictor = TimeoutPredictor::new();
+        predictor.seed_from_stats(&stats);
+
+        // 測っていなければ何も書き出さない
+        let mut exported = LspTimingStats::new("go");
+        predictor.export_stats_into(&mut exported);
+        assert_eq!(exported.ms_per_byte, None);
+
+        // 書き出すのはこの実行の履歴から求めた値だけ
+        predictor.record_processing(10_000, 500, Duration::from_millis(100));
+        predictor.export_stats_into(&mut exported);
+        assert_eq!(exported.ms_per_byte, Some(0.01));
+        assert_eq!(exported.ms_per_line, Some(0.2));
+        assert!(predictor.get_statistics().avg_ms_per_byte > 0.01);
+    }
+
     #[test]
     fn test_batch_prediction() {
         let predictor = TimeoutPredictor::new();
@@ -1,6 +1,8 @@
+use crate::query_loader::{self, QueryKind};
 use anyhow::Result;
-use lsif_core::{Position, Range, Symbol, SymbolKind};
-use std::collections::HashMap;
+use lsif_core::{DocComment, Position, Range, Symbol, SymbolKind, SymbolModifiers};
+use std::collections::{HashMap, HashSet};
+use std::path::Path;
 use tree_sitter::{Language, Node, Parser, Query, QueryCapture, QueryCursor};
 
 /// Tree-sitterベースのパーサー
@@ -10,187 +12,86 @@ pub struct TreeSitterParser {
     queries: LanguageQueries,
 }
 
-/// 言語固有のクエリ
+/// 言語固有のクエリ（`queries/<言語>/*.scm`、`query_loader`で上書き可能）
 struct LanguageQueries {
     symbols: Query,
     definitions: Query,
     references: Query,
     visibility: Option<Query>,
+    calls: Query,
+    imports: Query,
+    /// 定義の直前にあればドキュメントとみなすコメント（Pythonはdocstringを使用）
+    docs: Option<Query>,
 }
 
-impl TreeSitterParser {
-    /// Rust用パーサーを作成
-    pub fn rust() -> Result<Self> {
-        let mut parser = Parser::new();
-        let language = tree_sitter_rust::language();
-        parser.set_language(language)?;
-
-        let queries = LanguageQueries {
-            symbols: Query::new(
-                language,
-                r#"
-                (function_item name: (identifier) @function)
-                (struct_item name: (type_identifier) @struct)
-                (enum_item name: (type_identifier) @enum)
-                (trait_item name: (type_identifier) @trait)
-                (impl_item type: (type_identifier) @impl)
-                (mod_item name: (identifier) @module)
-                (const_item name: (identifier) @constant)
-                (static_item name: (identifier) @static)
-                (type_alias name: (type_identifier) @type_alias)
-                "#,
-            )?,
-            definitions: Query::new(
-                language,
-                r#"
-                (let_declaration pattern: (identifier) @definition)
-                (parameter pattern: (identifier) @definition)
-                "#,
-            )?,
-            references: Query::new(
-                language,
-                r#"
-                (identifier) @reference
-                (type_identifier) @type_reference
-                "#,
-            )?,
-            visibility: Some(Query::new(
-                language,
-                r#"
-                (visibility_modifier) @visibility
-                "#,
-            )?),
-        };
+impl LanguageQueries {
+    fn load(language: Language, name: &'static str) -> Result<Self> {
+        let load = |kind| query_loader::load_query(language, name, kind);
+        // パターンのないクエリ（コメントのみのファイル）は使わない
+        let optional = |query: Query| (query.pattern_count() > 0).then_some(query);
 
         Ok(Self {
-            parser,
-            language,
-            queries,
+            symbols: load(QueryKind::Symbols)?,
+            definitions: load(QueryKind::Definitions)?,
+            references: load(QueryKind::References)?,
+            visibility: optional(load(QueryKind::Visibility)?),
+            calls: load(QueryKind::Calls)?,
+            imports: load(QueryKind::Imports)?,
+            docs: optional(load(QueryKind::Docs)?),
         })
     }
+}
+
+/// クエリにマッチしたノード
+#[derive(Debug, Clone)]
+pub struct QueryCaptureMatch {
+    /// キャプチャ名（`@call`なら`call`）
+    pub capture: String,
+    pub text: String,
+    pub range: Range,
+}
+
+/// ドキュメントコメントを探す時に遡る親ノード（宣言のラッパー）
+const DOC_ANCHOR_PARENTS: &[&str] = &[
+    "export_statement",
+    "lexical_declaration",
+    "variable_declaration",
+    "decorated_definition",
+    "type_declaration",
+    "const_declaration",
+    "var_declaration",
+];
+
+impl TreeSitterParser {
+    /// Rust用パーサーを作成
+    pub fn rust() -> Result<Self> {
+        Self::with_queries(tree_sitter_rust::language(), "rust")
+    }
 
     /// TypeScript/JavaScript用パーサーを作成
     pub fn typescript() -> Result<Self> {
-        let mut parser = Parser::new();
-        let language = tree_sitter_typescript::language_typescript();
-        parser.set_language(language)?;
-
-        let queries = LanguageQueries {
-            symbols: Query::new(
-                language,
-                r#"
-                (function_declaration name: (identifier) @function)
-                (class_declaration name: (type_identifier) @class)
-                (interface_declaration name: (type_identifier) @interface)
-                (enum_declaration name: (identifier) @enum)
-                (type_alias_declaration name: (type_identifier) @type_alias)
-                (variable_declarator name: (identifier) @variable)
-                (method_definition name: (property_identifier) @method)
-                "#,
-            )?,
-            definitions: Query::new(
-                language,
-                r#"
-                (variable_declarator name: (identifier) @definition)
-                (formal_parameters (required_parameter pattern: (identifier) @parameter))
-                "#,
-            )?,
-            references: Query::new(
-                language,
-                r#"
-                (identifier) @reference
-                (type_identifier) @type_reference
-                "#,
-            )?,
-            visibility: Some(Query::new(
-                language,
-                r#"
-                (export_statement) @export
-                (accessibility_modifier) @access
-                "#,
-            )?),
-        };
+        Self::with_queries(tree_sitter_typescript::language_typescript(), "typescript")
+    }
 
-        Ok(Self {
-            parser,
-            language,
-            queries,
-        })
+    /// TSX/JSX用パーサーを作成（クエリはTypeScriptと共通）
+    pub fn tsx() -> Result<Self> {
+        Self::with_queries(tree_sitter_typescript::language_tsx(), "typescript")
     }
 
     /// Python用パーサーを作成
     pub fn python() -> Result<Self> {
-        let mut parser = Parser::new();
-        let language = tree_sitter_python::language();
-        parser.set_language(language)?;
-
-        let queries = LanguageQueries {
-            symbols: Query::new(
-                language,
-                r#"
-                (function_definition name: (identifier) @function)
-                (class_definition name: (identifier) @class)
-                (assignment left: (identifier) @variable)
-                (decorated_definition) @decorated
-                "#,
-            )?,
-            definitions: Query::new(
-                language,
-                r#"
-                (assignment left: (identifier) @definition)
-                (parameter (identifier) @parameter)
-                "#,
-            )?,
-            references: Query::new(
-                language,
-                r#"
-                (identifier) @reference
-                (attribute) @attribute_access
-                "#,
-            )?,
-            visibility: None, // Pythonは命名規則で判断
-        };
-
-        Ok(Self {
-            parser,
-            language,
-            queries,
-        })
+        Self::with_queries(tree_sitter_python::language(), "python")
     }
 
     /// Go用パーサーを作成
     pub fn go() -> Result<Self> {
+        Self::with_queries(tree_sitter_go::language(), "go")
+    }
+
+    fn with_queries(language: Language, name: &'static str) -> Result<Self> {
         let mut parser = Parser::new();
-        let language = tree_sitter_go::language();
         parser.set_language(language)?;
-
-        let queries = LanguageQueries {
-            symbols: Query::new(
-                language,
-                r#"
-                (function_declaration name: (identifier) @function)
-                (method_declaration name: (field_identifier) @method)
-                (type_declaration (type_spec name: (type_identifier) @type))
-                (const_declaration (const_spec name: (identifier) @constant))
-                (var_declaration (var_spec name: (identifier) @variable))
-                "#,
-            )?,
-            definitions: Query::new(
-                language,
-                r#"
-                (short_var_declaration left: (identifier_list (identifier) @definition))
-                (var_spec name: (identifier) @definition)
-                "#,
-            )?,
-            references: Query::new(
-                language,
-                r#"
-                (identifier) @reference
-                (type_identifier) @type_reference
-                "#,
-            )?,
-            visibility: None, // Goは大文字小文字で判断
-        };
+        let queries = LanguageQueries::load(language, name)?;
 
         Ok(Self {
             parser,
@@ -199,10 +100,47 @@ impl TreeSitterParser {
         })
     }
 
+    /// 拡張子から対応するパーサーを作成
+    pub fn from_extension(path: &Path) -> Option<Self> {
+        let parser = match path.extension()?.to_str()? {
+            "rs" => Self::rust(),
+            "ts" | "js" | "mjs" | "cjs" => Self::typescript(),
+            "tsx" | "jsx" => Self::tsx(),
+            "py" | "pyi" => Self::python(),
+            "go" => Self::go(),
+            _ => return None,
+        };
+        match parser {
+            Ok(parser) => Some(parser),
+            Err(e) => {
+                // 上書きしたクエリの誤りはファイルごとではなく一度だけ報告する
+                query_loader::warn_once(&e);
+                None
+            }
+        }
+    }
+
+    /// 拡張子をサポートしているか（パーサーは作成しない）
+    pub fn supports_extension(path: &Path) -> bool {
+        matches!(
+            path.extension().and_then(|ext| ext.to_str()),
+            Some("rs" | "ts" | "js" | "mjs" | "cjs" | "tsx" | "jsx" | "py" | "pyi" | "go")
+        )
+    }
+
     /// ソースコードをパース
     pub fn parse(&mut self, source: &str) -> Result<tree_sitter::Tree> {
+        self.parse_incremental(source, None)
+    }
+
+    /// 編集済み（`Tree::edit`を適用した）古い木を再利用してパース
+    pub fn parse_incremental(
+        &mut self,
+        source: &str,
+        old_tree: Option<&tree_sitter::Tree>,
+    ) -> Result<tree_sitter::Tree> {
         self.parser
-            .parse(source, None)
+            .parse(source, old_tree)
             .ok_or_else(|| anyhow::anyhow!("Failed to parse source"))
     }
 
@@ -216,9 +154,14 @@ impl TreeSitterParser {
 
         for m in matches {
             for capture in m.captures {
+                if capture_base(&self.queries.symbols, capture).starts_with('_') {
+                    continue;
+                }
                 let node = capture.node;
                 let kind = self.determine_symbol_kind(&capture);
                 let name = self.get_node_text(&node, source);
+                let detail = self.extract_detail(&node, source);
+                let tag = capture_tag(&self.queries.symbols, capture);
                 
                 symbols.push(Symbol {
                     id: format!("{}:{}:{}", file_path, node.start_position().row, node.start_position().column),
@@ -227,7 +170,12 @@ impl TreeSitterParser {
                     file_path: file_path.to_string(),
                     range: self.node_to_range(&node),
                     documentation: None,
-                    detail: self.extract_detail(&node, source),
+                    modifiers: detail
+                        .as_deref()
+                        .map(SymbolModifiers::from_signature)
+                        .unwrap_or_default(),
+                    detail: with_capture_tag(detail, tag),
+                    qualified_name: None,
                 });
             }
         }
@@ -235,6 +183,125 @@ impl TreeSitterParser {
         Ok(symbols)
     }
 
+    /// 定義全体の範囲・シグネチャ・ドキュメントコメント付きでシンボルを抽出
+    ///
+    /// 結果は文書順。範囲は定義全体なので、入れ子は範囲の包含関係
+    /// （`CodeGraph::link_containment`）から復元できる。
+    pub fn extract_outline(&mut self, source: &str, file_path: &str) -> Result<Vec<Symbol>> {
+        let tree = self.parse(source)?;
+        Ok(self.outline_from_tree(&tree, source, file_path, None))
+    }
+
+    /// 解析済みの木から`extract_outline`と同じシンボルを抽出
+    ///
+    /// `byte_range`を指定するとその範囲と重なる定義だけを対象にする。
+    /// 入れ子の判定のため、範囲は外側の定義全体を含むこと。
+    pub fn outline_from_tree(
+        &self,
+        tree: &tree_sitter::Tree,
+        source: &str,
+        file_path: &str,
+        byte_range: Option<std::ops::Range<usize>>,
+    ) -> Vec<Symbol> {
+        let mut cursor = QueryCursor::new();
+        if let Some(byte_range) = byte_range {
+            cursor.set_byte_range(byte_range);
+        }
+
+        // (定義ノード, 名前, 種別, キャプチャ名の後ろの名前)
+        let mut definitions: Vec<(Node, String, SymbolKind, Option<&str>)> = Vec::new();
+        let matches = cursor.matches(&self.queries.symbols, tree.root_node(), source.as_bytes());
+        for m in matches {
+            for capture in m.captures {
+                let capture_name = capture_base(&self.queries.symbols, capture);
+                let name = match capture_name {
+                    // デコレータ付き定義は中の関数・クラスとして拾う
+                    "decorated" => continue,
+                    // 補助キャプチャ（述語用）
+                    helper if helper.starts_with('_') => continue,
+                    "impl" => format!("impl {}", self.get_node_text(&capture.node, source)),
+                    _ => self.get_node_text(&capture.node, source),
+                };
+                let definition = match capture.node.parent() {
+                    Some(parent) => parent,
+                    None => continue,
+                };
+                let kind = match capture_name {
+                    "impl" => SymbolKind::Class,
+                    "type" => self.go_type_kind(&definition),
+                    _ => self.determine_symbol_kind(capture),
+                };
+                let tag = capture_tag(&self.queries.symbols, capture);
+                definitions.push((definition, name, kind, tag));
+            }
+        }
+
+        // 外側の定義が先に来るように並べる
+        definitions
+            .sort_by_key(|(node, _, _, _)| (node.start_byte(), std::cmp::Reverse(node.end_byte())));
+        // 組み込みのキャプチャと追加したキャプチャが同じ定義に付いた場合は名前を残す
+        definitions.dedup_by(|(node, _, _, tag), (kept, _, _, kept_tag)| {
+            if node.id() != kept.id() {
+                return false;
+            }
+            if kept_tag.is_none() {
+                *kept_tag = tag.take();
+            }
+            true
+        });
+
+        let doc_nodes = self.doc_comment_nodes(&tree, source);
+        let generated = is_generated_source(source);
+        let mut symbols = Vec::with_capacity(definitions.len());
+        // 囲んでいる定義の(終了バイト, 種別)
+        let mut enclosing: Vec<(usize, SymbolKind)> = Vec::new();
+        for (node, name, kind, tag) in definitions {
+            while let Some(&(end, _)) = enclosing.last() {
+                if end >= node.end_byte() {
+                    break;
+                }
+                enclosing.pop();
+            }
+
+            // 型の中の関数はメソッド
+            let kind = match (kind, enclosing.last().map(|&(_, k)| k)) {
+                (
+                    SymbolKind::Function,
+                    Some(
+                        SymbolKind::Class
+                        | SymbolKind::Struct
+                        | SymbolKind::Trait
+                        | SymbolKind::Interface,
+                    ),
+                ) => SymbolKind::Method,
+                (kind, _) => kind,
+            };
+
+            let range = self.node_to_range(&node);
+            let mut symbol = Symbol {
+                id: format!("{}#{}:{}", file_path, range.start.line + 1, name),
+                kind,
+                name,
+                file_path: file_path.to_string(),
+                range,
+                documentation: self.extract_doc_comment(&node, source, &doc_nodes),
+                detail: self.extract_signature(&node, source),
+                modifiers: SymbolModifiers::NONE,
+                qualified_name: None,
+            };
+            let parent_kind = enclosing.last().map(|&(_, k)| k);
+            symbol.modifiers = self.symbol_modifiers(&node, &symbol, parent_kind, source);
+            if generated {
+                symbol.modifiers.insert(SymbolModifiers::GENERATED);
+            }
+            symbol.detail = with_capture_tag(symbol.detail.take(), tag);
+            symbols.push(symbol);
+            enclosing.push((node.end_byte(), kind));
+        }
+
+        symbols
+    }
+
     /// 公開APIを抽出
     pub fn extract_public_apis(&mut self, source: &str, file_path: &str) -> Result<Vec<Symbol>> {
         let symbols = self.extract_symbols(source, file_path)?;
@@ -312,9 +379,8 @@ impl TreeSitterParser {
 
     /// ノードからシンボル種別を判定
     fn determine_symbol_kind(&self, capture: &QueryCapture) -> SymbolKind {
-        let capture_name = &self.queries.symbols.capture_names()[capture.index as usize];
-        
-        match capture_name.as_str() {
+        // `@function.route`のような拡張キャプチャは先頭の種別で判定
+        match capture_base(&self.queries.symbols, capture) {
             "function" => SymbolKind::Function,
             "struct" => SymbolKind::Struct,
             "enum" => SymbolKind::Enum,
@@ -326,6 +392,7 @@ impl TreeSitterParser {
             "variable" => SymbolKind::Variable,
             "method" => SymbolKind::Method,
             "type_alias" => SymbolKind::TypeAlias,
+            "static" => SymbolKind::Variable,
             "parameter" => SymbolKind::Parameter,
             _ => SymbolKind::Unknown,
         }
@@ -337,6 +404,8 @@ impl TreeSitterParser {
     }
 
     /// ノードから範囲を作成
+    ///
+    /// tree-sitterの列はバイト単位なので、そのまま正規のUTF-8の位置になる。
     fn node_to_range(&self, node: &Node) -> Range {
         Range {
             start: Position {
@@ -362,6 +431,353 @@ impl TreeSitterParser {
         None
     }
 
+    /// Goの`type_spec`を構造体・インターフェース・型エイリアスに分類
+    fn go_type_kind(&self, type_spec: &Node) -> SymbolKind {
+        match type_spec.child_by_field_name("type").map(|t| t.kind()) {
+            Some("struct_type") => SymbolKind::Struct,
+            Some("interface_type") => SymbolKind::Interface,
+            _ => SymbolKind::TypeAlias,
+        }
+    }
+
+    /// 本体を除いた宣言部分（複数行のシグネチャは1行にまとめる）
+    fn extract_signature(&self, node: &Node, source: &str) -> Option<String> {
+        let end = match node.child_by_field_name("body") {
+            Some(body) => body.start_byte(),
+            None => {
+                let text = &source[node.byte_range()];
+                node.start_byte() + text.find('\n').unwrap_or(text.len())
+            }
+        };
+        let header = &source[node.start_byte()..end];
+        let signature = header.split_whitespace().collect::<Vec<_>>().join(" ");
+        let signature = signature.trim_end_matches(|c: char| c == '{' || c.is_whitespace());
+        if signature.is_empty() {
+            None
+        } else {
+            Some(signature.to_string())
+        }
+    }
+
+    /// 定義の直前のドキュメントコメント（Pythonはdocstring）
+    fn extract_doc_comment(
+        &self,
+        node: &Node,
+        source: &str,
+        doc_nodes: &HashSet<usize>,
+    ) -> Option<String> {
+        let mut anchor = *node;
+        let raw = loop {
+            if let Some(doc) = self.preceding_doc_comment(&anchor, source, doc_nodes) {
+                break Some(doc);
+            }
+            match anchor.parent() {
+                Some(parent) if DOC_ANCHOR_PARENTS.contains(&parent.kind()) => anchor = parent,
+                _ => break None,
+            }
+        };
+        let raw = raw
+            .into_iter()
+            .chain(self.rust_inner_doc(node, source))
+            .chain(self.python_docstring(node, source))
+            .collect::<Vec<_>>()
+            .join("\n\n");
+
+        // タグを解釈してMarkdownにそろえる（非推奨の印もここで付ける）
+        let mut doc = DocComment::parse(&raw);
+        if let Some(note) = self
+            .rust_deprecated_attribute(&anchor, source)
+            .or_else(|| self.python_deprecation_warning(node, source))
+        {
+            doc.mark_deprecated(note.as_deref());
+        }
+        doc.to_markdown()
+    }
+
+    /// `#[deprecated]`属性（Some(説明)）
+    fn rust_deprecated_attribute(&self, node: &Node, source: &str) -> Option<Option<String>> {
+        let mut sibling = node.prev_sibling();
+        while let Some(prev) = sibling {
+            match prev.kind() {
+                "attribute_item" => {
+                    let text = self.get_node_text(&prev, source);
+                    if let Some(args) = text.strip_prefix("#[deprecated") {
+                        // `note = "..."`か`#[deprecated = "..."]`の文字列を説明とする
+                        let note = match args.find("note") {
+                            Some(i) => first_string_literal(&args[i..]),
+                            None if args.trim_start().starts_with('=') => {
+                                first_string_literal(args)
+                            }
+                            None => None,
+                        };
+                        return Some(note);
+                    }
+                }
+                "line_comment" | "block_comment" => {}
+                _ => break,
+            }
+            sibling = prev.prev_sibling();
+        }
+        None
+    }
+
+    /// 本体の先頭で`warnings.warn(..., DeprecationWarning)`を呼ぶPythonの関数（Some(メッセージ)）
+    fn python_deprecation_warning(&self, node: &Node, source: &str) -> Option<Option<String>> {
+        if node.kind() != "function_definition" {
+            return None;
+        }
+        let body = node.child_by_field_name("body")?;
+        let mut cursor = body.walk();
+        let statements: Vec<Node> = body.named_children(&mut cursor).collect();
+        statements.into_iter().find_map(|statement| {
+            let call = statement.named_child(0)?;
+            if statement.kind() != "expression_statement" || call.kind() != "call" {
+                return None;
+            }
+            let function = self.get_node_text(&call.child_by_field_name("function")?, source);
+            let arguments = self.get_node_text(&call.child_by_field_name("arguments")?, source);
+            if !function.ends_with("warn") || !arguments.contains("DeprecationWarning") {
+                return None;
+            }
+            Some(first_string_literal(&arguments))
+        })
+    }
+
+    /// `mod`本体の先頭にある`//!`コメント
+    fn rust_inner_doc(&self, node: &Node, source: &str) -> Option<String> {
+        if node.kind() != "mod_item" {
+            return None;
+        }
+        let body = node.child_by_field_name("body")?;
+        let mut cursor = body.walk();
+        let lines: Vec<String> = body
+            .named_children(&mut cursor)
+            .take_while(|child| {
+                let text = self.get_node_text(child, source);
+                child.kind().ends_with("comment")
+                    && (text.starts_with("//!") || text.starts_with("/*!"))
+            })
+            .map(|child| clean_comment(&self.get_node_text(&child, source)))
+            .collect();
+        let doc = lines.join("\n").trim().to_string();
+        if doc.is_empty() {
+            None
+        } else {
+            Some(doc)
+        }
+    }
+
+    /// 直前に連続するコメントを集める（属性は読み飛ばす）
+    fn preceding_doc_comment(
+        &self,
+        node: &Node,
+        source: &str,
+        doc_nodes: &HashSet<usize>,
+    ) -> Option<String> {
+        if doc_nodes.is_empty() {
+            return None;
+        }
+
+        let mut lines = Vec::new();
+        let mut next_row = node.start_position().row;
+        let mut sibling = node.prev_sibling();
+        while let Some(prev) = sibling {
+            if prev.kind() == "attribute_item" {
+                next_row = prev.start_position().row;
+                sibling = prev.prev_sibling();
+                continue;
+            }
+            if !doc_nodes.contains(&prev.id()) || prev.end_position().row + 1 < next_row {
+                break;
+            }
+            let text = self.get_node_text(&prev, source);
+            lines.push(clean_comment(&text));
+            next_row = prev.start_position().row;
+            sibling = prev.prev_sibling();
+        }
+
+        if lines.is_empty() {
+            return None;
+        }
+        lines.reverse();
+        let doc = lines.join("\n").trim().to_string();
+        if doc.is_empty() {
+            None
+        } else {
+            Some(doc)
+        }
+    }
+
+    /// docsクエリにマッチしたコメントノード
+    fn doc_comment_nodes(&self, tree: &tree_sitter::Tree, source: &str) -> HashSet<usize> {
+        let query = match &self.queries.docs {
+            Some(query) => query,
+            None => return HashSet::new(),
+        };
+        let mut cursor = QueryCursor::new();
+        cursor
+            .captures(query, tree.root_node(), source.as_bytes())
+            .map(|(m, i)| m.captures[i].node.id())
+            .collect()
+    }
+
+    /// 呼び出し箇所を抽出（callsクエリ）
+    pub fn extract_calls(&mut self, source: &str) -> Result<Vec<QueryCaptureMatch>> {
+        let tree = self.parse(source)?;
+        Ok(self.collect_captures(&self.queries.calls, &tree, source))
+    }
+
+    /// インポートしたモジュールを抽出（importsクエリ）
+    pub fn extract_imports(&mut self, source: &str) -> Result<Vec<QueryCaptureMatch>> {
+        let tree = self.parse(source)?;
+        Ok(self.collect_captures(&self.queries.imports, &tree, source))
+    }
+
+    fn collect_captures(
+        &self,
+        query: &Query,
+        tree: &tree_sitter::Tree,
+        source: &str,
+    ) -> Vec<QueryCaptureMatch> {
+        let mut cursor = QueryCursor::new();
+        cursor
+            .captures(query, tree.root_node(), source.as_bytes())
+            .map(|(m, i)| m.captures[i])
+            .filter_map(|capture| {
+                let name = &query.capture_names()[capture.index as usize];
+                if name.starts_with('_') {
+                    return None;
+                }
+                Some(QueryCaptureMatch {
+                    capture: name.clone(),
+                    text: self.get_node_text(&capture.node, source),
+                    range: self.node_to_range(&capture.node),
+                })
+            })
+            .collect()
+    }
+
+    /// Pythonの関数・クラス本体の先頭の文字列リテラル
+    fn python_docstring(&self, node: &Node, source: &str) -> Option<String> {
+        if !matches!(node.kind(), "function_definition" | "class_definition") {
+            return None;
+        }
+        let first = node.child_by_field_name("body")?.named_child(0)?;
+        if first.kind() != "expression_statement" {
+            return None;
+        }
+        let string = first.named_child(0)?;
+        if string.kind() != "string" {
+            return None;
+        }
+
+        let text = self.get_node_text(&string, source);
+        let text = text.trim_start_matches(|c: char| c.is_ascii_alphabetic());
+        let quote = ["\"\"\"", "'''", "\"", "'"]
+            .into_iter()
+            .find(|q| text.starts_with(q) && text.len() >= q.len() * 2)?;
+        let doc = dedent_docstring(&text[quote.len()..text.len() - quote.len()]);
+        if doc.is_empty() {
+            None
+        } else {
+            Some(doc)
+        }
+    }
+
+    /// 定義の修飾子（シグネチャのキーワードに言語ごとの可視性とテストの判定を加える）
+    fn symbol_modifiers(
+        &self,
+        node: &Node,
+        symbol: &Symbol,
+        parent_kind: Option<SymbolKind>,
+        source: &str,
+    ) -> SymbolModifiers {
+        let mut modifiers = symbol
+            .detail
+            .as_deref()
+            .map(SymbolModifiers::from_signature)
+            .unwrap_or_default();
+        if symbol.is_deprecated() {
+            modifiers.insert(SymbolModifiers::DEPRECATED);
+        }
+        modifiers.apply_language_rules(&symbol.name, &symbol.file_path, parent_kind);
+
+        // 名前やシグネチャだけでは分からないものは構文木から判定する
+        let extension = Path::new(&symbol.file_path)
+            .extension()
+            .and_then(|ext| ext.to_str())
+            .unwrap_or_default();
+        match extension {
+            "rs" => {
+                if self.is_rust_test(node, source) {
+                    modifiers.insert(SymbolModifiers::TEST);
+                }
+            }
+            "go" | "py" | "pyi" => {}
+            _ => {
+                // `export function`の`export`は外側のノードにある
+                let mut ancestor = node.parent();
+                while let Some(parent) = ancestor {
+                    if parent.kind() == "export_statement" {
+                        modifiers.insert(SymbolModifiers::EXPORTED | SymbolModifiers::PUBLIC);
+                        break;
+                    }
+                    if !DOC_ANCHOR_PARENTS.contains(&parent.kind()) {
+                        break;
+                    }
+                    ancestor = parent.parent();
+                }
+            }
+        }
+        modifiers
+    }
+
+    /// `#[test]`などのテスト属性が付いているか、`#[cfg(test)]`のモジュールの中にある
+    fn is_rust_test(&self, node: &Node, source: &str) -> bool {
+        let is_cfg_test = |text: &String| text.replace(' ', "") == "#[cfg(test)]";
+        // `#[test]`、`#[tokio::test]`、`#[rstest]`など
+        let is_test_attribute = |text: &String| {
+            let path = text.trim_start_matches("#[").trim_end_matches(']');
+            path.split('(')
+                .next()
+                .unwrap_or(path)
+                .trim()
+                .ends_with("test")
+        };
+        let attributes = self.attributes(node, source);
+        if attributes
+            .iter()
+            .any(|a| is_test_attribute(a) || is_cfg_test(a))
+        {
+            return true;
+        }
+        let mut ancestor = node.parent();
+        while let Some(parent) = ancestor {
+            if parent.kind() == "mod_item"
+                && self.attributes(&parent, source).iter().any(is_cfg_test)
+            {
+                return true;
+            }
+            ancestor = parent.parent();
+        }
+        false
+    }
+
+    /// 定義の直前にある属性（`#[...]`）
+    fn attributes(&self, node: &Node, source: &str) -> Vec<String> {
+        let mut attributes = Vec::new();
+        let mut sibling = node.prev_sibling();
+        while let Some(prev) = sibling {
+            match prev.kind() {
+                "attribute_item" => attributes.push(self.get_node_text(&prev, source)),
+                "line_comment" | "block_comment" => {}
+                _ => break,
+            }
+            sibling = prev.prev_sibling();
+        }
+        attributes
+    }
+
     /// 命名規則で公開APIか判定
     fn is_public_by_naming(&self, symbol: &Symbol) -> bool {
         // Go: 大文字始まり
@@ -391,6 +807,90 @@ impl TreeSitterParser {
     }
 }
 
+/// キャプチャ名の種別部分（`function.route`なら`function`）
+fn capture_base<'q>(query: &'q Query, capture: &QueryCapture) -> &'q str {
+    let name = &query.capture_names()[capture.index as usize];
+    name.split('.').next().unwrap_or(name)
+}
+
+/// キャプチャ名の種別の後ろの名前（`function.route`なら`route`）
+fn capture_tag<'q>(query: &'q Query, capture: &QueryCapture) -> Option<&'q str> {
+    let name = &query.capture_names()[capture.index as usize];
+    name.split_once('.').map(|(_, tag)| tag)
+}
+
+/// シグネチャの後ろにキャプチャの名前を`@route`の形で付ける
+///
+/// 修飾子や完全修飾名はシグネチャの先頭から読むので、名前は末尾に置く。
+fn with_capture_tag(detail: Option<String>, tag: Option<&str>) -> Option<String> {
+    match (detail, tag) {
+        (detail, None) => detail,
+        (Some(detail), Some(tag)) => Some(format!("{} @{}", detail, tag)),
+        (None, Some(tag)) => Some(format!("@{}", tag)),
+    }
+}
+
+/// docstringの2行目以降の共通インデントを除く（`inspect.cleandoc`と同じ）
+fn dedent_docstring(text: &str) -> String {
+    let mut lines = text.lines();
+    let first = lines.next().unwrap_or_default().trim();
+    let rest: Vec<&str> = lines.collect();
+    let indent = rest
+        .iter()
+        .filter(|line| !line.trim().is_empty())
+        .map(|line| line.len() - line.trim_start().len())
+        .min()
+        .unwrap_or(0);
+
+    std::iter::once(first)
+        .chain(
+            rest.iter()
+                .map(|line| line.get(indent..).unwrap_or_default().trim_end()),
+        )
+        .collect::<Vec<_>>()
+        .join("\n")
+        .trim()
+        .to_string()
+}
+
+/// 生成されたコードか（先頭の`Code generated ... DO NOT EDIT.`や`@generated`）
+fn is_generated_source(source: &str) -> bool {
+    source.lines().take(10).any(|line| {
+        (line.contains("Code generated") && line.contains("DO NOT EDIT"))
+            || line.contains("@generated")
+    })
+}
+
+/// 最初の`"..."`の中身
+fn first_string_literal(text: &str) -> Option<String> {
+    let quote = text.find(|c: char| c == '"' || c == '\'')?;
+    let delimiter = text[quote..].chars().next()?;
+    let rest = &text[quote + 1..];
+    let end = rest.find(delimiter)?;
+    Some(rest[..end].to_string())
+}
+
+/// コメント記号を取り除く
+fn clean_comment(text: &str) -> String {
+    text.lines()
+        .map(|line| {
+            let line = line.trim();
+            let line = line
+                .strip_prefix("///")
+                .or_else(|| line.strip_prefix("//!"))
+                .or_else(|| line.strip_prefix("//"))
+                .or_else(|| line.strip_prefix("/**"))
+                .or_else(|| line.strip_prefix("/*!"))
+                .or_else(|| line.strip_prefix("/*"))
+                .unwrap_or(line);
+            let line = line.strip_suffix("*/").unwrap_or(line);
+            let line = line.trim_start().strip_prefix('*').unwrap_or(line);
+            line.strip_prefix(' ').unwrap_or(line).trim_end()
+        })
+        .collect::<Vec<_>>()
+        .join("\n")
+}
+
 #[cfg(test)]
 mod tests {
     use super::*;
@@ -474,6 +974,315 @@ type Person struct {
         assert!(symbols.iter().any(|s| s.name == "Person"));
     }
 
+    #[test]
+    fn test_rust_outline() {
+        let mut parser = TreeSitterParser::rust().unwrap();
+        let source = r#"
+/// 設定
+#[derive(Debug)]
+pub struct Config {
+    name: String,
+}
+
+impl Config {
+    /// 作成する
+    pub fn new(
+        name: String,
+    ) -> Self {
+        Self { name }
+    }
+}
+"#;
+
+        let symbols = parser.extract_outline(source, "test.rs").unwrap();
+        let config = symbols.iter().find(|s| s.name == "Config").unwrap();
+        assert_eq!(config.kind, SymbolKind::Struct);
+        assert_eq!(config.documentation.as_deref(), Some("設定"));
+        assert_eq!(config.range.start.line, 3);
+        assert_eq!(config.range.end.line, 5);
+
+        let new = symbols.iter().find(|s| s.name == "new").unwrap();
+        assert_eq!(new.kind, SymbolKind::Method);
+        assert_eq!(new.documentation.as_deref(), Some("作成する"));
+        assert_eq!(
+            new.detail.as_deref(),
+            Some("pub fn new( name: String, ) -> Self")
+        );
+
+        // implの範囲がメソッドを含む
+        let imp = symbols.iter().find(|s| s.name == "impl Config").unwrap();
+        assert!(imp.range.start.line < new.range.start.line);
+        assert!(imp.range.end.line > new.range.end.line);
+    }
+
+    #[test]
+    fn test_go_outline_type_group() {
+        let mut parser = TreeSitterParser::go().unwrap();
+        let source = r#"
+package main
+
+type (
+    // Reader reads
+    Reader interface {
+        Read() error
+    }
+    Point struct {
+        X int
+    }
+)
+
+// Len returns the length
+func (p Point) Len() int { return p.X }
+"#;
+
+        let symbols = parser.extract_outline(source, "test.go").unwrap();
+        let reader = symbols.iter().find(|s| s.name == "Reader").unwrap();
+        assert_eq!(reader.kind, SymbolKind::Interface);
+        assert_eq!(reader.documentation.as_deref(), Some("Reader reads"));
+        let point = symbols.iter().find(|s| s.name == "Point").unwrap();
+        assert_eq!(point.kind, SymbolKind::Struct);
+        let len = symbols.iter().find(|s| s.name == "Len").unwrap();
+        assert_eq!(len.kind, SymbolKind::Method);
+        assert_eq!(len.documentation.as_deref(), Some("Len returns the length"));
+    }
+
+    #[test]
+    fn test_python_outline_docstring() {
+        let mut parser = TreeSitterParser::python().unwrap();
+        let source = r#"
+class Greeter:
+    """Greets people."""
+
+    def greet(self, name):
+        """Say hello."""
+        return name
+"#;
+
+        let symbols = parser.extract_outline(source, "test.py").unwrap();
+        let greeter = symbols.iter().find(|s| s.name == "Greeter").unwrap();
+        assert_eq!(greeter.documentation.as_deref(), Some("Greets people."));
+        let greet = symbols.iter().find(|s| s.name == "greet").unwrap();
+        assert_eq!(greet.kind, SymbolKind::Method);
+        assert_eq!(greet.documentation.as_deref(), Some("Say hello."));
+        assert_eq!(greet.detail.as_deref(), Some("def greet(self, name):"));
+    }
+
+    #[test]
+    fn test_custom_capture_name_is_kept() {
+        // `.lsif/queries/python/symbols.scm`に`; extends`で追記した場合と同じクエリ
+        let custom = r#"
+(decorated_definition
+  (decorator (call function: (attribute attribute: (identifier) @_verb)))
+  definition: (function_definition name: (identifier) @function.route)
+  (#match? @_verb "^(route|get|post|put|delete)$"))
+"#;
+        let mut parser = TreeSitterParser::python().unwrap();
+        let text = format!(
+            "{}{}",
+            query_loader::builtin_source("python", QueryKind::Symbols).unwrap(),
+            custom
+        );
+        parser.queries.symbols = Query::new(tree_sitter_python::language(), &text).unwrap();
+
+        let source = r#"
+@app.get("/users")
+def list_users():
+    return []
+
+def helper():
+    pass
+"#;
+        let symbols = parser.extract_outline(source, "app.py").unwrap();
+        let list_users: Vec<_> = symbols.iter().filter(|s| s.name == "list_users").collect();
+        assert_eq!(list_users.len(), 1);
+        assert_eq!(list_users[0].kind, SymbolKind::Function);
+        assert_eq!(
+            list_users[0].detail.as_deref(),
+            Some("def list_users(): @route")
+        );
+        let helper = symbols.iter().find(|s| s.name == "helper").unwrap();
+        assert_eq!(helper.detail.as_deref(), Some("def helper():"));
+
+        let symbols = parser.extract_symbols(source, "app.py").unwrap();
+        assert!(symbols.iter().any(|s| s.name == "list_users"
+            && s.detail.as_deref().is_some_and(|d| d.ends_with("@route"))));
+    }
+
+    #[test]
+    fn test_deprecation_markers() {
+        let mut parser = TreeSitterParser::rust().unwrap();
+        let source = r#"
+mod legacy {
+    //! 古いAPI
+}
+
+/// 読み込む
+#[deprecated(since = "0.2.0", note = "use load_v2")]
+pub fn load() {}
+"#;
+        let symbols = parser.extract_outline(source, "lib.rs").unwrap();
+        let legacy = symbols.iter().find(|s| s.name == "legacy").unwrap();
+        assert_eq!(legacy.documentation.as_deref(), Some("古いAPI"));
+        let load = symbols.iter().find(|s| s.name == "load").unwrap();
+        assert_eq!(
+            load.documentation.as_deref(),
+            Some("読み込む\n\n**Deprecated**: use load_v2")
+        );
+        assert_eq!(load.deprecation(), Some("use load_v2"));
+
+        let mut parser = TreeSitterParser::go().unwrap();
+        let source = r#"
+package db
+
+// Open opens the database.
+//
+// Deprecated: Use OpenContext instead.
+func Open() {}
+"#;
+        let symbols = parser.extract_outline(source, "db.go").unwrap();
+        let open = symbols.iter().find(|s| s.name == "Open").unwrap();
+        assert_eq!(open.deprecation(), Some("Use OpenContext instead."));
+
+        let mut parser = TreeSitterParser::typescript().unwrap();
+        let source = r#"
+/**
+ * Fetches a user.
+ * @param id - The user id
+ * @deprecated Use fetchUserById.
+ */
+export function fetchUser(id: string) {}
+"#;
+        let symbols = parser.extract_outline(source, "api.ts").unwrap();
+        let fetch = symbols.iter().find(|s| s.name == "fetchUser").unwrap();
+        assert_eq!(
+            fetch.documentation.as_deref(),
+            Some("Fetches a user.\n\n**Deprecated**: Use fetchUserById.\n\n**Parameters**\n- `id`: The user id")
+        );
+
+        let mut parser = TreeSitterParser::python().unwrap();
+        let source = r#"
+def old(name):
+    """Say hello.
+
+    :param name: who to greet
+    """
+    warnings.warn("use new()", DeprecationWarning)
+"#;
+        let symbols = parser.extract_outline(source, "m.py").unwrap();
+        let old = symbols.iter().find(|s| s.name == "old").unwrap();
+        assert_eq!(
+            old.documentation.as_deref(),
+            Some(
+                "Say hello.\n\n**Deprecated**: use new()\n\n**Parameters**\n- `name`: who to greet"
+            )
+        );
+    }
+
+    #[test]
+    fn test_outline_modifiers() {
+        let modifiers = |parser: &mut TreeSitterParser, source: &str, file: &str| {
+            parser
+                .extract_outline(source, file)
+                .unwrap()
+                .into_iter()
+                .map(|s| (s.name, s.modifiers.to_string()))
+                .collect::<Vec<_>>()
+        };
+
+        let mut rust = TreeSitterParser::rust().unwrap();
+        let source = r#"
+pub async fn fetch() {}
+pub(crate) fn helper() {}
+fn private() {}
+
+#[cfg(test)]
+mod tests {
+    #[tokio::test]
+    async fn it_works() {}
+}
+"#;
+        assert_eq!(
+            modifiers(&mut rust, source, "src/lib.rs"),
+            vec![
+                ("fetch".to_string(), "public,async".to_string()),
+                ("helper".to_string(), "internal".to_string()),
+                ("private".to_string(), "private".to_string()),
+                ("tests".to_string(), "private,test".to_string()),
+                ("it_works".to_string(), "private,async,test".to_string()),
+            ]
+        );
+
+        let mut go = TreeSitterParser::go().unwrap();
+        let source = "// Code generated by protoc. DO NOT EDIT.\n\npackage pb\n\nfunc New() {}\nfunc helper() {}\n";
+        assert_eq!(
+            modifiers(&mut go, source, "pb/pb.go"),
+            vec![
+                ("New".to_string(), "public,exported,generated".to_string()),
+                ("helper".to_string(), "private,generated".to_string()),
+            ]
+        );
+        let test_symbols = modifiers(
+            &mut go,
+            "package pb\n\nfunc TestNew(t *testing.T) {}\n",
+            "pb/pb_test.go",
+        );
+        assert_eq!(test_symbols[0].1, "public,exported,test");
+
+        let mut ts = TreeSitterParser::typescript().unwrap();
+        let source = r#"
+export function load() {}
+function local() {}
+export class Shape {
+    static async create() {}
+    private reset() {}
+}
+"#;
+        assert_eq!(
+            modifiers(&mut ts, source, "src/shape.ts"),
+            vec![
+                ("load".to_string(), "public,exported".to_string()),
+                ("local".to_string(), "".to_string()),
+                ("Shape".to_string(), "public,exported".to_string()),
+                ("create".to_string(), "async,static".to_string()),
+                ("reset".to_string(), "private".to_string()),
+            ]
+        );
+    }
+
+    #[test]
+    fn test_go_calls_and_imports() {
+        let mut parser = TreeSitterParser::go().unwrap();
+        let source = r#"
+package main
+
+import (
+    "fmt"
+    "net/http"
+)
+
+func main() {
+    http.HandleFunc("/", handler)
+    fmt.Println(run())
+}
+"#;
+
+        let imports: Vec<_> = parser
+            .extract_imports(source)
+            .unwrap()
+            .into_iter()
+            .map(|c| c.text)
+            .collect();
+        assert_eq!(imports, vec!["\"fmt\"", "\"net/http\""]);
+
+        let calls: Vec<_> = parser
+            .extract_calls(source)
+            .unwrap()
+            .into_iter()
+            .map(|c| c.text)
+            .collect();
+        assert_eq!(calls, vec!["HandleFunc", "Println", "run"]);
+    }
+
     #[test]
     fn test_complexity_calculation() {
         let mut parser = TreeSitterParser::rust().unwrap();
@@ -6,6 +6,9 @@ use tracing::{debug, info};
 // lsif-coreクレートからのインポート
 use lsif_core::{CodeGraph, Position, Range, Symbol, SymbolKind};
 
+use crate::lsp_helpers::{
+    document_symbol_modifiers, symbol_information_modifiers, SourceDocComments,
+};
 use crate::lsp_manager::UnifiedLspManager;
 
 /// LSPベースの統一インデクサー
@@ -46,7 +49,9 @@ impl UnifiedIndexer {
         // 各ファイルのシンボルをCodeGraphに変換
         for (file_path, doc_symbols) in project_index.symbols {
             let file_uri = format!("file://{}", file_path.display());
-            let symbols = self.convert_document_symbols(&doc_symbols, &file_uri, &file_path);
+            let docs = SourceDocComments::load(&file_path);
+            let symbols =
+                self.convert_document_symbols(&doc_symbols, None, &file_uri, &file_path, &docs);
 
             total_symbols += symbols.len();
             total_files += 1;
@@ -81,7 +86,9 @@ impl UnifiedIndexer {
         let doc_symbols = self.lsp_manager.get_document_symbols(file_path).await?;
 
         let file_uri = format!("file://{}", file_path.display());
-        let symbols = self.convert_document_symbols(&doc_symbols, &file_uri, file_path);
+        let docs = SourceDocComments::load(file_path);
+        let symbols =
+            self.convert_document_symbols(&doc_symbols, None, &file_uri, file_path, &docs);
 
         // グラフに追加
         for symbol in &symbols {
@@ -95,17 +102,27 @@ impl UnifiedIndexer {
     fn convert_document_symbols(
         &self,
         doc_symbols: &[DocumentSymbol],
+        parent: Option<&DocumentSymbol>,
         file_uri: &str,
         file_path: &Path,
+        docs: &SourceDocComments,
     ) -> Vec<Symbol> {
         let mut symbols = Vec::new();
 
         for doc_symbol in doc_symbols {
-            symbols.push(self.convert_document_symbol(doc_symbol, file_uri, file_path));
+            let mut symbol = self.convert_document_symbol(doc_symbol, parent, file_uri, file_path);
+            docs.attach(doc_symbol, &mut symbol);
+            symbols.push(symbol);
 
             // 子シンボルも再帰的に変換
             if let Some(children) = &doc_symbol.children {
-                symbols.extend(self.convert_document_symbols(children, file_uri, file_path));
+                symbols.extend(self.convert_document_symbols(
+                    children,
+                    Some(doc_symbol),
+                    file_uri,
+                    file_path,
+                    docs,
+                ));
             }
         }
 
@@ -116,10 +133,12 @@ impl UnifiedIndexer {
     fn convert_document_symbol(
         &self,
         doc_symbol: &DocumentSymbol,
+        parent: Option<&DocumentSymbol>,
         _file_uri: &str,
         file_path: &Path,
     ) -> Symbol {
         let file_path_str = file_path.to_string_lossy().to_string();
+        let modifiers = document_symbol_modifiers(doc_symbol, &file_path_str, parent);
 
         Symbol {
             id: format!(
@@ -139,8 +158,11 @@ impl UnifiedIndexer {
                     character: doc_symbol.range.end.character,
                 },
             },
-            documentation: doc_symbol.detail.clone(),
-            detail: None,
+            // detailはシグネチャ（ドキュメントコメントはソースから付ける）
+            documentation: None,
+            detail: doc_symbol.detail.clone(),
+            modifiers,
+            qualified_name: None,
         }
     }
 
@@ -192,6 +214,7 @@ impl UnifiedIndexer {
                 .uri
                 .to_file_path()
                 .map_err(|_| anyhow::anyhow!("Invalid URI"))?;
+            let modifiers = symbol_information_modifiers(&info);
 
             symbols.push(Symbol {
                 id: format!(
@@ -215,6 +238,8 @@ impl UnifiedIndexer {
                 },
                 documentation: None,
                 detail: info.container_name,
+                modifiers,
+                qualified_name: None,
             });
         }
 
@@ -317,16 +342,14 @@ mod tests {
 
         let file_path = Path::new("test.rs");
         let file_uri = "file://test.rs";
-        let symbol = indexer.convert_document_symbol(&doc_symbol, file_uri, file_path);
+        let symbol = indexer.convert_document_symbol(&doc_symbol, None, file_uri, file_path);
 
         assert_eq!(symbol.name, "test_function");
         assert_eq!(symbol.kind, SymbolKind::Function);
         assert_eq!(symbol.range.start.line, 10);
         assert_eq!(symbol.range.start.character, 5);
-        assert_eq!(
-            symbol.documentation,
-            Some("Test function detail".to_string())
-        );
+        assert_eq!(symbol.detail, Some("Test function detail".to_string()));
+        assert_eq!(symbol.documentation, None);
     }
 
     #[tokio::test]
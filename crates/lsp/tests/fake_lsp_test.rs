@@ -0,0 +1,515 @@
+//! フェイクLSPサーバーを使ったクライアント・プールの決定的テスト
+use lsp::adapter::lsp::GenericLspClient;
+use lsp::auto_switching_client::AutoSwitchingLspClient;
+use lsp::fake_lsp_server::{CannedResponse, FakeLspAdapter, FakeLspScenario};
+use lsp::lsp_health_check::{LspHealthChecker, LspOperationType};
+use lsp::lsp_pool::{LspClientPool, PoolConfig};
+use lsp::lsp_timing_stats::LspTimingStats;
+use lsp::timeout_predictor::TimeoutPredictor;
+use std::fs;
+use std::path::{Path, PathBuf};
+use std::sync::atomic::{AtomicUsize, Ordering};
+use std::sync::Arc;
+use std::time::{Duration, Instant};
+use tempfile::TempDir;
+
+const FAKE_LSP: &str = env!("CARGO_BIN_EXE_fake_lsp");
+
+fn write_scenario(dir: &Path, scenario: &FakeLspScenario) -> PathBuf {
+    let path = dir.join("scenario.json");
+    scenario.save(&path).unwrap();
+    path
+}
+
+fn document_symbol_scenario() -> FakeLspScenario {
+    FakeLspScenario::default()
+        .with_capabilities(serde_json::json!({
+            "documentSymbolProvider": true,
+            "workspaceSymbolProvider": true,
+        }))
+        .with_response(CannedResponse::result(
+            "textDocument/documentSymbol",
+            serde_json::json!([{
+                "name": "main",
+                "kind": 12,
+                "range": {
+                    "start": { "line": 0, "character": 0 },
+                    "end": { "line": 0, "character": 12 }
+                },
+                "selectionRange": {
+                    "start": { "line": 0, "character": 3 },
+                    "end": { "line": 0, "character": 7 }
+                }
+            }]),
+        ))
+}
+
+fn fast_pool_config() -> PoolConfig {
+    PoolConfig {
+        max_instances_per_language: 2,
+        max_idle_time: Duration::from_secs(60),
+        init_timeout: Duration::from_millis(500),
+        request_timeout: Duration::from_millis(500),
+        max_retries: 2,
+    }
+}
+
+#[test]
+fn test_generic_client_against_fake_server() {
+    let temp_dir = TempDir::new().unwrap();
+    let scenario_path = write_scenario(temp_dir.path(), &document_symbol_scenario());
+    let source = temp_dir.path().join("main.rs");
+    fs::write(&source, "fn main() {}").unwrap();
+
+    let adapter = FakeLspAdapter::new(FAKE_LSP, &scenario_path).with_language_id("rust");
+    let mut client = GenericLspClient::new_uninit(Box::new(adapter)).unwrap();
+    client
+        .initialize(temp_dir.path(), Some(Duration::from_secs(5)))
+        .unwrap();
+
+    assert!(client.has_capability("textDocument/documentSymbol"));
+    assert!(!client.has_capability("textDocument/references"));
+
+    let uri = format!("file://{}", source.canonicalize().unwrap().display());
+    let symbols = client.get_document_symbols(&uri).unwrap();
+    assert_eq!(symbols.len(), 1);
+    assert_eq!(symbols[0].name, "main");
+}
+
+#[test]
+fn test_terminate_waits_for_exit_instead_of_killing() {
+    let temp_dir = TempDir::new().unwrap();
+    let scenario_path = write_scenario(temp_dir.path(), &document_symbol_scenario());
+
+    let adapter = FakeLspAdapter::new(FAKE_LSP, &scenario_path).with_language_id("rust");
+    let mut client = GenericLspClient::new_uninit(Box::new(adapter)).unwrap();
+    client
+        .initialize(temp_dir.path(), Some(Duration::from_secs(5)))
+        .unwrap();
+
+    // shutdown・exitに応じて終了するので猶予時間いっぱいは待たない
+    let start = Instant::now();
+    client.terminate(Duration::from_secs(5));
+    assert!(start.elapsed() < Duration::from_secs(5));
+}
+
+#[test]
+fn test_pool_uses_adapter_factory() {
+    let temp_dir = TempDir::new().unwrap();
+    let scenario_path = write_scenario(temp_dir.path(), &document_symbol_scenario());
+    let source = temp_dir.path().join("lib.rs");
+    fs::write(&source, "pub fn lib() {}").unwrap();
+
+    let pool = LspClientPool::new(fast_pool_config()).with_adapter_factory(Arc::new(
+        move |language_id: &str| {
+            Some(Box::new(
+                FakeLspAdapter::new(FAKE_LSP, &scenario_path).with_language_id(language_id),
+            ) as Box<dyn lsp::LspAdapter>)
+        },
+    ));
+
+    pool.get_or_create_client(&source, temp_dir.path()).unwrap();
+    assert!(pool.has_capability_for_language("rust", "textDocument/documentSymbol"));
+    assert!(pool.has_capability_for_language("rust", "workspace/symbol"));
+
+    // 2回目はプールから再利用される
+    pool.get_or_create_client(&source, temp_dir.path()).unwrap();
+    assert_eq!(pool.get_stats().total_clients, 1);
+
+    pool.shutdown_all();
+    assert_eq!(pool.get_stats().total_clients, 0);
+}
+
+#[test]
+fn test_pool_gives_up_after_retries_when_server_crashes() {
+    let temp_dir = TempDir::new().unwrap();
+    // initializeを受け取った瞬間にクラッシュする
+    let scenario = FakeLspScenario {
+        crash_exit_code: 1,
+        ..Default::default()
+    }
+    .with_crash_after(0);
+    let scenario_path = write_scenario(temp_dir.path(), &scenario);
+    let source = temp_dir.path().join("main.rs");
+    fs::write(&source, "fn main() {}").unwrap();
+
+    // サーバーを起動した回数を数える
+    let launches = Arc::new(AtomicUsize::new(0));
+    let counter = Arc::clone(&launches);
+    let pool = LspClientPool::new(fast_pool_config()).with_adapter_factory(Arc::new(
+        move |language_id: &str| {
+            counter.fetch_add(1, Ordering::SeqCst);
+            Some(Box::new(
+                FakeLspAdapter::new(FAKE_LSP, &scenario_path).with_language_id(language_id),
+            ) as Box<dyn lsp::LspAdapter>)
+        },
+    ));
+
+    assert!(pool.get_or_create_client(&source, temp_dir.path()).is_err());
+    assert_eq!(pool.get_stats().total_clients, 0);
+    // max_retriesの回数だけ起動し直してから諦める
+    assert_eq!(
+        launches.load(Ordering::SeqCst),
+        fast_pool_config().max_retries
+    );
+}
+
+#[test]
+fn test_delayed_and_error_responses() {
+    let temp_dir = TempDir::new().unwrap();
+    let scenario = FakeLspScenario::default()
+        .with_capabilities(serde_json::json!({ "workspaceSymbolProvider": true }))
+        .with_response(
+            CannedResponse::error("workspace/symbol", -32801, "content modified").times(1),
+        )
+        .with_response(
+            CannedResponse::result("workspace/symbol", serde_json::json!([])).delayed(200),
+        );
+    let scenario_path = write_scenario(temp_dir.path(), &scenario);
+
+    let adapter = FakeLspAdapter::new(FAKE_LSP, &scenario_path);
+    let mut client = GenericLspClient::new_uninit(Box::new(adapter)).unwrap();
+    client
+        .initialize(temp_dir.path(), Some(Duration::from_secs(5)))
+        .unwrap();
+
+    // 1回目はエラー
+    let error = client.search_workspace_symbols("").unwrap_err();
+    assert!(error.to_string().contains("LSP error"));
+
+    // 2回目は遅延付きで成功
+    let start = Instant::now();
+    let symbols = client.search_workspace_symbols("").unwrap();
+    assert!(symbols.is_empty());
+    assert!(start.elapsed() >= Duration::from_millis(200));
+}
+
+#[test]
+fn test_empty_definition_is_not_an_error() {
+    let temp_dir = TempDir::new().unwrap();
+    let scenario = FakeLspScenario::default()
+        .with_capabilities(serde_json::json!({ "definitionProvider": true }))
+        .with_response(CannedResponse::result(
+            "textDocument/definition",
+            serde_json::Value::Null,
+        ));
+    let scenario_path = write_scenario(temp_dir.path(), &scenario);
+    let source = temp_dir.path().join("main.rs");
+    fs::write(&source, "fn main() {}").unwrap();
+
+    let adapter = FakeLspAdapter::new(FAKE_LSP, &scenario_path).with_language_id("rust");
+    let mut client = GenericLspClient::new_uninit(Box::new(adapter)).unwrap();
+    client
+        .initialize(temp_dir.path(), Some(Duration::from_secs(5)))
+        .unwrap();
+
+    let uri = format!("file://{}", source.canonicalize().unwrap().display());
+    let params = lsp_types::GotoDefinitionParams {
+        text_document_position_params: lsp_types::TextDocumentPositionParams {
+            text_document: lsp_types::TextDocumentIdentifier {
+                uri: uri.parse().unwrap(),
+            },
+            position: lsp_types::Position::new(0, 3),
+        },
+        work_done_progress_params: Default::default(),
+        partial_result_params: Default::default(),
+    };
+
+    // 空の結果はキャッシュできるようにOk(空)で返る
+    assert!(client.goto_definitions(params.clone()).unwrap().is_empty());
+    assert!(client.goto_definition(params).is_err());
+}
+
+#[test]
+fn test_document_highlights_convert_multibyte_columns() {
+    let temp_dir = TempDir::new().unwrap();
+    // positionEncodingを返さないサーバーはUTF-16の列で答える
+    let scenario = FakeLspScenario::default()
+        .with_capabilities(serde_json::json!({ "documentHighlightProvider": true }))
+        .with_response(CannedResponse::result(
+            "textDocument/documentHighlight",
+            serde_json::json!([
+                {
+                    "range": {
+                        "start": { "line": 0, "character": 16 },
+                        "end": { "line": 0, "character": 18 }
+                    },
+                    "kind": 3
+                },
+                {
+                    "range": {
+                        "start": { "line": 0, "character": 24 },
+                        "end": { "line": 0, "character": 26 }
+                    },
+                    "kind": 2
+                }
+            ]),
+        ));
+    let scenario_path = write_scenario(temp_dir.path(), &scenario);
+    let source = temp_dir.path().join("main.rs");
+    fs::write(&source, "fn main() { let 名前 = 1; 名前 + 1; }").unwrap();
+
+    let adapter = FakeLspAdapter::new(FAKE_LSP, &scenario_path).with_language_id("rust");
+    let mut client = GenericLspClient::new_uninit(Box::new(adapter)).unwrap();
+    client
+        .initialize(temp_dir.path(), Some(Duration::from_secs(5)))
+        .unwrap();
+
+    let uri = format!("file://{}", source.canonicalize().unwrap().display());
+    let params = lsp_types::DocumentHighlightParams {
+        text_document_position_params: lsp_types::TextDocumentPositionParams {
+            text_document: lsp_types::TextDocumentIdentifier {
+                uri: uri.parse().unwrap(),
+            },
+            // UTF-8バイトの列（`名前`の先頭）
+            position: lsp_types::Position::new(0, 16),
+        },
+        work_done_progress_params: Default::default(),
+        partial_result_params: Default::default(),
+    };
+
+    // 返ってきた範囲はUTF-8バイトの列に直っている
+    let ranges: Vec<(u32, u32)> = client
+        .document_highlights(params)
+        .unwrap()
+        .iter()
+        .map(|highlight| {
+            (
+                highlight.range.start.character,
+                highlight.range.end.character,
+            )
+        })
+        .collect();
+    assert_eq!(ranges, vec![(16, 22), (28, 34)]);
+}
+
+#[test]
+fn test_health_checker_learns_from_delayed_responses() {
+    let temp_dir = TempDir::new().unwrap();
+    let scenario = FakeLspScenario::default()
+        .with_capabilities(serde_json::json!({ "documentSymbolProvider": true }))
+        .with_response(
+            CannedResponse::result("textDocument/documentSymbol", serde_json::json!([]))
+                .delayed(100),
+        );
+    let scenario_path = write_scenario(temp_dir.path(), &scenario);
+    let source = temp_dir.path().join("main.rs");
+    fs::write(&source, "fn main() {}").unwrap();
+
+    let adapter = FakeLspAdapter::new(FAKE_LSP, &scenario_path).with_language_id("rust");
+    let mut client = GenericLspClient::new_uninit(Box::new(adapter)).unwrap();
+    client
+        .initialize(temp_dir.path(), Some(Duration::from_secs(5)))
+        .unwrap();
+
+    // ウォームアップ期間（最初の5リクエスト）を越えるまで呼ぶ
+    let uri = format!("file://{}", source.canonicalize().unwrap().display());
+    for _ in 0..4 {
+        client.get_document_symbols(&uri).unwrap();
+    }
+
+    // ウォームアップ後の応答時間は操作種別ごとに遅延以上で記録される
+    let stats = client.export_timing_stats();
+    let samples = &stats.operation_times_ms[&LspOperationType::DocumentSymbol];
+    assert!(!samples.is_empty());
+    assert!(samples.iter().all(|&ms| ms >= 100));
+    assert!(!stats.init_times_ms.is_empty());
+
+    // 学習した統計を引き継ぐと、既定値ではなく応答時間から求めたタイムアウトになる
+    let mut checker = LspHealthChecker::new();
+    checker.seed_from_stats(&stats);
+    let average = checker
+        .average_response_time_for_operation(LspOperationType::DocumentSymbol)
+        .unwrap();
+    assert!(average >= Duration::from_millis(100));
+    assert_eq!(
+        checker.calculate_timeout_for_operation(LspOperationType::DocumentSymbol),
+        (average * 2).max(Duration::from_secs(3))
+    );
+}
+
+#[test]
+fn test_timeout_predictor_learns_from_delayed_responses() {
+    let temp_dir = TempDir::new().unwrap();
+    let scenario = FakeLspScenario::default()
+        .with_capabilities(serde_json::json!({ "documentSymbolProvider": true }))
+        .with_response(
+            CannedResponse::result("textDocument/documentSymbol", serde_json::json!([]))
+                .delayed(100),
+        );
+    let scenario_path = write_scenario(temp_dir.path(), &scenario);
+    let source = temp_dir.path().join("main.rs");
+    fs::write(&source, "fn main() {}").unwrap();
+
+    let adapter = FakeLspAdapter::new(FAKE_LSP, &scenario_path).with_language_id("rust");
+    let mut client = GenericLspClient::new_uninit(Box::new(adapter)).unwrap();
+    client
+        .initialize(temp_dir.path(), Some(Duration::from_secs(5)))
+        .unwrap();
+
+    let uri = format!("file://{}", source.canonicalize().unwrap().display());
+    client.get_document_symbols(&uri).unwrap();
+
+    // 1行12バイトのファイルに100ms以上かかったので、行・バイトあたりの時間に反映される
+    let stats = client.export_timing_stats();
+    assert!(stats.ms_per_line.unwrap() >= 100.0);
+    assert!(stats.ms_per_byte.unwrap() >= 100.0 / 12.0);
+
+    // 引き継いだ予測器は、既定の予測器より長いタイムアウトを予測する
+    let mut predictor = TimeoutPredictor::new();
+    predictor.seed_from_stats(&stats);
+    let fresh = TimeoutPredictor::new().predict_timeout(12, 10);
+    let learned = predictor.predict_timeout(12, 10);
+    assert!(learned >= Duration::from_millis(1000));
+    assert!(learned > fresh);
+}
+
+#[test]
+fn test_learned_timeout_detects_hanging_server() {
+    let temp_dir = TempDir::new().unwrap();
+    let scenario = FakeLspScenario::default()
+        .with_capabilities(serde_json::json!({ "documentSymbolProvider": true }))
+        .with_response(CannedResponse::hang("textDocument/documentSymbol"));
+    let scenario_path = write_scenario(temp_dir.path(), &scenario);
+    let source = temp_dir.path().join("main.rs");
+    fs::write(&source, "fn main() {}").unwrap();
+
+    // 前回の実行では速く応答していた
+    let mut stats = LspTimingStats::new("rust");
+    for _ in 0..5 {
+        stats.record_operation(LspOperationType::DocumentSymbol, Duration::from_millis(10));
+    }
+
+    let adapter = FakeLspAdapter::new(FAKE_LSP, &scenario_path).with_language_id("rust");
+    let mut client = GenericLspClient::new_uninit(Box::new(adapter)).unwrap();
+    client.seed_timing_stats(&stats);
+    client
+        .initialize(temp_dir.path(), Some(Duration::from_secs(5)))
+        .unwrap();
+
+    // 既定のタイムアウト（ウォームアップ中は12.5秒）ではなく、
+    // 学習した最小値（3秒、ウォームアップ中は3.75秒）で諦める
+    let uri = format!("file://{}", source.canonicalize().unwrap().display());
+    let start = Instant::now();
+    let error = client.get_document_symbols(&uri).unwrap_err();
+    let elapsed = start.elapsed();
+    assert!(error.to_string().contains("timed out"));
+    assert!(elapsed >= Duration::from_secs(3));
+    assert!(elapsed < Duration::from_secs(10));
+}
+
+#[test]
+fn test_auto_switching_client_falls_back_to_document_symbols() {
+    let temp_dir = TempDir::new().unwrap();
+    // workspace/symbolを持たないサーバー
+    let scenario_path = write_scenario(
+        temp_dir.path(),
+        &FakeLspScenario::default()
+            .with_capabilities(serde_json::json!({ "documentSymbolProvider": true }))
+            .with_response(CannedResponse::result(
+                "textDocument/documentSymbol",
+                serde_json::json!([{
+                    "name": "main",
+                    "kind": 12,
+                    "range": {
+                        "start": { "line": 0, "character": 0 },
+                        "end": { "line": 0, "character": 12 }
+                    },
+                    "selectionRange": {
+                        "start": { "line": 0, "character": 3 },
+                        "end": { "line": 0, "character": 7 }
+                    }
+                }]),
+            )),
+    );
+    let project = temp_dir.path().join("project");
+    fs::create_dir_all(project.join("src")).unwrap();
+    fs::create_dir_all(project.join("target")).unwrap();
+    fs::write(project.join("src/main.rs"), "fn main() {}").unwrap();
+    fs::write(project.join("src/lib.rs"), "fn main() {}").unwrap();
+    // 除外ディレクトリとソース以外のファイルは問い合わせない
+    fs::write(project.join("target/build.rs"), "fn main() {}").unwrap();
+    fs::write(project.join("README.md"), "# project").unwrap();
+
+    let adapter = FakeLspAdapter::new(FAKE_LSP, &scenario_path).with_language_id("rust");
+    let client = AutoSwitchingLspClient::new(Box::new(adapter)).unwrap();
+    assert!(!client.has_workspace_symbol());
+    assert!(client.has_document_symbol());
+
+    // ファイルごとのdocumentSymbolに切り替える
+    let symbols = client.get_all_symbols(project.to_str().unwrap()).unwrap();
+    let mut files: Vec<String> = symbols
+        .iter()
+        .map(|symbol| {
+            assert_eq!(symbol.name, "main");
+            symbol.location.uri.path().to_string()
+        })
+        .collect();
+    files.sort();
+    assert_eq!(
+        files,
+        vec![
+            project.join("src/lib.rs").display().to_string(),
+            project.join("src/main.rs").display().to_string(),
+        ]
+    );
+}
+
+#[test]
+fn test_auto_switching_client_prefers_workspace_symbols() {
+    let temp_dir = TempDir::new().unwrap();
+    let scenario_path = write_scenario(
+        temp_dir.path(),
+        &FakeLspScenario::default()
+            .with_capabilities(serde_json::json!({
+                "workspaceSymbolProvider": true,
+                "documentSymbolProvider": true,
+            }))
+            .with_response(CannedResponse::result(
+                "workspace/symbol",
+                serde_json::json!([{
+                    "name": "Server",
+                    "kind": 23,
+                    "location": {
+                        "uri": "file:///project/src/server.rs",
+                        "range": {
+                            "start": { "line": 0, "character": 0 },
+                            "end": { "line": 0, "character": 17 }
+                        }
+                    }
+                }]),
+            ))
+            // documentSymbolが呼ばれたらテストが失敗するように、エラーを返す
+            .with_response(CannedResponse::error(
+                "textDocument/documentSymbol",
+                -32603,
+                "unexpected documentSymbol",
+            )),
+    );
+
+    let adapter = FakeLspAdapter::new(FAKE_LSP, &scenario_path).with_language_id("rust");
+    let client = AutoSwitchingLspClient::new(Box::new(adapter)).unwrap();
+    assert!(client.has_workspace_symbol());
+
+    let symbols = client
+        .get_all_symbols(temp_dir.path().to_str().unwrap())
+        .unwrap();
+    assert_eq!(symbols.len(), 1);
+    assert_eq!(symbols[0].name, "Server");
+}
+
+#[test]
+fn test_auto_switching_client_without_symbol_support() {
+    let temp_dir = TempDir::new().unwrap();
+    let scenario_path = write_scenario(
+        temp_dir.path(),
+        &FakeLspScenario::default().with_capabilities(serde_json::json!({})),
+    );
+
+    let adapter = FakeLspAdapter::new(FAKE_LSP, &scenario_path).with_language_id("rust");
+    let client = AutoSwitchingLspClient::new(Box::new(adapter)).unwrap();
+    assert!(client
+        .get_all_symbols(temp_dir.path().to_str().unwrap())
+        .is_err());
+    assert!(client.get_file_symbols("file:///main.rs").is_err());
+}
@@ -29,6 +29,8 @@ fn main() {
             },
             documentation: Some("メイン関数".to_string()),
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         },
         Symbol {
             id: "Calculator".to_string(),
@@ -47,6 +49,8 @@ fn main() {
             },
             documentation: Some("計算機構造体".to_string()),
             detail: Some("pub struct Calculator".to_string()),
+            modifiers: Default::default(),
+            qualified_name: None,
         },
         Symbol {
             id: "add".to_string(),
@@ -65,6 +69,8 @@ fn main() {
             },
             documentation: Some("加算メソッド".to_string()),
             detail: Some("pub fn add(&self, a: i32, b: i32) -> i32".to_string()),
+            modifiers: Default::default(),
+            qualified_name: None,
         },
     ];
 
@@ -33,6 +33,8 @@ fn test_public_api_extraction() {
         range: default_range(),
         documentation: None,
         detail: Some("pub fn public_function()".to_string()),
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     // プライベート関数
@@ -44,6 +46,8 @@ fn test_public_api_extraction() {
         range: default_range(),
         documentation: None,
         detail: Some("fn private_function()".to_string()),
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     // エクスポートされたモジュール
@@ -55,6 +59,8 @@ fn test_public_api_extraction() {
         range: default_range(),
         documentation: None,
         detail: Some("pub mod my_module".to_string()),
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     let pub_node = graph.add_symbol(public_fn.clone());
@@ -74,6 +80,8 @@ fn test_public_api_extraction() {
             range: default_range(),
             documentation: None,
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         };
         let ref_node = graph.add_symbol(ref_sym);
         graph.add_edge(ref_node, pub_node, EdgeKind::Reference);
@@ -123,6 +131,8 @@ fn test_entry_point_detection() {
         range: default_range(),
         documentation: None,
         detail: Some("fn main()".to_string()),
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     // index関数
@@ -134,6 +144,8 @@ fn test_entry_point_detection() {
         range: default_range(),
         documentation: None,
         detail: Some("export function index()".to_string()),
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     let main_node = graph.add_symbol(main_fn);
@@ -164,6 +176,8 @@ fn test_cyclomatic_complexity_calculation() {
         range: default_range(),
         documentation: None,
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     let fn_node = graph.add_symbol(complex_fn);
@@ -178,6 +192,8 @@ fn test_cyclomatic_complexity_calculation() {
             range: default_range(),
             documentation: None,
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         };
 
         let branch_node = graph.add_symbol(branch);
@@ -193,6 +209,8 @@ fn test_cyclomatic_complexity_calculation() {
                 range: default_range(),
                 documentation: None,
                 detail: None,
+                modifiers: Default::default(),
+                qualified_name: None,
             };
             let nested_node = graph.add_symbol(nested);
             graph.add_edge(branch_node, nested_node, EdgeKind::Contains);
@@ -229,6 +247,8 @@ fn test_circular_dependency_detection() {
         range: default_range(),
         documentation: None,
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     let module_b = Symbol {
@@ -239,6 +259,8 @@ fn test_circular_dependency_detection() {
         range: default_range(),
         documentation: None,
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     let module_c = Symbol {
@@ -249,6 +271,8 @@ fn test_circular_dependency_detection() {
         range: default_range(),
         documentation: None,
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     let a_node = graph.add_symbol(module_a);
@@ -298,6 +322,8 @@ fn test_importance_score_ranking() {
             } else {
                 name.to_string()
             }),
+            modifiers: Default::default(),
+            qualified_name: None,
         };
 
         let node = graph.add_symbol(sym);
@@ -316,6 +342,8 @@ fn test_importance_score_ranking() {
                 range: default_range(),
                 documentation: None,
                 detail: None,
+                modifiers: Default::default(),
+                qualified_name: None,
             };
             let ref_node = graph.add_symbol(ref_sym);
             graph.add_edge(ref_node, node, EdgeKind::Reference);
@@ -348,6 +376,8 @@ fn test_visibility_determination() {
         range: default_range(),
         documentation: None,
         detail: Some("pub fn func()".to_string()),
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     let _rust_private = Symbol {
@@ -358,6 +388,8 @@ fn test_visibility_determination() {
         range: default_range(),
         documentation: None,
         detail: Some("fn func()".to_string()),
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     // Python visibility tests
@@ -369,6 +401,8 @@ fn test_visibility_determination() {
         range: default_range(),
         documentation: None,
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     let python_private = Symbol {
@@ -379,6 +413,8 @@ fn test_visibility_determination() {
         range: default_range(),
         documentation: None,
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     // Go visibility tests
@@ -390,6 +426,8 @@ fn test_visibility_determination() {
         range: default_range(),
         documentation: None,
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     let _go_private = Symbol {
@@ -400,6 +438,8 @@ fn test_visibility_determination() {
         range: default_range(),
         documentation: None,
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     // Visibility checks would be done through PublicApiAnalyzer methods
@@ -1,4 +1,4 @@
-use lsif_core::{CodeGraph, EdgeKind, Position, Range, Symbol, SymbolKind};
+use lsif_core::{CodeGraph, EdgeKind, GraphBackend, Position, Range, Symbol, SymbolKind};
 use std::path::PathBuf;
 
 #[test]
@@ -9,38 +9,41 @@ fn test_call_hierarchy_with_sample_code() {
     // Import the call hierarchy analyzer
     use lsif_core::call_hierarchy::{format_hierarchy, CallHierarchyAnalyzer};
 
-    let analyzer = CallHierarchyAnalyzer::new(&graph);
+    for backend in GraphBackend::ALL {
+        let graph = backend.load(graph.clone());
+        let analyzer = CallHierarchyAnalyzer::new(&*graph);
 
-    // Test outgoing calls from main
-    let main_hierarchy = analyzer.get_outgoing_calls("main", 3).unwrap();
-    let formatted = format_hierarchy(&main_hierarchy, "", true);
+        // Test outgoing calls from main
+        let main_hierarchy = analyzer.get_outgoing_calls("main", 3).unwrap();
+        let formatted = format_hierarchy(&main_hierarchy, "", true);
 
-    println!("=== Outgoing calls from main ===");
-    println!("{formatted}");
+        println!("=== Outgoing calls from main ===");
+        println!("{formatted}");
 
-    assert!(formatted.contains("main"));
-    assert!(formatted.contains("calculate"));
-    assert!(formatted.contains("add"));
-    assert!(formatted.contains("multiply"));
+        assert!(formatted.contains("main"));
+        assert!(formatted.contains("calculate"));
+        assert!(formatted.contains("add"));
+        assert!(formatted.contains("multiply"));
 
-    // Test incoming calls to add
-    let add_hierarchy = analyzer.get_incoming_calls("add", 2).unwrap();
-    let formatted_incoming = format_hierarchy(&add_hierarchy, "", true);
+        // Test incoming calls to add
+        let add_hierarchy = analyzer.get_incoming_calls("add", 2).unwrap();
+        let formatted_incoming = format_hierarchy(&add_hierarchy, "", true);
 
-    println!("\n=== Incoming calls to add ===");
-    println!("{formatted_incoming}");
+        println!("\n=== Incoming calls to add ===");
+        println!("{formatted_incoming}");
 
-    assert!(formatted_incoming.contains("add"));
-    assert!(formatted_incoming.contains("calculate"));
+        assert!(formatted_incoming.contains("add"));
+        assert!(formatted_incoming.contains("calculate"));
 
-    // Test call paths
-    let paths = analyzer.find_call_paths("main", "add", 5);
-    println!("\n=== Call paths from main to add ===");
-    for path in &paths {
-        println!("  Path: {}", path.join(" -> "));
-    }
+        // Test call paths
+        let paths = analyzer.find_call_paths("main", "add", 5);
+        println!("\n=== Call paths from main to add ===");
+        for path in &paths {
+            println!("  Path: {}", path.join(" -> "));
+        }
 
-    assert!(!paths.is_empty());
+        assert!(!paths.is_empty());
+    }
 }
 
 #[test]
@@ -111,6 +114,8 @@ fn create_sample_graph() -> CodeGraph {
             },
             documentation: None,
         detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         };
         let idx = graph.add_symbol(symbol);
         indices.insert(name.to_string(), idx);
@@ -27,6 +27,8 @@ fn create_complex_graph() -> CodeGraph {
         },
         documentation: Some("type MyString = String".to_string()),
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     let string_type = Symbol {
@@ -46,6 +48,8 @@ fn create_complex_graph() -> CodeGraph {
         },
         documentation: Some("Standard String type".to_string()),
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     let user_name = Symbol {
@@ -65,6 +69,8 @@ fn create_complex_graph() -> CodeGraph {
         },
         documentation: Some("type UserName = MyString".to_string()),
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     let admin_name = Symbol {
@@ -84,6 +90,8 @@ fn create_complex_graph() -> CodeGraph {
         },
         documentation: Some("type AdminName = UserName".to_string()),
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     // Add symbols to graph
@@ -115,6 +123,8 @@ fn create_complex_graph() -> CodeGraph {
         },
         documentation: Some("let admin_user: AdminName".to_string()),
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     let admin_var_idx = graph.add_symbol(admin_var);
@@ -138,6 +148,8 @@ fn create_complex_graph() -> CodeGraph {
         },
         documentation: Some("fn get_admin() -> AdminName".to_string()),
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     let get_admin_idx = graph.add_symbol(get_admin);
@@ -172,6 +184,8 @@ fn create_cyclic_graph() -> CodeGraph {
         },
         documentation: Some("Recursive node structure".to_string()),
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     let node_ref = Symbol {
@@ -191,6 +205,8 @@ fn create_cyclic_graph() -> CodeGraph {
         },
         documentation: Some("type NodeRef = &Node".to_string()),
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     let node_idx = graph.add_symbol(node_struct.clone());
@@ -218,6 +234,8 @@ fn create_cyclic_graph() -> CodeGraph {
         },
         documentation: Some("List node using Node".to_string()),
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     let list_idx = graph.add_symbol(list_node);
@@ -305,6 +323,8 @@ fn test_multiple_definition_paths() {
         },
         documentation: None,
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     let validated = Symbol {
@@ -324,6 +344,8 @@ fn test_multiple_definition_paths() {
         },
         documentation: None,
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     let raw = Symbol {
@@ -343,6 +365,8 @@ fn test_multiple_definition_paths() {
         },
         documentation: None,
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     let sanitized = Symbol {
@@ -362,6 +386,8 @@ fn test_multiple_definition_paths() {
         },
         documentation: None,
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     let string = Symbol {
@@ -381,6 +407,8 @@ fn test_multiple_definition_paths() {
         },
         documentation: None,
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     let input_idx = graph.add_symbol(user_input);
@@ -448,6 +476,8 @@ fn test_shortest_definition_path() {
             },
             documentation: None,
         detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         })
         .collect();
 
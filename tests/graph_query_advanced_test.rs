@@ -1,4 +1,6 @@
-use lsif_core::{CodeGraph, EdgeKind, Position, QueryPattern, Range, Symbol, SymbolKind};
+use lsif_core::{
+    CodeGraph, EdgeKind, GraphBackend, Position, QueryPattern, Range, Symbol, SymbolKind,
+};
 use lsif_core::graph_query::{format_query_results, QueryEngine, QueryParser};
 use std::collections::HashSet;
 
@@ -24,6 +26,8 @@ fn create_complex_graph() -> CodeGraph {
         },
         documentation: Some("Serialization trait".to_string()),
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     // Base model
@@ -44,6 +48,8 @@ fn create_complex_graph() -> CodeGraph {
         },
         documentation: Some("Base model class".to_string()),
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     // User model extending base
@@ -64,6 +70,8 @@ fn create_complex_graph() -> CodeGraph {
         },
         documentation: Some("User model".to_string()),
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     // Admin model extending user
@@ -84,6 +92,8 @@ fn create_complex_graph() -> CodeGraph {
         },
         documentation: Some("Admin model".to_string()),
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     // Service using the models
@@ -104,6 +114,8 @@ fn create_complex_graph() -> CodeGraph {
         },
         documentation: Some("User service".to_string()),
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     // Functions
@@ -124,6 +136,8 @@ fn create_complex_graph() -> CodeGraph {
         },
         documentation: Some("Create user function".to_string()),
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     let validate_user = Symbol {
@@ -143,6 +157,8 @@ fn create_complex_graph() -> CodeGraph {
         },
         documentation: Some("Validate user function".to_string()),
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     let save_to_db = Symbol {
@@ -162,6 +178,8 @@ fn create_complex_graph() -> CodeGraph {
         },
         documentation: Some("Save to database".to_string()),
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     // Variables
@@ -182,6 +200,8 @@ fn create_complex_graph() -> CodeGraph {
         },
         documentation: Some("Current user variable".to_string()),
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     let admin_user = Symbol {
@@ -201,6 +221,8 @@ fn create_complex_graph() -> CodeGraph {
         },
         documentation: Some("Admin user variable".to_string()),
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     // Methods
@@ -221,6 +243,8 @@ fn create_complex_graph() -> CodeGraph {
         },
         documentation: Some("Save method".to_string()),
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     let validate_method = Symbol {
@@ -240,6 +264,8 @@ fn create_complex_graph() -> CodeGraph {
         },
         documentation: Some("Validate method".to_string()),
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     // Add all symbols
@@ -288,51 +314,58 @@ fn create_complex_graph() -> CodeGraph {
 #[test]
 fn test_complex_inheritance_chain() {
     let graph = create_complex_graph();
-    let engine = QueryEngine::new(&graph);
-
-    // Find all classes in inheritance chain from AdminModel to Serializable
-    let pattern =
-        QueryParser::parse("(admin:Class)-[:Definition|Implementation*1..3]->(trait:Interface)")
-            .unwrap();
-    let results = engine.execute(&pattern);
-
-    // Should find path: AdminModel -> UserModel -> BaseModel -> Serializable
-    assert!(!results.matches.is_empty());
-
-    let has_admin_to_trait = results.matches.iter().any(|m| {
-        m.bindings
-            .iter()
-            .any(|(v, s)| v == "admin" && s.name == "AdminModel")
-            && m.bindings
+    for backend in GraphBackend::ALL {
+        let graph = backend.load(graph.clone());
+        let engine = QueryEngine::new(&*graph);
+
+        // Find all classes in inheritance chain from AdminModel to Serializable
+        let pattern = QueryParser::parse(
+            "(admin:Class)-[:Definition|Implementation*1..3]->(trait:Interface)",
+        )
+        .unwrap();
+        let results = engine.execute(&pattern);
+
+        // Should find path: AdminModel -> UserModel -> BaseModel -> Serializable
+        assert!(!results.matches.is_empty());
+
+        let has_admin_to_trait = results.matches.iter().any(|m| {
+            m.bindings
                 .iter()
-                .any(|(v, s)| v == "trait" && s.name == "Serializable")
-    });
+                .any(|(v, s)| v == "admin" && s.name == "AdminModel")
+                && m.bindings
+                    .iter()
+                    .any(|(v, s)| v == "trait" && s.name == "Serializable")
+        });
 
-    assert!(has_admin_to_trait);
+        assert!(has_admin_to_trait);
+    }
 }
 
 #[test]
 fn test_method_to_function_references() {
     let graph = create_complex_graph();
-    let engine = QueryEngine::new(&graph);
+    for backend in GraphBackend::ALL {
+        let graph = backend.load(graph.clone());
+        let engine = QueryEngine::new(&*graph);
 
-    // Find methods that reference functions
-    let pattern = QueryParser::parse("(method:Method)-[:Reference]->(fn:Function)").unwrap();
-    let results = engine.execute(&pattern);
+        // Find methods that reference functions
+        let pattern = QueryParser::parse("(method:Method)-[:Reference]->(fn:Function)").unwrap();
+        let results = engine.execute(&pattern);
 
-    assert_eq!(results.matches.len(), 2); // save -> saveToDb, validate -> validateUser
+        assert_eq!(results.matches.len(), 2); // save -> saveToDb, validate -> validateUser
 
-    // Verify specific relationships
-    let method_names: HashSet<String> = results
-        .matches
-        .iter()
-        .flat_map(|m| &m.bindings)
-        .filter(|(v, _)| v == "method")
-        .map(|(_, s)| s.name.clone())
-        .collect();
+        // Verify specific relationships
+        let method_names: HashSet<String> = results
+            .matches
+            .iter()
+            .flat_map(|m| &m.bindings)
+            .filter(|(v, _)| v == "method")
+            .map(|(_, s)| s.name.clone())
+            .collect();
 
-    assert!(method_names.contains("save"));
-    assert!(method_names.contains("validate"));
+        assert!(method_names.contains("save"));
+        assert!(method_names.contains("validate"));
+    }
 }
 
 #[test]
@@ -357,6 +390,8 @@ fn test_circular_reference_detection() {
         },
         documentation: None,
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     let b = Symbol {
@@ -376,6 +411,8 @@ fn test_circular_reference_detection() {
         },
         documentation: None,
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     let c = Symbol {
@@ -395,6 +432,8 @@ fn test_circular_reference_detection() {
         },
         documentation: None,
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     let a_idx = graph.add_symbol(a);
@@ -406,104 +445,125 @@ fn test_circular_reference_detection() {
     graph.add_edge(b_idx, c_idx, EdgeKind::Reference);
     graph.add_edge(c_idx, a_idx, EdgeKind::Reference);
 
-    let engine = QueryEngine::new(&graph);
+    for backend in GraphBackend::ALL {
+        let graph = backend.load(graph.clone());
+        let engine = QueryEngine::new(&*graph);
 
-    // Query with unlimited depth should handle cycles
-    let pattern = QueryParser::parse("(start:Class)-[:Reference*]->(end:Class)").unwrap();
-    let results = engine.execute(&pattern);
+        // Query with unlimited depth should handle cycles
+        let pattern = QueryParser::parse("(start:Class)-[:Reference*]->(end:Class)").unwrap();
+        let results = engine.execute(&pattern);
 
-    // Should find results without infinite loop
-    assert!(!results.matches.is_empty());
-    assert!(results.matches.len() <= 9); // 3 nodes * 3 possible targets max
+        // Should find results without infinite loop
+        assert!(!results.matches.is_empty());
+        assert!(results.matches.len() <= 9); // 3 nodes * 3 possible targets max
+    }
 }
 
 #[test]
 fn test_backward_traversal() {
     let graph = create_complex_graph();
-    let _engine = QueryEngine::new(&graph);
+    for backend in GraphBackend::ALL {
+        let graph = backend.load(graph.clone());
+        let _engine = QueryEngine::new(&*graph);
 
-    // For now, skip backward traversal test as it's not fully implemented
-    // TODO: Implement proper backward arrow parsing
+        // For now, skip backward traversal test as it's not fully implemented
+        // TODO: Implement proper backward arrow parsing
 
-    // Find what references UserModel (backward traversal)
-    // let pattern = QueryParser::parse("(source)<-[:Reference]-(user:Class)").unwrap();
-    // let results = engine.execute(&pattern);
+        // Find what references UserModel (backward traversal)
+        // let pattern = QueryParser::parse("(source)<-[:Reference]-(user:Class)").unwrap();
+        // let results = engine.execute(&pattern);
 
-    // Should find things that reference classes
-    // assert!(!results.matches.is_empty());
+        // Should find things that reference classes
+        // assert!(!results.matches.is_empty());
+    }
 }
 
 #[test]
 fn test_bidirectional_traversal() {
     let graph = create_complex_graph();
-    let engine = QueryEngine::new(&graph);
+    for backend in GraphBackend::ALL {
+        let graph = backend.load(graph.clone());
+        let engine = QueryEngine::new(&*graph);
 
-    // Find nodes connected in any direction
-    let pattern = QueryParser::parse("(node:Class)--(:Class)").unwrap();
-    let results = engine.execute(&pattern);
+        // Find nodes connected in any direction
+        let pattern = QueryParser::parse("(node:Class)--(:Class)").unwrap();
+        let results = engine.execute(&pattern);
 
-    // Should find bidirectional connections
-    assert!(!results.matches.is_empty());
+        // Should find bidirectional connections
+        assert!(!results.matches.is_empty());
+    }
 }
 
 #[test]
 fn test_empty_pattern() {
     let graph = create_complex_graph();
-    let engine = QueryEngine::new(&graph);
-
-    // Empty pattern should return empty results
-    let pattern = QueryPattern {
-        nodes: vec![],
-        relationships: vec![],
-    };
-    let results = engine.execute(&pattern);
+    for backend in GraphBackend::ALL {
+        let graph = backend.load(graph.clone());
+        let engine = QueryEngine::new(&*graph);
+
+        // Empty pattern should return empty results
+        let pattern = QueryPattern {
+            nodes: vec![],
+            relationships: vec![],
+        };
+        let results = engine.execute(&pattern);
 
-    assert_eq!(results.matches.len(), 0);
+        assert_eq!(results.matches.len(), 0);
+    }
 }
 
 #[test]
 fn test_node_without_label() {
     let graph = create_complex_graph();
-    let engine = QueryEngine::new(&graph);
+    for backend in GraphBackend::ALL {
+        let graph = backend.load(graph.clone());
+        let engine = QueryEngine::new(&*graph);
 
-    // Node without label should match any symbol
-    let pattern = QueryParser::parse("(any)-[:Reference]->(cls:Class)").unwrap();
-    let results = engine.execute(&pattern);
+        // Node without label should match any symbol
+        let pattern = QueryParser::parse("(any)-[:Reference]->(cls:Class)").unwrap();
+        let results = engine.execute(&pattern);
 
-    // Should find all references to classes
-    assert!(!results.matches.is_empty());
+        // Should find all references to classes
+        assert!(!results.matches.is_empty());
+    }
 }
 
 #[test]
 fn test_multiple_edge_types() {
     let graph = create_complex_graph();
-    let engine = QueryEngine::new(&graph);
+    for backend in GraphBackend::ALL {
+        let graph = backend.load(graph.clone());
+        let engine = QueryEngine::new(&*graph);
 
-    // Pattern with Contains edge
-    let pattern = QueryParser::parse("(method:Method)-[:Contains]->(class:Class)").unwrap();
-    let results = engine.execute(&pattern);
+        // Pattern with Contains edge
+        let pattern = QueryParser::parse("(method:Method)-[:Contains]->(class:Class)").unwrap();
+        let results = engine.execute(&pattern);
 
-    // Methods are contained in classes
-    assert_eq!(results.matches.len(), 2);
+        // Methods are contained in classes
+        assert_eq!(results.matches.len(), 2);
+    }
 }
 
 #[test]
 fn test_exact_depth_matching() {
     let graph = create_complex_graph();
-    let engine = QueryEngine::new(&graph);
-
-    // Find exactly 2-hop paths
-    let pattern = QueryParser::parse("(fn:Function)-[:Reference*2]->(target)").unwrap();
-    let results = engine.execute(&pattern);
-
-    // createUser -> validateUser -> saveToDb (exactly 2 hops)
-    assert!(!results.matches.is_empty());
-
-    // Verify path length
-    for match_result in &results.matches {
-        for path in &match_result.paths {
-            // Path should have 3 nodes for 2 hops
-            assert_eq!(path.len(), 3);
+    for backend in GraphBackend::ALL {
+        let graph = backend.load(graph.clone());
+        let engine = QueryEngine::new(&*graph);
+
+        // Find exactly 2-hop paths
+        let pattern = QueryParser::parse("(fn:Function)-[:Reference*2]->(target)").unwrap();
+        let results = engine.execute(&pattern);
+
+        // createUser -> validateUser -> saveToDb (exactly 2 hops)
+        assert!(!results.matches.is_empty());
+
+        // Verify path length
+        for match_result in &results.matches {
+            for path in &match_result.paths {
+                // Path should have 3 nodes for 2 hops
+                assert_eq!(path.len(), 3);
+            }
         }
     }
 }
@@ -511,19 +571,22 @@ fn test_exact_depth_matching() {
 #[test]
 fn test_range_depth_boundary() {
     let graph = create_complex_graph();
-    let engine = QueryEngine::new(&graph);
-
-    // Find paths with depth 1-2
-    let pattern = QueryParser::parse("(start:Class)-[:Definition*1..2]->(end)").unwrap();
-    let results = engine.execute(&pattern);
-
-    // Should find both direct and 2-hop paths
-    assert!(!results.matches.is_empty());
-
-    // Check that no path exceeds 2 hops
-    for match_result in &results.matches {
-        for path in &match_result.paths {
-            assert!(path.len() >= 2 && path.len() <= 3);
+    for backend in GraphBackend::ALL {
+        let graph = backend.load(graph.clone());
+        let engine = QueryEngine::new(&*graph);
+
+        // Find paths with depth 1-2
+        let pattern = QueryParser::parse("(start:Class)-[:Definition*1..2]->(end)").unwrap();
+        let results = engine.execute(&pattern);
+
+        // Should find both direct and 2-hop paths
+        assert!(!results.matches.is_empty());
+
+        // Check that no path exceeds 2 hops
+        for match_result in &results.matches {
+            for path in &match_result.paths {
+                assert!(path.len() >= 2 && path.len() <= 3);
+            }
         }
     }
 }
@@ -567,6 +630,8 @@ fn test_large_graph_performance() {
             },
             documentation: None,
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         };
         symbols.push(graph.add_symbol(symbol));
     }
@@ -576,70 +641,82 @@ fn test_large_graph_performance() {
         graph.add_edge(symbols[i], symbols[i + 1], EdgeKind::Reference);
     }
 
-    let engine = QueryEngine::new(&graph);
+    for backend in GraphBackend::ALL {
+        let graph = backend.load(graph.clone());
+        let engine = QueryEngine::new(&*graph);
 
-    // Query with limited depth should complete quickly
-    let pattern = QueryParser::parse("(start:Class)-[:Reference*1..5]->(end:Class)").unwrap();
-    let start = std::time::Instant::now();
-    let results = engine.execute(&pattern);
-    let duration = start.elapsed();
+        // Query with limited depth should complete quickly
+        let pattern = QueryParser::parse("(start:Class)-[:Reference*1..5]->(end:Class)").unwrap();
+        let start = std::time::Instant::now();
+        let results = engine.execute(&pattern);
+        let duration = start.elapsed();
 
-    // Should complete in reasonable time (< 1 second)
-    assert!(duration.as_secs() < 1);
-    assert!(!results.matches.is_empty());
+        // Should complete in reasonable time (< 1 second)
+        assert!(duration.as_secs() < 1);
+        assert!(!results.matches.is_empty());
+    }
 }
 
 #[test]
 fn test_no_matching_nodes() {
     let graph = create_complex_graph();
-    let engine = QueryEngine::new(&graph);
+    for backend in GraphBackend::ALL {
+        let graph = backend.load(graph.clone());
+        let engine = QueryEngine::new(&*graph);
 
-    // Query for non-existent symbol kind
-    let pattern = QueryParser::parse("(param:Parameter)-[:Reference]->(any)").unwrap();
-    let results = engine.execute(&pattern);
+        // Query for non-existent symbol kind
+        let pattern = QueryParser::parse("(param:Parameter)-[:Reference]->(any)").unwrap();
+        let results = engine.execute(&pattern);
 
-    // Should return empty results
-    assert_eq!(results.matches.len(), 0);
+        // Should return empty results
+        assert_eq!(results.matches.len(), 0);
+    }
 }
 
 #[test]
 fn test_formatted_output() {
     let graph = create_complex_graph();
-    let engine = QueryEngine::new(&graph);
+    for backend in GraphBackend::ALL {
+        let graph = backend.load(graph.clone());
+        let engine = QueryEngine::new(&*graph);
 
-    let pattern = QueryParser::parse("(var:Variable)-[:Reference]->(cls:Class)").unwrap();
-    let results = engine.execute(&pattern);
+        let pattern = QueryParser::parse("(var:Variable)-[:Reference]->(cls:Class)").unwrap();
+        let results = engine.execute(&pattern);
 
-    let formatted = format_query_results(&results);
+        let formatted = format_query_results(&results);
 
-    // Check formatted output contains expected elements
-    assert!(formatted.contains("Match"));
-    assert!(formatted.contains("Bindings:"));
-    assert!(formatted.contains("currentUser") || formatted.contains("adminUser"));
+        // Check formatted output contains expected elements
+        assert!(formatted.contains("Match"));
+        assert!(formatted.contains("Bindings:"));
+        assert!(formatted.contains("currentUser") || formatted.contains("adminUser"));
 
-    // Should show variable bindings
-    assert!(formatted.contains("var ="));
-    assert!(formatted.contains("cls ="));
+        // Should show variable bindings
+        assert!(formatted.contains("var ="));
+        assert!(formatted.contains("cls ="));
+    }
 }
 
 #[test]
 fn test_complex_multi_hop_query() {
     let graph = create_complex_graph();
-    let engine = QueryEngine::new(&graph);
+    for backend in GraphBackend::ALL {
+        let graph = backend.load(graph.clone());
+        let engine = QueryEngine::new(&*graph);
 
-    // Find functions that indirectly lead to trait implementations (any edge type)
-    let pattern = QueryParser::parse("(fn:Function)-->(trait:Interface)").unwrap();
-    let _results = engine.execute(&pattern);
+        // Find functions that indirectly lead to trait implementations (any edge type)
+        let pattern = QueryParser::parse("(fn:Function)-->(trait:Interface)").unwrap();
+        let _results = engine.execute(&pattern);
 
-    // createUser -> UserModel -> BaseModel -> Serializable
-    // Note: This test may not find results with the current limited implementation
-    // assert!(!results.matches.is_empty());
+        // createUser -> UserModel -> BaseModel -> Serializable
+        // Note: This test may not find results with the current limited implementation
+        // assert!(!results.matches.is_empty());
 
-    // For now, skip this assertion as multi-hop with mixed edge types is not fully supported
-    // let has_path = results.matches.iter().any(|m| {
-    //     m.bindings.iter().any(|(v, s)| v == "fn" && s.name == "createUser") &&
-    //     m.bindings.iter().any(|(v, s)| v == "trait" && s.name == "Serializable")
-    // });
+        // For now, skip this assertion as multi-hop with mixed edge types is not fully supported
+        // let has_path = results.matches.iter().any(|m| {
+        //     m.bindings.iter().any(|(v, s)| v == "fn" && s.name == "createUser") &&
+        //     m.bindings.iter().any(|(v, s)| v == "trait" && s.name == "Serializable")
+        // });
 
-    // assert!(has_path);
+        // assert!(has_path);
+    }
 }
@@ -1,4 +1,4 @@
-use lsif_core::{CodeGraph, EdgeKind, Position, Range, Symbol, SymbolKind};
+use lsif_core::{CodeGraph, EdgeKind, GraphBackend, Position, Range, Symbol, SymbolKind};
 use lsif_core::graph_query::{format_query_results, QueryEngine, QueryParser};
 
 fn create_test_graph() -> CodeGraph {
@@ -22,6 +22,8 @@ fn create_test_graph() -> CodeGraph {
         },
         documentation: Some("Logger interface".to_string()),
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     let console_logger = Symbol {
@@ -41,6 +43,8 @@ fn create_test_graph() -> CodeGraph {
         },
         documentation: Some("Console logger implementation".to_string()),
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     let file_logger = Symbol {
@@ -60,6 +64,8 @@ fn create_test_graph() -> CodeGraph {
         },
         documentation: Some("File logger implementation".to_string()),
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     let log_function = Symbol {
@@ -79,6 +85,8 @@ fn create_test_graph() -> CodeGraph {
         },
         documentation: Some("Log function".to_string()),
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     let logger_var = Symbol {
@@ -98,6 +106,8 @@ fn create_test_graph() -> CodeGraph {
         },
         documentation: Some("Logger instance".to_string()),
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     let config = Symbol {
@@ -117,6 +127,8 @@ fn create_test_graph() -> CodeGraph {
         },
         documentation: None,
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     // Add symbols to graph
@@ -195,58 +207,65 @@ fn test_parse_depth_patterns() {
 #[test]
 fn test_query_find_implementations() {
     let graph = create_test_graph();
-    let engine = QueryEngine::new(&graph);
+    for backend in GraphBackend::ALL {
+        let graph = backend.load(graph.clone());
+        let engine = QueryEngine::new(&*graph);
 
-    // Find all classes that implement the interface
-    let pattern = QueryParser::parse("(impl:Class)-[:Definition]->(interface:Interface)").unwrap();
-    let results = engine.execute(&pattern);
+        // Find all classes that implement the interface
+        let pattern =
+            QueryParser::parse("(impl:Class)-[:Definition]->(interface:Interface)").unwrap();
+        let results = engine.execute(&pattern);
 
-    assert_eq!(results.matches.len(), 2);
+        assert_eq!(results.matches.len(), 2);
 
-    // Check that we found both implementations
-    let impl_names: Vec<String> = results
-        .matches
-        .iter()
-        .flat_map(|m| &m.bindings)
-        .filter(|(var, _)| var == "impl")
-        .map(|(_, sym)| sym.name.clone())
-        .collect();
+        // Check that we found both implementations
+        let impl_names: Vec<String> = results
+            .matches
+            .iter()
+            .flat_map(|m| &m.bindings)
+            .filter(|(var, _)| var == "impl")
+            .map(|(_, sym)| sym.name.clone())
+            .collect();
 
-    assert!(impl_names.contains(&"ConsoleLogger".to_string()));
-    assert!(impl_names.contains(&"FileLogger".to_string()));
+        assert!(impl_names.contains(&"ConsoleLogger".to_string()));
+        assert!(impl_names.contains(&"FileLogger".to_string()));
+    }
 }
 
 #[test]
 fn test_query_find_references() {
     let graph = create_test_graph();
-    let engine = QueryEngine::new(&graph);
+    for backend in GraphBackend::ALL {
+        let graph = backend.load(graph.clone());
+        let engine = QueryEngine::new(&*graph);
 
-    // Find all variables that reference classes
-    let pattern = QueryParser::parse("(var:Variable)-[:Reference]->(cls:Class)").unwrap();
-    let results = engine.execute(&pattern);
+        // Find all variables that reference classes
+        let pattern = QueryParser::parse("(var:Variable)-[:Reference]->(cls:Class)").unwrap();
+        let results = engine.execute(&pattern);
 
-    assert_eq!(results.matches.len(), 2); // logger -> ConsoleLogger, config -> FileLogger
+        assert_eq!(results.matches.len(), 2); // logger -> ConsoleLogger, config -> FileLogger
 
-    for match_result in &results.matches {
-        let var_binding = match_result
-            .bindings
-            .iter()
-            .find(|(v, _)| v == "var")
-            .map(|(_, s)| &s.name);
-        let cls_binding = match_result
-            .bindings
-            .iter()
-            .find(|(v, _)| v == "cls")
-            .map(|(_, s)| &s.name);
-
-        match (var_binding, cls_binding) {
-            (Some(var), Some(cls)) => {
-                assert!(
-                    (var == "logger" && cls == "ConsoleLogger")
-                        || (var == "config" && cls == "FileLogger")
-                );
+        for match_result in &results.matches {
+            let var_binding = match_result
+                .bindings
+                .iter()
+                .find(|(v, _)| v == "var")
+                .map(|(_, s)| &s.name);
+            let cls_binding = match_result
+                .bindings
+                .iter()
+                .find(|(v, _)| v == "cls")
+                .map(|(_, s)| &s.name);
+
+            match (var_binding, cls_binding) {
+                (Some(var), Some(cls)) => {
+                    assert!(
+                        (var == "logger" && cls == "ConsoleLogger")
+                            || (var == "config" && cls == "FileLogger")
+                    );
+                }
+                _ => panic!("Missing bindings"),
             }
-            _ => panic!("Missing bindings"),
         }
     }
 }
@@ -254,66 +273,75 @@ fn test_query_find_references() {
 #[test]
 fn test_query_transitive_references() {
     let graph = create_test_graph();
-    let engine = QueryEngine::new(&graph);
-
-    // Find functions that indirectly reference classes through variables
-    let pattern = QueryParser::parse("(fn:Function)-[:Reference*1..2]->(cls:Class)").unwrap();
-    let results = engine.execute(&pattern);
-
-    // Should find: log -> logger -> ConsoleLogger
-    assert!(!results.matches.is_empty());
-
-    let has_log_to_console = results.matches.iter().any(|m| {
-        let fn_name = m
-            .bindings
-            .iter()
-            .find(|(v, _)| v == "fn")
-            .map(|(_, s)| &s.name);
-        let cls_name = m
-            .bindings
-            .iter()
-            .find(|(v, _)| v == "cls")
-            .map(|(_, s)| &s.name);
-
-        fn_name == Some(&"log".to_string()) && cls_name == Some(&"ConsoleLogger".to_string())
-    });
-
-    assert!(has_log_to_console);
+    for backend in GraphBackend::ALL {
+        let graph = backend.load(graph.clone());
+        let engine = QueryEngine::new(&*graph);
+
+        // Find functions that indirectly reference classes through variables
+        let pattern = QueryParser::parse("(fn:Function)-[:Reference*1..2]->(cls:Class)").unwrap();
+        let results = engine.execute(&pattern);
+
+        // Should find: log -> logger -> ConsoleLogger
+        assert!(!results.matches.is_empty());
+
+        let has_log_to_console = results.matches.iter().any(|m| {
+            let fn_name = m
+                .bindings
+                .iter()
+                .find(|(v, _)| v == "fn")
+                .map(|(_, s)| &s.name);
+            let cls_name = m
+                .bindings
+                .iter()
+                .find(|(v, _)| v == "cls")
+                .map(|(_, s)| &s.name);
+
+            fn_name == Some(&"log".to_string()) && cls_name == Some(&"ConsoleLogger".to_string())
+        });
+
+        assert!(has_log_to_console);
+    }
 }
 
 #[test]
 fn test_query_any_relationship() {
     let graph = create_test_graph();
-    let engine = QueryEngine::new(&graph);
+    for backend in GraphBackend::ALL {
+        let graph = backend.load(graph.clone());
+        let engine = QueryEngine::new(&*graph);
 
-    // Find all nodes connected to interfaces (any relationship type)
-    let pattern = QueryParser::parse("(node)-[]->(interface:Interface)").unwrap();
-    let results = engine.execute(&pattern);
+        // Find all nodes connected to interfaces (any relationship type)
+        let pattern = QueryParser::parse("(node)-[]->(interface:Interface)").unwrap();
+        let results = engine.execute(&pattern);
 
-    // Should find ConsoleLogger and FileLogger
-    assert_eq!(results.matches.len(), 2);
+        // Should find ConsoleLogger and FileLogger
+        assert_eq!(results.matches.len(), 2);
+    }
 }
 
 #[test]
 fn test_query_with_paths() {
     let graph = create_test_graph();
-    let engine = QueryEngine::new(&graph);
-
-    // Find paths from functions to interfaces
-    let pattern =
-        QueryParser::parse("(fn:Function)-[:Reference*1..3]->(interface:Interface)").unwrap();
-    let results = engine.execute(&pattern);
-
-    // Check that paths are populated
-    if !results.matches.is_empty() {
-        for match_result in &results.matches {
-            assert!(!match_result.paths.is_empty());
-
-            // Each path should start with a function and end with an interface
-            for path in &match_result.paths {
-                if let (Some(first), Some(last)) = (path.first(), path.last()) {
-                    assert!(matches!(first.kind, SymbolKind::Function));
-                    assert!(matches!(last.kind, SymbolKind::Interface));
+    for backend in GraphBackend::ALL {
+        let graph = backend.load(graph.clone());
+        let engine = QueryEngine::new(&*graph);
+
+        // Find paths from functions to interfaces
+        let pattern =
+            QueryParser::parse("(fn:Function)-[:Reference*1..3]->(interface:Interface)").unwrap();
+        let results = engine.execute(&pattern);
+
+        // Check that paths are populated
+        if !results.matches.is_empty() {
+            for match_result in &results.matches {
+                assert!(!match_result.paths.is_empty());
+
+                // Each path should start with a function and end with an interface
+                for path in &match_result.paths {
+                    if let (Some(first), Some(last)) = (path.first(), path.last()) {
+                        assert!(matches!(first.kind, SymbolKind::Function));
+                        assert!(matches!(last.kind, SymbolKind::Interface));
+                    }
                 }
             }
         }
@@ -323,17 +351,21 @@ fn test_query_with_paths() {
 #[test]
 fn test_format_query_results() {
     let graph = create_test_graph();
-    let engine = QueryEngine::new(&graph);
-
-    let pattern = QueryParser::parse("(impl:Class)-[:Definition]->(interface:Interface)").unwrap();
-    let results = engine.execute(&pattern);
-
-    let formatted = format_query_results(&results);
-
-    // Check that the formatted output contains expected information
-    assert!(formatted.contains("Found 2 matches"));
-    assert!(formatted.contains("ConsoleLogger"));
-    assert!(formatted.contains("FileLogger"));
-    assert!(formatted.contains("ILogger"));
-    assert!(formatted.contains("Bindings:"));
+    for backend in GraphBackend::ALL {
+        let graph = backend.load(graph.clone());
+        let engine = QueryEngine::new(&*graph);
+
+        let pattern =
+            QueryParser::parse("(impl:Class)-[:Definition]->(interface:Interface)").unwrap();
+        let results = engine.execute(&pattern);
+
+        let formatted = format_query_results(&results);
+
+        // Check that the formatted output contains expected information
+        assert!(formatted.contains("Found 2 matches"));
+        assert!(formatted.contains("ConsoleLogger"));
+        assert!(formatted.contains("FileLogger"));
+        assert!(formatted.contains("ILogger"));
+        assert!(formatted.contains("Bindings:"));
+    }
 }
@@ -18,6 +18,8 @@ fn create_test_symbol(id: &str, name: &str, kind: SymbolKind, file_path: &str) -
         },
         documentation: None,
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     }
 }
 
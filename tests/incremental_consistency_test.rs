@@ -22,6 +22,8 @@ fn create_test_symbol(id: &str, name: &str, file: &str) -> Symbol {
         },
         documentation: None,
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     }
 }
 
@@ -197,6 +199,8 @@ fn test_bulk_update_consistency() {
                 },
                 documentation: None,
         detail: None,
+                modifiers: Default::default(),
+                qualified_name: None,
             });
         }
 
@@ -237,6 +241,8 @@ fn test_bulk_update_consistency() {
                 },
                 documentation: None,
         detail: None,
+                modifiers: Default::default(),
+                qualified_name: None,
             });
         }
         for sym_idx in 0..symbols_per_file / 2 {
@@ -257,6 +263,8 @@ fn test_bulk_update_consistency() {
                 },
                 documentation: None,
         detail: None,
+                modifiers: Default::default(),
+                qualified_name: None,
             });
         }
 
@@ -454,6 +462,8 @@ fn test_symbol_to_file_mapping_consistency() {
         },
         documentation: None,
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     // 古いファイルから削除
@@ -504,6 +514,8 @@ fn test_dead_code_detection_consistency() {
         },
         documentation: None,
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     let lib_pub = Symbol {
@@ -523,6 +535,8 @@ fn test_dead_code_detection_consistency() {
         },
         documentation: None,
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     let util1 = create_test_symbol("util1", "utility1", "utils.rs");
@@ -681,6 +695,8 @@ fn unused_internal() {
         },
         documentation: None,
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     }];
 
     let utils_symbols = vec![
@@ -701,6 +717,8 @@ fn unused_internal() {
             },
             documentation: None,
         detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         },
         Symbol {
             id: format!("{}:unused_internal", utils_path.display()),
@@ -719,6 +737,8 @@ fn unused_internal() {
             },
             documentation: None,
         detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         },
     ];
 
@@ -790,6 +810,8 @@ fn unused_internal() {
             },
             documentation: None,
         detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         },
         Symbol {
             id: format!("{}:internal_helper", utils_path.display()),
@@ -808,6 +830,8 @@ fn unused_internal() {
             },
             documentation: None,
         detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         },
         Symbol {
             id: format!("{}:unused_internal", utils_path.display()),
@@ -826,6 +850,8 @@ fn unused_internal() {
             },
             documentation: None,
         detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         },
     ];
 
@@ -21,6 +21,8 @@ fn create_test_symbol(id: &str, name: &str, kind: SymbolKind, file_path: &str) -
         },
         documentation: None,
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     }
 }
 
@@ -42,6 +44,8 @@ fn create_public_symbol(id: &str, file_path: &str) -> Symbol {
         },
         documentation: None,
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     }
 }
 
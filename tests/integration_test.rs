@@ -27,6 +27,8 @@ fn test_symbol_storage_and_retrieval() {
         },
         documentation: Some("User struct".to_string()),
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     let main_symbol = Symbol {
@@ -46,6 +48,8 @@ fn test_symbol_storage_and_retrieval() {
         },
         documentation: Some("Main function".to_string()),
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     // シンボルをグラフに追加
@@ -98,6 +102,8 @@ fn test_incremental_update() {
             },
             documentation: None,
         detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         },
         Symbol {
             id: "file1.rs#func2".to_string(),
@@ -116,6 +122,8 @@ fn test_incremental_update() {
             },
             documentation: None,
         detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         },
     ];
 
@@ -146,6 +154,8 @@ fn test_incremental_update() {
             },
             documentation: None,
         detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         },
         Symbol {
             id: "file1.rs#func3".to_string(), // 新規
@@ -164,6 +174,8 @@ fn test_incremental_update() {
             },
             documentation: None,
         detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         },
     ];
 
@@ -198,6 +210,8 @@ fn test_dead_code_detection() {
         },
         documentation: None,
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     // 使用されていない関数
@@ -218,6 +232,8 @@ fn test_dead_code_detection() {
         },
         documentation: None,
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     // main関数
@@ -238,6 +254,8 @@ fn test_dead_code_detection() {
         },
         documentation: None,
         detail: None,
+        modifiers: Default::default(),
+        qualified_name: None,
     };
 
     let used_idx = graph.add_symbol(used_func);
@@ -86,6 +86,8 @@ mod tests {
             },
             documentation: Some("Calculator struct".to_string()),
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         };
         let _calc_idx = graph.add_symbol(calc_symbol);
 
@@ -107,6 +109,8 @@ mod tests {
             },
             documentation: Some("Constructor".to_string()),
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         };
         let new_idx = graph.add_symbol(new_symbol);
 
@@ -128,6 +132,8 @@ mod tests {
             },
             documentation: None,
         detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         };
         let ref1_idx = graph.add_symbol(ref1_symbol);
 
@@ -149,6 +155,8 @@ mod tests {
             },
             documentation: None,
         detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         };
         let ref2_idx = graph.add_symbol(ref2_symbol);
 
@@ -206,6 +214,8 @@ mod tests {
             },
             documentation: Some("Add method".to_string()),
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         };
         let add_idx = graph.add_symbol(add_method);
 
@@ -227,6 +237,8 @@ mod tests {
             },
             documentation: Some("Get value method".to_string()),
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         };
         let get_value_idx = graph.add_symbol(get_value_method);
 
@@ -248,6 +260,8 @@ mod tests {
             },
             documentation: None,
         detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         };
         let add_call_idx = graph.add_symbol(add_call);
         graph.add_edge(add_call_idx, add_idx, EdgeKind::Reference);
@@ -270,6 +284,8 @@ mod tests {
             },
             documentation: None,
         detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         };
         let get_value_call1_idx = graph.add_symbol(get_value_call1);
         graph.add_edge(get_value_call1_idx, get_value_idx, EdgeKind::Reference);
@@ -291,6 +307,8 @@ mod tests {
             },
             documentation: None,
         detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         };
         let get_value_call2_idx = graph.add_symbol(get_value_call2);
         graph.add_edge(get_value_call2_idx, get_value_idx, EdgeKind::Reference);
@@ -334,6 +352,8 @@ mod tests {
             },
             documentation: Some("Factory function".to_string()),
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         };
         let def_idx = graph.add_symbol(definition.clone());
 
@@ -355,6 +375,8 @@ mod tests {
             },
             documentation: None,
         detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         };
         let ref_idx = graph.add_symbol(reference.clone());
 
@@ -468,6 +490,8 @@ class AdvancedCalculator implements CalculatorInterface {
             },
             documentation: Some("Calculator class".to_string()),
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         };
         let calc_idx = graph.add_symbol(calc_class);
 
@@ -489,6 +513,8 @@ class AdvancedCalculator implements CalculatorInterface {
             },
             documentation: None,
         detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         };
         let new_calc1_idx = graph.add_symbol(new_calc1);
         graph.add_edge(new_calc1_idx, calc_idx, EdgeKind::Reference);
@@ -510,6 +536,8 @@ class AdvancedCalculator implements CalculatorInterface {
             },
             documentation: None,
         detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         };
         let new_calc2_idx = graph.add_symbol(new_calc2);
         graph.add_edge(new_calc2_idx, calc_idx, EdgeKind::Reference);
@@ -545,6 +573,8 @@ class AdvancedCalculator implements CalculatorInterface {
             },
             documentation: Some("Calculator interface".to_string()),
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         };
         let interface_idx = graph.add_symbol(interface);
 
@@ -566,6 +596,8 @@ class AdvancedCalculator implements CalculatorInterface {
             },
             documentation: None,
         detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         };
         let impl_idx = graph.add_symbol(impl_class);
 
@@ -609,6 +641,8 @@ class AdvancedCalculator implements CalculatorInterface {
             },
             documentation: Some("Interface add method".to_string()),
             detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         };
         let interface_add_idx = graph.add_symbol(interface_add);
 
@@ -630,6 +664,8 @@ class AdvancedCalculator implements CalculatorInterface {
             },
             documentation: None,
         detail: None,
+            modifiers: Default::default(),
+            qualified_name: None,
         };
         let impl_add_idx = graph.add_symbol(impl_add);
 